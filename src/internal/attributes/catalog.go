@@ -1,21 +1,51 @@
 package attributes
 
 import (
+	"sort"
+
 	"github.com/rinq/rinq-go/src/internal/x/bufferpool"
 	"github.com/rinq/rinq-go/src/rinq/constraint"
+	"github.com/rinq/rinq-go/src/rinq/ident"
 )
 
 // Catalog is a namespaced collection of attributes.
+//
+// A catalog must not be modified once it has been shared. Use WithNamespace()
+// or WithNamespaces() to produce a new catalog with different attributes. The
+// tables it contains are immutable, see VTable.
 type Catalog map[string]VTable
 
 // WithNamespace returns a copy of the catalog with the ns namespace replaced by t.
+//
+// The tables of the other namespaces are shared with c, rather than cloned, so
+// the cost of the update does not depend on the number of attributes in those
+// namespaces.
 func (c Catalog) WithNamespace(ns string, t VTable) Catalog {
-	r := Catalog{ns: t}
+	r := make(Catalog, len(c)+1)
 
 	for n, t := range c {
-		if n != ns {
-			r[n] = t.Clone()
-		}
+		r[n] = t
+	}
+
+	r[ns] = t
+
+	return r
+}
+
+// WithNamespaces returns a copy of the catalog with each namespace in t
+// replaced by the associated table.
+//
+// As per WithNamespace(), the tables of the namespaces that are not in t are
+// shared with c.
+func (c Catalog) WithNamespaces(t map[string]VTable) Catalog {
+	r := make(Catalog, len(c)+len(t))
+
+	for n, t := range c {
+		r[n] = t
+	}
+
+	for n, t := range t {
+		r[n] = t
 	}
 
 	return r
@@ -40,6 +70,85 @@ func (c Catalog) IsEmpty() bool {
 	return true
 }
 
+// Namespaces returns a map of each namespace in the catalog to the revision at
+// which the first attribute in that namespace was created.
+func (c Catalog) Namespaces() map[string]ident.Revision {
+	r := map[string]ident.Revision{}
+
+	for ns, t := range c {
+		t.Range(func(attr VAttr) bool {
+			if rev, ok := r[ns]; !ok || attr.CreatedAt < rev {
+				r[ns] = attr.CreatedAt
+			}
+			return true
+		})
+	}
+
+	return r
+}
+
+// NamespacesAt returns the sorted names of the namespaces in created that
+// contained attributes as of the given revision. created is a map of namespace
+// to the revision at which the first attribute in that namespace was created,
+// as returned by Catalog.Namespaces().
+func NamespacesAt(created map[string]ident.Revision, rev ident.Revision) []string {
+	r := make([]string, 0, len(created))
+
+	for ns, cr := range created {
+		if cr <= rev {
+			r = append(r, ns)
+		}
+	}
+
+	sort.Strings(r)
+
+	return r
+}
+
+// Changes returns the attributes in each namespace that were updated after the
+// from revision and at or before the to revision, ordered by key.
+//
+// ok is false if any of the changes can not be determined because an
+// attribute that existed at the to revision has since been updated again.
+func (c Catalog) Changes(from, to ident.Revision) (changes map[string]VList, ok bool) {
+	changes = map[string]VList{}
+	ok = true
+
+	for ns, t := range c {
+		var l VList
+
+		t.Range(func(attr VAttr) bool {
+			if attr.UpdatedAt <= from {
+				// The attribute has not changed since the from revision.
+				return true
+			} else if attr.UpdatedAt <= to {
+				l = append(l, attr)
+			} else if attr.CreatedAt <= to {
+				// The attribute has been updated since the to revision, its
+				// value at the to revision is no longer known.
+				ok = false
+				return false
+			}
+
+			return true
+		})
+
+		if !ok {
+			return nil, false
+		}
+
+		if len(l) != 0 {
+			sort.Slice(l, func(i, j int) bool {
+				return l[i].Key < l[j].Key
+			})
+
+			changes[ns] = l
+		}
+	}
+
+	return changes, true
+}
+
 func (c Catalog) String() string {
 	buf := bufferpool.Get()
 	defer bufferpool.Put(buf)
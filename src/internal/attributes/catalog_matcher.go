@@ -30,12 +30,14 @@ func (m *catalogMatcher) Within(ns string, cons []constraint.Constraint, _ ...in
 
 func (m *catalogMatcher) Equal(k, v string, args ...interface{}) (interface{}, error) {
 	ns := unpackNamespace(args)
-	return m.cat[ns][k].Value == v, nil
+	attr, _ := m.cat[ns].Get(k)
+	return attr.Value == v, nil
 }
 
 func (m *catalogMatcher) NotEqual(k, v string, args ...interface{}) (interface{}, error) {
 	ns := unpackNamespace(args)
-	return m.cat[ns][k].Value != v, nil
+	attr, _ := m.cat[ns].Get(k)
+	return attr.Value != v, nil
 }
 
 func (m *catalogMatcher) Not(con constraint.Constraint, args ...interface{}) (interface{}, error) {
@@ -7,6 +7,7 @@ import (
 	. "github.com/rinq/rinq-go/src/internal/attributes"
 	"github.com/rinq/rinq-go/src/rinq"
 	"github.com/rinq/rinq-go/src/rinq/constraint"
+	"github.com/rinq/rinq-go/src/rinq/ident"
 )
 
 var _ = Describe("Catalog", func() {
@@ -15,12 +16,12 @@ var _ = Describe("Catalog", func() {
 
 		BeforeEach(func() {
 			cat = Catalog{
-				"ns1": {
-					"a": {Attr: rinq.Set("a", "1")},
-				},
-				"ns2": {
-					"b": {Attr: rinq.Set("b", "2")},
-				},
+				"ns1": NewVTable(
+					VAttr{Attr: rinq.Set("a", "1")},
+				),
+				"ns2": NewVTable(
+					VAttr{Attr: rinq.Set("b", "2")},
+				),
 			}
 		})
 
@@ -31,52 +32,101 @@ var _ = Describe("Catalog", func() {
 			Expect(cat).NotTo(HaveKey("ns3"))
 		})
 
-		It("clones the contained namespaces", func() {
+		It("shares the contained namespaces", func() {
 			c := cat.WithNamespace("ns2", VTable{})
 
-			c["ns1"]["c"] = VAttr{Attr: rinq.Set("c", "3")}
-			Expect(cat["ns1"]).NotTo(HaveKey("c"))
+			Expect(c["ns1"]).To(BeIdenticalTo(cat["ns1"]))
 		})
 
 		It("does not clone the merged namespace", func() {
-			ns := VTable{}
+			ns := NewVTable(VAttr{Attr: rinq.Set("c", "3")})
 
 			c := cat.WithNamespace("ns2", ns)
 
-			c["ns2"]["c"] = VAttr{Attr: rinq.Set("c", "3")}
-			Expect(ns).To(HaveKey("c"))
+			Expect(c["ns2"]).To(BeIdenticalTo(ns))
 		})
 
 		It("replaces an existing namespace", func() {
-			c := cat.WithNamespace("ns2", VTable{
-				"c": {Attr: rinq.Set("c", "3")},
-			})
+			c := cat.WithNamespace("ns2", NewVTable(
+				VAttr{Attr: rinq.Set("c", "3")},
+			))
 
 			Expect(c).To(Equal(Catalog{
-				"ns1": {
-					"a": {Attr: rinq.Set("a", "1")},
-				},
-				"ns2": {
-					"c": {Attr: rinq.Set("c", "3")},
-				},
+				"ns1": NewVTable(
+					VAttr{Attr: rinq.Set("a", "1")},
+				),
+				"ns2": NewVTable(
+					VAttr{Attr: rinq.Set("c", "3")},
+				),
 			}))
 		})
 
 		It("merges a new namespace", func() {
-			c := cat.WithNamespace("ns3", VTable{
-				"c": {Attr: rinq.Set("c", "3")},
+			c := cat.WithNamespace("ns3", NewVTable(
+				VAttr{Attr: rinq.Set("c", "3")},
+			))
+
+			Expect(c).To(Equal(Catalog{
+				"ns1": NewVTable(
+					VAttr{Attr: rinq.Set("a", "1")},
+				),
+				"ns2": NewVTable(
+					VAttr{Attr: rinq.Set("b", "2")},
+				),
+				"ns3": NewVTable(
+					VAttr{Attr: rinq.Set("c", "3")},
+				),
+			}))
+		})
+	})
+
+	Describe("WithNamespaces", func() {
+		var cat Catalog
+
+		BeforeEach(func() {
+			cat = Catalog{
+				"ns1": NewVTable(
+					VAttr{Attr: rinq.Set("a", "1")},
+				),
+				"ns2": NewVTable(
+					VAttr{Attr: rinq.Set("b", "2")},
+				),
+			}
+		})
+
+		It("returns a different instance", func() {
+			c := cat.WithNamespaces(map[string]VTable{"ns2": {}})
+			c["ns3"] = VTable{}
+
+			Expect(cat).NotTo(HaveKey("ns3"))
+		})
+
+		It("shares the untouched namespaces", func() {
+			c := cat.WithNamespaces(map[string]VTable{"ns2": {}})
+
+			Expect(c["ns1"]).To(BeIdenticalTo(cat["ns1"]))
+		})
+
+		It("replaces existing namespaces and merges new namespaces", func() {
+			c := cat.WithNamespaces(map[string]VTable{
+				"ns2": NewVTable(
+					VAttr{Attr: rinq.Set("c", "3")},
+				),
+				"ns3": NewVTable(
+					VAttr{Attr: rinq.Set("d", "4")},
+				),
 			})
 
 			Expect(c).To(Equal(Catalog{
-				"ns1": {
-					"a": {Attr: rinq.Set("a", "1")},
-				},
-				"ns2": {
-					"b": {Attr: rinq.Set("b", "2")},
-				},
-				"ns3": {
-					"c": {Attr: rinq.Set("c", "3")},
-				},
+				"ns1": NewVTable(
+					VAttr{Attr: rinq.Set("a", "1")},
+				),
+				"ns2": NewVTable(
+					VAttr{Attr: rinq.Set("c", "3")},
+				),
+				"ns3": NewVTable(
+					VAttr{Attr: rinq.Set("d", "4")},
+				),
 			}))
 		})
 	})
@@ -98,7 +148,7 @@ var _ = Describe("Catalog", func() {
 			Entry(
 				"Within",
 				Catalog{
-					"ns": {"a": {Attr: rinq.Set("a", "1")}},
+					"ns": NewVTable(VAttr{Attr: rinq.Set("a", "1")}),
 				},
 				"ns",
 				constraint.Within("ns", constraint.Equal("a", "1")),
@@ -107,7 +157,7 @@ var _ = Describe("Catalog", func() {
 			Entry(
 				"Equal",
 				Catalog{
-					"ns": {"a": {Attr: rinq.Set("a", "1")}},
+					"ns": NewVTable(VAttr{Attr: rinq.Set("a", "1")}),
 				},
 				"ns",
 				constraint.Equal("a", "1"),
@@ -116,7 +166,7 @@ var _ = Describe("Catalog", func() {
 			Entry(
 				"NotEqual",
 				Catalog{
-					"ns": {"a": {Attr: rinq.Set("a", "1")}},
+					"ns": NewVTable(VAttr{Attr: rinq.Set("a", "1")}),
 				},
 				"ns",
 				constraint.NotEqual("a", "2"),
@@ -125,7 +175,7 @@ var _ = Describe("Catalog", func() {
 			Entry(
 				"Not",
 				Catalog{
-					"ns": {"a": {Attr: rinq.Set("a", "1")}},
+					"ns": NewVTable(VAttr{Attr: rinq.Set("a", "1")}),
 				},
 				"ns",
 				constraint.Not(constraint.Equal("a", "2")),
@@ -134,10 +184,10 @@ var _ = Describe("Catalog", func() {
 			Entry(
 				"And",
 				Catalog{
-					"ns": {
-						"a": {Attr: rinq.Set("a", "1")},
-						"b": {Attr: rinq.Set("b", "2")},
-					},
+					"ns": NewVTable(
+						VAttr{Attr: rinq.Set("a", "1")},
+						VAttr{Attr: rinq.Set("b", "2")},
+					),
 				},
 				"ns",
 				constraint.And(
@@ -149,7 +199,7 @@ var _ = Describe("Catalog", func() {
 			Entry(
 				"Or",
 				Catalog{
-					"ns": {"a": {Attr: rinq.Set("a", "1")}},
+					"ns": NewVTable(VAttr{Attr: rinq.Set("a", "1")}),
 				},
 				"ns",
 				constraint.Or(
@@ -168,7 +218,7 @@ var _ = Describe("Catalog", func() {
 			Entry(
 				"Within with failing constraint",
 				Catalog{
-					"ns": {"a": {Attr: rinq.Set("a", "1")}},
+					"ns": NewVTable(VAttr{Attr: rinq.Set("a", "1")}),
 				},
 				"ns",
 				constraint.Within("ns", constraint.Equal("a", "2")),
@@ -176,7 +226,7 @@ var _ = Describe("Catalog", func() {
 			Entry(
 				"Within with different namespace",
 				Catalog{
-					"ns": {"a": {Attr: rinq.Set("a", "1")}},
+					"ns": NewVTable(VAttr{Attr: rinq.Set("a", "1")}),
 				},
 				"ns",
 				constraint.Within("other", constraint.Equal("a", "1")),
@@ -185,7 +235,7 @@ var _ = Describe("Catalog", func() {
 			Entry(
 				"Equal",
 				Catalog{
-					"ns": {"a": {Attr: rinq.Set("a", "1")}},
+					"ns": NewVTable(VAttr{Attr: rinq.Set("a", "1")}),
 				},
 				"ns",
 				constraint.Equal("a", "2"),
@@ -194,7 +244,7 @@ var _ = Describe("Catalog", func() {
 			Entry(
 				"NotEqual",
 				Catalog{
-					"ns": {"a": {Attr: rinq.Set("a", "1")}},
+					"ns": NewVTable(VAttr{Attr: rinq.Set("a", "1")}),
 				},
 				"ns",
 				constraint.NotEqual("a", "1"),
@@ -203,7 +253,7 @@ var _ = Describe("Catalog", func() {
 			Entry(
 				"Not",
 				Catalog{
-					"ns": {"a": {Attr: rinq.Set("a", "1")}},
+					"ns": NewVTable(VAttr{Attr: rinq.Set("a", "1")}),
 				},
 				"ns",
 				constraint.Not(constraint.Equal("a", "1")),
@@ -212,10 +262,10 @@ var _ = Describe("Catalog", func() {
 			Entry(
 				"And",
 				Catalog{
-					"ns": {
-						"a": {Attr: rinq.Set("a", "1")},
-						"b": {Attr: rinq.Set("b", "2")},
-					},
+					"ns": NewVTable(
+						VAttr{Attr: rinq.Set("a", "1")},
+						VAttr{Attr: rinq.Set("b", "2")},
+					),
 				},
 				"ns",
 				constraint.And(
@@ -227,7 +277,7 @@ var _ = Describe("Catalog", func() {
 			Entry(
 				"Or",
 				Catalog{
-					"ns": {"a": {Attr: rinq.Set("a", "1")}},
+					"ns": NewVTable(VAttr{Attr: rinq.Set("a", "1")}),
 				},
 				"ns",
 				constraint.Or(
@@ -249,15 +299,70 @@ var _ = Describe("Catalog", func() {
 
 		It("returns false when the table is not empty", func() {
 			cat := Catalog{
-				"ns1": {
-					"a": {Attr: rinq.Set("a", "1")},
-				},
+				"ns1": NewVTable(
+					VAttr{Attr: rinq.Set("a", "1")},
+				),
 			}
 
 			Expect(cat.IsEmpty()).To(BeFalse())
 		})
 	})
 
+	Describe("Namespaces", func() {
+		It("returns the revision at which each namespace was first populated", func() {
+			cat := Catalog{
+				"ns1": NewVTable(
+					VAttr{Attr: rinq.Set("a", "1"), CreatedAt: 3},
+					VAttr{Attr: rinq.Set("b", "2"), CreatedAt: 2},
+				),
+				"ns2": NewVTable(
+					VAttr{Attr: rinq.Set("c", "3"), CreatedAt: 5},
+				),
+				"ns3": {},
+			}
+
+			Expect(cat.Namespaces()).To(Equal(
+				map[string]ident.Revision{
+					"ns1": 2,
+					"ns2": 5,
+				},
+			))
+		})
+	})
+
+	Describe("Changes", func() {
+		cat := Catalog{
+			"ns1": NewVTable(
+				VAttr{Attr: rinq.Set("a", "1"), CreatedAt: 1, UpdatedAt: 1},
+				VAttr{Attr: rinq.Set("c", ""), CreatedAt: 1, UpdatedAt: 3},
+				VAttr{Attr: rinq.Set("b", "2"), CreatedAt: 2, UpdatedAt: 2},
+			),
+			"ns2": NewVTable(
+				VAttr{Attr: rinq.Set("d", "4"), CreatedAt: 4, UpdatedAt: 5},
+			),
+		}
+
+		It("returns the attributes updated within the revision range", func() {
+			changes, ok := cat.Changes(1, 3)
+
+			Expect(ok).To(BeTrue())
+			Expect(changes).To(Equal(
+				map[string]VList{
+					"ns1": {
+						{Attr: rinq.Set("b", "2"), CreatedAt: 2, UpdatedAt: 2},
+						{Attr: rinq.Set("c", ""), CreatedAt: 1, UpdatedAt: 3},
+					},
+				},
+			))
+		})
+
+		It("returns false if an attribute has been updated after the range", func() {
+			_, ok := cat.Changes(1, 4)
+
+			Expect(ok).To(BeFalse())
+		})
+	})
+
 	Describe("String", func() {
 		Context("when the table is empty", func() {
 			cat := Catalog{}
@@ -270,12 +375,12 @@ var _ = Describe("Catalog", func() {
 		Context("when the table is not empty", func() {
 			It("writes namespaces in any order", func() {
 				cat := Catalog{
-					"ns1": {
-						"a": {Attr: rinq.Set("a", "1")},
-					},
-					"ns2": {
-						"b": {Attr: rinq.Set("b", "2")},
-					},
+					"ns1": NewVTable(
+						VAttr{Attr: rinq.Set("a", "1")},
+					),
+					"ns2": NewVTable(
+						VAttr{Attr: rinq.Set("b", "2")},
+					),
 				}
 
 				Expect(cat.String()).To(SatisfyAny(
@@ -287,9 +392,9 @@ var _ = Describe("Catalog", func() {
 
 		It("excludes empty namespaces", func() {
 			cat := Catalog{
-				"ns1": {
-					"a": {Attr: rinq.Set("a", "1")},
-				},
+				"ns1": NewVTable(
+					VAttr{Attr: rinq.Set("a", "1")},
+				),
 				"ns2": {},
 			}
 
@@ -297,3 +402,15 @@ var _ = Describe("Catalog", func() {
 		})
 	})
 })
+
+var _ = Describe("NamespacesAt", func() {
+	It("returns the sorted namespaces created at or before the revision", func() {
+		created := map[string]ident.Revision{
+			"ns3": 1,
+			"ns1": 2,
+			"ns2": 3,
+		}
+
+		Expect(NamespacesAt(created, 2)).To(Equal([]string{"ns1", "ns3"}))
+	})
+})
@@ -1,38 +1,240 @@
 package attributes
 
 import (
+	"math/bits"
+	"sort"
+
 	"github.com/rinq/rinq-go/src/rinq"
 )
 
-// VTable is a collection of attributes with revision information.
-type VTable map[string]VAttr
+// VTable is an immutable collection of attributes with revision information.
+//
+// The table is a persistent hash array mapped trie. With() returns a new table
+// that shares all but the path to each updated key with the original, so the
+// cost of an update does not depend on the number of attributes in the table.
+// The zero value is an empty table.
+type VTable struct {
+	root *vnode
+	size int
+}
+
+// NewVTable returns a table containing attrs.
+func NewVTable(attrs ...VAttr) VTable {
+	return VTable{}.With(attrs...)
+}
+
+// Get returns the attribute with key k.
+func (t VTable) Get(k string) (VAttr, bool) {
+	if t.root == nil {
+		return VAttr{}, false
+	}
+
+	return t.root.get(hashKey(k), 0, k)
+}
+
+// With returns a copy of the table with each of attrs added or replaced.
+func (t VTable) With(attrs ...VAttr) VTable {
+	for _, attr := range attrs {
+		root := t.root
+		if root == nil {
+			root = &vnode{}
+		}
+
+		var added bool
+		t.root, added = root.with(hashKey(attr.Key), 0, attr)
+
+		if added {
+			t.size++
+		}
+	}
+
+	return t
+}
+
+// Range calls fn for each attribute in the table. Iteration stops when fn
+// returns false.
+func (t VTable) Range(fn func(VAttr) bool) {
+	if t.root != nil {
+		t.root.each(fn)
+	}
+}
 
 // Each calls fn for each attribute in the collection. Iteration stops
 // when fn returns false.
 func (t VTable) Each(fn func(rinq.Attr) bool) {
-	for _, attr := range t {
-		if !fn(attr.Attr) {
-			return
-		}
-	}
+	t.Range(func(attr VAttr) bool {
+		return fn(attr.Attr)
+	})
+}
+
+// Len returns the number of attributes in the table.
+func (t VTable) Len() int {
+	return t.size
 }
 
 // IsEmpty returns true if there are no attributes in the table.
 func (t VTable) IsEmpty() bool {
-	return len(t) == 0
+	return t.size == 0
 }
 
 func (t VTable) String() string {
 	return ToString(t)
 }
 
-// Clone returns a copy of t.
-func (t VTable) Clone() VTable {
-	c := VTable{}
+const (
+	// vnodeBits is the number of bits of a key's hash consumed at each level
+	// of the trie.
+	vnodeBits = 5
+
+	// vnodeMask selects the vnodeBits low bits of a shifted hash.
+	vnodeMask = 1<<vnodeBits - 1
+)
+
+// vnode is a node in the trie that backs a VTable. Nodes are never modified
+// once they are reachable from a table.
+type vnode struct {
+	// bitmap has one bit set for each of the 32 possible children that is
+	// present in children.
+	bitmap uint32
+
+	// children contains the present children, in bit order.
+	children []vchild
+}
+
+// vchild is either a sub-trie, or a leaf containing the attributes with keys
+// that hash to the same value, ordered by key.
+type vchild struct {
+	node  *vnode
+	attrs []VAttr
+}
+
+// get returns the attribute with key k, where h is the hash of k and shift is
+// the depth of n in bits.
+func (n *vnode) get(h uint32, shift uint, k string) (VAttr, bool) {
+	for {
+		bit := uint32(1) << ((h >> shift) & vnodeMask)
+		if n.bitmap&bit == 0 {
+			return VAttr{}, false
+		}
+
+		c := n.children[n.index(bit)]
+		if c.node == nil {
+			for _, attr := range c.attrs {
+				if attr.Key == k {
+					return attr, true
+				}
+			}
+
+			return VAttr{}, false
+		}
+
+		n = c.node
+		shift += vnodeBits
+	}
+}
+
+// with returns a copy of n with attr added or replaced, where h is the hash of
+// attr.Key and shift is the depth of n in bits. added is true if n did not
+// already contain an attribute with the same key.
+func (n *vnode) with(h uint32, shift uint, attr VAttr) (r *vnode, added bool) {
+	bit := uint32(1) << ((h >> shift) & vnodeMask)
+	i := n.index(bit)
+
+	if n.bitmap&bit == 0 {
+		children := make([]vchild, len(n.children)+1)
+		copy(children, n.children[:i])
+		children[i] = vchild{attrs: []VAttr{attr}}
+		copy(children[i+1:], n.children[i:])
+
+		return &vnode{n.bitmap | bit, children}, true
+	}
+
+	c := n.children[i]
+
+	switch {
+	case c.node != nil:
+		c.node, added = c.node.with(h, shift+vnodeBits, attr)
+
+	case hashKey(c.attrs[0].Key) == h:
+		c.attrs, added = withCollision(c.attrs, attr)
+
+	default:
+		// The leaf's keys have a different hash, push the leaf down into a
+		// new node where the hashes can be told apart.
+		s := shift + vnodeBits
+		sub := &vnode{
+			bitmap:   uint32(1) << ((hashKey(c.attrs[0].Key) >> s) & vnodeMask),
+			children: []vchild{c},
+		}
+
+		c = vchild{}
+		c.node, added = sub.with(h, s, attr)
+	}
+
+	children := make([]vchild, len(n.children))
+	copy(children, n.children)
+	children[i] = c
+
+	return &vnode{n.bitmap, children}, added
+}
+
+// each calls fn for each attribute in the sub-trie rooted at n. It returns
+// false if fn returned false.
+func (n *vnode) each(fn func(VAttr) bool) bool {
+	for _, c := range n.children {
+		if c.node != nil {
+			if !c.node.each(fn) {
+				return false
+			}
+
+			continue
+		}
+
+		for _, attr := range c.attrs {
+			if !fn(attr) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// index returns the position in n.children of the child with the given bit.
+func (n *vnode) index(bit uint32) int {
+	return bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+// withCollision returns a copy of attrs with attr added or replaced, keeping
+// the attributes ordered by key.
+func withCollision(attrs []VAttr, attr VAttr) ([]VAttr, bool) {
+	i := sort.Search(len(attrs), func(i int) bool {
+		return attrs[i].Key >= attr.Key
+	})
+
+	if i < len(attrs) && attrs[i].Key == attr.Key {
+		r := make([]VAttr, len(attrs))
+		copy(r, attrs)
+		r[i] = attr
+		return r, false
+	}
+
+	r := make([]VAttr, len(attrs)+1)
+	copy(r, attrs[:i])
+	r[i] = attr
+	copy(r[i+1:], attrs[i:])
+
+	return r, true
+}
+
+// hashKey returns the 32-bit FNV-1a hash of k.
+func hashKey(k string) uint32 {
+	h := uint32(2166136261)
 
-	for k, v := range t {
-		c[k] = v
+	for i := 0; i < len(k); i++ {
+		h ^= uint32(k[i])
+		h *= 16777619
 	}
 
-	return c
+	return h
 }
@@ -1,6 +1,8 @@
 package attributes_test
 
 import (
+	"strconv"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	. "github.com/rinq/rinq-go/src/internal/attributes"
@@ -11,10 +13,119 @@ var _ = Describe("VTable", func() {
 	var table VTable
 
 	BeforeEach(func() {
-		table = VTable{
-			"a": {Attr: rinq.Set("a", "1")},
-			"b": {Attr: rinq.Set("b", "2")},
-		}
+		table = NewVTable(
+			VAttr{Attr: rinq.Set("a", "1")},
+			VAttr{Attr: rinq.Set("b", "2")},
+		)
+	})
+
+	Describe("Get", func() {
+		It("returns the attribute with the given key", func() {
+			attr, ok := table.Get("a")
+
+			Expect(ok).To(BeTrue())
+			Expect(attr).To(Equal(VAttr{Attr: rinq.Set("a", "1")}))
+		})
+
+		It("returns false if the table does not contain the key", func() {
+			_, ok := table.Get("c")
+
+			Expect(ok).To(BeFalse())
+		})
+
+		It("returns false if the table is empty", func() {
+			_, ok := VTable{}.Get("a")
+
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("With", func() {
+		It("does not modify the original table", func() {
+			t := table.With(
+				VAttr{Attr: rinq.Set("a", "3")},
+				VAttr{Attr: rinq.Set("c", "4")},
+			)
+
+			Expect(t.Len()).To(Equal(3))
+			Expect(table.Len()).To(Equal(2))
+
+			attr, _ := table.Get("a")
+			Expect(attr.Value).To(Equal("1"))
+
+			_, ok := table.Get("c")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("replaces existing attributes", func() {
+			t := table.With(VAttr{Attr: rinq.Set("a", "3"), UpdatedAt: 2})
+
+			attr, _ := t.Get("a")
+			Expect(attr).To(Equal(VAttr{Attr: rinq.Set("a", "3"), UpdatedAt: 2}))
+			Expect(t.Len()).To(Equal(2))
+		})
+
+		It("stores attributes with keys that have the same hash", func() {
+			// "k32728" and "k261234" have the same FNV-1a hash.
+			t := table.With(
+				VAttr{Attr: rinq.Set("k32728", "x")},
+				VAttr{Attr: rinq.Set("k261234", "y")},
+				VAttr{Attr: rinq.Set("k32728", "z")},
+			)
+
+			a, _ := t.Get("k32728")
+			b, _ := t.Get("k261234")
+
+			Expect(a.Value).To(Equal("z"))
+			Expect(b.Value).To(Equal("y"))
+			Expect(t.Len()).To(Equal(4))
+		})
+
+		It("produces equal tables regardless of the order of updates", func() {
+			var a, b VTable
+
+			for i := 0; i < 1000; i++ {
+				k := strconv.Itoa(i)
+				a = a.With(VAttr{Attr: rinq.Set(k, k)})
+				b = b.With(VAttr{Attr: rinq.Set(strconv.Itoa(999-i), strconv.Itoa(999-i))})
+			}
+
+			Expect(a).To(Equal(b))
+			Expect(a.Len()).To(Equal(1000))
+
+			for i := 0; i < 1000; i++ {
+				k := strconv.Itoa(i)
+				attr, ok := a.Get(k)
+
+				Expect(ok).To(BeTrue())
+				Expect(attr.Value).To(Equal(k))
+			}
+		})
+	})
+
+	Describe("Range", func() {
+		It("calls the function for each attribute in the table", func() {
+			var attrs []VAttr
+			table.Range(func(attr VAttr) bool {
+				attrs = append(attrs, attr)
+				return true
+			})
+
+			Expect(attrs).To(ConsistOf(
+				VAttr{Attr: rinq.Set("a", "1")},
+				VAttr{Attr: rinq.Set("b", "2")},
+			))
+		})
+
+		It("stops iteration if the function returns false", func() {
+			var attrs []VAttr
+			table.Range(func(attr VAttr) bool {
+				attrs = append(attrs, attr)
+				return false
+			})
+
+			Expect(len(attrs)).To(Equal(1))
+		})
 	})
 
 	Describe("Each", func() {
@@ -44,7 +155,7 @@ var _ = Describe("VTable", func() {
 
 	Describe("IsEmpty", func() {
 		It("returns true when the table is empty", func() {
-			Expect(Table{}.IsEmpty()).To(BeTrue())
+			Expect(VTable{}.IsEmpty()).To(BeTrue())
 		})
 
 		It("returns false when the table is not empty", func() {
@@ -60,27 +171,4 @@ var _ = Describe("VTable", func() {
 			))
 		})
 	})
-
-	Describe("Clone", func() {
-		It("returns a different instance", func() {
-			t := table.Clone()
-			t["c"] = VAttr{Attr: rinq.Set("c", "3")}
-
-			Expect(table).NotTo(HaveKey("c"))
-		})
-
-		It("contains the same attributes", func() {
-			t := table.Clone()
-
-			Expect(t).To(Equal(table))
-		})
-
-		It("returns a non-nil table when cloning a nil table", func() {
-			table = nil
-			t := table.Clone()
-
-			Expect(t).To(BeEmpty())
-			Expect(t).NotTo(BeNil())
-		})
-	})
 })
@@ -0,0 +1,148 @@
+package chaos
+
+import (
+	"context"
+	"time"
+
+	"github.com/rinq/rinq-go/src/internal/command"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// invoker is a command.Invoker that injects faults into the command requests
+// sent by another invoker.
+type invoker struct {
+	command.Invoker
+	sender
+
+	timeout time.Duration
+}
+
+// NewInvoker returns an invoker that sends command requests using i, subject
+// to the faults chosen by p.
+//
+// Requests made by calls that block for a response are delayed or dropped, but
+// never duplicated or reordered. A dropped call blocks until its context is
+// done, or until timeout has elapsed if the context has no deadline, as per
+// options.DefaultTimeout().
+func NewInvoker(i command.Invoker, p *Policy, timeout time.Duration) command.Invoker {
+	return &invoker{
+		Invoker: i,
+		sender:  sender{policy: p},
+		timeout: timeout,
+	}
+}
+
+func (i *invoker) CallUnicast(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	target ident.PeerID,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+) (*rinq.Payload, error) {
+	ctx, cancel := i.withDefaultTimeout(ctx)
+	defer cancel()
+
+	if err := i.delay(ctx); err != nil {
+		return nil, err
+	}
+
+	return i.Invoker.CallUnicast(ctx, msgID, traceID, target, ns, cmd, out)
+}
+
+func (i *invoker) CallBalanced(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+) (*rinq.Payload, error) {
+	ctx, cancel := i.withDefaultTimeout(ctx)
+	defer cancel()
+
+	if err := i.delay(ctx); err != nil {
+		return nil, err
+	}
+
+	return i.Invoker.CallBalanced(ctx, msgID, traceID, ns, cmd, out)
+}
+
+func (i *invoker) CallBalancedAsync(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+	h rinq.AsyncHandler,
+) error {
+	return i.send(ctx, out, func(ctx context.Context, out *rinq.Payload) error {
+		return i.Invoker.CallBalancedAsync(ctx, msgID, traceID, ns, cmd, out, h)
+	})
+}
+
+func (i *invoker) ExecuteBalanced(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+) error {
+	return i.send(ctx, out, func(ctx context.Context, out *rinq.Payload) error {
+		return i.Invoker.ExecuteBalanced(ctx, msgID, traceID, ns, cmd, out)
+	})
+}
+
+func (i *invoker) ExecuteBalancedAt(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	t time.Time,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+) error {
+	return i.send(ctx, out, func(ctx context.Context, out *rinq.Payload) error {
+		return i.Invoker.ExecuteBalancedAt(ctx, msgID, traceID, t, ns, cmd, out)
+	})
+}
+
+func (i *invoker) ExecuteMulticast(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+) error {
+	return i.send(ctx, out, func(ctx context.Context, out *rinq.Payload) error {
+		return i.Invoker.ExecuteMulticast(ctx, msgID, traceID, ns, cmd, out)
+	})
+}
+
+// withDefaultTimeout returns a context derived from ctx that has a deadline,
+// applying the default timeout if ctx does not already have one.
+func (i *invoker) withDefaultTimeout(ctx context.Context) (context.Context, func()) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, i.timeout)
+}
+
+// delay applies the latency and drop faults to a call that blocks for a
+// response.
+func (i *invoker) delay(ctx context.Context) error {
+	f := i.policy.next()
+
+	if f.Drop {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	return sleep(ctx, f.Delay)
+}
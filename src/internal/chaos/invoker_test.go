@@ -0,0 +1,70 @@
+package chaos_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/rinq/rinq-go/src/internal/chaos"
+	"github.com/rinq/rinq-go/src/internal/command"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+)
+
+var _ = Describe("Invoker", func() {
+	var recorder *recordingInvoker
+
+	BeforeEach(func() {
+		recorder = &recordingInvoker{}
+	})
+
+	It("forwards calls if no faults are configured", func() {
+		subject := NewInvoker(recorder, NewPolicy(options.FaultOptions{}), time.Second)
+
+		_, err := subject.CallBalanced(context.Background(), ident.MessageID{}, "", "ns", "cmd", nil)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(recorder.calls).To(Equal(1))
+	})
+
+	It("applies the default timeout to dropped calls if the context has no deadline", func() {
+		subject := NewInvoker(recorder, NewPolicy(options.FaultOptions{DropRate: 1}), 10*time.Millisecond)
+
+		_, err := subject.CallBalanced(context.Background(), ident.MessageID{}, "", "ns", "cmd", nil)
+
+		Expect(err).To(Equal(context.DeadlineExceeded))
+		Expect(recorder.calls).To(Equal(0))
+	})
+
+	It("uses the context's deadline for dropped calls if it has one", func() {
+		subject := NewInvoker(recorder, NewPolicy(options.FaultOptions{DropRate: 1}), time.Hour)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := subject.CallUnicast(ctx, ident.MessageID{}, "", ident.NewPeerID(), "ns", "cmd", nil)
+
+		Expect(err).To(Equal(context.DeadlineExceeded))
+	})
+})
+
+// recordingInvoker is a command.Invoker that counts the calls made to it.
+type recordingInvoker struct {
+	command.Invoker
+
+	calls int
+}
+
+func (i *recordingInvoker) CallBalanced(
+	context.Context,
+	ident.MessageID,
+	string,
+	string,
+	string,
+	*rinq.Payload,
+) (*rinq.Payload, error) {
+	i.calls++
+	return nil, nil
+}
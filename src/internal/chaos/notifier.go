@@ -0,0 +1,110 @@
+package chaos
+
+import (
+	"context"
+
+	"github.com/rinq/rinq-go/src/internal/notify"
+	"github.com/rinq/rinq-go/src/internal/service"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/constraint"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// notifier is a notify.Notifier that injects faults into the notifications
+// sent by another notifier.
+type notifier struct {
+	notify.Notifier
+	sender
+}
+
+// serviceNotifier is a notifier that wraps a notifier that is also a service.
+type serviceNotifier struct {
+	*notifier
+	service.Service
+}
+
+// NewNotifier returns a notifier that sends notifications using n, subject to
+// the faults chosen by p. If n is a service.Service, so is the returned
+// notifier.
+func NewNotifier(n notify.Notifier, p *Policy) notify.Notifier {
+	cn := &notifier{
+		Notifier: n,
+		sender:   sender{policy: p},
+	}
+
+	if s, ok := n.(service.Service); ok {
+		return serviceNotifier{cn, s}
+	}
+
+	return cn
+}
+
+func (n *notifier) NotifyUnicast(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	target ident.SessionID,
+	ns string,
+	t string,
+	out *rinq.Payload,
+) error {
+	return n.send(ctx, out, func(ctx context.Context, out *rinq.Payload) error {
+		return n.Notifier.NotifyUnicast(ctx, msgID, traceID, target, ns, t, out)
+	})
+}
+
+func (n *notifier) NotifyRequest(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	target ident.SessionID,
+	ns string,
+	t string,
+	out *rinq.Payload,
+) error {
+	return n.send(ctx, out, func(ctx context.Context, out *rinq.Payload) error {
+		return n.Notifier.NotifyRequest(ctx, msgID, traceID, target, ns, t, out)
+	})
+}
+
+func (n *notifier) NotifyReply(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	target ident.SessionID,
+	ns string,
+	corrID ident.MessageID,
+	out *rinq.Payload,
+) error {
+	return n.send(ctx, out, func(ctx context.Context, out *rinq.Payload) error {
+		return n.Notifier.NotifyReply(ctx, msgID, traceID, target, ns, corrID, out)
+	})
+}
+
+func (n *notifier) NotifyMulticast(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	con constraint.Constraint,
+	ns string,
+	t string,
+	out *rinq.Payload,
+) error {
+	return n.send(ctx, out, func(ctx context.Context, out *rinq.Payload) error {
+		return n.Notifier.NotifyMulticast(ctx, msgID, traceID, con, ns, t, out)
+	})
+}
+
+func (n *notifier) NotifyMulticastReport(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	con constraint.Constraint,
+	ns string,
+	t string,
+	out *rinq.Payload,
+) error {
+	return n.send(ctx, out, func(ctx context.Context, out *rinq.Payload) error {
+		return n.Notifier.NotifyMulticastReport(ctx, msgID, traceID, con, ns, t, out)
+	})
+}
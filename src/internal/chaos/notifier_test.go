@@ -0,0 +1,144 @@
+package chaos_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/rinq/rinq-go/src/internal/chaos"
+	"github.com/rinq/rinq-go/src/internal/notify"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+)
+
+var _ = Describe("Notifier", func() {
+	var (
+		recorder *recordingNotifier
+		target   ident.SessionID
+	)
+
+	BeforeEach(func() {
+		recorder = &recordingNotifier{}
+		target = ident.NewPeerID().Session(1)
+	})
+
+	// sendAll sends n notifications using a notifier with the given fault
+	// options and returns the types of the notifications that were sent.
+	sendAll := func(opts options.FaultOptions, n int) []string {
+		subject := NewNotifier(recorder, NewPolicy(opts))
+
+		for i := 0; i < n; i++ {
+			err := subject.NotifyUnicast(
+				context.Background(),
+				ident.MessageID{},
+				"",
+				target,
+				"ns",
+				fmt.Sprintf("%d", i),
+				nil,
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+
+		return recorder.Types()
+	}
+
+	It("sends notifications unchanged if no faults are configured", func() {
+		types := sendAll(options.FaultOptions{}, 3)
+
+		Expect(types).To(Equal([]string{"0", "1", "2"}))
+	})
+
+	It("drops notifications", func() {
+		subject := NewNotifier(recorder, NewPolicy(options.FaultOptions{DropRate: 1}))
+
+		err := subject.NotifyUnicast(context.Background(), ident.MessageID{}, "", target, "ns", "0", nil)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Consistently(recorder.Count).Should(Equal(0))
+	})
+
+	It("duplicates notifications", func() {
+		types := sendAll(options.FaultOptions{DuplicateRate: 1}, 2)
+
+		Expect(types).To(Equal([]string{"0", "0", "1", "1"}))
+	})
+
+	It("reorders notifications", func() {
+		sendAll(options.FaultOptions{Seed: 1, ReorderRate: 0.5}, 20)
+
+		var expected []string
+		for i := 0; i < 20; i++ {
+			expected = append(expected, fmt.Sprintf("%d", i))
+		}
+
+		Eventually(recorder.Count).Should(Equal(20))
+		Expect(recorder.Types()).To(ConsistOf(expected))
+		Expect(recorder.Types()).NotTo(Equal(expected))
+	})
+
+	It("sends a held back notification if no other notification is sent", func() {
+		subject := NewNotifier(recorder, NewPolicy(options.FaultOptions{ReorderRate: 1}))
+
+		err := subject.NotifyUnicast(context.Background(), ident.MessageID{}, "", target, "ns", "0", nil)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Eventually(recorder.Types).Should(Equal([]string{"0"}))
+	})
+
+	It("injects the same faults given the same seed", func() {
+		opts := options.FaultOptions{Seed: 42, DropRate: 0.5, DuplicateRate: 0.5}
+
+		first := sendAll(opts, 20)
+		recorder.Reset()
+		second := sendAll(opts, 20)
+
+		Expect(second).To(Equal(first))
+	})
+})
+
+// recordingNotifier is a notify.Notifier that records the type of each
+// unicast notification that it sends.
+type recordingNotifier struct {
+	notify.Notifier
+
+	mutex sync.Mutex
+	types []string
+}
+
+func (n *recordingNotifier) NotifyUnicast(
+	_ context.Context,
+	_ ident.MessageID,
+	_ string,
+	_ ident.SessionID,
+	_ string,
+	t string,
+	_ *rinq.Payload,
+) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.types = append(n.types, t)
+	return nil
+}
+
+func (n *recordingNotifier) Types() []string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return append([]string(nil), n.types...)
+}
+
+func (n *recordingNotifier) Count() int {
+	return len(n.Types())
+}
+
+func (n *recordingNotifier) Reset() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.types = nil
+}
@@ -0,0 +1,3 @@
+// Package chaos injects faults into the messages sent by a peer, so that
+// applications can be tested against a misbehaving broker.
+package chaos
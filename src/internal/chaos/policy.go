@@ -0,0 +1,55 @@
+package chaos
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/rinq/rinq-go/src/rinq/options"
+)
+
+// Policy chooses the faults to inject into each message.
+type Policy struct {
+	opts options.FaultOptions
+
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+// NewPolicy returns a policy that chooses faults as described by opts.
+func NewPolicy(opts options.FaultOptions) *Policy {
+	return &Policy{
+		opts: opts,
+		rand: rand.New(rand.NewSource(opts.Seed)),
+	}
+}
+
+// fault describes the faults injected into a single message.
+type fault struct {
+	Delay     time.Duration
+	Drop      bool
+	Duplicate bool
+	Reorder   bool
+}
+
+// next returns the faults to inject into the next message.
+//
+// The same number of values is drawn from the random source for every message,
+// so that the faults chosen for each message depend only on the seed and the
+// number of messages that preceded it.
+func (p *Policy) next() fault {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	delay := p.rand.Float64()
+	drop := p.rand.Float64()
+	dup := p.rand.Float64()
+	reorder := p.rand.Float64()
+
+	return fault{
+		Delay:     time.Duration(delay * float64(p.opts.Latency)),
+		Drop:      drop < p.opts.DropRate,
+		Duplicate: dup < p.opts.DuplicateRate,
+		Reorder:   reorder < p.opts.ReorderRate,
+	}
+}
@@ -0,0 +1,121 @@
+package chaos
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+// reorderWindow is the longest time that a message is held back for
+// reordering. If no other message is sent within this window the held message
+// is sent anyway, so that it is delayed rather than lost.
+const reorderWindow = 100 * time.Millisecond
+
+// sendFunc sends a message with the given payload.
+type sendFunc func(ctx context.Context, out *rinq.Payload) error
+
+// sender injects faults into messages that do not expect a response.
+type sender struct {
+	policy *Policy
+
+	mutex sync.Mutex
+	held  *heldMessage // the message that is held back for reordering, if any
+}
+
+// heldMessage is a message that has been held back for reordering.
+type heldMessage struct {
+	send func()
+}
+
+// send sends out using fn, subject to the next fault chosen by the policy.
+//
+// Messages that are sent later than the call to send(), because they are
+// duplicated or held back, are sent with a copy of out and without ctx, which
+// may be canceled by then.
+func (s *sender) send(ctx context.Context, out *rinq.Payload, fn sendFunc) error {
+	f := s.policy.next()
+
+	if f.Drop {
+		return nil
+	}
+
+	if err := sleep(ctx, f.Delay); err != nil {
+		return err
+	}
+
+	if f.Reorder {
+		s.hold(out, fn)
+		return nil
+	}
+
+	err := fn(ctx, out)
+	s.release(nil)
+
+	if err == nil && f.Duplicate {
+		later(out, fn)()
+	}
+
+	return err
+}
+
+// hold holds back a message so that it is sent after the next message. Any
+// message that is already held back is sent first.
+func (s *sender) hold(out *rinq.Payload, fn sendFunc) {
+	s.release(nil)
+
+	m := &heldMessage{send: later(out, fn)}
+
+	s.mutex.Lock()
+	s.held = m
+	s.mutex.Unlock()
+
+	time.AfterFunc(reorderWindow, func() {
+		s.release(m)
+	})
+}
+
+// release sends the message that is held back. If m is non-nil, it is only
+// sent if it is still the message that is held back.
+func (s *sender) release(m *heldMessage) {
+	s.mutex.Lock()
+	held := s.held
+	if m == nil || m == held {
+		s.held = nil
+	} else {
+		held = nil
+	}
+	s.mutex.Unlock()
+
+	if held != nil {
+		held.send()
+	}
+}
+
+// later returns a function that sends a copy of out using fn.
+func later(out *rinq.Payload, fn sendFunc) func() {
+	out = out.Clone()
+
+	return func() {
+		defer out.Close()
+		_ = fn(context.Background(), out)
+	}
+}
+
+// sleep blocks for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,6 +2,7 @@ package command
 
 import (
 	"context"
+	"time"
 
 	"github.com/rinq/rinq-go/src/internal/service"
 	"github.com/rinq/rinq-go/src/rinq"
@@ -41,6 +42,9 @@ type Invoker interface {
 
 	// CallBalancedAsync sends a load-balanced command request to the first
 	// available peer, instructs it to send a response, but does not block.
+	//
+	// h is invoked when the response is received. If h is nil, the handler
+	// set for the session by SetAsyncHandler() is used instead.
 	CallBalancedAsync(
 		ctx context.Context,
 		msgID ident.MessageID,
@@ -48,6 +52,7 @@ type Invoker interface {
 		namespace string,
 		command string,
 		payload *rinq.Payload,
+		h rinq.AsyncHandler,
 	) error
 
 	// SetAsyncHandler sets the asynchronous handler to use for a specific
@@ -65,6 +70,23 @@ type Invoker interface {
 		payload *rinq.Payload,
 	) error
 
+	// ExecuteBalancedAt schedules a load-balanced command request to be sent
+	// to the first available peer at time t, and returns immediately.
+	ExecuteBalancedAt(
+		ctx context.Context,
+		msgID ident.MessageID,
+		traceID string,
+		t time.Time,
+		namespace string,
+		command string,
+		payload *rinq.Payload,
+	) error
+
+	// CancelScheduled cancels a command request scheduled by
+	// ExecuteBalancedAt(). It returns false if the request has already been
+	// sent or was never scheduled.
+	CancelScheduled(ctx context.Context, msgID ident.MessageID) (bool, error)
+
 	// ExecuteMulticast sends a multicast command request to the all available
 	// peers and returns immediately.
 	ExecuteMulticast(
@@ -75,4 +97,18 @@ type Invoker interface {
 		command string,
 		payload *rinq.Payload,
 	) error
+
+	// PendingCalls returns the number of command calls, including asynchronous
+	// calls, that are awaiting a response.
+	PendingCalls() int
+
+	// Capabilities returns the capabilities advertised by another peer in its
+	// presence announcements. ok is false if the peer's capabilities are not
+	// known.
+	Capabilities(peerID ident.PeerID) (caps rinq.PeerCapabilities, ok bool)
+
+	// ObserveLostPeers registers fn to be called when another peer is presumed
+	// to have stopped because it is no longer announcing its presence. It
+	// replaces any previously registered function.
+	ObserveLostPeers(fn func(ident.PeerID))
 }
@@ -9,6 +9,25 @@ import (
 type Server interface {
 	service.Service
 
-	Listen(ns string, h rinq.CommandHandler) (bool, error)
-	Unlisten(ns string) (bool, error)
+	Listen(ns string, version uint, h rinq.CommandHandler) (bool, error)
+	Unlisten(ns string, version uint) (bool, error)
+
+	// ListenAll starts listening to each of the namespaces in handlers, at
+	// version zero. Either all of the handlers are added, or none are. It
+	// returns the namespaces that were not already being listened to.
+	ListenAll(handlers map[string]rinq.CommandHandler) ([]string, error)
+
+	// UnlistenAll stops listening to all namespaces, at all versions, except
+	// for reserved namespaces that are used internally. It returns the
+	// namespaces that were being listened to.
+	UnlistenAll() ([]Namespace, error)
+
+	// InFlight returns the number of command handlers currently executing.
+	InFlight() int
+}
+
+// Namespace is a namespace that a server listens to, at a specific version.
+type Namespace struct {
+	Name    string
+	Version uint
 }
@@ -0,0 +1,17 @@
+package functest
+
+import (
+	"os"
+
+	"github.com/streadway/amqp"
+)
+
+// Broker returns a new connection to the AMQP broker used by functional tests.
+func Broker() (*amqp.Connection, error) {
+	dsn := os.Getenv("RINQ_AMQP_DSN")
+	if dsn == "" {
+		dsn = "amqp://localhost"
+	}
+
+	return amqp.Dial(dsn)
+}
@@ -33,8 +33,8 @@ func NewNamespace() string {
 	return ns
 }
 
-// TearDownNamespaces cleans up any queues created for command namespaces made
-// via NewNamespace()
+// TearDownNamespaces cleans up any queues and streams created for namespaces
+// made via NewNamespace()
 func TearDownNamespaces() {
 	namespaces.mutex.Lock()
 	defer namespaces.mutex.Unlock()
@@ -44,12 +44,7 @@ func TearDownNamespaces() {
 	}
 
 	if namespaces.channel == nil {
-		dsn := os.Getenv("RINQ_AMQP_DSN")
-		if dsn == "" {
-			dsn = "amqp://localhost"
-		}
-
-		broker, err := amqp.Dial(dsn)
+		broker, err := Broker()
 		if err != nil {
 			fmt.Println(err)
 			return
@@ -73,6 +68,14 @@ func TearDownNamespaces() {
 			false,     // ifEmpty,
 			false,     // noWait
 		)
+		if err == nil {
+			_, err = namespaces.channel.QueueDelete(
+				"ntf.stream."+ns, // see notifyamqp.streamQueue()
+				false,            // ifUnused,
+				false,            // ifEmpty,
+				false,            // noWait
+			)
+		}
 		if err != nil {
 			namespaces.broker = nil
 			namespaces.channel = nil
@@ -26,12 +26,18 @@ func SharedPeer() rinq.Peer {
 	return sharedPeer.peer
 }
 
-// NewPeer returns a new peer for use in functional tests.
-func NewPeer() rinq.Peer {
+// NewPeer returns a new peer for use in functional tests, configured with the
+// given options in addition to the defaults.
+func NewPeer(opts ...options.Option) rinq.Peer {
 	peer, err := rinqamqp.DialEnv(
-		options.Logger(
-			&twelf.StandardLogger{CaptureDebug: true},
-		),
+		append(
+			[]options.Option{
+				options.Logger(
+					&twelf.StandardLogger{CaptureDebug: true},
+				),
+			},
+			opts...,
+		)...,
 	)
 
 	if err != nil {
@@ -0,0 +1,14 @@
+package functest
+
+import "github.com/rinq/rinq-go/src/rinq"
+
+// Session returns a new session owned by p, or panics if it can not be
+// created.
+func Session(p rinq.Peer) rinq.Session {
+	sess, err := p.Session()
+	if err != nil {
+		panic(err)
+	}
+
+	return sess
+}
@@ -0,0 +1,60 @@
+package localsession
+
+import (
+	"context"
+
+	"github.com/rinq/rinq-go/src/internal/notify"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+// SetDispatchHandler implements rinq.Session.SetDispatchHandler()
+func (s *Session) SetDispatchHandler(h rinq.DispatchHandler) error {
+	// as per Listen(), the lock is held for the duration of the calls to
+	// s.listener to serialize them with s.listener.UnlistenAll().
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isDestroyed {
+		return rinq.NotFoundError{ID: s.ref.ID}
+	}
+
+	if h != nil && s.dispatch == nil {
+		if _, err := s.listener.Listen(s.ref.ID, notify.DispatchNamespace, s.receiveDispatchReport); err != nil {
+			return err
+		}
+	} else if h == nil && s.dispatch != nil {
+		if _, err := s.listener.Unlisten(s.ref.ID, notify.DispatchNamespace); err != nil {
+			return err
+		}
+	}
+
+	s.dispatch = h
+
+	return nil
+}
+
+// receiveDispatchReport is the notification handler for dispatch reports. It
+// passes the report to the dispatch handler, if one is set.
+func (s *Session) receiveDispatchReport(
+	ctx context.Context,
+	target rinq.Session,
+	n rinq.Notification,
+) {
+	defer n.Payload.Close()
+
+	s.mutex.RLock()
+	h := s.dispatch
+	s.mutex.RUnlock()
+
+	var r rinq.DispatchReport
+	if err := n.Payload.Decode(&r); err != nil {
+		logDispatchReportInvalid(s.logger, s.ref, n, err)
+		return
+	}
+
+	logDispatchReport(ctx, s.logger, s.ref, r)
+
+	if h != nil {
+		h(ctx, target, r)
+	}
+}
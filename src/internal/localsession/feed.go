@@ -0,0 +1,98 @@
+package localsession
+
+import (
+	"context"
+	"time"
+
+	"github.com/rinq/rinq-go/src/internal/attributes"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/constraint"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/trace"
+)
+
+// publishTimeout is the maximum time spent publishing each attribute change
+// notification.
+const publishTimeout = 5 * time.Second
+
+// feedUpdate is an attribute change notification that is waiting to be
+// published.
+type feedUpdate struct {
+	MsgID   ident.MessageID
+	TraceID string
+	Diff    *attributes.Diff
+}
+
+// prepareFeed returns the attribute change notifications to publish for each
+// of the diffs that changes a namespace published to the attribute change feed.
+// It assumes s.mutex is locked, and that the diffs produced s.ref.Rev.
+//
+// Message IDs are allocated while the mutex is locked, but the notifications
+// are not published until publish() is called.
+func (s *Session) prepareFeed(ctx context.Context, diffs ...*attributes.Diff) []feedUpdate {
+	var updates []feedUpdate
+
+	for _, diff := range diffs {
+		if diff.IsEmpty() || !s.isPublished(diff.Namespace) {
+			continue
+		}
+
+		msgID, traceID := s.nextMessageID(ctx)
+		updates = append(updates, feedUpdate{msgID, traceID, diff})
+	}
+
+	return updates
+}
+
+// publish sends a rinq.AttrChangeNotification for each of the updates. It must
+// not be called while s.mutex is locked, as publishing may block when the
+// broker applies back-pressure.
+//
+// The update has already been applied, so failures are logged rather than
+// returned to the caller. Each notification is published using a new context
+// that carries the trace ID of the update, so that publishing is not cut short
+// by the caller's deadline, but is bounded by publishTimeout.
+func (s *Session) publish(updates []feedUpdate) {
+	for _, u := range updates {
+		s.publishOne(u)
+	}
+}
+
+// publishOne sends the attribute change notification for u.
+func (s *Session) publishOne(u feedUpdate) {
+	attrs := make([]rinq.Attr, len(u.Diff.VList))
+	for i, attr := range u.Diff.VList {
+		attrs[i] = attr.Attr
+	}
+
+	ctx := trace.With(context.Background(), u.TraceID)
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	out := rinq.NewPayload(attrs)
+	defer out.Close()
+
+	err := s.notifier.NotifyMulticast(
+		ctx,
+		u.MsgID,
+		u.TraceID,
+		constraint.None,
+		u.Diff.Namespace,
+		rinq.AttrChangeNotification,
+		out,
+	)
+
+	logPublish(s.logger, u.MsgID, u.Diff, err, u.TraceID)
+}
+
+// isPublished returns true if changes to the ns namespace are published to
+// the attribute change feed.
+func (s *Session) isPublished(ns string) bool {
+	for _, n := range s.feed {
+		if n == ns {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,168 @@
+package localsession_test
+
+import (
+	"context"
+
+	"github.com/jmalloc/twelf/src/twelf"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rinq/rinq-go/src/internal/attributes"
+	. "github.com/rinq/rinq-go/src/internal/localsession"
+	"github.com/rinq/rinq-go/src/internal/notify"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/constraint"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+	"github.com/rinq/rinq-go/src/rinq/trace"
+)
+
+var _ = Describe("attribute change feed", func() {
+	var (
+		notifier *feedNotifier
+		sess     *Session
+	)
+
+	BeforeEach(func() {
+		notifier = &feedNotifier{}
+		sess = NewSession(
+			ident.NewPeerID().Session(1),
+			nil, // invoker
+			notifier,
+			nil, // listener
+			options.QuotaOptions{},
+			[]string{"feed"},
+			&twelf.StandardLogger{},
+			opentracing.NoopTracer{},
+			nil, // sampler
+		)
+	})
+
+	It("publishes changes to namespaces in the feed", func() {
+		_, _, err := sess.TryUpdate(context.Background(), 0, "feed", attributes.List{rinq.Set("a", "1"), rinq.Set("b", "2")})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(notifier.sent).To(HaveLen(1))
+
+		n := notifier.sent[0]
+		Expect(n.ID.Ref).To(Equal(sess.ID().At(1)))
+		Expect(n.Namespace).To(Equal("feed"))
+		Expect(n.Type).To(Equal(rinq.AttrChangeNotification))
+		Expect(n.Constraint).To(Equal(constraint.None))
+		Expect(n.Attrs).To(Equal([]rinq.Attr{rinq.Set("a", "1"), rinq.Set("b", "2")}))
+	})
+
+	It("publishes cleared and frozen attributes", func() {
+		_, _, err := sess.TryUpdate(context.Background(), 0, "feed", attributes.List{rinq.Set("a", "1"), rinq.Set("b", "2")})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, err = sess.TryClear(context.Background(), 1, "feed", []string{"a"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, err = sess.TryFreezeNamespace(context.Background(), 2, "feed")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(notifier.sent).To(HaveLen(3))
+		Expect(notifier.sent[1].Attrs).To(Equal([]rinq.Attr{rinq.Set("a", "")}))
+		Expect(notifier.sent[2].Attrs).To(ConsistOf(
+			rinq.Attr{Key: "a", IsFrozen: true},
+			rinq.Attr{Key: "b", Value: "2", IsFrozen: true},
+		))
+	})
+
+	It("does not publish changes to other namespaces", func() {
+		_, _, err := sess.TryUpdateMany(context.Background(), 0, map[string]attributes.List{
+			"feed":  {rinq.Set("a", "1")},
+			"other": {rinq.Set("b", "2")},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(notifier.sent).To(HaveLen(1))
+		Expect(notifier.sent[0].Namespace).To(Equal("feed"))
+	})
+
+	It("does not publish updates that do not change any attributes", func() {
+		_, _, err := sess.TryUpdate(context.Background(), 0, "feed", attributes.List{rinq.Set("a", "")})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(notifier.sent).To(BeEmpty())
+	})
+
+	It("publishes changes after the session is unlocked", func() {
+		notifier.inspect = func() {
+			// CurrentRevision() locks the session mutex, and would deadlock
+			// if the notification were published while it is held
+			Expect(sess.CurrentRevision().Ref().Rev).To(BeEquivalentTo(1))
+		}
+
+		_, _, err := sess.TryUpdate(context.Background(), 0, "feed", attributes.List{rinq.Set("a", "1")})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(notifier.sent).To(HaveLen(1))
+	})
+
+	It("publishes changes using the trace ID of the update", func() {
+		ctx := trace.With(context.Background(), "<trace>")
+
+		_, _, err := sess.TryUpdate(ctx, 0, "feed", attributes.List{rinq.Set("a", "1")})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(notifier.sent).To(HaveLen(1))
+		Expect(notifier.sent[0].TraceID).To(Equal("<trace>"))
+		Expect(notifier.sent[0].ContextTraceID).To(Equal("<trace>"))
+		Expect(notifier.sent[0].HasDeadline).To(BeTrue())
+	})
+})
+
+// feedNotification is a multicast notification recorded by feedNotifier.
+type feedNotification struct {
+	ID             ident.MessageID
+	TraceID        string
+	ContextTraceID string
+	HasDeadline    bool
+	Constraint     constraint.Constraint
+	Namespace      string
+	Type           string
+	Attrs          []rinq.Attr
+}
+
+// feedNotifier is a notify.Notifier that records multicast notifications.
+type feedNotifier struct {
+	notify.Notifier
+
+	sent    []feedNotification
+	inspect func()
+}
+
+func (n *feedNotifier) NotifyMulticast(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	con constraint.Constraint,
+	ns string,
+	t string,
+	out *rinq.Payload,
+) error {
+	var attrs []rinq.Attr
+	if err := out.Decode(&attrs); err != nil {
+		return err
+	}
+
+	if n.inspect != nil {
+		n.inspect()
+	}
+
+	_, hasDeadline := ctx.Deadline()
+
+	n.sent = append(n.sent, feedNotification{
+		msgID,
+		traceID,
+		trace.Get(ctx),
+		hasDeadline,
+		con,
+		ns,
+		t,
+		attrs,
+	})
+	return nil
+}
@@ -0,0 +1,70 @@
+package localsession
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// future is the implementation of rinq.Future.
+type future struct {
+	id   ident.MessageID
+	done chan struct{}
+
+	mutex sync.Mutex
+	in    *rinq.Payload
+	err   error
+}
+
+func newFuture() *future {
+	return &future{
+		done: make(chan struct{}),
+	}
+}
+
+// ID implements rinq.Future.ID()
+func (f *future) ID() ident.MessageID {
+	return f.id
+}
+
+// Done implements rinq.Future.Done()
+func (f *future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result implements rinq.Future.Result()
+func (f *future) Result() (*rinq.Payload, error) {
+	<-f.done
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.in, f.err
+}
+
+// Cancel implements rinq.Future.Cancel()
+func (f *future) Cancel() {
+	f.resolve(nil, context.Canceled)
+}
+
+// resolve makes in and err available as the result of the future. It returns
+// false if the result is already available, in which case in and err are
+// discarded.
+func (f *future) resolve(in *rinq.Payload, err error) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	select {
+	case <-f.done:
+		return false
+	default:
+	}
+
+	f.in = in
+	f.err = err
+	close(f.done)
+
+	return true
+}
@@ -0,0 +1,191 @@
+package localsession_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jmalloc/twelf/src/twelf"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rinq/rinq-go/src/internal/command"
+	. "github.com/rinq/rinq-go/src/internal/localsession"
+	"github.com/rinq/rinq-go/src/internal/notify"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+)
+
+var _ = Describe("Session.CallFuture", func() {
+	var (
+		invoker *asyncInvoker
+		sess    *Session
+	)
+
+	BeforeEach(func() {
+		invoker = &asyncInvoker{
+			handlers: map[ident.MessageID]rinq.AsyncHandler{},
+		}
+
+		sess = NewSession(
+			ident.NewPeerID().Session(1),
+			invoker,
+			nil, // notifier
+			&nullListener{},
+			options.QuotaOptions{},
+			nil, // feed
+			&twelf.StandardLogger{},
+			opentracing.NoopTracer{},
+			nil, // sampler
+		)
+	})
+
+	AfterEach(func() {
+		sess.Destroy()
+	})
+
+	It("returns a future that provides the response", func() {
+		f, err := sess.CallFuture(context.Background(), "ns", "<cmd>", nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(f.Done()).NotTo(BeClosed())
+
+		invoker.respond(f.ID(), rinq.NewPayload(123), nil)
+
+		Expect(f.Done()).To(BeClosed())
+
+		in, err := f.Result()
+		defer in.Close()
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(in.Value()).To(BeEquivalentTo(123))
+	})
+
+	It("returns a future that provides the error", func() {
+		f, err := sess.CallFuture(context.Background(), "ns", "<cmd>", nil)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		invoker.respond(f.ID(), nil, rinq.Failure{Type: "<type>"})
+
+		_, err = f.Result()
+		Expect(err).To(Equal(rinq.Failure{Type: "<type>"}))
+	})
+
+	It("returns an error if the request can not be sent", func() {
+		invoker.err = errors.New("<error>")
+
+		f, err := sess.CallFuture(context.Background(), "ns", "<cmd>", nil)
+
+		Expect(err).To(MatchError("<error>"))
+		Expect(f).To(BeNil())
+	})
+
+	It("returns an error if the session has been destroyed", func() {
+		sess.Destroy()
+
+		_, err := sess.CallFuture(context.Background(), "ns", "<cmd>", nil)
+
+		Expect(err).To(Equal(rinq.NotFoundError{ID: sess.ID()}))
+	})
+
+	Describe("Cancel", func() {
+		It("causes Result() to return a cancellation error", func() {
+			f, err := sess.CallFuture(context.Background(), "ns", "<cmd>", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			f.Cancel()
+
+			Expect(f.Done()).To(BeClosed())
+
+			_, err = f.Result()
+			Expect(err).To(Equal(context.Canceled))
+		})
+
+		It("discards a response that is received afterwards", func() {
+			f, err := sess.CallFuture(context.Background(), "ns", "<cmd>", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			f.Cancel()
+			invoker.respond(f.ID(), rinq.NewPayload(123), nil)
+
+			in, err := f.Result()
+			Expect(in).To(BeNil())
+			Expect(err).To(Equal(context.Canceled))
+		})
+
+		It("has no effect once the result is available", func() {
+			f, err := sess.CallFuture(context.Background(), "ns", "<cmd>", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			invoker.respond(f.ID(), rinq.NewPayload(123), nil)
+			f.Cancel()
+
+			in, err := f.Result()
+			defer in.Close()
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(in.Value()).To(BeEquivalentTo(123))
+		})
+	})
+
+	It("resolves pending futures when the session is destroyed", func() {
+		f, err := sess.CallFuture(context.Background(), "ns", "<cmd>", nil)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		sess.Destroy()
+
+		_, err = f.Result()
+		Expect(err).To(Equal(rinq.NotFoundError{ID: sess.ID()}))
+	})
+})
+
+// asyncInvoker is a command.Invoker that records the handler for each
+// asynchronous call, so that responses can be sent by the test.
+type asyncInvoker struct {
+	command.Invoker
+
+	err      error
+	handlers map[ident.MessageID]rinq.AsyncHandler
+}
+
+func (i *asyncInvoker) CallBalancedAsync(
+	_ context.Context,
+	msgID ident.MessageID,
+	_ string,
+	_ string,
+	_ string,
+	_ *rinq.Payload,
+	h rinq.AsyncHandler,
+) error {
+	if i.err != nil {
+		return i.err
+	}
+
+	i.handlers[msgID] = h
+	return nil
+}
+
+func (i *asyncInvoker) SetAsyncHandler(ident.SessionID, rinq.AsyncHandler) {}
+
+func (i *asyncInvoker) respond(msgID ident.MessageID, in *rinq.Payload, err error) {
+	i.handlers[msgID](
+		opentracing.ContextWithSpan(
+			context.Background(),
+			opentracing.NoopTracer{}.StartSpan(""),
+		),
+		nil, // session
+		msgID,
+		"ns",
+		"<cmd>",
+		in,
+		err,
+	)
+}
+
+// nullListener is a notify.Listener that ignores all calls.
+type nullListener struct {
+	notify.Listener
+}
+
+func (l *nullListener) UnlistenAll(ident.SessionID) error {
+	return nil
+}
@@ -0,0 +1,69 @@
+package localsession
+
+import (
+	"context"
+	"time"
+
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// hedgeResult is the outcome of one of the requests made by callHedged().
+type hedgeResult struct {
+	In  *rinq.Payload
+	Err error
+}
+
+// callHedged sends a load-balanced command request. If no response has been
+// received after delay, a duplicate request is sent using hedgeID.
+//
+// The first response received is returned, and the context of the other
+// request is canceled.
+func (s *Session) callHedged(
+	ctx context.Context,
+	msgID ident.MessageID,
+	hedgeID ident.MessageID,
+	delay time.Duration,
+	traceID string,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+) (*rinq.Payload, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, 2)
+
+	call := func(id ident.MessageID, p *rinq.Payload) {
+		// each request holds its own reference to the payload, as the caller
+		// may close out before the losing request has returned.
+		defer p.Close()
+
+		in, err := s.invoker.CallBalanced(ctx, id, traceID, ns, cmd, p)
+		results <- hedgeResult{in, err}
+	}
+
+	go call(msgID, out.Clone())
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case r := <-results:
+		return r.In, r.Err
+	case <-timer.C:
+	}
+
+	logHedge(s.logger, msgID, hedgeID, ns, cmd, delay, traceID)
+	go call(hedgeID, out.Clone())
+
+	r := <-results
+
+	// discard the response to the losing request once it has been canceled
+	go func() {
+		loser := <-results
+		loser.In.Close()
+	}()
+
+	return r.In, r.Err
+}
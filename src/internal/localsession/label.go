@@ -0,0 +1,64 @@
+package localsession
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/jmalloc/twelf/src/twelf"
+)
+
+// labelLogger is a twelf.Logger that prefixes each message with the label of
+// the session that it describes, if the session has a label.
+type labelLogger struct {
+	twelf.Logger
+
+	label atomic.Value // string
+}
+
+// SetLabel sets the label to include in subsequent messages.
+func (l *labelLogger) SetLabel(label string) {
+	l.label.Store(label)
+}
+
+// Label returns the current label.
+func (l *labelLogger) Label() string {
+	label, _ := l.label.Load().(string)
+	return label
+}
+
+func (l *labelLogger) Log(f string, v ...interface{}) {
+	if label := l.Label(); label != "" {
+		l.Logger.LogString(prefixLabel(label, fmt.Sprintf(f, v...)))
+	} else {
+		l.Logger.Log(f, v...)
+	}
+}
+
+func (l *labelLogger) LogString(s string) {
+	l.Logger.LogString(prefixLabel(l.Label(), s))
+}
+
+func (l *labelLogger) Debug(f string, v ...interface{}) {
+	if !l.Logger.IsDebug() {
+		return
+	}
+
+	if label := l.Label(); label != "" {
+		l.Logger.DebugString(prefixLabel(label, fmt.Sprintf(f, v...)))
+	} else {
+		l.Logger.Debug(f, v...)
+	}
+}
+
+func (l *labelLogger) DebugString(s string) {
+	l.Logger.DebugString(prefixLabel(l.Label(), s))
+}
+
+// prefixLabel returns s prefixed with the label, in brackets.
+func prefixLabel(label, s string) string {
+	if label == "" {
+		return s
+	}
+
+	return "[" + label + "] " + s
+}
@@ -0,0 +1,170 @@
+package localsession
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// linkNamespace is the notification namespace used to carry link messages.
+const linkNamespace = "_link"
+
+// linkBuffer is the number of received messages held by each link before
+// further messages block the notification handler.
+const linkBuffer = 32
+
+// Link implements rinq.Session.Link()
+func (s *Session) Link(target ident.SessionID) (rinq.Link, error) {
+	ident.MustValidate(target)
+	if target.Seq == 0 {
+		panic("can not link to the zero-session")
+	}
+
+	// as per Listen(), the lock is held for the duration of the call to
+	// s.listener.Listen() to serialize it with s.listener.UnlistenAll().
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isDestroyed {
+		return nil, rinq.NotFoundError{ID: s.ref.ID}
+	}
+
+	if l, ok := s.links[target]; ok {
+		return l, nil
+	}
+
+	if s.links == nil {
+		if _, err := s.listener.Listen(s.ref.ID, linkNamespace, s.receiveLink); err != nil {
+			return nil, err
+		}
+
+		s.links = map[ident.SessionID]*link{}
+	}
+
+	l := &link{
+		session:  s,
+		target:   target,
+		messages: make(chan *rinq.Payload, linkBuffer),
+		done:     make(chan struct{}),
+	}
+
+	s.links[target] = l
+	logLinkOpened(s.logger, s.ref, target)
+
+	return l, nil
+}
+
+// receiveLink is the notification handler for link messages. It passes the
+// message to the link to the sending session, if there is one.
+func (s *Session) receiveLink(
+	ctx context.Context,
+	target rinq.Session,
+	n rinq.Notification,
+) {
+	source := n.Source.SessionID()
+
+	s.mutex.RLock()
+	l, ok := s.links[source]
+	ref := s.ref
+	s.mutex.RUnlock()
+
+	if !ok {
+		n.Payload.Close()
+		logLinkDiscarded(s.logger, ref, source)
+		return
+	}
+
+	select {
+	case l.messages <- n.Payload:
+	case <-l.done:
+		n.Payload.Close()
+	case <-ctx.Done():
+		n.Payload.Close()
+	}
+}
+
+// closeLink removes l from the session's links, if it is still present.
+func (s *Session) closeLink(l *link) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.links[l.target] != l {
+		return
+	}
+
+	delete(s.links, l.target)
+	l.close()
+	logLinkClosed(s.logger, s.ref, l.target)
+}
+
+// link is the implementation of rinq.Link.
+type link struct {
+	session  *Session
+	target   ident.SessionID
+	messages chan *rinq.Payload
+	once     sync.Once
+	done     chan struct{}
+}
+
+// Target implements rinq.Link.Target()
+func (l *link) Target() ident.SessionID {
+	return l.target
+}
+
+// Send implements rinq.Link.Send()
+func (l *link) Send(ctx context.Context, out *rinq.Payload) error {
+	select {
+	case <-l.done:
+		return rinq.LinkClosedError{Target: l.target}
+	default:
+	}
+
+	s := l.session
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isDestroyed {
+		return rinq.NotFoundError{ID: s.ref.ID}
+	}
+
+	msgID, traceID := s.nextMessageID(ctx)
+
+	return s.notifier.NotifyUnicast(ctx, msgID, traceID, l.target, linkNamespace, "", out)
+}
+
+// Receive implements rinq.Link.Receive()
+func (l *link) Receive(ctx context.Context) (*rinq.Payload, error) {
+	select {
+	case p := <-l.messages:
+		return p, nil
+	case <-l.done:
+		return nil, rinq.LinkClosedError{Target: l.target}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close implements rinq.Link.Close()
+func (l *link) Close() {
+	l.session.closeLink(l)
+}
+
+// close closes the done channel and discards any buffered messages. s.mutex
+// must be held by the caller.
+func (l *link) close() {
+	l.once.Do(func() {
+		close(l.done)
+
+		for {
+			select {
+			case p := <-l.messages:
+				p.Close()
+			default:
+				return
+			}
+		}
+	})
+}
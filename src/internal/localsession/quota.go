@@ -0,0 +1,37 @@
+package localsession
+
+import "github.com/rinq/rinq-go/src/rinq"
+
+// attrUsage is the share of an attribute quota that is used by a set of
+// attributes.
+type attrUsage struct {
+	count uint
+	bytes uint
+}
+
+// usageOf returns the share of an attribute quota used by attr. Attributes
+// with an empty value do not count towards the quota.
+func usageOf(attr rinq.Attr) attrUsage {
+	if attr.Value == "" {
+		return attrUsage{}
+	}
+
+	return attrUsage{1, uint(len(attr.Key) + len(attr.Value))}
+}
+
+// add returns the sum of u and v.
+func (u attrUsage) add(v attrUsage) attrUsage {
+	return attrUsage{u.count + v.count, u.bytes + v.bytes}
+}
+
+// sub returns the difference of u and v. v must not exceed u.
+func (u attrUsage) sub(v attrUsage) attrUsage {
+	return attrUsage{u.count - v.count, u.bytes - v.bytes}
+}
+
+// exceeds returns true if u is greater than either of the given limits. A
+// limit of zero means unlimited.
+func (u attrUsage) exceeds(maxCount, maxBytes uint) bool {
+	return (maxCount != 0 && u.count > maxCount) ||
+		(maxBytes != 0 && u.bytes > maxBytes)
+}
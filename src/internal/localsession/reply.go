@@ -0,0 +1,182 @@
+package localsession
+
+import (
+	"context"
+	"time"
+
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/rinq/rinq-go/src/internal/namespaces"
+	"github.com/rinq/rinq-go/src/internal/opentr"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// replyNamespace is the notification namespace used to carry replies to
+// notifications sent with NotifyAndWait().
+const replyNamespace = "_reply"
+
+// NotifyAndWait implements rinq.Session.NotifyAndWait()
+func (s *Session) NotifyAndWait(
+	ctx context.Context,
+	ns, t string,
+	target ident.SessionID,
+	p *rinq.Payload,
+) (*rinq.Payload, error) {
+	namespaces.MustValidate(ns)
+	ident.MustValidate(target)
+	if target.Seq == 0 {
+		panic("can not send notifications to the zero-session")
+	}
+
+	s.mutex.Lock()
+
+	if s.isDestroyed {
+		s.mutex.Unlock()
+		return nil, rinq.NotFoundError{ID: s.ref.ID}
+	}
+
+	// as per Listen(), the lock is held for the duration of the call to
+	// s.listener.Listen() to serialize it with s.listener.UnlistenAll().
+	if s.replies == nil {
+		if _, err := s.listener.Listen(s.ref.ID, replyNamespace, s.receiveReply); err != nil {
+			s.mutex.Unlock()
+			return nil, err
+		}
+
+		s.replies = map[ident.MessageID]chan *rinq.Payload{}
+	}
+
+	msgID, traceID := s.nextMessageID(ctx)
+	attrs := s.attrs // capture for logging/tracing while mutex is locked
+	replies := make(chan *rinq.Payload, 1)
+	s.replies[msgID] = replies
+
+	// the lock is released before waiting for the reply, so that the target
+	// session can query or modify this session while handling the request.
+	s.mutex.Unlock()
+	defer s.discardReply(msgID)
+
+	span, ctx := opentr.ChildOf(ctx, s.sampler.Tracer(s.tracer, ns, traceID), ext.SpanKindRPCClient)
+	defer span.Finish()
+
+	opentr.SetupNotification(span, msgID, ns, t)
+	opentr.AddTraceID(span, traceID)
+	opentr.LogNotifierUnicast(span, attrs, target, p)
+
+	start := time.Now()
+	in, err := s.awaitReply(ctx, msgID, traceID, ns, t, target, p, replies)
+	elapsed := time.Since(start) / time.Millisecond
+
+	if err != nil {
+		opentr.LogNotifierError(span, err)
+	}
+
+	logNotifyAndWait(s.logger, msgID, ns, t, target, elapsed, p, in, err, traceID)
+
+	return in, err
+}
+
+// awaitReply sends a request notification and waits for a reply to arrive on
+// the replies channel.
+func (s *Session) awaitReply(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	ns, t string,
+	target ident.SessionID,
+	p *rinq.Payload,
+	replies <-chan *rinq.Payload,
+) (*rinq.Payload, error) {
+	if err := s.notifier.NotifyRequest(ctx, msgID, traceID, target, ns, t, p); err != nil {
+		return nil, err
+	}
+
+	select {
+	case in, ok := <-replies:
+		if !ok {
+			return nil, rinq.NotFoundError{ID: msgID.Ref.ID}
+		}
+		return in, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Reply implements rinq.Session.Reply()
+func (s *Session) Reply(ctx context.Context, n rinq.Notification, p *rinq.Payload) error {
+	if !n.IsRequest {
+		panic("can not reply to a notification that was not sent with NotifyAndWait()")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isDestroyed {
+		return rinq.NotFoundError{ID: s.ref.ID}
+	}
+
+	msgID, traceID := s.nextMessageID(ctx)
+	target := n.Source.SessionID()
+
+	span, ctx := opentr.ChildOf(ctx, s.sampler.Tracer(s.tracer, n.Namespace, traceID), ext.SpanKindProducer)
+	defer span.Finish()
+
+	opentr.SetupNotification(span, msgID, replyNamespace, "")
+	opentr.AddTraceID(span, traceID)
+	opentr.LogNotifierUnicast(span, s.attrs, target, p)
+
+	err := s.notifier.NotifyReply(ctx, msgID, traceID, target, replyNamespace, n.ID, p)
+
+	if err != nil {
+		opentr.LogNotifierError(span, err)
+	}
+
+	logReply(s.logger, msgID, n, p, err, traceID)
+
+	return err
+}
+
+// receiveReply is the notification handler for replies. It passes the reply
+// to the NotifyAndWait() call that is waiting for it, if there is one.
+func (s *Session) receiveReply(
+	ctx context.Context,
+	target rinq.Session,
+	n rinq.Notification,
+) {
+	// the read lock is held while sending to the channel so that it is not
+	// closed concurrently by s.destroy().
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if replies, ok := s.replies[n.CorrelationID]; ok {
+		select {
+		case replies <- n.Payload:
+			return
+		default:
+			// a reply has already been received
+		}
+	}
+
+	n.Payload.Close()
+	logReplyDiscarded(s.logger, s.ref, n)
+}
+
+// discardReply stops waiting for a reply to the notification identified by
+// msgID, closing the reply if it has arrived but was not received.
+func (s *Session) discardReply(msgID ident.MessageID) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	replies, ok := s.replies[msgID]
+	if !ok {
+		return
+	}
+
+	delete(s.replies, msgID)
+
+	select {
+	case p := <-replies:
+		p.Close()
+	default:
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/jmalloc/twelf/src/twelf"
 	"github.com/rinq/rinq-go/src/internal/attributes"
 	"github.com/rinq/rinq-go/src/internal/namespaces"
+	"github.com/rinq/rinq-go/src/internal/revisions"
 	"github.com/rinq/rinq-go/src/rinq"
 	"github.com/rinq/rinq-go/src/rinq/ident"
 	"github.com/rinq/rinq-go/src/rinq/trace"
@@ -22,6 +23,10 @@ func (r *revision) SessionID() ident.SessionID {
 	return r.ref.ID
 }
 
+func (r *revision) Ref() ident.Ref {
+	return r.ref
+}
+
 func (r *revision) Refresh(ctx context.Context) (rinq.Revision, error) {
 	return r.session.CurrentRevision(), nil
 }
@@ -33,7 +38,7 @@ func (r *revision) Get(ctx context.Context, ns, key string) (rinq.Attr, error) {
 		return rinq.Attr{Key: key}, nil
 	}
 
-	attr, ok := r.attrs[ns][key]
+	attr, ok := r.attrs[ns].Get(key)
 
 	// The attribute hadn't yet been created at this revision.
 	if !ok || attr.CreatedAt > r.ref.Rev {
@@ -56,7 +61,7 @@ func (r *revision) GetMany(ctx context.Context, ns string, keys ...string) (rinq
 	table := attributes.Table{}
 
 	for _, key := range keys {
-		attr, ok := attrs[key]
+		attr, ok := attrs.Get(key)
 
 		if !ok || attr.CreatedAt > r.ref.Rev {
 			// The attribute hadn't yet been created at this revision.
@@ -72,6 +77,41 @@ func (r *revision) GetMany(ctx context.Context, ns string, keys ...string) (rinq
 	return table, nil
 }
 
+func (r *revision) GetAll(ctx context.Context, ns string) (rinq.AttrTable, error) {
+	namespaces.MustValidate(ns)
+
+	table := attributes.Table{}
+	isStale := false
+
+	r.attrs[ns].Range(func(attr attributes.VAttr) bool {
+		if attr.CreatedAt > r.ref.Rev {
+			// The attribute hadn't yet been created at this revision.
+			return true
+		} else if attr.UpdatedAt > r.ref.Rev {
+			isStale = true
+			return false
+		} else if attr.Value != "" || attr.IsFrozen {
+			table[attr.Key] = attr.Attr
+		}
+
+		return true
+	})
+
+	if isStale {
+		return nil, rinq.StaleFetchError{Ref: r.ref}
+	}
+
+	return table, nil
+}
+
+func (r *revision) Namespaces(ctx context.Context) ([]string, error) {
+	return attributes.NamespacesAt(r.attrs.Namespaces(), r.ref.Rev), nil
+}
+
+func (r *revision) Diff(ctx context.Context, since ident.Revision) (rinq.Diff, error) {
+	return revisions.Diff(r.ref, since, r.attrs)
+}
+
 func (r *revision) Update(ctx context.Context, ns string, attrs ...rinq.Attr) (rinq.Revision, error) {
 	namespaces.MustValidate(ns)
 
@@ -79,7 +119,7 @@ func (r *revision) Update(ctx context.Context, ns string, attrs ...rinq.Attr) (r
 		return r, nil
 	}
 
-	rev, diff, err := r.session.TryUpdate(r.ref.Rev, ns, attrs)
+	rev, diff, err := r.session.TryUpdate(ctx, r.ref.Rev, ns, attrs)
 	if err != nil {
 		return r, err
 	}
@@ -89,10 +129,37 @@ func (r *revision) Update(ctx context.Context, ns string, attrs ...rinq.Attr) (r
 	return rev, nil
 }
 
-func (r *revision) Clear(ctx context.Context, ns string) (rinq.Revision, error) {
+func (r *revision) UpdateMany(ctx context.Context, attrs map[string][]rinq.Attr) (rinq.Revision, error) {
+	changes := map[string]attributes.List{}
+
+	for ns, a := range attrs {
+		namespaces.MustValidate(ns)
+
+		if len(a) != 0 {
+			changes[ns] = a
+		}
+	}
+
+	if len(changes) == 0 {
+		return r, nil
+	}
+
+	rev, diffs, err := r.session.TryUpdateMany(ctx, r.ref.Rev, changes)
+	if err != nil {
+		return r, err
+	}
+
+	for _, diff := range diffs {
+		logUpdate(ctx, r.logger, r.ref.ID.At(diff.Revision), diff)
+	}
+
+	return rev, nil
+}
+
+func (r *revision) Clear(ctx context.Context, ns string, keys ...string) (rinq.Revision, error) {
 	namespaces.MustValidate(ns)
 
-	rev, diff, err := r.session.TryClear(r.ref.Rev, ns)
+	rev, diff, err := r.session.TryClear(ctx, r.ref.Rev, ns, keys)
 	if err != nil {
 		return r, err
 	}
@@ -102,6 +169,28 @@ func (r *revision) Clear(ctx context.Context, ns string) (rinq.Revision, error)
 	return rev, nil
 }
 
+func (r *revision) FreezeNamespace(ctx context.Context, ns string) (rinq.Revision, error) {
+	namespaces.MustValidate(ns)
+
+	rev, diff, err := r.session.TryFreezeNamespace(ctx, r.ref.Rev, ns)
+	if err != nil {
+		return r, err
+	}
+
+	logFreeze(ctx, r.logger, r.ref.ID.At(diff.Revision), diff)
+
+	return rev, nil
+}
+
+func (r *revision) AwaitDestroy(ctx context.Context) error {
+	select {
+	case <-r.session.Done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (r *revision) Destroy(ctx context.Context) error {
 	first, err := r.session.TryDestroy(r.ref.Rev)
 	if err != nil {
@@ -114,3 +203,31 @@ func (r *revision) Destroy(ctx context.Context) error {
 
 	return nil
 }
+
+func (r *revision) Await(ctx context.Context, ns, key string, fn func(rinq.Attr) bool) (rinq.Revision, error) {
+	namespaces.MustValidate(ns)
+
+	attr, err := r.Get(ctx, ns, key)
+	if err == nil && fn(attr) {
+		return r, nil
+	}
+
+	for {
+		rev, changed := r.session.Watch()
+
+		// Get() on the most recent revision can only fail if the session has
+		// been destroyed.
+		attr, err := rev.Get(ctx, ns, key)
+		if err != nil {
+			return r, err
+		} else if rev.Ref().Rev > r.ref.Rev && fn(attr) {
+			return rev, nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return r, ctx.Err()
+		}
+	}
+}
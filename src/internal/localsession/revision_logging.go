@@ -52,3 +52,25 @@ func logClear(
 		)
 	}
 }
+
+func logFreeze(
+	ctx context.Context,
+	logger twelf.Logger,
+	ref ident.Ref,
+	diff *attributes.Diff,
+) {
+	if traceID := trace.Get(ctx); traceID != "" {
+		logger.Log(
+			"%s session namespace frozen %s [%s]",
+			ref.ShortString(),
+			diff,
+			traceID,
+		)
+	} else {
+		logger.Log(
+			"%s session namespace frozen %s",
+			ref.ShortString(),
+			diff,
+		)
+	}
+}
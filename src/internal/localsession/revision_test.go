@@ -0,0 +1,105 @@
+package localsession_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmalloc/twelf/src/twelf"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opentracing "github.com/opentracing/opentracing-go"
+	. "github.com/rinq/rinq-go/src/internal/localsession"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+)
+
+var _ = Describe("revision", func() {
+	var (
+		ctx  context.Context
+		sess *Session
+		rev  rinq.Revision
+	)
+
+	isDone := func(attr rinq.Attr) bool {
+		return attr.Value == "done"
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		sess = NewSession(
+			ident.NewPeerID().Session(1),
+			nil, // invoker
+			nil, // notifier
+			nil, // listener
+			options.QuotaOptions{},
+			nil, // feed
+			&twelf.StandardLogger{},
+			opentracing.NoopTracer{},
+			nil, // sampler
+		)
+		rev = sess.CurrentRevision()
+	})
+
+	Describe("Await", func() {
+		It("returns this revision if the attribute already satisfies the predicate", func() {
+			rev, err := rev.Update(ctx, "ns", rinq.Set("a", "done"))
+			Expect(err).NotTo(HaveOccurred())
+
+			r, err := rev.Await(ctx, "ns", "a", isDone)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r).To(Equal(rev))
+		})
+
+		It("returns the first later revision that satisfies the predicate", func() {
+			result := make(chan rinq.Revision, 1)
+			go func() {
+				defer GinkgoRecover()
+
+				r, err := rev.Await(ctx, "ns", "a", isDone)
+				Expect(err).NotTo(HaveOccurred())
+				result <- r
+			}()
+
+			next, err := rev.Update(ctx, "ns", rinq.Set("a", "pending"))
+			Expect(err).NotTo(HaveOccurred())
+			Consistently(result).ShouldNot(Receive())
+
+			next, err = next.Update(ctx, "ns", rinq.Set("a", "done"))
+			Expect(err).NotTo(HaveOccurred())
+
+			var r rinq.Revision
+			Eventually(result).Should(Receive(&r))
+			Expect(r.Ref()).To(Equal(next.Ref()))
+		})
+
+		It("ignores changes to other attributes", func() {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			result := make(chan error, 1)
+			go func() {
+				_, err := rev.Await(ctx, "ns", "a", isDone)
+				result <- err
+			}()
+
+			_, err := rev.Update(ctx, "ns", rinq.Set("b", "done"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Consistently(result).ShouldNot(Receive())
+
+			// stop the call to Await() before the next spec begins
+			cancel()
+			Eventually(result).Should(Receive(Equal(context.Canceled)))
+		})
+
+		It("returns the context error if the context is canceled first", func() {
+			ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancel()
+
+			r, err := rev.Await(ctx, "ns", "a", isDone)
+			Expect(err).To(Equal(context.DeadlineExceeded))
+			Expect(r).To(Equal(rev))
+		})
+	})
+})
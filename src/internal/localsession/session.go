@@ -14,10 +14,12 @@ import (
 	"github.com/rinq/rinq-go/src/internal/notify"
 	"github.com/rinq/rinq-go/src/internal/opentr"
 	"github.com/rinq/rinq-go/src/internal/revisions"
+	"github.com/rinq/rinq-go/src/internal/sampling"
 	"github.com/rinq/rinq-go/src/internal/x/syncx"
 	"github.com/rinq/rinq-go/src/rinq"
 	"github.com/rinq/rinq-go/src/rinq/constraint"
 	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
 	"github.com/rinq/rinq-go/src/rinq/trace"
 )
 
@@ -31,15 +33,27 @@ type Session struct {
 	invoker  command.Invoker
 	notifier notify.Notifier
 	listener notify.Listener
-	logger   twelf.Logger
+	quota    options.QuotaOptions
+	feed     []string
+	logger   *labelLogger
 	tracer   opentracing.Tracer
+	sampler  *sampling.Sampler
 
 	mutex       sync.RWMutex
 	ref         ident.Ref
 	msgSeq      uint32
 	isDestroyed bool
 	attrs       attributes.Catalog
+	usage       map[string]attrUsage                   // quota usage by namespace, nil until the first update
+	totalUsage  attrUsage                              // quota usage across all namespaces
+	frozen      map[string]struct{}                    // namespaces that can not be modified
+	links       map[ident.SessionID]*link              // links by target, nil until the first link is created
+	replies     map[ident.MessageID]chan *rinq.Payload // pending NotifyAndWait() calls, nil until the first call
+	futures     map[*future]struct{}                   // pending CallFuture() calls, nil until the first call
+	dispatch    rinq.DispatchHandler                   // NotifyMany() dispatch report handler, nil if reports are not requested
 	calls       sync.WaitGroup
+	onDestroy   []func()
+	changed     chan struct{} // closed on the next update or destroy, nil until the first call to Watch()
 	done        chan struct{}
 }
 
@@ -49,8 +63,11 @@ func NewSession(
 	invoker command.Invoker,
 	notifier notify.Notifier,
 	listener notify.Listener,
+	quota options.QuotaOptions,
+	feed []string,
 	logger twelf.Logger,
 	tracer opentracing.Tracer,
+	sampler *sampling.Sampler,
 ) *Session {
 	logCreated(logger, id)
 
@@ -58,8 +75,11 @@ func NewSession(
 		invoker:  invoker,
 		notifier: notifier,
 		listener: listener,
-		logger:   logger,
+		quota:    quota,
+		feed:     feed,
+		logger:   &labelLogger{Logger: logger},
 		tracer:   tracer,
+		sampler:  sampler,
 
 		ref:  id.At(0),
 		done: make(chan struct{}),
@@ -86,6 +106,40 @@ func (s *Session) CurrentRevision() rinq.Revision {
 	return &revision{s.ref, s, s.attrs, s.logger}
 }
 
+// AwaitRevision implements rinq.Session.AwaitRevision()
+func (s *Session) AwaitRevision(ctx context.Context, rev ident.Revision) (rinq.Revision, error) {
+	for {
+		r, changed := s.Watch()
+
+		if changed == nil {
+			return nil, rinq.NotFoundError{ID: s.ref.ID}
+		} else if r.Ref().Rev >= rev {
+			return r, nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// SetLabel implements rinq.Session.SetLabel()
+func (s *Session) SetLabel(label string) {
+	s.mutex.RLock()
+	ref := s.ref
+	s.mutex.RUnlock()
+
+	logLabel(s.logger, ref, s.logger.Label(), label)
+	s.logger.SetLabel(label)
+}
+
+// Label implements rinq.Session.Label()
+func (s *Session) Label() string {
+	return s.logger.Label()
+}
+
 // Call implements rinq.Session.Call()
 func (s *Session) Call(ctx context.Context, ns, cmd string, out *rinq.Payload) (*rinq.Payload, error) {
 	namespaces.MustValidate(ns)
@@ -100,6 +154,12 @@ func (s *Session) Call(ctx context.Context, ns, cmd string, out *rinq.Payload) (
 	msgID, traceID := s.nextMessageID(ctx)
 	attrs := s.attrs // capture for logging/tracing while mutex is locked
 
+	delay, hedged := rinq.HedgeDelay(ctx)
+	var hedgeID ident.MessageID
+	if hedged {
+		hedgeID, _ = s.nextMessageID(ctx)
+	}
+
 	s.calls.Add(1)
 	defer s.calls.Done()
 
@@ -107,7 +167,7 @@ func (s *Session) Call(ctx context.Context, ns, cmd string, out *rinq.Payload) (
 	// the handler of the call querying or modifying this session.
 	unlock()
 
-	span, ctx := opentr.ChildOf(ctx, s.tracer, ext.SpanKindRPCClient)
+	span, ctx := opentr.ChildOf(ctx, s.sampler.Tracer(s.tracer, ns, traceID), ext.SpanKindRPCClient)
 	defer span.Finish()
 
 	opentr.SetupCommand(span, msgID, ns, cmd)
@@ -115,7 +175,13 @@ func (s *Session) Call(ctx context.Context, ns, cmd string, out *rinq.Payload) (
 	opentr.LogInvokerCall(span, attrs, out)
 
 	start := time.Now()
-	in, err := s.invoker.CallBalanced(ctx, msgID, traceID, ns, cmd, out)
+	var in *rinq.Payload
+	var err error
+	if hedged {
+		in, err = s.callHedged(ctx, msgID, hedgeID, delay, traceID, ns, cmd, out)
+	} else {
+		in, err = s.invoker.CallBalanced(ctx, msgID, traceID, ns, cmd, out)
+	}
 	elapsed := time.Since(start) / time.Millisecond
 
 	if err == nil {
@@ -131,6 +197,88 @@ func (s *Session) Call(ctx context.Context, ns, cmd string, out *rinq.Payload) (
 
 // CallAsync implements rinq.Session.CallAsync()
 func (s *Session) CallAsync(ctx context.Context, ns, cmd string, out *rinq.Payload) (ident.MessageID, error) {
+	return s.callAsync(ctx, ns, cmd, out, nil)
+}
+
+// CallAsyncFunc implements rinq.Session.CallAsyncFunc()
+func (s *Session) CallAsyncFunc(
+	ctx context.Context,
+	ns, cmd string,
+	out *rinq.Payload,
+	h rinq.AsyncHandler,
+) (ident.MessageID, error) {
+	if h == nil {
+		panic("async handler must not be nil")
+	}
+
+	return s.callAsync(ctx, ns, cmd, out, s.wrapAsyncHandler(h))
+}
+
+// CallFuture implements rinq.Session.CallFuture()
+func (s *Session) CallFuture(ctx context.Context, ns, cmd string, out *rinq.Payload) (rinq.Future, error) {
+	f := newFuture()
+
+	// the future is tracked before the request is sent, so that it is resolved
+	// if the session is destroyed before the response arrives.
+	s.mutex.Lock()
+	if s.isDestroyed {
+		s.mutex.Unlock()
+		return nil, rinq.NotFoundError{ID: s.ref.ID}
+	}
+
+	if s.futures == nil {
+		s.futures = map[*future]struct{}{}
+	}
+	s.futures[f] = struct{}{}
+	s.mutex.Unlock()
+
+	id, err := s.callAsync(
+		ctx,
+		ns,
+		cmd,
+		out,
+		s.wrapAsyncHandler(func(
+			_ context.Context,
+			_ rinq.Session,
+			_ ident.MessageID,
+			_, _ string,
+			in *rinq.Payload,
+			err error,
+		) {
+			s.resolveFuture(f, in, err)
+		}),
+	)
+	if err != nil {
+		s.resolveFuture(f, nil, err)
+		return nil, err
+	}
+
+	f.id = id
+
+	return f, nil
+}
+
+// resolveFuture stops tracking f and makes in and err available as its result.
+// in is closed if the future has already been resolved, such as when it has
+// been canceled.
+func (s *Session) resolveFuture(f *future, in *rinq.Payload, err error) {
+	s.mutex.Lock()
+	delete(s.futures, f)
+	s.mutex.Unlock()
+
+	if !f.resolve(in, err) {
+		in.Close()
+	}
+}
+
+// callAsync sends an asynchronous command request. The response is passed to
+// h, or to the session's async handler if h is nil.
+func (s *Session) callAsync(
+	ctx context.Context,
+	ns, cmd string,
+	out *rinq.Payload,
+	h rinq.AsyncHandler,
+) (ident.MessageID, error) {
 	namespaces.MustValidate(ns)
 
 	s.mutex.Lock()
@@ -142,14 +290,14 @@ func (s *Session) CallAsync(ctx context.Context, ns, cmd string, out *rinq.Paylo
 
 	msgID, traceID := s.nextMessageID(ctx)
 
-	span, ctx := opentr.ChildOf(ctx, s.tracer, ext.SpanKindRPCClient)
+	span, ctx := opentr.ChildOf(ctx, s.sampler.Tracer(s.tracer, ns, traceID), ext.SpanKindRPCClient)
 	defer span.Finish()
 
 	opentr.SetupCommand(span, msgID, ns, cmd)
 	opentr.AddTraceID(span, traceID)
 	opentr.LogInvokerCallAsync(span, s.attrs, out)
 
-	err := s.invoker.CallBalancedAsync(ctx, msgID, traceID, ns, cmd, out)
+	err := s.invoker.CallBalancedAsync(ctx, msgID, traceID, ns, cmd, out, h)
 
 	if err != nil {
 		opentr.LogInvokerError(span, err)
@@ -172,36 +320,39 @@ func (s *Session) SetAsyncHandler(h rinq.AsyncHandler) error {
 		return rinq.NotFoundError{ID: s.ref.ID}
 	}
 
-	s.invoker.SetAsyncHandler(
-		s.ref.ID,
-		func(
-			ctx context.Context,
-			sess rinq.Session,
-			msgID ident.MessageID,
-			ns string,
-			cmd string,
-			in *rinq.Payload,
-			err error,
-		) {
-			span := opentracing.SpanFromContext(ctx)
-			opentr.SetupCommand(span, msgID, ns, cmd)
-			opentr.AddTraceID(span, trace.Get(ctx))
-
-			if err == nil {
-				opentr.LogInvokerSuccess(span, in)
-			} else {
-				opentr.LogInvokerError(span, err)
-			}
-
-			logAsyncResponse(ctx, s.logger, msgID, ns, cmd, in, err)
-
-			h(ctx, sess, msgID, ns, cmd, in, err)
-		},
-	)
+	s.invoker.SetAsyncHandler(s.ref.ID, s.wrapAsyncHandler(h))
 
 	return nil
 }
 
+// wrapAsyncHandler returns an async handler that records the response in the
+// current span and the session's log before invoking h.
+func (s *Session) wrapAsyncHandler(h rinq.AsyncHandler) rinq.AsyncHandler {
+	return func(
+		ctx context.Context,
+		sess rinq.Session,
+		msgID ident.MessageID,
+		ns string,
+		cmd string,
+		in *rinq.Payload,
+		err error,
+	) {
+		span := opentracing.SpanFromContext(ctx)
+		opentr.SetupCommand(span, msgID, ns, cmd)
+		opentr.AddTraceID(span, trace.Get(ctx))
+
+		if err == nil {
+			opentr.LogInvokerSuccess(span, in)
+		} else {
+			opentr.LogInvokerError(span, err)
+		}
+
+		logAsyncResponse(ctx, s.logger, msgID, ns, cmd, in, err)
+
+		h(ctx, sess, msgID, ns, cmd, in, err)
+	}
+}
+
 // Execute implements rinq.Session.Execute()
 func (s *Session) Execute(ctx context.Context, ns, cmd string, p *rinq.Payload) error {
 	namespaces.MustValidate(ns)
@@ -215,7 +366,7 @@ func (s *Session) Execute(ctx context.Context, ns, cmd string, p *rinq.Payload)
 
 	msgID, traceID := s.nextMessageID(ctx)
 
-	span, ctx := opentr.ChildOf(ctx, s.tracer, ext.SpanKindRPCClient)
+	span, ctx := opentr.ChildOf(ctx, s.sampler.Tracer(s.tracer, ns, traceID), ext.SpanKindRPCClient)
 	defer span.Finish()
 
 	opentr.SetupCommand(span, msgID, ns, cmd)
@@ -233,6 +384,64 @@ func (s *Session) Execute(ctx context.Context, ns, cmd string, p *rinq.Payload)
 	return err
 }
 
+// ExecuteAt implements rinq.Session.ExecuteAt()
+func (s *Session) ExecuteAt(ctx context.Context, t time.Time, ns, cmd string, p *rinq.Payload) (ident.MessageID, error) {
+	namespaces.MustValidate(ns)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isDestroyed {
+		return ident.MessageID{}, rinq.NotFoundError{ID: s.ref.ID}
+	}
+
+	msgID, traceID := s.nextMessageID(ctx)
+
+	span, ctx := opentr.ChildOf(ctx, s.sampler.Tracer(s.tracer, ns, traceID), ext.SpanKindRPCClient)
+	defer span.Finish()
+
+	opentr.SetupCommand(span, msgID, ns, cmd)
+	opentr.AddTraceID(span, traceID)
+	opentr.LogInvokerExecuteAt(span, s.attrs, t, p)
+
+	err := s.invoker.ExecuteBalancedAt(ctx, msgID, traceID, t, ns, cmd, p)
+
+	if err != nil {
+		opentr.LogInvokerError(span, err)
+	}
+
+	logExecuteAt(s.logger, msgID, t, ns, cmd, p, err, traceID)
+
+	return msgID, err
+}
+
+// CancelExecuteAt implements rinq.Session.CancelExecuteAt()
+func (s *Session) CancelExecuteAt(ctx context.Context, id ident.MessageID) (bool, error) {
+	s.mutex.RLock()
+	ref := s.ref
+	isDestroyed := s.isDestroyed
+	s.mutex.RUnlock()
+
+	if isDestroyed {
+		return false, rinq.NotFoundError{ID: ref.ID}
+	}
+
+	span, ctx := opentr.ChildOf(ctx, s.tracer, ext.SpanKindRPCClient)
+	defer span.Finish()
+
+	opentr.SetupCommandCancel(span, id)
+
+	ok, err := s.invoker.CancelScheduled(ctx, id)
+
+	if err != nil {
+		opentr.LogInvokerError(span, err)
+	}
+
+	logCancelExecuteAt(s.logger, ref, id, ok, err)
+
+	return ok, err
+}
+
 // Notify implements rinq.Session.Notify()
 func (s *Session) Notify(ctx context.Context, ns, t string, target ident.SessionID, p *rinq.Payload) error {
 	namespaces.MustValidate(ns)
@@ -250,7 +459,7 @@ func (s *Session) Notify(ctx context.Context, ns, t string, target ident.Session
 
 	msgID, traceID := s.nextMessageID(ctx)
 
-	span, ctx := opentr.ChildOf(ctx, s.tracer, ext.SpanKindProducer)
+	span, ctx := opentr.ChildOf(ctx, s.sampler.Tracer(s.tracer, ns, traceID), ext.SpanKindProducer)
 	defer span.Finish()
 
 	opentr.SetupNotification(span, msgID, ns, t)
@@ -281,14 +490,19 @@ func (s *Session) NotifyMany(ctx context.Context, ns, t string, con constraint.C
 
 	msgID, traceID := s.nextMessageID(ctx)
 
-	span, ctx := opentr.ChildOf(ctx, s.tracer, ext.SpanKindProducer)
+	span, ctx := opentr.ChildOf(ctx, s.sampler.Tracer(s.tracer, ns, traceID), ext.SpanKindProducer)
 	defer span.Finish()
 
 	opentr.SetupNotification(span, msgID, ns, t)
 	opentr.AddTraceID(span, traceID)
 	opentr.LogNotifierMulticast(span, s.attrs, con, p)
 
-	err := s.notifier.NotifyMulticast(ctx, msgID, traceID, con, ns, t, p)
+	var err error
+	if s.dispatch == nil {
+		err = s.notifier.NotifyMulticast(ctx, msgID, traceID, con, ns, t, p)
+	} else {
+		err = s.notifier.NotifyMulticastReport(ctx, msgID, traceID, con, ns, t, p)
+	}
 
 	if err != nil {
 		opentr.LogNotifierError(span, err)
@@ -300,12 +514,14 @@ func (s *Session) NotifyMany(ctx context.Context, ns, t string, con constraint.C
 }
 
 // Listen implements rinq.Session.Listen()
-func (s *Session) Listen(ns string, h rinq.NotificationHandler) error {
+func (s *Session) Listen(ns string, h rinq.NotificationHandler, mw ...rinq.NotificationMiddleware) error {
 	namespaces.MustValidate(ns)
 	if h == nil {
 		panic("handler must not be nil")
 	}
 
+	h = rinq.WithNotificationMiddleware(h, mw...)
+
 	// it is important that this lock is acquired for the duration of the call
 	// to s.listener.Listen(), to ensure that it is serialized with the call
 	// to s.listener.UnlistenAll() in s.destroy().
@@ -388,3 +604,17 @@ func (s *Session) Destroy() {
 func (s *Session) Done() <-chan struct{} {
 	return s.done
 }
+
+// OnDestroy implements rinq.Session.OnDestroy()
+func (s *Session) OnDestroy(fn func()) {
+	s.mutex.Lock()
+
+	if !s.isDestroyed {
+		s.onDestroy = append(s.onDestroy, fn)
+		s.mutex.Unlock()
+		return
+	}
+
+	s.mutex.Unlock()
+	fn()
+}
@@ -22,6 +22,32 @@ func logCreated(
 	)
 }
 
+func logLabel(
+	logger twelf.Logger,
+	ref ident.Ref,
+	prev string,
+	label string,
+) {
+	if label == "" {
+		logger.Log(
+			"%s session label removed",
+			ref.ShortString(),
+		)
+	} else if prev == "" {
+		logger.Log(
+			"%s session labeled '%s'",
+			ref.ShortString(),
+			label,
+		)
+	} else {
+		logger.Log(
+			"%s session relabeled '%s'",
+			ref.ShortString(),
+			label,
+		)
+	}
+}
+
 func logCall(
 	logger twelf.Logger,
 	msgID ident.MessageID,
@@ -69,7 +95,7 @@ func logCall(
 			traceID,
 		)
 	default:
-		if err == context.DeadlineExceeded || err == context.Canceled {
+		if rinq.IsDeadlineExceeded(err) || err == context.Canceled {
 			logger.Log(
 				"%s called '%s::%s' command: %s (%dms, %d/o -/i) [%s]",
 				msgID.ShortString(),
@@ -171,6 +197,49 @@ func logExecute(
 	)
 }
 
+func logExecuteAt(
+	logger twelf.Logger,
+	msgID ident.MessageID,
+	t time.Time,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+	err error,
+	traceID string,
+) {
+	if err != nil {
+		return // request never sent
+	}
+
+	logger.Log(
+		"%s scheduled '%s::%s' command for %s (%d/o) [%s]",
+		msgID.ShortString(),
+		ns,
+		cmd,
+		t.Format(time.RFC3339),
+		out.Len(),
+		traceID,
+	)
+}
+
+func logCancelExecuteAt(
+	logger twelf.Logger,
+	ref ident.Ref,
+	msgID ident.MessageID,
+	ok bool,
+	err error,
+) {
+	if err != nil || !ok {
+		return
+	}
+
+	logger.Log(
+		"%s canceled scheduled command %s",
+		ref.ShortString(),
+		msgID.ShortString(),
+	)
+}
+
 func logNotify(
 	logger twelf.Logger,
 	msgID ident.MessageID,
@@ -196,6 +265,68 @@ func logNotify(
 	)
 }
 
+func logNotifyAndWait(
+	logger twelf.Logger,
+	msgID ident.MessageID,
+	ns string,
+	t string,
+	target ident.SessionID,
+	elapsed time.Duration,
+	out *rinq.Payload,
+	in *rinq.Payload,
+	err error,
+	traceID string,
+) {
+	if err == nil {
+		logger.Log(
+			"%s sent '%s::%s' notification to %s: replied (%dms, %d/o %d/i) [%s]",
+			msgID.ShortString(),
+			ns,
+			t,
+			target.ShortString(),
+			elapsed,
+			out.Len(),
+			in.Len(),
+			traceID,
+		)
+	} else {
+		logger.Log(
+			"%s sent '%s::%s' notification to %s: %s (%dms, %d/o) [%s]",
+			msgID.ShortString(),
+			ns,
+			t,
+			target.ShortString(),
+			err,
+			elapsed,
+			out.Len(),
+			traceID,
+		)
+	}
+}
+
+func logReply(
+	logger twelf.Logger,
+	msgID ident.MessageID,
+	n rinq.Notification,
+	out *rinq.Payload,
+	err error,
+	traceID string,
+) {
+	if err != nil {
+		return // reply never sent
+	}
+
+	logger.Log(
+		"%s replied to '%s::%s' notification %s (%d/o) [%s]",
+		msgID.ShortString(),
+		n.Namespace,
+		n.Type,
+		n.ID.ShortString(),
+		out.Len(),
+		traceID,
+	)
+}
+
 func logNotifyMany(
 	logger twelf.Logger,
 	msgID ident.MessageID,
@@ -221,6 +352,32 @@ func logNotifyMany(
 	)
 }
 
+func logPublish(
+	logger twelf.Logger,
+	msgID ident.MessageID,
+	diff *attributes.Diff,
+	err error,
+	traceID string,
+) {
+	if err != nil {
+		logger.Log(
+			"%s could not publish attribute changes %s: %s [%s]",
+			msgID.ShortString(),
+			diff,
+			err,
+			traceID,
+		)
+		return
+	}
+
+	logger.Debug(
+		"%s published attribute changes %s [%s]",
+		msgID.ShortString(),
+		diff,
+		traceID,
+	)
+}
+
 func logNotifyRecv(
 	logger twelf.Logger,
 	ref ident.Ref,
@@ -283,3 +440,103 @@ func logSessionDestroy(
 		)
 	}
 }
+
+func logHedge(
+	logger twelf.Logger,
+	msgID ident.MessageID,
+	hedgeID ident.MessageID,
+	ns string,
+	cmd string,
+	delay time.Duration,
+	traceID string,
+) {
+	logger.Debug(
+		"%s hedged '%s::%s' command as %s after %dms without a response [%s]",
+		msgID.ShortString(),
+		ns,
+		cmd,
+		hedgeID.ShortString(),
+		delay/time.Millisecond,
+		traceID,
+	)
+}
+
+func logLinkOpened(
+	logger twelf.Logger,
+	ref ident.Ref,
+	target ident.SessionID,
+) {
+	logger.Debug(
+		"%s opened link to %s",
+		ref.ShortString(),
+		target.ShortString(),
+	)
+}
+
+func logLinkClosed(
+	logger twelf.Logger,
+	ref ident.Ref,
+	target ident.SessionID,
+) {
+	logger.Debug(
+		"%s closed link to %s",
+		ref.ShortString(),
+		target.ShortString(),
+	)
+}
+
+func logLinkDiscarded(
+	logger twelf.Logger,
+	ref ident.Ref,
+	source ident.SessionID,
+) {
+	logger.Debug(
+		"%s discarded link message from %s, no link is open",
+		ref.ShortString(),
+		source.ShortString(),
+	)
+}
+
+func logReplyDiscarded(
+	logger twelf.Logger,
+	ref ident.Ref,
+	n rinq.Notification,
+) {
+	logger.Debug(
+		"%s discarded reply to %s from %s, no longer waiting",
+		ref.ShortString(),
+		n.CorrelationID.ShortString(),
+		n.ID.Ref.ShortString(),
+	)
+}
+
+func logDispatchReport(
+	ctx context.Context,
+	logger twelf.Logger,
+	ref ident.Ref,
+	r rinq.DispatchReport,
+) {
+	logger.Debug(
+		"%s notification %s was dispatched to %d session(s) on %s [%s]",
+		ref.ShortString(),
+		r.ID.ShortString(),
+		r.Count,
+		r.Peer.ShortString(),
+		trace.Get(ctx),
+	)
+}
+
+func logDispatchReportInvalid(
+	logger twelf.Logger,
+	ref ident.Ref,
+	n rinq.Notification,
+	err error,
+) {
+	logger.Log(
+		"%s discarded invalid dispatch report %s from %s: %s",
+		ref.ShortString(),
+		n.ID.ShortString(),
+		n.ID.Ref.ID.Peer.ShortString(),
+		err,
+	)
+}
@@ -3,8 +3,10 @@ package localsession
 import (
 	"context"
 	"errors"
+	"sort"
 
 	"github.com/rinq/rinq-go/src/internal/attributes"
+	"github.com/rinq/rinq-go/src/internal/revisions"
 	"github.com/rinq/rinq-go/src/rinq"
 	"github.com/rinq/rinq-go/src/rinq/ident"
 	"github.com/rinq/rinq-go/src/rinq/trace"
@@ -47,12 +49,69 @@ func (s *Session) AttrsIn(ns string) (ident.Ref, attributes.VTable) {
 	return s.ref, s.attrs[ns]
 }
 
+// Watch returns the most recent revision, and a channel that is closed when
+// the session is next updated or destroyed.
+//
+// If the session has already been destroyed, rev is a closed revision and ch
+// is nil.
+func (s *Session) Watch() (rev rinq.Revision, ch <-chan struct{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isDestroyed {
+		return revisions.Closed(s.ref.ID), nil
+	}
+
+	if s.changed == nil {
+		s.changed = make(chan struct{})
+	}
+
+	return &revision{
+		s.ref,
+		s,
+		s.attrs,
+		s.logger,
+	}, s.changed
+}
+
+// notifyChanged wakes any callers waiting on the channel returned by Watch().
+// It assumes s.mutex is locked.
+func (s *Session) notifyChanged() {
+	if s.changed != nil {
+		close(s.changed)
+		s.changed = nil
+	}
+}
+
 // TryUpdate adds or updates attributes in the ns namespace of the attribute
 // table and returns the new head revision.
 //
 // The operation fails if ref is not the current session-ref, attrs includes
-// changes to frozen attributes, or the session has been destroyed.
-func (s *Session) TryUpdate(rev ident.Revision, ns string, attrs attributes.List) (rinq.Revision, *attributes.Diff, error) {
+// changes to frozen attributes, the changes exceed the attribute quota, or the
+// session has been destroyed.
+func (s *Session) TryUpdate(ctx context.Context, rev ident.Revision, ns string, attrs attributes.List) (rinq.Revision, *attributes.Diff, error) {
+	r, diffs, err := s.TryUpdateMany(ctx, rev, map[string]attributes.List{ns: attrs})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r, diffs[0], nil
+}
+
+// TryUpdateMany adds or updates attributes in several namespaces of the
+// attribute table as a single revision and returns the new head revision.
+//
+// attrs is a map of namespace to the attributes to update. The returned diffs
+// contain one entry for each namespace in attrs, ordered by namespace.
+//
+// The operation fails if ref is not the current session-ref, attrs includes
+// changes to frozen attributes, the changes exceed the attribute quota, or the
+// session has been destroyed.
+func (s *Session) TryUpdateMany(ctx context.Context, rev ident.Revision, attrs map[string]attributes.List) (rinq.Revision, []*attributes.Diff, error) {
+	// the feed is published after the mutex is unlocked, see publish()
+	var feed []feedUpdate
+	defer func() { s.publish(feed) }()
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -65,51 +124,109 @@ func (s *Session) TryUpdate(rev ident.Revision, ns string, attrs attributes.List
 	}
 
 	nextRev := rev + 1
-	nextAttrs := s.attrs[ns].Clone()
-	diff := attributes.NewDiff(ns, nextRev)
+	changes := map[string]attributes.VTable{}
+	usage := map[string]attrUsage{}
+	totalUsage := s.totalUsage
+	diffs := make([]*attributes.Diff, 0, len(attrs))
+
+	names := make([]string, 0, len(attrs))
+	for ns := range attrs {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+
+	for _, ns := range names {
+		nextAttrs := s.attrs[ns]
+		nextUsage := s.usage[ns]
+		diff := attributes.NewDiff(ns, nextRev)
+		_, isFrozenNamespace := s.frozen[ns]
+
+		for _, attr := range attrs[ns] {
+			entry, exists := nextAttrs.Get(attr.Key)
+
+			if attr.Value == entry.Value && attr.IsFrozen == entry.IsFrozen {
+				continue
+			}
+
+			if entry.IsFrozen || isFrozenNamespace {
+				return nil, nil, rinq.FrozenAttributesError{Ref: s.ref.ID.At(rev)}
+			}
+
+			nextUsage = nextUsage.add(usageOf(attr)).sub(usageOf(entry.Attr))
 
-	for _, attr := range attrs {
-		entry, exists := nextAttrs[attr.Key]
+			entry.Attr = attr
+			entry.UpdatedAt = nextRev
+			if !exists {
+				entry.CreatedAt = nextRev
+			}
 
-		if attr.Value == entry.Value && attr.IsFrozen == entry.IsFrozen {
-			continue
+			nextAttrs = nextAttrs.With(entry)
+			diff.Append(entry)
 		}
 
-		if entry.IsFrozen {
-			return nil, nil, rinq.FrozenAttributesError{Ref: s.ref.ID.At(rev)}
+		if nextUsage.exceeds(s.quota.MaxNamespaceCount, s.quota.MaxNamespaceBytes) {
+			return nil, nil, rinq.QuotaExceededError{Ref: s.ref.ID.At(rev), Namespace: ns}
 		}
 
-		entry.Attr = attr
-		entry.UpdatedAt = nextRev
-		if !exists {
-			entry.CreatedAt = nextRev
+		if !diff.IsEmpty() {
+			changes[ns] = nextAttrs
+			usage[ns] = nextUsage
+			totalUsage = totalUsage.add(nextUsage).sub(s.usage[ns])
 		}
 
-		nextAttrs[attr.Key] = entry
-		diff.Append(entry)
+		diffs = append(diffs, diff)
+	}
+
+	if totalUsage.exceeds(s.quota.MaxCount, s.quota.MaxBytes) {
+		return nil, nil, rinq.QuotaExceededError{Ref: s.ref.ID.At(rev)}
 	}
 
 	s.ref.Rev = nextRev
 	s.msgSeq = 0
+	s.notifyChanged()
 
-	if !diff.IsEmpty() {
-		s.attrs = s.attrs.WithNamespace(ns, nextAttrs)
+	if len(changes) != 0 {
+		s.attrs = s.attrs.WithNamespaces(changes)
+		s.updateUsage(usage, totalUsage)
 	}
 
+	feed = s.prepareFeed(ctx, diffs...)
+
 	return &revision{
 		s.ref,
 		s,
 		s.attrs,
 		s.logger,
-	}, diff, nil
+	}, diffs, nil
+}
+
+// updateUsage records the quota usage of the namespaces in usage, and of the
+// session as a whole. It assumes s.mutex is already locked.
+func (s *Session) updateUsage(usage map[string]attrUsage, total attrUsage) {
+	if s.usage == nil {
+		s.usage = make(map[string]attrUsage, len(usage))
+	}
+
+	for ns, u := range usage {
+		s.usage[ns] = u
+	}
+
+	s.totalUsage = total
 }
 
-// TryClear updates all attributes in the ns namespace of the attribute
-// table to the empty string and returns the new head revision.
+// TryClear updates attributes in the ns namespace of the attribute table to
+// the empty string and returns the new head revision.
 //
-// The operation fails if ref is not the current session-ref, there are any
-// frozen attributes, or the session has been destroyed.
-func (s *Session) TryClear(rev ident.Revision, ns string) (rinq.Revision, *attributes.Diff, error) {
+// If keys is empty, all attributes in the namespace are cleared, otherwise
+// only the attributes with the given keys are cleared.
+//
+// The operation fails if ref is not the current session-ref, any of the
+// attributes being cleared are frozen, or the session has been destroyed.
+func (s *Session) TryClear(ctx context.Context, rev ident.Revision, ns string, keys []string) (rinq.Revision, *attributes.Diff, error) {
+	// the feed is published after the mutex is unlocked, see publish()
+	var feed []feedUpdate
+	defer func() { s.publish(feed) }()
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -123,30 +240,117 @@ func (s *Session) TryClear(rev ident.Revision, ns string) (rinq.Revision, *attri
 
 	attrs := s.attrs[ns]
 	nextRev := rev + 1
-	nextAttrs := attributes.VTable{}
+	nextUsage := s.usage[ns]
 	diff := attributes.NewDiff(ns, nextRev)
 
-	for _, entry := range attrs {
+	var only map[string]struct{}
+	if len(keys) != 0 {
+		only = make(map[string]struct{}, len(keys))
+		for _, k := range keys {
+			only[k] = struct{}{}
+		}
+	}
+
+	isFrozen := false
+
+	attrs.Range(func(entry attributes.VAttr) bool {
+		if _, ok := only[entry.Key]; only != nil && !ok {
+			return true
+		}
+
 		if entry.Value != "" {
 			if entry.IsFrozen {
-				return nil, nil, rinq.FrozenAttributesError{Ref: s.ref.ID.At(rev)}
+				isFrozen = true
+				return false
 			}
 
+			nextUsage = nextUsage.sub(usageOf(entry.Attr))
+
 			entry.Value = ""
 			entry.UpdatedAt = nextRev
 			diff.Append(entry)
 		}
 
-		nextAttrs[entry.Key] = entry
+		return true
+	})
+
+	if isFrozen {
+		return nil, nil, rinq.FrozenAttributesError{Ref: s.ref.ID.At(rev)}
+	}
+
+	s.ref.Rev = nextRev
+	s.msgSeq = 0
+	s.notifyChanged()
+
+	if !diff.IsEmpty() {
+		s.attrs = s.attrs.WithNamespace(ns, attrs.With(diff.VList...))
+		s.updateUsage(
+			map[string]attrUsage{ns: nextUsage},
+			s.totalUsage.add(nextUsage).sub(s.usage[ns]),
+		)
+	}
+
+	feed = s.prepareFeed(ctx, diff)
+
+	return &revision{
+		s.ref,
+		s,
+		s.attrs,
+		s.logger,
+	}, diff, nil
+}
+
+// TryFreezeNamespace freezes all attributes in the ns namespace of the
+// attribute table and prevents the creation of new attributes in that
+// namespace. It returns the new head revision.
+//
+// The operation fails if ref is not the current session-ref, or the session
+// has been destroyed.
+func (s *Session) TryFreezeNamespace(ctx context.Context, rev ident.Revision, ns string) (rinq.Revision, *attributes.Diff, error) {
+	// the feed is published after the mutex is unlocked, see publish()
+	var feed []feedUpdate
+	defer func() { s.publish(feed) }()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isDestroyed {
+		return nil, nil, rinq.NotFoundError{ID: s.ref.ID}
+	}
+
+	if rev != s.ref.Rev {
+		return nil, nil, rinq.StaleUpdateError{Ref: s.ref.ID.At(rev)}
+	}
+
+	attrs := s.attrs[ns]
+	nextRev := rev + 1
+	diff := attributes.NewDiff(ns, nextRev)
+
+	attrs.Range(func(entry attributes.VAttr) bool {
+		if !entry.IsFrozen {
+			entry.IsFrozen = true
+			entry.UpdatedAt = nextRev
+			diff.Append(entry)
+		}
+
+		return true
+	})
+
+	if s.frozen == nil {
+		s.frozen = map[string]struct{}{}
 	}
 
+	s.frozen[ns] = struct{}{}
 	s.ref.Rev = nextRev
 	s.msgSeq = 0
+	s.notifyChanged()
 
 	if !diff.IsEmpty() {
-		s.attrs = s.attrs.WithNamespace(ns, nextAttrs)
+		s.attrs = s.attrs.WithNamespace(ns, attrs.With(diff.VList...))
 	}
 
+	feed = s.prepareFeed(ctx, diff)
+
 	return &revision{
 		s.ref,
 		s,
@@ -179,16 +383,41 @@ func (s *Session) TryDestroy(rev ident.Revision) (bool, error) {
 }
 
 // destroy marks the session as destroyed removes any callbacks registered with
-// the command and notification subsystems.
+// the command and notification subsystems, then calls the functions registered
+// with OnDestroy() once all pending calls have finished.
 func (s *Session) destroy() {
 	s.isDestroyed = true
+	s.notifyChanged()
 
 	s.invoker.SetAsyncHandler(s.ref.ID, nil)
 	_ = s.listener.UnlistenAll(s.ref.ID)
 
+	for _, l := range s.links {
+		l.close()
+	}
+	s.links = nil
+
+	for _, replies := range s.replies {
+		close(replies)
+	}
+	s.replies = nil
+
+	for f := range s.futures {
+		f.resolve(nil, rinq.NotFoundError{ID: s.ref.ID})
+	}
+	s.futures = nil
+
+	hooks := s.onDestroy
+	s.onDestroy = nil
+
 	go func() {
 		// close the done channel only after all pending calls have finished
 		s.calls.Wait()
+
+		for _, fn := range hooks {
+			fn()
+		}
+
 		close(s.done)
 	}()
 }
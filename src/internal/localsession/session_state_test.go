@@ -0,0 +1,270 @@
+package localsession_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rinq/rinq-go/src/internal/attributes"
+	. "github.com/rinq/rinq-go/src/internal/localsession"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+)
+
+var _ = Describe("Session", func() {
+	var (
+		quota  options.QuotaOptions
+		logger *bufferLogger
+		sess   *Session
+	)
+
+	BeforeEach(func() {
+		quota = options.QuotaOptions{}
+		logger = &bufferLogger{}
+	})
+
+	JustBeforeEach(func() {
+		sess = NewSession(
+			ident.NewPeerID().Session(1),
+			&asyncInvoker{},
+			nil, // notifier
+			&nullListener{},
+			quota,
+			nil, // feed
+			logger,
+			opentracing.NoopTracer{},
+			nil, // sampler
+		)
+	})
+
+	Describe("TryUpdateMany", func() {
+		Context("when the namespace quota is exceeded", func() {
+			BeforeEach(func() {
+				quota.MaxNamespaceCount = 2
+				quota.MaxNamespaceBytes = 6
+			})
+
+			It("returns an error if there are too many attributes", func() {
+				_, _, err := sess.TryUpdateMany(context.Background(), 0, map[string]attributes.List{
+					"ns": {rinq.Set("a", "1"), rinq.Set("b", "2"), rinq.Set("c", "3")},
+				})
+
+				Expect(err).To(Equal(rinq.QuotaExceededError{
+					Ref:       sess.CurrentRevision().Ref(),
+					Namespace: "ns",
+				}))
+			})
+
+			It("returns an error if the attributes are too large", func() {
+				_, _, err := sess.TryUpdateMany(context.Background(), 0, map[string]attributes.List{
+					"ns": {rinq.Set("a", "1234567")},
+				})
+
+				Expect(err).To(Equal(rinq.QuotaExceededError{
+					Ref:       sess.CurrentRevision().Ref(),
+					Namespace: "ns",
+				}))
+			})
+
+			It("does not apply any changes", func() {
+				_, _, err := sess.TryUpdateMany(context.Background(), 0, map[string]attributes.List{
+					"ns1": {rinq.Set("a", "1")},
+					"ns2": {rinq.Set("b", "2"), rinq.Set("c", "3"), rinq.Set("d", "4")},
+				})
+				Expect(err).To(HaveOccurred())
+
+				ref, cat := sess.Attrs()
+				Expect(ref.Rev).To(Equal(ident.Revision(0)))
+				Expect(cat.IsEmpty()).To(BeTrue())
+			})
+
+			It("applies the quota to each namespace separately", func() {
+				_, _, err := sess.TryUpdateMany(context.Background(), 0, map[string]attributes.List{
+					"ns1": {rinq.Set("a", "1"), rinq.Set("b", "2")},
+					"ns2": {rinq.Set("c", "3"), rinq.Set("d", "4")},
+				})
+
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("does not count attributes that have been cleared", func() {
+				_, _, err := sess.TryUpdateMany(context.Background(), 0, map[string]attributes.List{
+					"ns": {rinq.Set("a", "1"), rinq.Set("b", "2")},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, err = sess.TryClear(context.Background(), 1, "ns", []string{"a"})
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, err = sess.TryUpdateMany(context.Background(), 2, map[string]attributes.List{
+					"ns": {rinq.Set("c", "3")},
+				})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("does not count the previous value of an updated attribute", func() {
+				_, _, err := sess.TryUpdateMany(context.Background(), 0, map[string]attributes.List{
+					"ns": {rinq.Set("a", "1"), rinq.Set("b", "2")},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, err = sess.TryUpdateMany(context.Background(), 1, map[string]attributes.List{
+					"ns": {rinq.Set("a", "12")},
+				})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the session quota is exceeded", func() {
+			BeforeEach(func() {
+				quota.MaxCount = 2
+				quota.MaxBytes = 6
+			})
+
+			It("returns an error if there are too many attributes", func() {
+				_, _, err := sess.TryUpdateMany(context.Background(), 0, map[string]attributes.List{
+					"ns1": {rinq.Set("a", "1"), rinq.Set("b", "2")},
+					"ns2": {rinq.Set("c", "3")},
+				})
+
+				Expect(err).To(Equal(rinq.QuotaExceededError{
+					Ref: sess.CurrentRevision().Ref(),
+				}))
+			})
+
+			It("returns an error if the attributes are too large", func() {
+				_, _, err := sess.TryUpdateMany(context.Background(), 0, map[string]attributes.List{
+					"ns1": {rinq.Set("a", "12")},
+					"ns2": {rinq.Set("b", "1234")},
+				})
+
+				Expect(err).To(Equal(rinq.QuotaExceededError{
+					Ref: sess.CurrentRevision().Ref(),
+				}))
+			})
+
+			It("counts attributes from previous revisions", func() {
+				_, _, err := sess.TryUpdateMany(context.Background(), 0, map[string]attributes.List{
+					"ns1": {rinq.Set("a", "1"), rinq.Set("b", "2")},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, err = sess.TryUpdateMany(context.Background(), 1, map[string]attributes.List{
+					"ns2": {rinq.Set("c", "3")},
+				})
+
+				Expect(err).To(Equal(rinq.QuotaExceededError{
+					Ref: sess.CurrentRevision().Ref(),
+				}))
+			})
+		})
+
+		It("does not limit the attributes if no quota is configured", func() {
+			_, _, err := sess.TryUpdateMany(context.Background(), 0, map[string]attributes.List{
+				"ns": {rinq.Set("a", "1"), rinq.Set("b", "2"), rinq.Set("c", "3")},
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("AwaitRevision", func() {
+		It("returns the current revision if it has already reached the given revision", func() {
+			_, _, err := sess.TryUpdate(context.Background(), 0, "ns", attributes.List{rinq.Set("a", "1")})
+			Expect(err).NotTo(HaveOccurred())
+
+			rev, err := sess.AwaitRevision(context.Background(), 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rev.Ref().Rev).To(Equal(ident.Revision(1)))
+		})
+
+		It("waits until the session reaches the given revision", func() {
+			result := make(chan rinq.Revision, 1)
+			go func() {
+				defer GinkgoRecover()
+
+				rev, err := sess.AwaitRevision(context.Background(), 2)
+				Expect(err).NotTo(HaveOccurred())
+				result <- rev
+			}()
+
+			_, _, err := sess.TryUpdate(context.Background(), 0, "ns", attributes.List{rinq.Set("a", "1")})
+			Expect(err).NotTo(HaveOccurred())
+			Consistently(result).ShouldNot(Receive())
+
+			_, _, err = sess.TryUpdate(context.Background(), 1, "ns", attributes.List{rinq.Set("a", "2")})
+			Expect(err).NotTo(HaveOccurred())
+
+			var rev rinq.Revision
+			Eventually(result).Should(Receive(&rev))
+			Expect(rev.Ref().Rev).To(Equal(ident.Revision(2)))
+		})
+
+		It("returns the context error if the context is canceled first", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			_, err := sess.AwaitRevision(ctx, 1)
+			Expect(err).To(Equal(context.DeadlineExceeded))
+		})
+	})
+
+	Describe("SetLabel", func() {
+		It("stores the label", func() {
+			sess.SetLabel("<label>")
+
+			Expect(sess.Label()).To(Equal("<label>"))
+		})
+
+		It("logs the label change against the session", func() {
+			ref := sess.CurrentRevision().Ref().ShortString()
+			logger.lines = nil
+
+			sess.SetLabel("<label>")
+			sess.SetLabel("<other>")
+			sess.SetLabel("")
+
+			Expect(logger.lines).To(Equal([]string{
+				ref + " session labeled '<label>'",
+				"[<label>] " + ref + " session relabeled '<other>'",
+				"[<other>] " + ref + " session label removed",
+			}))
+		})
+
+		It("prefixes subsequent log messages with the label", func() {
+			sess.SetLabel("<label>")
+			logger.lines = nil
+
+			sess.Destroy()
+
+			Expect(logger.lines).To(ContainElement(
+				HavePrefix("[<label>] " + sess.ID().At(0).ShortString() + " session destroyed"),
+			))
+		})
+	})
+})
+
+// bufferLogger is a twelf.Logger that records each message.
+type bufferLogger struct {
+	lines []string
+}
+
+func (l *bufferLogger) Log(f string, v ...interface{}) {
+	l.LogString(fmt.Sprintf(f, v...))
+}
+
+func (l *bufferLogger) LogString(s string) {
+	l.lines = append(l.lines, s)
+}
+
+func (l *bufferLogger) Debug(f string, v ...interface{}) {}
+
+func (l *bufferLogger) DebugString(s string) {}
+
+func (l *bufferLogger) IsDebug() bool {
+	return false
+}
@@ -1,6 +1,7 @@
 package localsession
 
 import (
+	"context"
 	"sync"
 
 	"github.com/rinq/rinq-go/src/internal/revisions"
@@ -8,63 +9,133 @@ import (
 	"github.com/rinq/rinq-go/src/rinq/ident"
 )
 
+// storeShards is the number of shards that a store's sessions are divided
+// between. It must be a power of two.
+const storeShards = 64
+
 // Store is a collection of local sessions which provides an implementation
 // of revisions.Store.
+//
+// The sessions are divided between several shards, each with its own lock, so
+// that peers with many sessions do not contend on a single lock when sessions
+// are looked up, created or destroyed.
 type Store struct {
+	shards [storeShards]storeShard
+}
+
+// storeShard is a subset of the sessions in a store.
+type storeShard struct {
 	mutex    sync.RWMutex
 	sessions map[ident.SessionID]*Session
 }
 
 // NewStore returns a new session store.
 func NewStore() *Store {
-	return &Store{
-		sessions: map[ident.SessionID]*Session{},
+	s := &Store{}
+
+	for i := range s.shards {
+		s.shards[i].sessions = map[ident.SessionID]*Session{}
 	}
+
+	return s
+}
+
+// shard returns the shard that contains the session with the given ID.
+//
+// All of the sessions in a store are owned by the same peer, so the sequence
+// number alone distributes them evenly.
+func (s *Store) shard(id ident.SessionID) *storeShard {
+	return &s.shards[id.Seq&(storeShards-1)]
 }
 
 // Add adds a session to the store.
 func (s *Store) Add(sess *Session) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	id := sess.ID()
+	sh := s.shard(id)
 
-	s.sessions[sess.ID()] = sess
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	sh.sessions[id] = sess
 }
 
 // Remove removes a session to from the store.
 func (s *Store) Remove(id ident.SessionID) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	sh := s.shard(id)
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
 
-	delete(s.sessions, id)
+	delete(sh.sessions, id)
 }
 
 // Get fetches a session from the store by its ID.
 func (s *Store) Get(id ident.SessionID) (sess *Session, ok bool) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+	sh := s.shard(id)
+
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
 
-	sess, ok = s.sessions[id]
+	sess, ok = sh.sessions[id]
 	return
 }
 
+// Len returns the number of sessions in the store.
+func (s *Store) Len() int {
+	n := 0
+
+	for i := range s.shards {
+		sh := &s.shards[i]
+
+		sh.mutex.RLock()
+		n += len(sh.sessions)
+		sh.mutex.RUnlock()
+	}
+
+	return n
+}
+
 // Each calls fn(sess) for each session in the store.
+//
+// Each shard is locked in turn, so sessions that are added or removed while
+// Each() is running may or may not be visited.
 func (s *Store) Each(fn func(*Session)) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+	for i := range s.shards {
+		s.shards[i].each(fn)
+	}
+}
+
+// each calls fn(sess) for each session in the shard.
+func (sh *storeShard) each(fn func(*Session)) {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
 
-	for _, sess := range s.sessions {
+	for _, sess := range sh.sessions {
 		fn(sess)
 	}
 }
 
 // GetRevision returns the session revision for the given ref.
 func (s *Store) GetRevision(ref ident.Ref) (rinq.Revision, error) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-
-	if sess, ok := s.sessions[ref.ID]; ok {
+	if sess, ok := s.Get(ref.ID); ok {
 		return sess.At(ref.Rev)
 	}
 
 	return revisions.Closed(ref.ID), nil
 }
+
+// GetRevisions returns the session revisions for the given refs.
+func (s *Store) GetRevisions(ctx context.Context, refs []ident.Ref) ([]rinq.Revision, error) {
+	revs := make([]rinq.Revision, len(refs))
+
+	for i, ref := range refs {
+		rev, err := s.GetRevision(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		revs[i] = rev
+	}
+
+	return revs, nil
+}
@@ -0,0 +1,96 @@
+package localsession_test
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jmalloc/twelf/src/twelf"
+	opentracing "github.com/opentracing/opentracing-go"
+	. "github.com/rinq/rinq-go/src/internal/localsession"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+)
+
+// benchmarkSessions is the number of sessions in the store used by the
+// lookup benchmarks.
+const benchmarkSessions = 100000
+
+// silenceLogs discards the "session created" messages that are logged for
+// each session, returning a function that restores the log output.
+func silenceLogs() (restore func()) {
+	log.SetOutput(ioutil.Discard)
+	return func() { log.SetOutput(os.Stderr) }
+}
+
+func newBenchmarkStore(b *testing.B, peerID ident.PeerID) *Store {
+	store := NewStore()
+
+	for seq := uint32(1); seq <= benchmarkSessions; seq++ {
+		store.Add(newBenchmarkSession(peerID, seq))
+	}
+
+	b.ResetTimer()
+
+	return store
+}
+
+func newBenchmarkSession(peerID ident.PeerID, seq uint32) *Session {
+	return NewSession(
+		peerID.Session(seq),
+		nil,
+		nil,
+		nil,
+		options.QuotaOptions{},
+		nil,
+		&twelf.StandardLogger{},
+		opentracing.NoopTracer{},
+		nil,
+	)
+}
+
+func BenchmarkStoreGet(b *testing.B) {
+	defer silenceLogs()()
+
+	peerID := ident.NewPeerID()
+	store := newBenchmarkStore(b, peerID)
+
+	for i := 0; i < b.N; i++ {
+		store.Get(peerID.Session(uint32(i%benchmarkSessions) + 1))
+	}
+}
+
+func BenchmarkStoreGetParallel(b *testing.B) {
+	defer silenceLogs()()
+
+	peerID := ident.NewPeerID()
+	store := newBenchmarkStore(b, peerID)
+	var seq uint32
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := atomic.AddUint32(&seq, 7919) // spread goroutines across the store
+
+		for pb.Next() {
+			i++
+			store.Get(peerID.Session(i%benchmarkSessions + 1))
+		}
+	})
+}
+
+func BenchmarkStoreAddRemoveParallel(b *testing.B) {
+	defer silenceLogs()()
+
+	peerID := ident.NewPeerID()
+	store := NewStore()
+	var seq uint32
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			sess := newBenchmarkSession(peerID, atomic.AddUint32(&seq, 1))
+			store.Add(sess)
+			store.Remove(sess.ID())
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/jmalloc/twelf/src/twelf"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/options"
+)
+
+// NewPayloadLogger returns a logger that renders any *rinq.Payload arguments
+// to l according to opts. If redact is non-nil, it is applied to the value of
+// each payload before it is rendered.
+//
+// If opts is the zero value and redact is nil, l is returned unchanged.
+func NewPayloadLogger(
+	l twelf.Logger,
+	opts options.PayloadLogOptions,
+	redact func(interface{}) interface{},
+) twelf.Logger {
+	if opts == (options.PayloadLogOptions{}) && redact == nil {
+		return l
+	}
+
+	return &payloadLogger{l, opts, redact}
+}
+
+// payloadLogger is a twelf.Logger that renders payload arguments according to
+// the payload logging options.
+type payloadLogger struct {
+	twelf.Logger
+
+	opts   options.PayloadLogOptions
+	redact func(interface{}) interface{}
+}
+
+func (l *payloadLogger) Log(f string, v ...interface{}) {
+	l.Logger.LogString(fmt.Sprintf(f, l.render(v)...))
+}
+
+func (l *payloadLogger) Debug(f string, v ...interface{}) {
+	if l.Logger.IsDebug() {
+		l.Logger.DebugString(fmt.Sprintf(f, l.render(v)...))
+	}
+}
+
+// render returns a copy of v with each payload replaced by its rendered
+// representation.
+func (l *payloadLogger) render(v []interface{}) []interface{} {
+	r := make([]interface{}, len(v))
+
+	for i, a := range v {
+		if p, ok := a.(*rinq.Payload); ok {
+			r[i] = l.renderPayload(p)
+		} else {
+			r[i] = a
+		}
+	}
+
+	return r
+}
+
+// renderPayload returns the representation of p to include in a log message.
+func (l *payloadLogger) renderPayload(p *rinq.Payload) string {
+	if l.opts.Omit {
+		return fmt.Sprintf("(%d bytes)", p.Len())
+	}
+
+	if l.redact == nil {
+		return truncate(p.String(), l.opts.MaxLength)
+	}
+
+	// decode a fresh copy of the value, so that the redactor can not modify
+	// the value of the payload itself
+	var v interface{}
+	if err := p.Decode(&v); err != nil {
+		return fmt.Sprintf("(%d bytes, %s)", p.Len(), err)
+	}
+
+	r := rinq.NewPayload(l.redact(v))
+	defer r.Close()
+
+	return truncate(r.String(), l.opts.MaxLength)
+}
+
+// truncate returns s limited to max bytes, without splitting a multi-byte
+// character. If max is zero, s is returned unchanged.
+func truncate(s string, max uint) string {
+	if max == 0 || uint(len(s)) <= max {
+		return s
+	}
+
+	n := int(max)
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return fmt.Sprintf("%s... (%d bytes truncated)", s[:n], len(s)-n)
+}
@@ -0,0 +1,155 @@
+package logging_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/rinq/rinq-go/src/internal/logging"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/options"
+)
+
+var _ = Describe("NewPayloadLogger", func() {
+	var (
+		logger  *bufferLogger
+		payload *rinq.Payload
+	)
+
+	BeforeEach(func() {
+		logger = &bufferLogger{isDebug: true}
+		payload = rinq.NewPayload("value")
+	})
+
+	AfterEach(func() {
+		payload.Close()
+	})
+
+	It("returns the logger unchanged if the options are the zero value", func() {
+		Expect(NewPayloadLogger(logger, options.PayloadLogOptions{}, nil)).To(BeIdenticalTo(logger))
+	})
+
+	It("replaces payloads with their size when payloads are omitted", func() {
+		l := NewPayloadLogger(logger, options.PayloadLogOptions{Omit: true}, nil)
+
+		l.Debug("call %s >>> %s", "<id>", payload)
+
+		Expect(logger.lines).To(Equal([]string{
+			fmt.Sprintf("call <id> >>> (%d bytes)", payload.Len()),
+		}))
+	})
+
+	It("truncates payloads that exceed the maximum length", func() {
+		l := NewPayloadLogger(logger, options.PayloadLogOptions{MaxLength: 4}, nil)
+
+		l.Log(">>> %s", payload)
+
+		Expect(logger.lines).To(Equal([]string{
+			`>>> "val... (3 bytes truncated)`,
+		}))
+	})
+
+	It("does not split multi-byte characters when truncating", func() {
+		payload = rinq.NewPayload("a€")
+		l := NewPayloadLogger(logger, options.PayloadLogOptions{MaxLength: 3}, nil)
+
+		l.Log(">>> %s", payload)
+
+		Expect(logger.lines).To(Equal([]string{
+			`>>> "a... (4 bytes truncated)`,
+		}))
+	})
+
+	It("does not truncate payloads within the maximum length", func() {
+		l := NewPayloadLogger(logger, options.PayloadLogOptions{MaxLength: 100}, nil)
+
+		l.Log(">>> %s", payload)
+
+		Expect(logger.lines).To(Equal([]string{
+			`>>> "value"`,
+		}))
+	})
+
+	Context("when a redactor is configured", func() {
+		redact := func(v interface{}) interface{} {
+			if m, ok := v.(map[interface{}]interface{}); ok {
+				m["token"] = "<redacted>"
+			}
+
+			return v
+		}
+
+		BeforeEach(func() {
+			payload = rinq.NewPayload(map[string]string{
+				"user":  "alice",
+				"token": "secret",
+			})
+		})
+
+		It("renders the redacted value", func() {
+			l := NewPayloadLogger(logger, options.PayloadLogOptions{}, redact)
+
+			l.Log(">>> %s", payload)
+
+			Expect(logger.lines).To(HaveLen(1))
+			Expect(logger.lines[0]).To(ContainSubstring("alice"))
+			Expect(logger.lines[0]).NotTo(ContainSubstring("secret"))
+		})
+
+		It("does not modify the payload", func() {
+			l := NewPayloadLogger(logger, options.PayloadLogOptions{}, redact)
+
+			l.Log(">>> %s", payload)
+
+			Expect(payload.String()).To(ContainSubstring("secret"))
+		})
+
+		It("truncates the redacted value", func() {
+			l := NewPayloadLogger(logger, options.PayloadLogOptions{MaxLength: 1}, redact)
+
+			l.Log(">>> %s", payload)
+
+			Expect(logger.lines).To(HaveLen(1))
+			Expect(logger.lines[0]).To(HavePrefix(">>> {... ("))
+		})
+	})
+
+	It("does not format debug messages if debug logging is disabled", func() {
+		logger.isDebug = false
+		l := NewPayloadLogger(logger, options.PayloadLogOptions{Omit: true}, nil)
+
+		l.Debug(">>> %s", payload)
+
+		Expect(logger.lines).To(BeEmpty())
+	})
+})
+
+// bufferLogger is a twelf.Logger that records each message.
+type bufferLogger struct {
+	isDebug bool
+	lines   []string
+}
+
+func (l *bufferLogger) Log(f string, v ...interface{}) {
+	l.LogString(fmt.Sprintf(f, v...))
+}
+
+func (l *bufferLogger) LogString(s string) {
+	l.lines = append(l.lines, s)
+}
+
+func (l *bufferLogger) Debug(f string, v ...interface{}) {
+	if l.isDebug {
+		l.Log(f, v...)
+	}
+}
+
+func (l *bufferLogger) DebugString(s string) {
+	if l.isDebug {
+		l.LogString(s)
+	}
+}
+
+func (l *bufferLogger) IsDebug() bool {
+	return l.isDebug
+}
@@ -0,0 +1,3 @@
+// Package logging provides loggers that control how the messages produced by
+// a peer are rendered.
+package logging
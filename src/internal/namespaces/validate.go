@@ -17,7 +17,7 @@ import (
 func Validate(ns string) error {
 	if ns == "" {
 		return errors.New("namespace must not be empty")
-	} else if ns[0] == '_' {
+	} else if IsReserved(ns) {
 		return fmt.Errorf("namespace '%s' is reserved", ns)
 	} else if !pattern.MatchString(ns) {
 		return fmt.Errorf("namespace '%s' contains invalid characters", ns)
@@ -26,6 +26,11 @@ func Validate(ns string) error {
 	return nil
 }
 
+// IsReserved returns true if ns is reserved for internal use.
+func IsReserved(ns string) bool {
+	return ns != "" && ns[0] == '_'
+}
+
 // MustValidate panics if ns is invalid.
 func MustValidate(ns string) {
 	if err := Validate(ns); err != nil {
@@ -44,3 +44,14 @@ var _ = DescribeTable(
 	},
 	entries...,
 )
+
+var _ = DescribeTable(
+	"IsReserved",
+	func(namespace string, expected bool) {
+		Expect(namespaces.IsReserved(namespace)).To(Equal(expected))
+	},
+	Entry("empty", "", false),
+	Entry("unreserved", "foo", false),
+	Entry("underscore", "_", true),
+	Entry("leading underscore", "_foo", true),
+)
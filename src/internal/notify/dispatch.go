@@ -0,0 +1,5 @@
+package notify
+
+// DispatchNamespace is the notification namespace used to carry the dispatch
+// reports requested by notifications sent with NotifyMulticastReport().
+const DispatchNamespace = "_dispatch"
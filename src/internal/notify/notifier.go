@@ -21,6 +21,30 @@ type Notifier interface {
 		out *rinq.Payload,
 	) error
 
+	// NotifyRequest sends a notification to a specific session, and indicates
+	// that the source session expects a reply.
+	NotifyRequest(
+		ctx context.Context,
+		msgID ident.MessageID,
+		traceID string,
+		s ident.SessionID,
+		ns string,
+		t string,
+		out *rinq.Payload,
+	) error
+
+	// NotifyReply sends a reply to the request notification identified by
+	// corrID to a specific session.
+	NotifyReply(
+		ctx context.Context,
+		msgID ident.MessageID,
+		traceID string,
+		s ident.SessionID,
+		ns string,
+		corrID ident.MessageID,
+		out *rinq.Payload,
+	) error
+
 	// NotifyMulticast sends a notification to all sessions matching a constraint.
 	NotifyMulticast(
 		ctx context.Context,
@@ -31,4 +55,22 @@ type Notifier interface {
 		t string,
 		out *rinq.Payload,
 	) error
+
+	// NotifyMulticastReport sends a notification to all sessions matching a
+	// constraint, and requests that each peer that receives it reports the
+	// number of sessions it was dispatched to, as a rinq.DispatchReport sent
+	// to the source session in the DispatchNamespace namespace.
+	NotifyMulticastReport(
+		ctx context.Context,
+		msgID ident.MessageID,
+		traceID string,
+		con constraint.Constraint,
+		ns string,
+		t string,
+		out *rinq.Payload,
+	) error
+
+	// Queued returns the number of notifications that have been sent but not
+	// yet published to the network.
+	Queued() int
 }
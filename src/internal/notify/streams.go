@@ -0,0 +1,14 @@
+package notify
+
+import (
+	"github.com/rinq/rinq-go/src/internal/service"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+// Streams consumes notifications that are retained in notification streams.
+type Streams interface {
+	service.Service
+
+	Listen(ns string, offset rinq.StreamOffset, h rinq.StreamHandler) (bool, error)
+	Unlisten(ns string) (bool, error)
+}
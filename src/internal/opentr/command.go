@@ -1,6 +1,9 @@
 package opentr
 
 import (
+	"fmt"
+	"time"
+
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
 	"github.com/opentracing/opentracing-go/log"
@@ -13,6 +16,7 @@ var (
 	invokerCallEvent      = log.String("event", "call")
 	invokerCallAsyncEvent = log.String("event", "call-async")
 	invokerExecuteEvent   = log.String("event", "execute")
+	invokerExecuteAtEvent = log.String("event", "execute-at")
 
 	invokerErrorSourceClient = log.String("error.source", "client")
 	invokerErrorSourceServer = log.String("error.source", "server")
@@ -21,6 +25,8 @@ var (
 
 	serverRequestEvent  = log.String("event", "request")
 	serverResponseEvent = log.String("event", "response")
+	serverPanicEvent    = log.String("event", "panic")
+	serverParkedEvent   = log.String("event", "parked")
 )
 
 // SetupCommand configures span as a command-related span.
@@ -92,6 +98,36 @@ func LogInvokerExecute(
 	s.LogFields(fields...)
 }
 
+// LogInvokerExecuteAt logs information about a scheduled "execute" style
+// command request to s.
+func LogInvokerExecuteAt(
+	s opentracing.Span,
+	attrs attributes.Catalog,
+	t time.Time,
+	p *rinq.Payload,
+) {
+	fields := []log.Field{
+		invokerExecuteAtEvent,
+		log.String("at", t.Format(time.RFC3339Nano)),
+		log.Int("size", p.Len()),
+	}
+
+	if !attrs.IsEmpty() {
+		fields = append(fields, lazyString("attributes", attrs.String))
+	}
+
+	s.LogFields(fields...)
+}
+
+// SetupCommandCancel configures span as a span that cancels the scheduled
+// command request identified by id.
+func SetupCommandCancel(s opentracing.Span, id ident.MessageID) {
+	s.SetOperationName("cancel command")
+
+	s.SetTag("subsystem", "command")
+	s.SetTag("message_id", id.String())
+}
+
 // LogInvokerSuccess logs information about a successful command response to s.
 func LogInvokerSuccess(s opentracing.Span, p *rinq.Payload) {
 	s.LogFields(
@@ -167,3 +203,24 @@ func LogServerError(s opentracing.Span, err error) {
 		)
 	}
 }
+
+// LogServerPanic logs information about a command handler that panicked while
+// handling a request to s.
+func LogServerPanic(s opentracing.Span, value interface{}, deliveries uint) {
+	ext.Error.Set(s, true)
+
+	s.LogFields(
+		serverPanicEvent,
+		log.String("message", fmt.Sprint(value)),
+		log.Int("deliveries", int(deliveries)),
+	)
+}
+
+// LogServerParked logs information about a request that was moved to the
+// parked request queue to s.
+func LogServerParked(s opentracing.Span, queue string) {
+	s.LogFields(
+		serverParkedEvent,
+		log.String("queue", queue),
+	)
+}
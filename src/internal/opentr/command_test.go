@@ -2,6 +2,7 @@ package opentr_test
 
 import (
 	"errors"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -41,11 +42,11 @@ var _ = Describe("LogInvokerCall", func() {
 		span := &mockSpan{}
 
 		attrs := attributes.Catalog{
-			"ns": {
-				"foo": attributes.VAttr{
+			"ns": attributes.NewVTable(
+				attributes.VAttr{
 					Attr: rinq.Freeze("foo", "bar"),
 				},
-			},
+			),
 		}
 
 		p := rinq.NewPayloadFromBytes(make([]byte, 4))
@@ -70,11 +71,11 @@ var _ = Describe("LogInvokerCallAsync", func() {
 		span := &mockSpan{}
 
 		attrs := attributes.Catalog{
-			"ns": {
-				"foo": attributes.VAttr{
+			"ns": attributes.NewVTable(
+				attributes.VAttr{
 					Attr: rinq.Freeze("foo", "bar"),
 				},
-			},
+			),
 		}
 
 		p := rinq.NewPayloadFromBytes(make([]byte, 4))
@@ -99,11 +100,11 @@ var _ = Describe("LogInvokerExecute", func() {
 		span := &mockSpan{}
 
 		attrs := attributes.Catalog{
-			"ns": {
-				"foo": attributes.VAttr{
+			"ns": attributes.NewVTable(
+				attributes.VAttr{
 					Attr: rinq.Freeze("foo", "bar"),
 				},
-			},
+			),
 		}
 
 		p := rinq.NewPayloadFromBytes(make([]byte, 4))
@@ -123,6 +124,61 @@ var _ = Describe("LogInvokerExecute", func() {
 	})
 })
 
+var _ = Describe("LogInvokerExecuteAt", func() {
+	It("logs the appropriate fields", func() {
+		span := &mockSpan{}
+
+		attrs := attributes.Catalog{
+			"ns": attributes.NewVTable(
+				attributes.VAttr{
+					Attr: rinq.Freeze("foo", "bar"),
+				},
+			),
+		}
+
+		t := time.Date(2017, 10, 11, 12, 13, 14, 0, time.UTC)
+
+		p := rinq.NewPayloadFromBytes(make([]byte, 4))
+		defer p.Close()
+
+		LogInvokerExecuteAt(span, attrs, t, p)
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event":      "execute-at",
+					"at":         "2017-10-11T12:13:14Z",
+					"attributes": "ns::{foo@bar}",
+					"size":       4,
+				},
+			},
+		))
+	})
+})
+
+var _ = Describe("SetupCommandCancel", func() {
+	It("sets the operation name", func() {
+		span := &mockSpan{}
+
+		SetupCommandCancel(span, ident.MessageID{})
+
+		Expect(span.operationName).To(Equal("cancel command"))
+	})
+
+	It("sets the appropriate tags", func() {
+		span := &mockSpan{}
+
+		msgID := ident.NewPeerID().Session(1).At(0).Message(27)
+
+		SetupCommandCancel(span, msgID)
+
+		Expect(span.tags).To(Equal(map[string]interface{}{
+			"subsystem":  "command",
+			"message_id": msgID.String(),
+		}))
+	})
+})
+
 var _ = Describe("LogInvokerSuccess", func() {
 	It("logs the appropriate fields", func() {
 		span := &mockSpan{}
@@ -329,3 +385,43 @@ var _ = Describe("LogServerError", func() {
 		})
 	})
 })
+
+var _ = Describe("LogServerPanic", func() {
+	It("logs the appropriate fields", func() {
+		span := &mockSpan{}
+		LogServerPanic(span, "<value>", 2)
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event":      "panic",
+					"message":    "<value>",
+					"deliveries": 2,
+				},
+			},
+		))
+	})
+
+	It("sets the error tag", func() {
+		span := &mockSpan{}
+		LogServerPanic(span, "<value>", 1)
+
+		Expect(span.tags["error"]).To(BeTrue())
+	})
+})
+
+var _ = Describe("LogServerParked", func() {
+	It("logs the appropriate fields", func() {
+		span := &mockSpan{}
+		LogServerParked(span, "<queue>")
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event": "parked",
+					"queue": "<queue>",
+				},
+			},
+		))
+	})
+})
@@ -1,6 +1,8 @@
 package opentr
 
 import (
+	"fmt"
+
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
 	"github.com/opentracing/opentracing-go/log"
@@ -14,9 +16,10 @@ var (
 	notifierUnicastEvent   = log.String("event", "notify")
 	notifierMulticastEvent = log.String("event", "notify-many")
 	listenerReceiveEvent   = log.String("event", "notification")
+	listenerPanicEvent     = log.String("event", "panic")
 )
 
-// SetupNotification configures span as a command-related span.
+// SetupNotification configures span as a notification-related span.
 func SetupNotification(
 	s opentracing.Span,
 	id ident.MessageID,
@@ -51,7 +54,7 @@ func LogNotifierUnicast(
 	s.LogFields(fields...)
 }
 
-// LogNotifierMulticast logs informatin about a multicast notification to s.
+// LogNotifierMulticast logs information about a multicast notification to s.
 func LogNotifierMulticast(
 	s opentracing.Span,
 	attrs attributes.Catalog,
@@ -99,3 +102,14 @@ func LogListenerReceived(s opentracing.Span, ref ident.Ref, n rinq.Notification)
 
 	s.LogFields(fields...)
 }
+
+// LogListenerPanic logs information about a notification handler that panicked
+// while handling a notification to s.
+func LogListenerPanic(s opentracing.Span, value interface{}) {
+	ext.Error.Set(s, true)
+
+	s.LogFields(
+		listenerPanicEvent,
+		log.String("message", fmt.Sprint(value)),
+	)
+}
@@ -0,0 +1,199 @@
+package opentr_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/internal/attributes"
+	. "github.com/rinq/rinq-go/src/internal/opentr"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/constraint"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+var _ = Describe("SetupNotification", func() {
+	It("sets the operation name", func() {
+		span := &mockSpan{}
+
+		SetupNotification(span, ident.MessageID{}, "<ns>", "<type>")
+
+		Expect(span.operationName).To(Equal("<ns>::<type> notification"))
+	})
+
+	It("sets the appropriate tags", func() {
+		span := &mockSpan{}
+
+		msgID := ident.NewPeerID().Session(1).At(0).Message(27)
+
+		SetupNotification(span, msgID, "<ns>", "<type>")
+
+		Expect(span.tags).To(Equal(map[string]interface{}{
+			"subsystem":  "notify",
+			"message_id": msgID.String(),
+			"namespace":  "<ns>",
+			"type":       "<type>",
+		}))
+	})
+})
+
+var _ = Describe("LogNotifierUnicast", func() {
+	It("logs the appropriate fields", func() {
+		span := &mockSpan{}
+
+		attrs := attributes.Catalog{
+			"ns": attributes.NewVTable(
+				attributes.VAttr{
+					Attr: rinq.Freeze("foo", "bar"),
+				},
+			),
+		}
+
+		target := ident.NewPeerID().Session(1)
+
+		p := rinq.NewPayloadFromBytes(make([]byte, 4))
+		defer p.Close()
+
+		LogNotifierUnicast(span, attrs, target, p)
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event":      "notify",
+					"target":     target.String(),
+					"attributes": "ns::{foo@bar}",
+					"size":       4,
+				},
+			},
+		))
+	})
+})
+
+var _ = Describe("LogNotifierMulticast", func() {
+	It("logs the appropriate fields", func() {
+		span := &mockSpan{}
+
+		attrs := attributes.Catalog{
+			"ns": attributes.NewVTable(
+				attributes.VAttr{
+					Attr: rinq.Freeze("foo", "bar"),
+				},
+			),
+		}
+
+		con := constraint.Equal("a", "1")
+
+		p := rinq.NewPayloadFromBytes(make([]byte, 4))
+		defer p.Close()
+
+		LogNotifierMulticast(span, attrs, con, p)
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event":      "notify-many",
+					"constraint": con.String(),
+					"attributes": "ns::{foo@bar}",
+					"size":       4,
+				},
+			},
+		))
+	})
+})
+
+var _ = Describe("LogNotifierError", func() {
+	It("logs the appropriate fields", func() {
+		span := &mockSpan{}
+		LogNotifierError(span, errors.New("<error>"))
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event":   "error",
+					"message": "<error>",
+				},
+			},
+		))
+	})
+
+	It("sets the error tag", func() {
+		span := &mockSpan{}
+		LogNotifierError(span, errors.New("<error>"))
+
+		Expect(span.tags["error"]).To(BeTrue())
+	})
+})
+
+var _ = Describe("LogListenerReceived", func() {
+	ref := ident.NewPeerID().Session(1).At(2)
+
+	It("logs the appropriate fields for unicast notifications", func() {
+		span := &mockSpan{}
+
+		n := rinq.Notification{
+			Payload: rinq.NewPayloadFromBytes(make([]byte, 4)),
+		}
+		defer n.Payload.Close()
+
+		LogListenerReceived(span, ref, n)
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event":     "notification",
+					"recipient": ref.String(),
+					"multicast": false,
+					"size":      4,
+				},
+			},
+		))
+	})
+
+	It("logs the appropriate fields for multicast notifications", func() {
+		span := &mockSpan{}
+
+		n := rinq.Notification{
+			IsMulticast: true,
+			Constraint:  constraint.Equal("a", "1"),
+			Payload:     rinq.NewPayloadFromBytes(make([]byte, 4)),
+		}
+		defer n.Payload.Close()
+
+		LogListenerReceived(span, ref, n)
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event":      "notification",
+					"recipient":  ref.String(),
+					"multicast":  true,
+					"size":       4,
+					"constraint": n.Constraint.String(),
+				},
+			},
+		))
+	})
+})
+
+var _ = Describe("LogListenerPanic", func() {
+	It("logs the appropriate fields", func() {
+		span := &mockSpan{}
+		LogListenerPanic(span, "<value>")
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event":   "panic",
+					"message": "<value>",
+				},
+			},
+		))
+	})
+
+	It("sets the error tag", func() {
+		span := &mockSpan{}
+		LogListenerPanic(span, "<value>")
+
+		Expect(span.tags["error"]).To(BeTrue())
+	})
+})
@@ -13,15 +13,21 @@ import (
 
 const (
 	fetchOp   = "session fetch"
+	fetchNOp  = "session fetch many"
 	updateOp  = "session update"
 	clearOp   = "session clear"
+	freezeOp  = "session freeze"
 	destroyOp = "session destroy"
+	awaitOp   = "session await destroy"
+	nsOp      = "session namespaces"
+	diffOp    = "session diff"
 )
 
 var (
 	fetchEvent   = log.String("event", "fetch")
 	updateEvent  = log.String("event", "update")
 	clearEvent   = log.String("event", "clear")
+	freezeEvent  = log.String("event", "freeze")
 	destroyEvent = log.String("event", "destroy")
 )
 
@@ -38,6 +44,16 @@ func SetupSessionFetch(s opentracing.Span, ns string, sessID ident.SessionID) {
 	s.SetTag("namespace", ns)
 }
 
+// SetupSessionFetchMany configures s as an operation that fetches the state
+// of several sessions owned by the same peer.
+func SetupSessionFetchMany(s opentracing.Span, peerID ident.PeerID, count int) {
+	s.SetOperationName(fetchNOp)
+
+	s.SetTag("subsystem", "session")
+	s.SetTag("peer", peerID.String())
+	s.SetTag("sessions", count)
+}
+
 // LogSessionFetchRequest logs information about a session fetch attempt to s.
 func LogSessionFetchRequest(s opentracing.Span, keys []string) {
 	fields := []log.Field{
@@ -101,6 +117,46 @@ func LogSessionUpdateSuccess(s opentracing.Span, rev ident.Revision, diff *attri
 	s.LogFields(fields...)
 }
 
+// SetupSessionUpdateMany configures s as a multi-namespace attribute update
+// operation.
+func SetupSessionUpdateMany(s opentracing.Span, sessID ident.SessionID) {
+	setupSessionCommand(s, updateOp, sessID)
+}
+
+// LogSessionUpdateManyRequest logs information about a multi-namespace session
+// update attempt to s.
+func LogSessionUpdateManyRequest(s opentracing.Span, rev ident.Revision, attrs map[string]attributes.List) {
+	fields := []log.Field{
+		updateEvent,
+		log.Uint32("rev", uint32(rev)),
+	}
+
+	for ns, l := range attrs {
+		if !l.IsEmpty() {
+			fields = append(fields, lazyString("changes."+ns, l.String))
+		}
+	}
+
+	s.LogFields(fields...)
+}
+
+// LogSessionUpdateManySuccess logs information about a successful
+// multi-namespace session update to s.
+func LogSessionUpdateManySuccess(s opentracing.Span, rev ident.Revision, diffs []*attributes.Diff) {
+	fields := []log.Field{
+		successEvent,
+		log.Uint32("rev", uint32(rev)),
+	}
+
+	for _, diff := range diffs {
+		if !diff.IsEmpty() {
+			fields = append(fields, lazyString("diff."+diff.Namespace, diff.StringWithoutNamespace))
+		}
+	}
+
+	s.LogFields(fields...)
+}
+
 // SetupSessionClear configures s as an attribute update operation.
 func SetupSessionClear(s opentracing.Span, ns string, sessID ident.SessionID) {
 	setupSessionCommand(s, clearOp, sessID)
@@ -108,12 +164,19 @@ func SetupSessionClear(s opentracing.Span, ns string, sessID ident.SessionID) {
 }
 
 // LogSessionClearRequest logs information about a session clear attempt to s.
-func LogSessionClearRequest(s opentracing.Span, rev ident.Revision) {
+// keys is empty if the entire namespace is being cleared.
+func LogSessionClearRequest(s opentracing.Span, rev ident.Revision, keys []string) {
 	fields := []log.Field{
 		clearEvent,
 		log.Uint32("rev", uint32(rev)),
 	}
 
+	if len(keys) != 0 {
+		fields = append(fields, lazyString("keys", func() string {
+			return "{" + strings.Join(keys, ", ") + "}"
+		}))
+	}
+
 	s.LogFields(fields...)
 }
 
@@ -132,6 +195,35 @@ func LogSessionClearSuccess(s opentracing.Span, rev ident.Revision, diff *attrib
 	s.LogFields(fields...)
 }
 
+// SetupSessionFreeze configures s as a namespace freeze operation.
+func SetupSessionFreeze(s opentracing.Span, ns string, sessID ident.SessionID) {
+	setupSessionCommand(s, freezeOp, sessID)
+	s.SetTag("namespace", ns)
+}
+
+// LogSessionFreezeRequest logs information about a namespace freeze attempt to s.
+func LogSessionFreezeRequest(s opentracing.Span, rev ident.Revision) {
+	s.LogFields(
+		freezeEvent,
+		log.Uint32("rev", uint32(rev)),
+	)
+}
+
+// LogSessionFreezeSuccess logs information about a successful namespace freeze
+// to s. diff is optional, as the information is not known on the remote end.
+func LogSessionFreezeSuccess(s opentracing.Span, rev ident.Revision, diff *attributes.Diff) {
+	fields := []log.Field{
+		successEvent,
+		log.Uint32("rev", uint32(rev)),
+	}
+
+	if diff != nil && !diff.IsEmpty() {
+		fields = append(fields, lazyString("diff", diff.StringWithoutNamespace))
+	}
+
+	s.LogFields(fields...)
+}
+
 // SetupSessionDestroy configures s as a destroy operation.
 func SetupSessionDestroy(s opentracing.Span, sessID ident.SessionID) {
 	setupSessionCommand(s, destroyOp, sessID)
@@ -152,6 +244,12 @@ func LogSessionDestroySuccess(s opentracing.Span) {
 	)
 }
 
+// SetupSessionAwaitDestroy configures s as an operation that waits for a
+// session to be destroyed.
+func SetupSessionAwaitDestroy(s opentracing.Span, sessID ident.SessionID) {
+	setupSessionCommand(s, awaitOp, sessID)
+}
+
 // LogSessionError logs information about an error during a session operation.
 func LogSessionError(s opentracing.Span, err error) {
 	switch e := err.(type) {
@@ -169,3 +267,16 @@ func LogSessionError(s opentracing.Span, err error) {
 		)
 	}
 }
+
+// SetupSessionNamespaces configures s as an operation that lists the
+// namespaces of a session.
+func SetupSessionNamespaces(s opentracing.Span, sessID ident.SessionID) {
+	setupSessionCommand(s, nsOp, sessID)
+}
+
+// SetupSessionDiff configures s as an operation that fetches the changes to the
+// attributes of a session since the given revision.
+func SetupSessionDiff(s opentracing.Span, sessID ident.SessionID, since ident.Revision) {
+	setupSessionCommand(s, diffOp, sessID)
+	s.SetTag("since", since)
+}
@@ -146,6 +146,102 @@ var _ = Describe("LogSessionUpdateSuccess", func() {
 	})
 })
 
+var _ = Describe("SetupSessionFetchMany", func() {
+	It("sets the operation name", func() {
+		span := &mockSpan{}
+
+		SetupSessionFetchMany(span, ident.PeerID{}, 2)
+
+		Expect(span.operationName).To(Equal("session fetch many"))
+	})
+
+	It("sets the appropriate tags", func() {
+		span := &mockSpan{}
+
+		peerID := ident.NewPeerID()
+
+		SetupSessionFetchMany(span, peerID, 2)
+
+		Expect(span.tags).To(Equal(map[string]interface{}{
+			"subsystem": "session",
+			"peer":      peerID.String(),
+			"sessions":  2,
+		}))
+	})
+})
+
+var _ = Describe("SetupSessionUpdateMany", func() {
+	It("sets the operation name", func() {
+		span := &mockSpan{}
+
+		SetupSessionUpdateMany(span, ident.SessionID{})
+
+		Expect(span.operationName).To(Equal("session update"))
+	})
+
+	It("sets the appropriate tags", func() {
+		span := &mockSpan{}
+
+		sessID := ident.NewPeerID().Session(1)
+
+		SetupSessionUpdateMany(span, sessID)
+
+		Expect(span.tags).To(Equal(map[string]interface{}{
+			"subsystem": "session",
+			"session":   sessID.String(),
+		}))
+	})
+})
+
+var _ = Describe("LogSessionUpdateManyRequest", func() {
+	It("logs the appropriate fields", func() {
+		span := &mockSpan{}
+
+		attrs := map[string]attributes.List{
+			"ns1": {rinq.Set("a", "1")},
+			"ns2": {rinq.Set("b", "2")},
+		}
+
+		LogSessionUpdateManyRequest(span, 23, attrs)
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event":       "update",
+					"rev":         uint32(23),
+					"changes.ns1": "{a=1}",
+					"changes.ns2": "{b=2}",
+				},
+			},
+		))
+	})
+})
+
+var _ = Describe("LogSessionUpdateManySuccess", func() {
+	It("logs the appropriate fields", func() {
+		span := &mockSpan{}
+
+		diff1 := attributes.NewDiff("ns1", 23)
+		diff1.Append(attributes.VAttr{Attr: rinq.Set("a", "1")})
+
+		diff2 := attributes.NewDiff("ns2", 23)
+		diff2.Append(attributes.VAttr{Attr: rinq.Set("b", "2")})
+
+		LogSessionUpdateManySuccess(span, 23, []*attributes.Diff{diff1, diff2})
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event":    "success",
+					"rev":      uint32(23),
+					"diff.ns1": diff1.StringWithoutNamespace(),
+					"diff.ns2": diff2.StringWithoutNamespace(),
+				},
+			},
+		))
+	})
+})
+
 var _ = Describe("SetupSessionClear", func() {
 	It("sets the operation name", func() {
 		span := &mockSpan{}
@@ -174,7 +270,7 @@ var _ = Describe("LogSessionClearRequest", func() {
 	It("logs the appropriate fields", func() {
 		span := &mockSpan{}
 
-		LogSessionClearRequest(span, 23)
+		LogSessionClearRequest(span, 23, nil)
 
 		Expect(span.log).To(Equal(
 			[]map[string]interface{}{
@@ -185,6 +281,22 @@ var _ = Describe("LogSessionClearRequest", func() {
 			},
 		))
 	})
+
+	It("logs the keys when present", func() {
+		span := &mockSpan{}
+
+		LogSessionClearRequest(span, 23, []string{"a", "b"})
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event": "clear",
+					"rev":   uint32(23),
+					"keys":  "{a, b}",
+				},
+			},
+		))
+	})
 })
 
 var _ = Describe("LogSessionClearSuccess", func() {
@@ -226,6 +338,85 @@ var _ = Describe("LogSessionClearSuccess", func() {
 	})
 })
 
+var _ = Describe("SetupSessionFreeze", func() {
+	It("sets the operation name", func() {
+		span := &mockSpan{}
+
+		SetupSessionFreeze(span, "<ns>", ident.SessionID{})
+
+		Expect(span.operationName).To(Equal("session freeze"))
+	})
+
+	It("sets the appropriate tags", func() {
+		span := &mockSpan{}
+
+		sessID := ident.NewPeerID().Session(1)
+
+		SetupSessionFreeze(span, "<ns>", sessID)
+
+		Expect(span.tags).To(Equal(map[string]interface{}{
+			"subsystem": "session",
+			"session":   sessID.String(),
+			"namespace": "<ns>",
+		}))
+	})
+})
+
+var _ = Describe("LogSessionFreezeRequest", func() {
+	It("logs the appropriate fields", func() {
+		span := &mockSpan{}
+
+		LogSessionFreezeRequest(span, 23)
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event": "freeze",
+					"rev":   uint32(23),
+				},
+			},
+		))
+	})
+})
+
+var _ = Describe("LogSessionFreezeSuccess", func() {
+	It("logs the appropriate fields", func() {
+		span := &mockSpan{}
+
+		diff := attributes.NewDiff("ns", 23)
+		diff.Append(
+			attributes.VAttr{Attr: rinq.Freeze("a", "1"), UpdatedAt: 23},
+		)
+
+		LogSessionFreezeSuccess(span, 23, diff)
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event": "success",
+					"rev":   uint32(23),
+					"diff":  diff.StringWithoutNamespace(),
+				},
+			},
+		))
+	})
+
+	It("allows a nil diff", func() {
+		span := &mockSpan{}
+
+		LogSessionFreezeSuccess(span, 23, nil)
+
+		Expect(span.log).To(Equal(
+			[]map[string]interface{}{
+				{
+					"event": "success",
+					"rev":   uint32(23),
+				},
+			},
+		))
+	})
+})
+
 var _ = Describe("SetupSessionDestroy", func() {
 	It("sets the operation name", func() {
 		span := &mockSpan{}
@@ -282,6 +473,29 @@ var _ = Describe("LogSessionDestroySuccess", func() {
 	})
 })
 
+var _ = Describe("SetupSessionAwaitDestroy", func() {
+	It("sets the operation name", func() {
+		span := &mockSpan{}
+
+		SetupSessionAwaitDestroy(span, ident.SessionID{})
+
+		Expect(span.operationName).To(Equal("session await destroy"))
+	})
+
+	It("sets the appropriate tags", func() {
+		span := &mockSpan{}
+
+		sessID := ident.NewPeerID().Session(1)
+
+		SetupSessionAwaitDestroy(span, sessID)
+
+		Expect(span.tags).To(Equal(map[string]interface{}{
+			"subsystem": "session",
+			"session":   sessID.String(),
+		}))
+	})
+})
+
 var _ = Describe("LogSessionError", func() {
 	Context("when the error is a failure", func() {
 		err := rinq.Failure{
@@ -46,21 +46,58 @@ func (c *client) Fetch(
 	ident.Revision,
 	attributes.VList,
 	error,
+) {
+	return c.fetch(
+		ctx,
+		sessID,
+		fetchRequest{
+			Seq:       sessID.Seq,
+			Namespace: ns,
+			Keys:      keys,
+		},
+	)
+}
+
+// FetchAll fetches every attribute in the ns namespace.
+func (c *client) FetchAll(
+	ctx context.Context,
+	sessID ident.SessionID,
+	ns string,
+) (
+	ident.Revision,
+	attributes.VList,
+	error,
+) {
+	return c.fetch(
+		ctx,
+		sessID,
+		fetchRequest{
+			Seq:       sessID.Seq,
+			Namespace: ns,
+			All:       true,
+		},
+	)
+}
+
+func (c *client) fetch(
+	ctx context.Context,
+	sessID ident.SessionID,
+	req fetchRequest,
+) (
+	ident.Revision,
+	attributes.VList,
+	error,
 ) {
 	msgID, traceID := c.nextMessageID(ctx)
 
 	span, ctx := opentr.ChildOf(ctx, c.tracer, ext.SpanKindRPCClient)
 	defer span.Finish()
 
-	opentr.SetupSessionFetch(span, ns, sessID)
+	opentr.SetupSessionFetch(span, req.Namespace, sessID)
 	opentr.AddTraceID(span, traceID)
-	opentr.LogSessionFetchRequest(span, keys)
+	opentr.LogSessionFetchRequest(span, req.Keys)
 
-	out := rinq.NewPayload(fetchRequest{
-		Seq:       sessID.Seq,
-		Namespace: ns,
-		Keys:      keys,
-	})
+	out := rinq.NewPayload(req)
 	defer out.Close()
 
 	in, err := c.invoker.CallUnicast(
@@ -93,6 +130,63 @@ func (c *client) Fetch(
 	return rsp.Rev, rsp.Attrs, nil
 }
 
+// FetchMany fetches the head revision of each of the sessions owned by peerID
+// with the given sequence numbers, along with the prefetch attributes of each
+// session. Sessions that do not exist are omitted from the result.
+func (c *client) FetchMany(
+	ctx context.Context,
+	peerID ident.PeerID,
+	seqs []uint32,
+	prefetch map[string][]string,
+) (
+	map[uint32]fetchManyResult,
+	error,
+) {
+	msgID, traceID := c.nextMessageID(ctx)
+
+	span, ctx := opentr.ChildOf(ctx, c.tracer, ext.SpanKindRPCClient)
+	defer span.Finish()
+
+	opentr.SetupSessionFetchMany(span, peerID, len(seqs))
+	opentr.AddTraceID(span, traceID)
+
+	out := rinq.NewPayload(fetchManyRequest{
+		Seqs:     seqs,
+		Prefetch: prefetch,
+	})
+	defer out.Close()
+
+	in, err := c.invoker.CallUnicast(
+		ctx,
+		msgID,
+		traceID,
+		peerID,
+		sessionNamespace,
+		fetchManyCommand,
+		out,
+	)
+	defer in.Close()
+
+	if err != nil {
+		opentr.LogSessionError(span, err)
+		return nil, err
+	}
+
+	var rsp fetchManyResponse
+	if err = in.Decode(&rsp); err != nil {
+		opentr.LogSessionError(span, err)
+		return nil, err
+	}
+
+	return rsp.Sessions, nil
+}
+
+// SupportsFetchMany returns true if peerID is known to support FetchMany().
+func (c *client) SupportsFetchMany(peerID ident.PeerID) bool {
+	caps, ok := c.invoker.Capabilities(peerID)
+	return ok && caps.Supports(rinq.FeatureBatchFetch)
+}
+
 func (c *client) Update(
 	ctx context.Context,
 	ref ident.Ref,
@@ -163,10 +257,89 @@ func (c *client) Update(
 	return rsp.Rev, diff.VList, nil
 }
 
+func (c *client) UpdateMany(
+	ctx context.Context,
+	ref ident.Ref,
+	attrs map[string]attributes.List,
+) (
+	ident.Revision,
+	map[string]attributes.VList,
+	error,
+) {
+	msgID, traceID := c.nextMessageID(ctx)
+
+	span, ctx := opentr.ChildOf(ctx, c.tracer, ext.SpanKindRPCClient)
+	defer span.Finish()
+
+	opentr.SetupSessionUpdateMany(span, ref.ID)
+	opentr.AddTraceID(span, traceID)
+	opentr.LogSessionUpdateManyRequest(span, ref.Rev, attrs)
+
+	out := rinq.NewPayload(updateManyRequest{
+		Seq:   ref.ID.Seq,
+		Rev:   ref.Rev,
+		Attrs: attrs,
+	})
+	defer out.Close()
+
+	in, err := c.invoker.CallUnicast(
+		ctx,
+		msgID,
+		traceID,
+		ref.ID.Peer,
+		sessionNamespace,
+		updateManyCommand,
+		out,
+	)
+	defer in.Close()
+
+	if err != nil {
+		opentr.LogSessionError(span, err)
+		return 0, nil, failureToError(ref, err)
+	}
+
+	var rsp updateManyResponse
+	err = in.Decode(&rsp)
+
+	if err != nil {
+		opentr.LogSessionError(span, err)
+
+		return 0, nil, err
+	}
+
+	result := make(map[string]attributes.VList, len(attrs))
+	diffs := make([]*attributes.Diff, 0, len(attrs))
+
+	for ns, l := range attrs {
+		diff := attributes.NewDiff(ns, rsp.Rev)
+		createdRevs := rsp.CreatedRevs[ns]
+
+		for index, attr := range l {
+			diff.Append(
+				attributes.VAttr{
+					Attr:      attr,
+					CreatedAt: createdRevs[index],
+					UpdatedAt: rsp.Rev,
+				},
+			)
+		}
+
+		logUpdate(ctx, c.logger, c.peerID, ref.ID.At(rsp.Rev), diff)
+
+		result[ns] = diff.VList
+		diffs = append(diffs, diff)
+	}
+
+	opentr.LogSessionUpdateManySuccess(span, rsp.Rev, diffs)
+
+	return rsp.Rev, result, nil
+}
+
 func (c *client) Clear(
 	ctx context.Context,
 	ref ident.Ref,
 	ns string,
+	keys []string,
 ) (
 	ident.Revision,
 	error,
@@ -178,22 +351,30 @@ func (c *client) Clear(
 
 	opentr.SetupSessionClear(span, ns, ref.ID)
 	opentr.AddTraceID(span, traceID)
-	opentr.LogSessionClearRequest(span, ref.Rev)
+	opentr.LogSessionClearRequest(span, ref.Rev, keys)
 
-	out := rinq.NewPayload(updateRequest{
+	out := rinq.NewPayload(clearRequest{
 		Seq:       ref.ID.Seq,
 		Rev:       ref.Rev,
 		Namespace: ns,
+		Keys:      keys,
 	})
 	defer out.Close()
 
+	// Clearing specific keys uses a distinct command so that peers that do not
+	// support it reject the request rather than clearing the entire namespace.
+	cmd := clearCommand
+	if len(keys) != 0 {
+		cmd = clearKeysCommand
+	}
+
 	in, err := c.invoker.CallUnicast(
 		ctx,
 		msgID,
 		traceID,
 		ref.ID.Peer,
 		sessionNamespace,
-		clearCommand,
+		cmd,
 		out,
 	)
 	defer in.Close()
@@ -212,12 +393,67 @@ func (c *client) Clear(
 		return 0, err
 	}
 
-	logClear(ctx, c.logger, c.peerID, ref.ID.At(rsp.Rev), ns)
+	logClear(ctx, c.logger, c.peerID, ref.ID.At(rsp.Rev), ns, keys)
 	opentr.LogSessionClearSuccess(span, rsp.Rev, nil)
 
 	return rsp.Rev, nil
 }
 
+func (c *client) FreezeNamespace(
+	ctx context.Context,
+	ref ident.Ref,
+	ns string,
+) (
+	ident.Revision,
+	error,
+) {
+	msgID, traceID := c.nextMessageID(ctx)
+
+	span, ctx := opentr.ChildOf(ctx, c.tracer, ext.SpanKindRPCClient)
+	defer span.Finish()
+
+	opentr.SetupSessionFreeze(span, ns, ref.ID)
+	opentr.AddTraceID(span, traceID)
+	opentr.LogSessionFreezeRequest(span, ref.Rev)
+
+	out := rinq.NewPayload(freezeRequest{
+		Seq:       ref.ID.Seq,
+		Rev:       ref.Rev,
+		Namespace: ns,
+	})
+	defer out.Close()
+
+	in, err := c.invoker.CallUnicast(
+		ctx,
+		msgID,
+		traceID,
+		ref.ID.Peer,
+		sessionNamespace,
+		freezeCommand,
+		out,
+	)
+	defer in.Close()
+
+	if err != nil {
+		opentr.LogSessionError(span, err)
+		return 0, failureToError(ref, err)
+	}
+
+	var rsp updateResponse
+	err = in.Decode(&rsp)
+
+	if err != nil {
+		opentr.LogSessionError(span, err)
+
+		return 0, err
+	}
+
+	logFreeze(ctx, c.logger, c.peerID, ref.ID.At(rsp.Rev), ns)
+	opentr.LogSessionFreezeSuccess(span, rsp.Rev, nil)
+
+	return rsp.Rev, nil
+}
+
 func (c *client) Destroy(
 	ctx context.Context,
 	ref ident.Ref,
@@ -259,6 +495,45 @@ func (c *client) Destroy(
 	return nil
 }
 
+// Watch asks the owning peer to announce the destruction of the session with
+// the given ID to this peer. It returns as soon as the owning peer has
+// registered the request.
+func (c *client) Watch(
+	ctx context.Context,
+	id ident.SessionID,
+) error {
+	msgID, traceID := c.nextMessageID(ctx)
+
+	span, ctx := opentr.ChildOf(ctx, c.tracer, ext.SpanKindRPCClient)
+	defer span.Finish()
+
+	opentr.SetupSessionAwaitDestroy(span, id)
+	opentr.AddTraceID(span, traceID)
+
+	out := rinq.NewPayload(watchRequest{
+		Seq: id.Seq,
+	})
+	defer out.Close()
+
+	in, err := c.invoker.CallUnicast(
+		ctx,
+		msgID,
+		traceID,
+		id.Peer,
+		sessionNamespace,
+		watchCommand,
+		out,
+	)
+	defer in.Close()
+
+	if err != nil {
+		opentr.LogSessionError(span, err)
+		return failureToError(id.At(0), err)
+	}
+
+	return nil
+}
+
 func (c *client) nextMessageID(ctx context.Context) (msgID ident.MessageID, traceID string) {
 	seq := atomic.AddUint32(&c.seq, 1)
 	msgID = c.peerID.Session(0).At(0).Message(seq)
@@ -270,3 +545,102 @@ func (c *client) nextMessageID(ctx context.Context) (msgID ident.MessageID, trac
 
 	return
 }
+
+func (c *client) Namespaces(
+	ctx context.Context,
+	id ident.SessionID,
+) (
+	ident.Revision,
+	map[string]ident.Revision,
+	error,
+) {
+	msgID, traceID := c.nextMessageID(ctx)
+
+	span, ctx := opentr.ChildOf(ctx, c.tracer, ext.SpanKindRPCClient)
+	defer span.Finish()
+
+	opentr.SetupSessionNamespaces(span, id)
+	opentr.AddTraceID(span, traceID)
+
+	out := rinq.NewPayload(namespacesRequest{
+		Seq: id.Seq,
+	})
+	defer out.Close()
+
+	in, err := c.invoker.CallUnicast(
+		ctx,
+		msgID,
+		traceID,
+		id.Peer,
+		sessionNamespace,
+		namespacesCommand,
+		out,
+	)
+	defer in.Close()
+
+	if err != nil {
+		opentr.LogSessionError(span, err)
+		return 0, nil, failureToError(id.At(0), err)
+	}
+
+	var rsp namespacesResponse
+	if err = in.Decode(&rsp); err != nil {
+		opentr.LogSessionError(span, err)
+		return 0, nil, err
+	}
+
+	return rsp.Rev, rsp.Namespaces, nil
+}
+
+func (c *client) Diff(
+	ctx context.Context,
+	id ident.SessionID,
+	since ident.Revision,
+) (
+	ident.Revision,
+	attributes.Catalog,
+	error,
+) {
+	msgID, traceID := c.nextMessageID(ctx)
+
+	span, ctx := opentr.ChildOf(ctx, c.tracer, ext.SpanKindRPCClient)
+	defer span.Finish()
+
+	opentr.SetupSessionDiff(span, id, since)
+	opentr.AddTraceID(span, traceID)
+
+	out := rinq.NewPayload(diffRequest{
+		Seq:   id.Seq,
+		Since: since,
+	})
+	defer out.Close()
+
+	in, err := c.invoker.CallUnicast(
+		ctx,
+		msgID,
+		traceID,
+		id.Peer,
+		sessionNamespace,
+		diffCommand,
+		out,
+	)
+	defer in.Close()
+
+	if err != nil {
+		opentr.LogSessionError(span, err)
+		return 0, nil, failureToError(id.At(since), err)
+	}
+
+	var rsp diffResponse
+	if err = in.Decode(&rsp); err != nil {
+		opentr.LogSessionError(span, err)
+		return 0, nil, err
+	}
+
+	cat := attributes.Catalog{}
+	for ns, l := range rsp.Attrs {
+		cat[ns] = attributes.NewVTable(l...)
+	}
+
+	return rsp.Rev, cat, nil
+}
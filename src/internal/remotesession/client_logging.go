@@ -2,6 +2,7 @@ package remotesession
 
 import (
 	"context"
+	"strings"
 
 	"github.com/jmalloc/twelf/src/twelf"
 	"github.com/rinq/rinq-go/src/internal/attributes"
@@ -31,13 +32,36 @@ func logClear(
 	peerID ident.PeerID,
 	ref ident.Ref,
 	ns string,
+	keys []string,
 ) {
+	k := "*"
+	if len(keys) != 0 {
+		k = strings.Join(keys, ", ")
+	}
+
 	logger.Log(
-		"%s cleared remote session %s %s::{*} [%s]",
+		"%s cleared remote session %s %s::{%s} [%s]",
 		peerID.ShortString(),
 		ref.ShortString(),
+		ns,
+		k,
 		trace.Get(ctx),
+	)
+}
+
+func logFreeze(
+	ctx context.Context,
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	ref ident.Ref,
+	ns string,
+) {
+	logger.Log(
+		"%s froze remote session namespace %s %s [%s]",
+		peerID.ShortString(),
+		ref.ShortString(),
 		ns,
+		trace.Get(ctx),
 	)
 }
 
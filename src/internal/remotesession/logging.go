@@ -40,3 +40,15 @@ func logCacheRemove(
 		sessID.ShortString(),
 	)
 }
+
+func logCacheOrphaned(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	sessID ident.SessionID,
+) {
+	logger.Debug(
+		"%s closed remote session %s because its owning peer has stopped",
+		peerID.ShortString(),
+		sessID.ShortString(),
+	)
+}
@@ -19,6 +19,10 @@ func (r *revision) SessionID() ident.SessionID {
 	return r.ref.ID
 }
 
+func (r *revision) Ref() ident.Ref {
+	return r.ref
+}
+
 func (r *revision) Refresh(ctx context.Context) (rinq.Revision, error) {
 	rev, err := r.session.Head(ctx)
 
@@ -71,6 +75,46 @@ func (r *revision) GetMany(ctx context.Context, ns string, keys ...string) (rinq
 	return table, nil
 }
 
+func (r *revision) GetAll(ctx context.Context, ns string) (rinq.AttrTable, error) {
+	namespaces.MustValidate(ns)
+
+	table := attributes.Table{}
+
+	if r.ref.Rev == 0 {
+		return table, nil
+	}
+
+	attrs, err := r.session.FetchAll(ctx, r.ref.Rev, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, attr := range attrs {
+		if attr.Value != "" || attr.IsFrozen {
+			table[attr.Key] = attr
+		}
+	}
+
+	return table, nil
+}
+
+func (r *revision) Namespaces(ctx context.Context) ([]string, error) {
+	if r.ref.Rev == 0 {
+		return []string{}, nil
+	}
+
+	return r.session.Namespaces(ctx, r.ref.Rev)
+}
+
+func (r *revision) Diff(ctx context.Context, since ident.Revision) (rinq.Diff, error) {
+	if r.ref.Rev == 0 {
+		revisions.MustValidateSince(r.ref, since)
+		return rinq.Diff{From: r.ref, To: r.ref}, nil
+	}
+
+	return r.session.Diff(ctx, r.ref.Rev, since)
+}
+
 func (r *revision) Update(ctx context.Context, ns string, attrs ...rinq.Attr) (rinq.Revision, error) {
 	namespaces.MustValidate(ns)
 
@@ -82,10 +126,44 @@ func (r *revision) Update(ctx context.Context, ns string, attrs ...rinq.Attr) (r
 	return rev, nil
 }
 
-func (r *revision) Clear(ctx context.Context, ns string) (rinq.Revision, error) {
+func (r *revision) UpdateMany(ctx context.Context, attrs map[string][]rinq.Attr) (rinq.Revision, error) {
+	changes := map[string]attributes.List{}
+
+	for ns, a := range attrs {
+		namespaces.MustValidate(ns)
+
+		if len(a) != 0 {
+			changes[ns] = a
+		}
+	}
+
+	if len(changes) == 0 {
+		return r, nil
+	}
+
+	rev, err := r.session.TryUpdateMany(ctx, r.ref.Rev, changes)
+	if err != nil {
+		return r, err
+	}
+
+	return rev, nil
+}
+
+func (r *revision) Clear(ctx context.Context, ns string, keys ...string) (rinq.Revision, error) {
 	namespaces.MustValidate(ns)
 
-	rev, err := r.session.TryClear(ctx, r.ref.Rev, ns)
+	rev, err := r.session.TryClear(ctx, r.ref.Rev, ns, keys)
+	if err != nil {
+		return r, err
+	}
+
+	return rev, nil
+}
+
+func (r *revision) FreezeNamespace(ctx context.Context, ns string) (rinq.Revision, error) {
+	namespaces.MustValidate(ns)
+
+	rev, err := r.session.TryFreezeNamespace(ctx, r.ref.Rev, ns)
 	if err != nil {
 		return r, err
 	}
@@ -96,3 +174,27 @@ func (r *revision) Clear(ctx context.Context, ns string) (rinq.Revision, error)
 func (r *revision) Destroy(ctx context.Context) error {
 	return r.session.TryDestroy(ctx, r.ref.Rev)
 }
+
+func (r *revision) AwaitDestroy(ctx context.Context) error {
+	return r.session.AwaitDestroy(ctx)
+}
+
+func (r *revision) Await(ctx context.Context, ns, key string, fn func(rinq.Attr) bool) (rinq.Revision, error) {
+	namespaces.MustValidate(ns)
+
+	attr, err := r.Get(ctx, ns, key)
+	if err == nil {
+		if fn(attr) {
+			return r, nil
+		}
+	} else if !rinq.ShouldRetry(err) {
+		return r, err
+	}
+
+	rev, err := r.session.Await(ctx, r.ref.Rev, ns, key, fn)
+	if err != nil {
+		return r, err
+	}
+
+	return rev, nil
+}
@@ -4,12 +4,14 @@ package remotesession_test
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/rinq/rinq-go/src/internal/attributes"
 	"github.com/rinq/rinq-go/src/internal/functest"
 	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/options"
 )
 
 var _ = Describe("revision (functional)", func() {
@@ -25,7 +27,7 @@ var _ = Describe("revision (functional)", func() {
 		ctx = context.Background()
 		ns = functest.NewNamespace()
 		client = functest.NewPeer()
-		session = client.Session()
+		session = functest.Session(client)
 		server = functest.NewPeer()
 
 		functest.Must(server.Listen(ns, func(ctx context.Context, req rinq.Request, res rinq.Response) {
@@ -275,6 +277,151 @@ var _ = Describe("revision (functional)", func() {
 		})
 	})
 
+	Describe("GetAll", func() {
+		It("returns an empty attribute table at revision zero", func() {
+			attrs, err := remote.GetAll(ctx, ns)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attrs.IsEmpty()).To(BeTrue())
+		})
+
+		It("returns all non-empty attributes in the namespace", func() {
+			var err error
+			local, err = local.Update(
+				ctx,
+				ns,
+				rinq.Set("a", "1"),
+				rinq.Freeze("b", "2"),
+				rinq.Set("c", ""),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			remote, err = remote.Refresh(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			attrs, err := remote.GetAll(ctx, ns)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attributes.ToMap(attrs)).To(Equal(
+				map[string]rinq.Attr{
+					"a": rinq.Set("a", "1"),
+					"b": rinq.Freeze("b", "2"),
+				},
+			))
+		})
+
+		It("returns a stale fetch error if an attribute has been updated in a later revision", func() {
+			var err error
+			local, err = local.Update(ctx, ns, rinq.Set("a", "1"))
+			Expect(err).NotTo(HaveOccurred())
+
+			remote, err = remote.Refresh(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			local, err = local.Update(ctx, ns, rinq.Set("a", "2"))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = remote.GetAll(ctx, ns)
+			Expect(err).To(HaveOccurred())
+			Expect(rinq.ShouldRetry(err)).To(BeTrue())
+		})
+	})
+
+	Describe("Namespaces", func() {
+		It("returns an empty slice at revision zero", func() {
+			names, err := remote.Namespaces(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names).To(BeEmpty())
+		})
+
+		It("returns the namespaces that contained attributes at the revision", func() {
+			var err error
+			local, err = local.Update(ctx, "ns-b", rinq.Set("a", "1"))
+			Expect(err).NotTo(HaveOccurred())
+
+			local, err = local.Update(ctx, "ns-a", rinq.Set("a", "1"))
+			Expect(err).NotTo(HaveOccurred())
+
+			remote, err = remote.Refresh(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			local, err = local.Update(ctx, "ns-c", rinq.Set("a", "1"))
+			Expect(err).NotTo(HaveOccurred())
+
+			names, err := remote.Namespaces(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names).To(Equal([]string{"ns-a", "ns-b"}))
+		})
+
+		It("returns a not found error if the session has been destroyed", func() {
+			var err error
+			local, err = local.Update(ctx, ns, rinq.Set("a", "1"))
+			Expect(err).NotTo(HaveOccurred())
+
+			remote, err = remote.Refresh(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			session.Destroy()
+			<-session.Done()
+
+			_, err = remote.Namespaces(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(rinq.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Describe("Diff", func() {
+		It("returns an empty diff at revision zero", func() {
+			diff, err := remote.Diff(ctx, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff.IsEmpty()).To(BeTrue())
+		})
+
+		It("returns the attributes changed since the given revision", func() {
+			var err error
+			local, err = local.Update(ctx, ns, rinq.Set("a", "1"), rinq.Set("b", "2"))
+			Expect(err).NotTo(HaveOccurred())
+
+			since := local.Ref().Rev
+
+			local, err = local.Update(ctx, ns, rinq.Set("b", "3"), rinq.Set("c", "4"))
+			Expect(err).NotTo(HaveOccurred())
+
+			remote, err = remote.Refresh(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			diff, err := remote.Diff(ctx, since)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff.From).To(Equal(local.SessionID().At(since)))
+			Expect(diff.To).To(Equal(remote.Ref()))
+			Expect(diff.Attrs).To(Equal(
+				map[string][]rinq.Attr{
+					ns: {rinq.Set("b", "3"), rinq.Set("c", "4")},
+				},
+			))
+		})
+
+		It("returns a stale fetch error if an attribute has been updated in a later revision", func() {
+			var err error
+			local, err = local.Update(ctx, ns, rinq.Set("a", "1"))
+			Expect(err).NotTo(HaveOccurred())
+
+			remote, err = remote.Refresh(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			local, err = local.Update(ctx, ns, rinq.Set("a", "2"))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = remote.Diff(ctx, 0)
+			Expect(err).To(HaveOccurred())
+			Expect(rinq.ShouldRetry(err)).To(BeTrue())
+		})
+
+		It("panics if the given revision is later than the revision", func() {
+			Expect(func() {
+				remote.Diff(ctx, 1)
+			}).To(Panic())
+		})
+	})
+
 	Describe("Update", func() {
 		It("returns a stale update error if session is at a later revision", func() {
 			var err error
@@ -295,6 +442,78 @@ var _ = Describe("revision (functional)", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(rinq.IsNotFound(err)).To(BeTrue())
 		})
+
+		It("returns a quota exceeded error if the owning peer's quota would be exceeded", func() {
+			owner := functest.NewPeer(
+				options.AttrQuota(options.QuotaOptions{MaxNamespaceCount: 1}),
+			)
+			defer func() {
+				owner.Stop()
+				<-owner.Done()
+			}()
+
+			functest.Must(functest.Session(owner).Call(ctx, ns, "", nil))
+
+			_, err := remote.Update(ctx, ns, rinq.Set("a", "1"), rinq.Set("b", "2"))
+			Expect(err).To(BeAssignableToTypeOf(rinq.QuotaExceededError{}))
+			Expect(err.(rinq.QuotaExceededError).Namespace).To(Equal(ns))
+		})
+	})
+
+	Describe("UpdateMany", func() {
+		It("updates attributes in several namespaces as a single revision", func() {
+			var err error
+			remote, err = remote.UpdateMany(ctx, map[string][]rinq.Attr{
+				ns:       {rinq.Set("a", "1")},
+				ns + "2": {rinq.Set("b", "2")},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			local, err = local.Refresh(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			a, err := local.Get(ctx, ns, "a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a.Value).To(Equal("1"))
+
+			b, err := local.Get(ctx, ns+"2", "b")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(b.Value).To(Equal("2"))
+		})
+
+		It("does not apply any changes if an attribute is frozen", func() {
+			var err error
+			local, err = local.Update(ctx, ns, rinq.Freeze("a", "1"))
+			Expect(err).NotTo(HaveOccurred())
+
+			remote, err = remote.Refresh(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = remote.UpdateMany(ctx, map[string][]rinq.Attr{
+				ns:       {rinq.Set("a", "2")},
+				ns + "2": {rinq.Set("b", "2")},
+			})
+			Expect(err).To(BeAssignableToTypeOf(rinq.FrozenAttributesError{}))
+
+			local, err = local.Refresh(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			b, err := local.Get(ctx, ns+"2", "b")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(b.Value).To(BeEmpty())
+		})
+
+		It("returns a stale update error if session is at a later revision", func() {
+			var err error
+			local, err = local.Update(ctx, ns, rinq.Set("a", "1"))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = remote.UpdateMany(ctx, map[string][]rinq.Attr{
+				ns: {rinq.Set("a", "2")},
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(rinq.ShouldRetry(err)).To(BeTrue())
+		})
 	})
 
 	Describe("Clear", func() {
@@ -326,6 +545,51 @@ var _ = Describe("revision (functional)", func() {
 			Expect(b.Value).To(BeEmpty())
 		})
 
+		It("clears only the specified keys", func() {
+			var err error
+			local, err = local.Update(
+				ctx,
+				ns,
+				rinq.Set("a", "1"),
+				rinq.Set("b", "2"),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			remote, err = remote.Refresh(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = remote.Clear(ctx, ns, "a")
+			Expect(err).NotTo(HaveOccurred())
+
+			local, err = local.Refresh(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			attrs, err := local.GetMany(ctx, ns, "a", "b")
+			Expect(err).NotTo(HaveOccurred())
+
+			a, _ := attrs.Get("a")
+			b, _ := attrs.Get("b")
+			Expect(a.Value).To(BeEmpty())
+			Expect(b.Value).To(Equal("2"))
+		})
+
+		It("ignores frozen attributes that are not being cleared", func() {
+			var err error
+			local, err = local.Update(
+				ctx,
+				ns,
+				rinq.Set("a", "1"),
+				rinq.Freeze("b", "2"),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			remote, err = remote.Refresh(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = remote.Clear(ctx, ns, "a")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
 		It("returns an error if any frozen attribute exists", func() {
 			var err error
 			local, err = local.Update(ctx, ns, rinq.Freeze("a", "1"))
@@ -360,7 +624,58 @@ var _ = Describe("revision (functional)", func() {
 		})
 	})
 
+	Describe("FreezeNamespace", func() {
+		It("freezes existing attributes", func() {
+			var err error
+			local, err = local.Update(ctx, ns, rinq.Set("a", "1"))
+			Expect(err).NotTo(HaveOccurred())
+
+			remote, err = remote.Refresh(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			remote, err = remote.FreezeNamespace(ctx, ns)
+			Expect(err).NotTo(HaveOccurred())
+
+			attr, err := remote.Get(ctx, ns, "a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attr).To(Equal(rinq.Freeze("a", "1")))
+
+			_, err = remote.Update(ctx, ns, rinq.Set("a", "2"))
+			Expect(err).To(BeAssignableToTypeOf(rinq.FrozenAttributesError{}))
+		})
+
+		It("prevents new attributes from being created", func() {
+			var err error
+			remote, err = remote.FreezeNamespace(ctx, ns)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = remote.Update(ctx, ns, rinq.Set("b", "1"))
+			Expect(err).To(BeAssignableToTypeOf(rinq.FrozenAttributesError{}))
+		})
+
+		It("returns a stale update error if session is at a later revision", func() {
+			var err error
+			local, err = local.Update(ctx, ns, rinq.Set("a", "1"))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = remote.FreezeNamespace(ctx, ns)
+			Expect(err).To(HaveOccurred())
+			Expect(rinq.ShouldRetry(err)).To(BeTrue())
+		})
+	})
+
 	Describe("Destroy", func() {
+		It("calls the session's destroy hooks", func() {
+			called := false
+			session.OnDestroy(func() { called = true })
+
+			err := remote.Destroy(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			<-session.Done()
+			Expect(called).To(BeTrue())
+		})
+
 		It("returns a stale update error if session is at a later revision", func() {
 			var err error
 			local, err = local.Update(ctx, ns, rinq.Set("a", "1"))
@@ -380,4 +695,108 @@ var _ = Describe("revision (functional)", func() {
 			Expect(rinq.IsNotFound(err)).To(BeTrue())
 		})
 	})
+
+	Describe("AwaitDestroy", func() {
+		It("returns when the session is destroyed", func() {
+			result := make(chan error, 1)
+			go func() {
+				result <- remote.AwaitDestroy(ctx)
+			}()
+
+			session.Destroy()
+			<-session.Done()
+
+			Eventually(result).Should(Receive(BeNil()))
+		})
+
+		It("returns immediately if the session has already been destroyed", func() {
+			session.Destroy()
+			<-session.Done()
+
+			err := remote.AwaitDestroy(ctx)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns the context error if the context is canceled first", func() {
+			ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+
+			err := remote.AwaitDestroy(ctx)
+			Expect(err).To(Equal(context.DeadlineExceeded))
+		})
+	})
+
+	Describe("Await", func() {
+		isDone := func(attr rinq.Attr) bool {
+			return attr.Value == "done"
+		}
+
+		It("returns this revision if the attribute already satisfies the predicate", func() {
+			var err error
+			local, err = local.Update(ctx, ns, rinq.Set("a", "done"))
+			Expect(err).NotTo(HaveOccurred())
+
+			remote, err = remote.Refresh(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			rev, err := remote.Await(ctx, ns, "a", isDone)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rev).To(Equal(remote))
+		})
+
+		It("returns a later revision once the attribute satisfies the predicate", func() {
+			result := make(chan rinq.Revision, 1)
+			go func() {
+				defer GinkgoRecover()
+
+				rev, err := remote.Await(ctx, ns, "a", isDone)
+				Expect(err).NotTo(HaveOccurred())
+				result <- rev
+			}()
+
+			var err error
+			local, err = local.Update(ctx, ns, rinq.Set("a", "pending"))
+			Expect(err).NotTo(HaveOccurred())
+
+			local, err = local.Update(ctx, ns, rinq.Set("b", "done"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Consistently(result, 300*time.Millisecond).ShouldNot(Receive())
+
+			local, err = local.Update(ctx, ns, rinq.Set("a", "done"))
+			Expect(err).NotTo(HaveOccurred())
+
+			var rev rinq.Revision
+			Eventually(result).Should(Receive(&rev))
+			Expect(rev.Ref()).To(Equal(local.Ref()))
+
+			attr, err := rev.Get(ctx, ns, "a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attr).To(Equal(rinq.Set("a", "done")))
+		})
+
+		It("returns a not found error if the session is destroyed", func() {
+			result := make(chan error, 1)
+			go func() {
+				_, err := remote.Await(ctx, ns, "a", isDone)
+				result <- err
+			}()
+
+			session.Destroy()
+			<-session.Done()
+
+			var err error
+			Eventually(result).Should(Receive(&err))
+			Expect(rinq.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("returns the context error if the context is canceled first", func() {
+			ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+
+			rev, err := remote.Await(ctx, ns, "a", isDone)
+			Expect(err).To(Equal(context.DeadlineExceeded))
+			Expect(rev).To(Equal(remote))
+		})
+	})
 })
@@ -3,6 +3,9 @@ package remotesession
 import (
 	"context"
 	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/jmalloc/twelf/src/twelf"
 	opentracing "github.com/opentracing/opentracing-go"
@@ -15,26 +18,47 @@ import (
 	"github.com/rinq/rinq-go/src/rinq/trace"
 )
 
+// announceTimeout is the maximum time to spend sending the announcement that
+// a watched session has been destroyed.
+const announceTimeout = 5 * time.Second
+
 type server struct {
 	peerID   ident.PeerID
+	invoker  command.Invoker
 	sessions *localsession.Store
+	remote   Store
 	logger   twelf.Logger
+
+	seq     uint32
+	mutex   sync.Mutex
+	watched map[ident.SessionID]struct{} // sessions that announce their destruction
 }
 
 // Listen attaches a new remote session service to the given command server.
+//
+// invoker is used to announce the destruction of local sessions that are
+// watched by other peers. Announcements are made after the session is
+// destroyed, which may be after the command server has been replaced, so
+// invoker must remain usable for the lifetime of the peer. remote is the store
+// that is notified of the destruction of remote sessions.
 func Listen(
 	svr command.Server,
+	invoker command.Invoker,
 	peerID ident.PeerID,
 	sessions *localsession.Store,
+	remote Store,
 	logger twelf.Logger,
 ) error {
 	s := &server{
 		peerID:   peerID,
+		invoker:  invoker,
 		sessions: sessions,
+		remote:   remote,
 		logger:   logger,
+		watched:  map[ident.SessionID]struct{}{},
 	}
 
-	_, err := svr.Listen(sessionNamespace, s.handle)
+	_, err := svr.Listen(sessionNamespace, 0, s.handle)
 	return err
 }
 
@@ -48,12 +72,26 @@ func (s *server) handle(
 	switch req.Command {
 	case fetchCommand:
 		s.fetch(ctx, req, res)
+	case fetchManyCommand:
+		s.fetchMany(ctx, req, res)
 	case updateCommand:
 		s.update(ctx, req, res)
-	case clearCommand:
+	case updateManyCommand:
+		s.updateMany(ctx, req, res)
+	case clearCommand, clearKeysCommand:
 		s.clear(ctx, req, res)
+	case freezeCommand:
+		s.freeze(ctx, req, res)
 	case destroyCommand:
 		s.destroy(ctx, req, res)
+	case watchCommand:
+		s.watch(ctx, req, res)
+	case destroyedCommand:
+		s.destroyed(ctx, req, res)
+	case namespacesCommand:
+		s.namespaces(ctx, req, res)
+	case diffCommand:
+		s.diff(ctx, req, res)
 	default:
 		res.Error(errors.New("unknown command"))
 	}
@@ -91,10 +129,16 @@ func (s *server) fetch(
 	rsp := fetchResponse{Rev: ref.Rev}
 	count := len(args.Keys)
 
-	if count != 0 {
+	if args.All {
+		rsp.Attrs = make([]attributes.VAttr, 0, attrs.Len())
+		attrs.Range(func(attr attributes.VAttr) bool {
+			rsp.Attrs = append(rsp.Attrs, attr)
+			return true
+		})
+	} else if count != 0 {
 		rsp.Attrs = make([]attributes.VAttr, 0, count)
 		for _, key := range args.Keys {
-			if attr, ok := attrs[key]; ok {
+			if attr, ok := attrs.Get(key); ok {
 				rsp.Attrs = append(rsp.Attrs, attr)
 			}
 		}
@@ -108,6 +152,65 @@ func (s *server) fetch(
 	opentr.LogSessionFetchSuccess(span, rsp.Rev, rsp.Attrs)
 }
 
+func (s *server) fetchMany(
+	ctx context.Context,
+	req rinq.Request,
+	res rinq.Response,
+) {
+	span := opentracing.SpanFromContext(ctx)
+
+	var args fetchManyRequest
+
+	if err := req.Payload.Decode(&args); err != nil {
+		res.Error(err)
+		opentr.LogSessionError(span, err)
+		return
+	}
+
+	opentr.SetupSessionFetchMany(span, s.peerID, len(args.Seqs))
+	opentr.AddTraceID(span, trace.Get(ctx))
+
+	rsp := fetchManyResponse{
+		Sessions: make(map[uint32]fetchManyResult, len(args.Seqs)),
+	}
+
+	for _, seq := range args.Seqs {
+		sess, ok := s.sessions.Get(s.peerID.Session(seq))
+		if !ok {
+			continue
+		}
+
+		ref, cat := sess.Attrs()
+		result := fetchManyResult{Rev: ref.Rev}
+
+		for ns, keys := range args.Prefetch {
+			attrs := cat[ns]
+			var l attributes.VList
+
+			for _, key := range keys {
+				if attr, ok := attrs.Get(key); ok {
+					l = append(l, attr)
+				}
+			}
+
+			if len(l) != 0 {
+				if result.Attrs == nil {
+					result.Attrs = map[string]attributes.VList{}
+				}
+
+				result.Attrs[ns] = l
+			}
+		}
+
+		rsp.Sessions[seq] = result
+	}
+
+	payload := rinq.NewPayload(rsp)
+	defer payload.Close()
+
+	res.Done(payload)
+}
+
 func (s *server) update(
 	ctx context.Context,
 	req rinq.Request,
@@ -136,7 +239,7 @@ func (s *server) update(
 		return
 	}
 
-	_, diff, err := sess.TryUpdate(args.Rev, args.Namespace, args.Attrs)
+	_, diff, err := sess.TryUpdate(ctx, args.Rev, args.Namespace, args.Attrs)
 	if err != nil {
 		res.Error(errorToFailure(err))
 		opentr.LogSessionError(span, err)
@@ -152,10 +255,8 @@ func (s *server) update(
 	_, attrs := sess.AttrsIn(args.Namespace)
 
 	for _, attr := range args.Attrs {
-		rsp.CreatedRevs = append(
-			rsp.CreatedRevs,
-			attrs[attr.Key].CreatedAt,
-		)
+		entry, _ := attrs.Get(attr.Key)
+		rsp.CreatedRevs = append(rsp.CreatedRevs, entry.CreatedAt)
 	}
 
 	payload := rinq.NewPayload(rsp)
@@ -166,6 +267,74 @@ func (s *server) update(
 	opentr.LogSessionUpdateSuccess(span, rsp.Rev, diff)
 }
 
+func (s *server) updateMany(
+	ctx context.Context,
+	req rinq.Request,
+	res rinq.Response,
+) {
+	span := opentracing.SpanFromContext(ctx)
+
+	var args updateManyRequest
+
+	if err := req.Payload.Decode(&args); err != nil {
+		res.Error(err)
+		opentr.LogSessionError(span, err)
+		return
+	}
+
+	sessID := s.peerID.Session(args.Seq)
+
+	opentr.SetupSessionUpdateMany(span, sessID)
+	opentr.AddTraceID(span, trace.Get(ctx))
+	opentr.LogSessionUpdateManyRequest(span, args.Rev, args.Attrs)
+
+	sess, ok := s.sessions.Get(sessID)
+	if !ok {
+		err := res.Fail(notFoundFailure, "")
+		opentr.LogSessionError(span, err)
+		return
+	}
+
+	_, diffs, err := sess.TryUpdateMany(ctx, args.Rev, args.Attrs)
+	if err != nil {
+		res.Error(errorToFailure(err))
+		opentr.LogSessionError(span, err)
+		return
+	}
+
+	// A successful update always produces the revision immediately following
+	// the one it was applied to, even if args.Attrs is empty.
+	nextRev := args.Rev + 1
+
+	for _, diff := range diffs {
+		logRemoteUpdate(ctx, s.logger, sessID.At(nextRev), req.ID.Ref.ID.Peer, diff)
+	}
+
+	rsp := updateManyResponse{
+		Rev:         nextRev,
+		CreatedRevs: make(map[string][]ident.Revision, len(args.Attrs)),
+	}
+	_, cat := sess.Attrs()
+
+	for ns, l := range args.Attrs {
+		createdRevs := make([]ident.Revision, 0, len(l))
+
+		for _, attr := range l {
+			entry, _ := cat[ns].Get(attr.Key)
+			createdRevs = append(createdRevs, entry.CreatedAt)
+		}
+
+		rsp.CreatedRevs[ns] = createdRevs
+	}
+
+	payload := rinq.NewPayload(rsp)
+	defer payload.Close()
+
+	res.Done(payload)
+
+	opentr.LogSessionUpdateManySuccess(span, rsp.Rev, diffs)
+}
+
 func (s *server) clear(
 	ctx context.Context,
 	req rinq.Request,
@@ -173,7 +342,7 @@ func (s *server) clear(
 ) {
 	span := opentracing.SpanFromContext(ctx)
 
-	var args updateRequest
+	var args clearRequest
 
 	if err := req.Payload.Decode(&args); err != nil {
 		res.Error(err)
@@ -185,7 +354,7 @@ func (s *server) clear(
 
 	opentr.SetupSessionClear(span, args.Namespace, sessID)
 	opentr.AddTraceID(span, trace.Get(ctx))
-	opentr.LogSessionClearRequest(span, args.Rev)
+	opentr.LogSessionClearRequest(span, args.Rev, args.Keys)
 
 	sess, ok := s.sessions.Get(sessID)
 	if !ok {
@@ -194,7 +363,7 @@ func (s *server) clear(
 		return
 	}
 
-	_, diff, err := sess.TryClear(args.Rev, args.Namespace)
+	_, diff, err := sess.TryClear(ctx, args.Rev, args.Namespace, args.Keys)
 	if err != nil {
 		res.Error(errorToFailure(err))
 		opentr.LogSessionError(span, err)
@@ -215,6 +384,55 @@ func (s *server) clear(
 	opentr.LogSessionClearSuccess(span, rsp.Rev, diff)
 }
 
+func (s *server) freeze(
+	ctx context.Context,
+	req rinq.Request,
+	res rinq.Response,
+) {
+	span := opentracing.SpanFromContext(ctx)
+
+	var args freezeRequest
+
+	if err := req.Payload.Decode(&args); err != nil {
+		res.Error(err)
+		opentr.LogSessionError(span, err)
+		return
+	}
+
+	sessID := s.peerID.Session(args.Seq)
+
+	opentr.SetupSessionFreeze(span, args.Namespace, sessID)
+	opentr.AddTraceID(span, trace.Get(ctx))
+	opentr.LogSessionFreezeRequest(span, args.Rev)
+
+	sess, ok := s.sessions.Get(sessID)
+	if !ok {
+		err := res.Fail(notFoundFailure, "")
+		opentr.LogSessionError(span, err)
+		return
+	}
+
+	_, diff, err := sess.TryFreezeNamespace(ctx, args.Rev, args.Namespace)
+	if err != nil {
+		res.Error(errorToFailure(err))
+		opentr.LogSessionError(span, err)
+		return
+	}
+
+	logRemoteFreeze(ctx, s.logger, sessID.At(diff.Revision), req.ID.Ref.ID.Peer, diff)
+
+	rsp := updateResponse{
+		Rev: diff.Revision,
+	}
+
+	payload := rinq.NewPayload(rsp)
+	defer payload.Close()
+
+	res.Done(payload)
+
+	opentr.LogSessionFreezeSuccess(span, rsp.Rev, diff)
+}
+
 func (s *server) destroy(
 	ctx context.Context,
 	req rinq.Request,
@@ -258,3 +476,176 @@ func (s *server) destroy(
 
 	opentr.LogSessionDestroySuccess(span)
 }
+
+func (s *server) watch(
+	ctx context.Context,
+	req rinq.Request,
+	res rinq.Response,
+) {
+	span := opentracing.SpanFromContext(ctx)
+
+	var args watchRequest
+
+	if err := req.Payload.Decode(&args); err != nil {
+		res.Error(err)
+		opentr.LogSessionError(span, err)
+		return
+	}
+
+	sessID := s.peerID.Session(args.Seq)
+
+	opentr.SetupSessionAwaitDestroy(span, sessID)
+	opentr.AddTraceID(span, trace.Get(ctx))
+
+	sess, ok := s.sessions.Get(sessID)
+	if !ok {
+		err := res.Fail(notFoundFailure, "")
+		opentr.LogSessionError(span, err)
+		return
+	}
+
+	// A single announcement is made for each session, regardless of how many
+	// peers are watching it, as it is multicast to every peer.
+	s.mutex.Lock()
+	_, isWatched := s.watched[sessID]
+	s.watched[sessID] = struct{}{}
+	s.mutex.Unlock()
+
+	if !isWatched {
+		sess.OnDestroy(func() {
+			go s.announce(sessID)
+		})
+	}
+
+	res.Close()
+}
+
+// announce notifies all peers that the session with the given ID has been
+// destroyed.
+func (s *server) announce(sessID ident.SessionID) {
+	s.mutex.Lock()
+	delete(s.watched, sessID)
+	s.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), announceTimeout)
+	defer cancel()
+
+	seq := atomic.AddUint32(&s.seq, 1)
+	msgID := s.peerID.Session(0).At(0).Message(seq)
+
+	out := rinq.NewPayload(destroyedRequest{
+		Seq: sessID.Seq,
+	})
+	defer out.Close()
+
+	if err := s.invoker.ExecuteMulticast(
+		ctx,
+		msgID,
+		msgID.String(),
+		sessionNamespace,
+		destroyedCommand,
+		out,
+	); err != nil {
+		logAnnounceError(s.logger, sessID, err)
+	}
+}
+
+func (s *server) destroyed(
+	ctx context.Context,
+	req rinq.Request,
+	res rinq.Response,
+) {
+	var args destroyedRequest
+
+	if err := req.Payload.Decode(&args); err != nil {
+		res.Error(err)
+		return
+	}
+
+	s.remote.CloseSession(req.ID.Ref.ID.Peer.Session(args.Seq))
+
+	res.Close()
+}
+
+func (s *server) namespaces(
+	ctx context.Context,
+	req rinq.Request,
+	res rinq.Response,
+) {
+	span := opentracing.SpanFromContext(ctx)
+
+	var args namespacesRequest
+
+	if err := req.Payload.Decode(&args); err != nil {
+		res.Error(err)
+		opentr.LogSessionError(span, err)
+		return
+	}
+
+	sessID := s.peerID.Session(args.Seq)
+
+	opentr.SetupSessionNamespaces(span, sessID)
+	opentr.AddTraceID(span, trace.Get(ctx))
+
+	sess, ok := s.sessions.Get(sessID)
+	if !ok {
+		err := res.Fail(notFoundFailure, "")
+		opentr.LogSessionError(span, err)
+		return
+	}
+
+	ref, cat := sess.Attrs()
+	rsp := namespacesResponse{
+		Rev:        ref.Rev,
+		Namespaces: cat.Namespaces(),
+	}
+
+	payload := rinq.NewPayload(rsp)
+	defer payload.Close()
+
+	res.Done(payload)
+}
+
+func (s *server) diff(
+	ctx context.Context,
+	req rinq.Request,
+	res rinq.Response,
+) {
+	span := opentracing.SpanFromContext(ctx)
+
+	var args diffRequest
+
+	if err := req.Payload.Decode(&args); err != nil {
+		res.Error(err)
+		opentr.LogSessionError(span, err)
+		return
+	}
+
+	sessID := s.peerID.Session(args.Seq)
+
+	opentr.SetupSessionDiff(span, sessID, args.Since)
+	opentr.AddTraceID(span, trace.Get(ctx))
+
+	sess, ok := s.sessions.Get(sessID)
+	if !ok {
+		err := res.Fail(notFoundFailure, "")
+		opentr.LogSessionError(span, err)
+		return
+	}
+
+	ref, cat := sess.Attrs()
+
+	// The catalog is always at the head revision, so the changes are always
+	// known.
+	changes, _ := cat.Changes(args.Since, ref.Rev)
+
+	rsp := diffResponse{
+		Rev:   ref.Rev,
+		Attrs: changes,
+	}
+
+	payload := rinq.NewPayload(rsp)
+	defer payload.Close()
+
+	res.Done(payload)
+}
@@ -26,6 +26,22 @@ func logRemoteUpdate(
 	)
 }
 
+func logRemoteFreeze(
+	ctx context.Context,
+	logger twelf.Logger,
+	ref ident.Ref,
+	peerID ident.PeerID,
+	diff *attributes.Diff,
+) {
+	logger.Log(
+		"%s session namespace frozen by %s %s [%s]",
+		ref.ShortString(),
+		peerID.ShortString(),
+		diff,
+		trace.Get(ctx),
+	)
+}
+
 func logRemoteClear(
 	ctx context.Context,
 	logger twelf.Logger,
@@ -58,3 +74,15 @@ func logRemoteDestroy(
 		trace.Get(ctx),
 	)
 }
+
+func logAnnounceError(
+	logger twelf.Logger,
+	sessID ident.SessionID,
+	err error,
+) {
+	logger.Log(
+		"%s could not announce destruction of the session to watching peers: %s",
+		sessID.ShortString(),
+		err,
+	)
+}
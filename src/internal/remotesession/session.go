@@ -3,6 +3,7 @@ package remotesession
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/rinq/rinq-go/src/internal/attributes"
 	"github.com/rinq/rinq-go/src/internal/revisions"
@@ -11,22 +12,36 @@ import (
 	"github.com/rinq/rinq-go/src/rinq/ident"
 )
 
+// awaitPollInterval is the interval at which the owning peer is polled for
+// changes while a call to Revision.Await() is waiting.
+const awaitPollInterval = 100 * time.Millisecond
+
 type session struct {
-	id     ident.SessionID
-	client *client
+	id       ident.SessionID
+	client   *client
+	prefetch map[string][]string
 
 	mutex      sync.RWMutex
 	highestRev ident.Revision
 	cache      attrTableCache
 	isClosed   bool
+	done       chan struct{} // closed when isClosed becomes true
+	isWatched  bool          // true once the owning peer has agreed to announce destruction
+	awaiting   int           // number of calls to AwaitDestroy() in progress
 }
 
-func newSession(id ident.SessionID, client *client) *session {
+func newSession(
+	id ident.SessionID,
+	client *client,
+	prefetch map[string][]string,
+) *session {
 	return &session{
-		id:     id,
-		client: client,
+		id:       id,
+		client:   client,
+		prefetch: prefetch,
 
 		cache: attrTableCache{},
+		done:  make(chan struct{}),
 	}
 }
 
@@ -57,6 +72,14 @@ func (s *session) Head(ctx context.Context) (rinq.Revision, error) {
 	}, nil
 }
 
+// IsClosed returns true if the session is known to have been destroyed.
+func (s *session) IsClosed() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.isClosed
+}
+
 func (s *session) At(rev ident.Revision) rinq.Revision {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -85,7 +108,8 @@ func (s *session) Fetch(
 		return solvedAttrs, nil
 	}
 
-	fetchedRev, fetchedAttrs, err := s.client.Fetch(ctx, s.id, ns, unsolvedKeys)
+	fetchKeys := s.withPrefetchKeys(ns, unsolvedKeys)
+	fetchedRev, fetchedAttrs, err := s.client.Fetch(ctx, s.id, ns, fetchKeys)
 
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -119,6 +143,11 @@ func (s *session) Fetch(
 			continue
 		}
 
+		// The attribute was only fetched because it's in the prefetch list.
+		if len(fetchKeys) != len(unsolvedKeys) && !containsKey(unsolvedKeys, attr.Key) {
+			continue
+		}
+
 		// The attribute hadn't been created at this revision, so we know it
 		// had an empty value.
 		if attr.CreatedAt > rev {
@@ -147,6 +176,124 @@ func (s *session) Fetch(
 	return solvedAttrs, nil
 }
 
+// FetchAll fetches every attribute in the ns namespace from the owning peer.
+func (s *session) FetchAll(
+	ctx context.Context,
+	rev ident.Revision,
+	ns string,
+) (attributes.List, error) {
+	s.mutex.RLock()
+	isClosed := s.isClosed
+	s.mutex.RUnlock()
+
+	if isClosed {
+		return nil, rinq.NotFoundError{ID: s.id}
+	}
+
+	fetchedRev, fetchedAttrs, err := s.client.FetchAll(ctx, s.id, ns)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.updateState(fetchedRev, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(attributes.List, 0, len(fetchedAttrs))
+	isStaleFetch := false
+	cache, isExistingNamespace := s.cache[ns]
+
+	for _, attr := range fetchedAttrs {
+		entry := cache[attr.Key]
+
+		// Update the cache entry if the fetched revision is newer.
+		if fetchedRev > entry.FetchedAt {
+			if cache == nil {
+				cache = attrNamespaceCache{}
+			}
+
+			cache[attr.Key] = cachedAttr{attr, fetchedRev}
+		}
+
+		// The attribute hadn't been created at this revision.
+		if attr.CreatedAt > rev {
+			continue
+		}
+
+		// The attribute has been changed since this revision, so we know it's
+		// stale, but we continue through the loop to cache any other attributes.
+		if attr.UpdatedAt > rev {
+			isStaleFetch = true
+			continue
+		}
+
+		attrs = append(attrs, attr.Attr)
+	}
+
+	if !isExistingNamespace && cache != nil {
+		s.cache[ns] = cache
+	}
+
+	if isStaleFetch {
+		return nil, rinq.StaleFetchError{Ref: s.id.At(rev)}
+	}
+
+	return attrs, nil
+}
+
+// Namespaces fetches the names of the namespaces that contained attributes as
+// of rev from the owning peer.
+func (s *session) Namespaces(ctx context.Context, rev ident.Revision) ([]string, error) {
+	s.mutex.RLock()
+	isClosed := s.isClosed
+	s.mutex.RUnlock()
+
+	if isClosed {
+		return nil, rinq.NotFoundError{ID: s.id}
+	}
+
+	fetchedRev, created, err := s.client.Namespaces(ctx, s.id)
+
+	s.mutex.Lock()
+	s.updateState(fetchedRev, err)
+	s.mutex.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return attributes.NamespacesAt(created, rev), nil
+}
+
+// Diff fetches the attributes that were changed after the since revision, up to
+// and including rev, from the owning peer.
+func (s *session) Diff(ctx context.Context, rev, since ident.Revision) (rinq.Diff, error) {
+	ref := s.id.At(rev)
+	revisions.MustValidateSince(ref, since)
+
+	s.mutex.RLock()
+	isClosed := s.isClosed
+	s.mutex.RUnlock()
+
+	if isClosed {
+		return rinq.Diff{}, rinq.NotFoundError{ID: s.id}
+	}
+
+	fetchedRev, cat, err := s.client.Diff(ctx, s.id, since)
+
+	s.mutex.Lock()
+	s.updateState(fetchedRev, err)
+	s.mutex.Unlock()
+
+	if err != nil {
+		return rinq.Diff{}, err
+	}
+
+	return revisions.Diff(ref, since, cat)
+}
+
 func (s *session) TryUpdate(
 	ctx context.Context,
 	rev ident.Revision,
@@ -224,10 +371,96 @@ func (s *session) TryUpdate(
 	}, nil
 }
 
+func (s *session) TryUpdateMany(
+	ctx context.Context,
+	rev ident.Revision,
+	attrs map[string]attributes.List,
+) (rinq.Revision, error) {
+	unlock := syncx.RLock(&s.mutex)
+	defer unlock()
+
+	if s.isClosed {
+		return nil, rinq.NotFoundError{ID: s.id}
+	}
+
+	ref := s.id.At(rev)
+
+	if s.highestRev > rev {
+		return nil, rinq.StaleUpdateError{Ref: ref}
+	}
+
+	updateAttrs := make(map[string]attributes.List, len(attrs))
+
+	for ns, l := range attrs {
+		nsAttrs := make(attributes.List, 0, len(l))
+		cache := s.cache[ns]
+
+		for _, attr := range l {
+			if entry, ok := cache[attr.Key]; ok {
+				if entry.Attr.IsFrozen {
+					if attr == entry.Attr.Attr {
+						continue
+					}
+
+					return nil, rinq.FrozenAttributesError{Ref: ref}
+				}
+
+				if entry.FetchedAt == rev && attr == entry.Attr.Attr {
+					continue
+				}
+			}
+
+			nsAttrs = append(nsAttrs, attr)
+		}
+
+		if len(nsAttrs) != 0 {
+			updateAttrs[ns] = nsAttrs
+		}
+	}
+
+	unlock()
+
+	updatedRev, returnedAttrs, err := s.client.UpdateMany(ctx, ref, updateAttrs)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.updateState(updatedRev, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for ns, attrs := range returnedAttrs {
+		cache, isExistingNamespace := s.cache[ns]
+
+		for _, attr := range attrs {
+			entry := cache[attr.Key]
+			if updatedRev > entry.FetchedAt {
+				if cache == nil {
+					cache = attrNamespaceCache{}
+				}
+
+				cache[attr.Key] = cachedAttr{attr, updatedRev}
+			}
+		}
+
+		if !isExistingNamespace && cache != nil {
+			s.cache[ns] = cache
+		}
+	}
+
+	return &revision{
+		s.id.At(s.highestRev),
+		s,
+	}, nil
+}
+
 func (s *session) TryClear(
 	ctx context.Context,
 	rev ident.Revision,
 	ns string,
+	keys []string,
 ) (rinq.Revision, error) {
 	unlock := syncx.RLock(&s.mutex)
 	defer unlock()
@@ -242,7 +475,19 @@ func (s *session) TryClear(
 		return nil, rinq.StaleUpdateError{Ref: ref}
 	}
 
-	for _, entry := range s.cache[ns] {
+	var only map[string]struct{}
+	if len(keys) != 0 {
+		only = make(map[string]struct{}, len(keys))
+		for _, k := range keys {
+			only[k] = struct{}{}
+		}
+	}
+
+	for key, entry := range s.cache[ns] {
+		if _, ok := only[key]; only != nil && !ok {
+			continue
+		}
+
 		if entry.Attr.IsFrozen {
 			if entry.Attr.Value == "" {
 				continue
@@ -254,7 +499,7 @@ func (s *session) TryClear(
 
 	unlock()
 
-	updatedRev, err := s.client.Clear(ctx, ref, ns)
+	updatedRev, err := s.client.Clear(ctx, ref, ns, keys)
 
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -268,6 +513,10 @@ func (s *session) TryClear(
 	cache := s.cache[ns]
 
 	for key, entry := range cache {
+		if _, ok := only[key]; only != nil && !ok {
+			continue
+		}
+
 		if updatedRev > entry.FetchedAt {
 			entry.Attr.Value = ""
 			entry.FetchedAt = updatedRev
@@ -281,6 +530,53 @@ func (s *session) TryClear(
 	}, nil
 }
 
+func (s *session) TryFreezeNamespace(
+	ctx context.Context,
+	rev ident.Revision,
+	ns string,
+) (rinq.Revision, error) {
+	unlock := syncx.RLock(&s.mutex)
+	defer unlock()
+
+	if s.isClosed {
+		return nil, rinq.NotFoundError{ID: s.id}
+	}
+
+	ref := s.id.At(rev)
+
+	if s.highestRev > rev {
+		return nil, rinq.StaleUpdateError{Ref: ref}
+	}
+
+	unlock()
+
+	updatedRev, err := s.client.FreezeNamespace(ctx, ref, ns)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.updateState(updatedRev, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cache := s.cache[ns]
+
+	for key, entry := range cache {
+		if updatedRev > entry.FetchedAt {
+			entry.Attr.IsFrozen = true
+			entry.FetchedAt = updatedRev
+			cache[key] = entry
+		}
+	}
+
+	return &revision{
+		s.id.At(s.highestRev),
+		s,
+	}, nil
+}
+
 func (s *session) TryDestroy(
 	ctx context.Context,
 	rev ident.Revision,
@@ -308,11 +604,127 @@ func (s *session) TryDestroy(
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	s.isClosed = true
+	s.markClosed()
 
 	return nil
 }
 
+func (s *session) AwaitDestroy(ctx context.Context) error {
+	s.mutex.Lock()
+
+	if s.isClosed {
+		s.mutex.Unlock()
+		return nil
+	}
+
+	// The session is kept in the cache while it is awaited, so that it is
+	// still present when the owning peer announces its destruction.
+	s.awaiting++
+	isWatched := s.isWatched
+
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		s.awaiting--
+		s.mutex.Unlock()
+	}()
+
+	if !isWatched {
+		if err := s.watch(ctx); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// watch asks the owning peer to announce the destruction of the session.
+func (s *session) watch(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Stop waiting on the owning peer if the session is closed by other means,
+	// such as when the owning peer is presumed to have stopped.
+	go func() {
+		select {
+		case <-s.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := s.client.Watch(ctx, s.id)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err == nil {
+		s.isWatched = true
+	} else if rinq.IsNotFound(err) {
+		s.markClosed()
+	} else if !s.isClosed {
+		return err
+	}
+
+	return nil
+}
+
+// IsAwaited returns true if there are calls to AwaitDestroy() waiting for the
+// session to be destroyed.
+func (s *session) IsAwaited() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.awaiting != 0
+}
+
+// Await polls the owning peer for changes to the attribute with the given key
+// made after the since revision, until the attribute satisfies fn.
+func (s *session) Await(
+	ctx context.Context,
+	since ident.Revision,
+	ns string,
+	key string,
+	fn func(rinq.Attr) bool,
+) (rinq.Revision, error) {
+	ticker := time.NewTicker(awaitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.done:
+			return nil, rinq.NotFoundError{ID: s.id}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		rev, cat, err := s.client.Diff(ctx, s.id, since)
+
+		s.mutex.Lock()
+		s.updateState(rev, err)
+		s.mutex.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+
+		// The diff is computed at the owning peer's latest revision, so the
+		// attributes in cat can not be stale.
+		if entry, ok := cat[ns].Get(key); ok && fn(entry.Attr) {
+			return &revision{s.id.At(rev), s}, nil
+		}
+
+		since = rev
+	}
+}
+
 func (s *session) fetchLocal(
 	rev ident.Revision,
 	ns string,
@@ -365,12 +777,92 @@ func (s *session) fetchLocal(
 	return
 }
 
+// withPrefetchKeys returns keys with the addition of any keys in the prefetch
+// list for the ns namespace that are not already in the cache.
+func (s *session) withPrefetchKeys(ns string, keys []string) []string {
+	prefetch := s.prefetch[ns]
+	if len(prefetch) == 0 {
+		return keys
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	cache := s.cache[ns]
+	result := keys
+
+	for _, key := range prefetch {
+		if _, ok := cache[key]; ok || containsKey(result, key) {
+			continue
+		}
+
+		if len(result) == len(keys) {
+			// copy keys so that the caller's slice is not modified
+			result = append([]string(nil), keys...)
+		}
+
+		result = append(result, key)
+	}
+
+	return result
+}
+
+// containsKey returns true if key is in keys.
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// markClosed records that the session is known to have been destroyed, and
+// wakes any calls to AwaitDestroy(). It assumes s.mutex is locked.
+func (s *session) markClosed() {
+	if !s.isClosed {
+		s.isClosed = true
+		close(s.done)
+	}
+}
+
 func (s *session) updateState(rev ident.Revision, err error) {
 	if err != nil {
 		if rinq.IsNotFound(err) {
-			s.isClosed = true
+			s.markClosed()
 		}
 	} else if rev > s.highestRev {
 		s.highestRev = rev
 	}
 }
+
+// applyFetched records the state of the session returned by a batched fetch.
+// found is false if the owning peer reported that the session does not exist.
+func (s *session) applyFetched(result fetchManyResult, found bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !found {
+		s.markClosed()
+		return
+	}
+
+	s.updateState(result.Rev, nil)
+
+	for ns, attrs := range result.Attrs {
+		cache := s.cache[ns]
+
+		for _, attr := range attrs {
+			// Update the cache entry if the fetched revision is newer.
+			if result.Rev > cache[attr.Key].FetchedAt {
+				if cache == nil {
+					cache = attrNamespaceCache{}
+					s.cache[ns] = cache
+				}
+
+				cache[attr.Key] = cachedAttr{attr, result.Rev}
+			}
+		}
+	}
+}
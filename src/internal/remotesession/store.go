@@ -1,6 +1,8 @@
 package remotesession
 
 import (
+	"container/list"
+	"context"
 	"sync"
 	"time"
 
@@ -10,6 +12,7 @@ import (
 	"github.com/rinq/rinq-go/src/internal/revisions"
 	"github.com/rinq/rinq-go/src/internal/service"
 	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/clock"
 	"github.com/rinq/rinq-go/src/rinq/ident"
 )
 
@@ -17,6 +20,40 @@ import (
 type Store interface {
 	revisions.Store
 	service.Service
+
+	// Stats returns statistics about the use of the cache.
+	Stats() CacheStats
+
+	// ClosePeer closes the cached sessions owned by peerID and removes them
+	// from the cache, waking any calls to AwaitDestroy() on those sessions.
+	// It is used when peerID is presumed to have stopped without destroying
+	// its sessions. It returns the number of sessions that were closed.
+	//
+	// The sessions are not remembered as destroyed, as a new peer with the
+	// same ID may reuse their session IDs.
+	ClosePeer(peerID ident.PeerID) int
+
+	// CloseSession closes the cached session with the given ID, waking any
+	// calls to AwaitDestroy() on that session. It is used when the owning
+	// peer announces that the session has been destroyed.
+	CloseSession(id ident.SessionID)
+}
+
+// CacheStats contains statistics about the use of a Store's cache.
+type CacheStats struct {
+	// Hits is the number of times a remote session was found in the cache.
+	Hits uint64
+
+	// Misses is the number of times a remote session was not found in the
+	// cache and a new entry was created.
+	Misses uint64
+
+	// Evictions is the number of entries removed from the cache because it had
+	// reached its maximum size.
+	Evictions uint64
+
+	// Size is the number of remote sessions currently in the cache.
+	Size int
 }
 
 type store struct {
@@ -26,17 +63,46 @@ type store struct {
 	peerID   ident.PeerID
 	client   *client
 	interval time.Duration
+	ttl      time.Duration
+	size     uint
+	notFound time.Duration
+	prefetch map[string][]string
+	clock    clock.Clock
 	logger   twelf.Logger
 
-	mutex sync.Mutex
-	cache map[ident.SessionID]*cacheEntry
+	mutex     sync.Mutex
+	cache     map[ident.SessionID]*list.Element
+	lru       *list.List // most recently used at the front
+	destroyed map[ident.SessionID]time.Time
+	stats     CacheStats
 }
 
 // NewStore returns a new store for revisions of remote sessions.
+//
+// If ttl is non-zero, sessions are removed from the cache once they have not
+// been used for that duration, otherwise they are removed after going unused
+// for two consecutive prune intervals. If size is non-zero, the least recently
+// used session is evicted when the cache contains more than size sessions.
+//
+// If notFound is non-zero, sessions that are known to have been destroyed are
+// remembered for that duration after they are removed from the cache, so that
+// subsequent operations on them fail without querying the owning peer.
+//
+// prefetch is a map of namespace to the attribute keys within that namespace
+// that are fetched together whenever any uncached attribute in that namespace
+// is requested.
+//
+// clk is used to measure the TTL and notFound durations, and the prune
+// interval.
 func NewStore(
 	peerID ident.PeerID,
 	invoker command.Invoker,
 	pruneInterval time.Duration,
+	ttl time.Duration,
+	size uint,
+	notFound time.Duration,
+	prefetch map[string][]string,
+	clk clock.Clock,
 	logger twelf.Logger,
 	tracer opentracing.Tracer,
 ) Store {
@@ -44,8 +110,16 @@ func NewStore(
 		peerID:   peerID,
 		client:   newClient(peerID, invoker, logger, tracer),
 		interval: pruneInterval,
+		ttl:      ttl,
+		size:     size,
+		notFound: notFound,
+		prefetch: prefetch,
+		clock:    clk,
 		logger:   logger,
-		cache:    map[ident.SessionID]*cacheEntry{},
+
+		cache:     map[ident.SessionID]*list.Element{},
+		lru:       list.New(),
+		destroyed: map[ident.SessionID]time.Time{},
 	}
 
 	s.sm = service.NewStateMachine(s.run, nil)
@@ -57,8 +131,9 @@ func NewStore(
 }
 
 type cacheEntry struct {
-	Session *session
-	Marked  bool
+	Session  *session
+	Marked   bool
+	LastUsed time.Time
 }
 
 func (s *store) GetRevision(ref ident.Ref) (rinq.Revision, error) {
@@ -66,26 +141,215 @@ func (s *store) GetRevision(ref ident.Ref) (rinq.Revision, error) {
 	return sess.At(ref.Rev), nil
 }
 
+// GetRevisions returns the session revisions for the given refs.
+//
+// The state of the sessions is fetched with a single request to each of the
+// peers that own them, provided that the peer supports batched fetches. The
+// request includes the prefetch attributes, so that they are cached before the
+// revisions are used.
+func (s *store) GetRevisions(ctx context.Context, refs []ident.Ref) ([]rinq.Revision, error) {
+	sessions := s.getSessions(refs)
+
+	if err := s.fetchMany(ctx, sessions); err != nil {
+		return nil, err
+	}
+
+	revs := make([]rinq.Revision, len(refs))
+	for i, ref := range refs {
+		revs[i] = sessions[i].At(ref.Rev)
+	}
+
+	return revs, nil
+}
+
+func (s *store) Stats() CacheStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := s.stats
+	stats.Size = len(s.cache)
+
+	return stats
+}
+
+func (s *store) ClosePeer(peerID ident.PeerID) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n := 0
+
+	for elem := s.lru.Front(); elem != nil; {
+		next := elem.Next()
+		sess := elem.Value.(*cacheEntry).Session
+
+		if sess.id.Peer == peerID {
+			sess.mutex.Lock()
+			sess.markClosed()
+			sess.mutex.Unlock()
+
+			logCacheOrphaned(s.logger, s.peerID, sess.id)
+			s.remove(elem)
+			n++
+		}
+
+		elem = next
+	}
+
+	for id := range s.destroyed {
+		if id.Peer == peerID {
+			delete(s.destroyed, id)
+		}
+	}
+
+	return n
+}
+
+func (s *store) CloseSession(id ident.SessionID) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if elem, ok := s.cache[id]; ok {
+		sess := elem.Value.(*cacheEntry).Session
+
+		sess.mutex.Lock()
+		sess.markClosed()
+		sess.mutex.Unlock()
+	}
+}
+
 func (s *store) getSession(id ident.SessionID) *session {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if entry, ok := s.cache[id]; ok {
+	return s.get(id)
+}
+
+// getSessions returns the session for each of the given refs.
+func (s *store) getSessions(refs []ident.Ref) []*session {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sessions := make([]*session, len(refs))
+	for i, ref := range refs {
+		sessions[i] = s.get(ref.ID)
+	}
+
+	return sessions
+}
+
+// get returns the cached session with the given ID, adding it to the cache if
+// necessary. It assumes s.mutex is locked.
+func (s *store) get(id ident.SessionID) *session {
+	if elem, ok := s.cache[id]; ok {
+		entry := elem.Value.(*cacheEntry)
 		entry.Marked = false
+		entry.LastUsed = s.clock.Now()
+		s.lru.MoveToFront(elem)
+		s.stats.Hits++
+
 		return entry.Session
 	}
 
-	sess := newSession(id, s.client)
-	s.cache[id] = &cacheEntry{sess, false}
+	sess := newSession(id, s.client, s.prefetch)
+
+	if expiry, ok := s.destroyed[id]; ok {
+		if s.clock.Now().Before(expiry) {
+			sess.markClosed()
+		} else {
+			delete(s.destroyed, id)
+		}
+	}
+
+	s.cache[id] = s.lru.PushFront(&cacheEntry{sess, false, s.clock.Now()})
+	s.stats.Misses++
 	logCacheAdd(s.logger, s.peerID, id)
 
+	if s.size != 0 && uint(len(s.cache)) > s.size {
+		// Sessions that are awaited are never evicted, see prune().
+		for elem := s.lru.Back(); elem != nil; elem = elem.Prev() {
+			if !elem.Value.(*cacheEntry).Session.IsAwaited() {
+				s.remove(elem)
+				s.stats.Evictions++
+				break
+			}
+		}
+	}
+
 	return sess
 }
 
+// fetchMany fetches the state of the given sessions, making a single request
+// to each of the peers that own them.
+func (s *store) fetchMany(ctx context.Context, sessions []*session) error {
+	groups := map[ident.PeerID][]*session{}
+	seen := map[ident.SessionID]struct{}{}
+
+	for _, sess := range sessions {
+		if _, ok := seen[sess.id]; ok || sess.IsClosed() {
+			continue
+		}
+
+		seen[sess.id] = struct{}{}
+
+		if s.client.SupportsFetchMany(sess.id.Peer) {
+			groups[sess.id.Peer] = append(groups[sess.id.Peer], sess)
+		}
+	}
+
+	errs := make(chan error, len(groups))
+
+	for peerID, group := range groups {
+		go func(peerID ident.PeerID, group []*session) {
+			errs <- s.fetchFrom(ctx, peerID, group)
+		}(peerID, group)
+	}
+
+	var err error
+	for range groups {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// fetchFrom fetches the state of the given sessions, all of which are owned by
+// peerID.
+func (s *store) fetchFrom(ctx context.Context, peerID ident.PeerID, sessions []*session) error {
+	seqs := make([]uint32, len(sessions))
+	for i, sess := range sessions {
+		seqs[i] = sess.id.Seq
+	}
+
+	results, err := s.client.FetchMany(ctx, peerID, seqs, s.prefetch)
+	if err != nil {
+		return err
+	}
+
+	for _, sess := range sessions {
+		result, ok := results[sess.id.Seq]
+		sess.applyFetched(result, ok)
+	}
+
+	return nil
+}
+
+// remove deletes the entry in elem from the cache. It assumes s.mutex is locked.
+func (s *store) remove(elem *list.Element) {
+	entry := s.lru.Remove(elem).(*cacheEntry)
+	delete(s.cache, entry.Session.id)
+	logCacheRemove(s.logger, s.peerID, entry.Session.id)
+
+	if s.notFound != 0 && entry.Session.IsClosed() {
+		s.destroyed[entry.Session.id] = s.clock.Now().Add(s.notFound)
+	}
+}
+
 func (s *store) run() (service.State, error) {
 	for {
 		select {
-		case <-time.After(s.interval):
+		case <-s.clock.After(s.interval):
 			s.prune()
 
 		case <-s.sm.Graceful:
@@ -101,13 +365,51 @@ func (s *store) prune() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	for id, entry := range s.cache {
+	now := s.clock.Now()
+	for id, expiry := range s.destroyed {
+		if now.After(expiry) {
+			delete(s.destroyed, id)
+		}
+	}
+
+	if s.ttl != 0 {
+		threshold := now.Add(-s.ttl)
+
+		// Entries are ordered by use, so stop at the first entry that has been
+		// used recently enough.
+		for elem := s.lru.Back(); elem != nil; {
+			prev := elem.Prev()
+			entry := elem.Value.(*cacheEntry)
+
+			if entry.LastUsed.After(threshold) {
+				break
+			}
+
+			if !entry.Session.IsAwaited() {
+				s.remove(elem)
+			}
+
+			elem = prev
+		}
+
+		return
+	}
+
+	for elem := s.lru.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*cacheEntry)
+
+		// Awaited sessions are kept in the cache so that the owning peer's
+		// announcement of their destruction is not missed.
 		if entry.Marked {
-			delete(s.cache, id)
-			logCacheRemove(s.logger, s.peerID, id)
+			if !entry.Session.IsAwaited() {
+				s.remove(elem)
+			}
 		} else {
 			entry.Marked = true
-			logCacheMark(s.logger, s.peerID, id)
+			logCacheMark(s.logger, s.peerID, entry.Session.id)
 		}
+
+		elem = next
 	}
 }
@@ -0,0 +1,375 @@
+package remotesession_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmalloc/twelf/src/twelf"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rinq/rinq-go/src/internal/attributes"
+	"github.com/rinq/rinq-go/src/internal/command"
+	. "github.com/rinq/rinq-go/src/internal/remotesession"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/clock"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		peerID ident.PeerID
+		clk    *clock.Manual
+		store  Store
+	)
+
+	newStore := func(interval, ttl time.Duration, size uint) Store {
+		return NewStore(
+			ident.NewPeerID(),
+			nil, // invoker
+			interval,
+			ttl,
+			size,
+			0,   // not found TTL
+			nil, // prefetch
+			clk,
+			&twelf.StandardLogger{},
+			opentracing.NoopTracer{},
+		)
+	}
+
+	BeforeEach(func() {
+		peerID = ident.NewPeerID()
+		clk = clock.NewManual(time.Now())
+	})
+
+	AfterEach(func() {
+		store.Stop()
+		<-store.Done()
+	})
+
+	Describe("Stats", func() {
+		It("counts cache hits and misses", func() {
+			store = newStore(time.Minute, 0, 0)
+
+			_, _ = store.GetRevision(peerID.Session(1).At(0))
+			_, _ = store.GetRevision(peerID.Session(1).At(1))
+			_, _ = store.GetRevision(peerID.Session(2).At(0))
+
+			Expect(store.Stats()).To(Equal(CacheStats{
+				Hits:   1,
+				Misses: 2,
+				Size:   2,
+			}))
+		})
+	})
+
+	Context("when the cache size is limited", func() {
+		It("evicts the least recently used session", func() {
+			store = newStore(time.Minute, 0, 2)
+
+			_, _ = store.GetRevision(peerID.Session(1).At(0))
+			_, _ = store.GetRevision(peerID.Session(2).At(0))
+			_, _ = store.GetRevision(peerID.Session(1).At(0)) // use session 1 again
+			_, _ = store.GetRevision(peerID.Session(3).At(0)) // evicts session 2
+			_, _ = store.GetRevision(peerID.Session(1).At(0)) // still cached
+
+			Expect(store.Stats()).To(Equal(CacheStats{
+				Hits:      2,
+				Misses:    3,
+				Evictions: 1,
+				Size:      2,
+			}))
+		})
+	})
+
+	Describe("GetRevisions", func() {
+		var (
+			ctx     context.Context
+			invoker *fetchManyInvoker
+		)
+
+		BeforeEach(func() {
+			ctx = context.Background()
+			invoker = &fetchManyInvoker{
+				supported: true,
+				response: map[string]interface{}{
+					"s": map[uint32]interface{}{
+						1: map[string]interface{}{
+							"r": 1,
+							"a": map[string]attributes.VList{
+								"ns": {
+									{Attr: rinq.Set("a", "1"), CreatedAt: 1, UpdatedAt: 1},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			store = NewStore(
+				ident.NewPeerID(),
+				invoker,
+				time.Minute,
+				0, // ttl
+				0, // size
+				0, // not found TTL
+				map[string][]string{"ns": {"a"}},
+				clk,
+				&twelf.StandardLogger{},
+				opentracing.NoopTracer{},
+			)
+		})
+
+		It("fetches the sessions owned by each peer with a single request", func() {
+			_, err := store.GetRevisions(ctx, []ident.Ref{
+				peerID.Session(1).At(1),
+				peerID.Session(2).At(1),
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(invoker.calls).To(Equal(1))
+		})
+
+		It("caches the prefetch attributes", func() {
+			revs, err := store.GetRevisions(ctx, []ident.Ref{
+				peerID.Session(1).At(1),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			attr, err := revs[0].Get(ctx, "ns", "a")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attr).To(Equal(rinq.Set("a", "1")))
+			Expect(invoker.calls).To(Equal(1))
+		})
+
+		It("returns closed revisions for sessions that do not exist", func() {
+			revs, err := store.GetRevisions(ctx, []ident.Ref{
+				peerID.Session(2).At(1),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = revs[0].Get(ctx, "ns", "a")
+			Expect(rinq.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("does not fetch sessions from peers that do not support batched fetches", func() {
+			invoker.supported = false
+
+			revs, err := store.GetRevisions(ctx, []ident.Ref{
+				peerID.Session(1).At(1),
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revs[0].Ref()).To(Equal(peerID.Session(1).At(1)))
+			Expect(invoker.calls).To(Equal(0))
+		})
+	})
+
+	Describe("ClosePeer", func() {
+		It("removes the sessions owned by the peer from the cache", func() {
+			store = newStore(time.Minute, 0, 0)
+
+			_, _ = store.GetRevision(peerID.Session(1).At(0))
+			_, _ = store.GetRevision(peerID.Session(2).At(0))
+			_, _ = store.GetRevision(ident.NewPeerID().Session(1).At(0))
+
+			Expect(store.ClosePeer(peerID)).To(Equal(2))
+			Expect(store.Stats().Size).To(Equal(1))
+		})
+
+		It("closes revisions of the peer's sessions that are already in use", func() {
+			store = newStore(time.Minute, 0, 0)
+
+			rev, _ := store.GetRevision(peerID.Session(1).At(0))
+			store.ClosePeer(peerID)
+
+			rev, err := rev.Refresh(context.Background())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(rev.Ref()).To(Equal(peerID.Session(1).At(0)))
+
+			_, err = rev.Get(context.Background(), "ns", "key")
+			Expect(rinq.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("wakes calls that are waiting for the peer's sessions to be destroyed", func() {
+			store = NewStore(
+				ident.NewPeerID(),
+				&blockingInvoker{},
+				time.Minute,
+				0,   // ttl
+				0,   // size
+				0,   // not found TTL
+				nil, // prefetch
+				clk,
+				&twelf.StandardLogger{},
+				opentracing.NoopTracer{},
+			)
+
+			rev, _ := store.GetRevision(peerID.Session(1).At(0))
+
+			result := make(chan error, 1)
+			go func() {
+				result <- rev.AwaitDestroy(context.Background())
+			}()
+
+			store.ClosePeer(peerID)
+
+			Eventually(result).Should(Receive(BeNil()))
+		})
+
+		It("does not remember the peer's sessions as destroyed", func() {
+			invoker := &fetchManyInvoker{
+				response: map[string]interface{}{"r": 1},
+			}
+
+			store = NewStore(
+				ident.NewPeerID(),
+				invoker,
+				time.Minute,
+				0,           // ttl
+				0,           // size
+				time.Minute, // not found TTL
+				nil,         // prefetch
+				clk,
+				&twelf.StandardLogger{},
+				opentracing.NoopTracer{},
+			)
+
+			_, _ = store.GetRevision(peerID.Session(1).At(0))
+			store.ClosePeer(peerID)
+
+			// a new peer with the same ID may reuse the session ID
+			rev, _ := store.GetRevision(peerID.Session(1).At(0))
+			rev, err := rev.Refresh(context.Background())
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(rev.Ref()).To(Equal(peerID.Session(1).At(1)))
+			Expect(invoker.calls).To(Equal(1))
+		})
+	})
+
+	Describe("CloseSession", func() {
+		It("wakes calls that are waiting for the session to be destroyed", func() {
+			invoker := &signalingInvoker{calls: make(chan struct{}, 1)}
+
+			store = NewStore(
+				ident.NewPeerID(),
+				invoker,
+				time.Minute,
+				0,   // ttl
+				1,   // size
+				0,   // not found TTL
+				nil, // prefetch
+				clk,
+				&twelf.StandardLogger{},
+				opentracing.NoopTracer{},
+			)
+
+			rev, _ := store.GetRevision(peerID.Session(1).At(0))
+
+			result := make(chan error, 1)
+			go func() {
+				result <- rev.AwaitDestroy(context.Background())
+			}()
+
+			Eventually(invoker.calls).Should(Receive())
+
+			// the awaited session is not evicted to make room for another
+			_, _ = store.GetRevision(peerID.Session(2).At(0))
+			store.CloseSession(peerID.Session(1))
+
+			Eventually(result).Should(Receive(BeNil()))
+		})
+	})
+
+	Context("when the cache TTL is set", func() {
+		It("removes sessions that have not been used within the TTL", func() {
+			store = newStore(10*time.Millisecond, 20*time.Millisecond, 0)
+
+			_, _ = store.GetRevision(peerID.Session(1).At(0))
+			Expect(store.Stats().Size).To(Equal(1))
+
+			// the store's prune loop may not yet be waiting on the clock, so
+			// keep advancing it until the session is removed
+			Eventually(func() int {
+				clk.Advance(30 * time.Millisecond)
+				return store.Stats().Size
+			}).Should(Equal(0))
+		})
+	})
+})
+
+// fetchManyInvoker is a command.Invoker that responds to every unicast call
+// with a canned response.
+type fetchManyInvoker struct {
+	command.Invoker
+
+	supported bool
+	response  interface{}
+	calls     int
+}
+
+func (i *fetchManyInvoker) Capabilities(ident.PeerID) (rinq.PeerCapabilities, bool) {
+	if !i.supported {
+		return rinq.PeerCapabilities{}, false
+	}
+
+	return rinq.PeerCapabilities{
+		Features: []string{rinq.FeatureBatchFetch},
+	}, true
+}
+
+func (i *fetchManyInvoker) CallUnicast(
+	context.Context,
+	ident.MessageID,
+	string,
+	ident.PeerID,
+	string,
+	string,
+	*rinq.Payload,
+) (*rinq.Payload, error) {
+	i.calls++
+	return rinq.NewPayload(i.response), nil
+}
+
+// blockingInvoker is a command.Invoker that blocks every unicast call until its
+// context is done.
+type blockingInvoker struct {
+	command.Invoker
+}
+
+func (i *blockingInvoker) CallUnicast(
+	ctx context.Context,
+	_ ident.MessageID,
+	_ string,
+	_ ident.PeerID,
+	_ string,
+	_ string,
+	_ *rinq.Payload,
+) (*rinq.Payload, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// signalingInvoker is a command.Invoker that sends to calls for every unicast
+// call, and responds with an empty payload.
+type signalingInvoker struct {
+	command.Invoker
+
+	calls chan struct{}
+}
+
+func (i *signalingInvoker) CallUnicast(
+	context.Context,
+	ident.MessageID,
+	string,
+	ident.PeerID,
+	string,
+	string,
+	*rinq.Payload,
+) (*rinq.Payload, error) {
+	i.calls <- struct{}{}
+	return nil, nil
+}
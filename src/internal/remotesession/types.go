@@ -11,16 +11,25 @@ const (
 )
 
 const (
-	fetchCommand   = "fetch"
-	updateCommand  = "update"
-	clearCommand   = "clear"
-	destroyCommand = "destroy"
+	fetchCommand      = "fetch"
+	fetchManyCommand  = "fetch-many"
+	updateCommand     = "update"
+	updateManyCommand = "update-many"
+	clearCommand      = "clear"
+	clearKeysCommand  = "clear-keys"
+	freezeCommand     = "freeze"
+	destroyCommand    = "destroy"
+	watchCommand      = "watch"
+	destroyedCommand  = "destroyed"
+	namespacesCommand = "namespaces"
+	diffCommand       = "diff"
 )
 
 type fetchRequest struct {
 	Seq       uint32   `json:"s"`
 	Namespace string   `json:"ns,omitempty"`
 	Keys      []string `json:"k,omitempty"`
+	All       bool     `json:"all,omitempty"` // fetch every attribute in the namespace
 }
 
 type fetchResponse struct {
@@ -28,11 +37,25 @@ type fetchResponse struct {
 	Attrs attributes.VList `json:"a,omitempty"`
 }
 
+type fetchManyRequest struct {
+	Seqs     []uint32            `json:"s"`
+	Prefetch map[string][]string `json:"p,omitempty"` // namespace -> keys
+}
+
+type fetchManyResponse struct {
+	Sessions map[uint32]fetchManyResult `json:"s,omitempty"` // sessions that were not found are omitted
+}
+
+type fetchManyResult struct {
+	Rev   ident.Revision              `json:"r"`
+	Attrs map[string]attributes.VList `json:"a,omitempty"`
+}
+
 type updateRequest struct {
 	Seq       uint32          `json:"s"`
 	Rev       ident.Revision  `json:"r"`
 	Namespace string          `json:"ns"`
-	Attrs     attributes.List `json:"a,omitempty"` // omitted for "clear" command
+	Attrs     attributes.List `json:"a,omitempty"`
 }
 
 type updateResponse struct {
@@ -40,26 +63,80 @@ type updateResponse struct {
 	CreatedRevs []ident.Revision `json:"cr,omitempty"`
 }
 
+type updateManyRequest struct {
+	Seq   uint32                     `json:"s"`
+	Rev   ident.Revision             `json:"r"`
+	Attrs map[string]attributes.List `json:"a,omitempty"`
+}
+
+type updateManyResponse struct {
+	Rev         ident.Revision              `json:"r"`
+	CreatedRevs map[string][]ident.Revision `json:"cr,omitempty"`
+}
+
+type clearRequest struct {
+	Seq       uint32         `json:"s"`
+	Rev       ident.Revision `json:"r"`
+	Namespace string         `json:"ns"`
+	Keys      []string       `json:"k,omitempty"` // omitted for "clear" command
+}
+
+type freezeRequest struct {
+	Seq       uint32         `json:"s"`
+	Rev       ident.Revision `json:"r"`
+	Namespace string         `json:"ns"`
+}
+
 type destroyRequest struct {
 	Seq uint32         `json:"s"`
 	Rev ident.Revision `json:"r"`
 }
 
+type watchRequest struct {
+	Seq uint32 `json:"s"`
+}
+
+type destroyedRequest struct {
+	Seq uint32 `json:"s"`
+}
+
+type namespacesRequest struct {
+	Seq uint32 `json:"s"`
+}
+
+type namespacesResponse struct {
+	Rev        ident.Revision            `json:"r"`
+	Namespaces map[string]ident.Revision `json:"ns,omitempty"` // namespace -> first created revision
+}
+
+type diffRequest struct {
+	Seq   uint32         `json:"s"`
+	Since ident.Revision `json:"r"`
+}
+
+type diffResponse struct {
+	Rev   ident.Revision              `json:"r"`
+	Attrs map[string]attributes.VList `json:"a,omitempty"`
+}
+
 const (
 	notFoundFailure         = "not-found"
 	staleUpdateFailure      = "stale"
 	frozenAttributesFailure = "frozen"
+	quotaExceededFailure    = "quota"
 )
 
 // errorToFailure returns the appropriate failure type based on the type of err.
 func errorToFailure(err error) error {
-	switch err.(type) {
+	switch e := err.(type) {
 	case rinq.NotFoundError:
 		return rinq.Failure{Type: notFoundFailure}
 	case rinq.StaleUpdateError:
 		return rinq.Failure{Type: staleUpdateFailure}
 	case rinq.FrozenAttributesError:
 		return rinq.Failure{Type: frozenAttributesFailure}
+	case rinq.QuotaExceededError:
+		return rinq.Failure{Type: quotaExceededFailure, Message: e.Namespace}
 	default:
 		return err
 	}
@@ -74,6 +151,8 @@ func failureToError(ref ident.Ref, err error) error {
 		return rinq.StaleUpdateError{Ref: ref}
 	case frozenAttributesFailure:
 		return rinq.FrozenAttributesError{Ref: ref}
+	case quotaExceededFailure:
+		return rinq.QuotaExceededError{Ref: ref, Namespace: err.(rinq.Failure).Message}
 	}
 
 	return err
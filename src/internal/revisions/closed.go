@@ -18,6 +18,10 @@ func (r closed) SessionID() ident.SessionID {
 	return ident.SessionID(r)
 }
 
+func (r closed) Ref() ident.Ref {
+	return ident.SessionID(r).At(0)
+}
+
 func (r closed) Refresh(context.Context) (rinq.Revision, error) {
 	return r, nil
 }
@@ -30,14 +34,42 @@ func (r closed) GetMany(context.Context, string, ...string) (rinq.AttrTable, err
 	return nil, rinq.NotFoundError{ID: ident.SessionID(r)}
 }
 
+func (r closed) GetAll(context.Context, string) (rinq.AttrTable, error) {
+	return nil, rinq.NotFoundError{ID: ident.SessionID(r)}
+}
+
+func (r closed) Namespaces(context.Context) ([]string, error) {
+	return nil, rinq.NotFoundError{ID: ident.SessionID(r)}
+}
+
+func (r closed) Diff(context.Context, ident.Revision) (rinq.Diff, error) {
+	return rinq.Diff{}, rinq.NotFoundError{ID: ident.SessionID(r)}
+}
+
 func (r closed) Update(context.Context, string, ...rinq.Attr) (rinq.Revision, error) {
 	return r, rinq.NotFoundError{ID: ident.SessionID(r)}
 }
 
-func (r closed) Clear(context.Context, string) (rinq.Revision, error) {
+func (r closed) UpdateMany(context.Context, map[string][]rinq.Attr) (rinq.Revision, error) {
+	return r, rinq.NotFoundError{ID: ident.SessionID(r)}
+}
+
+func (r closed) Clear(context.Context, string, ...string) (rinq.Revision, error) {
+	return r, rinq.NotFoundError{ID: ident.SessionID(r)}
+}
+
+func (r closed) FreezeNamespace(context.Context, string) (rinq.Revision, error) {
 	return r, rinq.NotFoundError{ID: ident.SessionID(r)}
 }
 
 func (r closed) Destroy(context.Context) error {
 	return nil
 }
+
+func (r closed) AwaitDestroy(context.Context) error {
+	return nil
+}
+
+func (r closed) Await(context.Context, string, string, func(rinq.Attr) bool) (rinq.Revision, error) {
+	return r, rinq.NotFoundError{ID: ident.SessionID(r)}
+}
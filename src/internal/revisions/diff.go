@@ -0,0 +1,51 @@
+package revisions
+
+import (
+	"fmt"
+
+	"github.com/rinq/rinq-go/src/internal/attributes"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// Diff returns the changes made to the attributes in cat after the since
+// revision, as seen by the revision with the given ref.
+//
+// cat must contain every attribute that was updated after since, it may
+// contain the attributes as of a revision later than ref.Rev.
+func Diff(ref ident.Ref, since ident.Revision, cat attributes.Catalog) (rinq.Diff, error) {
+	MustValidateSince(ref, since)
+
+	changes, ok := cat.Changes(since, ref.Rev)
+	if !ok {
+		return rinq.Diff{}, rinq.StaleFetchError{Ref: ref}
+	}
+
+	diff := rinq.Diff{
+		From:  ref.ID.At(since),
+		To:    ref,
+		Attrs: map[string][]rinq.Attr{},
+	}
+
+	for ns, l := range changes {
+		attrs := make([]rinq.Attr, len(l))
+		for i, attr := range l {
+			attrs[i] = attr.Attr
+		}
+
+		diff.Attrs[ns] = attrs
+	}
+
+	return diff, nil
+}
+
+// MustValidateSince panics if since is later than ref.Rev.
+func MustValidateSince(ref ident.Ref, since ident.Revision) {
+	if since > ref.Rev {
+		panic(fmt.Sprintf(
+			"can not diff %s against later revision %d",
+			ref.ShortString(),
+			since,
+		))
+	}
+}
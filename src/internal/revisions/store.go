@@ -1,6 +1,8 @@
 package revisions
 
 import (
+	"context"
+
 	"github.com/rinq/rinq-go/src/rinq"
 	"github.com/rinq/rinq-go/src/rinq/ident"
 )
@@ -9,6 +11,13 @@ import (
 type Store interface {
 	// GetRevision returns the session revision for the given ref.
 	GetRevision(ident.Ref) (rinq.Revision, error)
+
+	// GetRevisions returns the session revisions for the given refs, in the
+	// same order.
+	//
+	// It is equivalent to calling GetRevision() for each ref, except that
+	// the store may fetch the state of several sessions at once.
+	GetRevisions(ctx context.Context, refs []ident.Ref) ([]rinq.Revision, error)
 }
 
 // AggregateStore is a revision store that forwards to one of two other stores
@@ -45,3 +54,67 @@ func (s *AggregateStore) GetRevision(ref ident.Ref) (rinq.Revision, error) {
 
 	return Closed(ref.ID), nil
 }
+
+// GetRevisions returns the session revisions for the given refs, in the same
+// order. Each of the local and remote stores is called once, with the refs
+// that it is responsible for.
+func (s *AggregateStore) GetRevisions(ctx context.Context, refs []ident.Ref) ([]rinq.Revision, error) {
+	revs := make([]rinq.Revision, len(refs))
+
+	var local, remote batch
+
+	for i, ref := range refs {
+		if ref.ID.Peer == s.PeerID {
+			if s.Local != nil {
+				local.add(i, ref)
+				continue
+			}
+		} else if s.Remote != nil {
+			remote.add(i, ref)
+			continue
+		}
+
+		revs[i] = Closed(ref.ID)
+	}
+
+	if err := local.get(ctx, s.Local, revs); err != nil {
+		return nil, err
+	}
+
+	if err := remote.get(ctx, s.Remote, revs); err != nil {
+		return nil, err
+	}
+
+	return revs, nil
+}
+
+// batch is a subset of the refs passed to AggregateStore.GetRevisions().
+type batch struct {
+	indices []int // the index of each ref in the original slice
+	refs    []ident.Ref
+}
+
+// add adds the ref at index i of the original slice to the batch.
+func (b *batch) add(i int, ref ident.Ref) {
+	b.indices = append(b.indices, i)
+	b.refs = append(b.refs, ref)
+}
+
+// get fetches the revisions in the batch from store, and places them in revs
+// at their original positions.
+func (b *batch) get(ctx context.Context, store Store, revs []rinq.Revision) error {
+	if len(b.refs) == 0 {
+		return nil
+	}
+
+	r, err := store.GetRevisions(ctx, b.refs)
+	if err != nil {
+		return err
+	}
+
+	for i, rev := range r {
+		revs[b.indices[i]] = rev
+	}
+
+	return nil
+}
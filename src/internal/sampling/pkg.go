@@ -0,0 +1,4 @@
+// Package sampling decides which traces have their debug log messages and
+// tracing spans recorded, so that hot namespaces can be observed without
+// recording every message.
+package sampling
@@ -0,0 +1,97 @@
+package sampling
+
+import (
+	"hash/fnv"
+	"math"
+
+	"github.com/jmalloc/twelf/src/twelf"
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// Sampler decides whether the debug log messages and tracing spans produced
+// for a trace are recorded, based on a sampling rate for each namespace.
+//
+// The decision is derived from the trace ID alone, so every peer makes the
+// same decision for a given trace and namespace without coordination, and a
+// trace that is sampled at one rate is also sampled at any higher rate.
+//
+// A nil *Sampler records every trace.
+type Sampler struct {
+	rates map[string]float64
+}
+
+// NewSampler returns a sampler that samples traces within each namespace in
+// rates at the associated rate, between 0 and 1. Traces within namespaces
+// that are not in rates are always recorded.
+//
+// If rates is empty, it returns nil.
+func NewSampler(rates map[string]float64) *Sampler {
+	if len(rates) == 0 {
+		return nil
+	}
+
+	return &Sampler{rates}
+}
+
+// IsSampled returns true if messages produced for the trace with the given ID
+// within the ns namespace should be recorded.
+func (s *Sampler) IsSampled(ns, traceID string) bool {
+	if s == nil {
+		return true
+	}
+
+	rate, ok := s.rates[ns]
+	if !ok {
+		return true
+	}
+
+	return float64(hash(traceID)) < rate*(math.MaxUint32+1)
+}
+
+// Logger returns l if the trace is sampled, otherwise it returns a logger
+// that discards debug messages written to l.
+func (s *Sampler) Logger(l twelf.Logger, ns, traceID string) twelf.Logger {
+	if s.IsSampled(ns, traceID) {
+		return l
+	}
+
+	return unsampledLogger{l}
+}
+
+// Tracer returns t if the trace is sampled, otherwise it returns a tracer
+// that does not record spans.
+func (s *Sampler) Tracer(t opentracing.Tracer, ns, traceID string) opentracing.Tracer {
+	if s.IsSampled(ns, traceID) {
+		return t
+	}
+
+	return opentracing.NoopTracer{}
+}
+
+// hash returns a uniformly distributed hash of a trace ID.
+//
+// Trace IDs are usually message IDs, which differ only in their trailing
+// characters, so the FNV hash is passed through the MurmurHash3 finalizer to
+// spread those differences across the high bits.
+func hash(traceID string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(traceID))
+
+	v := h.Sum32()
+	v ^= v >> 16
+	v *= 0x85ebca6b
+	v ^= v >> 13
+	v *= 0xc2b2ae35
+	v ^= v >> 16
+
+	return v
+}
+
+// unsampledLogger is a twelf.Logger that discards debug messages.
+type unsampledLogger struct {
+	twelf.Logger
+}
+
+func (unsampledLogger) Debug(string, ...interface{}) {}
+func (unsampledLogger) DebugString(string)           {}
+func (unsampledLogger) IsDebug() bool                { return false }
@@ -0,0 +1,143 @@
+package sampling_test
+
+import (
+	"fmt"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opentracing "github.com/opentracing/opentracing-go"
+	. "github.com/rinq/rinq-go/src/internal/sampling"
+)
+
+var _ = Describe("Sampler", func() {
+	Describe("NewSampler", func() {
+		It("returns nil if there are no rates", func() {
+			Expect(NewSampler(nil)).To(BeNil())
+			Expect(NewSampler(map[string]float64{})).To(BeNil())
+		})
+	})
+
+	Describe("IsSampled", func() {
+		It("samples every trace if the sampler is nil", func() {
+			var s *Sampler
+
+			Expect(s.IsSampled("ns", "<trace>")).To(BeTrue())
+		})
+
+		It("samples every trace within namespaces that have no rate", func() {
+			s := NewSampler(map[string]float64{"hot": 0})
+
+			Expect(s.IsSampled("ns", "<trace>")).To(BeTrue())
+		})
+
+		It("samples no traces at a rate of zero", func() {
+			s := NewSampler(map[string]float64{"ns": 0})
+
+			for i := 0; i < 100; i++ {
+				Expect(s.IsSampled("ns", strconv.Itoa(i))).To(BeFalse())
+			}
+		})
+
+		It("samples every trace at a rate of one", func() {
+			s := NewSampler(map[string]float64{"ns": 1})
+
+			for i := 0; i < 100; i++ {
+				Expect(s.IsSampled("ns", strconv.Itoa(i))).To(BeTrue())
+			}
+		})
+
+		It("samples approximately the given fraction of traces", func() {
+			s := NewSampler(map[string]float64{"ns": 0.1})
+
+			n := 0
+			for i := 0; i < 1000; i++ {
+				if s.IsSampled("ns", fmt.Sprintf("trace-%d", i)) {
+					n++
+				}
+			}
+
+			Expect(n).To(BeNumerically("~", 100, 50))
+		})
+
+		It("samples every trace that is sampled at a lower rate", func() {
+			low := NewSampler(map[string]float64{"ns": 0.1})
+			high := NewSampler(map[string]float64{"ns": 0.5})
+
+			for i := 0; i < 1000; i++ {
+				id := fmt.Sprintf("trace-%d", i)
+				if low.IsSampled("ns", id) {
+					Expect(high.IsSampled("ns", id)).To(BeTrue())
+				}
+			}
+		})
+	})
+
+	Describe("Logger", func() {
+		var logger *recordingLogger
+
+		BeforeEach(func() {
+			logger = &recordingLogger{}
+		})
+
+		It("returns the logger unchanged if the trace is sampled", func() {
+			s := NewSampler(map[string]float64{"ns": 1})
+
+			Expect(s.Logger(logger, "ns", "<trace>")).To(BeIdenticalTo(logger))
+		})
+
+		It("discards debug messages if the trace is not sampled", func() {
+			s := NewSampler(map[string]float64{"ns": 0})
+			l := s.Logger(logger, "ns", "<trace>")
+
+			l.Debug("debug %d", 1)
+			l.DebugString("debug")
+			l.Log("log %d", 1)
+			l.LogString("log")
+
+			Expect(l.IsDebug()).To(BeFalse())
+			Expect(logger.lines).To(Equal([]string{"log 1", "log"}))
+		})
+	})
+
+	Describe("Tracer", func() {
+		tracer := &opentracing.NoopTracer{}
+
+		It("returns the tracer unchanged if the trace is sampled", func() {
+			s := NewSampler(map[string]float64{"ns": 1})
+
+			Expect(s.Tracer(tracer, "ns", "<trace>")).To(BeIdenticalTo(tracer))
+		})
+
+		It("returns a no-op tracer if the trace is not sampled", func() {
+			s := NewSampler(map[string]float64{"ns": 0})
+
+			Expect(s.Tracer(tracer, "ns", "<trace>")).To(Equal(opentracing.NoopTracer{}))
+		})
+	})
+})
+
+// recordingLogger is a twelf.Logger that records each message.
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Log(f string, v ...interface{}) {
+	l.LogString(fmt.Sprintf(f, v...))
+}
+
+func (l *recordingLogger) LogString(s string) {
+	l.lines = append(l.lines, s)
+}
+
+func (l *recordingLogger) Debug(f string, v ...interface{}) {
+	l.DebugString(fmt.Sprintf(f, v...))
+}
+
+func (l *recordingLogger) DebugString(s string) {
+	l.lines = append(l.lines, s)
+}
+
+func (l *recordingLogger) IsDebug() bool {
+	return true
+}
@@ -3,20 +3,76 @@ package bufferpool
 import (
 	"bytes"
 	"sync"
+	"sync/atomic"
 )
 
-var buffers sync.Pool
+// DefaultMaxSize is the default value for the maximum capacity of a buffer that
+// is retained by the pool.
+const DefaultMaxSize = 64 * 1024
+
+var (
+	buffers sync.Pool
+	maxSize = int64(DefaultMaxSize)
+	stats   Stats
+)
+
+// Stats contains statistics about the use of the buffer pool.
+type Stats struct {
+	// Gets is the number of buffers fetched from the pool.
+	Gets uint64
+
+	// Puts is the number of buffers returned to the pool.
+	Puts uint64
+
+	// Discards is the number of buffers that were not returned to the pool
+	// because their capacity exceeded the maximum size.
+	Discards uint64
+}
 
 // Get fetches a buffer from the buffer pool.
 func Get() *bytes.Buffer {
+	atomic.AddUint64(&stats.Gets, 1)
 	return buffers.Get().(*bytes.Buffer)
 }
 
 // Put returns a buffer to the buffer pool.
+//
+// Buffers with a capacity greater than the maximum size are discarded, so that
+// a single large payload does not cause the pool to retain a large amount of
+// memory indefinitely.
 func Put(buf *bytes.Buffer) {
-	if buf != nil {
-		buf.Reset()
-		buffers.Put(buf)
+	if buf == nil {
+		return
+	}
+
+	if max := atomic.LoadInt64(&maxSize); max > 0 && int64(buf.Cap()) > max {
+		atomic.AddUint64(&stats.Discards, 1)
+		return
+	}
+
+	atomic.AddUint64(&stats.Puts, 1)
+	buf.Reset()
+	buffers.Put(buf)
+}
+
+// SetMaxSize sets the maximum capacity, in bytes, of a buffer that is retained
+// by the pool. A value of zero means there is no limit.
+func SetMaxSize(n int) {
+	atomic.StoreInt64(&maxSize, int64(n))
+}
+
+// MaxSize returns the maximum capacity, in bytes, of a buffer that is retained
+// by the pool.
+func MaxSize() int {
+	return int(atomic.LoadInt64(&maxSize))
+}
+
+// GetStats returns statistics about the use of the buffer pool.
+func GetStats() Stats {
+	return Stats{
+		Gets:     atomic.LoadUint64(&stats.Gets),
+		Puts:     atomic.LoadUint64(&stats.Puts),
+		Discards: atomic.LoadUint64(&stats.Discards),
 	}
 }
 
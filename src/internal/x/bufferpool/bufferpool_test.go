@@ -35,3 +35,55 @@ var _ = Describe("Put", func() {
 		Expect(Get()).ShouldNot(BeNil())
 	})
 })
+
+var _ = Describe("SetMaxSize", func() {
+	AfterEach(func() {
+		SetMaxSize(DefaultMaxSize)
+	})
+
+	It("sets the maximum size", func() {
+		SetMaxSize(100)
+		Expect(MaxSize()).To(Equal(100))
+	})
+
+	It("causes oversized buffers to be discarded", func() {
+		SetMaxSize(10)
+		before := GetStats()
+
+		Put(bytes.NewBuffer(make([]byte, 11)))
+
+		after := GetStats()
+		Expect(after.Discards - before.Discards).To(BeEquivalentTo(1))
+		Expect(after.Puts - before.Puts).To(BeEquivalentTo(0))
+	})
+
+	It("does not discard buffers when the maximum size is zero", func() {
+		SetMaxSize(0)
+		before := GetStats()
+
+		Put(bytes.NewBuffer(make([]byte, DefaultMaxSize+1)))
+
+		after := GetStats()
+		Expect(after.Discards - before.Discards).To(BeEquivalentTo(0))
+		Expect(after.Puts - before.Puts).To(BeEquivalentTo(1))
+
+		// Remove the oversized buffer from the pool, otherwise it is discarded
+		// when other tests return it after the maximum size is restored.
+		Get()
+	})
+})
+
+var _ = Describe("GetStats", func() {
+	It("counts gets and puts", func() {
+		before := GetStats()
+
+		// Put a new buffer, as the buffer returned by Get() may have been
+		// grown beyond the maximum size by another test.
+		Get()
+		Put(&bytes.Buffer{})
+
+		after := GetStats()
+		Expect(after.Gets - before.Gets).To(BeEquivalentTo(1))
+		Expect(after.Puts - before.Puts).To(BeEquivalentTo(1))
+	})
+})
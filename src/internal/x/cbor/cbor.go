@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io"
 	"sync"
+	"sync/atomic"
 
 	"github.com/ugorji/go/codec"
 )
@@ -12,12 +13,44 @@ import (
 var Nil []byte
 
 var encoders sync.Pool
+var canonicalEncoders sync.Pool
 var decoders sync.Pool
 
+// canonical is non-zero if values are encoded in canonical form.
+var canonical int32
+
+// SetCanonical sets whether values are encoded in canonical form.
+//
+// Canonical encoding sorts map keys, such that equal values always produce
+// identical binary representations, at some cost in performance.
+func SetCanonical(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&canonical, 1)
+	} else {
+		atomic.StoreInt32(&canonical, 0)
+	}
+}
+
+// IsCanonical returns true if values are encoded in canonical form.
+func IsCanonical() bool {
+	return atomic.LoadInt32(&canonical) != 0
+}
+
+// encoderPool returns the pool of encoders to use for the current encoding
+// mode.
+func encoderPool() *sync.Pool {
+	if IsCanonical() {
+		return &canonicalEncoders
+	}
+
+	return &encoders
+}
+
 // Encode writes v to w in CBOR format.
 func Encode(w io.Writer, v interface{}) error {
-	e := encoders.Get().(*codec.Encoder)
-	defer encoders.Put(e)
+	pool := encoderPool()
+	e := pool.Get().(*codec.Encoder)
+	defer pool.Put(e)
 
 	e.Reset(w)
 	return e.Encode(v)
@@ -25,8 +58,9 @@ func Encode(w io.Writer, v interface{}) error {
 
 // MustEncode writes v to w in CBOR format, or panics if unable to do so.
 func MustEncode(w io.Writer, v interface{}) {
-	e := encoders.Get().(*codec.Encoder)
-	defer encoders.Put(e)
+	pool := encoderPool()
+	e := pool.Get().(*codec.Encoder)
+	defer pool.Put(e)
 
 	e.Reset(w)
 	e.MustEncode(v)
@@ -71,12 +105,17 @@ func MustDecodeBytes(b []byte, v interface{}) {
 }
 
 func init() {
-	var handle codec.CborHandle
+	var handle, canonicalHandle codec.CborHandle
+	canonicalHandle.Canonical = true
 
 	encoders.New = func() interface{} {
 		return codec.NewEncoder(nil, &handle)
 	}
 
+	canonicalEncoders.New = func() interface{} {
+		return codec.NewEncoder(nil, &canonicalHandle)
+	}
+
 	decoders.New = func() interface{} {
 		return codec.NewDecoder(nil, &handle)
 	}
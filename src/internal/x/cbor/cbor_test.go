@@ -72,3 +72,33 @@ var _ = Describe("MustDecodeBytes", func() {
 		Expect(v).To(Equal(uint64(123)))
 	})
 })
+
+var _ = Describe("SetCanonical", func() {
+	AfterEach(func() {
+		SetCanonical(false)
+	})
+
+	It("enables canonical encoding", func() {
+		SetCanonical(true)
+		Expect(IsCanonical()).To(BeTrue())
+
+		for i := 0; i < 10; i++ {
+			var buf bytes.Buffer
+			MustEncode(&buf, map[string]int{"b": 2, "a": 1, "c": 3})
+
+			Expect(buf.Bytes()).To(Equal([]byte{
+				0xa3,
+				0x61, 'a', 1,
+				0x61, 'b', 2,
+				0x61, 'c', 3,
+			}))
+		}
+	})
+
+	It("disables canonical encoding", func() {
+		SetCanonical(true)
+		SetCanonical(false)
+
+		Expect(IsCanonical()).To(BeFalse())
+	})
+})
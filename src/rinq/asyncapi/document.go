@@ -0,0 +1,151 @@
+package asyncapi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+// Version is the version of the AsyncAPI specification that documents conform
+// to.
+const Version = "2.0.0"
+
+// Document is an AsyncAPI document. It is serialized by encoding/json.
+type Document struct {
+	AsyncAPI string             `json:"asyncapi"`
+	Info     Info               `json:"info"`
+	Channels map[string]Channel `json:"channels"`
+}
+
+// Info contains metadata about the service described by a document.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Channel describes a single command or notification type.
+type Channel struct {
+	Description string    `json:"description,omitempty"`
+	Publish     Operation `json:"publish"`
+}
+
+// Operation describes the messages that may be sent to a channel.
+type Operation struct {
+	OperationID string  `json:"operationId"`
+	Message     Message `json:"message"`
+
+	// Response describes the response payload of a command. It is a Rinq
+	// extension, as AsyncAPI has no concept of a response.
+	Response json.RawMessage `json:"x-rinq-response,omitempty"`
+}
+
+// Message describes the payload of a command request or notification. The
+// payload is a JSON schema.
+type Message struct {
+	Name    string          `json:"name"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Notification describes a notification type that is handled by the sessions
+// of a peer.
+type Notification struct {
+	// Namespace is the namespace that the sessions listen to.
+	Namespace string
+
+	// Type is the notification type.
+	Type string
+
+	// Description is a human-readable description of the notification.
+	Description string
+
+	// Schema is a hint describing the payload, as per rinq.CommandSchema.
+	Schema string
+}
+
+// Generate returns a document describing commands and notifications.
+//
+// commands is typically the catalog returned by rinq.Peer.Commands(). Each
+// namespace that is not handled by a rinq.CommandMux is described by a single
+// channel that accepts any command.
+//
+// Schema hints that are valid JSON are used as JSON schemas verbatim. Any
+// other hint is used as the title of an otherwise empty schema.
+func Generate(info Info, commands []rinq.CommandInfo, notifications []Notification) *Document {
+	doc := &Document{
+		AsyncAPI: Version,
+		Info:     info,
+		Channels: map[string]Channel{},
+	}
+
+	for _, c := range commands {
+		doc.Channels[commandChannel(c)] = Channel{
+			Description: commandDescription(c),
+			Publish: Operation{
+				OperationID: commandChannel(c),
+				Message: Message{
+					Name:    c.Command,
+					Payload: schema(c.Schema.Request),
+				},
+				Response: schema(c.Schema.Response),
+			},
+		}
+	}
+
+	for _, n := range notifications {
+		name := n.Namespace + "::" + n.Type
+
+		doc.Channels[name] = Channel{
+			Description: n.Description,
+			Publish: Operation{
+				OperationID: name,
+				Message: Message{
+					Name:    n.Type,
+					Payload: schema(n.Schema),
+				},
+			},
+		}
+	}
+
+	return doc
+}
+
+// commandChannel returns the name of the channel that describes c.
+//
+// Versioned namespaces are suffixed with "@" and the version. The "@"
+// character is not valid in namespaces, so the name never collides with that
+// of another namespace.
+func commandChannel(c rinq.CommandInfo) string {
+	ns := c.Namespace
+	if c.Version != 0 {
+		ns = fmt.Sprintf("%s@%d", ns, c.Version)
+	}
+
+	if c.Command == "" {
+		return ns + "::*"
+	}
+
+	return ns + "::" + c.Command
+}
+
+// commandDescription returns the description of the channel for c.
+func commandDescription(c rinq.CommandInfo) string {
+	if c.Command == "" {
+		return fmt.Sprintf("Any command in the '%s' namespace.", c.Namespace)
+	}
+
+	return fmt.Sprintf("The '%s' command in the '%s' namespace.", c.Command, c.Namespace)
+}
+
+// schema returns the JSON schema described by hint, or nil if hint is empty.
+func schema(hint string) json.RawMessage {
+	if hint == "" {
+		return nil
+	} else if json.Valid([]byte(hint)) {
+		return json.RawMessage(hint)
+	}
+
+	s, _ := json.Marshal(map[string]string{"title": hint})
+	return s
+}
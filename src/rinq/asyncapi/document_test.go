@@ -0,0 +1,109 @@
+package asyncapi_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/asyncapi"
+)
+
+var _ = Describe("Generate", func() {
+	info := asyncapi.Info{Title: "<title>", Version: "1.0.0"}
+
+	It("describes each command as a channel", func() {
+		doc := asyncapi.Generate(
+			info,
+			[]rinq.CommandInfo{
+				{
+					Namespace: "ns",
+					Command:   "cmd",
+					Schema: rinq.CommandSchema{
+						Request:  `{"type":"string"}`,
+						Response: "Result",
+					},
+				},
+			},
+			nil,
+		)
+
+		Expect(doc.AsyncAPI).To(Equal(asyncapi.Version))
+		Expect(doc.Info).To(Equal(info))
+		Expect(doc.Channels).To(HaveKey("ns::cmd"))
+
+		op := doc.Channels["ns::cmd"].Publish
+		Expect(op.Message.Name).To(Equal("cmd"))
+		Expect(op.Message.Payload).To(MatchJSON(`{"type":"string"}`))
+		Expect(op.Response).To(MatchJSON(`{"title":"Result"}`))
+	})
+
+	It("includes the version in the channel name of versioned namespaces", func() {
+		doc := asyncapi.Generate(
+			info,
+			[]rinq.CommandInfo{
+				{Namespace: "ns", Version: 2, Command: "cmd"},
+			},
+			nil,
+		)
+
+		Expect(doc.Channels).To(HaveKey("ns@2::cmd"))
+	})
+
+	It("describes namespaces with an unknown set of commands as a single channel", func() {
+		doc := asyncapi.Generate(
+			info,
+			[]rinq.CommandInfo{
+				{Namespace: "ns"},
+			},
+			nil,
+		)
+
+		Expect(doc.Channels).To(HaveKey("ns::*"))
+	})
+
+	It("describes each notification as a channel", func() {
+		doc := asyncapi.Generate(
+			info,
+			nil,
+			[]asyncapi.Notification{
+				{Namespace: "ns", Type: "type", Description: "<desc>"},
+			},
+		)
+
+		Expect(doc.Channels).To(HaveKey("ns::type"))
+
+		ch := doc.Channels["ns::type"]
+		Expect(ch.Description).To(Equal("<desc>"))
+		Expect(ch.Publish.Message.Name).To(Equal("type"))
+		Expect(ch.Publish.Message.Payload).To(BeNil())
+		Expect(ch.Publish.Response).To(BeNil())
+	})
+
+	It("omits empty schemas from the JSON representation", func() {
+		doc := asyncapi.Generate(
+			info,
+			[]rinq.CommandInfo{
+				{Namespace: "ns", Command: "cmd"},
+			},
+			nil,
+		)
+
+		buf, err := json.Marshal(doc)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(buf).To(MatchJSON(`{
+			"asyncapi": "2.0.0",
+			"info": {"title": "<title>", "version": "1.0.0"},
+			"channels": {
+				"ns::cmd": {
+					"description": "The 'cmd' command in the 'ns' namespace.",
+					"publish": {
+						"operationId": "ns::cmd",
+						"message": {"name": "cmd"}
+					}
+				}
+			}
+		}`))
+	})
+})
@@ -0,0 +1,8 @@
+// Package asyncapi generates AsyncAPI documents that describe the commands and
+// notifications handled by a Rinq peer, so that the consumers of a service can
+// discover its contracts.
+//
+// Each command and notification is described by a channel. Commands are taken
+// from the catalog returned by rinq.Peer.Commands(). Notifications are not
+// registered with the peer, so they are described by the application.
+package asyncapi
@@ -0,0 +1,16 @@
+package rinq
+
+// AttrChangeNotification is the type of the notifications that a peer
+// publishes when the attributes of one of its sessions are changed within a
+// namespace that is configured with options.AttrFeed().
+//
+// Each notification is multicast within the namespace that contains the
+// changed attributes, to every session that is listening to that namespace.
+// The notification's source is the revision produced by the change, and its
+// payload is a []Attr containing each of the changed attributes as they were
+// at that revision. Attributes that have been cleared have an empty value.
+//
+// Changes are published on a best-effort basis. A listener that requires a
+// complete view of the attributes should reconcile its copy using
+// Revision.Diff() whenever it detects a gap in the revisions it has received.
+const AttrChangeNotification = "rinq.attr-change"
@@ -0,0 +1,65 @@
+package rinq
+
+import "context"
+
+// WithBaggage returns a new context derived from parent that carries a baggage
+// item with the given key and value.
+//
+// Baggage is a small set of key/value pairs that is sent with each command
+// request and notification made using the context, and is restored in the
+// context passed to the handler. Because handlers pass their context to any
+// further requests, baggage propagates through an entire call graph. It is
+// intended for request-scoped values such as a tenant ID, locale or feature
+// flags, which are not attributes of the source session.
+//
+// Baggage is sent in the message headers, so it should be kept small. An empty
+// value removes the item with the given key.
+func WithBaggage(parent context.Context, key, value string) context.Context {
+	if key == "" {
+		panic("baggage key must not be empty")
+	}
+
+	return withMapItem(parent, baggageKey, key, value)
+}
+
+// Baggage returns the value of the baggage item with the given key. ok is false
+// if ctx does not carry such an item.
+func Baggage(ctx context.Context, key string) (value string, ok bool) {
+	items, _ := ctx.Value(baggageKey).(map[string]string)
+	value, ok = items[key]
+	return
+}
+
+// AllBaggage returns the baggage items carried by ctx. The returned map must
+// not be modified.
+func AllBaggage(ctx context.Context) map[string]string {
+	items, _ := ctx.Value(baggageKey).(map[string]string)
+	return items
+}
+
+type baggageKeyType struct{}
+
+var baggageKey baggageKeyType
+
+// withMapItem returns a new context derived from parent in which the map
+// stored under ctxKey has an item with the given key and value. The map in
+// parent is not modified. An empty value removes the item.
+func withMapItem(parent context.Context, ctxKey interface{}, key, value string) context.Context {
+	prev, _ := parent.Value(ctxKey).(map[string]string)
+	if _, ok := prev[key]; !ok && value == "" {
+		return parent
+	}
+
+	items := make(map[string]string, len(prev)+1)
+	for k, v := range prev {
+		items[k] = v
+	}
+
+	if value == "" {
+		delete(items, key)
+	} else {
+		items[key] = value
+	}
+
+	return context.WithValue(parent, ctxKey, items)
+}
@@ -0,0 +1,57 @@
+package rinq_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+var _ = Describe("WithBaggage", func() {
+	It("adds the item to the context", func() {
+		ctx := rinq.WithBaggage(context.Background(), "key", "value")
+
+		v, ok := rinq.Baggage(ctx, "key")
+
+		Expect(ok).To(BeTrue())
+		Expect(v).To(Equal("value"))
+	})
+
+	It("retains the items from the parent context", func() {
+		parent := rinq.WithBaggage(context.Background(), "a", "1")
+		ctx := rinq.WithBaggage(parent, "b", "2")
+
+		Expect(rinq.AllBaggage(ctx)).To(Equal(map[string]string{"a": "1", "b": "2"}))
+	})
+
+	It("does not modify the items in the parent context", func() {
+		parent := rinq.WithBaggage(context.Background(), "a", "1")
+		rinq.WithBaggage(parent, "a", "2")
+
+		Expect(rinq.AllBaggage(parent)).To(Equal(map[string]string{"a": "1"}))
+	})
+
+	It("removes the item if the value is empty", func() {
+		parent := rinq.WithBaggage(context.Background(), "a", "1")
+		ctx := rinq.WithBaggage(parent, "a", "")
+
+		_, ok := rinq.Baggage(ctx, "a")
+
+		Expect(ok).To(BeFalse())
+	})
+
+	It("panics if the key is empty", func() {
+		Expect(func() {
+			rinq.WithBaggage(context.Background(), "", "value")
+		}).To(Panic())
+	})
+})
+
+var _ = Describe("Baggage", func() {
+	It("returns false if the context has no baggage", func() {
+		_, ok := rinq.Baggage(context.Background(), "key")
+
+		Expect(ok).To(BeFalse())
+	})
+})
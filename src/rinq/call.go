@@ -0,0 +1,113 @@
+package rinq
+
+import (
+	"context"
+	"time"
+)
+
+// WithHedging returns a new context derived from parent that enables request
+// hedging for calls made with Session.Call().
+//
+// If no response has been received within delay of a hedged call being sent,
+// a duplicate load-balanced request is sent. The first response received from
+// either request is returned and the other request is abandoned.
+//
+// Hedging reduces tail latency when a single server is slow to respond, at the
+// cost of additional load. It should only be used for commands that are safe
+// to execute more than once.
+func WithHedging(parent context.Context, delay time.Duration) context.Context {
+	return context.WithValue(parent, hedgeKey, delay)
+}
+
+// HedgeDelay returns the hedging delay from ctx. ok is false if hedging is not
+// enabled.
+func HedgeDelay(ctx context.Context) (delay time.Duration, ok bool) {
+	delay, ok = ctx.Value(hedgeKey).(time.Duration)
+	return
+}
+
+type hedgeKeyType struct{}
+
+var hedgeKey hedgeKeyType
+
+// Budget returns the time remaining before the deadline of ctx. ok is false if
+// ctx has no deadline. The budget is never negative.
+//
+// Within a command handler, the deadline is that of the request, less the
+// server's hop margin, see options.HopMargin(). Handlers can use the budget to
+// decide whether there is enough time remaining to start expensive work, such
+// as making further calls.
+func Budget(ctx context.Context) (budget time.Duration, ok bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+
+	budget = time.Until(deadline)
+	if budget < 0 {
+		budget = 0
+	}
+
+	return budget, true
+}
+
+// WithAPIVersion returns a new context derived from parent that specifies the
+// API version of the namespace to send command requests to.
+//
+// Requests made with the returned context are only delivered to peers that
+// are listening to the namespace at that specific version, as per
+// Peer.ListenVersion(). A version of zero is equivalent to an unversioned
+// request.
+func WithAPIVersion(parent context.Context, version uint) context.Context {
+	return context.WithValue(parent, versionKey, version)
+}
+
+// APIVersion returns the API version from ctx, or zero if none is present.
+func APIVersion(ctx context.Context) uint {
+	version, _ := ctx.Value(versionKey).(uint)
+	return version
+}
+
+type versionKeyType struct{}
+
+var versionKey versionKeyType
+
+// WithGroup returns a new context derived from parent that restricts
+// load-balanced command requests to peers in the named group, as per
+// options.Group().
+//
+// It applies to Session.Call(), CallAsync() and Execute(). If no peer in the
+// group is known to be listening to the namespace, the request fails. Use
+// WithPreferredGroup() to allow requests to be sent to any peer in that case.
+//
+// Group membership is learned from presence announcements, so a peer that has
+// only just started may not yet know of the peers in the group.
+func WithGroup(parent context.Context, group string) context.Context {
+	return context.WithValue(parent, groupKey, groupRouting{group, true})
+}
+
+// WithPreferredGroup returns a new context derived from parent that prefers
+// to send load-balanced command requests to peers in the named group.
+//
+// It behaves as per WithGroup(), except that if no peer in the group is known
+// to be listening to the namespace, the request is load-balanced as usual.
+func WithPreferredGroup(parent context.Context, group string) context.Context {
+	return context.WithValue(parent, groupKey, groupRouting{group, false})
+}
+
+// Group returns the group that ctx restricts command requests to. strict is
+// true if the context was created by WithGroup(), or false if it was created
+// by WithPreferredGroup(). group is empty if neither applies.
+func Group(ctx context.Context) (group string, strict bool) {
+	r, _ := ctx.Value(groupKey).(groupRouting)
+	return r.Group, r.Strict
+}
+
+type groupRouting struct {
+	Group  string
+	Strict bool
+}
+
+type groupKeyType struct{}
+
+var groupKey groupKeyType
@@ -0,0 +1,40 @@
+//go:build go1.18
+// +build go1.18
+
+package rinq
+
+import "context"
+
+// CallAs sends a command request to the next available peer listening to the
+// ns namespace and waits for a response, as per Session.Call().
+//
+// req is encoded as the request payload, and the response payload is decoded
+// into a value of type Resp. All payloads are closed before CallAs returns,
+// including the payload of a Failure, so commands that return failures with
+// payloads should be called with Session.Call() directly.
+//
+// If the call fails, or the response payload can not be decoded, err is
+// non-nil and resp is the zero-value of Resp.
+func CallAs[Req, Resp any](
+	ctx context.Context,
+	sess Session,
+	ns, cmd string,
+	req Req,
+) (resp Resp, err error) {
+	out := NewPayload(req)
+	defer out.Close()
+
+	in, err := sess.Call(ctx, ns, cmd, out)
+	defer in.Close()
+
+	if err == nil {
+		err = in.Decode(&resp)
+	}
+
+	if err != nil {
+		var zero Resp
+		return zero, err
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,74 @@
+//go:build go1.18
+// +build go1.18
+
+package rinq_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+var _ = Describe("CallAs", func() {
+	type request struct {
+		A, B int
+	}
+
+	It("encodes the request and decodes the response", func() {
+		sess := &callSession{
+			call: func(ns, cmd string, out *rinq.Payload) (*rinq.Payload, error) {
+				Expect(ns).To(Equal("ns"))
+				Expect(cmd).To(Equal("cmd"))
+
+				var req request
+				Expect(out.Decode(&req)).To(Succeed())
+
+				return rinq.NewPayload(req.A + req.B), nil
+			},
+		}
+
+		resp, err := rinq.CallAs[request, int](context.Background(), sess, "ns", "cmd", request{1, 2})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(resp).To(Equal(3))
+	})
+
+	It("returns the error from the call", func() {
+		expected := errors.New("<error>")
+		sess := &callSession{
+			call: func(string, string, *rinq.Payload) (*rinq.Payload, error) {
+				return nil, expected
+			},
+		}
+
+		_, err := rinq.CallAs[int, int](context.Background(), sess, "ns", "cmd", 1)
+
+		Expect(err).To(Equal(expected))
+	})
+
+	It("returns an error if the response can not be decoded", func() {
+		sess := &callSession{
+			call: func(string, string, *rinq.Payload) (*rinq.Payload, error) {
+				return rinq.NewPayload("<string>"), nil
+			},
+		}
+
+		resp, err := rinq.CallAs[int, int](context.Background(), sess, "ns", "cmd", 1)
+
+		Expect(err).To(HaveOccurred())
+		Expect(resp).To(BeZero())
+	})
+})
+
+// callSession is a rinq.Session that handles calls with a function.
+type callSession struct {
+	rinq.Session
+	call func(ns, cmd string, out *rinq.Payload) (*rinq.Payload, error)
+}
+
+func (s *callSession) Call(_ context.Context, ns, cmd string, out *rinq.Payload) (*rinq.Payload, error) {
+	return s.call(ns, cmd, out)
+}
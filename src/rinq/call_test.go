@@ -0,0 +1,102 @@
+package rinq_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/rinq/rinq-go/src/rinq"
+)
+
+var _ = Describe("WithHedging", func() {
+	It("returns a context containing the hedging delay", func() {
+		ctx := WithHedging(context.Background(), 50*time.Millisecond)
+
+		delay, ok := HedgeDelay(ctx)
+
+		Expect(ok).To(BeTrue())
+		Expect(delay).To(Equal(50 * time.Millisecond))
+	})
+})
+
+var _ = Describe("WithAPIVersion", func() {
+	It("returns a context containing the API version", func() {
+		ctx := WithAPIVersion(context.Background(), 2)
+
+		Expect(APIVersion(ctx)).To(Equal(uint(2)))
+	})
+})
+
+var _ = Describe("APIVersion", func() {
+	It("returns zero if the context does not contain a version", func() {
+		Expect(APIVersion(context.Background())).To(Equal(uint(0)))
+	})
+})
+
+var _ = Describe("HedgeDelay", func() {
+	It("returns false if the context does not enable hedging", func() {
+		_, ok := HedgeDelay(context.Background())
+
+		Expect(ok).To(BeFalse())
+	})
+})
+
+var _ = Describe("Budget", func() {
+	It("returns the time remaining before the deadline", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		budget, ok := Budget(ctx)
+
+		Expect(ok).To(BeTrue())
+		Expect(budget).To(BeNumerically("~", 10*time.Second, 100*time.Millisecond))
+	})
+
+	It("returns zero if the deadline has passed", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), -1)
+		defer cancel()
+
+		budget, ok := Budget(ctx)
+
+		Expect(ok).To(BeTrue())
+		Expect(budget).To(BeZero())
+	})
+
+	It("returns false if the context has no deadline", func() {
+		_, ok := Budget(context.Background())
+
+		Expect(ok).To(BeFalse())
+	})
+})
+
+var _ = Describe("WithGroup", func() {
+	It("returns a context with a strict group", func() {
+		ctx := WithGroup(context.Background(), "region=eu-west")
+
+		group, strict := Group(ctx)
+
+		Expect(group).To(Equal("region=eu-west"))
+		Expect(strict).To(BeTrue())
+	})
+})
+
+var _ = Describe("WithPreferredGroup", func() {
+	It("returns a context with a preferred group", func() {
+		ctx := WithPreferredGroup(context.Background(), "region=eu-west")
+
+		group, strict := Group(ctx)
+
+		Expect(group).To(Equal("region=eu-west"))
+		Expect(strict).To(BeFalse())
+	})
+})
+
+var _ = Describe("Group", func() {
+	It("returns an empty group if none is present", func() {
+		group, strict := Group(context.Background())
+
+		Expect(group).To(BeEmpty())
+		Expect(strict).To(BeFalse())
+	})
+})
@@ -0,0 +1,58 @@
+package rinq
+
+// ProtocolRevision is the revision of the wire protocol implemented by this
+// version of Rinq. It is incremented when a change is made that older peers
+// can not safely ignore.
+const ProtocolRevision = 1
+
+const (
+	// FeatureBaggage indicates that a peer restores baggage in the context
+	// passed to handlers. See WithBaggage().
+	FeatureBaggage = "baggage"
+
+	// FeatureHeaders indicates that a peer exposes application-defined headers
+	// to handlers. See WithHeader().
+	FeatureHeaders = "headers"
+
+	// FeatureNotifyReply indicates that a peer supports replies to
+	// notifications sent with Session.NotifyAndWait().
+	FeatureNotifyReply = "notify-reply"
+
+	// FeatureBatchFetch indicates that a peer can return the state of several
+	// of its sessions in response to a single request.
+	FeatureBatchFetch = "batch-fetch"
+)
+
+// Features is the list of optional wire features supported by this version
+// of Rinq, as advertised to other peers.
+var Features = []string{
+	FeatureBaggage,
+	FeatureHeaders,
+	FeatureNotifyReply,
+	FeatureBatchFetch,
+}
+
+// PeerCapabilities describes the wire protocol features supported by a peer,
+// as returned by Peer.Capabilities().
+//
+// It allows peers in a fleet running mixed versions of Rinq to determine
+// whether a feature can be used with a particular peer before enabling it.
+type PeerCapabilities struct {
+	// Revision is the peer's protocol revision. It is zero for peers that
+	// predate capability advertisement.
+	Revision uint
+
+	// Features is the list of optional features that the peer supports.
+	Features []string
+}
+
+// Supports returns true if the peer supports the given feature.
+func (c PeerCapabilities) Supports(feature string) bool {
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,24 @@
+package rinq_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+var _ = Describe("PeerCapabilities", func() {
+	Describe("Supports", func() {
+		subject := rinq.PeerCapabilities{
+			Revision: rinq.ProtocolRevision,
+			Features: []string{rinq.FeatureBaggage},
+		}
+
+		It("returns true if the feature is listed", func() {
+			Expect(subject.Supports(rinq.FeatureBaggage)).To(BeTrue())
+		})
+
+		It("returns false if the feature is not listed", func() {
+			Expect(subject.Supports(rinq.FeatureHeaders)).To(BeFalse())
+		})
+	})
+})
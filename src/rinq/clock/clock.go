@@ -0,0 +1,43 @@
+package clock
+
+import "time"
+
+// Clock is an interface for reading the current time and scheduling work to
+// occur after a delay.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel that receives the current time once d has
+	// elapsed.
+	After(d time.Duration) <-chan time.Time
+
+	// AfterFunc calls fn once d has elapsed. The returned timer can be used to
+	// cancel the call.
+	AfterFunc(d time.Duration, fn func()) Timer
+}
+
+// Timer is a pending call scheduled by Clock.AfterFunc().
+type Timer interface {
+	// Stop prevents the timer from firing. It returns false if the timer has
+	// already fired or been stopped.
+	Stop() bool
+}
+
+// System is a Clock that uses the system time, as provided by the time
+// package.
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (systemClock) AfterFunc(d time.Duration, fn func()) Timer {
+	return time.AfterFunc(d, fn)
+}
@@ -0,0 +1,117 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Manual is a Clock that only advances when instructed to do so.
+//
+// Timers created by a manual clock fire only when Advance() is called, even if
+// their delay is zero or negative.
+type Manual struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+// NewManual returns a manual clock with its current time set to now.
+func NewManual(now time.Time) *Manual {
+	return &Manual{now: now}
+}
+
+// Now returns the clock's current time.
+func (c *Manual) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// After returns a channel that receives the clock's current time once it has
+// been advanced by at least d.
+func (c *Manual) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.schedule(d, func(t time.Time) { ch <- t })
+	return ch
+}
+
+// AfterFunc calls fn once the clock has been advanced by at least d.
+func (c *Manual) AfterFunc(d time.Duration, fn func()) Timer {
+	return c.schedule(d, func(time.Time) { fn() })
+}
+
+// Advance moves the clock forward by d and fires any timers that are due, in
+// the order of their expiry. Functions passed to AfterFunc() are called
+// before Advance() returns.
+func (c *Manual) Advance(d time.Duration) {
+	c.mutex.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+
+	var due []*manualTimer
+	n := 0
+	for _, t := range c.timers {
+		if t.expiresAt.After(now) {
+			c.timers[n] = t
+			n++
+		} else {
+			t.fired = true
+			due = append(due, t)
+		}
+	}
+	c.timers = c.timers[:n]
+	c.mutex.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].expiresAt.Before(due[j].expiresAt)
+	})
+
+	for _, t := range due {
+		t.fn(now)
+	}
+}
+
+func (c *Manual) schedule(d time.Duration, fn func(time.Time)) *manualTimer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t := &manualTimer{
+		clock:     c,
+		expiresAt: c.now.Add(d),
+		fn:        fn,
+	}
+	c.timers = append(c.timers, t)
+
+	return t
+}
+
+// manualTimer is a Timer created by a Manual clock.
+type manualTimer struct {
+	clock     *Manual
+	expiresAt time.Time
+	fn        func(time.Time)
+	fired     bool // guarded by clock.mutex
+}
+
+func (t *manualTimer) Stop() bool {
+	c := t.clock
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if t.fired {
+		return false
+	}
+
+	for i, x := range c.timers {
+		if x == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			t.fired = true
+			return true
+		}
+	}
+
+	return false
+}
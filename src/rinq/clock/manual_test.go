@@ -0,0 +1,90 @@
+package clock_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/rinq/rinq-go/src/rinq/clock"
+)
+
+var _ = Describe("Manual", func() {
+	var (
+		epoch time.Time
+		clock *Manual
+	)
+
+	BeforeEach(func() {
+		epoch = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		clock = NewManual(epoch)
+	})
+
+	Describe("Now", func() {
+		It("returns the time the clock was created with", func() {
+			Expect(clock.Now()).To(Equal(epoch))
+		})
+
+		It("returns the advanced time", func() {
+			clock.Advance(time.Minute)
+
+			Expect(clock.Now()).To(Equal(epoch.Add(time.Minute)))
+		})
+	})
+
+	Describe("After", func() {
+		It("does not send until the clock has advanced by the delay", func() {
+			ch := clock.After(time.Minute)
+
+			clock.Advance(59 * time.Second)
+			Expect(ch).NotTo(Receive())
+
+			clock.Advance(time.Second)
+			Expect(ch).To(Receive(Equal(epoch.Add(time.Minute))))
+		})
+	})
+
+	Describe("AfterFunc", func() {
+		It("calls the function in order of expiry", func() {
+			var calls []int
+
+			clock.AfterFunc(2*time.Second, func() { calls = append(calls, 2) })
+			clock.AfterFunc(1*time.Second, func() { calls = append(calls, 1) })
+			clock.AfterFunc(3*time.Second, func() { calls = append(calls, 3) })
+
+			clock.Advance(2 * time.Second)
+			Expect(calls).To(Equal([]int{1, 2}))
+
+			clock.Advance(time.Second)
+			Expect(calls).To(Equal([]int{1, 2, 3}))
+		})
+
+		It("does not call the function until the clock is advanced", func() {
+			called := false
+			clock.AfterFunc(0, func() { called = true })
+
+			Expect(called).To(BeFalse())
+
+			clock.Advance(0)
+			Expect(called).To(BeTrue())
+		})
+	})
+
+	Describe("Stop", func() {
+		It("prevents the timer from firing", func() {
+			called := false
+			t := clock.AfterFunc(time.Second, func() { called = true })
+
+			Expect(t.Stop()).To(BeTrue())
+			clock.Advance(time.Second)
+
+			Expect(called).To(BeFalse())
+		})
+
+		It("returns false if the timer has already fired", func() {
+			t := clock.AfterFunc(time.Second, func() {})
+			clock.Advance(time.Second)
+
+			Expect(t.Stop()).To(BeFalse())
+		})
+	})
+})
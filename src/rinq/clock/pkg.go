@@ -0,0 +1,6 @@
+// Package clock provides an abstraction of the passage of time, used by a peer
+// for cache expiry, presence expiry and call deadlines.
+//
+// A Clock is configured by passing options.Clock() when creating a peer. The
+// Manual clock allows tests and simulations to advance time deterministically.
+package clock
@@ -2,6 +2,7 @@ package rinq
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/rinq/rinq-go/src/rinq/ident"
@@ -17,8 +18,19 @@ import (
 // res.Close(); otherwise the request may be redelivered, possibly to a
 // different peer.
 //
+// The request is acknowledged to the broker once the handler has returned,
+// rather than when it is received. If the peer's connection is lost while the
+// handler is running, such as when the process crashes, load-balanced requests
+// are redelivered to another peer that is listening to the namespace. Use
+// options.ReplayWindow() to avoid invoking the handler a second time for
+// requests that had already been responded to.
+//
 // The handler is responsible for closing req.Payload, however there is no
 // requirement that the payload be closed during the execution of the handler.
+//
+// ctx contains the trace ID, the ID of the receiving peer and a reference to
+// the source session. Use trace.Logger() to include these values in log
+// messages produced by the handler.
 type CommandHandler func(
 	ctx context.Context,
 	req Request,
@@ -42,13 +54,66 @@ type Request struct {
 	// command is logged for each request.
 	Command string
 
+	// Version is the API version of the namespace that the request was sent
+	// to. A value of zero indicates an unversioned request.
+	Version uint
+
 	// Payload contains optional application-defined information about the
 	// request, such as arguments to the command. The handler that accepts the
 	// request is responsible for closing the payload, however there is no
 	// requirement that the payload be closed during the execution of the handler.
 	Payload *Payload
+
+	// Headers contains the application-defined headers sent with the
+	// request, as per WithHeader(). It is nil if there are no headers.
+	Headers map[string]string
 }
 
+// CallSource sends a command request to the peer that owns the source session
+// and waits for a response.
+//
+// It allows a command handler to invoke a command provided by the caller's
+// peer, such as a callback, while the original request is still being handled.
+// The owning peer must be listening to the ns namespace. Unlike Session.Call(),
+// the request is never delivered to any other peer.
+//
+// ctx must be the context passed to the command handler, or derived from it.
+// The source of the callback request, as seen by the handler on the owning
+// peer, is not a session that can be queried or modified.
+//
+// The return values are the same as for Session.Call().
+func (r Request) CallSource(ctx context.Context, ns, cmd string, out *Payload) (*Payload, error) {
+	c, ok := ctx.Value(peerCallerKey).(PeerCaller)
+	if !ok {
+		return nil, errors.New("context was not passed to a command handler")
+	}
+
+	return c(ctx, r.Source.SessionID().Peer, ns, cmd, out)
+}
+
+// PeerCaller is a function that sends a command request to a specific peer and
+// waits for a response. It is used to implement Request.CallSource().
+type PeerCaller func(
+	ctx context.Context,
+	target ident.PeerID,
+	ns, cmd string,
+	out *Payload,
+) (*Payload, error)
+
+// WithPeerCaller returns a new context derived from parent that uses c to send
+// the command requests made by Request.CallSource().
+//
+// Peers add a PeerCaller to the context passed to each command handler, so
+// applications only need to call WithPeerCaller() when invoking a handler
+// directly, such as in tests.
+func WithPeerCaller(parent context.Context, c PeerCaller) context.Context {
+	return context.WithValue(parent, peerCallerKey, c)
+}
+
+type peerCallerKeyType struct{}
+
+var peerCallerKey peerCallerKeyType
+
 // Response sends a reply to incoming command requests.
 type Response interface {
 	// IsRequired returns true if the sender is waiting for the response.
@@ -96,6 +161,10 @@ type Response interface {
 //
 // Failures can be produced in a command handler by calling Response.Fail() or
 // passing a Failure value to Response.Error().
+//
+// Failures can be matched with errors.Is() by comparing them to a Failure that
+// has only a type, only a code, or both, such as
+// errors.Is(err, rinq.Failure{Type: "out-of-stock"}).
 type Failure struct {
 	// Type is an application-defined string identifying the failure.
 	// They serve the same purpose as an error code. They should be concise
@@ -107,12 +176,54 @@ type Failure struct {
 
 	// Payload is an optional application-defined payload.
 	Payload *Payload
+
+	// Code is an optional application-defined numeric error code. It allows
+	// failures of different types to be grouped into categories.
+	Code int
+
+	// Fields is an optional set of application-defined key/value pairs that
+	// describe the failure, such as the name of an invalid argument. Unlike
+	// the payload, fields are available without decoding.
+	Fields map[string]string
 }
 
+// InternalErrorFailureType is the failure type sent to the caller when a
+// command handler panics before responding. The failure message contains the
+// trace ID of the request.
+const InternalErrorFailureType = "internal-error"
+
+// HandlerTimeoutFailureType is the failure type sent to the caller when a
+// command handler does not respond within the maximum execution time set for
+// its namespace by options.HandlerTimeout().
+const HandlerTimeoutFailureType = "handler-timeout"
+
 func (err Failure) Error() string {
 	return fmt.Sprintf("%s: %s", err.Type, err.Message)
 }
 
+// Is returns true if target is ErrFailure, or a Failure with the same type and
+// code as err. The type and code are only compared if they are set in target,
+// so that failures can be matched by either one. A target with neither a type
+// nor a code never matches.
+//
+// It allows failures to be matched using errors.Is().
+func (err Failure) Is(target error) bool {
+	if target == ErrFailure {
+		return true
+	}
+
+	t, ok := target.(Failure)
+	if !ok || t.Type == "" && t.Code == 0 {
+		return false
+	}
+
+	if t.Type != "" && t.Type != err.Type {
+		return false
+	}
+
+	return t.Code == 0 || t.Code == err.Code
+}
+
 // IsFailure returns true if err is a Failure.
 func IsFailure(err error) bool {
 	_, ok := err.(Failure)
@@ -140,6 +251,39 @@ func FailureType(err error) string {
 	return f.Type
 }
 
+// FailureCode returns the failure code of err; or zero if err is not a Failure,
+// or is a Failure without a code.
+func FailureCode(err error) int {
+	f, _ := err.(Failure)
+	return f.Code
+}
+
+// FailureAs decodes the payload of err into target and closes the payload, if
+// err is a Failure. It simplifies the handling of failures that carry
+// application-defined details in their payload, for example:
+//
+//	in, err := sess.Call(ctx, "ns", "cmd", out)
+//	defer in.Close()
+//
+//	var details OutOfStockDetails
+//	if ok, _ := rinq.FailureAs(err, &details); ok {
+//	    ...
+//	}
+//
+// ok is false if err is not a Failure, in which case target is left unchanged.
+// Otherwise, derr is the error that occurred while decoding the payload, if
+// any.
+func FailureAs(err error, target interface{}) (ok bool, derr error) {
+	f, ok := err.(Failure)
+	if !ok {
+		return false, nil
+	}
+
+	defer f.Payload.Close()
+
+	return true, f.Payload.Decode(target)
+}
+
 // IsCommandError returns true if err was sent in response to a command request,
 // as opposed to a local error that occurred when attempting to send the request.
 func IsCommandError(err error) bool {
@@ -162,3 +306,8 @@ func (err CommandError) Error() string {
 
 	return string(err)
 }
+
+// Is returns true if target is ErrCommandError.
+func (err CommandError) Is(target error) bool {
+	return target == ErrCommandError
+}
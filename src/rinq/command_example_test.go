@@ -33,7 +33,10 @@ func ExampleResponse_fail() {
 		)
 	})
 
-	sess := peer.Session()
+	sess, err := peer.Session()
+	if err != nil {
+		panic(err)
+	}
 	defer sess.Destroy()
 
 	in, err := sess.Call(context.Background(), "my-api", "test", nil)
@@ -0,0 +1,149 @@
+package rinq
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// UnknownCommandFailureType is the failure type produced by a CommandMux when it
+// receives a request for a command that has no handler.
+const UnknownCommandFailureType = "unknown-command"
+
+// CommandMux is a command handler that dispatches each request to the handler
+// registered for its command name.
+//
+// A mux's commands are included in the catalog returned by Peer.Commands()
+// when it is passed to Peer.ListenMux(), whereas only the namespace of a plain
+// command handler is known to the peer.
+type CommandMux struct {
+	mutex    sync.RWMutex
+	commands map[string]muxEntry
+}
+
+// muxEntry is a command registered with a CommandMux.
+type muxEntry struct {
+	handler      CommandHandler
+	schema       CommandSchema
+	registeredAt time.Time
+}
+
+// CommandSchema contains optional hints describing the payloads of a command.
+//
+// The hints are not interpreted by Rinq. They may be any application-defined
+// description, such as a JSON schema or the name of a type.
+type CommandSchema struct {
+	// Request describes the request payload.
+	Request string
+
+	// Response describes the response payload, or the failure payload.
+	Response string
+}
+
+// CommandInfo describes a command handled by a peer, as returned by
+// Peer.Commands().
+type CommandInfo struct {
+	// Namespace is the namespace that the command belongs to.
+	Namespace string
+
+	// Version is the API version of the namespace.
+	Version uint
+
+	// Command is the command name. It is empty if the namespace's handler is
+	// not a CommandMux, in which case the commands it accepts are not known.
+	Command string
+
+	// Schema contains the hints registered with the command, if any.
+	Schema CommandSchema
+
+	// RegisteredAt is the time at which the command became available, that is
+	// the later of the time it was registered with the mux and the time the
+	// peer started listening to the namespace.
+	RegisteredAt time.Time
+}
+
+// NewCommandMux returns a new, empty command mux.
+func NewCommandMux() *CommandMux {
+	return &CommandMux{
+		commands: map[string]muxEntry{},
+	}
+}
+
+// Handle registers h as the handler for the cmd command.
+//
+// Repeated calls with the same command replace the existing handler.
+func (m *CommandMux) Handle(cmd string, h CommandHandler) {
+	m.HandleSchema(cmd, CommandSchema{}, h)
+}
+
+// HandleSchema registers h as the handler for the cmd command, along with
+// hints describing its payloads.
+//
+// Repeated calls with the same command replace the existing handler.
+func (m *CommandMux) HandleSchema(cmd string, s CommandSchema, h CommandHandler) {
+	if err := ValidateCommand(cmd); err != nil {
+		panic(err)
+	} else if h == nil {
+		panic("handler must not be nil")
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.commands[cmd] = muxEntry{h, s, time.Now()}
+}
+
+// Remove removes the handler for the cmd command, if any.
+func (m *CommandMux) Remove(cmd string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.commands, cmd)
+}
+
+// Serve dispatches req to the handler registered for its command. It has the
+// signature of a CommandHandler, so a mux can be passed to Peer.Listen().
+//
+// If there is no handler for the command, it responds with an
+// UnknownCommandFailureType.
+func (m *CommandMux) Serve(ctx context.Context, req Request, res Response) {
+	m.mutex.RLock()
+	e, ok := m.commands[req.Command]
+	m.mutex.RUnlock()
+
+	if ok {
+		e.handler(ctx, req, res)
+		return
+	}
+
+	req.Payload.Close()
+	res.Fail(
+		UnknownCommandFailureType,
+		"'%s::%s' command is not supported",
+		req.Namespace,
+		req.Command,
+	)
+}
+
+// Commands returns the commands registered with the mux, ordered by name. The
+// Namespace and Version fields of each are empty.
+func (m *CommandMux) Commands() []CommandInfo {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	commands := make([]CommandInfo, 0, len(m.commands))
+	for cmd, e := range m.commands {
+		commands = append(commands, CommandInfo{
+			Command:      cmd,
+			Schema:       e.schema,
+			RegisteredAt: e.registeredAt,
+		})
+	}
+
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Command < commands[j].Command
+	})
+
+	return commands
+}
@@ -0,0 +1,136 @@
+package rinq_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+var _ = Describe("CommandMux", func() {
+	var (
+		subject *rinq.CommandMux
+		res     *fakeResponse
+	)
+
+	BeforeEach(func() {
+		subject = rinq.NewCommandMux()
+		res = &fakeResponse{}
+	})
+
+	Describe("Serve", func() {
+		It("dispatches the request to the handler for its command", func() {
+			var called string
+			handler := func(cmd string) rinq.CommandHandler {
+				return func(_ context.Context, _ rinq.Request, res rinq.Response) {
+					called = cmd
+					res.Close()
+				}
+			}
+
+			subject.Handle("a", handler("a"))
+			subject.Handle("b", handler("b"))
+
+			subject.Serve(context.Background(), rinq.Request{Command: "b"}, res)
+
+			Expect(called).To(Equal("b"))
+		})
+
+		It("fails if there is no handler for the command", func() {
+			subject.Serve(
+				context.Background(),
+				rinq.Request{Namespace: "ns", Command: "cmd"},
+				res,
+			)
+
+			Expect(rinq.IsFailureType(rinq.UnknownCommandFailureType, res.err)).To(BeTrue())
+		})
+
+		It("does not dispatch to a removed handler", func() {
+			subject.Handle("cmd", func(context.Context, rinq.Request, rinq.Response) {
+				Fail("unexpected call")
+			})
+			subject.Remove("cmd")
+
+			subject.Serve(context.Background(), rinq.Request{Command: "cmd"}, res)
+
+			Expect(rinq.IsFailureType(rinq.UnknownCommandFailureType, res.err)).To(BeTrue())
+		})
+	})
+
+	Describe("Commands", func() {
+		It("returns the registered commands in order", func() {
+			h := func(context.Context, rinq.Request, rinq.Response) {}
+			schema := rinq.CommandSchema{Request: "<req>", Response: "<res>"}
+
+			subject.Handle("b", h)
+			subject.HandleSchema("a", schema, h)
+
+			commands := subject.Commands()
+
+			Expect(commands).To(HaveLen(2))
+			Expect(commands[0].Command).To(Equal("a"))
+			Expect(commands[0].Schema).To(Equal(schema))
+			Expect(commands[0].RegisteredAt).NotTo(BeZero())
+			Expect(commands[1].Command).To(Equal("b"))
+			Expect(commands[1].Schema).To(BeZero())
+		})
+	})
+
+	Describe("HandleSchema", func() {
+		It("panics if the command is empty", func() {
+			Expect(func() {
+				subject.Handle("", func(context.Context, rinq.Request, rinq.Response) {})
+			}).To(Panic())
+		})
+
+		It("panics if the handler is nil", func() {
+			Expect(func() {
+				subject.Handle("cmd", nil)
+			}).To(Panic())
+		})
+	})
+})
+
+// fakeResponse is a rinq.Response that records the value of the payload or the
+// error that it is closed with.
+type fakeResponse struct {
+	closed  bool
+	payload interface{}
+	err     error
+}
+
+func (r *fakeResponse) IsRequired() bool {
+	return true
+}
+
+func (r *fakeResponse) IsClosed() bool {
+	return r.closed
+}
+
+func (r *fakeResponse) Done(p *rinq.Payload) {
+	r.closed = true
+	r.payload = p.Value()
+}
+
+func (r *fakeResponse) Error(err error) {
+	r.closed = true
+	r.err = err
+}
+
+func (r *fakeResponse) Fail(t, f string, v ...interface{}) rinq.Failure {
+	err := rinq.Failure{Type: t, Message: fmt.Sprintf(f, v...)}
+	r.Error(err)
+	return err
+}
+
+func (r *fakeResponse) Close() bool {
+	if r.closed {
+		return false
+	}
+
+	r.closed = true
+	return true
+}
@@ -1,13 +1,59 @@
 package rinq_test
 
 import (
+	"context"
 	"errors"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/internal/revisions"
 	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
 )
 
+var _ = Describe("Request", func() {
+	Describe("CallSource", func() {
+		sessID := ident.NewPeerID().Session(1)
+		req := rinq.Request{Source: revisions.Closed(sessID)}
+
+		It("calls the peer that owns the source session", func() {
+			expected := rinq.NewPayload(456)
+			defer expected.Close()
+
+			ctx := rinq.WithPeerCaller(
+				context.Background(),
+				func(
+					_ context.Context,
+					target ident.PeerID,
+					ns, cmd string,
+					out *rinq.Payload,
+				) (*rinq.Payload, error) {
+					Expect(target).To(Equal(sessID.Peer))
+					Expect(ns).To(Equal("ns"))
+					Expect(cmd).To(Equal("cmd"))
+					Expect(out.Value()).To(BeEquivalentTo(123))
+
+					return expected, nil
+				},
+			)
+
+			out := rinq.NewPayload(123)
+			defer out.Close()
+
+			in, err := req.CallSource(ctx, "ns", "cmd", out)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(in).To(Equal(expected))
+		})
+
+		It("returns an error if the context has no peer caller", func() {
+			_, err := req.CallSource(context.Background(), "ns", "cmd", nil)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
 var _ = Describe("Failure", func() {
 	Describe("Error", func() {
 		It("includes both the type and the message", func() {
@@ -15,6 +61,38 @@ var _ = Describe("Failure", func() {
 			Expect(err.Error()).To(Equal("<type>: <message>"))
 		})
 	})
+
+	Describe("Is", func() {
+		err := rinq.Failure{Type: "foo", Code: 404}
+
+		It("returns true for a failure with the same type", func() {
+			Expect(err.Is(rinq.Failure{Type: "foo"})).To(BeTrue())
+		})
+
+		It("returns true for a failure with the same code", func() {
+			Expect(err.Is(rinq.Failure{Code: 404})).To(BeTrue())
+		})
+
+		It("returns true for a failure with the same type and code", func() {
+			Expect(err.Is(rinq.Failure{Type: "foo", Code: 404})).To(BeTrue())
+		})
+
+		It("returns false for a failure with a different type", func() {
+			Expect(err.Is(rinq.Failure{Type: "bar", Code: 404})).To(BeFalse())
+		})
+
+		It("returns false for a failure with a different code", func() {
+			Expect(err.Is(rinq.Failure{Type: "foo", Code: 500})).To(BeFalse())
+		})
+
+		It("returns false for a failure with neither a type nor a code", func() {
+			Expect(err.Is(rinq.Failure{})).To(BeFalse())
+		})
+
+		It("returns false for other error types", func() {
+			Expect(err.Is(errors.New("foo"))).To(BeFalse())
+		})
+	})
 })
 
 var _ = Describe("IsFailure", func() {
@@ -72,6 +150,61 @@ var _ = Describe("FailureType", func() {
 	})
 })
 
+var _ = Describe("FailureCode", func() {
+	It("returns the failure code", func() {
+		r := rinq.FailureCode(rinq.Failure{Type: "foo", Code: 404})
+		Expect(r).To(Equal(404))
+	})
+
+	It("returns zero for other error types", func() {
+		r := rinq.FailureCode(errors.New(""))
+		Expect(r).To(Equal(0))
+	})
+})
+
+var _ = Describe("FailureAs", func() {
+	It("decodes the failure payload into the target", func() {
+		p := rinq.NewPayload(map[string]int{"available": 3})
+		err := rinq.Failure{Type: "out-of-stock", Payload: p}
+
+		var target map[string]int
+		ok, derr := rinq.FailureAs(err, &target)
+
+		Expect(ok).To(BeTrue())
+		Expect(derr).ShouldNot(HaveOccurred())
+		Expect(target).To(Equal(map[string]int{"available": 3}))
+	})
+
+	It("closes the failure payload", func() {
+		p := rinq.NewPayload(123)
+		err := rinq.Failure{Type: "foo", Payload: p}
+
+		var target int
+		_, _ = rinq.FailureAs(err, &target)
+
+		Expect(p.Value()).To(BeNil())
+	})
+
+	It("returns the decoding error if the payload can not be decoded", func() {
+		err := rinq.Failure{Type: "foo", Payload: rinq.NewPayload("<string>")}
+
+		var target int
+		ok, derr := rinq.FailureAs(err, &target)
+
+		Expect(ok).To(BeTrue())
+		Expect(derr).To(HaveOccurred())
+	})
+
+	It("returns false for other error types", func() {
+		target := 123
+		ok, derr := rinq.FailureAs(errors.New(""), &target)
+
+		Expect(ok).To(BeFalse())
+		Expect(derr).ShouldNot(HaveOccurred())
+		Expect(target).To(Equal(123))
+	})
+})
+
 var _ = Describe("IsCommandError", func() {
 	It("returns true for Failure", func() {
 		r := rinq.IsCommandError(rinq.Failure{Type: "foo"})
@@ -0,0 +1,74 @@
+package rinq
+
+import "context"
+
+// DeadlineStage describes how far a command request had progressed when the
+// caller's deadline was exceeded.
+type DeadlineStage int
+
+const (
+	// DeadlineStageUnknown indicates that the progress of the request is not
+	// known, such as when the request was handled by the calling peer itself.
+	DeadlineStageUnknown DeadlineStage = iota
+
+	// DeadlineStageNotConsumed indicates that the request was not consumed by
+	// any server, for example because no server was listening to the namespace
+	// or every server was busy.
+	DeadlineStageNotConsumed
+
+	// DeadlineStageHandling indicates that a server consumed the request, but
+	// the command handler was still running when the server's deadline passed.
+	DeadlineStageHandling
+
+	// DeadlineStageResponseLost indicates that a server consumed the request
+	// and the command handler returned before the server's deadline, but the
+	// response was not received by the caller.
+	DeadlineStageResponseLost
+)
+
+func (s DeadlineStage) String() string {
+	switch s {
+	case DeadlineStageNotConsumed:
+		return "request was never consumed"
+	case DeadlineStageHandling:
+		return "handler was still running"
+	case DeadlineStageResponseLost:
+		return "response was lost"
+	default:
+		return "progress unknown"
+	}
+}
+
+// DeadlineExceededError indicates that a command call did not complete before
+// its deadline. It is returned in place of context.DeadlineExceeded by peers
+// configured with options.DeadlineDiagnostics().
+type DeadlineExceededError struct {
+	Stage DeadlineStage
+}
+
+// IsDeadlineExceeded returns true if err is context.DeadlineExceeded or a
+// DeadlineExceededError.
+func IsDeadlineExceeded(err error) bool {
+	if err == context.DeadlineExceeded {
+		return true
+	}
+
+	_, ok := err.(DeadlineExceededError)
+	return ok
+}
+
+func (err DeadlineExceededError) Error() string {
+	return context.DeadlineExceeded.Error() + ": " + err.Stage.String()
+}
+
+// Is returns true if target is context.DeadlineExceeded, so that
+// errors.Is(err, context.DeadlineExceeded) behaves the same regardless of
+// whether options.DeadlineDiagnostics() is enabled.
+func (err DeadlineExceededError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// Timeout returns true, as per context.DeadlineExceeded.
+func (err DeadlineExceededError) Timeout() bool {
+	return true
+}
@@ -0,0 +1,33 @@
+package rinq_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+var _ = Describe("DeadlineExceededError", func() {
+	Describe("Error", func() {
+		It("describes the stage of the request", func() {
+			err := rinq.DeadlineExceededError{Stage: rinq.DeadlineStageHandling}
+			Expect(err.Error()).To(Equal("context deadline exceeded: handler was still running"))
+		})
+	})
+
+	Describe("IsDeadlineExceeded", func() {
+		It("returns true for deadline exceeded errors", func() {
+			Expect(rinq.IsDeadlineExceeded(rinq.DeadlineExceededError{})).To(BeTrue())
+		})
+
+		It("returns true for context.DeadlineExceeded", func() {
+			Expect(rinq.IsDeadlineExceeded(context.DeadlineExceeded)).To(BeTrue())
+		})
+
+		It("returns false for other error types", func() {
+			Expect(rinq.IsDeadlineExceeded(errors.New(""))).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,67 @@
+package dedupe
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// DefaultMemorySize is the number of IDs retained by a MemoryStore when no
+// size is specified.
+const DefaultMemorySize = 10000
+
+// MemoryStore is a Store that keeps the IDs of the most recently handled
+// requests in memory.
+//
+// As the store is not shared between peers, it only detects requests that are
+// redelivered to the same peer.
+type MemoryStore struct {
+	size uint
+
+	mutex sync.Mutex
+	ids   map[ident.MessageID]*list.Element
+	lru   *list.List // most recently seen at the front
+}
+
+// NewMemoryStore returns a new MemoryStore that retains up to size IDs. If
+// size is zero, DefaultMemorySize is used.
+func NewMemoryStore(size uint) *MemoryStore {
+	if size == 0 {
+		size = DefaultMemorySize
+	}
+
+	return &MemoryStore{
+		size: size,
+		ids:  map[ident.MessageID]*list.Element{},
+		lru:  list.New(),
+	}
+}
+
+// Seen implements Store.Seen()
+func (s *MemoryStore) Seen(id ident.MessageID) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, ok := s.ids[id]
+	return ok
+}
+
+// Mark implements Store.Mark()
+func (s *MemoryStore) Mark(id ident.MessageID) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if elem, ok := s.ids[id]; ok {
+		s.lru.MoveToFront(elem)
+		return
+	}
+
+	s.ids[id] = s.lru.PushFront(id)
+
+	if uint(s.lru.Len()) > s.size {
+		elem := s.lru.Back()
+		s.lru.Remove(elem)
+		delete(s.ids, elem.Value.(ident.MessageID))
+	}
+}
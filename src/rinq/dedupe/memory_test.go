@@ -0,0 +1,57 @@
+package dedupe_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/rinq/rinq-go/src/rinq/dedupe"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+var _ = Describe("MemoryStore", func() {
+	var (
+		store *MemoryStore
+		ids   []ident.MessageID
+	)
+
+	BeforeEach(func() {
+		store = NewMemoryStore(2)
+
+		ref := ident.NewPeerID().Session(1).At(0)
+		ids = []ident.MessageID{
+			ref.Message(1),
+			ref.Message(2),
+			ref.Message(3),
+		}
+	})
+
+	Describe("Seen", func() {
+		It("returns false if the ID has not been marked", func() {
+			Expect(store.Seen(ids[0])).To(BeFalse())
+		})
+
+		It("does not record the ID", func() {
+			store.Seen(ids[0])
+
+			Expect(store.Seen(ids[0])).To(BeFalse())
+		})
+
+		It("returns true if the ID has been marked", func() {
+			store.Mark(ids[0])
+
+			Expect(store.Seen(ids[0])).To(BeTrue())
+		})
+	})
+
+	Describe("Mark", func() {
+		It("forgets the least recently marked ID once the store is full", func() {
+			store.Mark(ids[0])
+			store.Mark(ids[1])
+			store.Mark(ids[0]) // ids[1] is now the least recently marked
+			store.Mark(ids[2])
+
+			Expect(store.Seen(ids[0])).To(BeTrue())
+			Expect(store.Seen(ids[1])).To(BeFalse())
+			Expect(store.Seen(ids[2])).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,7 @@
+// Package dedupe provides a mechanism for detecting command requests that are
+// delivered to a peer more than once.
+//
+// A Store is configured by passing options.DedupeStore() when creating a peer.
+// It is consulted before invoking the command handler for each request that is
+// executed in a namespace configured with options.AtLeastOnce().
+package dedupe
@@ -0,0 +1,27 @@
+package dedupe
+
+import "github.com/rinq/rinq-go/src/rinq/ident"
+
+// Store is an interface for recording the IDs of the command requests that
+// have been handled by a peer.
+//
+// A store may be shared by several peers, for example by backing it with a
+// database, so that a request that is redelivered to a different peer is also
+// detected.
+//
+// IDs are only recorded once the request has been handled and acknowledged.
+// A request that is redelivered because the peer stopped while its handler was
+// running is therefore handled again. Likewise, if the same request is
+// delivered to two peers at once, both may handle it.
+//
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Seen returns true if the request with the given ID has already been
+	// handled, in which case it is acknowledged without invoking the command
+	// handler.
+	Seen(id ident.MessageID) bool
+
+	// Mark records that the request with the given ID has been handled. It is
+	// called after the request has been acknowledged.
+	Mark(id ident.MessageID)
+}
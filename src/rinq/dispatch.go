@@ -0,0 +1,44 @@
+package rinq
+
+import (
+	"context"
+
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// DispatchReport describes the number of sessions that a single peer
+// dispatched a multicast notification to.
+//
+// Multicast notifications are matched against the attributes of each session
+// by the peers that receive them, so each of those peers reports separately.
+// Peers that have no sessions listening to the notification's namespace do not
+// receive the notification, and hence do not send a report.
+type DispatchReport struct {
+	// ID is the message ID of the notification.
+	ID ident.MessageID
+
+	// Peer is the ID of the peer that dispatched the notification.
+	Peer ident.PeerID
+
+	// Namespace and Type are the namespace and type of the notification.
+	Namespace string
+	Type      string
+
+	// Count is the number of the peer's sessions that the notification was
+	// dispatched to. It is zero if none of the peer's sessions that are
+	// listening to the namespace matched the notification's constraint.
+	Count int
+}
+
+// DispatchHandler is a callback-function invoked when a peer reports the number
+// of sessions that a notification sent with Session.NotifyMany() was
+// dispatched to.
+//
+// sess is the session that sent the notification. ctx contains the trace ID of
+// the notification, which can be used to correlate the report with the call to
+// NotifyMany().
+type DispatchHandler func(
+	ctx context.Context,
+	sess Session,
+	r DispatchReport,
+)
@@ -0,0 +1,41 @@
+package rinq
+
+import "errors"
+
+// Sentinel errors that match the error types of the same name when compared
+// using errors.Is(). They allow callers to test for a category of error
+// without a type assertion, for example errors.Is(err, rinq.ErrNotFound).
+//
+// Use errors.As() to obtain the details of the error, such as the ID of the
+// session that was not found.
+var (
+	// ErrNotFound matches any NotFoundError.
+	ErrNotFound = errors.New("session not found")
+
+	// ErrStaleFetch matches any StaleFetchError.
+	ErrStaleFetch = errors.New("stale fetch")
+
+	// ErrStaleUpdate matches any StaleUpdateError.
+	ErrStaleUpdate = errors.New("stale update")
+
+	// ErrFrozenAttributes matches any FrozenAttributesError.
+	ErrFrozenAttributes = errors.New("frozen attributes")
+
+	// ErrQuotaExceeded matches any QuotaExceededError.
+	ErrQuotaExceeded = errors.New("attribute quota exceeded")
+
+	// ErrLinkClosed matches any LinkClosedError.
+	ErrLinkClosed = errors.New("link closed")
+
+	// ErrPeerStopped matches any PeerStoppedError.
+	ErrPeerStopped = errors.New("peer stopped")
+
+	// ErrSessionLimit matches any SessionLimitError.
+	ErrSessionLimit = errors.New("session limit exceeded")
+
+	// ErrFailure matches any Failure.
+	ErrFailure = errors.New("command failure")
+
+	// ErrCommandError matches any CommandError.
+	ErrCommandError = errors.New("command error")
+)
@@ -0,0 +1,54 @@
+package rinq_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+// matcher is an error that can be compared to a target error, as per
+// errors.Is().
+type matcher interface {
+	error
+	Is(target error) bool
+}
+
+var _ = Describe("sentinel errors", func() {
+	DescribeTable(
+		"are matched by the corresponding error type",
+		func(err matcher, sentinel error) {
+			Expect(err.Is(sentinel)).To(BeTrue())
+			Expect(err.Is(errors.New(sentinel.Error()))).To(BeFalse())
+		},
+		Entry("NotFoundError", rinq.NotFoundError{}, rinq.ErrNotFound),
+		Entry("StaleFetchError", rinq.StaleFetchError{}, rinq.ErrStaleFetch),
+		Entry("StaleUpdateError", rinq.StaleUpdateError{}, rinq.ErrStaleUpdate),
+		Entry("FrozenAttributesError", rinq.FrozenAttributesError{}, rinq.ErrFrozenAttributes),
+		Entry("QuotaExceededError", rinq.QuotaExceededError{}, rinq.ErrQuotaExceeded),
+		Entry("LinkClosedError", rinq.LinkClosedError{}, rinq.ErrLinkClosed),
+		Entry("PeerStoppedError", rinq.PeerStoppedError{}, rinq.ErrPeerStopped),
+		Entry("SessionLimitError", rinq.SessionLimitError{}, rinq.ErrSessionLimit),
+		Entry("Failure", rinq.Failure{Type: "foo"}, rinq.ErrFailure),
+		Entry("CommandError", rinq.CommandError("foo"), rinq.ErrCommandError),
+		Entry("DeadlineExceededError", rinq.DeadlineExceededError{}, context.DeadlineExceeded),
+	)
+
+	It("are not matched by other error types", func() {
+		Expect(rinq.NotFoundError{}.Is(rinq.ErrStaleUpdate)).To(BeFalse())
+	})
+})
+
+var _ = Describe("PeerStoppedError", func() {
+	Describe("Unwrap", func() {
+		It("returns the cause", func() {
+			cause := errors.New("<cause>")
+			err := rinq.PeerStoppedError{Cause: cause}
+
+			Expect(err.Unwrap()).To(Equal(cause))
+		})
+	})
+})
@@ -0,0 +1,36 @@
+package rinq
+
+import "github.com/rinq/rinq-go/src/rinq/ident"
+
+// Future is the result of a command call made with Session.CallFuture(), which
+// may not yet be available.
+//
+// Futures allow several calls to be made concurrently, and their results to be
+// collected once they are available, without starting a goroutine for each
+// call.
+type Future interface {
+	// ID returns the message ID of the command request.
+	ID() ident.MessageID
+
+	// Done returns a channel that is closed once the result is available.
+	Done() <-chan struct{}
+
+	// Result blocks until the result is available, then returns the response
+	// payload and error, as per Session.Call().
+	//
+	// The caller is responsible for closing in, even if err is non-nil. Result()
+	// returns the same values each time it is called, so the payload is only
+	// closed once.
+	//
+	// If IsNotFound(err) returns true, the session was destroyed before the
+	// response was received.
+	Result() (in *Payload, err error)
+
+	// Cancel stops waiting for the response.
+	//
+	// If the result is not yet available, Result() returns context.Canceled
+	// and any response received afterwards is discarded. The command request
+	// itself is not canceled, so the handler may still be invoked. Cancel()
+	// has no effect once the result is available.
+	Cancel()
+}
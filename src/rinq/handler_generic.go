@@ -0,0 +1,53 @@
+//go:build go1.18
+// +build go1.18
+
+package rinq
+
+import "context"
+
+// InvalidPayloadFailureType is the failure type sent to the caller when the
+// request payload can not be decoded by a handler created with HandlerFor().
+const InvalidPayloadFailureType = "invalid-payload"
+
+// HandlerFor returns a command handler that decodes the request payload into a
+// value of type Req and passes it to fn.
+//
+// If fn returns a nil error, its result is encoded as the response payload.
+// Otherwise, the error is sent to the caller via Response.Error(), so a
+// Failure returned by fn is received by the caller as that failure, and any
+// other error is received as an unexpected server-side error.
+//
+// If the request payload can not be decoded, fn is not invoked and the caller
+// receives an InvalidPayloadFailureType failure. The request payload is
+// always closed before fn is invoked.
+func HandlerFor[Req, Resp any](
+	fn func(ctx context.Context, req Request, v Req) (Resp, error),
+) CommandHandler {
+	return func(ctx context.Context, req Request, res Response) {
+		var v Req
+		err := req.Payload.Decode(&v)
+		req.Payload.Close()
+
+		if err != nil {
+			res.Fail(
+				InvalidPayloadFailureType,
+				"could not decode '%s::%s' request payload: %s",
+				req.Namespace,
+				req.Command,
+				err,
+			)
+			return
+		}
+
+		resp, err := fn(ctx, req, v)
+		if err != nil {
+			res.Error(err)
+			return
+		}
+
+		payload := NewPayload(resp)
+		defer payload.Close()
+
+		res.Done(payload)
+	}
+}
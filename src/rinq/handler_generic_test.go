@@ -0,0 +1,74 @@
+//go:build go1.18
+// +build go1.18
+
+package rinq_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+var _ = Describe("HandlerFor", func() {
+	var res *fakeResponse
+
+	BeforeEach(func() {
+		res = &fakeResponse{}
+	})
+
+	It("decodes the request and encodes the response", func() {
+		h := rinq.HandlerFor(func(_ context.Context, req rinq.Request, v int) (int, error) {
+			Expect(req.Command).To(Equal("cmd"))
+			return v * 2, nil
+		})
+
+		h(
+			context.Background(),
+			rinq.Request{Command: "cmd", Payload: rinq.NewPayload(21)},
+			res,
+		)
+
+		Expect(res.err).ShouldNot(HaveOccurred())
+		Expect(res.payload).To(BeEquivalentTo(42))
+	})
+
+	It("sends the error returned by the function", func() {
+		expected := rinq.Failure{Type: "<type>"}
+		h := rinq.HandlerFor(func(context.Context, rinq.Request, int) (int, error) {
+			return 0, expected
+		})
+
+		h(context.Background(), rinq.Request{}, res)
+
+		Expect(res.err).To(Equal(expected))
+	})
+
+	It("sends unexpected errors as-is", func() {
+		expected := errors.New("<error>")
+		h := rinq.HandlerFor(func(context.Context, rinq.Request, int) (int, error) {
+			return 0, expected
+		})
+
+		h(context.Background(), rinq.Request{}, res)
+
+		Expect(res.err).To(Equal(expected))
+	})
+
+	It("fails without invoking the function if the payload can not be decoded", func() {
+		h := rinq.HandlerFor(func(context.Context, rinq.Request, int) (int, error) {
+			Fail("unexpected call")
+			return 0, nil
+		})
+
+		h(
+			context.Background(),
+			rinq.Request{Payload: rinq.NewPayload("<string>")},
+			res,
+		)
+
+		Expect(rinq.IsFailureType(rinq.InvalidPayloadFailureType, res.err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,35 @@
+package rinq
+
+import "context"
+
+// WithHeader returns a new context derived from parent that adds an
+// application-defined header to the command requests and notifications sent
+// using the context.
+//
+// Headers allow metadata that is not part of a command's API, such as
+// authentication tokens, to be sent alongside the payload. They are available
+// to handlers as Request.Headers and Notification.Headers. Unlike baggage,
+// headers apply only to the requests made directly with the returned context;
+// they are not present in the context passed to the handler, and so are not
+// forwarded by any further requests that the handler makes.
+//
+// Headers are sent in the message headers, so they should be kept small. An
+// empty value removes the header with the given key.
+func WithHeader(parent context.Context, key, value string) context.Context {
+	if key == "" {
+		panic("header key must not be empty")
+	}
+
+	return withMapItem(parent, headerKey, key, value)
+}
+
+// Headers returns the headers that are sent with requests made using ctx.
+// The returned map must not be modified.
+func Headers(ctx context.Context) map[string]string {
+	h, _ := ctx.Value(headerKey).(map[string]string)
+	return h
+}
+
+type headerKeyType struct{}
+
+var headerKey headerKeyType
@@ -0,0 +1,37 @@
+package rinq_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+var _ = Describe("WithHeader", func() {
+	It("adds the header to the context", func() {
+		parent := rinq.WithHeader(context.Background(), "a", "1")
+		ctx := rinq.WithHeader(parent, "b", "2")
+
+		Expect(rinq.Headers(ctx)).To(Equal(map[string]string{"a": "1", "b": "2"}))
+	})
+
+	It("removes the header if the value is empty", func() {
+		parent := rinq.WithHeader(context.Background(), "a", "1")
+		ctx := rinq.WithHeader(parent, "a", "")
+
+		Expect(rinq.Headers(ctx)).To(BeEmpty())
+	})
+
+	It("is distinct from the baggage", func() {
+		ctx := rinq.WithHeader(context.Background(), "a", "1")
+
+		Expect(rinq.AllBaggage(ctx)).To(BeEmpty())
+	})
+
+	It("panics if the key is empty", func() {
+		Expect(func() {
+			rinq.WithHeader(context.Background(), "", "value")
+		}).To(Panic())
+	})
+})
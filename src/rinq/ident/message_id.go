@@ -67,6 +67,16 @@ func (id MessageID) Validate() error {
 	return fmt.Errorf("message ID %s is invalid", id)
 }
 
+// Compare returns -1 if id sorts before m, +1 if id sorts after m, or 0 if
+// they are equal. Message IDs are ordered by ref, then by sequence.
+func (id MessageID) Compare(m MessageID) int {
+	if c := id.Ref.Compare(m.Ref); c != 0 {
+		return c
+	}
+
+	return compareUint(uint64(id.Seq), uint64(m.Seq))
+}
+
 // ShortString returns a string representation based on the session's short
 // string representation.
 func (id MessageID) ShortString() string {
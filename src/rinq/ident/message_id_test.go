@@ -61,6 +61,17 @@ var _ = Describe("MessageID", func() {
 		Entry("non-zero struct", MessageID{Ref: sessionRef, Seq: 1}, true),
 	)
 
+	DescribeTable(
+		"Compare",
+		func(a, b MessageID, expected int) {
+			Expect(a.Compare(b)).To(Equal(expected))
+		},
+		Entry("a == b", sessionRef.Message(1), sessionRef.Message(1), 0),
+		Entry("a < b (sequence)", sessionRef.Message(1), sessionRef.Message(2), -1),
+		Entry("a > b (sequence)", sessionRef.Message(2), sessionRef.Message(1), +1),
+		Entry("a < b (ref)", sessionRef.Message(2), sessionRef.ID.At(457).Message(1), -1),
+	)
+
 	Describe("ShortString", func() {
 		It("returns a human readable ID", func() {
 			subject := MessageID{Ref: sessionRef, Seq: 789}
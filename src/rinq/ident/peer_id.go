@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"regexp"
+	"strconv"
 	"time"
 )
 
@@ -41,6 +43,31 @@ func NewPeerID() PeerID {
 	}
 }
 
+// ParsePeerID parses a string representation of a peer ID.
+func ParsePeerID(str string) (id PeerID, err error) {
+	matches := peerIDPattern.FindStringSubmatch(str)
+
+	if len(matches) != 0 {
+		// Read the clock component ...
+		var value uint64
+		value, err = strconv.ParseUint(matches[1], 16, 64)
+		if err != nil {
+			return
+		}
+		id.Clock = value
+
+		// Read the random component ...
+		value, err = strconv.ParseUint(matches[2], 16, 16)
+		if err != nil {
+			return
+		}
+		id.Rand = uint16(value)
+	}
+
+	err = id.Validate()
+	return
+}
+
 // Validate returns an error if the peer ID is not valid.
 //
 // Neither the Clock nor Rand component may be zero.
@@ -52,6 +79,17 @@ func (id PeerID) Validate() error {
 	return fmt.Errorf("peer ID %s is invalid", id)
 }
 
+// Compare returns -1 if id sorts before p, +1 if id sorts after p, or 0 if
+// they are equal. Peer IDs are ordered by clock component, then by random
+// component, so peers that connected earlier generally sort first.
+func (id PeerID) Compare(p PeerID) int {
+	if c := compareUint(id.Clock, p.Clock); c != 0 {
+		return c
+	}
+
+	return compareUint(uint64(id.Rand), uint64(p.Rand))
+}
+
 // Session returns a new session ID for this peer with seq as the sequence
 // number.
 func (id PeerID) Session(seq uint32) SessionID {
@@ -79,3 +117,11 @@ func (id PeerID) String() string {
 		id.Rand,
 	)
 }
+
+var peerIDPattern *regexp.Regexp
+
+func init() {
+	peerIDPattern = regexp.MustCompile(
+		`^(.+)\-(.+)$`,
+	)
+}
@@ -16,6 +16,40 @@ var _ = Describe("PeerID", func() {
 		})
 	})
 
+	DescribeTable(
+		"Compare",
+		func(a, b PeerID, expected int) {
+			Expect(a.Compare(b)).To(Equal(expected))
+		},
+		Entry("a == b", PeerID{Clock: 1, Rand: 1}, PeerID{Clock: 1, Rand: 1}, 0),
+		Entry("a < b (clock)", PeerID{Clock: 1, Rand: 2}, PeerID{Clock: 2, Rand: 1}, -1),
+		Entry("a > b (clock)", PeerID{Clock: 2, Rand: 1}, PeerID{Clock: 1, Rand: 2}, +1),
+		Entry("a < b (random)", PeerID{Clock: 1, Rand: 1}, PeerID{Clock: 1, Rand: 2}, -1),
+	)
+
+	Describe("ParsePeerID", func() {
+		It("parses a human readable ID", func() {
+			id, err := ParsePeerID("123456789ABCDEF-0BAD")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(id).To(Equal(PeerID{Clock: 0x0123456789abcdef, Rand: 0x0bad}))
+		})
+
+		DescribeTable(
+			"returns an error if the string is malformed",
+			func(id string) {
+				_, err := ParsePeerID(id)
+
+				Expect(err).Should(HaveOccurred())
+			},
+			Entry("malformed", "<malformed>"),
+			Entry("zero clock component", "0-1"),
+			Entry("zero random component", "1-0"),
+			Entry("invalid clock component", "x-1"),
+			Entry("invalid random component", "1-x"),
+		)
+	})
+
 	DescribeTable(
 		"Validate",
 		func(subject PeerID, isValid bool) {
@@ -1,6 +1,10 @@
 package ident
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
 
 // Revision holds the "version" of a session. A session's revision is
 // incremented when a change is made to its attribute table. A session that has
@@ -13,6 +17,30 @@ type Ref struct {
 	Rev Revision
 }
 
+// ParseRef parses a string representation of a ref, such as
+// "58AEE146-191C.45@3".
+func ParseRef(str string) (ref Ref, err error) {
+	matches := refPattern.FindStringSubmatch(str)
+
+	if len(matches) != 0 {
+		ref.ID, err = ParseSessionID(matches[1])
+		if err != nil {
+			return
+		}
+
+		// Read the session revision ...
+		var value uint64
+		value, err = strconv.ParseUint(matches[2], 10, 32)
+		if err != nil {
+			return
+		}
+		ref.Rev = Revision(value)
+	}
+
+	err = ref.Validate()
+	return
+}
+
 // Validate returns nil if the Ref is valid.
 func (ref Ref) Validate() error {
 	if ref.ID.Validate() == nil {
@@ -40,6 +68,20 @@ func (ref Ref) After(r Ref) bool {
 	return ref.Rev > r.Rev
 }
 
+// Compare returns -1 if ref sorts before r, +1 if ref sorts after r, or 0 if
+// they are equal.
+//
+// Refs are ordered by session ID, then by revision. Unlike Before() and
+// After(), refs to different sessions can be compared, so that Compare() can
+// be used to sort refs or to order them within a store.
+func (ref Ref) Compare(r Ref) int {
+	if c := ref.ID.Compare(r.ID); c != 0 {
+		return c
+	}
+
+	return compareUint(uint64(ref.Rev), uint64(r.Rev))
+}
+
 // Message returns a new message ID derived from this ref with seq as the
 // sequence number.
 func (ref Ref) Message(seq uint32) MessageID {
@@ -58,3 +100,22 @@ func (ref Ref) ShortString() string {
 func (ref Ref) String() string {
 	return fmt.Sprintf("%s@%d", ref.ID, ref.Rev)
 }
+
+var refPattern *regexp.Regexp
+
+func init() {
+	refPattern = regexp.MustCompile(
+		`^(.+)@(.+)$`,
+	)
+}
+
+// compareUint returns -1 if a < b, +1 if a > b, or 0 if they are equal.
+func compareUint(a, b uint64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return +1
+	}
+
+	return 0
+}
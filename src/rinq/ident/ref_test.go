@@ -16,6 +16,29 @@ var _ = Describe("Ref", func() {
 		Seq: 123,
 	}
 
+	Describe("ParseRef", func() {
+		It("parses a human readable ref", func() {
+			ref, err := ParseRef("123456789ABCDEF-0BAD.123@456")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ref).To(Equal(Ref{ID: sessionID, Rev: 456}))
+		})
+
+		DescribeTable(
+			"returns an error if the string is malformed",
+			func(ref string) {
+				_, err := ParseRef(ref)
+
+				Expect(err).Should(HaveOccurred())
+			},
+			Entry("malformed", "<malformed>"),
+			Entry("missing revision", "1-1.1"),
+			Entry("zero peer clock component", "0-1.1@0"),
+			Entry("invalid session sequence", "1-1.x@0"),
+			Entry("invalid session revision", "1-1.1@x"),
+		)
+	})
+
 	DescribeTable(
 		"Validate",
 		func(subject Ref, isValid bool) {
@@ -69,6 +92,19 @@ var _ = Describe("Ref", func() {
 		})
 	})
 
+	DescribeTable(
+		"Compare",
+		func(a, b Ref, expected int) {
+			Expect(a.Compare(b)).To(Equal(expected))
+		},
+		Entry("a == b", SessionID{Seq: 1}.At(1), SessionID{Seq: 1}.At(1), 0),
+		Entry("a < b (revision)", SessionID{Seq: 1}.At(1), SessionID{Seq: 1}.At(2), -1),
+		Entry("a > b (revision)", SessionID{Seq: 1}.At(2), SessionID{Seq: 1}.At(1), +1),
+		Entry("a < b (session)", SessionID{Seq: 1}.At(2), SessionID{Seq: 2}.At(1), -1),
+		Entry("a > b (session)", SessionID{Seq: 2}.At(1), SessionID{Seq: 1}.At(2), +1),
+		Entry("a < b (peer)", SessionID{Seq: 2}.At(0), SessionID{Peer: PeerID{Rand: 1}, Seq: 1}.At(0), -1),
+	)
+
 	Describe("Message", func() {
 		It("returns a new MessageID with the given sequence number", func() {
 			subject := Ref{ID: sessionID, Rev: 456}
@@ -68,6 +68,16 @@ func (id SessionID) Validate() error {
 	return fmt.Errorf("session ID %s is invalid", id)
 }
 
+// Compare returns -1 if id sorts before s, +1 if id sorts after s, or 0 if
+// they are equal. Session IDs are ordered by peer ID, then by sequence.
+func (id SessionID) Compare(s SessionID) int {
+	if c := id.Peer.Compare(s.Peer); c != 0 {
+		return c
+	}
+
+	return compareUint(uint64(id.Seq), uint64(s.Seq))
+}
+
 // At returns a Ref for this session ID.
 func (id SessionID) At(rev Revision) Ref {
 	return Ref{ID: id, Rev: rev}
@@ -51,6 +51,17 @@ var _ = Describe("SessionID", func() {
 		Entry("non-zero struct", SessionID{Peer: peerID, Seq: 1}, true),
 	)
 
+	DescribeTable(
+		"Compare",
+		func(a, b SessionID, expected int) {
+			Expect(a.Compare(b)).To(Equal(expected))
+		},
+		Entry("a == b", SessionID{Seq: 1}, SessionID{Seq: 1}, 0),
+		Entry("a < b (sequence)", SessionID{Seq: 1}, SessionID{Seq: 2}, -1),
+		Entry("a > b (sequence)", SessionID{Seq: 2}, SessionID{Seq: 1}, +1),
+		Entry("a < b (peer)", SessionID{Seq: 2}, SessionID{Peer: PeerID{Clock: 1}, Seq: 1}, -1),
+	)
+
 	Describe("At", func() {
 		It("creates a ref at the given version", func() {
 			subject := SessionID{Peer: peerID, Seq: 123}
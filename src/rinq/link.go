@@ -0,0 +1,62 @@
+package rinq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// Link is a bidirectional message pipe between two sessions.
+//
+// Links are created by calling Session.Link(). Messages are carried by
+// notifications, but without the namespace and type that are required for
+// notifications sent with Session.Notify().
+//
+// Messages sent by one session are received by the other only if it has a
+// link to the sender. Messages that arrive while there is no such link are
+// discarded.
+type Link interface {
+	// Target returns the ID of the session at the other end of the link.
+	Target() ident.SessionID
+
+	// Send sends a message to the target session.
+	//
+	// If IsNotFound(err) returns true, the session that owns the link has been
+	// destroyed and the message can not be sent.
+	Send(ctx context.Context, out *Payload) error
+
+	// Receive blocks until a message is received from the target session, the
+	// link is closed, or ctx is canceled.
+	//
+	// The caller is responsible for closing the returned payload.
+	Receive(ctx context.Context) (*Payload, error)
+
+	// Close closes the link. Any messages that have been received but not yet
+	// returned by Receive() are discarded. Links are closed automatically when
+	// the session that owns them is destroyed.
+	//
+	// It is not an error to close a link multiple times.
+	Close()
+}
+
+// LinkClosedError indicates that an operation failed because the link has
+// been closed.
+type LinkClosedError struct {
+	Target ident.SessionID
+}
+
+// IsLinkClosed returns true if err is a LinkClosedError.
+func IsLinkClosed(err error) bool {
+	_, ok := err.(LinkClosedError)
+	return ok
+}
+
+func (err LinkClosedError) Error() string {
+	return fmt.Sprintf("link to session %s is closed", err.Target)
+}
+
+// Is returns true if target is ErrLinkClosed.
+func (err LinkClosedError) Is(target error) bool {
+	return target == ErrLinkClosed
+}
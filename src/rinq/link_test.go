@@ -0,0 +1,33 @@
+package rinq_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+var _ = Describe("LinkClosedError", func() {
+	Describe("Error", func() {
+		It("includes the target session ID", func() {
+			id := ident.SessionID{
+				Peer: ident.PeerID{Clock: 1, Rand: 2},
+				Seq:  3,
+			}
+			err := rinq.LinkClosedError{Target: id}
+			Expect(err.Error()).To(Equal("link to session 1-0002.3 is closed"))
+		})
+	})
+
+	Describe("IsLinkClosed", func() {
+		It("returns true for link closed errors", func() {
+			Expect(rinq.IsLinkClosed(rinq.LinkClosedError{})).To(BeTrue())
+		})
+
+		It("returns false for other error types", func() {
+			Expect(rinq.IsLinkClosed(errors.New(""))).To(BeFalse())
+		})
+	})
+})
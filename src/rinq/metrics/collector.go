@@ -0,0 +1,406 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+// DefaultLatencyBounds is the set of histogram bucket bounds used by a
+// Collector when no bounds are specified.
+var DefaultLatencyBounds = []time.Duration{
+	1 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Collector is a Recorder that keeps in-memory statistics for each command and
+// notification type.
+type Collector struct {
+	bounds []time.Duration
+
+	mutex         sync.Mutex
+	calls         map[commandKey]*CommandStats
+	handled       map[commandKey]*CommandStats
+	notifications map[notificationKey]*NotificationStats
+	conn          ConnectionStats
+}
+
+// NewCollector returns a new Collector that records latencies in histogram
+// buckets with the given upper bounds. If no bounds are given,
+// DefaultLatencyBounds is used.
+func NewCollector(bounds ...time.Duration) *Collector {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBounds
+	}
+
+	b := make([]time.Duration, len(bounds))
+	copy(b, bounds)
+	sort.Slice(b, func(i, j int) bool {
+		return b[i] < b[j]
+	})
+
+	return &Collector{
+		bounds:        b,
+		calls:         map[commandKey]*CommandStats{},
+		handled:       map[commandKey]*CommandStats{},
+		notifications: map[notificationKey]*NotificationStats{},
+	}
+}
+
+// RecordCall implements Recorder.RecordCall()
+func (c *Collector) RecordCall(ns, cmd string, d time.Duration, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.record(c.calls, ns, cmd, d, err)
+}
+
+// RecordHandled implements Recorder.RecordHandled()
+func (c *Collector) RecordHandled(ns, cmd string, d time.Duration, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.record(c.handled, ns, cmd, d, err)
+}
+
+// RecordSlowHandler implements SlowHandlerRecorder.RecordSlowHandler()
+func (c *Collector) RecordSlowHandler(ns, cmd string, d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.stats(c.handled, ns, cmd).SlowHandlers++
+}
+
+// RecordConnectionBlocked implements
+// ConnectionBlockedRecorder.RecordConnectionBlocked()
+func (c *Collector) RecordConnectionBlocked(reason string, d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.conn.Blocked++
+	c.conn.BlockedTime += d
+}
+
+// RecordNotificationPublished implements
+// NotificationRecorder.RecordNotificationPublished()
+func (c *Collector) RecordNotificationPublished(ns, t string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.notificationStats(ns, t).Published++
+}
+
+// RecordNotificationMatched implements
+// NotificationRecorder.RecordNotificationMatched()
+func (c *Collector) RecordNotificationMatched(ns, t string, listening, matched int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := c.notificationStats(ns, t)
+	s.Listening += uint64(listening)
+	s.Matched += uint64(matched)
+}
+
+// RecordNotificationDelivered implements
+// NotificationRecorder.RecordNotificationDelivered()
+func (c *Collector) RecordNotificationDelivered(ns, t string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.notificationStats(ns, t).Delivered++
+}
+
+// RecordNotificationDropped implements
+// NotificationRecorder.RecordNotificationDropped()
+func (c *Collector) RecordNotificationDropped(ns, t string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.notificationStats(ns, t).Dropped++
+}
+
+// Calls returns statistics about the command calls made by sessions owned by
+// the peer, ordered by namespace and command.
+func (c *Collector) Calls() []CommandStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return snapshot(c.calls)
+}
+
+// Handled returns statistics about the command requests handled by the peer,
+// ordered by namespace and command.
+func (c *Collector) Handled() []CommandStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return snapshot(c.handled)
+}
+
+// Notifications returns statistics about the notifications published and
+// received by the peer, ordered by namespace and type.
+func (c *Collector) Notifications() []NotificationStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	r := make([]NotificationStats, 0, len(c.notifications))
+	for _, s := range c.notifications {
+		r = append(r, *s)
+	}
+
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].Namespace != r[j].Namespace {
+			return r[i].Namespace < r[j].Namespace
+		}
+
+		return r[i].Type < r[j].Type
+	})
+
+	return r
+}
+
+// Connection returns statistics about the peer's connection to the broker.
+func (c *Collector) Connection() ConnectionStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.conn
+}
+
+func (c *Collector) record(
+	m map[commandKey]*CommandStats,
+	ns, cmd string,
+	d time.Duration,
+	err error,
+) {
+	s := c.stats(m, ns, cmd)
+	s.Count++
+
+	switch {
+	case err == nil:
+		s.Successes++
+	case rinq.IsDeadlineExceeded(err):
+		s.DeadlineExceeded++
+	case rinq.IsFailure(err):
+		if s.Failures == nil {
+			s.Failures = map[string]uint64{}
+		}
+		s.Failures[rinq.FailureType(err)]++
+	default:
+		s.Errors++
+	}
+
+	s.Latency.observe(d)
+}
+
+// stats returns the statistics for the given command in m, adding them if
+// necessary.
+func (c *Collector) stats(m map[commandKey]*CommandStats, ns, cmd string) *CommandStats {
+	k := commandKey{ns, cmd}
+	s, ok := m[k]
+
+	if !ok {
+		s = &CommandStats{
+			Namespace: ns,
+			Command:   cmd,
+			Latency: Histogram{
+				Bounds: c.bounds,
+				Counts: make([]uint64, len(c.bounds)+1),
+			},
+		}
+		m[k] = s
+	}
+
+	return s
+}
+
+// notificationStats returns the statistics for the given notification type,
+// adding them if necessary.
+func (c *Collector) notificationStats(ns, t string) *NotificationStats {
+	k := notificationKey{ns, t}
+	s, ok := c.notifications[k]
+
+	if !ok {
+		s = &NotificationStats{
+			Namespace: ns,
+			Type:      t,
+		}
+		c.notifications[k] = s
+	}
+
+	return s
+}
+
+// CommandStats contains statistics about a single command.
+type CommandStats struct {
+	Namespace string
+	Command   string
+
+	// Count is the total number of requests.
+	Count uint64
+
+	// Successes is the number of requests that completed successfully.
+	Successes uint64
+
+	// Failures is a map of failure type to the number of requests that
+	// resulted in a failure of that type.
+	Failures map[string]uint64
+
+	// DeadlineExceeded is the number of requests that did not complete before
+	// their deadline.
+	DeadlineExceeded uint64
+
+	// Errors is the number of requests that resulted in any other error.
+	Errors uint64
+
+	// SlowHandlers is the number of requests whose handler took longer than
+	// the peer's slow handler threshold. It is always zero for calls.
+	SlowHandlers uint64
+
+	// Latency is a histogram of the time taken by each request.
+	Latency Histogram
+}
+
+// DeadlineExceededRate returns the proportion of requests that did not
+// complete before their deadline, between 0 and 1.
+func (s CommandStats) DeadlineExceededRate() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+
+	return float64(s.DeadlineExceeded) / float64(s.Count)
+}
+
+// NotificationStats contains statistics about a single notification type.
+type NotificationStats struct {
+	Namespace string
+	Type      string
+
+	// Published is the number of notifications published by sessions owned by
+	// the peer.
+	Published uint64
+
+	// Listening is the total number of listening sessions that multicast
+	// notifications were checked against.
+	Listening uint64
+
+	// Matched is the total number of listening sessions that satisfied the
+	// constraint of a multicast notification.
+	Matched uint64
+
+	// Delivered is the number of notifications passed to session handlers.
+	Delivered uint64
+
+	// Dropped is the number of notifications that were not delivered because
+	// the target session was destroyed or stopped listening before the
+	// notification could be handled.
+	Dropped uint64
+}
+
+// MatchRate returns the proportion of listening sessions that satisfied the
+// constraints of multicast notifications, between 0 and 1.
+func (s NotificationStats) MatchRate() float64 {
+	if s.Listening == 0 {
+		return 0
+	}
+
+	return float64(s.Matched) / float64(s.Listening)
+}
+
+// ConnectionStats contains statistics about a peer's connection to the broker.
+type ConnectionStats struct {
+	// Blocked is the number of times the broker has blocked and subsequently
+	// unblocked the connection.
+	Blocked uint64
+
+	// BlockedTime is the total time that the connection spent blocked.
+	BlockedTime time.Duration
+}
+
+// Histogram is a latency histogram.
+type Histogram struct {
+	// Bounds is the inclusive upper bound of each bucket, in ascending order.
+	Bounds []time.Duration
+
+	// Counts is the number of observations in each bucket. It has one more
+	// element than Bounds, which counts observations greater than the last
+	// bound.
+	Counts []uint64
+
+	// Sum is the total of all observations.
+	Sum time.Duration
+}
+
+// Mean returns the mean of all observations.
+func (h Histogram) Mean() time.Duration {
+	var n uint64
+	for _, c := range h.Counts {
+		n += c
+	}
+
+	if n == 0 {
+		return 0
+	}
+
+	return h.Sum / time.Duration(n)
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	i := sort.Search(len(h.Bounds), func(i int) bool {
+		return d <= h.Bounds[i]
+	})
+
+	h.Counts[i]++
+	h.Sum += d
+}
+
+type commandKey struct {
+	Namespace string
+	Command   string
+}
+
+type notificationKey struct {
+	Namespace string
+	Type      string
+}
+
+// snapshot returns a copy of the stats in m, ordered by namespace and command.
+func snapshot(m map[commandKey]*CommandStats) []CommandStats {
+	r := make([]CommandStats, 0, len(m))
+
+	for _, s := range m {
+		c := *s
+		c.Latency.Counts = append([]uint64(nil), s.Latency.Counts...)
+
+		if s.Failures != nil {
+			c.Failures = make(map[string]uint64, len(s.Failures))
+			for t, n := range s.Failures {
+				c.Failures[t] = n
+			}
+		}
+
+		r = append(r, c)
+	}
+
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].Namespace != r[j].Namespace {
+			return r[i].Namespace < r[j].Namespace
+		}
+
+		return r[i].Command < r[j].Command
+	})
+
+	return r
+}
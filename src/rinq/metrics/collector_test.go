@@ -0,0 +1,156 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+	. "github.com/rinq/rinq-go/src/rinq/metrics"
+)
+
+var _ = Describe("Collector", func() {
+	var collector *Collector
+
+	BeforeEach(func() {
+		collector = NewCollector(10*time.Millisecond, time.Millisecond)
+	})
+
+	Describe("RecordCall", func() {
+		It("counts outcomes by type", func() {
+			collector.RecordCall("ns", "cmd", time.Millisecond, nil)
+			collector.RecordCall("ns", "cmd", time.Millisecond, rinq.Failure{Type: "bad"})
+			collector.RecordCall("ns", "cmd", time.Millisecond, rinq.Failure{Type: "bad"})
+			collector.RecordCall("ns", "cmd", time.Millisecond, context.DeadlineExceeded)
+			collector.RecordCall("ns", "cmd", time.Millisecond, rinq.DeadlineExceededError{})
+			collector.RecordCall("ns", "cmd", time.Millisecond, errors.New("<error>"))
+
+			stats := collector.Calls()
+
+			Expect(stats).To(HaveLen(1))
+			Expect(stats[0].Namespace).To(Equal("ns"))
+			Expect(stats[0].Command).To(Equal("cmd"))
+			Expect(stats[0].Count).To(BeNumerically("==", 6))
+			Expect(stats[0].Successes).To(BeNumerically("==", 1))
+			Expect(stats[0].Failures).To(Equal(map[string]uint64{"bad": 2}))
+			Expect(stats[0].DeadlineExceeded).To(BeNumerically("==", 2))
+			Expect(stats[0].Errors).To(BeNumerically("==", 1))
+			Expect(stats[0].DeadlineExceededRate()).To(Equal(2.0 / 6))
+		})
+
+		It("records latency in the appropriate bucket", func() {
+			collector.RecordCall("ns", "cmd", time.Millisecond, nil)
+			collector.RecordCall("ns", "cmd", 5*time.Millisecond, nil)
+			collector.RecordCall("ns", "cmd", 20*time.Millisecond, nil)
+
+			h := collector.Calls()[0].Latency
+
+			Expect(h.Bounds).To(Equal([]time.Duration{time.Millisecond, 10 * time.Millisecond}))
+			Expect(h.Counts).To(Equal([]uint64{1, 1, 1}))
+			Expect(h.Sum).To(Equal(26 * time.Millisecond))
+			Expect(h.Mean()).To(Equal(26 * time.Millisecond / 3))
+		})
+
+		It("does not affect the handled statistics", func() {
+			collector.RecordCall("ns", "cmd", time.Millisecond, nil)
+
+			Expect(collector.Handled()).To(BeEmpty())
+		})
+	})
+
+	Describe("Handled", func() {
+		It("returns statistics ordered by namespace and command", func() {
+			collector.RecordHandled("ns-b", "cmd", time.Millisecond, nil)
+			collector.RecordHandled("ns-a", "cmd-2", time.Millisecond, nil)
+			collector.RecordHandled("ns-a", "cmd-1", time.Millisecond, nil)
+
+			var names []string
+			for _, s := range collector.Handled() {
+				names = append(names, s.Namespace+"::"+s.Command)
+			}
+
+			Expect(names).To(Equal([]string{"ns-a::cmd-1", "ns-a::cmd-2", "ns-b::cmd"}))
+		})
+
+		It("returns a copy of the statistics", func() {
+			collector.RecordHandled("ns", "cmd", time.Millisecond, rinq.Failure{Type: "bad"})
+			stats := collector.Handled()
+
+			collector.RecordHandled("ns", "cmd", time.Millisecond, rinq.Failure{Type: "bad"})
+
+			Expect(stats[0].Failures).To(Equal(map[string]uint64{"bad": 1}))
+			Expect(stats[0].Latency.Counts).To(Equal([]uint64{1, 0, 0}))
+		})
+	})
+
+	Describe("RecordSlowHandler", func() {
+		It("counts slow handlers in the handled statistics", func() {
+			collector.RecordSlowHandler("ns", "cmd", time.Second)
+			collector.RecordHandled("ns", "cmd", time.Second, nil)
+
+			stats := collector.Handled()
+
+			Expect(stats).To(HaveLen(1))
+			Expect(stats[0].Count).To(BeNumerically("==", 1))
+			Expect(stats[0].SlowHandlers).To(BeNumerically("==", 1))
+			Expect(collector.Calls()).To(BeEmpty())
+		})
+	})
+
+	Describe("Notifications", func() {
+		It("accumulates statistics by namespace and type", func() {
+			collector.RecordNotificationPublished("ns", "type")
+			collector.RecordNotificationMatched("ns", "type", 4, 1)
+			collector.RecordNotificationMatched("ns", "type", 4, 2)
+			collector.RecordNotificationDelivered("ns", "type")
+			collector.RecordNotificationDelivered("ns", "type")
+			collector.RecordNotificationDropped("ns", "type")
+
+			stats := collector.Notifications()
+
+			Expect(stats).To(Equal([]NotificationStats{
+				{
+					Namespace: "ns",
+					Type:      "type",
+					Published: 1,
+					Listening: 8,
+					Matched:   3,
+					Delivered: 2,
+					Dropped:   1,
+				},
+			}))
+			Expect(stats[0].MatchRate()).To(Equal(3.0 / 8))
+		})
+
+		It("returns statistics ordered by namespace and type", func() {
+			collector.RecordNotificationPublished("ns-b", "type")
+			collector.RecordNotificationPublished("ns-a", "type-2")
+			collector.RecordNotificationPublished("ns-a", "type-1")
+
+			var names []string
+			for _, s := range collector.Notifications() {
+				names = append(names, s.Namespace+"::"+s.Type)
+			}
+
+			Expect(names).To(Equal([]string{"ns-a::type-1", "ns-a::type-2", "ns-b::type"}))
+		})
+
+		It("reports a match rate of zero if no sessions were listening", func() {
+			Expect(NotificationStats{}.MatchRate()).To(BeZero())
+		})
+	})
+
+	Describe("RecordConnectionBlocked", func() {
+		It("accumulates the number of blocks and the time spent blocked", func() {
+			collector.RecordConnectionBlocked("low on memory", time.Second)
+			collector.RecordConnectionBlocked("low on disk", 2*time.Second)
+
+			Expect(collector.Connection()).To(Equal(ConnectionStats{
+				Blocked:     2,
+				BlockedTime: 3 * time.Second,
+			}))
+		})
+	})
+})
@@ -0,0 +1,5 @@
+// Package metrics provides a mechanism for collecting measurements about the
+// command requests and notifications made and handled by a peer.
+//
+// A Recorder is configured by passing options.Metrics() when creating a peer.
+package metrics
@@ -0,0 +1,72 @@
+package metrics
+
+import "time"
+
+// Recorder is an interface for receiving measurements about the command
+// requests made and handled by a peer.
+//
+// Implementations must be safe for concurrent use.
+type Recorder interface {
+	// RecordCall records the outcome of a command call made by a session owned
+	// by the peer, where d is the time taken to receive the response.
+	//
+	// err is the error returned by the call, if any.
+	RecordCall(ns, cmd string, d time.Duration, err error)
+
+	// RecordHandled records the outcome of a command request handled by the
+	// peer, where d is the time taken by the command handler.
+	//
+	// err is the error sent in response to the request, if any.
+	RecordHandled(ns, cmd string, d time.Duration, err error)
+}
+
+// SlowHandlerRecorder is an optional interface that a Recorder may implement to
+// be informed of command handlers that exceed the peer's slow handler
+// threshold, see options.SlowHandlerThreshold().
+type SlowHandlerRecorder interface {
+	// RecordSlowHandler records that the handler for a command request took d
+	// to complete, which is longer than the slow handler threshold. It is
+	// called before RecordHandled() for the same request.
+	RecordSlowHandler(ns, cmd string, d time.Duration)
+}
+
+// ConnectionBlockedRecorder is an optional interface that a Recorder may
+// implement to be informed when the broker blocks the peer's connection, such
+// as when it has raised a memory or disk alarm.
+type ConnectionBlockedRecorder interface {
+	// RecordConnectionBlocked records that the broker unblocked the peer's
+	// connection after blocking it for d. reason is the reason the broker gave
+	// for blocking the connection.
+	RecordConnectionBlocked(reason string, d time.Duration)
+}
+
+// NotificationRecorder is an optional interface that a Recorder may implement
+// to be informed of the notifications published and received by the peer.
+type NotificationRecorder interface {
+	// RecordNotificationPublished records that a session owned by the peer
+	// published a notification of type t in the ns namespace.
+	RecordNotificationPublished(ns, t string)
+
+	// RecordNotificationMatched records that a multicast notification received
+	// by the peer was checked against the constraints of the listening
+	// sessions, of which matched sessions satisfied the notification's
+	// constraint.
+	RecordNotificationMatched(ns, t string, listening, matched int)
+
+	// RecordNotificationDelivered records that a notification was passed to the
+	// handler of a session owned by the peer.
+	RecordNotificationDelivered(ns, t string)
+
+	// RecordNotificationDropped records that a notification was not delivered
+	// because the target session was destroyed or stopped listening to the
+	// namespace before the notification could be handled.
+	RecordNotificationDropped(ns, t string)
+}
+
+// Discard is a Recorder that ignores all measurements.
+var Discard Recorder = discard{}
+
+type discard struct{}
+
+func (discard) RecordCall(string, string, time.Duration, error)    {}
+func (discard) RecordHandled(string, string, time.Duration, error) {}
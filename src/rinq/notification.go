@@ -36,6 +36,20 @@ type Notification struct {
 	// criteria for selecting which sessions receive the notification. The
 	// constraint is nil if IsMulticast is false.
 	Constraint constraint.Constraint
+
+	// IsRequest is true if the notification was sent with
+	// Session.NotifyAndWait(), in which case the source session is waiting
+	// for the handler to respond with Session.Reply().
+	IsRequest bool
+
+	// CorrelationID is the ID of the request notification that this
+	// notification is a reply to. It is the zero-value if the notification is
+	// not a reply.
+	CorrelationID ident.MessageID
+
+	// Headers contains the application-defined headers sent with the
+	// notification, as per WithHeader(). It is nil if there are no headers.
+	Headers map[string]string
 }
 
 // NotificationHandler is a callback-function invoked when an inter-session
@@ -46,8 +60,36 @@ type Notification struct {
 //
 // The handler is responsible for closing n.Payload, however there is no
 // requirement that the payload be closed during the execution of the handler.
+//
+// ctx contains the trace ID, the ID of the receiving peer and a reference to
+// the source session. Use trace.Logger() to include these values in log
+// messages produced by the handler.
 type NotificationHandler func(
 	ctx context.Context,
 	target Session,
 	n Notification,
 )
+
+// NotificationMiddleware is a function that decorates a NotificationHandler,
+// typically to perform some common processing before or after next is invoked,
+// such as logging, recording metrics, validating payloads or authorizing the
+// source session.
+//
+// Middleware may decline to invoke next, for example to discard a notification
+// that fails validation, in which case it is responsible for closing n.Payload.
+//
+// See Session.Listen() to apply middleware to a handler.
+type NotificationMiddleware func(next NotificationHandler) NotificationHandler
+
+// WithNotificationMiddleware returns a handler that passes each notification
+// through mw before invoking h.
+//
+// The middleware are applied in order, such that mw[0] is the first to
+// receive each notification.
+func WithNotificationMiddleware(h NotificationHandler, mw ...NotificationMiddleware) NotificationHandler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+
+	return h
+}
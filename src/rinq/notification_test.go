@@ -0,0 +1,66 @@
+package rinq_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+var _ = Describe("WithNotificationMiddleware", func() {
+	var calls []string
+
+	middleware := func(name string) rinq.NotificationMiddleware {
+		return func(next rinq.NotificationHandler) rinq.NotificationHandler {
+			return func(ctx context.Context, target rinq.Session, n rinq.Notification) {
+				calls = append(calls, name)
+				next(ctx, target, n)
+			}
+		}
+	}
+
+	handler := func(ctx context.Context, target rinq.Session, n rinq.Notification) {
+		calls = append(calls, "handler")
+	}
+
+	BeforeEach(func() {
+		calls = nil
+	})
+
+	It("passes the notification through the middleware in order", func() {
+		h := rinq.WithNotificationMiddleware(
+			handler,
+			middleware("a"),
+			middleware("b"),
+		)
+
+		h(context.Background(), nil, rinq.Notification{})
+
+		Expect(calls).To(Equal([]string{"a", "b", "handler"}))
+	})
+
+	It("returns the handler unchanged if there is no middleware", func() {
+		h := rinq.WithNotificationMiddleware(handler)
+
+		h(context.Background(), nil, rinq.Notification{})
+
+		Expect(calls).To(Equal([]string{"handler"}))
+	})
+
+	It("does not invoke the handler if the middleware does not invoke next", func() {
+		h := rinq.WithNotificationMiddleware(
+			handler,
+			func(next rinq.NotificationHandler) rinq.NotificationHandler {
+				return func(ctx context.Context, target rinq.Session, n rinq.Notification) {
+					calls = append(calls, "reject")
+				}
+			},
+			middleware("b"),
+		)
+
+		h(context.Background(), nil, rinq.Notification{})
+
+		Expect(calls).To(Equal([]string{"reject"}))
+	})
+})
@@ -0,0 +1,54 @@
+package options
+
+import "fmt"
+
+// BalanceStrategy is a strategy used to select the peer that services a
+// load-balanced command call.
+type BalanceStrategy int
+
+const (
+	// BrokerBalancing leaves the distribution of load-balanced calls to the
+	// message broker. Calls are delivered to the listening peers in turn.
+	BrokerBalancing BalanceStrategy = iota
+
+	// LeastPendingBalancing sends each call to the listening peer with the
+	// fewest calls awaiting a response from this peer.
+	LeastPendingBalancing
+
+	// SessionHashBalancing sends each call to a listening peer chosen by
+	// consistent hashing of the calling session's ID, such that calls from the
+	// same session are sent to the same peer while the set of listening peers
+	// does not change.
+	SessionHashBalancing
+
+	// WeightedBalancing sends each call to a listening peer chosen at random,
+	// weighted by the number of command workers each peer has.
+	WeightedBalancing
+)
+
+var balanceStrategyNames = map[BalanceStrategy]string{
+	BrokerBalancing:       "broker",
+	LeastPendingBalancing: "least-pending",
+	SessionHashBalancing:  "session-hash",
+	WeightedBalancing:     "weighted",
+}
+
+// String returns the name of the strategy.
+func (s BalanceStrategy) String() string {
+	if n, ok := balanceStrategyNames[s]; ok {
+		return n
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// parseBalanceStrategy returns the strategy with the given name.
+func parseBalanceStrategy(n string) (BalanceStrategy, error) {
+	for s, name := range balanceStrategyNames {
+		if name == n {
+			return s, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown balancing strategy: %s", n)
+}
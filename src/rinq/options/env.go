@@ -2,6 +2,7 @@ package options
 
 import (
 	"os"
+	"strings"
 
 	"github.com/jmalloc/twelf/src/twelf"
 	"github.com/rinq/rinq-go/src/internal/x/env"
@@ -11,12 +12,35 @@ import (
 //
 // The environment variables are listed below.
 //
-// - RINQ_DEFAULT_TIMEOUT (duration in milliseconds, non-zero)
-// - RINQ_LOG_DEBUG       (boolean 'true' or 'false')
-// - RINQ_COMMAND_WORKERS (positive integer, non-zero)
-// - RINQ_SESSION_WORKERS (positive integer, non-zero)
-// - RINQ_PRUNE_INTERVAL  (duration in milliseconds, non-zero)
-// - RINQ_PRODUCT         (string)
+// - RINQ_DEFAULT_TIMEOUT       (duration in milliseconds, non-zero)
+// - RINQ_LOG_DEBUG             (boolean 'true' or 'false')
+// - RINQ_COMMAND_WORKERS       (positive integer, non-zero)
+// - RINQ_SESSION_WORKERS       (positive integer, non-zero)
+// - RINQ_PRUNE_INTERVAL        (duration in milliseconds, non-zero)
+// - RINQ_CACHE_TTL             (duration in milliseconds, non-zero)
+// - RINQ_CACHE_SIZE            (positive integer, non-zero)
+// - RINQ_NOT_FOUND_TTL         (duration in milliseconds, non-zero)
+// - RINQ_REPLAY_WINDOW         (duration in milliseconds, non-zero)
+// - RINQ_BALANCING             (broker, least-pending, session-hash or weighted)
+// - RINQ_STICKY_SESSIONS       (true/false)
+// - RINQ_TENANT                (string)
+// - RINQ_NOTIFY_BATCH          (duration in milliseconds, non-zero)
+// - RINQ_CANONICAL             (true/false)
+// - RINQ_PRODUCT               (string)
+// - RINQ_LISTENER_CONCURRENCY  (positive integer, non-zero)
+// - RINQ_LISTENER_BUFFER       (positive integer, non-zero)
+// - RINQ_LISTENER_OVERFLOW     (block, drop-oldest or drop-newest)
+// - RINQ_ORDERED_NOTIFICATIONS (true/false)
+// - RINQ_HOP_MARGIN            (duration in milliseconds, non-zero)
+// - RINQ_GROUPS                (comma-separated list of group names)
+// - RINQ_DEADLINE_DIAGNOSTICS  (true/false)
+// - RINQ_SLOW_HANDLER_THRESHOLD (duration in milliseconds, non-zero)
+// - RINQ_PAUSE_WHEN_BLOCKED    (true/false)
+// - RINQ_ADAPTIVE_COMMAND_WORKERS (duration in milliseconds, non-zero)
+// - RINQ_RESTARTABLE           (true/false)
+// - RINQ_QUORUM_QUEUES         (true/false)
+// - RINQ_NAME_PREFIX           (string)
+// - RINQ_BUFFER_POOL_MAX_SIZE  (positive integer, non-zero)
 func FromEnv() ([]Option, error) {
 	var o []Option
 
@@ -56,9 +80,167 @@ func FromEnv() ([]Option, error) {
 		o = append(o, PruneInterval(t))
 	}
 
+	t, ok, err = env.Duration("RINQ_CACHE_TTL")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, CacheTTL(t))
+	}
+
+	n, ok, err = env.UInt("RINQ_CACHE_SIZE")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, CacheSize(n))
+	}
+
+	t, ok, err = env.Duration("RINQ_NOT_FOUND_TTL")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, NotFoundTTL(t))
+	}
+
+	t, ok, err = env.Duration("RINQ_REPLAY_WINDOW")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, ReplayWindow(t))
+	}
+
+	if n := os.Getenv("RINQ_BALANCING"); n != "" {
+		s, err := parseBalanceStrategy(n)
+		if err != nil {
+			return nil, err
+		}
+
+		o = append(o, Balancing(s))
+	}
+
+	sticky, ok, err := env.Bool("RINQ_STICKY_SESSIONS")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, StickySessions(sticky))
+	}
+
+	if t := os.Getenv("RINQ_TENANT"); t != "" {
+		o = append(o, Tenant(t))
+	}
+
+	t, ok, err = env.Duration("RINQ_NOTIFY_BATCH")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, NotifyBatch(t))
+	}
+
+	canonical, ok, err := env.Bool("RINQ_CANONICAL")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, Canonical(canonical))
+	}
+
 	if p := os.Getenv("RINQ_PRODUCT"); p != "" {
 		o = append(o, Product(p))
 	}
 
+	n, ok, err = env.UInt("RINQ_LISTENER_CONCURRENCY")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, ListenerConcurrency(n))
+	}
+
+	n, ok, err = env.UInt("RINQ_LISTENER_BUFFER")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, ListenerBuffer(n))
+	}
+
+	if n := os.Getenv("RINQ_LISTENER_OVERFLOW"); n != "" {
+		p, err := parseOverflowPolicy(n)
+		if err != nil {
+			return nil, err
+		}
+
+		o = append(o, ListenerOverflow(p))
+	}
+
+	ordered, ok, err := env.Bool("RINQ_ORDERED_NOTIFICATIONS")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, OrderedNotifications(ordered))
+	}
+
+	t, ok, err = env.Duration("RINQ_HOP_MARGIN")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, HopMargin(t))
+	}
+
+	if g := os.Getenv("RINQ_GROUPS"); g != "" {
+		for _, name := range strings.Split(g, ",") {
+			o = append(o, Group(strings.TrimSpace(name)))
+		}
+	}
+
+	diagnostics, ok, err := env.Bool("RINQ_DEADLINE_DIAGNOSTICS")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, DeadlineDiagnostics(diagnostics))
+	}
+
+	t, ok, err = env.Duration("RINQ_SLOW_HANDLER_THRESHOLD")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, SlowHandlerThreshold(t))
+	}
+
+	pause, ok, err := env.Bool("RINQ_PAUSE_WHEN_BLOCKED")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, PauseWhenBlocked(pause))
+	}
+
+	t, ok, err = env.Duration("RINQ_ADAPTIVE_COMMAND_WORKERS")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, AdaptiveCommandWorkers(t))
+	}
+
+	restartable, ok, err := env.Bool("RINQ_RESTARTABLE")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, Restartable(restartable))
+	}
+
+	quorum, ok, err := env.Bool("RINQ_QUORUM_QUEUES")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, DefaultQueue(ListenOptions{Quorum: quorum}))
+	}
+
+	if p := os.Getenv("RINQ_NAME_PREFIX"); p != "" {
+		o = append(o, NamePrefix(p))
+	}
+
+	n, ok, err = env.UInt("RINQ_BUFFER_POOL_MAX_SIZE")
+	if err != nil {
+		return nil, err
+	} else if ok {
+		o = append(o, BufferPoolMaxSize(n))
+	}
+
 	return o, nil
 }
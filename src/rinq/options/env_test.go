@@ -17,7 +17,30 @@ var _ = Describe("FromEnv", func() {
 		os.Setenv("RINQ_COMMAND_WORKERS", "")
 		os.Setenv("RINQ_SESSION_WORKERS", "")
 		os.Setenv("RINQ_PRUNE_INTERVAL", "")
+		os.Setenv("RINQ_CACHE_TTL", "")
+		os.Setenv("RINQ_CACHE_SIZE", "")
+		os.Setenv("RINQ_NOT_FOUND_TTL", "")
+		os.Setenv("RINQ_REPLAY_WINDOW", "")
+		os.Setenv("RINQ_BALANCING", "")
+		os.Setenv("RINQ_STICKY_SESSIONS", "")
+		os.Setenv("RINQ_TENANT", "")
+		os.Setenv("RINQ_NOTIFY_BATCH", "")
+		os.Setenv("RINQ_CANONICAL", "")
 		os.Setenv("RINQ_PRODUCT", "")
+		os.Setenv("RINQ_LISTENER_CONCURRENCY", "")
+		os.Setenv("RINQ_LISTENER_BUFFER", "")
+		os.Setenv("RINQ_LISTENER_OVERFLOW", "")
+		os.Setenv("RINQ_ORDERED_NOTIFICATIONS", "")
+		os.Setenv("RINQ_HOP_MARGIN", "")
+		os.Setenv("RINQ_GROUPS", "")
+		os.Setenv("RINQ_DEADLINE_DIAGNOSTICS", "")
+		os.Setenv("RINQ_SLOW_HANDLER_THRESHOLD", "")
+		os.Setenv("RINQ_PAUSE_WHEN_BLOCKED", "")
+		os.Setenv("RINQ_ADAPTIVE_COMMAND_WORKERS", "")
+		os.Setenv("RINQ_RESTARTABLE", "")
+		os.Setenv("RINQ_QUORUM_QUEUES", "")
+		os.Setenv("RINQ_NAME_PREFIX", "")
+		os.Setenv("RINQ_BUFFER_POOL_MAX_SIZE", "")
 	})
 
 	It("returns an empty slice when no environment variables are set", func() {
@@ -148,6 +171,188 @@ var _ = Describe("FromEnv", func() {
 		})
 	})
 
+	Context("RINQ_CACHE_TTL", func() {
+		It("returns a CacheTTL option", func() {
+			os.Setenv("RINQ_CACHE_TTL", "1500")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.CacheTTL).To(Equal(1500 * time.Millisecond))
+		})
+
+		It("returns an error if the value is not a positive integer", func() {
+			os.Setenv("RINQ_CACHE_TTL", "-500")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_CACHE_SIZE", func() {
+		It("returns a CacheSize option", func() {
+			os.Setenv("RINQ_CACHE_SIZE", "1000")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.CacheSize).To(Equal(uint(1000)))
+		})
+
+		It("returns an error if the value is not a positive integer", func() {
+			os.Setenv("RINQ_CACHE_SIZE", "-500")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_NOT_FOUND_TTL", func() {
+		It("returns a NotFoundTTL option", func() {
+			os.Setenv("RINQ_NOT_FOUND_TTL", "1500")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.NotFoundTTL).To(Equal(1500 * time.Millisecond))
+		})
+
+		It("returns an error if the value is not a positive integer", func() {
+			os.Setenv("RINQ_NOT_FOUND_TTL", "-500")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_REPLAY_WINDOW", func() {
+		It("returns a ReplayWindow option", func() {
+			os.Setenv("RINQ_REPLAY_WINDOW", "1500")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.ReplayWindow).To(Equal(1500 * time.Millisecond))
+		})
+
+		It("returns an error if the value is not a positive integer", func() {
+			os.Setenv("RINQ_REPLAY_WINDOW", "-500")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_BALANCING", func() {
+		It("returns a Balancing option", func() {
+			os.Setenv("RINQ_BALANCING", "least-pending")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.Balancing).To(Equal(options.LeastPendingBalancing))
+		})
+
+		It("returns an error if the value is not a known strategy", func() {
+			os.Setenv("RINQ_BALANCING", "<invalid>")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_STICKY_SESSIONS", func() {
+		It("returns a StickySessions option", func() {
+			os.Setenv("RINQ_STICKY_SESSIONS", "true")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.StickySessions).To(BeTrue())
+		})
+
+		It("returns an error if the value is not a boolean", func() {
+			os.Setenv("RINQ_STICKY_SESSIONS", "<invalid>")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_TENANT", func() {
+		It("returns a Tenant option", func() {
+			os.Setenv("RINQ_TENANT", "staging")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.Tenant).To(Equal("staging"))
+		})
+	})
+
+	Context("RINQ_NOTIFY_BATCH", func() {
+		It("returns a NotifyBatch option", func() {
+			os.Setenv("RINQ_NOTIFY_BATCH", "5")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.NotifyBatch).To(Equal(5 * time.Millisecond))
+		})
+
+		It("returns an error if the value is not a positive integer", func() {
+			os.Setenv("RINQ_NOTIFY_BATCH", "-5")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_CANONICAL", func() {
+		It("returns a Canonical option", func() {
+			os.Setenv("RINQ_CANONICAL", "true")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.Canonical).To(BeTrue())
+		})
+
+		It("returns an error if the value is not a boolean", func() {
+			os.Setenv("RINQ_CANONICAL", "<invalid>")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	Context("RINQ_PRODUCT", func() {
 		It("returns a Product option", func() {
 			os.Setenv("RINQ_PRODUCT", "my-app")
@@ -161,4 +366,284 @@ var _ = Describe("FromEnv", func() {
 			Expect(opts.Product).To(Equal("my-app"))
 		})
 	})
+
+	Context("RINQ_LISTENER_CONCURRENCY", func() {
+		It("returns a ListenerConcurrency option", func() {
+			os.Setenv("RINQ_LISTENER_CONCURRENCY", "3")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.ListenerConcurrency).To(Equal(uint(3)))
+		})
+
+		It("returns an error if the value is not a positive integer", func() {
+			os.Setenv("RINQ_LISTENER_CONCURRENCY", "-3")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_LISTENER_BUFFER", func() {
+		It("returns a ListenerBuffer option", func() {
+			os.Setenv("RINQ_LISTENER_BUFFER", "100")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.ListenerBuffer).To(Equal(uint(100)))
+		})
+
+		It("returns an error if the value is not a positive integer", func() {
+			os.Setenv("RINQ_LISTENER_BUFFER", "-100")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_LISTENER_OVERFLOW", func() {
+		It("returns a ListenerOverflow option", func() {
+			os.Setenv("RINQ_LISTENER_OVERFLOW", "drop-oldest")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.ListenerOverflow).To(Equal(options.DropOldestOverflow))
+		})
+
+		It("returns an error if the value is not a known policy", func() {
+			os.Setenv("RINQ_LISTENER_OVERFLOW", "<invalid>")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_ORDERED_NOTIFICATIONS", func() {
+		It("returns an OrderedNotifications option", func() {
+			os.Setenv("RINQ_ORDERED_NOTIFICATIONS", "true")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.OrderedNotifications).To(BeTrue())
+		})
+
+		It("returns an error if the value is not a boolean", func() {
+			os.Setenv("RINQ_ORDERED_NOTIFICATIONS", "<invalid>")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_HOP_MARGIN", func() {
+		It("returns a HopMargin option", func() {
+			os.Setenv("RINQ_HOP_MARGIN", "50")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.HopMargin).To(Equal(50 * time.Millisecond))
+		})
+
+		It("returns an error if the value is not a positive integer", func() {
+			os.Setenv("RINQ_HOP_MARGIN", "-500")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_GROUPS", func() {
+		It("returns a Group option for each group", func() {
+			os.Setenv("RINQ_GROUPS", "region=eu-west, tier=gold")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.Groups).To(Equal([]string{"region=eu-west", "tier=gold"}))
+		})
+	})
+
+	Context("RINQ_DEADLINE_DIAGNOSTICS", func() {
+		It("returns a DeadlineDiagnostics option", func() {
+			os.Setenv("RINQ_DEADLINE_DIAGNOSTICS", "true")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.DeadlineDiagnostics).To(BeTrue())
+		})
+
+		It("returns an error if the value is not a boolean", func() {
+			os.Setenv("RINQ_DEADLINE_DIAGNOSTICS", "<invalid>")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_SLOW_HANDLER_THRESHOLD", func() {
+		It("returns a SlowHandlerThreshold option", func() {
+			os.Setenv("RINQ_SLOW_HANDLER_THRESHOLD", "250")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.SlowHandlerThreshold).To(Equal(250 * time.Millisecond))
+		})
+
+		It("returns an error if the value is not a positive integer", func() {
+			os.Setenv("RINQ_SLOW_HANDLER_THRESHOLD", "-500")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_PAUSE_WHEN_BLOCKED", func() {
+		It("returns a PauseWhenBlocked option", func() {
+			os.Setenv("RINQ_PAUSE_WHEN_BLOCKED", "true")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.PauseWhenBlocked).To(BeTrue())
+		})
+
+		It("returns an error if the value is not a boolean", func() {
+			os.Setenv("RINQ_PAUSE_WHEN_BLOCKED", "<invalid>")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_ADAPTIVE_COMMAND_WORKERS", func() {
+		It("returns an AdaptiveCommandWorkers option", func() {
+			os.Setenv("RINQ_ADAPTIVE_COMMAND_WORKERS", "250")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.AdaptiveCommandWorkers).To(Equal(250 * time.Millisecond))
+		})
+
+		It("returns an error if the value is not a positive integer", func() {
+			os.Setenv("RINQ_ADAPTIVE_COMMAND_WORKERS", "-500")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_RESTARTABLE", func() {
+		It("returns a Restartable option", func() {
+			os.Setenv("RINQ_RESTARTABLE", "true")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.Restartable).To(BeTrue())
+		})
+
+		It("returns an error if the value is not a boolean", func() {
+			os.Setenv("RINQ_RESTARTABLE", "<invalid>")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_QUORUM_QUEUES", func() {
+		It("returns a DefaultQueue option", func() {
+			os.Setenv("RINQ_QUORUM_QUEUES", "true")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.DefaultQueue).To(Equal(options.ListenOptions{Quorum: true}))
+		})
+
+		It("returns an error if the value is not a boolean", func() {
+			os.Setenv("RINQ_QUORUM_QUEUES", "<invalid>")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("RINQ_NAME_PREFIX", func() {
+		It("returns a NamePrefix option", func() {
+			os.Setenv("RINQ_NAME_PREFIX", "acme.")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.NamePrefix).To(Equal("acme."))
+		})
+	})
+
+	Context("RINQ_BUFFER_POOL_MAX_SIZE", func() {
+		It("returns a BufferPoolMaxSize option", func() {
+			os.Setenv("RINQ_BUFFER_POOL_MAX_SIZE", "1024")
+			o, err := options.FromEnv()
+
+			Expect(err).NotTo(HaveOccurred())
+
+			opts, err := options.NewOptions(o...)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.BufferPoolMaxSize).To(Equal(uint(1024)))
+		})
+
+		It("returns an error if the value is not a positive integer", func() {
+			os.Setenv("RINQ_BUFFER_POOL_MAX_SIZE", "-1024")
+			_, err := options.FromEnv()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
 })
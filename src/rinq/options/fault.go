@@ -0,0 +1,65 @@
+package options
+
+import (
+	"errors"
+	"time"
+)
+
+// FaultOptions describes faults that a peer injects into the messages that it
+// sends, so that applications can be tested against a misbehaving broker.
+//
+// Each message is subject to each fault independently. Faults are chosen using
+// a pseudo-random source seeded with Seed, so a sequence of messages sent in
+// the same order is subject to the same faults on every run. The sequence
+// continues across calls to Peer.Restart(), it is not restarted from the seed.
+// The zero value injects no faults.
+type FaultOptions struct {
+	// Seed is the seed for the pseudo-random source used to choose faults.
+	Seed int64
+
+	// Latency is the maximum delay added before each message is sent. The
+	// delay for each message is chosen uniformly between zero and Latency.
+	Latency time.Duration
+
+	// DropRate is the probability that a message is discarded instead of
+	// being sent, from 0 to 1.
+	DropRate float64
+
+	// DuplicateRate is the probability that a message is sent twice, from 0
+	// to 1. It does not apply to command calls that block for a response.
+	DuplicateRate float64
+
+	// ReorderRate is the probability that a message is held back until after
+	// the next message is sent, from 0 to 1. It does not apply to command
+	// calls that block for a response.
+	ReorderRate float64
+}
+
+// IsZero returns true if o injects no faults.
+func (o FaultOptions) IsZero() bool {
+	return o.Latency == 0 &&
+		o.DropRate == 0 &&
+		o.DuplicateRate == 0 &&
+		o.ReorderRate == 0
+}
+
+// validate returns an error if o describes faults that can not be injected.
+func (o FaultOptions) validate() error {
+	if o.Latency < 0 {
+		return errors.New("latency must not be negative")
+	}
+
+	if o.DropRate < 0 || o.DropRate > 1 {
+		return errors.New("drop rate must be between 0 and 1")
+	}
+
+	if o.DuplicateRate < 0 || o.DuplicateRate > 1 {
+		return errors.New("duplicate rate must be between 0 and 1")
+	}
+
+	if o.ReorderRate < 0 || o.ReorderRate > 1 {
+		return errors.New("reorder rate must be between 0 and 1")
+	}
+
+	return nil
+}
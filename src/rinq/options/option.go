@@ -5,6 +5,11 @@ import (
 
 	"github.com/jmalloc/twelf/src/twelf"
 	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rinq/rinq-go/src/internal/namespaces"
+	"github.com/rinq/rinq-go/src/rinq/clock"
+	"github.com/rinq/rinq-go/src/rinq/dedupe"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/metrics"
 )
 
 // Option is a function that applies a configuration change.
@@ -51,6 +56,149 @@ func PruneInterval(t time.Duration) Option {
 	}
 }
 
+// CacheTTL returns an Option that specifies how long information about a remote
+// session is kept in the cache after it was last used.
+//
+// A value of zero, the default, means that information is removed after it has
+// gone unused for two consecutive prune intervals.
+func CacheTTL(t time.Duration) Option {
+	return func(v visitor) error {
+		return v.applyCacheTTL(t)
+	}
+}
+
+// CacheSize returns an Option that specifies the maximum number of remote
+// sessions kept in the cache. When the limit is reached, the least recently
+// used session is evicted.
+//
+// A value of zero, the default, means that the cache size is unbounded.
+func CacheSize(n uint) Option {
+	return func(v visitor) error {
+		return v.applyCacheSize(n)
+	}
+}
+
+// NotFoundTTL returns an Option that specifies how long the knowledge that a
+// remote session has been destroyed is retained after the session has been
+// removed from the cache.
+//
+// While retained, operations on the destroyed session fail immediately without
+// querying the owning peer. A value of zero, the default, means that this
+// knowledge is discarded along with the cache entry.
+func NotFoundTTL(t time.Duration) Option {
+	return func(v visitor) error {
+		return v.applyNotFoundTTL(t)
+	}
+}
+
+// ReplayWindow returns an Option that specifies how long the response to each
+// command request is retained by the server.
+//
+// If the broker redelivers a request within this window, for example after the
+// server's consumer reconnects, the original response is sent again instead of
+// invoking the command handler a second time. A value of zero, the default,
+// disables duplicate suppression.
+func ReplayWindow(t time.Duration) Option {
+	return func(v visitor) error {
+		return v.applyReplayWindow(t)
+	}
+}
+
+// Balancing returns an Option that specifies the strategy used to select the
+// peer that services each load-balanced command call.
+//
+// Strategies other than BrokerBalancing rely on the peers announcing the
+// namespaces they listen to. Calls are left to the broker until such an
+// announcement has been received. Asynchronous calls and executions are always
+// balanced by the broker.
+//
+// A call is sent directly to the selected peer, it is not redirected to
+// another peer if the selected peer stops without warning. Such a peer
+// remains a candidate until its last announcement expires, which takes up to
+// 30 seconds, and calls sent to it during that time fail only when their
+// deadline is reached.
+func Balancing(s BalanceStrategy) Option {
+	return func(v visitor) error {
+		return v.applyBalancing(s)
+	}
+}
+
+// StickySessions returns an Option that specifies whether all load-balanced
+// calls made by a session are sent to the same peer.
+//
+// When enabled, the peer is chosen by consistent hashing of the session ID. The
+// session remains with that peer for as long as the peer continues to listen
+// to the namespace, even if other peers begin listening. This allows servers
+// to keep "warm" per-session state. Stickiness takes precedence over the
+// strategy specified by Balancing().
+func StickySessions(enabled bool) Option {
+	return func(v visitor) error {
+		return v.applyStickySessions(enabled)
+	}
+}
+
+// Tenant returns an Option that specifies the tenant that the peer belongs to.
+//
+// Tenants allow several logical environments to share a single broker. Peers
+// only exchange command requests and notifications with peers of the same
+// tenant, namespaces used by one tenant never collide with those used by
+// another. Requests that originate from another tenant are rejected.
+//
+// Valid characters are alpha-numeric characters, underscores, hyphens, periods
+// and colons. The default is the empty string, which is itself a tenant.
+func Tenant(t string) Option {
+	return func(v visitor) error {
+		return v.applyTenant(t)
+	}
+}
+
+// NotifyBatch returns an Option that specifies how long notifications are held
+// so that they can be published to the broker together.
+//
+// Notifications sent within the window are published in a single batch, reducing
+// the overhead of sending many notifications in quick succession. Errors that
+// occur while publishing a batch are logged rather than returned to the sender.
+// A value of zero, the default, publishes each notification immediately.
+func NotifyBatch(t time.Duration) Option {
+	return func(v visitor) error {
+		return v.applyNotifyBatch(t)
+	}
+}
+
+// Canonical returns an Option that specifies whether payloads are encoded in
+// canonical form.
+//
+// Canonical encoding ensures that equal payload values always produce identical
+// binary representations, regardless of which peer encoded them, which allows
+// payloads to be hashed or signed. It is somewhat slower than the default
+// encoding.
+//
+// Payload encoding is shared by all peers within a process, once any peer
+// enables canonical encoding it remains enabled.
+func Canonical(enabled bool) Option {
+	return func(v visitor) error {
+		return v.applyCanonical(enabled)
+	}
+}
+
+// Prefetch returns an Option that specifies attributes within the ns namespace
+// that are always fetched together from a remote session.
+//
+// When any attribute in ns that is not in the cache is requested from a remote
+// session, the attributes in keys are fetched in the same request. This allows
+// a command server to avoid a separate network round-trip for each attribute
+// it reads from a cold cache.
+//
+// The option may be specified multiple times to prefetch additional keys or
+// namespaces.
+func Prefetch(ns string, keys ...string) Option {
+	namespaces.MustValidate(ns)
+
+	return func(v visitor) error {
+		return v.applyPrefetch(ns, keys)
+	}
+}
+
 // Product returns an Option that specifies an application-defined string that
 // identifies the application.
 //
@@ -71,3 +219,469 @@ func Tracer(t opentracing.Tracer) Option {
 		return v.applyTracer(t)
 	}
 }
+
+// Metrics returns an Option that specifies a recorder to use for collecting
+// measurements about command requests, such as counts, latencies and failure
+// types.
+//
+// metrics.NewCollector() returns a recorder that keeps these statistics in
+// memory.
+func Metrics(r metrics.Recorder) Option {
+	return func(v visitor) error {
+		return v.applyMetrics(r)
+	}
+}
+
+// ListenerConcurrency returns an Option that specifies the maximum number of
+// notification handlers that are invoked concurrently for each session.
+//
+// Notifications that arrive while a session is at this limit are held in the
+// session's buffer, see ListenerBuffer(). A value of zero, the default, means
+// that there is no limit, and each notification is handled as soon as it is
+// received.
+func ListenerConcurrency(n uint) Option {
+	return func(v visitor) error {
+		return v.applyListenerConcurrency(n)
+	}
+}
+
+// ListenerBuffer returns an Option that specifies the number of notifications
+// that are buffered for each session while its handlers are at the limit set
+// by ListenerConcurrency().
+//
+// When the buffer is full the overflow policy is applied, see
+// ListenerOverflow(). The buffer is not used if there is no concurrency limit.
+func ListenerBuffer(n uint) Option {
+	return func(v visitor) error {
+		return v.applyListenerBuffer(n)
+	}
+}
+
+// ListenerOverflow returns an Option that specifies what happens to a
+// notification that arrives for a session whose buffer is full.
+//
+// The default is BlockOverflow. Notifications that are discarded by other
+// policies are logged, but are otherwise lost.
+func ListenerOverflow(p OverflowPolicy) Option {
+	return func(v visitor) error {
+		return v.applyListenerOverflow(p)
+	}
+}
+
+// OrderedNotifications returns an Option that specifies whether notifications
+// are delivered to each session in the order they are received from the
+// broker.
+//
+// Notifications from a single sending session are received in the order they
+// were published. When enabled, each session handles its notifications one at a
+// time, in order, overriding ListenerConcurrency(). Notifications for different
+// sessions are still handled concurrently. Under BlockOverflow, a session whose
+// buffer is full delays the acknowledgement of its notifications, but not the
+// delivery of notifications to other sessions.
+func OrderedNotifications(enabled bool) Option {
+	return func(v visitor) error {
+		return v.applyOrderedNotifications(enabled)
+	}
+}
+
+// Queue returns an Option that specifies how the broker queue that distributes
+// balanced command requests in the ns namespace is declared, at all API
+// versions.
+//
+// Queues are declared by both the peers that send requests and those that
+// listen for them, and the broker rejects declarations that differ from that
+// of an existing queue. All peers that use ns must therefore be configured with
+// the same options. Specifying the option again for the same namespace
+// replaces the previous options.
+func Queue(ns string, o ListenOptions) Option {
+	namespaces.MustValidate(ns)
+
+	return func(v visitor) error {
+		return v.applyQueue(ns, o)
+	}
+}
+
+// HopMargin returns an Option that specifies a safety margin that is subtracted
+// from the deadline of each command request before it is passed to a command
+// handler.
+//
+// The margin leaves time for the handler's response to reach the caller before
+// the caller's deadline passes. Because the handler's context carries the
+// reduced deadline, each call made by the handler using that context is
+// reduced again by the next server, such that nested call chains consume the
+// deadline one hop at a time. Handlers can use rinq.Budget() to find the time
+// that remains. The default is zero.
+func HopMargin(t time.Duration) Option {
+	return func(v visitor) error {
+		return v.applyHopMargin(t)
+	}
+}
+
+// Group returns an Option that adds the peer to a named group, such as
+// "region=eu-west". It may be given several times to join several groups.
+//
+// Group names are free-form, but must not be empty or contain commas. A peer's
+// groups are included in its presence announcements, allowing other peers to
+// restrict load-balanced command requests to the members of a group, see
+// rinq.WithGroup().
+func Group(name string) Option {
+	return func(v visitor) error {
+		return v.applyGroup(name)
+	}
+}
+
+// Clock returns an Option that specifies the clock used to measure cache and
+// presence expiry, the replay window and the deadlines of asynchronous calls.
+//
+// The default is clock.System. Tests and simulations can use a clock.Manual to
+// advance time deterministically. Context deadlines, including those of
+// synchronous calls, are always measured against the system time.
+func Clock(c clock.Clock) Option {
+	return func(v visitor) error {
+		return v.applyClock(c)
+	}
+}
+
+// DeadlineDiagnostics returns an Option that specifies whether command calls
+// that exceed their deadline report how far the request progressed.
+//
+// When enabled, servers are asked to report when they consume each request,
+// and again if the handler is still running when the server's deadline passes.
+// Calls that exceed their deadline then fail with a rinq.DeadlineExceededError
+// rather than context.DeadlineExceeded. The reports add up to two messages per
+// call, so diagnostics are disabled by default.
+//
+// Servers report a running handler at their own deadline, which is only
+// earlier than the caller's if the server has a non-zero HopMargin(). Without
+// a margin, a running handler may be reported as a lost response.
+func DeadlineDiagnostics(enabled bool) Option {
+	return func(v visitor) error {
+		return v.applyDeadlineDiagnostics(enabled)
+	}
+}
+
+// SlowHandlerThreshold returns an Option that specifies how long a command
+// handler may take before it is reported as slow.
+//
+// Each request whose handler takes longer than t is logged along with the
+// namespace, command, elapsed time and trace ID, even if the handler completes
+// within the request's deadline. It is also passed to the metrics recorder if
+// it implements metrics.SlowHandlerRecorder. A value of zero, the default,
+// disables slow handler detection.
+func SlowHandlerThreshold(t time.Duration) Option {
+	return func(v visitor) error {
+		return v.applySlowHandlerThreshold(t)
+	}
+}
+
+// HandlerTimeout returns an Option that specifies the maximum time that command
+// handlers for the ns namespace may run, regardless of the caller's deadline.
+//
+// If a handler has not responded once t has elapsed, its context is canceled
+// and the caller is sent a failure with a type of
+// rinq.HandlerTimeoutFailureType. Any response written by the handler after
+// that point is discarded. Handlers can not be stopped forcefully, so the
+// request continues to occupy a worker until the handler returns. Specifying
+// the option again for the same namespace replaces the previous timeout.
+func HandlerTimeout(ns string, t time.Duration) Option {
+	namespaces.MustValidate(ns)
+
+	return func(v visitor) error {
+		return v.applyHandlerTimeout(ns, t)
+	}
+}
+
+// PauseWhenBlocked returns an Option that specifies whether command calls and
+// notifications wait for the broker to unblock the peer's connection before
+// they are published.
+//
+// The broker blocks connections that publish messages while it has raised a
+// memory or disk alarm. Regardless of this option, the peer emits a
+// rinq.ConnectionBlockedEvent when this occurs. When enabled, calls and
+// notifications made while the connection is blocked wait for it to be
+// unblocked, failing with the context's error if it is done first, instead of
+// stalling inside the AMQP client.
+func PauseWhenBlocked(enabled bool) Option {
+	return func(v visitor) error {
+		return v.applyPauseWhenBlocked(enabled)
+	}
+}
+
+// AdaptiveCommandWorkers returns an Option that adjusts the number of command
+// requests the peer handles concurrently based on the observed performance of
+// its command handlers.
+//
+// The value given to CommandWorkers() becomes the upper bound. If the mean time
+// taken by command handlers exceeds target, or more than a tenth of requests
+// result in an error, the limit is halved. Otherwise it is increased by one.
+// Adjustments are made once per second. Failures are considered a normal
+// outcome and do not count as errors. A value of zero, the default, disables
+// adaptive concurrency.
+func AdaptiveCommandWorkers(target time.Duration) Option {
+	return func(v visitor) error {
+		return v.applyAdaptiveCommandWorkers(target)
+	}
+}
+
+// Restartable returns an Option that specifies whether the peer keeps running
+// when its connection to the broker is lost, or one of its consumers fails.
+//
+// By default the peer stops in these cases, destroying its sessions. When
+// enabled, the peer emits the usual event and then remains disconnected,
+// preserving its sessions and command handlers, until Peer.Restart() or
+// Peer.Stop() is called. Operations attempted while the peer is disconnected
+// fail.
+func Restartable(enabled bool) Option {
+	return func(v visitor) error {
+		return v.applyRestartable(enabled)
+	}
+}
+
+// DefaultQueue returns an Option that specifies how the broker queues that
+// distribute balanced command requests are declared for namespaces that are
+// not configured with Queue().
+//
+// For example, DefaultQueue(ListenOptions{Quorum: true, DeliveryLimit: 5})
+// declares every command queue as a replicated quorum queue that discards
+// requests after five deliveries. As with Queue(), all peers that listen to a
+// namespace must declare its queue with the same options.
+func DefaultQueue(o ListenOptions) Option {
+	return func(v visitor) error {
+		return v.applyDefaultQueue(o)
+	}
+}
+
+// Stream returns an Option that retains the multicast notifications sent in
+// the ns namespace in a RabbitMQ stream, so that they can be replayed with
+// Peer.ListenStream().
+//
+// The peer declares the stream when it connects, so that notifications are
+// retained even when no consumers are running. As with Queue(), the broker
+// rejects declarations that differ from that of an existing stream, so all
+// peers that declare the stream must be configured with the same options.
+// Specifying the option again for the same namespace replaces the previous
+// options.
+func Stream(ns string, o StreamOptions) Option {
+	namespaces.MustValidate(ns)
+
+	return func(v visitor) error {
+		return v.applyStream(ns, o)
+	}
+}
+
+// NamePrefix returns an Option that specifies a prefix that is added to the
+// name of every exchange and queue that the peer declares on the broker.
+//
+// Prefixes allow several independent Rinq deployments to share a single
+// virtual host, and allow the names to conform to a naming policy, such as
+// "acme.rinq.". Unlike Tenant(), which isolates peers that share the same
+// exchanges and queues, peers with different prefixes use entirely separate
+// resources. All peers that communicate with each other must use the same
+// prefix.
+//
+// Valid characters are alpha-numeric characters, underscores, hyphens, periods
+// and colons. The prefix must not begin with "amq.", which is reserved by the
+// broker. The default is the empty string, which leaves names unchanged.
+func NamePrefix(p string) Option {
+	return func(v visitor) error {
+		return v.applyNamePrefix(p)
+	}
+}
+
+// AttrQuota returns an Option that limits the attributes that each session
+// owned by the peer may hold.
+//
+// Updates that would exceed the quota fail with a rinq.QuotaExceededError,
+// leaving the session unchanged. The quota applies to every update of a
+// session owned by the peer, including those made by other peers. Clearing
+// attributes releases their share of the quota. The default is
+// QuotaOptions{}, which imposes no limits.
+func AttrQuota(q QuotaOptions) Option {
+	return func(v visitor) error {
+		return v.applyAttrQuota(q)
+	}
+}
+
+// FaultInjection returns an Option that injects faults into the messages that
+// the peer sends, such as added latency, dropped, duplicated and reordered
+// messages.
+//
+// It is intended for functional tests that verify an application's behavior
+// when the broker misbehaves, and must not be used in production. The default
+// is FaultOptions{}, which injects no faults.
+func FaultInjection(f FaultOptions) Option {
+	return func(v visitor) error {
+		return v.applyFaultInjection(f)
+	}
+}
+
+// PeerIDGenerator returns an Option that replaces the function used to
+// generate the peer's ID when it connects to the network.
+//
+// It allows the peer ID to be derived from an operator-supplied identity, such
+// as the ordinal of a replica, so that the peer has the same ID each time it is
+// started and can be correlated with orchestration metadata.
+//
+// The random component of each peer's ID must be unique among the peers that
+// are connected to the network at any given time. If the generated ID is in
+// use by another peer, fn is called again. If it returns the same ID, the peer
+// waits for the ID to be released, or for the dial context to be done.
+//
+// Session IDs are derived from the peer ID, so a peer that has the same ID as
+// a previous peer also reuses that peer's session IDs. Other peers discard the
+// state that they have cached for the previous peer's sessions once they
+// receive the first presence announcement from the new peer, but until then
+// they may serve that state in place of the new peer's sessions. Only the
+// random component of the ID needs to be unique, so to avoid this, derive just
+// the Rand component from the operator-supplied identity, and set the Clock
+// component to the current time, as ident.NewPeerID() does.
+//
+// The default is ident.NewPeerID().
+func PeerIDGenerator(fn func() ident.PeerID) Option {
+	return func(v visitor) error {
+		return v.applyPeerIDGenerator(fn)
+	}
+}
+
+// AttrFeed returns an Option that publishes changes to the attributes within
+// the ns namespace of each session owned by the peer.
+//
+// Each change is sent as a multicast notification of type
+// rinq.AttrChangeNotification within the ns namespace, allowing other services
+// to maintain their own view of session state by listening to ns, rather than
+// by querying sessions from within the call path. See
+// rinq.AttrChangeNotification for a description of the notification.
+//
+// The option may be specified multiple times to publish several namespaces.
+func AttrFeed(ns string) Option {
+	namespaces.MustValidate(ns)
+
+	return func(v visitor) error {
+		return v.applyAttrFeed(ns)
+	}
+}
+
+// PayloadLogging returns an Option that controls how payloads are rendered in
+// the peer's debug log messages.
+//
+// Payloads are rendered in full by default, which can make debug logging too
+// expensive, or too verbose, to enable in production. Omitting payloads logs
+// the beginning and end of each call without its content, and truncating
+// payloads limits the size of each log message. The default is
+// PayloadLogOptions{}, which renders each payload in full.
+func PayloadLogging(p PayloadLogOptions) Option {
+	return func(v visitor) error {
+		return v.applyPayloadLogging(p)
+	}
+}
+
+// PayloadRedactor returns an Option that masks sensitive content, such as
+// tokens or personal information, before payloads are rendered in the peer's
+// log messages.
+//
+// fn is called with the value of each payload that is logged, as per
+// rinq.Payload.Value(), and returns the value to render in its place. The
+// value is decoded separately for each log message, so fn may modify it in
+// place. The payloads that are sent and
+// received are never modified. fn is not called if payloads are omitted from
+// log messages, see PayloadLogging().
+//
+// By default, payloads are rendered without modification.
+func PayloadRedactor(fn func(v interface{}) interface{}) Option {
+	return func(v visitor) error {
+		return v.applyPayloadRedactor(fn)
+	}
+}
+
+// Sampling returns an Option that records the debug log messages and tracing
+// spans for only a fraction of the command requests and notifications within
+// the ns namespace, such as 0.01 for 1%.
+//
+// It allows debug logging and tracing to remain enabled for namespaces that
+// carry too much traffic to record in full. The decision is made per trace,
+// so each sampled trace is recorded in full by every peer that is configured
+// with the same rate. Messages that are logged regardless of the debug setting
+// are not affected.
+//
+// The option may be specified multiple times to sample several namespaces.
+// By default, every message is recorded.
+func Sampling(ns string, rate float64) Option {
+	namespaces.MustValidate(ns)
+
+	return func(v visitor) error {
+		return v.applySampling(ns, rate)
+	}
+}
+
+// SessionLimit returns an Option that limits the number of sessions that the
+// peer may own, and the rate at which they may be created.
+//
+// Peer.Session() returns a rinq.SessionLimitError if creating a session would
+// exceed either limit. Sessions count towards the limit until they are
+// destroyed. The default is SessionLimitOptions{}, which imposes no limits.
+func SessionLimit(l SessionLimitOptions) Option {
+	return func(v visitor) error {
+		return v.applySessionLimit(l)
+	}
+}
+
+// AtLeastOnce returns an Option that guarantees that each command request
+// sent to the ns namespace by Session.Execute() is handled at least once.
+//
+// Execute() does not return until the broker has confirmed that it has
+// persisted the request. The request is always queued by the broker, rather
+// than sent to a specific peer, and it does not expire when the context passed
+// to Execute() is canceled or reaches its deadline. Requests that are not
+// acknowledged by a command handler, such as when a peer crashes, are
+// redelivered to another peer.
+//
+// Redelivered requests that have already been handled by the receiving peer
+// are acknowledged without invoking the command handler again, see
+// DedupeStore(). Command handlers must still tolerate being invoked more than
+// once for the same request, such as when a peer stops after handling a request
+// but before acknowledging it.
+// The namespace's queue must not be transient.
+//
+// The option may be specified multiple times to apply to several namespaces.
+// By default, no namespaces use at-least-once execution.
+func AtLeastOnce(ns string) Option {
+	namespaces.MustValidate(ns)
+
+	return func(v visitor) error {
+		return v.applyAtLeastOnce(ns)
+	}
+}
+
+// DedupeStore returns an Option that sets the store used to detect command
+// requests that are redelivered to the peer after they have been handled.
+//
+// The store is consulted before invoking the command handler for requests sent
+// by Session.Execute() to namespaces that use at-least-once execution, see
+// AtLeastOnce(). Requests that the store reports as already handled are
+// acknowledged without invoking the handler, and each request is marked as
+// handled once it has been acknowledged. A store that is shared between
+// peers, such as one backed by Redis, also detects requests that are
+// redelivered to a different peer.
+//
+// By default, each peer uses a dedupe.MemoryStore of dedupe.DefaultMemorySize.
+func DedupeStore(s dedupe.Store) Option {
+	return func(v visitor) error {
+		return v.applyDedupeStore(s)
+	}
+}
+
+// BufferPoolMaxSize returns an Option that specifies the maximum capacity, in
+// bytes, of a buffer that is returned to the buffer pool once it is no longer
+// needed. Larger buffers are discarded, so that a single large payload does
+// not cause the pool to retain a large amount of memory indefinitely.
+//
+// The buffer pool is shared by every peer in the process, so the value given
+// to the most recently dialed peer applies to all of them. A value of zero
+// means that buffers of any size are retained. The default is 64 KiB.
+//
+// The use of the pool is reported by Peer.Stats().
+func BufferPoolMaxSize(n uint) Option {
+	return func(v visitor) error {
+		return v.applyBufferPoolMaxSize(n)
+	}
+}
@@ -1,21 +1,68 @@
 package options
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/jmalloc/twelf/src/twelf"
 	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rinq/rinq-go/src/rinq/clock"
+	"github.com/rinq/rinq-go/src/rinq/dedupe"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/metrics"
 )
 
 // Options is a structure representing a resolved set of options.
 type Options struct {
-	DefaultTimeout time.Duration
-	Logger         twelf.Logger
-	CommandWorkers uint
-	SessionWorkers uint
-	PruneInterval  time.Duration
-	Product        string
-	Tracer         opentracing.Tracer
+	DefaultTimeout         time.Duration
+	Logger                 twelf.Logger
+	CommandWorkers         uint
+	SessionWorkers         uint
+	PruneInterval          time.Duration
+	CacheTTL               time.Duration
+	CacheSize              uint
+	NotFoundTTL            time.Duration
+	ReplayWindow           time.Duration
+	Balancing              BalanceStrategy
+	StickySessions         bool
+	Tenant                 string
+	NotifyBatch            time.Duration
+	Canonical              bool
+	Prefetch               map[string][]string
+	Product                string
+	Tracer                 opentracing.Tracer
+	Metrics                metrics.Recorder
+	ListenerConcurrency    uint
+	ListenerBuffer         uint
+	ListenerOverflow       OverflowPolicy
+	OrderedNotifications   bool
+	Queues                 map[string]ListenOptions
+	HopMargin              time.Duration
+	Groups                 []string
+	Clock                  clock.Clock
+	DeadlineDiagnostics    bool
+	SlowHandlerThreshold   time.Duration
+	HandlerTimeouts        map[string]time.Duration
+	PauseWhenBlocked       bool
+	AdaptiveCommandWorkers time.Duration
+	Restartable            bool
+	DefaultQueue           ListenOptions
+	Streams                map[string]StreamOptions
+	NamePrefix             string
+	AttrQuota              QuotaOptions
+	FaultInjection         FaultOptions
+	PeerIDGenerator        func() ident.PeerID
+	AttrFeed               []string
+	PayloadLogging         PayloadLogOptions
+	PayloadRedactor        func(v interface{}) interface{}
+	Sampling               map[string]float64
+	SessionLimit           SessionLimitOptions
+	AtLeastOnce            []string
+	DedupeStore            dedupe.Store
+	BufferPoolMaxSize      uint
 }
 
 // NewOptions returns a new Options object from the given options, with default
@@ -59,6 +106,78 @@ func (o *Options) applyPruneInterval(v time.Duration) error {
 	return nil
 }
 
+// applyCacheTTL sets the CacheTTL value.
+func (o *Options) applyCacheTTL(v time.Duration) error {
+	o.CacheTTL = v
+	return nil
+}
+
+// applyCacheSize sets the CacheSize value.
+func (o *Options) applyCacheSize(v uint) error {
+	o.CacheSize = v
+	return nil
+}
+
+// applyNotFoundTTL sets the NotFoundTTL value.
+func (o *Options) applyNotFoundTTL(v time.Duration) error {
+	o.NotFoundTTL = v
+	return nil
+}
+
+// applyReplayWindow sets the ReplayWindow value.
+func (o *Options) applyReplayWindow(v time.Duration) error {
+	o.ReplayWindow = v
+	return nil
+}
+
+// applyBalancing sets the Balancing value.
+func (o *Options) applyBalancing(v BalanceStrategy) error {
+	if _, ok := balanceStrategyNames[v]; !ok {
+		return fmt.Errorf("unknown balancing strategy: %s", v)
+	}
+
+	o.Balancing = v
+	return nil
+}
+
+// applyStickySessions sets the StickySessions value.
+func (o *Options) applyStickySessions(v bool) error {
+	o.StickySessions = v
+	return nil
+}
+
+// applyTenant sets the Tenant value.
+func (o *Options) applyTenant(v string) error {
+	if v != "" && !tenantPattern.MatchString(v) {
+		return fmt.Errorf("tenant '%s' contains invalid characters", v)
+	}
+
+	o.Tenant = v
+	return nil
+}
+
+// applyNotifyBatch sets the NotifyBatch value.
+func (o *Options) applyNotifyBatch(v time.Duration) error {
+	o.NotifyBatch = v
+	return nil
+}
+
+// applyCanonical sets the Canonical value.
+func (o *Options) applyCanonical(v bool) error {
+	o.Canonical = v
+	return nil
+}
+
+// applyPrefetch adds keys to the Prefetch value.
+func (o *Options) applyPrefetch(ns string, keys []string) error {
+	if o.Prefetch == nil {
+		o.Prefetch = map[string][]string{}
+	}
+
+	o.Prefetch[ns] = append(o.Prefetch[ns], keys...)
+	return nil
+}
+
 // applyProduct sets the Product value.
 func (o *Options) applyProduct(v string) error {
 	o.Product = v
@@ -74,3 +193,321 @@ func (o *Options) applyTracer(v opentracing.Tracer) error {
 	o.Tracer = v
 	return nil
 }
+
+// applyMetrics sets the Metrics value.
+func (o *Options) applyMetrics(v metrics.Recorder) error {
+	if v == nil {
+		panic("metrics recorder must not be nil")
+	}
+
+	o.Metrics = v
+	return nil
+}
+
+// applyListenerConcurrency sets the ListenerConcurrency value.
+func (o *Options) applyListenerConcurrency(v uint) error {
+	o.ListenerConcurrency = v
+	return nil
+}
+
+// applyListenerBuffer sets the ListenerBuffer value.
+func (o *Options) applyListenerBuffer(v uint) error {
+	o.ListenerBuffer = v
+	return nil
+}
+
+// applyListenerOverflow sets the ListenerOverflow value.
+func (o *Options) applyListenerOverflow(v OverflowPolicy) error {
+	if _, ok := overflowPolicyNames[v]; !ok {
+		return fmt.Errorf("unknown overflow policy: %s", v)
+	}
+
+	o.ListenerOverflow = v
+	return nil
+}
+
+// applyOrderedNotifications sets the OrderedNotifications value.
+func (o *Options) applyOrderedNotifications(v bool) error {
+	o.OrderedNotifications = v
+	return nil
+}
+
+// applyQueue sets the queue options for the ns namespace.
+func (o *Options) applyQueue(ns string, v ListenOptions) error {
+	if err := v.validate(); err != nil {
+		return fmt.Errorf("invalid queue options for '%s' namespace: %s", ns, err)
+	}
+
+	if v.Transient && o.isAtLeastOnce(ns) {
+		return fmt.Errorf("queue for '%s' namespace can not be transient, as it uses at-least-once execution", ns)
+	}
+
+	if o.Queues == nil {
+		o.Queues = map[string]ListenOptions{}
+	}
+
+	o.Queues[ns] = v
+	return nil
+}
+
+// applyHopMargin sets the HopMargin value.
+func (o *Options) applyHopMargin(v time.Duration) error {
+	if v < 0 {
+		return fmt.Errorf("hop margin must not be negative: %s", v)
+	}
+
+	o.HopMargin = v
+	return nil
+}
+
+// applyGroup adds a group to the Groups value.
+func (o *Options) applyGroup(v string) error {
+	if v == "" {
+		return errors.New("group must not be empty")
+	} else if strings.Contains(v, ",") {
+		return fmt.Errorf("group '%s' must not contain commas", v)
+	}
+
+	for _, g := range o.Groups {
+		if g == v {
+			return nil
+		}
+	}
+
+	o.Groups = append(o.Groups, v)
+	return nil
+}
+
+// applyClock sets the Clock value.
+func (o *Options) applyClock(v clock.Clock) error {
+	if v == nil {
+		panic("clock must not be nil")
+	}
+
+	o.Clock = v
+	return nil
+}
+
+// applyDeadlineDiagnostics sets the DeadlineDiagnostics value.
+func (o *Options) applyDeadlineDiagnostics(v bool) error {
+	o.DeadlineDiagnostics = v
+	return nil
+}
+
+// applySlowHandlerThreshold sets the SlowHandlerThreshold value.
+func (o *Options) applySlowHandlerThreshold(v time.Duration) error {
+	if v < 0 {
+		return fmt.Errorf("slow handler threshold must not be negative: %s", v)
+	}
+
+	o.SlowHandlerThreshold = v
+	return nil
+}
+
+// applyHandlerTimeout sets the handler timeout for the ns namespace.
+func (o *Options) applyHandlerTimeout(ns string, v time.Duration) error {
+	if v <= 0 {
+		return fmt.Errorf("handler timeout for '%s' namespace must be positive: %s", ns, v)
+	}
+
+	if o.HandlerTimeouts == nil {
+		o.HandlerTimeouts = map[string]time.Duration{}
+	}
+
+	o.HandlerTimeouts[ns] = v
+	return nil
+}
+
+// applyPauseWhenBlocked sets the PauseWhenBlocked value.
+func (o *Options) applyPauseWhenBlocked(v bool) error {
+	o.PauseWhenBlocked = v
+	return nil
+}
+
+// applyAdaptiveCommandWorkers sets the AdaptiveCommandWorkers value.
+func (o *Options) applyAdaptiveCommandWorkers(v time.Duration) error {
+	if v < 0 {
+		return fmt.Errorf("adaptive command worker latency target must not be negative: %s", v)
+	}
+
+	o.AdaptiveCommandWorkers = v
+	return nil
+}
+
+// applyRestartable sets the Restartable value.
+func (o *Options) applyRestartable(v bool) error {
+	o.Restartable = v
+	return nil
+}
+
+// applyDefaultQueue sets the DefaultQueue value.
+func (o *Options) applyDefaultQueue(v ListenOptions) error {
+	if err := v.validate(); err != nil {
+		return fmt.Errorf("invalid default queue options: %s", err)
+	}
+
+	if v.Transient {
+		for _, ns := range o.AtLeastOnce {
+			if _, ok := o.Queues[ns]; !ok {
+				return fmt.Errorf("default queue can not be transient, as the '%s' namespace uses at-least-once execution", ns)
+			}
+		}
+	}
+
+	o.DefaultQueue = v
+	return nil
+}
+
+// applyStream sets the stream options for the ns namespace.
+func (o *Options) applyStream(ns string, v StreamOptions) error {
+	if err := v.validate(); err != nil {
+		return fmt.Errorf("invalid stream options for '%s' namespace: %s", ns, err)
+	}
+
+	if o.Streams == nil {
+		o.Streams = map[string]StreamOptions{}
+	}
+
+	o.Streams[ns] = v
+	return nil
+}
+
+// applyNamePrefix sets the NamePrefix value.
+func (o *Options) applyNamePrefix(v string) error {
+	if v != "" && !tenantPattern.MatchString(v) {
+		return fmt.Errorf("name prefix '%s' contains invalid characters", v)
+	}
+
+	if strings.HasPrefix(v, "amq.") {
+		return fmt.Errorf("name prefix '%s' uses the reserved 'amq.' prefix", v)
+	}
+
+	o.NamePrefix = v
+	return nil
+}
+
+// applyAttrQuota sets the AttrQuota value.
+func (o *Options) applyAttrQuota(v QuotaOptions) error {
+	o.AttrQuota = v
+	return nil
+}
+
+// applyFaultInjection sets the FaultInjection value.
+func (o *Options) applyFaultInjection(v FaultOptions) error {
+	if err := v.validate(); err != nil {
+		return fmt.Errorf("invalid fault injection options: %s", err)
+	}
+
+	o.FaultInjection = v
+	return nil
+}
+
+// applyPeerIDGenerator sets the PeerIDGenerator value.
+func (o *Options) applyPeerIDGenerator(v func() ident.PeerID) error {
+	if v == nil {
+		panic("peer ID generator must not be nil")
+	}
+
+	o.PeerIDGenerator = v
+	return nil
+}
+
+// applyAttrFeed adds a namespace to the AttrFeed value.
+func (o *Options) applyAttrFeed(v string) error {
+	for _, ns := range o.AttrFeed {
+		if ns == v {
+			return nil
+		}
+	}
+
+	o.AttrFeed = append(o.AttrFeed, v)
+	return nil
+}
+
+// applyPayloadLogging sets the PayloadLogging value.
+func (o *Options) applyPayloadLogging(v PayloadLogOptions) error {
+	o.PayloadLogging = v
+	return nil
+}
+
+// applyPayloadRedactor sets the PayloadRedactor value.
+func (o *Options) applyPayloadRedactor(v func(interface{}) interface{}) error {
+	if v == nil {
+		panic("payload redactor must not be nil")
+	}
+
+	o.PayloadRedactor = v
+	return nil
+}
+
+// applySampling sets the sampling rate for the ns namespace.
+func (o *Options) applySampling(ns string, v float64) error {
+	if v < 0 || v > 1 {
+		return fmt.Errorf("sampling rate for '%s' namespace must be between 0 and 1", ns)
+	}
+
+	if o.Sampling == nil {
+		o.Sampling = map[string]float64{}
+	}
+
+	o.Sampling[ns] = v
+	return nil
+}
+
+// applySessionLimit sets the SessionLimit value.
+func (o *Options) applySessionLimit(v SessionLimitOptions) error {
+	if err := v.validate(); err != nil {
+		return fmt.Errorf("invalid session limit options: %s", err)
+	}
+
+	o.SessionLimit = v
+	return nil
+}
+
+// applyAtLeastOnce adds a namespace to the AtLeastOnce value.
+func (o *Options) applyAtLeastOnce(v string) error {
+	if o.isAtLeastOnce(v) {
+		return nil
+	}
+
+	q, ok := o.Queues[v]
+	if !ok {
+		q = o.DefaultQueue
+	}
+
+	if q.Transient {
+		return fmt.Errorf("'%s' namespace can not use at-least-once execution, as its queue is transient", v)
+	}
+
+	o.AtLeastOnce = append(o.AtLeastOnce, v)
+	return nil
+}
+
+// isAtLeastOnce returns true if the ns namespace is in the AtLeastOnce value.
+func (o *Options) isAtLeastOnce(ns string) bool {
+	for _, v := range o.AtLeastOnce {
+		if v == ns {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyDedupeStore sets the DedupeStore value.
+func (o *Options) applyDedupeStore(v dedupe.Store) error {
+	if v == nil {
+		panic("dedupe store must not be nil")
+	}
+
+	o.DedupeStore = v
+	return nil
+}
+
+// applyBufferPoolMaxSize sets the BufferPoolMaxSize value.
+func (o *Options) applyBufferPoolMaxSize(v uint) error {
+	o.BufferPoolMaxSize = v
+	return nil
+}
+
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9_\.\-:]+$`)
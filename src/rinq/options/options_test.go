@@ -8,6 +8,8 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rinq/rinq-go/src/rinq/clock"
+	"github.com/rinq/rinq-go/src/rinq/metrics"
 	"github.com/rinq/rinq-go/src/rinq/options"
 )
 
@@ -17,13 +19,468 @@ var _ = Describe("NewOptions", func() {
 
 		Expect(err).NotTo(HaveOccurred())
 		Expect(opts).To(Equal(options.Options{
-			DefaultTimeout: 5 * time.Second,
-			CommandWorkers: uint(runtime.GOMAXPROCS(0)),
-			SessionWorkers: uint(runtime.GOMAXPROCS(0)) * 10,
-			Logger:         &twelf.StandardLogger{},
-			PruneInterval:  3 * time.Minute,
-			Product:        "",
-			Tracer:         opentracing.NoopTracer{},
+			DefaultTimeout:         5 * time.Second,
+			CommandWorkers:         uint(runtime.GOMAXPROCS(0)),
+			SessionWorkers:         uint(runtime.GOMAXPROCS(0)) * 10,
+			Logger:                 &twelf.StandardLogger{},
+			PruneInterval:          3 * time.Minute,
+			CacheTTL:               0,
+			CacheSize:              0,
+			NotFoundTTL:            0,
+			ReplayWindow:           0,
+			Balancing:              options.BrokerBalancing,
+			StickySessions:         false,
+			Tenant:                 "",
+			NotifyBatch:            0,
+			Canonical:              false,
+			Prefetch:               nil,
+			Product:                "",
+			Tracer:                 opentracing.NoopTracer{},
+			Metrics:                metrics.Discard,
+			ListenerConcurrency:    0,
+			ListenerBuffer:         0,
+			ListenerOverflow:       options.BlockOverflow,
+			OrderedNotifications:   false,
+			Queues:                 nil,
+			HopMargin:              0,
+			Groups:                 nil,
+			Clock:                  clock.System,
+			DeadlineDiagnostics:    false,
+			SlowHandlerThreshold:   0,
+			HandlerTimeouts:        nil,
+			PauseWhenBlocked:       false,
+			AdaptiveCommandWorkers: 0,
+			Restartable:            false,
+			DefaultQueue:           options.ListenOptions{},
+			Streams:                nil,
+			NamePrefix:             "",
+			AttrQuota:              options.QuotaOptions{},
+			FaultInjection:         options.FaultOptions{},
+			PeerIDGenerator:        nil,
+			AttrFeed:               nil,
+			PayloadLogging:         options.PayloadLogOptions{},
+			PayloadRedactor:        nil,
+			Sampling:               nil,
+			SessionLimit:           options.SessionLimitOptions{},
+			AtLeastOnce:            nil,
+			DedupeStore:            nil,
+			BufferPoolMaxSize:      64 * 1024,
 		}))
 	})
 })
+
+var _ = Describe("Balancing", func() {
+	It("returns an error if the strategy is unknown", func() {
+		_, err := options.NewOptions(
+			options.Balancing(options.BalanceStrategy(-1)),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ListenerOverflow", func() {
+	It("returns an error if the policy is unknown", func() {
+		_, err := options.NewOptions(
+			options.ListenerOverflow(options.OverflowPolicy(-1)),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("HopMargin", func() {
+	It("returns an error if the margin is negative", func() {
+		_, err := options.NewOptions(
+			options.HopMargin(-time.Second),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("AdaptiveCommandWorkers", func() {
+	It("returns an error if the target is negative", func() {
+		_, err := options.NewOptions(
+			options.AdaptiveCommandWorkers(-time.Second),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Group", func() {
+	It("adds each group once", func() {
+		opts, err := options.NewOptions(
+			options.Group("a"),
+			options.Group("b"),
+			options.Group("a"),
+		)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Groups).To(Equal([]string{"a", "b"}))
+	})
+
+	It("returns an error if the group is empty", func() {
+		_, err := options.NewOptions(
+			options.Group(""),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error if the group contains a comma", func() {
+		_, err := options.NewOptions(
+			options.Group("a,b"),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Tenant", func() {
+	It("returns an error if the tenant contains invalid characters", func() {
+		_, err := options.NewOptions(
+			options.Tenant("foo/bar"),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Queue", func() {
+	It("replaces the options for the same namespace", func() {
+		opts, err := options.NewOptions(
+			options.Queue("ns1", options.ListenOptions{Lazy: true}),
+			options.Queue("ns2", options.ListenOptions{MaxLength: 10}),
+			options.Queue("ns1", options.ListenOptions{Quorum: true}),
+		)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Queues).To(Equal(map[string]options.ListenOptions{
+			"ns1": {Quorum: true},
+			"ns2": {MaxLength: 10},
+		}))
+	})
+
+	It("returns an error if a quorum queue is transient", func() {
+		_, err := options.NewOptions(
+			options.Queue("ns", options.ListenOptions{Quorum: true, Transient: true}),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error if a quorum queue is lazy", func() {
+		_, err := options.NewOptions(
+			options.Queue("ns", options.ListenOptions{Quorum: true, Lazy: true}),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error if a delivery limit is set on a classic queue", func() {
+		_, err := options.NewOptions(
+			options.Queue("ns", options.ListenOptions{DeliveryLimit: 3}),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error if the message TTL is negative", func() {
+		_, err := options.NewOptions(
+			options.Queue("ns", options.ListenOptions{MessageTTL: -time.Second}),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("panics if the namespace is invalid", func() {
+		Expect(func() {
+			options.Queue("", options.ListenOptions{})
+		}).To(Panic())
+	})
+})
+
+var _ = Describe("DefaultQueue", func() {
+	It("returns an error if the options are invalid", func() {
+		_, err := options.NewOptions(
+			options.DefaultQueue(options.ListenOptions{Quorum: true, Transient: true}),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Stream", func() {
+	It("replaces the options for the same namespace", func() {
+		opts, err := options.NewOptions(
+			options.Stream("ns1", options.StreamOptions{MaxAge: time.Hour}),
+			options.Stream("ns2", options.StreamOptions{MaxBytes: 1024}),
+			options.Stream("ns1", options.StreamOptions{MaxBytes: 2048}),
+		)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Streams).To(Equal(map[string]options.StreamOptions{
+			"ns1": {MaxBytes: 2048},
+			"ns2": {MaxBytes: 1024},
+		}))
+	})
+
+	It("returns an error if the max age is negative", func() {
+		_, err := options.NewOptions(
+			options.Stream("ns", options.StreamOptions{MaxAge: -time.Second}),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error if the max age is less than one second", func() {
+		_, err := options.NewOptions(
+			options.Stream("ns", options.StreamOptions{MaxAge: time.Millisecond}),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("panics if the namespace is invalid", func() {
+		Expect(func() {
+			options.Stream("", options.StreamOptions{})
+		}).To(Panic())
+	})
+})
+
+var _ = Describe("NamePrefix", func() {
+	It("returns an error if the prefix contains invalid characters", func() {
+		_, err := options.NewOptions(
+			options.NamePrefix("acme rinq"),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error if the prefix is reserved by the broker", func() {
+		_, err := options.NewOptions(
+			options.NamePrefix("amq.rinq."),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("FaultInjection", func() {
+	It("returns an error if the latency is negative", func() {
+		_, err := options.NewOptions(
+			options.FaultInjection(options.FaultOptions{Latency: -time.Second}),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error if a rate is greater than one", func() {
+		_, err := options.NewOptions(
+			options.FaultInjection(options.FaultOptions{DropRate: 1.5}),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error if a rate is negative", func() {
+		_, err := options.NewOptions(
+			options.FaultInjection(options.FaultOptions{ReorderRate: -0.1}),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("PeerIDGenerator", func() {
+	It("panics if the generator is nil", func() {
+		Expect(func() {
+			_, _ = options.NewOptions(options.PeerIDGenerator(nil))
+		}).Should(Panic())
+	})
+})
+
+var _ = Describe("AttrFeed", func() {
+	It("adds each namespace once", func() {
+		opts, err := options.NewOptions(
+			options.AttrFeed("ns1"),
+			options.AttrFeed("ns2"),
+			options.AttrFeed("ns1"),
+		)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.AttrFeed).To(Equal([]string{"ns1", "ns2"}))
+	})
+
+	It("panics if the namespace is invalid", func() {
+		Expect(func() {
+			options.AttrFeed("")
+		}).To(Panic())
+	})
+})
+
+var _ = Describe("PayloadRedactor", func() {
+	It("panics if the redactor is nil", func() {
+		Expect(func() {
+			_, _ = options.NewOptions(options.PayloadRedactor(nil))
+		}).Should(Panic())
+	})
+})
+
+var _ = Describe("Sampling", func() {
+	It("replaces the rate for the same namespace", func() {
+		opts, err := options.NewOptions(
+			options.Sampling("ns1", 0.1),
+			options.Sampling("ns2", 0.2),
+			options.Sampling("ns1", 0.3),
+		)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Sampling).To(Equal(map[string]float64{
+			"ns1": 0.3,
+			"ns2": 0.2,
+		}))
+	})
+
+	It("returns an error if the rate is out of range", func() {
+		_, err := options.NewOptions(options.Sampling("ns", 1.5))
+		Expect(err).To(HaveOccurred())
+
+		_, err = options.NewOptions(options.Sampling("ns", -0.1))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("panics if the namespace is invalid", func() {
+		Expect(func() {
+			options.Sampling("", 0.5)
+		}).To(Panic())
+	})
+})
+
+var _ = Describe("Prefetch", func() {
+	It("accumulates keys across multiple options", func() {
+		opts, err := options.NewOptions(
+			options.Prefetch("ns1", "a", "b"),
+			options.Prefetch("ns2", "c"),
+			options.Prefetch("ns1", "d"),
+		)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Prefetch).To(Equal(map[string][]string{
+			"ns1": {"a", "b", "d"},
+			"ns2": {"c"},
+		}))
+	})
+
+	It("panics if the namespace is invalid", func() {
+		Expect(func() {
+			options.Prefetch("")
+		}).To(Panic())
+	})
+})
+
+var _ = Describe("HandlerTimeout", func() {
+	It("replaces the timeout for the same namespace", func() {
+		opts, err := options.NewOptions(
+			options.HandlerTimeout("ns1", time.Second),
+			options.HandlerTimeout("ns2", 2*time.Second),
+			options.HandlerTimeout("ns1", 3*time.Second),
+		)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.HandlerTimeouts).To(Equal(map[string]time.Duration{
+			"ns1": 3 * time.Second,
+			"ns2": 2 * time.Second,
+		}))
+	})
+
+	It("returns an error if the timeout is not positive", func() {
+		_, err := options.NewOptions(
+			options.HandlerTimeout("ns", 0),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("panics if the namespace is invalid", func() {
+		Expect(func() {
+			options.HandlerTimeout("", time.Second)
+		}).To(Panic())
+	})
+})
+
+var _ = Describe("SessionLimit", func() {
+	It("returns an error if the rate is negative", func() {
+		_, err := options.NewOptions(
+			options.SessionLimit(options.SessionLimitOptions{Rate: -1}),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("AtLeastOnce", func() {
+	It("adds each namespace once", func() {
+		opts, err := options.NewOptions(
+			options.AtLeastOnce("ns1"),
+			options.AtLeastOnce("ns2"),
+			options.AtLeastOnce("ns1"),
+		)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.AtLeastOnce).To(Equal([]string{"ns1", "ns2"}))
+	})
+
+	It("returns an error if the namespace's queue is transient", func() {
+		_, err := options.NewOptions(
+			options.Queue("ns", options.ListenOptions{Transient: true}),
+			options.AtLeastOnce("ns"),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error if the namespace's queue is later made transient", func() {
+		_, err := options.NewOptions(
+			options.AtLeastOnce("ns"),
+			options.Queue("ns", options.ListenOptions{Transient: true}),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error if the default queue is made transient", func() {
+		_, err := options.NewOptions(
+			options.AtLeastOnce("ns"),
+			options.DefaultQueue(options.ListenOptions{Transient: true}),
+		)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("allows a transient default queue if the namespace has its own queue", func() {
+		_, err := options.NewOptions(
+			options.Queue("ns", options.ListenOptions{}),
+			options.AtLeastOnce("ns"),
+			options.DefaultQueue(options.ListenOptions{Transient: true}),
+		)
+
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("panics if the namespace is invalid", func() {
+		Expect(func() {
+			options.AtLeastOnce("")
+		}).To(Panic())
+	})
+})
+
+var _ = Describe("DedupeStore", func() {
+	It("panics if the store is nil", func() {
+		Expect(func() {
+			_, _ = options.NewOptions(options.DedupeStore(nil))
+		}).Should(Panic())
+	})
+})
@@ -0,0 +1,48 @@
+package options
+
+import "fmt"
+
+// OverflowPolicy is a policy that determines what happens to a notification
+// that arrives for a session whose notification buffer is full.
+type OverflowPolicy int
+
+const (
+	// BlockOverflow stops the peer from consuming further notifications until
+	// there is space in the session's buffer. Notifications accumulate at the
+	// broker, which eventually applies back-pressure to the publishers.
+	BlockOverflow OverflowPolicy = iota
+
+	// DropOldestOverflow discards the oldest notification in the session's
+	// buffer to make space for the new notification.
+	DropOldestOverflow
+
+	// DropNewestOverflow discards the new notification, leaving the session's
+	// buffer unchanged.
+	DropNewestOverflow
+)
+
+var overflowPolicyNames = map[OverflowPolicy]string{
+	BlockOverflow:      "block",
+	DropOldestOverflow: "drop-oldest",
+	DropNewestOverflow: "drop-newest",
+}
+
+// String returns the name of the policy.
+func (p OverflowPolicy) String() string {
+	if n, ok := overflowPolicyNames[p]; ok {
+		return n
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(p))
+}
+
+// parseOverflowPolicy returns the policy with the given name.
+func parseOverflowPolicy(n string) (OverflowPolicy, error) {
+	for p, name := range overflowPolicyNames {
+		if name == n {
+			return p, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown overflow policy: %s", n)
+}
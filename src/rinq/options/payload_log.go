@@ -0,0 +1,15 @@
+package options
+
+// PayloadLogOptions controls how payloads are rendered in debug log messages.
+//
+// The zero value renders each payload in full.
+type PayloadLogOptions struct {
+	// Omit replaces each payload with its size in bytes, so that debug log
+	// messages record the metadata of each call without its content.
+	Omit bool
+
+	// MaxLength is the maximum number of bytes of each payload's rendered
+	// representation to include in a log message. Longer payloads are
+	// truncated. Zero means unlimited.
+	MaxLength uint
+}
@@ -0,0 +1,61 @@
+package options
+
+import (
+	"errors"
+	"time"
+)
+
+// ListenOptions describes how the broker queue that distributes balanced
+// command requests for a namespace is declared.
+//
+// The zero value describes the default declaration, a durable queue that
+// supports request priorities and has no length or TTL limits.
+type ListenOptions struct {
+	// Transient, if true, declares a non-durable queue, which does not survive
+	// a broker restart.
+	Transient bool
+
+	// Lazy, if true, declares a lazy queue, which moves requests to disk as
+	// early as possible.
+	Lazy bool
+
+	// Quorum, if true, declares a replicated quorum queue. Quorum queues are
+	// always durable and do not support request priorities, so requests are
+	// handled in the order they are sent.
+	Quorum bool
+
+	// MaxLength is the maximum number of requests held in the queue. When the
+	// queue is full the oldest requests are discarded. Zero means unlimited.
+	MaxLength uint
+
+	// MessageTTL is the maximum time a request is held in the queue before it
+	// is discarded. Zero means requests are held until they are handled or
+	// their deadline passes.
+	MessageTTL time.Duration
+
+	// DeliveryLimit is the maximum number of times a request is delivered,
+	// including redeliveries after a handler is interrupted, before the broker
+	// discards it. It requires a quorum queue. Zero means unlimited.
+	DeliveryLimit uint
+}
+
+// validate returns an error if o describes a queue that can not be declared.
+func (o ListenOptions) validate() error {
+	if o.Quorum && o.Transient {
+		return errors.New("quorum queues can not be transient")
+	}
+
+	if o.Quorum && o.Lazy {
+		return errors.New("quorum queues can not be lazy")
+	}
+
+	if o.DeliveryLimit != 0 && !o.Quorum {
+		return errors.New("delivery limits require a quorum queue")
+	}
+
+	if o.MessageTTL < 0 {
+		return errors.New("message TTL must not be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package options
+
+// QuotaOptions limits the attributes that a single session may hold.
+//
+// An attribute counts towards the quota while it has a non-empty value. Its
+// size is the combined length of its key and value, in bytes. The zero value
+// imposes no limits.
+type QuotaOptions struct {
+	// MaxCount is the maximum number of attributes in a session, across all
+	// namespaces. Zero means unlimited.
+	MaxCount uint
+
+	// MaxBytes is the maximum combined size of the attributes in a session,
+	// across all namespaces. Zero means unlimited.
+	MaxBytes uint
+
+	// MaxNamespaceCount is the maximum number of attributes in each namespace
+	// of a session. Zero means unlimited.
+	MaxNamespaceCount uint
+
+	// MaxNamespaceBytes is the maximum combined size of the attributes in
+	// each namespace of a session. Zero means unlimited.
+	MaxNamespaceBytes uint
+}
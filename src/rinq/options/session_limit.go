@@ -0,0 +1,33 @@
+package options
+
+import "errors"
+
+// SessionLimitOptions limits the sessions that a peer may create, so that a
+// surge of clients can not exhaust the peer's resources.
+//
+// Creation is rate-limited using a token bucket that holds up to Burst
+// tokens and is refilled at Rate tokens per second. Each session consumes a
+// single token. The zero value imposes no limits.
+type SessionLimitOptions struct {
+	// MaxSessions is the maximum number of sessions that the peer may own at
+	// any one time. Zero means unlimited.
+	MaxSessions uint
+
+	// Rate is the sustained number of sessions that may be created per
+	// second. Zero means unlimited.
+	Rate float64
+
+	// Burst is the number of sessions that may be created at once before the
+	// rate limit applies. If it is zero, a burst of one session is allowed.
+	// It has no effect if Rate is zero.
+	Burst uint
+}
+
+// validate returns an error if o describes limits that can not be enforced.
+func (o SessionLimitOptions) validate() error {
+	if o.Rate < 0 {
+		return errors.New("rate must not be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package options
+
+import (
+	"errors"
+	"time"
+)
+
+// StreamOptions describes how the stream that retains the multicast
+// notifications for a namespace is declared.
+//
+// The zero value describes a stream that retains notifications until the
+// broker's default limits are reached.
+type StreamOptions struct {
+	// MaxAge is the maximum time a notification is retained in the stream.
+	// It is rounded down to a whole number of seconds. Zero means unlimited.
+	MaxAge time.Duration
+
+	// MaxBytes is the maximum size of the stream, in bytes. When the stream
+	// is full the oldest notifications are discarded. Zero means unlimited.
+	MaxBytes uint64
+}
+
+// validate returns an error if o describes a stream that can not be declared.
+func (o StreamOptions) validate() error {
+	if o.MaxAge < 0 {
+		return errors.New("max age must not be negative")
+	}
+
+	if o.MaxAge != 0 && o.MaxAge < time.Second {
+		return errors.New("max age must be at least one second")
+	}
+
+	return nil
+}
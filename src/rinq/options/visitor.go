@@ -6,6 +6,10 @@ import (
 
 	"github.com/jmalloc/twelf/src/twelf"
 	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rinq/rinq-go/src/rinq/clock"
+	"github.com/rinq/rinq-go/src/rinq/dedupe"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/metrics"
 )
 
 // visitor handles the application of options.
@@ -15,8 +19,47 @@ type visitor interface {
 	applyCommandWorkers(uint) error
 	applySessionWorkers(uint) error
 	applyPruneInterval(time.Duration) error
+	applyCacheTTL(time.Duration) error
+	applyCacheSize(uint) error
+	applyNotFoundTTL(time.Duration) error
+	applyReplayWindow(time.Duration) error
+	applyBalancing(BalanceStrategy) error
+	applyStickySessions(bool) error
+	applyTenant(string) error
+	applyNotifyBatch(time.Duration) error
+	applyCanonical(bool) error
+	applyPrefetch(string, []string) error
 	applyProduct(string) error
 	applyTracer(opentracing.Tracer) error
+	applyMetrics(metrics.Recorder) error
+	applyListenerConcurrency(uint) error
+	applyListenerBuffer(uint) error
+	applyListenerOverflow(OverflowPolicy) error
+	applyOrderedNotifications(bool) error
+	applyQueue(string, ListenOptions) error
+	applyHopMargin(time.Duration) error
+	applyGroup(string) error
+	applyClock(clock.Clock) error
+	applyDeadlineDiagnostics(bool) error
+	applySlowHandlerThreshold(time.Duration) error
+	applyHandlerTimeout(string, time.Duration) error
+	applyPauseWhenBlocked(bool) error
+	applyAdaptiveCommandWorkers(time.Duration) error
+	applyRestartable(bool) error
+	applyDefaultQueue(ListenOptions) error
+	applyStream(string, StreamOptions) error
+	applyNamePrefix(string) error
+	applyAttrQuota(QuotaOptions) error
+	applyFaultInjection(FaultOptions) error
+	applyPeerIDGenerator(func() ident.PeerID) error
+	applyAttrFeed(string) error
+	applyPayloadLogging(PayloadLogOptions) error
+	applyPayloadRedactor(func(interface{}) interface{}) error
+	applySampling(string, float64) error
+	applySessionLimit(SessionLimitOptions) error
+	applyAtLeastOnce(string) error
+	applyDedupeStore(dedupe.Store) error
+	applyBufferPoolMaxSize(uint) error
 }
 
 // Apply applies the default options, then a sequence of additional options to v.
@@ -46,6 +89,18 @@ func Apply(v visitor, opts ...Option) error {
 		return err
 	}
 
+	if err := v.applyMetrics(metrics.Discard); err != nil {
+		return err
+	}
+
+	if err := v.applyClock(clock.System); err != nil {
+		return err
+	}
+
+	if err := v.applyBufferPoolMaxSize(64 * 1024); err != nil {
+		return err
+	}
+
 	for _, o := range opts {
 		if err := o(v); err != nil {
 			return err
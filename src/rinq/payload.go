@@ -63,7 +63,35 @@ func NewPayloadFromBytes(buf []byte) *Payload {
 
 	return &Payload{
 		&payloadData{
-			buffer:   bytes.NewBuffer(buf),
+			buffer:   wrapBuffer(buf),
+			refCount: 1,
+		},
+	}
+}
+
+// BorrowPayload creates a new payload from a binary representation without
+// taking ownership of the byte-slice. An empty byte-slice is equivalent to the
+// nil value.
+//
+// Unlike NewPayloadFromBytes(), buf is never recycled for use by other
+// payloads, so it remains valid after the payload is closed. release, if
+// non-nil, is called once the payload and all of its clones have been closed.
+// It is intended for payloads that reference buffers owned by a transport, such
+// as the body of a message that has not yet been acknowledged.
+func BorrowPayload(buf []byte, release func()) *Payload {
+	if len(buf) == 0 {
+		if release != nil {
+			release()
+		}
+
+		return nil
+	}
+
+	return &Payload{
+		&payloadData{
+			buffer:   wrapBuffer(buf),
+			borrowed: true,
+			release:  release,
 			refCount: 1,
 		},
 	}
@@ -168,9 +196,22 @@ func (p *Payload) Close() {
 
 	data.refCount--
 
-	if data.refCount == 0 && data.buffer != nil {
+	if data.refCount != 0 {
+		return
+	}
+
+	if data.buffer != nil {
+		if data.borrowed {
+			// detach the borrowed byte-slice so it is not reused by the pool
+			*data.buffer = bytes.Buffer{}
+		}
+
 		bufferpool.Put(data.buffer)
 	}
+
+	if data.release != nil {
+		data.release()
+	}
 }
 
 // String returns a human-readable representation of the payload.
@@ -201,11 +242,26 @@ type payloadData struct {
 	// Indicates whether the value has been populated.
 	hasValue bool
 
+	// Indicates whether buffer references a byte-slice that is not owned by
+	// the payload.
+	borrowed bool
+
+	// release is called when the last reference to a borrowed payload is
+	// closed. It may be nil.
+	release func()
+
 	// refCount is the number of payload structures that are pointing to this
 	// element.
 	refCount uint
 }
 
+// wrapBuffer returns a pooled buffer that contains buf.
+func wrapBuffer(buf []byte) *bytes.Buffer {
+	buffer := bufferpool.Get()
+	*buffer = *bytes.NewBuffer(buf)
+	return buffer
+}
+
 var jsonHandle codec.JsonHandle
 var jsonEncoders = sync.Pool{
 	New: func() interface{} {
@@ -0,0 +1,126 @@
+package rinq
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/rinq/rinq-go/src/internal/x/cbor"
+)
+
+// PayloadEqual returns true if a and b represent the same value.
+//
+// Payloads are compared by their decoded values, rather than their binary
+// representations, so payloads that encode maps with keys in a different order
+// are considered equal. A nil payload is equal to a payload with a nil value.
+func PayloadEqual(a, b *Payload) bool {
+	return reflect.DeepEqual(normalizePayload(a), normalizePayload(b))
+}
+
+// PayloadDiff returns a human-readable description of each difference between
+// the values represented by a and b. It returns an empty slice if the payloads
+// are equal, as per PayloadEqual().
+//
+// Each difference is described in the form "<path>: <a> != <b>", where path
+// identifies the location of the difference within the value, such as
+// ".users[2].name".
+func PayloadDiff(a, b *Payload) []string {
+	return diffValues(nil, "", normalizePayload(a), normalizePayload(b))
+}
+
+// normalizePayload returns the value of p as decoded from its binary
+// representation, such that equal values have the same Go types.
+func normalizePayload(p *Payload) interface{} {
+	buf := p.Bytes()
+	if buf == nil {
+		return nil
+	}
+
+	var v interface{}
+	cbor.MustDecodeBytes(buf, &v)
+
+	return v
+}
+
+func diffValues(diff []string, path string, a, b interface{}) []string {
+	switch av := a.(type) {
+	case map[interface{}]interface{}:
+		if bv, ok := b.(map[interface{}]interface{}); ok {
+			return diffMaps(diff, path, av, bv)
+		}
+
+	case []interface{}:
+		if bv, ok := b.([]interface{}); ok {
+			return diffSlices(diff, path, av, bv)
+		}
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return diff
+	}
+
+	return append(diff, describeDiff(path, a, b))
+}
+
+func diffMaps(diff []string, path string, a, b map[interface{}]interface{}) []string {
+	keys := make([]interface{}, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+
+	for _, k := range keys {
+		p := fmt.Sprintf("%s.%v", path, k)
+		av, aok := a[k]
+		bv, bok := b[k]
+
+		switch {
+		case !aok:
+			diff = append(diff, fmt.Sprintf("%s: <missing> != %v", p, bv))
+		case !bok:
+			diff = append(diff, fmt.Sprintf("%s: %v != <missing>", p, av))
+		default:
+			diff = diffValues(diff, p, av, bv)
+		}
+	}
+
+	return diff
+}
+
+func diffSlices(diff []string, path string, a, b []interface{}) []string {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+
+	for i := 0; i < n; i++ {
+		p := fmt.Sprintf("%s[%d]", path, i)
+
+		switch {
+		case i >= len(a):
+			diff = append(diff, fmt.Sprintf("%s: <missing> != %v", p, b[i]))
+		case i >= len(b):
+			diff = append(diff, fmt.Sprintf("%s: %v != <missing>", p, a[i]))
+		default:
+			diff = diffValues(diff, p, a[i], b[i])
+		}
+	}
+
+	return diff
+}
+
+func describeDiff(path string, a, b interface{}) string {
+	if path == "" {
+		path = "."
+	}
+
+	return fmt.Sprintf("%s: %v != %v", path, a, b)
+}
@@ -0,0 +1,90 @@
+package rinq_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+var _ = Describe("PayloadEqual", func() {
+	DescribeTable(
+		"returns true when the values are equal",
+		func(a, b *rinq.Payload) {
+			defer a.Close()
+			defer b.Close()
+
+			Expect(rinq.PayloadEqual(a, b)).To(BeTrue())
+		},
+		Entry("nil payloads", nil, nil),
+		Entry("nil payload and nil value", nil, rinq.NewPayloadFromBytes(nil)),
+		Entry("scalars of different types", rinq.NewPayload(123), rinq.NewPayload(uint8(123))),
+		Entry("value and bytes", rinq.NewPayload(123), rinq.NewPayloadFromBytes([]byte{24, 123})),
+		Entry(
+			"maps",
+			rinq.NewPayload(map[string]int{"a": 1, "b": 2}),
+			rinq.NewPayload(map[string]int{"b": 2, "a": 1}),
+		),
+	)
+
+	DescribeTable(
+		"returns false when the values are not equal",
+		func(a, b *rinq.Payload) {
+			defer a.Close()
+			defer b.Close()
+
+			Expect(rinq.PayloadEqual(a, b)).To(BeFalse())
+		},
+		Entry("nil and non-nil", nil, rinq.NewPayload(123)),
+		Entry("different scalars", rinq.NewPayload(123), rinq.NewPayload(456)),
+		Entry(
+			"different maps",
+			rinq.NewPayload(map[string]int{"a": 1}),
+			rinq.NewPayload(map[string]int{"a": 2}),
+		),
+	)
+})
+
+var _ = Describe("PayloadDiff", func() {
+	It("returns an empty slice when the values are equal", func() {
+		a := rinq.NewPayload(map[string]int{"a": 1})
+		defer a.Close()
+		b := rinq.NewPayload(map[string]int{"a": 1})
+		defer b.Close()
+
+		Expect(rinq.PayloadDiff(a, b)).To(BeEmpty())
+	})
+
+	It("describes scalar differences", func() {
+		a := rinq.NewPayload(123)
+		defer a.Close()
+		b := rinq.NewPayload("foo")
+		defer b.Close()
+
+		Expect(rinq.PayloadDiff(a, b)).To(Equal([]string{
+			".: 123 != foo",
+		}))
+	})
+
+	It("describes nested differences by path", func() {
+		a := rinq.NewPayload(map[string]interface{}{
+			"name":  "alice",
+			"roles": []string{"admin", "user"},
+			"old":   true,
+		})
+		defer a.Close()
+		b := rinq.NewPayload(map[string]interface{}{
+			"name":  "bob",
+			"roles": []string{"admin"},
+			"new":   true,
+		})
+		defer b.Close()
+
+		Expect(rinq.PayloadDiff(a, b)).To(Equal([]string{
+			".name: alice != bob",
+			".new: <missing> != true",
+			".old: true != <missing>",
+			".roles[1]: user != <missing>",
+		}))
+	})
+})
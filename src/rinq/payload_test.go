@@ -182,3 +182,42 @@ var _ = Describe("NewPayload", func() {
 		Entry("nil slice", ([]int)(nil)),
 	)
 })
+
+var _ = Describe("BorrowPayload", func() {
+	It("returns nil when the byte-slice is empty", func() {
+		p := rinq.BorrowPayload(nil, nil)
+
+		Expect(p).To(BeNil())
+	})
+
+	It("does not modify the byte-slice after the payload is closed", func() {
+		buf := []byte{24, 123}
+		p := rinq.BorrowPayload(buf, nil)
+		p.Close()
+
+		q := rinq.NewPayload(456)
+		defer q.Close()
+		q.Bytes()
+
+		Expect(buf).To(Equal([]byte{24, 123}))
+	})
+
+	It("calls the release function when the last clone is closed", func() {
+		released := false
+		p := rinq.BorrowPayload([]byte{24, 123}, func() { released = true })
+		c := p.Clone()
+
+		p.Close()
+		Expect(released).To(BeFalse())
+
+		c.Close()
+		Expect(released).To(BeTrue())
+	})
+
+	It("calls the release function immediately when the byte-slice is empty", func() {
+		released := false
+		rinq.BorrowPayload(nil, func() { released = true })
+
+		Expect(released).To(BeTrue())
+	})
+})
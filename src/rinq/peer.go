@@ -1,6 +1,10 @@
 package rinq
 
-import "github.com/rinq/rinq-go/src/rinq/ident"
+import (
+	"context"
+
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
 
 // Peer represents a connection to a Rinq network.
 //
@@ -20,12 +24,15 @@ type Peer interface {
 
 	// Session returns a new session owned by this peer.
 	//
-	// Creating a session does not perform any network IO. The only limit to the
-	// number of sessions is the memory required to store them.
+	// Creating a session does not perform any network IO. If the peer was
+	// created with options.SessionLimit(), it returns a SessionLimitError when
+	// the peer owns the maximum number of sessions, or sessions are being
+	// created too quickly. Otherwise, the only limit to the number of sessions
+	// is the memory required to store them.
 	//
 	// Sessions created after the peer has been stopped are unusable. Any
 	// operation will fail immediately.
-	Session() Session
+	Session() (Session, error)
 
 	// Listen starts listening for command requests in the given namespace.
 	//
@@ -43,6 +50,143 @@ type Peer interface {
 	// If the peer is not currently listening to ns, nil is returned immediately.
 	Unlisten(ns string) error
 
+	// ListenVersion starts listening for command requests in the given
+	// namespace at a specific API version.
+	//
+	// It behaves as per Listen(), except that h only receives requests sent
+	// with a context created by WithAPIVersion() using the same version. A peer
+	// may listen to several versions of the same namespace concurrently,
+	// allowing incompatible changes to be rolled out gradually.
+	//
+	// Listening at version zero is equivalent to calling Listen().
+	ListenVersion(ns string, version uint, h CommandHandler) error
+
+	// UnlistenVersion stops listening for command requests in the given
+	// namespace at a specific API version.
+	//
+	// If the peer is not currently listening to ns at that version, nil is
+	// returned immediately.
+	UnlistenVersion(ns string, version uint) error
+
+	// ListenAll starts listening for command requests in each of the
+	// namespaces in handlers, which maps each namespace to its handler.
+	//
+	// It behaves as per calling Listen() for each namespace, except that the
+	// handlers are added in a single operation. Either all of the handlers are
+	// added or, if an error occurs, none of them are.
+	ListenAll(handlers map[string]CommandHandler) error
+
+	// ListenMux starts listening for command requests in the given namespace,
+	// dispatching them to the handlers registered with m.
+	//
+	// It behaves as per Listen(ns, m.Serve), except that the commands in m are
+	// included in the catalog returned by Commands().
+	ListenMux(ns string, m *CommandMux) error
+
+	// UnlistenAll stops listening for command requests in all namespaces, at
+	// all API versions.
+	//
+	// If the peer is not currently listening to any namespaces, nil is
+	// returned immediately.
+	UnlistenAll() error
+
+	// Broadcast sends a command request to every peer that is listening to the
+	// ns namespace, including this peer, and returns immediately.
+	//
+	// Unlike requests sent by sessions, which are handled by a single peer,
+	// broadcasts are intended for cluster-wide control messages, such as
+	// cache invalidation. No response is sent, and a peer that is not
+	// connected when the request is sent does not receive it.
+	//
+	// cmd and out are an application-defined command name and request payload,
+	// respectively. Both are passed to the command handler on each peer. The
+	// source of the request, as seen by the handler, is not a session that can
+	// be queried or modified.
+	Broadcast(ctx context.Context, ns, cmd string, out *Payload) error
+
+	// ListenStream starts consuming the notification stream for the ns
+	// namespace, beginning at offset.
+	//
+	// A stream retains a copy of each multicast notification sent in its
+	// namespace, such as those sent with Session.NotifyMany(), so that they
+	// can be replayed. Streams are declared by the peers that are configured
+	// with options.Stream(). Notifications sent before a stream is first
+	// declared are not retained.
+	//
+	// h is invoked for each notification, regardless of the constraint that it
+	// was sent with. Repeated calls to ListenStream() with the same namespace
+	// stop the existing consumer and start a new one at offset.
+	//
+	// If the peer is restarted, consumption resumes from the notification
+	// after the last one passed to h.
+	ListenStream(ns string, offset StreamOffset, h StreamHandler) error
+
+	// UnlistenStream stops consuming the notification stream for the ns
+	// namespace.
+	//
+	// If the peer is not currently consuming the stream, nil is returned
+	// immediately.
+	UnlistenStream(ns string) error
+
+	// Commands returns a catalog of the commands that the peer is listening
+	// for, ordered by namespace, version and command name.
+	//
+	// Each namespace listened to with ListenMux() contributes one entry per
+	// command registered with the mux. Every other namespace contributes a
+	// single entry with an empty command name.
+	//
+	// It is intended to power service catalogs and documentation tools.
+	Commands() []CommandInfo
+
+	// Capabilities returns the wire protocol features supported by the peer
+	// identified by id.
+	//
+	// Peers advertise their capabilities in the presence announcements that
+	// they publish while listening for command requests. ok is false if no
+	// current announcement has been received from the peer, such as when it is
+	// not listening to any namespaces. The capabilities of this peer are
+	// always known.
+	//
+	// Applications can use the capabilities to avoid enabling a feature until
+	// every peer that would receive it supports it.
+	Capabilities(id ident.PeerID) (caps PeerCapabilities, ok bool)
+
+	// Stats returns a snapshot of the peer's current workload.
+	//
+	// It is intended to help operators observe backpressure, such as calls
+	// that are not being answered promptly or handlers that are slow to
+	// complete.
+	Stats() PeerStats
+
+	// Events returns a channel on which events describing changes to the
+	// peer's state are delivered, such as sessions being created or destroyed.
+	//
+	// Every call returns the same channel. It is buffered, events that occur
+	// while the buffer is full are discarded so that the peer is never blocked
+	// by a slow reader. The channel is closed when the peer stops.
+	Events() <-chan PeerEvent
+
+	// ObserveSessions registers o to be notified when sessions owned by this
+	// peer are created or destroyed.
+	//
+	// Only sessions created after o is registered are reported to o.
+	ObserveSessions(o SessionObserver)
+
+	// Restart closes the peer's connection to the broker and establishes a new
+	// one, retaining the peer's ID.
+	//
+	// Sessions owned by the peer, including their attributes and notification
+	// listeners, survive the restart, as do the command handlers registered
+	// with Listen() and its variants. Calls, notifications and command
+	// requests that are in progress when the peer is restarted may fail.
+	//
+	// ctx bounds the time spent establishing the new connection. If the
+	// restart fails, the peer remains disconnected until Restart() is called
+	// again or the peer is stopped. Restart can not be used once the peer has
+	// stopped, see options.Restartable() to keep a peer running after its
+	// connection is lost.
+	Restart(ctx context.Context) error
+
 	// Done returns a channel that is closed when the peer is stopped.
 	//
 	// Err() may be called to obtain the error that caused the peer to stop, if
@@ -52,7 +196,9 @@ type Peer interface {
 	// Err returns the error that caused the Done() channel to close.
 	//
 	// A nil return value indicates that the peer was stopped because Stop() or
-	// GracefulStop() has been called.
+	// GracefulStop() has been called. Otherwise, the error is a
+	// PeerStoppedError describing the reason that the peer stopped. Use
+	// ShouldRedial() to decide whether to replace the peer.
 	Err() error
 
 	// Stop instructs the peer to disconnect from the network immediately.
@@ -71,3 +217,51 @@ type Peer interface {
 	// Done() channel to wait for the peer to disconnect.
 	GracefulStop()
 }
+
+// PeerStats contains statistics about the workload of a peer at a single point
+// in time, as returned by Peer.Stats().
+type PeerStats struct {
+	// PendingCalls is the number of command calls made by sessions owned by the
+	// peer that are awaiting a response, including asynchronous calls.
+	PendingCalls int
+
+	// InFlightCommands is the number of command handlers that are currently
+	// executing.
+	InFlightCommands int
+
+	// QueuedNotifications is the number of notifications that have been sent
+	// by sessions owned by the peer but not yet published to the network, such
+	// as when notifications are batched.
+	QueuedNotifications int
+
+	// LocalSessions is the number of sessions owned by the peer.
+	LocalSessions int
+
+	// RemoteSessions is the number of sessions owned by other peers that are
+	// in the peer's cache.
+	RemoteSessions int
+
+	// ConnectionBlocked is true if the broker has blocked the peer's
+	// connection, in which case no messages can be published.
+	ConnectionBlocked bool
+
+	// BufferPool contains statistics about the use of the pool of buffers
+	// used to encode payloads and messages. The pool is shared by every peer
+	// in the process, so the statistics are not specific to this peer.
+	BufferPool BufferPoolStats
+}
+
+// BufferPoolStats contains statistics about the use of the buffer pool since
+// the process started, as returned by Peer.Stats().
+type BufferPoolStats struct {
+	// Gets is the number of buffers fetched from the pool.
+	Gets uint64
+
+	// Puts is the number of buffers returned to the pool for reuse.
+	Puts uint64
+
+	// Discards is the number of buffers that were not returned to the pool
+	// because their capacity exceeded the maximum size, see
+	// options.BufferPoolMaxSize().
+	Discards uint64
+}
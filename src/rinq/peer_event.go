@@ -0,0 +1,107 @@
+package rinq
+
+import (
+	"fmt"
+
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// PeerEventType is an enumeration of the kinds of events that are emitted by
+// Peer.Events().
+type PeerEventType int
+
+const (
+	// ListenEvent indicates that the peer started listening for command
+	// requests in PeerEvent.Namespace, at PeerEvent.Version.
+	ListenEvent PeerEventType = iota
+
+	// UnlistenEvent indicates that the peer stopped listening for command
+	// requests in PeerEvent.Namespace, at PeerEvent.Version.
+	UnlistenEvent
+
+	// SessionCreatedEvent indicates that the session identified by
+	// PeerEvent.Session was created.
+	SessionCreatedEvent
+
+	// SessionDestroyedEvent indicates that the session identified by
+	// PeerEvent.Session was destroyed, either locally or by another peer.
+	SessionDestroyedEvent
+
+	// ConnectionLostEvent indicates that the connection to the network was
+	// lost. PeerEvent.Err is the reason given by the broker, if any. The peer
+	// stops after this event is emitted.
+	ConnectionLostEvent
+
+	// ConsumerErrorEvent indicates that one of the peer's internal message
+	// consumers stopped unexpectedly. PeerEvent.Err is the cause. The peer
+	// stops after this event is emitted.
+	ConsumerErrorEvent
+
+	// ConnectionBlockedEvent indicates that the broker has blocked the
+	// connection, typically because it has raised a memory or disk alarm.
+	// PeerEvent.Reason is the reason given by the broker. Messages can not be
+	// published until a ConnectionUnblockedEvent is emitted.
+	ConnectionBlockedEvent
+
+	// ConnectionUnblockedEvent indicates that the broker has unblocked a
+	// connection that was previously blocked.
+	ConnectionUnblockedEvent
+
+	// RestartEvent indicates that the peer has been restarted successfully by
+	// a call to Peer.Restart().
+	RestartEvent
+
+	// PeerLostEvent indicates that the remote peer identified by
+	// PeerEvent.Peer is presumed to have stopped, because it is no longer
+	// announcing its presence. Any cached sessions owned by that peer have
+	// been closed.
+	PeerLostEvent
+)
+
+var peerEventTypeNames = map[PeerEventType]string{
+	ListenEvent:           "listen",
+	UnlistenEvent:         "unlisten",
+	SessionCreatedEvent:   "session-created",
+	SessionDestroyedEvent: "session-destroyed",
+	ConnectionLostEvent:   "connection-lost",
+	ConsumerErrorEvent:    "consumer-error",
+
+	ConnectionBlockedEvent:   "connection-blocked",
+	ConnectionUnblockedEvent: "connection-unblocked",
+	RestartEvent:             "restart",
+	PeerLostEvent:            "peer-lost",
+}
+
+// String returns the name of the event type.
+func (t PeerEventType) String() string {
+	if n, ok := peerEventTypeNames[t]; ok {
+		return n
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(t))
+}
+
+// PeerEvent describes a change to the state of a peer, as emitted by
+// Peer.Events().
+type PeerEvent struct {
+	// Type is the kind of event.
+	Type PeerEventType
+
+	// Namespace and Version are the command namespace and API version for
+	// ListenEvent and UnlistenEvent.
+	Namespace string
+	Version   uint
+
+	// Session is the ID of the session for SessionCreatedEvent and
+	// SessionDestroyedEvent.
+	Session ident.SessionID
+
+	// Err is the cause of a ConnectionLostEvent or ConsumerErrorEvent.
+	Err error
+
+	// Reason is the reason given by the broker for a ConnectionBlockedEvent.
+	Reason string
+
+	// Peer is the ID of the remote peer for PeerLostEvent.
+	Peer ident.PeerID
+}
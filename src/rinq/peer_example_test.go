@@ -18,7 +18,10 @@ func ExamplePeer_session() {
 	}
 	defer peer.Stop()
 
-	sess := peer.Session()
+	sess, err := peer.Session()
+	if err != nil {
+		panic(err)
+	}
 	defer sess.Destroy()
 
 	fmt.Printf("created session #%d\n", sess.ID().Seq)
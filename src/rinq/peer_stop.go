@@ -0,0 +1,86 @@
+package rinq
+
+// StopReason describes why a peer stopped.
+type StopReason int
+
+const (
+	// StopRequested indicates that the peer stopped because Peer.Stop() or
+	// Peer.GracefulStop() was called.
+	StopRequested StopReason = iota
+
+	// StopConnectionLost indicates that the peer's connection to the broker
+	// was closed by the broker or the network.
+	StopConnectionLost
+
+	// StopConsumerFailed indicates that one of the peer's internal consumers
+	// stopped unexpectedly, such as when the broker closes a channel due to a
+	// channel-level error.
+	StopConsumerFailed
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopConnectionLost:
+		return "connection lost"
+	case StopConsumerFailed:
+		return "consumer failed"
+	default:
+		return "stop requested"
+	}
+}
+
+// PeerStoppedError is the error returned by Peer.Err() when a peer stops for
+// any reason other than a successful call to Peer.Stop() or
+// Peer.GracefulStop().
+type PeerStoppedError struct {
+	// Reason is the reason that the peer stopped.
+	Reason StopReason
+
+	// Cause is the underlying error, if any. It is nil if the broker closed
+	// the connection without giving a reason.
+	Cause error
+}
+
+func (err PeerStoppedError) Error() string {
+	if err.Cause == nil {
+		return "peer stopped: " + err.Reason.String()
+	}
+
+	return "peer stopped: " + err.Reason.String() + ": " + err.Cause.Error()
+}
+
+// Is returns true if target is ErrPeerStopped.
+func (err PeerStoppedError) Is(target error) bool {
+	return target == ErrPeerStopped
+}
+
+// Unwrap returns the underlying error, if any.
+func (err PeerStoppedError) Unwrap() error {
+	return err.Cause
+}
+
+// StopReasonOf returns the reason that a peer stopped, where err is the error
+// returned by Peer.Err(). It returns StopRequested if err is nil.
+//
+// ok is false if err is neither nil nor a PeerStoppedError.
+func StopReasonOf(err error) (r StopReason, ok bool) {
+	if err == nil {
+		return StopRequested, true
+	}
+
+	if e, ok := err.(PeerStoppedError); ok {
+		return e.Reason, true
+	}
+
+	return StopRequested, false
+}
+
+// ShouldRedial returns true if err, as returned by Peer.Err(), indicates that
+// the peer stopped because of a failure of its connection to the broker, such
+// that a supervisor may reasonably attempt to dial a new peer.
+//
+// It returns false if the peer was stopped deliberately.
+func ShouldRedial(err error) bool {
+	r, ok := StopReasonOf(err)
+	return ok && r != StopRequested
+}
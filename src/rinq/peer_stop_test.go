@@ -0,0 +1,69 @@
+package rinq_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+var _ = Describe("PeerStoppedError", func() {
+	Describe("Error", func() {
+		It("describes the reason and cause", func() {
+			err := rinq.PeerStoppedError{
+				Reason: rinq.StopConnectionLost,
+				Cause:  errors.New("<cause>"),
+			}
+
+			Expect(err.Error()).To(Equal("peer stopped: connection lost: <cause>"))
+		})
+
+		It("omits the cause if it is nil", func() {
+			err := rinq.PeerStoppedError{Reason: rinq.StopConsumerFailed}
+
+			Expect(err.Error()).To(Equal("peer stopped: consumer failed"))
+		})
+	})
+
+	Describe("StopReasonOf", func() {
+		It("returns StopRequested for a nil error", func() {
+			r, ok := rinq.StopReasonOf(nil)
+
+			Expect(r).To(Equal(rinq.StopRequested))
+			Expect(ok).To(BeTrue())
+		})
+
+		It("returns the reason from a PeerStoppedError", func() {
+			r, ok := rinq.StopReasonOf(rinq.PeerStoppedError{Reason: rinq.StopConnectionLost})
+
+			Expect(r).To(Equal(rinq.StopConnectionLost))
+			Expect(ok).To(BeTrue())
+		})
+
+		It("returns false for other error types", func() {
+			_, ok := rinq.StopReasonOf(errors.New("<error>"))
+
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("ShouldRedial", func() {
+		It("returns true if the connection was lost or a consumer failed", func() {
+			Expect(rinq.ShouldRedial(rinq.PeerStoppedError{Reason: rinq.StopConnectionLost})).To(BeTrue())
+			Expect(rinq.ShouldRedial(rinq.PeerStoppedError{Reason: rinq.StopConsumerFailed})).To(BeTrue())
+		})
+
+		It("returns false if the peer was stopped deliberately", func() {
+			Expect(rinq.ShouldRedial(nil)).To(BeFalse())
+			Expect(rinq.ShouldRedial(rinq.PeerStoppedError{
+				Reason: rinq.StopRequested,
+				Cause:  errors.New("<close error>"),
+			})).To(BeFalse())
+		})
+
+		It("returns false for other error types", func() {
+			Expect(rinq.ShouldRedial(errors.New("<error>"))).To(BeFalse())
+		})
+	})
+})
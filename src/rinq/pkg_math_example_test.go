@@ -75,7 +75,10 @@ func Example_mathService() {
 	}
 	defer clientPeer.Stop()
 
-	sess := clientPeer.Session()
+	sess, err := clientPeer.Session()
+	if err != nil {
+		panic(err)
+	}
 	defer sess.Destroy()
 
 	// call the "math::add" command
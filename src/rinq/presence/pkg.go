@@ -0,0 +1,7 @@
+// Package presence tracks which sessions currently match an attribute
+// constraint, such as the users that are online in a chat room, or the workers
+// that are available for a queue.
+//
+// Sessions are removed from a tracker as soon as they are destroyed, but
+// changes to their attributes are only observed when the tracker is refreshed.
+package presence
@@ -0,0 +1,330 @@
+package presence
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/rinq/rinq-go/src/internal/attributes"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/constraint"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// Change describes a session that started or stopped matching a tracker's
+// constraint.
+type Change struct {
+	// Session is the ID of the session that changed.
+	Session ident.SessionID
+
+	// IsPresent is true if the session now matches the constraint, or false if
+	// it no longer matches, including because it has been destroyed.
+	IsPresent bool
+}
+
+// Tracker maintains the set of tracked sessions that match a constraint.
+//
+// Sessions are added to the tracker with Add(). The tracker keeps a copy of
+// each session's attributes, which is brought up to date by applying the
+// attribute diffs returned by rinq.Revision.Diff(). Sessions are removed as
+// soon as they are destroyed, as the tracker waits for the destruction of each
+// session using rinq.Revision.AwaitDestroy().
+//
+// Attribute changes are not pushed to the tracker. Call Refresh()
+// periodically, or Update() whenever a tracked session is known to have
+// changed, to bring the tracker up to date.
+//
+// A Tracker can be registered with rinq.Peer.ObserveSessions() to track every
+// session owned by a peer.
+type Tracker struct {
+	ns  string
+	con constraint.Constraint
+
+	mutex    sync.Mutex
+	sessions map[ident.SessionID]*session
+	changes  chan Change
+}
+
+// session is the information a tracker holds about a single session.
+type session struct {
+	Revision  rinq.Revision
+	Attrs     attributes.Catalog
+	IsPresent bool
+	Cancel    func() // stops waiting for the session to be destroyed, nil if not waiting
+}
+
+// NewTracker returns a tracker for sessions that match con. ns is the default
+// namespace used if con does not contain a 'within' constraint.
+//
+// Changes are delivered on the channel returned by Changes(), which is
+// buffered to hold buffer changes. Changes that occur while the buffer is full
+// are discarded, so that the tracker is never blocked by a slow reader.
+func NewTracker(ns string, con constraint.Constraint, buffer uint) *Tracker {
+	if err := con.Validate(); err != nil {
+		panic(err)
+	}
+
+	return &Tracker{
+		ns:       ns,
+		con:      con,
+		sessions: map[ident.SessionID]*session{},
+		changes:  make(chan Change, buffer),
+	}
+}
+
+// Add starts tracking the session that rev belongs to, as of rev.
+//
+// If the session is already being tracked it is updated to rev, as per
+// Update(). The session is removed from the tracker when it is destroyed.
+func (t *Tracker) Add(ctx context.Context, rev rinq.Revision) error {
+	if err := t.Update(ctx, rev); err != nil {
+		return err
+	}
+
+	t.watch(rev)
+
+	return nil
+}
+
+// watch starts waiting for the session that rev belongs to to be destroyed, so
+// that it can be removed from the tracker. It does nothing if the session is
+// not tracked, or is already being watched.
+func (t *Tracker) watch(rev rinq.Revision) {
+	id := rev.SessionID()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s, ok := t.sessions[id]
+	if !ok || s.Cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Cancel = cancel
+
+	go func() {
+		if err := rev.AwaitDestroy(ctx); err == nil {
+			t.Remove(id)
+		}
+	}()
+}
+
+// Update brings the tracker's copy of the session that rev belongs to up to
+// date with rev. If the session is not yet tracked it is added.
+//
+// If the session has been destroyed, it is removed from the tracker and err is
+// nil.
+func (t *Tracker) Update(ctx context.Context, rev rinq.Revision) error {
+	id := rev.SessionID()
+
+	t.mutex.Lock()
+	s, ok := t.sessions[id]
+	t.mutex.Unlock()
+
+	var since ident.Revision
+	attrs := attributes.Catalog{}
+
+	if ok {
+		since = s.Revision.Ref().Rev
+		attrs = s.Attrs
+
+		if rev.Ref().Rev <= since {
+			return nil
+		}
+	}
+
+	d, err := rev.Diff(ctx, since)
+	if rinq.IsNotFound(err) {
+		t.Remove(id)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	changed := map[string]attributes.VTable{}
+	for ns, list := range d.Attrs {
+		vt := attrs[ns]
+		for _, a := range list {
+			vt = vt.With(attributes.VAttr{Attr: a})
+		}
+
+		changed[ns] = vt
+	}
+
+	attrs = attrs.WithNamespaces(changed)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// the session may have been updated to a later revision, or removed, while
+	// the diff was being fetched.
+	current, ok := t.sessions[id]
+	if ok && current.Revision.Ref().Rev != since || !ok && since != 0 {
+		return nil
+	}
+
+	isPresent := attrs.MatchConstraint(t.ns, t.con)
+	wasPresent := ok && current.IsPresent
+
+	if isPresent != wasPresent {
+		t.emit(Change{id, isPresent})
+	}
+
+	var cancel func()
+	if ok {
+		cancel = current.Cancel
+	}
+
+	t.sessions[id] = &session{rev, attrs, isPresent, cancel}
+
+	return nil
+}
+
+// Remove stops tracking the session identified by id.
+func (t *Tracker) Remove(id ident.SessionID) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if s, ok := t.sessions[id]; ok {
+		t.forget(id, s)
+
+		if s.IsPresent {
+			t.emit(Change{id, false})
+		}
+	}
+}
+
+// RemovePeer stops tracking every session owned by peerID. It is typically
+// called in response to a rinq.PeerLostEvent, as sessions owned by a peer that
+// stopped unexpectedly are never reported as destroyed.
+func (t *Tracker) RemovePeer(peerID ident.PeerID) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for id, s := range t.sessions {
+		if id.Peer != peerID {
+			continue
+		}
+
+		t.forget(id, s)
+
+		if s.IsPresent {
+			t.emit(Change{id, false})
+		}
+	}
+}
+
+// forget stops tracking the session identified by id, which is s. t.mutex
+// must be held by the caller.
+func (t *Tracker) forget(id ident.SessionID, s *session) {
+	delete(t.sessions, id)
+
+	if s.Cancel != nil {
+		s.Cancel()
+	}
+}
+
+// Refresh updates every tracked session to its latest revision. Sessions that
+// have been destroyed are removed.
+//
+// It returns the first error that occurs, after attempting to refresh every
+// session.
+func (t *Tracker) Refresh(ctx context.Context) error {
+	t.mutex.Lock()
+	revs := make([]rinq.Revision, 0, len(t.sessions))
+	for _, s := range t.sessions {
+		revs = append(revs, s.Revision)
+	}
+	t.mutex.Unlock()
+
+	var first error
+
+	for _, rev := range revs {
+		err := t.refresh(ctx, rev)
+		if first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// refresh updates the session that rev belongs to to its latest revision.
+func (t *Tracker) refresh(ctx context.Context, rev rinq.Revision) error {
+	for {
+		latest, err := rev.Refresh(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = t.Update(ctx, latest)
+		if !rinq.ShouldRetry(err) {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rev = latest
+	}
+}
+
+// Snapshot returns the IDs of the tracked sessions that currently match the
+// constraint, in order.
+func (t *Tracker) Snapshot() []ident.SessionID {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var ids []ident.SessionID
+	for id, s := range t.sessions {
+		if s.IsPresent {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+
+	return ids
+}
+
+// IsPresent returns true if the session identified by id is tracked and
+// currently matches the constraint.
+func (t *Tracker) IsPresent(id ident.SessionID) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s, ok := t.sessions[id]
+	return ok && s.IsPresent
+}
+
+// Changes returns a channel on which changes to the set of sessions that match
+// the constraint are delivered. Every call returns the same channel.
+func (t *Tracker) Changes() <-chan Change {
+	return t.changes
+}
+
+// SessionCreated starts tracking sess. It implements rinq.SessionObserver.
+func (t *Tracker) SessionCreated(sess rinq.Session) {
+	// new sessions have no attributes, so adding the session never performs
+	// any network IO. There is no need to wait for the session to be
+	// destroyed, as SessionDestroyed() is called when it is.
+	_ = t.Update(context.Background(), sess.CurrentRevision())
+}
+
+// SessionDestroyed stops tracking sess. It implements rinq.SessionObserver.
+func (t *Tracker) SessionDestroyed(sess rinq.Session) {
+	t.Remove(sess.ID())
+}
+
+// emit sends c on the changes channel, unless the buffer is full. t.mutex must
+// be held by the caller, so that changes are delivered in order.
+func (t *Tracker) emit(c Change) {
+	select {
+	case t.changes <- c:
+	default:
+	}
+}
@@ -0,0 +1,205 @@
+package presence_test
+
+import (
+	"context"
+
+	"github.com/jmalloc/twelf/src/twelf"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rinq/rinq-go/src/internal/command"
+	"github.com/rinq/rinq-go/src/internal/localsession"
+	"github.com/rinq/rinq-go/src/internal/notify"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/constraint"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+	"github.com/rinq/rinq-go/src/rinq/presence"
+)
+
+var _ = Describe("Tracker", func() {
+	var (
+		ctx     context.Context
+		peerID  ident.PeerID
+		sess    *localsession.Session
+		subject *presence.Tracker
+	)
+
+	newSession := func(seq uint32) *localsession.Session {
+		return localsession.NewSession(
+			peerID.Session(seq),
+			&nullInvoker{},
+			nil, // notifier
+			&nullListener{},
+			options.QuotaOptions{},
+			nil, // feed
+			&twelf.StandardLogger{},
+			opentracing.NoopTracer{},
+			nil, // sampler
+		)
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		peerID = ident.NewPeerID()
+		sess = newSession(1)
+		subject = presence.NewTracker("room", constraint.Equal("online", "yes"), 10)
+	})
+
+	Describe("Add", func() {
+		It("tracks a session that matches the constraint", func() {
+			rev, err := sess.CurrentRevision().Update(ctx, "room", rinq.Set("online", "yes"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = subject.Add(ctx, rev)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(subject.Snapshot()).To(ConsistOf(sess.ID()))
+			Expect(subject.Changes()).To(Receive(Equal(presence.Change{Session: sess.ID(), IsPresent: true})))
+		})
+
+		It("removes the session when it is destroyed", func() {
+			rev, err := sess.CurrentRevision().Update(ctx, "room", rinq.Set("online", "yes"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = subject.Add(ctx, rev)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(subject.Changes()).To(Receive())
+
+			sess.Destroy()
+
+			Eventually(subject.Changes()).Should(Receive(Equal(presence.Change{Session: sess.ID(), IsPresent: false})))
+			Expect(subject.Snapshot()).To(BeEmpty())
+		})
+
+		It("does not report a session that does not match the constraint", func() {
+			err := subject.Add(ctx, sess.CurrentRevision())
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(subject.Snapshot()).To(BeEmpty())
+			Expect(subject.Changes()).NotTo(Receive())
+		})
+	})
+
+	Describe("Refresh", func() {
+		It("applies attribute changes made after the session was added", func() {
+			err := subject.Add(ctx, sess.CurrentRevision())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			rev, err := sess.CurrentRevision().Update(ctx, "room", rinq.Set("online", "yes"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = subject.Refresh(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(subject.IsPresent(sess.ID())).To(BeTrue())
+			Expect(subject.Changes()).To(Receive(Equal(presence.Change{Session: sess.ID(), IsPresent: true})))
+
+			_, err = rev.Update(ctx, "room", rinq.Set("online", "no"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = subject.Refresh(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(subject.IsPresent(sess.ID())).To(BeFalse())
+			Expect(subject.Changes()).To(Receive(Equal(presence.Change{Session: sess.ID(), IsPresent: false})))
+		})
+
+		It("ignores changes in other namespaces", func() {
+			err := subject.Add(ctx, sess.CurrentRevision())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = sess.CurrentRevision().Update(ctx, "other", rinq.Set("online", "yes"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = subject.Refresh(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(subject.Snapshot()).To(BeEmpty())
+		})
+	})
+
+	Describe("Remove", func() {
+		It("reports that a present session is no longer present", func() {
+			rev, err := sess.CurrentRevision().Update(ctx, "room", rinq.Set("online", "yes"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = subject.Add(ctx, rev)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(subject.Changes()).To(Receive())
+
+			subject.Remove(sess.ID())
+
+			Expect(subject.Snapshot()).To(BeEmpty())
+			Expect(subject.Changes()).To(Receive(Equal(presence.Change{Session: sess.ID(), IsPresent: false})))
+		})
+	})
+
+	Describe("RemovePeer", func() {
+		It("reports that the peer's present sessions are no longer present", func() {
+			rev, err := sess.CurrentRevision().Update(ctx, "room", rinq.Set("online", "yes"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = subject.Add(ctx, rev)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(subject.Changes()).To(Receive())
+
+			subject.RemovePeer(sess.ID().Peer)
+
+			Expect(subject.Snapshot()).To(BeEmpty())
+			Expect(subject.Changes()).To(Receive(Equal(presence.Change{Session: sess.ID(), IsPresent: false})))
+		})
+
+		It("does not remove sessions owned by other peers", func() {
+			rev, err := sess.CurrentRevision().Update(ctx, "room", rinq.Set("online", "yes"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = subject.Add(ctx, rev)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			subject.RemovePeer(ident.NewPeerID())
+
+			Expect(subject.Snapshot()).To(ConsistOf(sess.ID()))
+		})
+	})
+
+	Describe("Snapshot", func() {
+		It("returns the present sessions in order", func() {
+			other := newSession(2)
+
+			for _, s := range []*localsession.Session{other, sess} {
+				rev, err := s.CurrentRevision().Update(ctx, "room", rinq.Set("online", "yes"))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(subject.Add(ctx, rev)).To(Succeed())
+			}
+
+			Expect(subject.Snapshot()).To(Equal([]ident.SessionID{sess.ID(), other.ID()}))
+		})
+	})
+
+	Describe("SessionCreated", func() {
+		It("tracks the session", func() {
+			subject.SessionCreated(sess)
+
+			_, err := sess.CurrentRevision().Update(ctx, "room", rinq.Set("online", "yes"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = subject.Refresh(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(subject.IsPresent(sess.ID())).To(BeTrue())
+		})
+	})
+})
+
+// nullInvoker is a command.Invoker that ignores async handlers.
+type nullInvoker struct {
+	command.Invoker
+}
+
+func (i *nullInvoker) SetAsyncHandler(ident.SessionID, rinq.AsyncHandler) {}
+
+// nullListener is a notify.Listener that is not listening to any namespaces.
+type nullListener struct {
+	notify.Listener
+}
+
+func (l *nullListener) UnlistenAll(ident.SessionID) error {
+	return nil
+}
@@ -20,6 +20,10 @@ type Revision interface {
 	// SessionID returns the ID of the underlying session.
 	SessionID() ident.SessionID
 
+	// Ref returns the session reference, which holds the session ID and the
+	// revision number represented by this instance.
+	Ref() ident.Ref
+
 	// Refresh returns the latest revision of the session.
 	//
 	// If the session has been destroyed, err is nil, but any operations on rev
@@ -68,6 +72,55 @@ type Revision interface {
 	// If err is nil, t contains all of the attributes specified in k.
 	GetMany(ctx context.Context, ns string, k ...string) (t AttrTable, err error)
 
+	// GetAll returns all of the attributes within the ns namespace of the
+	// attribute table that have a non-empty value or are frozen.
+	//
+	// The returned attributes are guaranteed to be correct as of Ref().Rev.
+	//
+	// The attributes are always fetched from the owning peer, as other peers
+	// can not know whether their copy of the namespace is complete.
+	//
+	// If any of the attributes can not be retrieved because they have already
+	// been modified, ShouldRetry(err) returns true. To fetch the attribute
+	// values at the later revision, first call Refresh() then retry the
+	// GetAll() on the newer revision.
+	//
+	// If IsNotFound(err) returns true, the session has been destroyed and the
+	// revision can not be queried.
+	GetAll(ctx context.Context, ns string) (t AttrTable, err error)
+
+	// Namespaces returns the names of the namespaces that contain attributes
+	// as of Ref().Rev, in lexical order.
+	//
+	// A namespace is included if any attribute within it had been created by
+	// Ref().Rev, even if that attribute has since been cleared.
+	//
+	// The namespaces are always fetched from the owning peer, as other peers
+	// can not know whether their copy of the attribute table is complete.
+	//
+	// If IsNotFound(err) returns true, the session has been destroyed and the
+	// revision can not be queried.
+	Namespaces(ctx context.Context) (ns []string, err error)
+
+	// Diff returns the attributes that were changed after the since revision,
+	// up to and including Ref().Rev.
+	//
+	// This allows a client that holds a copy of some attributes as of an
+	// earlier revision to reconcile that copy with the attributes of this
+	// revision, typically after a call to Refresh().
+	//
+	// The changes are always fetched from the owning peer. It panics if since
+	// is greater than Ref().Rev.
+	//
+	// If any of the changes can not be determined because the attributes have
+	// since been modified again, ShouldRetry(err) returns true. To fetch the
+	// changes up to the later revision, first call Refresh() then retry the
+	// Diff() on the newer revision.
+	//
+	// If IsNotFound(err) returns true, the session has been destroyed and the
+	// revision can not be queried.
+	Diff(ctx context.Context, since ident.Revision) (d Diff, err error)
+
 	// Update atomically modifies a set of attributes within the ns namespace of
 	// the attribute table.
 	//
@@ -87,6 +140,10 @@ type Revision interface {
 	//    attributes being updated are already frozen the update fails and
 	//    ShouldRetry(err) returns false.
 	//
+	// 3. The resulting attributes must not exceed the attribute quota of the
+	//    peer that owns the session, see options.AttrQuota(). If the quota
+	//    would be exceeded the update fails with a QuotaExceededError.
+	//
 	// If attrs is empty no update occurs, rev is this revision and err is nil.
 	//
 	// As a convenience, if the update fails for any reason, rev is this
@@ -94,16 +151,53 @@ type Revision interface {
 	// existing variable without first checking for errors.
 	Update(ctx context.Context, ns string, attrs ...Attr) (rev Revision, err error)
 
+	// UpdateMany atomically modifies a set of attributes across several
+	// namespaces of the attribute table.
+	//
+	// attrs is a map of namespace to the attributes to update within that
+	// namespace. All of the changes are applied in a single revision, such that
+	// no other operation can observe some namespaces updated and others not.
+	//
+	// The sematics are otherwise the same as for Update(). This means the
+	// operation fails if ANY of the changes reference a frozen attribute.
+	//
+	// If attrs contains no attributes no update occurs, rev is this revision
+	// and err is nil.
+	//
+	// As a convenience, if the update fails for any reason, rev is this
+	// revision. This allows the caller to assign the return value to an
+	// existing variable without first checking for errors.
+	UpdateMany(ctx context.Context, attrs map[string][]Attr) (rev Revision, err error)
+
 	// Clear is an update operation that atomically sets the value of each
 	// attribute within the ns namespace to the empty string.
 	//
+	// If keys is non-empty, only the attributes with those keys are cleared,
+	// otherwise every attribute in the namespace is cleared.
+	//
 	// The sematics are the same as for Update(). This means the operation fails
-	// if ANY attribute in the ns namespace is frozen.
+	// if ANY attribute being cleared is frozen.
 	//
 	// As a convenience, if the clear operation fails for any reason, rev is
 	// this revision. This allows the caller to assign the return value to an
 	// existing variable without first checking for errors.
-	Clear(ctx context.Context, ns string) (rev Revision, err error)
+	Clear(ctx context.Context, ns string, keys ...string) (rev Revision, err error)
+
+	// FreezeNamespace is an update operation that atomically freezes every
+	// attribute within the ns namespace, and prevents any new attributes from
+	// being created within that namespace.
+	//
+	// This is useful for finalizing information that must not change for the
+	// remainder of the session's lifetime, such as the result of
+	// authentication.
+	//
+	// The sematics are otherwise the same as for Update(). It is not an error
+	// to freeze a namespace that is already frozen.
+	//
+	// As a convenience, if the freeze operation fails for any reason, rev is
+	// this revision. This allows the caller to assign the return value to an
+	// existing variable without first checking for errors.
+	FreezeNamespace(ctx context.Context, ns string) (rev Revision, err error)
 
 	// Destroy terminates the session.
 	//
@@ -111,6 +205,40 @@ type Revision interface {
 	// revision. If Ref().Rev is not the latest revision the destroy fails;
 	// ShouldRetry(err) returns true.
 	Destroy(ctx context.Context) (err error)
+
+	// AwaitDestroy blocks until the session is destroyed, or ctx is canceled.
+	//
+	// This allows peers that hold resources on behalf of a session to release
+	// them promptly, rather than discovering that the session has been
+	// destroyed the next time it is used.
+	//
+	// err is nil if the session has been destroyed. It is not an error to await
+	// the destruction of a session that has already been destroyed. If ctx is
+	// canceled before the session is destroyed, err is ctx.Err().
+	//
+	// For remote sessions, the owning peer is asked to announce the session's
+	// destruction, so waiting does not occupy any of the owning peer's command
+	// workers.
+	AwaitDestroy(ctx context.Context) (err error)
+
+	// Await blocks until the attribute with key k within the ns namespace
+	// satisfies fn, or ctx is canceled.
+	//
+	// If the attribute satisfies fn as of Ref().Rev, rev is this revision.
+	// Otherwise, rev is the first later revision observed in which the
+	// attribute satisfies fn. Intermediate revisions are not necessarily
+	// observed, so fn should test the attribute value rather than count
+	// changes.
+	//
+	// If IsNotFound(err) returns true, the session has been destroyed before
+	// the attribute satisfied fn. If ctx is canceled first, err is ctx.Err().
+	//
+	// As a convenience, if the await fails for any reason, rev is this
+	// revision.
+	//
+	// For remote sessions, the owning peer is polled for changes to the
+	// attribute table.
+	Await(ctx context.Context, ns, k string, fn func(Attr) bool) (rev Revision, err error)
 }
 
 // ShouldRetry returns true if a call to Revision.Get(), GetMany(), Update() or
@@ -127,6 +255,61 @@ func ShouldRetry(err error) bool {
 	}
 }
 
+// maxUpdateAttempts is the maximum number of times RetryUpdate() attempts an
+// update before giving up.
+const maxUpdateAttempts = 10
+
+// RetryUpdate atomically modifies a set of attributes within the ns namespace
+// of sess's attribute table, retrying on the latest revision if the update
+// fails because the revision is out of date.
+//
+// fn is called with the revision to be updated, and returns the attributes to
+// apply to it. It may be called several times, so it should compute the
+// attributes from the state of the given revision, rather than from any
+// previously observed state.
+//
+// If fn returns an error, no update occurs and that error is returned. The
+// update is attempted a limited number of times, after which the most recent
+// stale update error is returned.
+//
+// On success, the newly created revision is returned. As with Update(), if the
+// update fails the revision on which the last attempt was made is returned.
+func RetryUpdate(
+	ctx context.Context,
+	sess Session,
+	ns string,
+	fn func(rev Revision) ([]Attr, error),
+) (Revision, error) {
+	rev := sess.CurrentRevision()
+
+	for attempt := 1; ; attempt++ {
+		attrs, err := fn(rev)
+		if err != nil {
+			return rev, err
+		}
+
+		next, err := rev.Update(ctx, ns, attrs...)
+		if err == nil {
+			return next, nil
+		}
+
+		if !ShouldRetry(err) || attempt == maxUpdateAttempts {
+			return rev, err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return rev, err
+		}
+
+		next, err = rev.Refresh(ctx)
+		if err != nil {
+			return rev, err
+		}
+
+		rev = next
+	}
+}
+
 // StaleFetchError indicates a failure to fetch an attribute for a specific
 // revision because it has been modified after that revision.
 type StaleFetchError struct {
@@ -140,6 +323,11 @@ func (err StaleFetchError) Error() string {
 	)
 }
 
+// Is returns true if target is ErrStaleFetch.
+func (err StaleFetchError) Is(target error) bool {
+	return target == ErrStaleFetch
+}
+
 // StaleUpdateError indicates a failure to update or destroy a session because
 // the session has been modified after that revision.
 type StaleUpdateError struct {
@@ -153,6 +341,11 @@ func (err StaleUpdateError) Error() string {
 	)
 }
 
+// Is returns true if target is ErrStaleUpdate.
+func (err StaleUpdateError) Is(target error) bool {
+	return target == ErrStaleUpdate
+}
+
 // FrozenAttributesError indicates a failure to update a session because at least
 // one of the attributes being updated is frozen.
 type FrozenAttributesError struct {
@@ -165,3 +358,39 @@ func (err FrozenAttributesError) Error() string {
 		err.Ref,
 	)
 }
+
+// Is returns true if target is ErrFrozenAttributes.
+func (err FrozenAttributesError) Is(target error) bool {
+	return target == ErrFrozenAttributes
+}
+
+// QuotaExceededError indicates a failure to update a session because the
+// resulting attributes would exceed the attribute quota of the peer that owns
+// the session.
+type QuotaExceededError struct {
+	Ref ident.Ref
+
+	// Namespace is the namespace with the quota that would be exceeded. It is
+	// empty if the quota of the session as a whole would be exceeded.
+	Namespace string
+}
+
+func (err QuotaExceededError) Error() string {
+	if err.Namespace == "" {
+		return fmt.Sprintf(
+			"can not update %s, the change exceeds the attribute quota of the session",
+			err.Ref,
+		)
+	}
+
+	return fmt.Sprintf(
+		"can not update %s, the change exceeds the attribute quota of the '%s' namespace",
+		err.Ref,
+		err.Namespace,
+	)
+}
+
+// Is returns true if target is ErrQuotaExceeded.
+func (err QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
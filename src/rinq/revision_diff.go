@@ -0,0 +1,28 @@
+package rinq
+
+import (
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// Diff describes the changes made to a session's attribute table between two
+// revisions, as returned by Revision.Diff().
+type Diff struct {
+	// From is the earlier of the two revisions. Changes made at or before
+	// From.Rev are not included in the diff.
+	From ident.Ref
+
+	// To is the later of the two revisions. Changes made after To.Rev are not
+	// included in the diff.
+	To ident.Ref
+
+	// Attrs is a map of namespace to the attributes within that namespace that
+	// were changed after From.Rev, ordered by key. The attributes are as they
+	// were at To.Rev. Attributes that have been cleared have an empty value.
+	// Namespaces without any changes are omitted.
+	Attrs map[string][]Attr
+}
+
+// IsEmpty returns true if no attributes changed between d.From and d.To.
+func (d Diff) IsEmpty() bool {
+	return len(d.Attrs) == 0
+}
@@ -21,7 +21,10 @@ func ExampleRevision_get() {
 	}
 	defer peer.Stop()
 
-	sess := peer.Session()
+	sess, err := peer.Session()
+	if err != nil {
+		panic(err)
+	}
 	defer sess.Destroy()
 
 	rev := sess.CurrentRevision()
@@ -63,7 +66,10 @@ func ExampleRevision_update() {
 	}
 	defer peer.Stop()
 
-	sess := peer.Session()
+	sess, err := peer.Session()
+	if err != nil {
+		panic(err)
+	}
 	defer sess.Destroy()
 
 	rev := sess.CurrentRevision()
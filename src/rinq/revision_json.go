@@ -0,0 +1,92 @@
+package rinq
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/rinq/rinq-go/src/internal/namespaces"
+)
+
+// jsonAttr is the JSON representation of an attribute used by ExportJSON() and
+// ImportJSON().
+type jsonAttr struct {
+	Value    string `json:"value"`
+	IsFrozen bool   `json:"frozen,omitempty"`
+}
+
+// ExportJSON returns a JSON document containing the attributes of rev within
+// each of the given namespaces. If no namespaces are given, the attributes in
+// every namespace returned by rev.Namespaces() are exported.
+//
+// The document is an object that maps each namespace to an object of
+// attributes, keyed by attribute key, for example:
+//
+//     {"user": {"name": {"value": "alice"}, "id": {"value": "7", "frozen": true}}}
+//
+// Namespaces that contain no attributes are included as empty objects.
+// Attributes with empty values that are not frozen are omitted.
+//
+// If any of the attributes can not be retrieved because they have already
+// been modified, ShouldRetry(err) returns true.
+func ExportJSON(ctx context.Context, rev Revision, ns ...string) ([]byte, error) {
+	if len(ns) == 0 {
+		var err error
+		ns, err = rev.Namespaces(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	doc := make(map[string]map[string]jsonAttr, len(ns))
+
+	for _, n := range ns {
+		t, err := rev.GetAll(ctx, n)
+		if err != nil {
+			return nil, err
+		}
+
+		attrs := map[string]jsonAttr{}
+		t.Each(func(attr Attr) bool {
+			attrs[attr.Key] = jsonAttr{attr.Value, attr.IsFrozen}
+			return true
+		})
+
+		doc[n] = attrs
+	}
+
+	return json.Marshal(doc)
+}
+
+// ImportJSON applies the attributes described by a JSON document to rev.
+//
+// The document must be in the format produced by ExportJSON(). All of the
+// attributes are applied as a single revision, as per Revision.UpdateMany().
+// Attributes that exist within the session but are not present in the document
+// are left unchanged.
+//
+// As a convenience, if the import fails for any reason, the returned revision
+// is rev.
+func ImportJSON(ctx context.Context, rev Revision, doc []byte) (Revision, error) {
+	var parsed map[string]map[string]jsonAttr
+
+	if err := json.Unmarshal(doc, &parsed); err != nil {
+		return rev, err
+	}
+
+	attrs := make(map[string][]Attr, len(parsed))
+
+	for ns, table := range parsed {
+		if err := namespaces.Validate(ns); err != nil {
+			return rev, err
+		}
+
+		for key, attr := range table {
+			attrs[ns] = append(
+				attrs[ns],
+				Attr{Key: key, Value: attr.Value, IsFrozen: attr.IsFrozen},
+			)
+		}
+	}
+
+	return rev.UpdateMany(ctx, attrs)
+}
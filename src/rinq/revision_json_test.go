@@ -0,0 +1,140 @@
+package rinq_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/internal/attributes"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+var _ = Describe("ExportJSON", func() {
+	It("returns a JSON document containing the attributes in each namespace", func() {
+		rev := &jsonRevision{
+			attrs: map[string]attributes.Table{
+				"user": {
+					"name": rinq.Set("name", "alice"),
+					"id":   rinq.Freeze("id", "7"),
+				},
+			},
+		}
+
+		doc, err := rinq.ExportJSON(context.Background(), rev, "user", "empty")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(doc).To(MatchJSON(`{
+			"user": {
+				"name": {"value": "alice"},
+				"id": {"value": "7", "frozen": true}
+			},
+			"empty": {}
+		}`))
+	})
+
+	It("exports every namespace if none are given", func() {
+		rev := &jsonRevision{
+			attrs: map[string]attributes.Table{
+				"user": {"name": rinq.Set("name", "alice")},
+				"org":  {"id": rinq.Set("id", "3")},
+			},
+		}
+
+		doc, err := rinq.ExportJSON(context.Background(), rev)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(doc).To(MatchJSON(`{
+			"user": {"name": {"value": "alice"}},
+			"org": {"id": {"value": "3"}}
+		}`))
+	})
+
+	It("returns an error if the attributes can not be fetched", func() {
+		rev := &jsonRevision{err: rinq.StaleFetchError{}}
+
+		_, err := rinq.ExportJSON(context.Background(), rev, "user")
+
+		Expect(rinq.ShouldRetry(err)).To(BeTrue())
+	})
+})
+
+var _ = Describe("ImportJSON", func() {
+	It("applies the attributes in the document as a single update", func() {
+		rev := &jsonRevision{}
+
+		next, err := rinq.ImportJSON(
+			context.Background(),
+			rev,
+			[]byte(`{"user": {"id": {"value": "7", "frozen": true}}}`),
+		)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next).To(Equal(rev.next))
+		Expect(rev.updates).To(Equal(
+			map[string][]rinq.Attr{
+				"user": {rinq.Freeze("id", "7")},
+			},
+		))
+	})
+
+	It("returns an error if the document is not valid JSON", func() {
+		rev := &jsonRevision{}
+
+		next, err := rinq.ImportJSON(context.Background(), rev, []byte(`{`))
+
+		Expect(err).To(HaveOccurred())
+		Expect(next).To(Equal(rev))
+	})
+
+	It("returns an error if a namespace is invalid", func() {
+		rev := &jsonRevision{}
+
+		_, err := rinq.ImportJSON(
+			context.Background(),
+			rev,
+			[]byte(`{"_reserved": {}}`),
+		)
+
+		Expect(err).To(HaveOccurred())
+		Expect(rev.updates).To(BeNil())
+	})
+})
+
+// jsonRevision is a rinq.Revision used to test ExportJSON() and ImportJSON().
+type jsonRevision struct {
+	rinq.Revision
+
+	attrs   map[string]attributes.Table
+	err     error
+	updates map[string][]rinq.Attr
+	next    *jsonRevision
+}
+
+func (r *jsonRevision) GetAll(_ context.Context, ns string) (rinq.AttrTable, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if t, ok := r.attrs[ns]; ok {
+		return t, nil
+	}
+
+	return attributes.Table{}, nil
+}
+
+func (r *jsonRevision) Namespaces(context.Context) ([]string, error) {
+	var ns []string
+
+	for n := range r.attrs {
+		ns = append(ns, n)
+	}
+
+	return ns, nil
+}
+
+func (r *jsonRevision) UpdateMany(_ context.Context, attrs map[string][]rinq.Attr) (rinq.Revision, error) {
+	r.updates = attrs
+	r.next = &jsonRevision{}
+
+	return r.next, nil
+}
@@ -1,6 +1,9 @@
 package rinq_test
 
 import (
+	"context"
+	"errors"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/rinq/rinq-go/src/rinq"
@@ -36,6 +39,79 @@ var _ = Describe("Revision", func() {
 		})
 	})
 
+	Describe("RetryUpdate", func() {
+		var (
+			ctx  context.Context
+			sess *retrySession
+		)
+
+		BeforeEach(func() {
+			ctx = context.Background()
+			sess = &retrySession{}
+		})
+
+		It("applies the attributes returned by fn", func() {
+			rev, err := rinq.RetryUpdate(ctx, sess, "ns", func(rinq.Revision) ([]rinq.Attr, error) {
+				return []rinq.Attr{rinq.Set("a", "1")}, nil
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rev.(*retryRevision).rev).To(Equal(1))
+			Expect(sess.updates).To(Equal([]rinq.Attr{rinq.Set("a", "1")}))
+		})
+
+		It("retries on the latest revision if the update is stale", func() {
+			sess.stale = 2
+
+			var revs []int
+			rev, err := rinq.RetryUpdate(ctx, sess, "ns", func(r rinq.Revision) ([]rinq.Attr, error) {
+				revs = append(revs, r.(*retryRevision).rev)
+				return []rinq.Attr{rinq.Set("a", "1")}, nil
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revs).To(Equal([]int{0, 1, 2}))
+			Expect(rev.(*retryRevision).rev).To(Equal(3))
+		})
+
+		It("returns the error from fn without updating", func() {
+			expected := errors.New("<error>")
+
+			_, err := rinq.RetryUpdate(ctx, sess, "ns", func(rinq.Revision) ([]rinq.Attr, error) {
+				return nil, expected
+			})
+
+			Expect(err).To(Equal(expected))
+			Expect(sess.updates).To(BeEmpty())
+		})
+
+		It("does not retry errors other than stale updates", func() {
+			sess.err = rinq.FrozenAttributesError{}
+
+			calls := 0
+			_, err := rinq.RetryUpdate(ctx, sess, "ns", func(rinq.Revision) ([]rinq.Attr, error) {
+				calls++
+				return []rinq.Attr{rinq.Set("a", "1")}, nil
+			})
+
+			Expect(err).To(Equal(rinq.FrozenAttributesError{}))
+			Expect(calls).To(Equal(1))
+		})
+
+		It("gives up after a limited number of attempts", func() {
+			sess.stale = 1000
+
+			calls := 0
+			_, err := rinq.RetryUpdate(ctx, sess, "ns", func(rinq.Revision) ([]rinq.Attr, error) {
+				calls++
+				return nil, nil
+			})
+
+			Expect(rinq.ShouldRetry(err)).To(BeTrue())
+			Expect(calls).To(Equal(10))
+		})
+	})
+
 	Describe("StaleFetchError", func() {
 		Describe("Error", func() {
 			It("returns the message", func() {
@@ -68,4 +144,65 @@ var _ = Describe("Revision", func() {
 			})
 		})
 	})
+
+	Describe("QuotaExceededError", func() {
+		Describe("Error", func() {
+			It("returns the message for a session quota", func() {
+				err := rinq.QuotaExceededError{Ref: sessionRef}
+				Expect(err.Error()).To(Equal(
+					"can not update 1-0002.3@4, the change exceeds the attribute quota of the session",
+				))
+			})
+
+			It("returns the message for a namespace quota", func() {
+				err := rinq.QuotaExceededError{Ref: sessionRef, Namespace: "ns"}
+				Expect(err.Error()).To(Equal(
+					"can not update 1-0002.3@4, the change exceeds the attribute quota of the 'ns' namespace",
+				))
+			})
+		})
+	})
 })
+
+// retrySession is a rinq.Session used to test RetryUpdate(). Each update fails
+// with a stale update error until stale reaches zero.
+type retrySession struct {
+	rinq.Session
+
+	rev     int
+	stale   int
+	err     error
+	updates []rinq.Attr
+}
+
+func (s *retrySession) CurrentRevision() rinq.Revision {
+	return &retryRevision{session: s, rev: s.rev}
+}
+
+type retryRevision struct {
+	rinq.Revision
+
+	session *retrySession
+	rev     int
+}
+
+func (r *retryRevision) Refresh(context.Context) (rinq.Revision, error) {
+	return r.session.CurrentRevision(), nil
+}
+
+func (r *retryRevision) Update(_ context.Context, _ string, attrs ...rinq.Attr) (rinq.Revision, error) {
+	if r.session.err != nil {
+		return r, r.session.err
+	}
+
+	r.session.rev++
+
+	if r.session.stale > 0 {
+		r.session.stale--
+		return r, rinq.StaleUpdateError{}
+	}
+
+	r.session.updates = append(r.session.updates, attrs...)
+
+	return r.session.CurrentRevision(), nil
+}
@@ -3,6 +3,7 @@ package rinq
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/rinq/rinq-go/src/rinq/constraint"
 	"github.com/rinq/rinq-go/src/rinq/ident"
@@ -41,6 +42,31 @@ type Session interface {
 	// CurrentRevision returns the current revision of this session.
 	CurrentRevision() Revision
 
+	// AwaitRevision blocks until the session's current revision is at least
+	// rev, or ctx is canceled.
+	//
+	// This provides read-your-writes consistency when another component
+	// reports a ref out-of-band, such as a peer that updated the session
+	// remotely and passed the resulting ref along with a message.
+	//
+	// On success, r is the current revision, which may be later than rev. If
+	// IsNotFound(err) returns true, the session was destroyed before reaching
+	// rev. If ctx is canceled first, err is ctx.Err().
+	AwaitRevision(ctx context.Context, rev ident.Revision) (r Revision, err error)
+
+	// SetLabel sets a human-readable label for the session, such as the name
+	// of the user or connection that the session represents.
+	//
+	// The label is included in log messages about the session that are
+	// produced by the owning peer, making it easier to identify the session
+	// than by its ID alone. It is not visible to other peers. An empty label
+	// removes any existing label.
+	SetLabel(label string)
+
+	// Label returns the label set by SetLabel(), or an empty string if the
+	// session has no label.
+	Label() string
+
 	// Call sends a command request to the next available peer listening to the
 	// ns namespace and waits for a response.
 	//
@@ -52,7 +78,14 @@ type Session interface {
 	// respectively. Both are passed to the command handler on the server.
 	//
 	// Calls always use a deadline; if ctx does not have a deadline, a timeout
-	// described by options.DefaultTimeout() is used.
+	// described by options.DefaultTimeout() is used. The deadline is shared
+	// with the server. If ctx is canceled before a response is received, the
+	// server is asked to cancel the context passed to the command handler.
+	//
+	// If ctx was created by WithHedging(), a duplicate request is sent if no
+	// response is received within the hedging delay. If ctx was created by
+	// WithGroup() or WithPreferredGroup(), the request is sent to a peer in
+	// that group.
 	//
 	// If the call completes successfully, err is nil and in is the
 	// application-defined response payload sent by the server.
@@ -67,6 +100,10 @@ type Session interface {
 	// contains the failure's application-defined payload; for this reason
 	// out.Close() must always be called, even if err is non-nil.
 	//
+	// IsDeadlineExceeded(err) returns true if no response was received before
+	// the deadline. If the peer is configured with options.DeadlineDiagnostics()
+	// err is a DeadlineExceededError describing how far the request progressed.
+	//
 	// If IsNotFound(err) returns true, the session has been destroyed and the
 	// command request can not be sent.
 	Call(ctx context.Context, ns, cmd string, out *Payload) (in *Payload, err error)
@@ -84,18 +121,49 @@ type Session interface {
 	// request, along with the response payload and error.
 	//
 	// It is the application's responsibility to correlate the request with the
-	// response and handle the context deadline. The request is NOT tracked by
-	// the session and as such the handler is never invoked in the event of a
-	// timeout.
+	// response. If no response is received before the context deadline, or
+	// the peer's default timeout if ctx has no deadline, the handler is invoked
+	// with a context.DeadlineExceeded error. Any response that arrives after
+	// the deadline is discarded.
 	//
 	// If IsNotFound(err) returns true, the session has been destroyed and the
 	// command request can not be sent.
 	CallAsync(ctx context.Context, ns, cmd string, out *Payload) (id ident.MessageID, err error)
 
+	// CallAsyncFunc sends a command request to the next available peer
+	// listening to the ns namespace and instructs it to send a response, but
+	// does not block.
+	//
+	// It is equivalent to CallAsync(), except that the response is passed to
+	// h instead of the handler specified by SetAsyncHandler(). This allows
+	// each call to handle its own response without having to correlate it
+	// with the request. h is invoked exactly once, unless the session is
+	// destroyed before the response is received.
+	//
+	// If IsNotFound(err) returns true, the session has been destroyed and the
+	// command request can not be sent.
+	CallAsyncFunc(ctx context.Context, ns, cmd string, out *Payload, h AsyncHandler) (id ident.MessageID, err error)
+
+	// CallFuture sends a command request to the next available peer listening
+	// to the ns namespace and instructs it to send a response, but does not
+	// block.
+	//
+	// cmd and out are an application-defined command name and request payload,
+	// respectively. Both are passed to the command handler on the server.
+	//
+	// f provides the response once it is received. If no response is received
+	// before the context deadline, or the peer's default timeout if ctx has no
+	// deadline, f.Result() returns a context.DeadlineExceeded error.
+	//
+	// If IsNotFound(err) returns true, the session has been destroyed and the
+	// command request can not be sent.
+	CallFuture(ctx context.Context, ns, cmd string, out *Payload) (f Future, err error)
+
 	// SetAsyncHandler sets the asynchronous call handler.
 	//
 	// h is invoked for each command response received to a command request made
-	// with CallAsync().
+	// with CallAsync(). It is not invoked for requests made with
+	// CallAsyncFunc().
 	//
 	// If IsNotFound(err) returns true, the session has been destroyed and the
 	// handler can not be set.
@@ -107,10 +175,41 @@ type Session interface {
 	// cmd and out are an application-defined command name and request payload,
 	// respectively. Both are passed to the command handler on the server.
 	//
+	// If the peer was created with options.AtLeastOnce() for ns, Execute()
+	// instead blocks until the broker has persisted the request, which is
+	// then handled at least once.
+	//
 	// If IsNotFound(err) returns true, the session has been destroyed and the
 	// command request can not be sent.
 	Execute(ctx context.Context, ns, cmd string, out *Payload) (err error)
 
+	// ExecuteAt sends a command request to the next available peer listening
+	// to the ns namespace at time t, and returns immediately.
+	//
+	// The request is held by the broker until t, so it is executed even if
+	// this session or its peer no longer exist by then. It is executed no
+	// earlier than t, but may be delayed if no peer is available at that time.
+	// If t is in the past the request is sent immediately. ctx applies only to
+	// scheduling the request, its deadline is not passed to the handler.
+	//
+	// id is the message ID of the request, which can be passed to
+	// CancelExecuteAt() to cancel the execution.
+	//
+	// If IsNotFound(err) returns true, the session has been destroyed and the
+	// command request can not be scheduled.
+	ExecuteAt(ctx context.Context, t time.Time, ns, cmd string, out *Payload) (id ident.MessageID, err error)
+
+	// CancelExecuteAt cancels a command request that was scheduled by
+	// ExecuteAt().
+	//
+	// id may refer to a request scheduled by any session, including sessions
+	// that no longer exist. ok is true if the request was canceled, or false
+	// if it has already been sent or is unknown.
+	//
+	// If IsNotFound(err) returns true, the session has been destroyed and the
+	// request can not be canceled.
+	CancelExecuteAt(ctx context.Context, id ident.MessageID) (ok bool, err error)
+
 	// Notify sends a message directly to another session listening to the ns
 	// namespace.
 	//
@@ -133,17 +232,86 @@ type Session interface {
 	// respectively. Both are passed to the notification handlers configured on
 	// those sessions that match c.
 	//
+	// The sessions that match c are not known when NotifyMany returns, as they
+	// are determined by each peer that receives the notification. Each of
+	// those peers logs the number of its sessions that the notification was
+	// dispatched to at the debug level, and reports it to this session if a
+	// handler has been set with SetDispatchHandler().
+	//
 	// If IsNotFound(err) returns true, this session has been destroyed and the
 	// notification can not be sent.
 	NotifyMany(ctx context.Context, ns, t string, c constraint.Constraint, out *Payload) error
 
+	// SetDispatchHandler sets the dispatch report handler.
+	//
+	// While a handler is set, each peer that receives a notification sent with
+	// NotifyMany() reports the number of its sessions that the notification
+	// was dispatched to, and h is invoked for each report. If h is nil,
+	// reports are no longer requested.
+	//
+	// If IsNotFound(err) returns true, the session has been destroyed and the
+	// handler can not be set.
+	SetDispatchHandler(h DispatchHandler) error
+
+	// NotifyAndWait sends a message directly to another session listening to
+	// the ns namespace and waits for a reply.
+	//
+	// t and out are an application-defined notification type and payload,
+	// respectively. Both are passed to the notification handler configured on
+	// the session identified by s, which replies by calling Session.Reply().
+	//
+	// It is intended for lightweight request/reply interactions between
+	// sessions that do not warrant a command namespace. Unlike a command call,
+	// no error is reported if the target session does not exist, is not
+	// listening to ns or does not reply; NotifyAndWait blocks until ctx is
+	// canceled or reaches its deadline. ctx should therefore always have a
+	// deadline.
+	//
+	// If IsNotFound(err) returns true, this session has been destroyed, either
+	// before the notification was sent or while waiting for the reply.
+	NotifyAndWait(ctx context.Context, ns, t string, s ident.SessionID, out *Payload) (in *Payload, err error)
+
+	// Reply sends a reply to a notification that was sent with
+	// NotifyAndWait().
+	//
+	// n is the notification being replied to, as passed to this session's
+	// notification handler. It panics if n.IsRequest is false.
+	//
+	// The reply is discarded if the source session is no longer waiting for
+	// it, and only the first reply to each notification is used.
+	//
+	// If IsNotFound(err) returns true, this session has been destroyed and the
+	// reply can not be sent.
+	Reply(ctx context.Context, n Notification, out *Payload) error
+
+	// Link returns a bidirectional message pipe to the target session.
+	//
+	// Links are intended for chatty interactions between two sessions, where
+	// the overhead of a command call per message is too high. Messages are
+	// received only if the target session also has a link to this session.
+	// Each session has at most one link to any given target, so calling Link()
+	// again with the same target returns the existing link until it is closed.
+	//
+	// Messages are handled as notifications by the receiving peer. They are
+	// received in the order they were sent only if that peer is configured with
+	// options.OrderedNotifications().
+	//
+	// If IsNotFound(err) returns true, this session has been destroyed and the
+	// link can not be created.
+	Link(target ident.SessionID) (Link, error)
+
 	// Listen begins listening for notifications sent to this session in the ns
 	// namespace.
 	//
 	// When a notification is received with a namespace equal to ns, h is invoked.
 	//
-	// h is invoked on its own goroutine for each notification.
-	Listen(ns string, h NotificationHandler) error
+	// h is invoked on its own goroutine for each notification. The number of
+	// handlers invoked concurrently for each session can be limited with the
+	// options.ListenerConcurrency() option.
+	//
+	// If any middleware is given, each notification is passed through mw, in
+	// order, before h is invoked. See WithNotificationMiddleware().
+	Listen(ns string, h NotificationHandler, mw ...NotificationMiddleware) error
 
 	// Unlisten stops listening for notifications from the ns namespace.
 	//
@@ -165,10 +333,37 @@ type Session interface {
 	//
 	// All sessions are destroyed when their owning peer is stopped.
 	Done() <-chan struct{}
+
+	// OnDestroy registers fn to be called when the session is destroyed.
+	//
+	// It is intended for releasing resources that are associated with the
+	// session, such as file handles, goroutines or external locks. As with
+	// Done(), the session may be destroyed locally or remotely.
+	//
+	// Registered functions are called in the order they were registered, after
+	// any pending Session.Call() operations have completed, but before the
+	// Done() channel is closed. If the session has already been destroyed fn is
+	// called immediately.
+	OnDestroy(fn func())
+}
+
+// SessionObserver is an interface for receiving notifications about the
+// lifecycle of the sessions owned by a peer.
+//
+// Observers are registered with Peer.ObserveSessions(). The methods are called
+// synchronously, and must not block.
+type SessionObserver interface {
+	// SessionCreated is called when a new session is created by
+	// Peer.Session().
+	SessionCreated(sess Session)
+
+	// SessionDestroyed is called when sess is destroyed. It is called after
+	// any functions registered with sess.OnDestroy().
+	SessionDestroyed(sess Session)
 }
 
 // AsyncHandler is a call-back function invoked when a response is received to
-// a command call made with Session.CallAsync()
+// a command call made with Session.CallAsync() or Session.CallAsyncFunc().
 //
 // If err is non-nil, it always represents a server-side error.
 //
@@ -202,3 +397,38 @@ func IsNotFound(err error) bool {
 func (err NotFoundError) Error() string {
 	return fmt.Sprintf("session %s not found", err.ID)
 }
+
+// Is returns true if target is ErrNotFound.
+func (err NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// SessionLimitError indicates a failure to create a session because the peer
+// has reached the limits configured by options.SessionLimit().
+type SessionLimitError struct {
+	Peer ident.PeerID
+
+	// RateExceeded is true if sessions are being created faster than the
+	// peer's rate limit allows. Otherwise, the peer already owns the maximum
+	// number of sessions.
+	RateExceeded bool
+}
+
+// IsSessionLimit returns true if err is a SessionLimitError.
+func IsSessionLimit(err error) bool {
+	_, ok := err.(SessionLimitError)
+	return ok
+}
+
+func (err SessionLimitError) Error() string {
+	if err.RateExceeded {
+		return fmt.Sprintf("can not create a session on %s, sessions are being created too quickly", err.Peer)
+	}
+
+	return fmt.Sprintf("can not create a session on %s, the peer owns the maximum number of sessions", err.Peer)
+}
+
+// Is returns true if target is ErrSessionLimit.
+func (err SessionLimitError) Is(target error) bool {
+	return target == ErrSessionLimit
+}
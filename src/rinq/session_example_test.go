@@ -35,7 +35,10 @@ func ExampleSession_callAsync() {
 		res.Done(payload)
 	})
 
-	sess := peer.Session()
+	sess, err := peer.Session()
+	if err != nil {
+		panic(err)
+	}
 	defer sess.Destroy()
 
 	// setup the asynchronous response handler
@@ -67,6 +70,126 @@ func ExampleSession_callAsync() {
 	// Output: received my-api::test response with <payload> payload
 }
 
+// This example shows how to make an asynchronous command call that handles its
+// own response.
+func ExampleSession_callAsyncFunc() {
+	peer, err := rinqamqp.DialEnv()
+	if err != nil {
+		panic(err)
+	}
+	defer peer.Stop()
+
+	// listen for command requests
+	peer.Listen("my-api", func(
+		ctx context.Context,
+		req Request,
+		res Response,
+	) {
+		defer req.Payload.Close()
+
+		payload := NewPayload("<payload>")
+		defer payload.Close()
+
+		res.Done(payload)
+	})
+
+	sess, err := peer.Session()
+	if err != nil {
+		panic(err)
+	}
+	defer sess.Destroy()
+
+	// send the command request, along with the handler for its response
+	if _, err := sess.CallAsyncFunc(
+		context.Background(),
+		"my-api",
+		"test",
+		nil,
+		func(
+			ctx context.Context,
+			s Session, _ ident.MessageID,
+			ns, cmd string,
+			in *Payload, err error,
+		) {
+			defer in.Close()
+			peer.Stop()
+
+			fmt.Printf("received %s::%s response with %s payload\n", ns, cmd, in.Value())
+		},
+	); err != nil {
+		panic(err)
+	}
+
+	<-peer.Done()
+	// Output: received my-api::test response with <payload> payload
+}
+
+// This example shows how to make several command calls concurrently, and wait
+// for all of their responses.
+func ExampleSession_callFuture() {
+	peer, err := rinqamqp.DialEnv()
+	if err != nil {
+		panic(err)
+	}
+	defer peer.Stop()
+
+	// listen for command requests
+	peer.Listen("my-api", func(
+		ctx context.Context,
+		req Request,
+		res Response,
+	) {
+		defer req.Payload.Close()
+
+		var n int
+		if err := req.Payload.Decode(&n); err != nil {
+			res.Error(err)
+			return
+		}
+
+		payload := NewPayload(n * n)
+		defer payload.Close()
+
+		res.Done(payload)
+	})
+
+	sess, err := peer.Session()
+	if err != nil {
+		panic(err)
+	}
+	defer sess.Destroy()
+
+	// send all of the command requests before waiting for any responses
+	var futures []Future
+	for n := 1; n <= 3; n++ {
+		out := NewPayload(n)
+		f, err := sess.CallFuture(context.Background(), "my-api", "square", out)
+		out.Close()
+
+		if err != nil {
+			panic(err)
+		}
+
+		futures = append(futures, f)
+	}
+
+	// collect the responses
+	for _, f := range futures {
+		in, err := f.Result()
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Println(in.Value())
+		in.Close()
+	}
+
+	// Output:
+	// 1
+	// 4
+	// 9
+}
+
 // This example shows how to send a notification from one session to another.
 func ExampleSession_notify() {
 	peer, err := rinqamqp.DialEnv()
@@ -76,7 +199,10 @@ func ExampleSession_notify() {
 	defer peer.Stop()
 
 	// create a session to receive the notification
-	recv := peer.Session()
+	recv, err := peer.Session()
+	if err != nil {
+		panic(err)
+	}
 	defer recv.Destroy()
 
 	if err := recv.Listen(
@@ -96,7 +222,10 @@ func ExampleSession_notify() {
 	}
 
 	// create a session to send the notification to recv
-	send := peer.Session()
+	send, err := peer.Session()
+	if err != nil {
+		panic(err)
+	}
 	defer send.Destroy()
 
 	payload := NewPayload("<payload>")
@@ -125,9 +254,20 @@ func ExampleSession_notifyMany() {
 	}
 
 	// create three sessions for receiving notifications
-	recv1 := peer.Session()
-	recv2 := peer.Session()
-	recv3 := peer.Session()
+	recv1, err := peer.Session()
+	if err != nil {
+		panic(err)
+	}
+
+	recv2, err := peer.Session()
+	if err != nil {
+		panic(err)
+	}
+
+	recv3, err := peer.Session()
+	if err != nil {
+		panic(err)
+	}
 
 	// create a notification handler that stops the peer once TWO notifications
 	// have been received
@@ -167,7 +307,10 @@ func ExampleSession_notifyMany() {
 	}
 
 	// create a session to send the notification to recv
-	send := peer.Session()
+	send, err := peer.Session()
+	if err != nil {
+		panic(err)
+	}
 
 	payload := NewPayload("<payload>")
 	defer payload.Close()
@@ -0,0 +1,43 @@
+package rinq
+
+import "context"
+
+// StreamOffset is the position of a notification in a notification stream.
+//
+// Non-negative values identify a specific notification. The negative values
+// StreamNext, StreamFirst and StreamLast are relative positions that can be
+// passed to Peer.ListenStream().
+type StreamOffset int64
+
+const (
+	// StreamNext starts consuming a stream with the first notification that is
+	// published after the consumer starts.
+	StreamNext StreamOffset = -1
+
+	// StreamFirst starts consuming a stream with the oldest notification that
+	// the stream retains.
+	StreamFirst StreamOffset = -2
+
+	// StreamLast starts consuming a stream with the most recently written
+	// chunk of notifications, which may include several notifications.
+	StreamLast StreamOffset = -3
+)
+
+// StreamHandler is a callback-function invoked for each notification that is
+// consumed from a notification stream.
+//
+// offset is the position of n in the stream. An application that records the
+// offset of the last notification it handled can resume consumption after a
+// restart by passing offset+1 to Peer.ListenStream().
+//
+// The handler is responsible for closing n.Payload, however there is no
+// requirement that the payload be closed during the execution of the handler.
+//
+// Notifications are consumed in the order they were written to the stream.
+// The handler is invoked for one notification at a time, and the next
+// notification is not delivered until it returns.
+type StreamHandler func(
+	ctx context.Context,
+	n Notification,
+	offset StreamOffset,
+)
@@ -1,6 +1,10 @@
 package trace
 
-import "context"
+import (
+	"context"
+
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
 
 // With returns a new context derived from parent that includes an
 // application-defined "trace ID" value.
@@ -46,6 +50,43 @@ func Get(ctx context.Context) string {
 	return str
 }
 
-type keyType struct{}
+// WithPeer returns a new context derived from parent that includes the ID of
+// the peer that is handling the current operation.
+//
+// Rinq adds the peer ID to the ctx supplied to command and notification
+// handlers, it is not usually necessary to call WithPeer() directly.
+func WithPeer(parent context.Context, id ident.PeerID) context.Context {
+	return context.WithValue(parent, peerKey, id)
+}
+
+// GetPeer returns the peer ID from ctx. ok is false if none is present.
+func GetPeer(ctx context.Context) (id ident.PeerID, ok bool) {
+	id, ok = ctx.Value(peerKey).(ident.PeerID)
+	return
+}
+
+// WithSession returns a new context derived from parent that includes a
+// reference to the session that initiated the current operation, such as the
+// source of a command request or notification.
+//
+// Rinq adds the session reference to the ctx supplied to command and
+// notification handlers, it is not usually necessary to call WithSession()
+// directly.
+func WithSession(parent context.Context, ref ident.Ref) context.Context {
+	return context.WithValue(parent, sessionKey, ref)
+}
+
+// GetSession returns the session reference from ctx. ok is false if none is
+// present.
+func GetSession(ctx context.Context) (ref ident.Ref, ok bool) {
+	ref, ok = ctx.Value(sessionKey).(ident.Ref)
+	return
+}
+
+type keyType int
 
-var key keyType
+const (
+	key keyType = iota
+	peerKey
+	sessionKey
+)
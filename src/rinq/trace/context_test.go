@@ -5,6 +5,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq/ident"
 	. "github.com/rinq/rinq-go/src/rinq/trace"
 )
 
@@ -49,3 +50,41 @@ var _ = Describe("Get", func() {
 		Expect(Get(ctx)).To(Equal(""))
 	})
 })
+
+var _ = Describe("WithPeer", func() {
+	It("adds the peer ID", func() {
+		id := ident.NewPeerID()
+		ctx := WithPeer(context.Background(), id)
+
+		p, ok := GetPeer(ctx)
+		Expect(ok).To(BeTrue())
+		Expect(p).To(Equal(id))
+	})
+})
+
+var _ = Describe("GetPeer", func() {
+	It("returns false when no peer ID is present", func() {
+		_, ok := GetPeer(context.Background())
+
+		Expect(ok).To(BeFalse())
+	})
+})
+
+var _ = Describe("WithSession", func() {
+	It("adds the session reference", func() {
+		ref := ident.NewPeerID().Session(1).At(2)
+		ctx := WithSession(context.Background(), ref)
+
+		r, ok := GetSession(ctx)
+		Expect(ok).To(BeTrue())
+		Expect(r).To(Equal(ref))
+	})
+})
+
+var _ = Describe("GetSession", func() {
+	It("returns false when no session reference is present", func() {
+		_, ok := GetSession(context.Background())
+
+		Expect(ok).To(BeFalse())
+	})
+})
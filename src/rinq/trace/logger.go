@@ -0,0 +1,92 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/jmalloc/twelf/src/twelf"
+)
+
+// Logger returns a logger that appends the trace ID, peer ID and session
+// reference from ctx to each message written to l.
+//
+// It is intended for use within command and notification handlers, so that
+// log messages produced by the application can be correlated with those
+// produced by Rinq without formatting the trace ID at each call site, for
+// example:
+//
+//     "order placed (peer: 191C, session: 191C.1@3) [58AEE146-191C.1@3#2]"
+//
+// Any of the values that are not present in ctx are omitted.
+func Logger(ctx context.Context, l twelf.Logger) twelf.Logger {
+	suffix := contextSuffix(ctx)
+
+	if suffix == "" {
+		return l
+	}
+
+	return &contextLogger{l, suffix}
+}
+
+// contextLogger is a twelf.Logger that appends a fixed suffix to each message.
+type contextLogger struct {
+	twelf.Logger
+
+	suffix string
+}
+
+func (l *contextLogger) Log(f string, v ...interface{}) {
+	l.Logger.LogString(fmt.Sprintf(f, v...) + l.suffix)
+}
+
+func (l *contextLogger) LogString(s string) {
+	l.Logger.LogString(s + l.suffix)
+}
+
+func (l *contextLogger) Debug(f string, v ...interface{}) {
+	if l.Logger.IsDebug() {
+		l.Logger.DebugString(fmt.Sprintf(f, v...) + l.suffix)
+	}
+}
+
+func (l *contextLogger) DebugString(s string) {
+	l.Logger.DebugString(s + l.suffix)
+}
+
+// contextSuffix returns the string appended to messages logged by a logger
+// returned by Logger().
+func contextSuffix(ctx context.Context) string {
+	var buf bytes.Buffer
+
+	peerID, hasPeer := GetPeer(ctx)
+	ref, hasSession := GetSession(ctx)
+
+	if hasPeer || hasSession {
+		buf.WriteString(" (")
+
+		if hasPeer {
+			buf.WriteString("peer: ")
+			buf.WriteString(peerID.ShortString())
+		}
+
+		if hasSession {
+			if hasPeer {
+				buf.WriteString(", ")
+			}
+
+			buf.WriteString("session: ")
+			buf.WriteString(ref.ShortString())
+		}
+
+		buf.WriteString(")")
+	}
+
+	if t := Get(ctx); t != "" {
+		buf.WriteString(" [")
+		buf.WriteString(t)
+		buf.WriteString("]")
+	}
+
+	return buf.String()
+}
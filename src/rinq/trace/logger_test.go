@@ -0,0 +1,88 @@
+package trace_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	. "github.com/rinq/rinq-go/src/rinq/trace"
+)
+
+var _ = Describe("Logger", func() {
+	var (
+		peerID ident.PeerID
+		logger *bufferLogger
+	)
+
+	BeforeEach(func() {
+		peerID = ident.PeerID{Clock: 1, Rand: 0x191C}
+		logger = &bufferLogger{isDebug: true}
+	})
+
+	It("appends the trace ID, peer ID and session reference", func() {
+		ctx := With(context.Background(), "<id>")
+		ctx = WithPeer(ctx, peerID)
+		ctx = WithSession(ctx, peerID.Session(1).At(3))
+
+		Logger(ctx, logger).Log("value is %d", 7)
+
+		Expect(logger.lines).To(Equal([]string{
+			"value is 7 (peer: 191C, session: 191C.1@3) [<id>]",
+		}))
+	})
+
+	It("omits values that are not present", func() {
+		ctx := With(context.Background(), "<id>")
+
+		Logger(ctx, logger).Debug("value is %d", 7)
+
+		Expect(logger.lines).To(Equal([]string{
+			"value is 7 [<id>]",
+		}))
+	})
+
+	It("returns the logger unchanged if the context contains no values", func() {
+		Expect(Logger(context.Background(), logger)).To(BeIdenticalTo(logger))
+	})
+
+	It("does not format debug messages if debug logging is disabled", func() {
+		logger.isDebug = false
+		ctx := With(context.Background(), "<id>")
+
+		Logger(ctx, logger).Debug("value is %d", 7)
+
+		Expect(logger.lines).To(BeEmpty())
+	})
+})
+
+// bufferLogger is a twelf.Logger that records each message.
+type bufferLogger struct {
+	isDebug bool
+	lines   []string
+}
+
+func (l *bufferLogger) Log(f string, v ...interface{}) {
+	l.LogString(fmt.Sprintf(f, v...))
+}
+
+func (l *bufferLogger) LogString(s string) {
+	l.lines = append(l.lines, s)
+}
+
+func (l *bufferLogger) Debug(f string, v ...interface{}) {
+	if l.isDebug {
+		l.Log(f, v...)
+	}
+}
+
+func (l *bufferLogger) DebugString(s string) {
+	if l.isDebug {
+		l.LogString(s)
+	}
+}
+
+func (l *bufferLogger) IsDebug() bool {
+	return l.isDebug
+}
@@ -0,0 +1,30 @@
+package rinq
+
+import (
+	"errors"
+
+	"github.com/rinq/rinq-go/src/internal/namespaces"
+)
+
+// ValidateNamespace returns an error if ns is not a valid namespace.
+//
+// Namespaces must not be empty. Valid characters are alpha-numeric characters,
+// underscores, hyphens, periods and colons. Namespaces beginning with an
+// underscore are reserved for internal use.
+//
+// Operations that accept a namespace panic if it is invalid, so applications
+// that read namespaces from configuration can use ValidateNamespace() to
+// reject an invalid configuration at startup.
+func ValidateNamespace(ns string) error {
+	return namespaces.Validate(ns)
+}
+
+// ValidateCommand returns an error if cmd is not a valid command name for use
+// with a CommandMux. Command names must not be empty.
+func ValidateCommand(cmd string) error {
+	if cmd == "" {
+		return errors.New("command must not be empty")
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package rinq_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+var _ = Describe("ValidateNamespace", func() {
+	It("accepts a valid namespace", func() {
+		Expect(rinq.ValidateNamespace("acme.billing:v2")).To(Succeed())
+	})
+
+	It("rejects an empty namespace", func() {
+		Expect(rinq.ValidateNamespace("")).Should(HaveOccurred())
+	})
+
+	It("rejects a reserved namespace", func() {
+		Expect(rinq.ValidateNamespace("_sess")).Should(HaveOccurred())
+	})
+
+	It("rejects a namespace that contains invalid characters", func() {
+		Expect(rinq.ValidateNamespace("acme billing")).Should(HaveOccurred())
+	})
+})
+
+var _ = Describe("ValidateCommand", func() {
+	It("accepts a non-empty command", func() {
+		Expect(rinq.ValidateCommand("create-invoice")).To(Succeed())
+	})
+
+	It("rejects an empty command", func() {
+		Expect(rinq.ValidateCommand("")).Should(HaveOccurred())
+	})
+})
@@ -2,6 +2,7 @@ package rinqamqp
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"os"
@@ -10,16 +11,18 @@ import (
 
 	version "github.com/hashicorp/go-version"
 	"github.com/jmalloc/twelf/src/twelf"
+	"github.com/rinq/rinq-go/src/internal/chaos"
 	"github.com/rinq/rinq-go/src/internal/localsession"
-	"github.com/rinq/rinq-go/src/internal/remotesession"
+	"github.com/rinq/rinq-go/src/internal/logging"
 	"github.com/rinq/rinq-go/src/internal/revisions"
+	"github.com/rinq/rinq-go/src/internal/sampling"
+	"github.com/rinq/rinq-go/src/internal/x/bufferpool"
+	"github.com/rinq/rinq-go/src/internal/x/cbor"
 	"github.com/rinq/rinq-go/src/internal/x/env"
 	"github.com/rinq/rinq-go/src/rinq"
 	"github.com/rinq/rinq-go/src/rinq/ident"
 	"github.com/rinq/rinq-go/src/rinq/options"
 	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
-	"github.com/rinq/rinq-go/src/rinqamqp/internal/commandamqp"
-	"github.com/rinq/rinq-go/src/rinqamqp/internal/notifyamqp"
 	"github.com/streadway/amqp"
 )
 
@@ -32,6 +35,19 @@ type Dialer struct {
 
 	// Configuration for the underlying AMQP connection.
 	AMQPConfig amqp.Config
+
+	// NetDialer establishes the network connection to the AMQP broker. It can be
+	// used to connect via a proxy or tunnel, or to customize TCP settings. If
+	// NetDialer is nil, a net.Dialer is used.
+	//
+	// NetDialer is ignored if AMQPConfig.Dial is set.
+	NetDialer NetDialer
+}
+
+// NetDialer is an interface for establishing network connections, such as
+// net.Dialer.
+type NetDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
 }
 
 const (
@@ -42,12 +58,32 @@ const (
 	DefaultPoolSize = 20
 )
 
+// reserveRetryInterval is how long to wait before retrying the reservation of
+// a peer ID that is still reserved by a previous connection.
+const reserveRetryInterval = 100 * time.Millisecond
+
 // Dial connects to an AMQP-based Rinq network using the default dialer.
 func Dial(dsn string, opts ...options.Option) (rinq.Peer, error) {
 	d := Dialer{}
 	return d.Dial(context.Background(), dsn, opts...)
 }
 
+// DialContext connects to an AMQP-based Rinq network using the default dialer.
+// If ctx is canceled before the peer is established, the connection attempt is
+// abandoned.
+func DialContext(ctx context.Context, dsn string, opts ...options.Option) (rinq.Peer, error) {
+	d := Dialer{}
+	return d.Dial(ctx, dsn, opts...)
+}
+
+// DialTLS connects to an AMQP-based Rinq network over TLS using the default
+// dialer, with cfg as the TLS configuration. dsn must use the "amqps" scheme.
+func DialTLS(dsn string, cfg *tls.Config, opts ...options.Option) (rinq.Peer, error) {
+	d := Dialer{}
+	d.AMQPConfig.TLSClientConfig = cfg
+	return d.Dial(context.Background(), dsn, opts...)
+}
+
 // DialEnv connects to an AMQP-based Rinq network using the a dialer and
 // peer options described by environment variables.
 //
@@ -55,7 +91,7 @@ func Dial(dsn string, opts ...options.Option) (rinq.Peer, error) {
 // undefined, the default value is used. Additionally, Rinq peer options are
 // obtained by calling options.FromEnv().
 //
-// - RINQ_AMQP_DSN
+// - RINQ_AMQP_DSN (may contain query parameters, see ParseDSN())
 // - RINQ_AMQP_HEARTBEAT (duration in milliseconds, non-zero)
 // - RINQ_AMQP_CHANNELS (channel pool size, positive integer, non-zero)
 // - RINQ_AMQP_CONNECTION_TIMEOUT (duration in milliseconds, non-zero)
@@ -113,6 +149,10 @@ func DialEnv(opts ...options.Option) (rinq.Peer, error) {
 
 // Dial connects to an AMQP-based Rinq network using the specified context and
 // configuration.
+//
+// dsn may contain query parameters that specify peer options, as described by
+// ParseDSN(). Options specified in the DSN take precedence over those in the o
+// slice, and over the Dialer's own configuration.
 func (d *Dialer) Dial(
 	ctx context.Context,
 	dsn string,
@@ -122,12 +162,35 @@ func (d *Dialer) Dial(
 		dsn = DefaultDSN
 	}
 
-	opts, err := options.NewOptions(o...)
+	parsed, err := ParseDSN(dsn)
 	if err != nil {
 		return nil, err
 	}
+	dsn = parsed.URI
+
+	opts, err := options.NewOptions(append(o, parsed.Options...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Canonical {
+		cbor.SetCanonical(true)
+	}
+
+	bufferpool.SetMaxSize(int(opts.BufferPoolMaxSize))
+
+	opts.Logger = logging.NewPayloadLogger(opts.Logger, opts.PayloadLogging, opts.PayloadRedactor)
 
 	amqpCfg := d.AMQPConfig
+	if parsed.Heartbeat != 0 {
+		amqpCfg.Heartbeat = parsed.Heartbeat
+
+		// round up to the nearest second
+		if r := amqpCfg.Heartbeat % time.Second; r != 0 {
+			amqpCfg.Heartbeat += time.Second - r
+		}
+	}
+
 	if amqpCfg.Properties == nil {
 		product := opts.Product
 		if product == "" {
@@ -140,11 +203,28 @@ func (d *Dialer) Dial(
 		}
 	}
 
-	if amqpCfg.Dial == nil {
-		amqpCfg.Dial = makeDeadlineDialer(ctx)
+	poolSize := d.PoolSize
+	if parsed.PoolSize != 0 {
+		poolSize = parsed.PoolSize
+	} else if poolSize == 0 {
+		poolSize = DefaultPoolSize
+	}
+
+	c := &connector{
+		dialer:   *d,
+		dsn:      dsn,
+		config:   amqpCfg,
+		poolSize: poolSize,
+		opts:     opts,
 	}
 
-	broker, err := amqp.DialConfig(dsn, amqpCfg)
+	c.incarnation = uint64(time.Now().UnixNano())
+
+	if !opts.FaultInjection.IsZero() {
+		c.faults = chaos.NewPolicy(opts.FaultInjection)
+	}
+
+	broker, channels, err := c.dial(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -157,17 +237,12 @@ func (d *Dialer) Dial(
 		}
 	}()
 
-	if err = d.checkCapabilities(broker); err != nil {
-		return nil, err
+	generate := opts.PeerIDGenerator
+	if generate == nil {
+		generate = ident.NewPeerID
 	}
 
-	poolSize := d.PoolSize
-	if poolSize == 0 {
-		poolSize = DefaultPoolSize
-	}
-
-	channels := amqputil.NewChannelPool(broker, poolSize)
-	peerID, err := d.establishIdentity(ctx, channels, opts.Logger)
+	peerID, err := d.establishIdentity(ctx, channels, opts.NamePrefix, generate, opts.Logger)
 	if err != nil {
 		return nil, err
 	}
@@ -179,66 +254,80 @@ func (d *Dialer) Dial(
 		peerID,
 	)
 
-	localStore := localsession.NewStore()
-	revStore := revisions.NewAggregateStore(
+	ref := &transportRef{}
+	c.invoker = newInvokerProxy(ref)
+
+	c.sessions = localsession.NewStore()
+	c.revs = revisions.NewAggregateStore(
 		peerID,
-		localStore,
-		nil, // Remote revision store depends on invoker, created below
+		c.sessions,
+		remoteRevisions{ref},
 	)
 
-	invoker, server, err := commandamqp.New(peerID, opts, localStore, revStore, channels)
+	t, err := c.start(peerID, broker, channels)
 	if err != nil {
 		return nil, err
 	}
 
-	notifier, listener, err := notifyamqp.New(peerID, opts, localStore, revStore, channels)
-	if err != nil {
-		return nil, err
-	}
-
-	remoteStore := remotesession.NewStore(peerID, invoker, opts.PruneInterval, opts.Logger, opts.Tracer)
-	revStore.Remote = remoteStore
-
-	if err := remotesession.Listen(server, peerID, localStore, opts.Logger); err != nil {
-		return nil, err
-	}
+	ref.set(t)
 
 	return newPeer(
 		peerID,
-		broker,
-		localStore,
-		remoteStore,
-		invoker,
-		server,
-		notifier,
-		listener,
+		c,
+		ref,
 		opts.Logger,
 		opts.Tracer,
+		sampling.NewSampler(opts.Sampling),
+		opts.Metrics,
+		opts.Clock,
+		opts.Restartable,
+		opts.AttrQuota,
+		opts.AttrFeed,
+		opts.SessionLimit,
 	), nil
 }
 
-// establishIdentity allocates a new peer ID on the broker.
+// establishIdentity allocates a new peer ID on the broker, using generate to
+// produce candidate IDs. If generate produces the same ID as the previous
+// attempt, it waits for that ID to be released before retrying.
 func (d *Dialer) establishIdentity(
 	ctx context.Context,
 	channels amqputil.ChannelPool,
+	prefix string,
+	generate func() ident.PeerID,
 	logger twelf.Logger,
 ) (id ident.PeerID, err error) {
 	var channel *amqp.Channel
 
 	for {
+		prev := id
+		id = generate()
+
+		if err = id.Validate(); err != nil {
+			return
+		}
+
+		if id == prev {
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+				return
+			case <-time.After(reserveRetryInterval):
+			}
+		}
+
 		channel, err = channels.Get()
 		if err != nil {
 			return
 		}
 
-		id = ident.NewPeerID()
 		_, err = channel.QueueDeclare(
-			id.ShortString(), // this queue is used purely to reserve the peer ID
-			false,            // durable
-			false,            // autoDelete
-			true,             // exclusive,
-			false,            // noWait
-			nil,              // args
+			prefix+id.ShortString(), // this queue is used purely to reserve the peer ID
+			false,                   // durable
+			false,                   // autoDelete
+			true,                    // exclusive,
+			false,                   // noWait
+			nil,                     // args
 		)
 
 		if amqpErr, ok := err.(*amqp.Error); !ok || amqpErr.Code != amqp.ResourceLocked {
@@ -262,6 +351,46 @@ func (d *Dialer) establishIdentity(
 	}
 }
 
+// reserveIdentity reserves an existing peer ID on the broker, such as when a
+// peer is restarted. If the ID is still reserved by a previous connection, it
+// retries until the reservation is released or ctx is done.
+func (d *Dialer) reserveIdentity(
+	ctx context.Context,
+	channels amqputil.ChannelPool,
+	prefix string,
+	id ident.PeerID,
+) error {
+	for {
+		channel, err := channels.Get()
+		if err != nil {
+			return err
+		}
+
+		_, err = channel.QueueDeclare(
+			prefix+id.ShortString(), // this queue is used purely to reserve the peer ID
+			false,                   // durable
+			false,                   // autoDelete
+			true,                    // exclusive,
+			false,                   // noWait
+			nil,                     // args
+		)
+
+		if amqpErr, ok := err.(*amqp.Error); !ok || amqpErr.Code != amqp.ResourceLocked {
+			if err == nil {
+				channels.Put(channel)
+			}
+
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(reserveRetryInterval):
+		}
+	}
+}
+
 func (d *Dialer) checkCapabilities(broker *amqp.Connection) error {
 	product, _ := broker.Properties["product"].(string)
 
@@ -297,19 +426,22 @@ type amqpDialer func(network, addr string) (net.Conn, error)
 
 // makeDeadlineDialer returns a dial function suitable for use in amqp.Config.Dial
 // which honors the deadline in ctx.
-func makeDeadlineDialer(ctx context.Context) amqpDialer {
+func makeDeadlineDialer(ctx context.Context, nd NetDialer) amqpDialer {
 	dl, ok := ctx.Deadline()
-	if !ok {
+	if !ok && nd == nil {
 		// if there is no deadline, return nil, thereby using the default
 		// dialer provided by the amqp package.
 		return nil
 	}
 
+	if nd == nil {
+		nd = &net.Dialer{}
+	}
+
 	return func(network, addr string) (conn net.Conn, err error) {
-		d := net.Dialer{}
-		conn, err = d.DialContext(ctx, network, addr)
+		conn, err = nd.DialContext(ctx, network, addr)
 
-		if err == nil {
+		if err == nil && ok {
 			err = conn.SetDeadline(dl)
 		}
 
@@ -0,0 +1,64 @@
+package rinqamqp_test
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/rinq/rinq-go/src/rinqamqp"
+)
+
+var _ = Describe("Dialer", func() {
+	var netDialer *stubNetDialer
+
+	BeforeEach(func() {
+		netDialer = &stubNetDialer{}
+	})
+
+	Describe("Dial", func() {
+		It("connects using the net dialer", func() {
+			d := &Dialer{NetDialer: netDialer}
+
+			_, err := d.Dial(context.Background(), "amqp://host:1234")
+
+			Expect(err).To(MatchError("<error>"))
+			Expect(netDialer.calls).To(Equal([]string{"tcp host:1234"}))
+		})
+
+		It("does not connect if the context is already done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			d := &Dialer{NetDialer: netDialer}
+
+			_, err := d.Dial(ctx, "amqp://host:1234")
+
+			Expect(err).To(Equal(context.Canceled))
+			Expect(netDialer.calls).To(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("DialContext", func() {
+	It("returns the context error if the context is already done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := DialContext(ctx, "amqp://host:1234")
+
+		Expect(err).To(Equal(context.Canceled))
+	})
+})
+
+// stubNetDialer is a NetDialer that records each connection attempt and fails
+// to connect.
+type stubNetDialer struct {
+	calls []string
+}
+
+func (d *stubNetDialer) DialContext(_ context.Context, network, addr string) (net.Conn, error) {
+	d.calls = append(d.calls, network+" "+addr)
+	return nil, errors.New("<error>")
+}
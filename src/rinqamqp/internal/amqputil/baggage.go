@@ -0,0 +1,66 @@
+package amqputil
+
+import (
+	"context"
+
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/streadway/amqp"
+)
+
+// baggageHeader specifies the baggage items carried by a message.
+const baggageHeader = "bg"
+
+// PackBaggage sets the baggage header on msg to the baggage items in ctx, if
+// there are any.
+func PackBaggage(ctx context.Context, msg *amqp.Publishing) {
+	packStringTable(msg, baggageHeader, rinq.AllBaggage(ctx))
+}
+
+// UnpackBaggage creates a new context based on parent which carries the
+// baggage items from msg. Items that are not strings are ignored.
+func UnpackBaggage(parent context.Context, msg *amqp.Delivery) context.Context {
+	ctx := parent
+	for k, v := range unpackStringTable(msg, baggageHeader) {
+		ctx = rinq.WithBaggage(ctx, k, v)
+	}
+
+	return ctx
+}
+
+// packStringTable sets the header h on msg to a table containing the items in
+// m, if there are any.
+func packStringTable(msg *amqp.Publishing, h string, m map[string]string) {
+	if len(m) == 0 {
+		return
+	}
+
+	t := make(amqp.Table, len(m))
+	for k, v := range m {
+		t[k] = v
+	}
+
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+
+	msg.Headers[h] = t
+}
+
+// unpackStringTable returns the items in the table in the header h of msg.
+// Items with empty keys or values, or values that are not strings, are
+// ignored. It returns nil if there are no items.
+func unpackStringTable(msg *amqp.Delivery, h string) map[string]string {
+	t, _ := msg.Headers[h].(amqp.Table)
+
+	var m map[string]string
+	for k, v := range t {
+		if s, ok := v.(string); ok && k != "" && s != "" {
+			if m == nil {
+				m = map[string]string{}
+			}
+			m[k] = s
+		}
+	}
+
+	return m
+}
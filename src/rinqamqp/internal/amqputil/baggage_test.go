@@ -0,0 +1,45 @@
+package amqputil_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
+	"github.com/streadway/amqp"
+)
+
+var _ = Describe("Baggage", func() {
+	It("restores the baggage items packed into the message", func() {
+		ctx := rinq.WithBaggage(context.Background(), "a", "1")
+		ctx = rinq.WithBaggage(ctx, "b", "2")
+
+		pub := amqp.Publishing{}
+		amqputil.PackBaggage(ctx, &pub)
+		del := amqp.Delivery{Headers: pub.Headers}
+
+		ctx = amqputil.UnpackBaggage(context.Background(), &del)
+
+		Expect(rinq.AllBaggage(ctx)).To(Equal(map[string]string{"a": "1", "b": "2"}))
+	})
+
+	It("does not set the header if there is no baggage", func() {
+		pub := amqp.Publishing{}
+		amqputil.PackBaggage(context.Background(), &pub)
+
+		Expect(pub.Headers).To(BeNil())
+	})
+
+	It("ignores items that are not strings", func() {
+		del := amqp.Delivery{
+			Headers: amqp.Table{
+				"bg": amqp.Table{"a": "1", "b": int32(2)},
+			},
+		}
+
+		ctx := amqputil.UnpackBaggage(context.Background(), &del)
+
+		Expect(rinq.AllBaggage(ctx)).To(Equal(map[string]string{"a": "1"}))
+	})
+})
@@ -2,6 +2,7 @@ package amqputil
 
 import (
 	"errors"
+	"sync"
 
 	"github.com/streadway/amqp"
 )
@@ -9,8 +10,11 @@ import (
 const maxPreFetch = ^uint(0) >> 1 // largest int value as uint
 
 // ChannelPool provides a pool of reusable AMQP channels.
+//
+// Channels that have been closed, such as by a channel-level exception from the
+// broker, are discarded rather than being returned from the pool.
 type ChannelPool interface {
-	// Get fetches a channel from the pool, or creates one as necessary.
+	// Get fetches an open channel from the pool, or creates one as necessary.
 	Get() (*amqp.Channel, error)
 
 	// GetQOS fetches a channel from the pool and sets the pre-fetch count
@@ -26,23 +30,82 @@ type ChannelPool interface {
 func NewChannelPool(broker *amqp.Connection, size uint) ChannelPool {
 	return &channelPool{
 		broker:   broker,
+		dial:     broker.Channel,
 		channels: make(chan *amqp.Channel, size),
+		open:     map[*amqp.Channel]struct{}{},
 	}
 }
 
 type channelPool struct {
 	broker   *amqp.Connection
+	dial     func() (*amqp.Channel, error)
 	channels chan *amqp.Channel
+
+	mutex sync.Mutex
+	open  map[*amqp.Channel]struct{} // channels created by the pool that are still open
 }
 
-func (p *channelPool) Get() (channel *amqp.Channel, err error) {
-	select {
-	case channel = <-p.channels: // fetch from the pool
-	default: // none available, make a new channel
-		channel, err = p.broker.Channel()
+func (p *channelPool) Get() (*amqp.Channel, error) {
+	for {
+		select {
+		case channel := <-p.channels: // fetch from the pool
+			if !p.isClosed(channel) {
+				return channel, nil
+			}
+		default: // none available, make a new channel
+			return p.create()
+		}
+	}
+}
+
+// create opens a new channel and begins watching for its closure.
+func (p *channelPool) create() (*amqp.Channel, error) {
+	channel, err := p.dial()
+	if err != nil {
+		return nil, err
 	}
 
-	return
+	p.watch(channel, channel.NotifyClose(make(chan *amqp.Error, 1)))
+
+	return channel, nil
+}
+
+// watch records channel as open until notify is closed or receives an error.
+//
+// The channel is forgotten as soon as it is closed, rather than when it is
+// next returned to the pool, as callers that consume from a channel keep it
+// and close it themselves.
+func (p *channelPool) watch(channel *amqp.Channel, notify <-chan *amqp.Error) {
+	p.mutex.Lock()
+	p.open[channel] = struct{}{}
+	p.mutex.Unlock()
+
+	go func() {
+		<-notify
+		p.forget(channel)
+	}()
+}
+
+// forget stops watching channel.
+func (p *channelPool) forget(channel *amqp.Channel) {
+	p.mutex.Lock()
+	delete(p.open, channel)
+	p.mutex.Unlock()
+}
+
+// isClosed returns true if channel has been closed.
+func (p *channelPool) isClosed(channel *amqp.Channel) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	_, ok := p.open[channel]
+	return !ok
+}
+
+// discard closes channel and stops watching it.
+func (p *channelPool) discard(channel *amqp.Channel) {
+	_ = channel.Close()
+	p.forget(channel)
 }
 
 // GetQOS fetches a channel from the pool and sets the pre-fetch count
@@ -60,11 +123,13 @@ func (p *channelPool) GetQOS(preFetch uint) (*amqp.Channel, error) {
 	global, _ := caps["per_consumer_qos"].(bool)
 
 	if preFetch > maxPreFetch {
+		p.Put(channel)
 		return nil, errors.New("pre-fetch is too large")
 	}
 
 	err = channel.Qos(int(preFetch), 0, global)
 	if err != nil {
+		p.discard(channel)
 		return nil, err
 	}
 
@@ -78,13 +143,48 @@ func (p *channelPool) Put(channel *amqp.Channel) {
 
 	// set the QoS state back to unlimited, both to "reset" the channel, and to
 	// verify that it is still usable.
-	if err := channel.Qos(0, 0, true); err != nil {
+	if p.isClosed(channel) || channel.Qos(0, 0, true) != nil {
+		p.discard(channel)
 		return
 	}
 
 	select {
 	case p.channels <- channel: // return to the pool
 	default: // pool is full, close channel
-		_ = channel.Close()
+		p.discard(channel)
 	}
 }
+
+// Publish publishes msg to exchange using a channel from p.
+//
+// If the channel turns out to have been closed, for example because a previous
+// publish on the same channel caused a channel-level exception, it is discarded
+// and the message is published once more on a new channel.
+func Publish(p ChannelPool, exchange, key string, msg amqp.Publishing) error {
+	return retryClosed(func() error {
+		channel, err := p.Get()
+		if err != nil {
+			return err
+		}
+		defer p.Put(channel)
+
+		return channel.Publish(
+			exchange,
+			key,
+			false, // mandatory
+			false, // immediate
+			msg,
+		)
+	})
+}
+
+// retryClosed calls fn, and calls it once more if it fails because the
+// channel it used was closed.
+func retryClosed(fn func() error) error {
+	err := fn()
+	if err == amqp.ErrClosed {
+		err = fn()
+	}
+
+	return err
+}
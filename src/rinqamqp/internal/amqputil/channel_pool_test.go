@@ -0,0 +1,130 @@
+package amqputil
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/streadway/amqp"
+)
+
+var _ = Describe("channelPool", func() {
+	var pool *channelPool
+
+	BeforeEach(func() {
+		pool = &channelPool{
+			dial: func() (*amqp.Channel, error) {
+				return &amqp.Channel{}, nil
+			},
+			channels: make(chan *amqp.Channel, 2),
+			open:     map[*amqp.Channel]struct{}{},
+		}
+	})
+
+	// watched returns the number of channels the pool is watching.
+	watched := func() int {
+		pool.mutex.Lock()
+		defer pool.mutex.Unlock()
+
+		return len(pool.open)
+	}
+
+	Describe("Get", func() {
+		It("returns an open channel from the pool", func() {
+			channel := &amqp.Channel{}
+			pool.watch(channel, make(chan *amqp.Error))
+			pool.channels <- channel
+
+			c, err := pool.Get()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c).To(BeIdenticalTo(channel))
+		})
+
+		It("discards closed channels from the pool", func() {
+			channel := &amqp.Channel{}
+			notify := make(chan *amqp.Error)
+			pool.watch(channel, notify)
+			pool.channels <- channel
+
+			close(notify)
+			Eventually(watched).Should(Equal(0))
+
+			c, err := pool.Get()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c).NotTo(BeIdenticalTo(channel))
+			Expect(pool.channels).To(BeEmpty())
+		})
+
+		It("watches the channels that it creates", func() {
+			c, err := pool.Get()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pool.isClosed(c)).To(BeFalse())
+			Expect(watched()).To(Equal(1))
+		})
+
+		It("returns an error if a channel can not be created", func() {
+			pool.dial = func() (*amqp.Channel, error) {
+				return nil, errors.New("<error>")
+			}
+
+			_, err := pool.Get()
+
+			Expect(err).To(MatchError("<error>"))
+			Expect(watched()).To(Equal(0))
+		})
+	})
+
+	Describe("watch", func() {
+		It("forgets channels that are closed without being returned to the pool", func() {
+			channel := &amqp.Channel{}
+			notify := make(chan *amqp.Error, 1)
+			pool.watch(channel, notify)
+
+			notify <- &amqp.Error{Code: amqp.NotFound}
+
+			Eventually(watched).Should(Equal(0))
+			Expect(pool.isClosed(channel)).To(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("retryClosed", func() {
+	It("retries once if the channel was closed", func() {
+		calls := 0
+		err := retryClosed(func() error {
+			calls++
+			if calls == 1 {
+				return amqp.ErrClosed
+			}
+			return nil
+		})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls).To(Equal(2))
+	})
+
+	It("does not retry more than once", func() {
+		calls := 0
+		err := retryClosed(func() error {
+			calls++
+			return amqp.ErrClosed
+		})
+
+		Expect(err).To(Equal(amqp.ErrClosed))
+		Expect(calls).To(Equal(2))
+	})
+
+	It("does not retry other errors", func() {
+		calls := 0
+		err := retryClosed(func() error {
+			calls++
+			return errors.New("<error>")
+		})
+
+		Expect(err).To(MatchError("<error>"))
+		Expect(calls).To(Equal(1))
+	})
+})
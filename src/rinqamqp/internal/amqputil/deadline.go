@@ -44,17 +44,21 @@ func PackDeadline(ctx context.Context, msg *amqp.Publishing) (bool, error) {
 }
 
 // UnpackDeadline creates a new context based on parent which has a deadline
-// computed from the expiration information in msg.
+// computed from the expiration information in msg, brought forward by margin.
 //
 // The return values are the same as context.WithDeadline()
-func UnpackDeadline(parent context.Context, msg *amqp.Delivery) (context.Context, func()) {
+func UnpackDeadline(
+	parent context.Context,
+	msg *amqp.Delivery,
+	margin time.Duration,
+) (context.Context, func()) {
 	deadlineMillis, ok := msg.Headers[deadlineHeader].(int64)
 	if !ok {
 		return context.WithCancel(parent)
 	}
 
 	deadlineNanos := deadlineMillis * int64(time.Millisecond)
-	deadline := time.Unix(0, deadlineNanos)
+	deadline := time.Unix(0, deadlineNanos).Add(-margin)
 
 	return context.WithDeadline(parent, deadline)
 }
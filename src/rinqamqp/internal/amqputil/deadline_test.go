@@ -65,7 +65,7 @@ var _ = Describe("Deadline", func() {
 				Expiration: "0",
 			}
 
-			ctx, cancel := amqputil.UnpackDeadline(context.Background(), &msg)
+			ctx, cancel := amqputil.UnpackDeadline(context.Background(), &msg, 0)
 			defer cancel()
 
 			deadline, ok := ctx.Deadline()
@@ -74,12 +74,29 @@ var _ = Describe("Deadline", func() {
 			Expect(deadline).To(BeTemporally("~", expected, time.Millisecond)) // within one milli
 		})
 
+		It("brings the deadline forward by the margin", func() {
+			expected := time.Now().Add(10 * time.Second)
+
+			msg := amqp.Delivery{
+				Headers:    amqp.Table{"dl": expected.UnixNano() / int64(time.Millisecond)},
+				Expiration: "10000",
+			}
+
+			ctx, cancel := amqputil.UnpackDeadline(context.Background(), &msg, 2*time.Second)
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+
+			Expect(ok).To(BeTrue())
+			Expect(deadline).To(BeTemporally("~", expected.Add(-2*time.Second), time.Millisecond))
+		})
+
 		It("does not add a deadline if there is no deadline in the message", func() {
 			msg := amqp.Delivery{
 				Expiration: "1000",
 			}
 
-			ctx, cancel := amqputil.UnpackDeadline(context.Background(), &msg)
+			ctx, cancel := amqputil.UnpackDeadline(context.Background(), &msg, 0)
 			defer cancel()
 
 			_, ok := ctx.Deadline()
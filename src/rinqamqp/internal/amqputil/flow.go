@@ -0,0 +1,109 @@
+package amqputil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// Flow tracks whether the broker has blocked the connection, such as when it
+// has raised a memory or disk alarm.
+//
+// While the connection is blocked the broker stops reading from it, so any
+// publish blocks until the alarm clears. If pausing is enabled, Wait() allows
+// publishers to wait for the connection to be unblocked in a way that honours
+// their context.
+type Flow struct {
+	pause bool
+
+	mutex    sync.Mutex
+	reason   string
+	resumed  chan struct{} // closed when the connection is unblocked, nil if not blocked
+	observer func(blocked bool, reason string)
+}
+
+// NewFlow returns a Flow that tracks the connection.blocked and
+// connection.unblocked notifications sent by the broker on conn. If pause is
+// true, Wait() blocks while the connection is blocked.
+func NewFlow(conn *amqp.Connection, pause bool) *Flow {
+	f := &Flow{pause: pause}
+
+	// The notifications are sent synchronously by the connection's reader, so
+	// they must always be drained.
+	ch := conn.NotifyBlocked(make(chan amqp.Blocking, 1))
+
+	go func() {
+		for b := range ch {
+			f.set(b.Active, b.Reason)
+		}
+	}()
+
+	return f
+}
+
+// Observe registers fn to be called each time the connection becomes blocked
+// or unblocked. It replaces any previously registered function.
+func (f *Flow) Observe(fn func(blocked bool, reason string)) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.observer = fn
+}
+
+// Blocked returns true if the connection is currently blocked, along with the
+// reason given by the broker.
+func (f *Flow) Blocked() (blocked bool, reason string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.resumed != nil, f.reason
+}
+
+// Wait blocks until the connection is unblocked or ctx is done. It returns
+// immediately if pausing is disabled or the connection is not blocked.
+func (f *Flow) Wait(ctx context.Context) error {
+	if !f.pause {
+		return nil
+	}
+
+	f.mutex.Lock()
+	resumed := f.resumed
+	f.mutex.Unlock()
+
+	if resumed == nil {
+		return nil
+	}
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f *Flow) set(blocked bool, reason string) {
+	f.mutex.Lock()
+
+	if blocked == (f.resumed != nil) {
+		f.mutex.Unlock()
+		return
+	}
+
+	if blocked {
+		f.resumed = make(chan struct{})
+		f.reason = reason
+	} else {
+		close(f.resumed)
+		f.resumed = nil
+		f.reason = ""
+	}
+
+	fn := f.observer
+	f.mutex.Unlock()
+
+	if fn != nil {
+		fn(blocked, reason)
+	}
+}
@@ -0,0 +1,23 @@
+package amqputil
+
+import (
+	"context"
+
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/streadway/amqp"
+)
+
+// headersHeader specifies the application-defined headers of a message.
+const headersHeader = "hd"
+
+// PackHeaders sets the headers header on msg to the application-defined
+// headers in ctx, if there are any.
+func PackHeaders(ctx context.Context, msg *amqp.Publishing) {
+	packStringTable(msg, headersHeader, rinq.Headers(ctx))
+}
+
+// UnpackHeaders returns the application-defined headers of msg, or nil if it
+// has none. Headers that are not strings are ignored.
+func UnpackHeaders(msg *amqp.Delivery) map[string]string {
+	return unpackStringTable(msg, headersHeader)
+}
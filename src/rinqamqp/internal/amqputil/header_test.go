@@ -0,0 +1,33 @@
+package amqputil_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
+	"github.com/streadway/amqp"
+)
+
+var _ = Describe("Headers", func() {
+	It("returns the headers packed into the message", func() {
+		ctx := rinq.WithHeader(context.Background(), "a", "1")
+
+		pub := amqp.Publishing{}
+		amqputil.PackHeaders(ctx, &pub)
+		del := amqp.Delivery{Headers: pub.Headers}
+
+		Expect(amqputil.UnpackHeaders(&del)).To(Equal(map[string]string{"a": "1"}))
+	})
+
+	It("does not include the baggage", func() {
+		ctx := rinq.WithBaggage(context.Background(), "a", "1")
+
+		pub := amqp.Publishing{}
+		amqputil.PackHeaders(ctx, &pub)
+		del := amqp.Delivery{Headers: pub.Headers}
+
+		Expect(amqputil.UnpackHeaders(&del)).To(BeNil())
+	})
+})
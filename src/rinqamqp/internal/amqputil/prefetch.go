@@ -0,0 +1,90 @@
+package amqputil
+
+import (
+	"sync"
+	"time"
+)
+
+// PrefetchController computes a pre-fetch count for a consuming channel using
+// an additive-increase/multiplicative-decrease (AIMD) algorithm.
+//
+// The outcome of each message handled is passed to Observe(). Each call to
+// Adjust() inspects the observations made since the previous call. If the
+// mean handling time exceeds the latency target, or more than a tenth of the
+// messages failed, the pre-fetch count is halved. Otherwise it is increased by
+// one. The count never falls below one nor exceeds the maximum given to
+// NewPrefetchController().
+type PrefetchController struct {
+	target time.Duration
+	max    uint
+
+	mutex  sync.Mutex
+	limit  uint
+	count  uint64
+	failed uint64
+	total  time.Duration
+}
+
+// NewPrefetchController returns a controller that aims to keep the mean
+// handling time below target. The pre-fetch count starts at max.
+func NewPrefetchController(target time.Duration, max uint) *PrefetchController {
+	if max == 0 {
+		max = 1
+	}
+
+	return &PrefetchController{
+		target: target,
+		max:    max,
+		limit:  max,
+	}
+}
+
+// Observe records that a message took d to handle. failed is true if handling
+// the message resulted in an error that indicates the consumer is overloaded.
+func (c *PrefetchController) Observe(d time.Duration, failed bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.count++
+	c.total += d
+
+	if failed {
+		c.failed++
+	}
+}
+
+// Limit returns the current pre-fetch count.
+func (c *PrefetchController) Limit() uint {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.limit
+}
+
+// Adjust computes a new pre-fetch count from the observations made since the
+// previous call. changed is false if the count remains the same, including
+// when there have been no observations.
+func (c *PrefetchController) Adjust() (limit uint, changed bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.count == 0 {
+		return c.limit, false
+	}
+
+	prev := c.limit
+	mean := c.total / time.Duration(c.count)
+
+	if mean > c.target || c.failed*10 > c.count {
+		c.limit /= 2
+		if c.limit == 0 {
+			c.limit = 1
+		}
+	} else if c.limit < c.max {
+		c.limit++
+	}
+
+	c.count, c.failed, c.total = 0, 0, 0
+
+	return c.limit, c.limit != prev
+}
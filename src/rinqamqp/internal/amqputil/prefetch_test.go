@@ -0,0 +1,78 @@
+package amqputil_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
+)
+
+var _ = Describe("PrefetchController", func() {
+	var controller *amqputil.PrefetchController
+
+	BeforeEach(func() {
+		controller = amqputil.NewPrefetchController(100*time.Millisecond, 8)
+	})
+
+	It("starts at the maximum", func() {
+		Expect(controller.Limit()).To(BeNumerically("==", 8))
+	})
+
+	It("does not change when there are no observations", func() {
+		limit, changed := controller.Adjust()
+
+		Expect(limit).To(BeNumerically("==", 8))
+		Expect(changed).To(BeFalse())
+	})
+
+	It("halves the limit when the mean latency exceeds the target", func() {
+		controller.Observe(50*time.Millisecond, false)
+		controller.Observe(200*time.Millisecond, false)
+
+		limit, changed := controller.Adjust()
+
+		Expect(limit).To(BeNumerically("==", 4))
+		Expect(changed).To(BeTrue())
+	})
+
+	It("halves the limit when more than a tenth of observations failed", func() {
+		controller.Observe(time.Millisecond, true)
+		controller.Observe(time.Millisecond, false)
+
+		limit, _ := controller.Adjust()
+
+		Expect(limit).To(BeNumerically("==", 4))
+	})
+
+	It("does not reduce the limit below one", func() {
+		for i := 0; i < 5; i++ {
+			controller.Observe(time.Second, false)
+			controller.Adjust()
+		}
+
+		Expect(controller.Limit()).To(BeNumerically("==", 1))
+	})
+
+	It("increases the limit by one when the consumer is healthy, up to the maximum", func() {
+		controller.Observe(time.Second, false)
+		controller.Adjust()
+
+		for i := 0; i < 10; i++ {
+			controller.Observe(time.Millisecond, false)
+			controller.Adjust()
+		}
+
+		Expect(controller.Limit()).To(BeNumerically("==", 8))
+	})
+
+	It("only considers observations made since the previous adjustment", func() {
+		controller.Observe(time.Second, false)
+		controller.Adjust()
+
+		controller.Observe(time.Millisecond, false)
+		limit, _ := controller.Adjust()
+
+		Expect(limit).To(BeNumerically("==", 5))
+	})
+})
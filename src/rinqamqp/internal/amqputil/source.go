@@ -0,0 +1,71 @@
+package amqputil
+
+import (
+	"context"
+
+	"github.com/rinq/rinq-go/src/internal/revisions"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/streadway/amqp"
+)
+
+// Drain returns a batch containing msg, followed by any further messages that
+// are immediately available from deliveries, up to a total of max messages. It
+// never blocks.
+func Drain(msg amqp.Delivery, deliveries <-chan amqp.Delivery, max int) []amqp.Delivery {
+	batch := []amqp.Delivery{msg}
+
+	for len(batch) < max {
+		select {
+		case m, ok := <-deliveries:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, m)
+		default:
+			return batch
+		}
+	}
+
+	return batch
+}
+
+// GetSources returns the revisions of the sessions that sent the messages in
+// batch, keyed by message ID.
+//
+// The revisions are looked up with a single call to revs.GetRevisions(), so
+// that the state of remote sessions is fetched with one request to each of the
+// peers that own them, rather than one request per message. Messages with
+// invalid IDs are omitted. If the lookup fails, nil is returned and the caller
+// falls back to looking up each revision as the message is dispatched.
+func GetSources(
+	ctx context.Context,
+	revs revisions.Store,
+	batch []amqp.Delivery,
+) map[string]rinq.Revision {
+	ids := make([]string, 0, len(batch))
+	refs := make([]ident.Ref, 0, len(batch))
+
+	for _, msg := range batch {
+		if msgID, err := ident.ParseMessageID(msg.MessageId); err == nil {
+			ids = append(ids, msg.MessageId)
+			refs = append(refs, msgID.Ref)
+		}
+	}
+
+	if len(refs) == 0 {
+		return nil
+	}
+
+	sources, err := revs.GetRevisions(ctx, refs)
+	if err != nil {
+		return nil
+	}
+
+	result := make(map[string]rinq.Revision, len(ids))
+	for i, id := range ids {
+		result[id] = sources[i]
+	}
+
+	return result
+}
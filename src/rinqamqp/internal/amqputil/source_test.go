@@ -0,0 +1,114 @@
+package amqputil_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/internal/revisions"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
+	"github.com/streadway/amqp"
+)
+
+var _ = Describe("Source", func() {
+	Describe("Drain", func() {
+		It("returns the messages that are immediately available", func() {
+			deliveries := make(chan amqp.Delivery, 3)
+			deliveries <- amqp.Delivery{MessageId: "<b>"}
+			deliveries <- amqp.Delivery{MessageId: "<c>"}
+
+			batch := amqputil.Drain(amqp.Delivery{MessageId: "<a>"}, deliveries, 10)
+
+			Expect(batch).To(Equal([]amqp.Delivery{
+				{MessageId: "<a>"},
+				{MessageId: "<b>"},
+				{MessageId: "<c>"},
+			}))
+		})
+
+		It("does not return more than the maximum number of messages", func() {
+			deliveries := make(chan amqp.Delivery, 3)
+			deliveries <- amqp.Delivery{MessageId: "<b>"}
+			deliveries <- amqp.Delivery{MessageId: "<c>"}
+
+			batch := amqputil.Drain(amqp.Delivery{MessageId: "<a>"}, deliveries, 2)
+
+			Expect(batch).To(HaveLen(2))
+			Expect(deliveries).To(Receive(Equal(amqp.Delivery{MessageId: "<c>"})))
+		})
+
+		It("stops when the channel is closed", func() {
+			deliveries := make(chan amqp.Delivery)
+			close(deliveries)
+
+			batch := amqputil.Drain(amqp.Delivery{MessageId: "<a>"}, deliveries, 10)
+
+			Expect(batch).To(HaveLen(1))
+		})
+	})
+
+	Describe("GetSources", func() {
+		var (
+			peerID ident.PeerID
+			store  *batchStore
+		)
+
+		BeforeEach(func() {
+			peerID = ident.NewPeerID()
+			store = &batchStore{}
+		})
+
+		It("looks up the source of every message with a single call", func() {
+			a := peerID.Session(1).At(1).Message(1)
+			b := peerID.Session(2).At(1).Message(1)
+
+			sources := amqputil.GetSources(context.Background(), store, []amqp.Delivery{
+				{MessageId: a.String()},
+				{MessageId: "<invalid>"},
+				{MessageId: b.String()},
+			})
+
+			Expect(store.calls).To(Equal(1))
+			Expect(sources).To(HaveLen(2))
+			Expect(sources[a.String()].SessionID()).To(Equal(a.Ref.ID))
+			Expect(sources[b.String()].SessionID()).To(Equal(b.Ref.ID))
+		})
+
+		It("returns nil if the lookup fails", func() {
+			store.err = errors.New("<error>")
+
+			sources := amqputil.GetSources(context.Background(), store, []amqp.Delivery{
+				{MessageId: peerID.Session(1).At(1).Message(1).String()},
+			})
+
+			Expect(sources).To(BeNil())
+		})
+	})
+})
+
+// batchStore is a revisions.Store that returns closed revisions from
+// GetRevisions().
+type batchStore struct {
+	revisions.Store
+
+	calls int
+	err   error
+}
+
+func (s *batchStore) GetRevisions(_ context.Context, refs []ident.Ref) ([]rinq.Revision, error) {
+	s.calls++
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	revs := make([]rinq.Revision, len(refs))
+	for i, ref := range refs {
+		revs[i] = revisions.Closed(ref.ID)
+	}
+
+	return revs, nil
+}
@@ -0,0 +1,46 @@
+package amqputil
+
+import (
+	"fmt"
+
+	"github.com/streadway/amqp"
+)
+
+// tenantHeader specifies the tenant that sent a message.
+const tenantHeader = "tn"
+
+// TenantKey returns a routing key for the given tenant. Routing keys for the
+// default tenant (the empty string) are returned unchanged. Neither tenants nor
+// namespaces may contain a slash, so keys can never collide across tenants.
+func TenantKey(tenant, key string) string {
+	if tenant == "" {
+		return key
+	}
+
+	return tenant + "/" + key
+}
+
+// PackTenant sets the tenant header on msg, unless tenant is the default
+// tenant.
+func PackTenant(msg *amqp.Publishing, tenant string) {
+	if tenant == "" {
+		return
+	}
+
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+
+	msg.Headers[tenantHeader] = tenant
+}
+
+// CheckTenant returns an error if msg was not sent by the given tenant.
+func CheckTenant(msg *amqp.Delivery, tenant string) error {
+	t, _ := msg.Headers[tenantHeader].(string)
+
+	if t != tenant {
+		return fmt.Errorf("message from tenant '%s' can not be accepted by tenant '%s'", t, tenant)
+	}
+
+	return nil
+}
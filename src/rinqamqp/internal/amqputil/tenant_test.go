@@ -0,0 +1,52 @@
+package amqputil_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
+	"github.com/streadway/amqp"
+)
+
+var _ = Describe("Tenant", func() {
+	Describe("TenantKey", func() {
+		It("prefixes the key with the tenant", func() {
+			Expect(amqputil.TenantKey("<tenant>", "<key>")).To(Equal("<tenant>/<key>"))
+		})
+
+		It("returns the key unchanged for the default tenant", func() {
+			Expect(amqputil.TenantKey("", "<key>")).To(Equal("<key>"))
+		})
+	})
+
+	Describe("CheckTenant", func() {
+		It("accepts messages from the same tenant", func() {
+			pub := amqp.Publishing{}
+			amqputil.PackTenant(&pub, "<tenant>")
+			del := amqp.Delivery{Headers: pub.Headers}
+
+			Expect(amqputil.CheckTenant(&del, "<tenant>")).To(Succeed())
+		})
+
+		It("accepts messages from the default tenant when using the default tenant", func() {
+			pub := amqp.Publishing{}
+			amqputil.PackTenant(&pub, "")
+			del := amqp.Delivery{Headers: pub.Headers}
+
+			Expect(amqputil.CheckTenant(&del, "")).To(Succeed())
+		})
+
+		It("rejects messages from a different tenant", func() {
+			pub := amqp.Publishing{}
+			amqputil.PackTenant(&pub, "<tenant>")
+			del := amqp.Delivery{Headers: pub.Headers}
+
+			Expect(amqputil.CheckTenant(&del, "<other>")).ToNot(Succeed())
+		})
+
+		It("rejects messages from the default tenant when using a named tenant", func() {
+			del := amqp.Delivery{}
+
+			Expect(amqputil.CheckTenant(&del, "<tenant>")).ToNot(Succeed())
+		})
+	})
+})
@@ -0,0 +1,316 @@
+package commandamqp
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/clock"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+)
+
+// balancer selects the peer that services a load-balanced call based on the
+// presence announcements received from other peers.
+type balancer struct {
+	strategy options.BalanceStrategy
+	sticky   bool
+	clock    clock.Clock
+
+	mutex sync.Mutex
+	peers map[ident.PeerID]*peerState
+	pins  map[ident.SessionID]*pin // sticky sessions, nil if stickiness disabled
+}
+
+// peerState is the information a balancer holds about a single peer.
+type peerState struct {
+	Namespaces map[string]struct{}
+	Groups     map[string]struct{}
+	Capacity   uint
+	Caps       rinq.PeerCapabilities
+	Pending    uint // number of calls awaiting a response from this peer
+	ExpiresAt  time.Time
+}
+
+// pin associates a session with the peer that services all of its calls.
+type pin struct {
+	PeerID   ident.PeerID
+	LastUsed time.Time
+}
+
+// newBalancer returns a balancer that uses the given strategy. If sticky is
+// true, each session's calls are sent to the same peer regardless of strategy.
+//
+// If the strategy is options.BrokerBalancing and sticky is false, the balancer
+// only selects a peer for calls that are restricted to a group. clk is used to
+// expire presence announcements.
+func newBalancer(strategy options.BalanceStrategy, sticky bool, clk clock.Clock) *balancer {
+	b := &balancer{
+		strategy: strategy,
+		sticky:   sticky,
+		clock:    clk,
+		peers:    map[ident.PeerID]*peerState{},
+	}
+
+	if sticky {
+		b.pins = map[ident.SessionID]*pin{}
+	}
+
+	return b
+}
+
+// Update records a presence announcement.
+func (b *balancer) Update(p presence) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock.Now()
+
+	for id, s := range b.peers {
+		if s.Pending == 0 && now.After(s.ExpiresAt) {
+			delete(b.peers, id)
+		}
+	}
+
+	// forget sessions that have not made a call recently, they are re-pinned
+	// by consistent hashing if they make another call.
+	for id, p := range b.pins {
+		if now.Sub(p.LastUsed) > presenceTTL {
+			delete(b.pins, id)
+		}
+	}
+
+	s, ok := b.peers[p.PeerID]
+	if !ok {
+		if len(p.Namespaces) == 0 {
+			return
+		}
+
+		s = &peerState{}
+		b.peers[p.PeerID] = s
+	}
+
+	s.Namespaces = map[string]struct{}{}
+	for _, ns := range p.Namespaces {
+		s.Namespaces[ns] = struct{}{}
+	}
+
+	s.Groups = map[string]struct{}{}
+	for _, g := range p.Groups {
+		s.Groups[g] = struct{}{}
+	}
+
+	s.Capacity = p.Capacity
+	s.Caps = rinq.PeerCapabilities{Revision: p.Revision, Features: p.Features}
+	s.ExpiresAt = now.Add(presenceTTL)
+}
+
+// Select returns the peer that should service a call to the ns namespace made
+// by the sessID session. If group is non-empty, only peers in that group are
+// considered. ok is false if no such peer is known to be listening to ns, or
+// if the selection is left to the broker.
+//
+// If ok is true, Done() must be called with the returned peer ID once the call
+// is complete.
+func (b *balancer) Select(ns string, sessID ident.SessionID, group string) (peerID ident.PeerID, ok bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock.Now()
+
+	var candidates []ident.PeerID
+	for id, s := range b.peers {
+		if _, listening := s.Namespaces[ns]; !listening || now.After(s.ExpiresAt) {
+			continue
+		}
+
+		if _, member := s.Groups[group]; group != "" && !member {
+			continue
+		}
+
+		candidates = append(candidates, id)
+	}
+
+	if len(candidates) == 0 {
+		return
+	}
+
+	if b.sticky {
+		peerID = b.pinned(candidates, sessID, now)
+		b.peers[peerID].Pending++
+
+		return peerID, true
+	}
+
+	switch b.strategy {
+	case options.LeastPendingBalancing:
+		peerID = b.leastPending(candidates)
+	case options.SessionHashBalancing:
+		peerID = b.sessionHash(candidates, sessID)
+	case options.WeightedBalancing:
+		peerID = b.weighted(candidates)
+	case options.BrokerBalancing:
+		if group == "" {
+			return
+		}
+
+		// the broker can not restrict delivery to a group, so choose in
+		// proportion to capacity, as the broker would.
+		peerID = b.weighted(candidates)
+	default:
+		return
+	}
+
+	b.peers[peerID].Pending++
+
+	return peerID, true
+}
+
+// SelectLocal selects peerID to service a call to the ns namespace if it is
+// the only peer known to be listening to ns. It is used to pass calls that are
+// otherwise left to the broker directly to the local peer, as the broker would
+// deliver them to that peer anyway.
+//
+// If it returns true, Done() must be called with peerID once the call is
+// complete.
+func (b *balancer) SelectLocal(ns string, peerID ident.PeerID) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock.Now()
+
+	found := false
+	for id, s := range b.peers {
+		if _, listening := s.Namespaces[ns]; !listening || now.After(s.ExpiresAt) {
+			continue
+		}
+
+		if id != peerID {
+			return false
+		}
+
+		found = true
+	}
+
+	if found {
+		b.peers[peerID].Pending++
+	}
+
+	return found
+}
+
+// Capabilities returns the capabilities advertised by a peer. ok is false if
+// no current announcement has been received from the peer.
+func (b *balancer) Capabilities(peerID ident.PeerID) (caps rinq.PeerCapabilities, ok bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s, ok := b.peers[peerID]
+	if !ok || b.clock.Now().After(s.ExpiresAt) {
+		return rinq.PeerCapabilities{}, false
+	}
+
+	return s.Caps, true
+}
+
+// Done records the completion of a call to a peer returned by Select().
+func (b *balancer) Done(peerID ident.PeerID) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if s, ok := b.peers[peerID]; ok && s.Pending > 0 {
+		s.Pending--
+	}
+}
+
+// pinned returns the candidate that sessID is pinned to. If the session is not
+// pinned, or the peer it is pinned to is no longer a candidate, the session is
+// pinned to a candidate chosen by consistent hashing.
+func (b *balancer) pinned(candidates []ident.PeerID, sessID ident.SessionID, now time.Time) ident.PeerID {
+	p, ok := b.pins[sessID]
+	if !ok {
+		p = &pin{PeerID: b.sessionHash(candidates, sessID)}
+		b.pins[sessID] = p
+	} else if !containsPeer(candidates, p.PeerID) {
+		p.PeerID = b.sessionHash(candidates, sessID)
+	}
+
+	p.LastUsed = now
+
+	return p.PeerID
+}
+
+// leastPending returns the candidate with the fewest pending calls.
+func (b *balancer) leastPending(candidates []ident.PeerID) ident.PeerID {
+	best := candidates[0]
+
+	for _, id := range candidates[1:] {
+		if b.peers[id].Pending < b.peers[best].Pending {
+			best = id
+		}
+	}
+
+	return best
+}
+
+// sessionHash returns a candidate chosen by rendezvous hashing of sessID, such
+// that the same session maps to the same peer while that peer remains a
+// candidate.
+func (b *balancer) sessionHash(candidates []ident.PeerID, sessID ident.SessionID) ident.PeerID {
+	var best ident.PeerID
+	var bestScore uint64
+
+	for _, id := range candidates {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(sessID.String()))
+		_, _ = h.Write([]byte(id.String()))
+
+		if score := h.Sum64(); score >= bestScore {
+			best, bestScore = id, score
+		}
+	}
+
+	return best
+}
+
+// weighted returns a candidate chosen at random, weighted by capacity.
+func (b *balancer) weighted(candidates []ident.PeerID) ident.PeerID {
+	var total uint
+	for _, id := range candidates {
+		total += weight(b.peers[id])
+	}
+
+	n := uint(rand.Int63n(int64(total)))
+
+	for _, id := range candidates {
+		w := weight(b.peers[id])
+		if n < w {
+			return id
+		}
+		n -= w
+	}
+
+	return candidates[len(candidates)-1]
+}
+
+// weight returns the weight of s for weighted balancing.
+func weight(s *peerState) uint {
+	if s.Capacity == 0 {
+		return 1
+	}
+
+	return s.Capacity
+}
+
+// containsPeer returns true if id is in ids.
+func containsPeer(ids []ident.PeerID, id ident.PeerID) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,295 @@
+package commandamqp
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq/clock"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+)
+
+var _ = Describe("balancer", func() {
+	var (
+		clk    *clock.Manual
+		sessID ident.SessionID
+		peerA  ident.PeerID
+		peerB  ident.PeerID
+		peerC  ident.PeerID
+	)
+
+	BeforeEach(func() {
+		clk = clock.NewManual(time.Now())
+		sessID = ident.NewPeerID().Session(1)
+		peerA = ident.NewPeerID()
+		peerB = ident.NewPeerID()
+		peerC = ident.NewPeerID()
+	})
+
+	// announce records a presence announcement for id listening to the "ns"
+	// namespace.
+	announce := func(b *balancer, id ident.PeerID, capacity uint, groups ...string) {
+		b.Update(presence{
+			PeerID:     id,
+			Namespaces: []string{"ns"},
+			Capacity:   capacity,
+			Groups:     groups,
+		})
+	}
+
+	Describe("Select", func() {
+		It("returns false if no peer is listening to the namespace", func() {
+			b := newBalancer(options.LeastPendingBalancing, false, clk)
+			announce(b, peerA, 1)
+
+			_, ok := b.Select("other", sessID, "")
+
+			Expect(ok).To(BeFalse())
+		})
+
+		It("only considers peers in the group", func() {
+			b := newBalancer(options.LeastPendingBalancing, false, clk)
+			announce(b, peerA, 1)
+			announce(b, peerB, 1, "group")
+
+			for i := 0; i < 3; i++ {
+				id, ok := b.Select("ns", sessID, "group")
+
+				Expect(ok).To(BeTrue())
+				Expect(id).To(Equal(peerB))
+			}
+
+			_, ok := b.Select("ns", sessID, "other")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("skips peers with expired announcements", func() {
+			b := newBalancer(options.LeastPendingBalancing, false, clk)
+			announce(b, peerA, 1)
+
+			clk.Advance(presenceTTL + time.Second)
+
+			_, ok := b.Select("ns", sessID, "")
+			Expect(ok).To(BeFalse())
+		})
+
+		Context("when using least-pending balancing", func() {
+			It("chooses the peer with the fewest pending calls", func() {
+				b := newBalancer(options.LeastPendingBalancing, false, clk)
+				announce(b, peerA, 1)
+				announce(b, peerB, 1)
+
+				first, _ := b.Select("ns", sessID, "")
+				second, _ := b.Select("ns", sessID, "")
+				Expect(second).NotTo(Equal(first))
+
+				b.Done(first)
+
+				third, _ := b.Select("ns", sessID, "")
+				Expect(third).To(Equal(first))
+			})
+		})
+
+		Context("when using session-hash balancing", func() {
+			It("returns the same peer for the same session", func() {
+				b := newBalancer(options.SessionHashBalancing, false, clk)
+				announce(b, peerA, 1)
+				announce(b, peerB, 1)
+				announce(b, peerC, 1)
+
+				expected, _ := b.Select("ns", sessID, "")
+
+				for i := 0; i < 10; i++ {
+					id, _ := b.Select("ns", sessID, "")
+					Expect(id).To(Equal(expected))
+				}
+			})
+
+			It("chooses another peer when the peer stops listening", func() {
+				b := newBalancer(options.SessionHashBalancing, false, clk)
+				announce(b, peerA, 1)
+				announce(b, peerB, 1)
+				announce(b, peerC, 1)
+
+				previous, _ := b.Select("ns", sessID, "")
+				b.Update(presence{PeerID: previous, Namespaces: []string{"other"}})
+
+				id, ok := b.Select("ns", sessID, "")
+
+				Expect(ok).To(BeTrue())
+				Expect(id).NotTo(Equal(previous))
+			})
+		})
+
+		Context("when using weighted balancing", func() {
+			It("treats peers with no capacity as having a weight of one", func() {
+				b := newBalancer(options.WeightedBalancing, false, clk)
+				announce(b, peerA, 0)
+				announce(b, peerB, 0)
+
+				seen := map[ident.PeerID]bool{}
+				for i := 0; i < 1000 && len(seen) < 2; i++ {
+					id, ok := b.Select("ns", sessID, "")
+					Expect(ok).To(BeTrue())
+					seen[id] = true
+				}
+
+				Expect(seen).To(HaveLen(2))
+			})
+		})
+
+		Context("when using broker balancing", func() {
+			It("returns false if no group is specified", func() {
+				b := newBalancer(options.BrokerBalancing, false, clk)
+				announce(b, peerA, 1)
+
+				_, ok := b.Select("ns", sessID, "")
+
+				Expect(ok).To(BeFalse())
+			})
+
+			It("chooses a peer in the group", func() {
+				b := newBalancer(options.BrokerBalancing, false, clk)
+				announce(b, peerA, 1)
+				announce(b, peerB, 1, "group")
+
+				id, ok := b.Select("ns", sessID, "group")
+
+				Expect(ok).To(BeTrue())
+				Expect(id).To(Equal(peerB))
+			})
+		})
+
+		Context("when sessions are sticky", func() {
+			It("sends the session's calls to the same peer regardless of strategy", func() {
+				b := newBalancer(options.LeastPendingBalancing, true, clk)
+				announce(b, peerA, 1)
+				announce(b, peerB, 1)
+
+				// pending calls are not completed, so least-pending balancing
+				// alone would alternate between the peers
+				expected, _ := b.Select("ns", sessID, "")
+
+				for i := 0; i < 10; i++ {
+					id, ok := b.Select("ns", sessID, "")
+
+					Expect(ok).To(BeTrue())
+					Expect(id).To(Equal(expected))
+				}
+			})
+
+			It("pins the session to the peer chosen by session-hash balancing", func() {
+				sticky := newBalancer(options.WeightedBalancing, true, clk)
+				hashed := newBalancer(options.SessionHashBalancing, false, clk)
+
+				for _, b := range []*balancer{sticky, hashed} {
+					announce(b, peerA, 1)
+					announce(b, peerB, 1)
+					announce(b, peerC, 1)
+				}
+
+				expected, _ := hashed.Select("ns", sessID, "")
+				id, _ := sticky.Select("ns", sessID, "")
+
+				Expect(id).To(Equal(expected))
+			})
+
+			It("re-pins the session when its peer stops listening", func() {
+				b := newBalancer(options.BrokerBalancing, true, clk)
+				announce(b, peerA, 1)
+				announce(b, peerB, 1)
+				announce(b, peerC, 1)
+
+				previous, _ := b.Select("ns", sessID, "")
+				b.Update(presence{PeerID: previous, Namespaces: []string{"other"}})
+
+				var remaining []ident.PeerID
+				for _, id := range []ident.PeerID{peerA, peerB, peerC} {
+					if id != previous {
+						remaining = append(remaining, id)
+					}
+				}
+
+				next, ok := b.Select("ns", sessID, "")
+				Expect(ok).To(BeTrue())
+				Expect(next).To(Equal(b.sessionHash(remaining, sessID)))
+
+				// the session remains pinned to the new peer once the previous
+				// peer listens again
+				b.Update(presence{PeerID: previous, Namespaces: []string{"ns"}})
+
+				id, _ := b.Select("ns", sessID, "")
+				Expect(id).To(Equal(next))
+			})
+		})
+	})
+
+	Describe("SelectLocal", func() {
+		It("selects the peer if it is the only peer listening to the namespace", func() {
+			b := newBalancer(options.BrokerBalancing, false, clk)
+			announce(b, peerA, 1)
+
+			Expect(b.SelectLocal("ns", peerA)).To(BeTrue())
+			Expect(b.peers[peerA].Pending).To(BeNumerically("==", 1))
+		})
+
+		It("does not select the peer if other peers are listening", func() {
+			b := newBalancer(options.BrokerBalancing, false, clk)
+			announce(b, peerA, 1)
+			announce(b, peerB, 1)
+
+			Expect(b.SelectLocal("ns", peerA)).To(BeFalse())
+		})
+
+		It("does not select the peer if it is not listening", func() {
+			b := newBalancer(options.BrokerBalancing, false, clk)
+			announce(b, peerB, 1)
+
+			Expect(b.SelectLocal("ns", peerA)).To(BeFalse())
+		})
+
+		It("ignores peers with expired announcements", func() {
+			b := newBalancer(options.BrokerBalancing, false, clk)
+			announce(b, peerB, 1)
+			clk.Advance(presenceTTL + time.Second)
+			announce(b, peerA, 1)
+
+			Expect(b.SelectLocal("ns", peerA)).To(BeTrue())
+		})
+	})
+
+	Describe("Update", func() {
+		It("forgets pins that have not been used within the presence TTL", func() {
+			b := newBalancer(options.BrokerBalancing, true, clk)
+			announce(b, peerA, 1)
+
+			_, _ = b.Select("ns", sessID, "")
+			Expect(b.pins).To(HaveKey(sessID))
+
+			clk.Advance(presenceTTL / 2)
+			announce(b, peerA, 1)
+			Expect(b.pins).To(HaveKey(sessID))
+
+			clk.Advance(presenceTTL)
+			announce(b, peerA, 1)
+			Expect(b.pins).NotTo(HaveKey(sessID))
+		})
+
+		It("does not forget expired peers that have pending calls", func() {
+			b := newBalancer(options.LeastPendingBalancing, false, clk)
+			announce(b, peerA, 1)
+
+			_, _ = b.Select("ns", sessID, "")
+			clk.Advance(presenceTTL + time.Second)
+
+			announce(b, peerB, 1)
+			Expect(b.peers).To(HaveKey(peerA))
+
+			b.Done(peerA)
+
+			announce(b, peerB, 1)
+			Expect(b.peers).NotTo(HaveKey(peerA))
+		})
+	})
+})
@@ -0,0 +1,204 @@
+package commandamqp
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
+	"github.com/streadway/amqp"
+)
+
+// confirmer publishes messages on a channel in confirm mode, and waits for the
+// broker to confirm that it has taken responsibility for each message.
+//
+// Publishes are pipelined, each confirmation is matched to the message that it
+// describes by its delivery tag, so callers do not wait for each other's
+// confirmations.
+type confirmer struct {
+	channels amqputil.ChannelPool
+
+	mutex   sync.Mutex
+	current *confirmChannel // nil until the first publish, or after the channel fails
+}
+
+// confirmChannel is a channel in confirm mode, along with the messages
+// published on it that are awaiting confirmation.
+//
+// It has its own mutex, rather than using confirmer.mutex, so that confirmations
+// can be consumed while the channel is being closed.
+type confirmChannel struct {
+	channel *amqp.Channel
+
+	mutex   sync.Mutex
+	tag     uint64               // delivery tag of the last message published
+	pending map[uint64]chan bool // confirmations awaited by Publish(), by delivery tag
+}
+
+// errNotConfirmed is returned by confirmer.Publish() when the broker refuses
+// to take responsibility for a message.
+var errNotConfirmed = errors.New("the broker did not confirm the command request")
+
+// Publish publishes msg and blocks until the broker confirms it.
+//
+// If ctx is canceled before the confirmation arrives, ctx.Err() is returned.
+// The message may still have been delivered.
+func (c *confirmer) Publish(
+	ctx context.Context,
+	queues *queueSet,
+	exchange string,
+	key string,
+	msg amqp.Publishing,
+) error {
+	cc, tag, ack, err := c.publish(queues, exchange, key, msg)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case ok, open := <-ack:
+		if !open {
+			return amqp.ErrClosed
+		}
+
+		if !ok {
+			return errNotConfirmed
+		}
+
+		return nil
+
+	case <-ctx.Done():
+		cc.mutex.Lock()
+		delete(cc.pending, tag)
+		cc.mutex.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+// publish publishes msg on the current channel, opening it if necessary. It
+// returns the channel and delivery tag of the message, and a channel that
+// receives the broker's confirmation.
+func (c *confirmer) publish(
+	queues *queueSet,
+	exchange string,
+	key string,
+	msg amqp.Publishing,
+) (*confirmChannel, uint64, <-chan bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.current == nil {
+		if err := c.open(); err != nil {
+			return nil, 0, nil, err
+		}
+	}
+
+	cc := c.current
+
+	if _, err := queues.Get(cc.channel, key); err != nil {
+		c.discard()
+		return nil, 0, nil, err
+	}
+
+	// delivery tags are assigned by the broker sequentially from 1, for each
+	// message published on the channel after it enters confirm mode. The
+	// confirmation is awaited before publishing, as it may arrive before
+	// Publish() returns.
+	cc.mutex.Lock()
+	cc.tag++
+	tag := cc.tag
+	ack := make(chan bool, 1)
+	cc.pending[tag] = ack
+	cc.mutex.Unlock()
+
+	if err := cc.channel.Publish(
+		exchange,
+		key,
+		false, // mandatory
+		false, // immediate
+		msg,
+	); err != nil {
+		c.discard()
+		return nil, 0, nil, err
+	}
+
+	return cc, tag, ack, nil
+}
+
+// Close closes the channel, if it is open.
+func (c *confirmer) Close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.current != nil {
+		c.discard()
+	}
+}
+
+// open fetches a channel from the pool, puts it into confirm mode and makes it
+// the current channel. It assumes c.mutex is locked.
+func (c *confirmer) open() error {
+	channel, err := c.channels.Get()
+	if err != nil {
+		return err
+	}
+
+	if err := channel.Confirm(false); err != nil { // false = wait for reply
+		_ = channel.Close()
+		c.channels.Put(channel)
+		return err
+	}
+
+	cc := &confirmChannel{
+		channel: channel,
+		pending: map[uint64]chan bool{},
+	}
+
+	go c.confirm(cc, channel.NotifyPublish(make(chan amqp.Confirmation, 1)))
+
+	c.current = cc
+
+	return nil
+}
+
+// confirm passes each confirmation for messages published on cc to the
+// Publish() call that is waiting for it, until the channel is closed.
+func (c *confirmer) confirm(cc *confirmChannel, confirms <-chan amqp.Confirmation) {
+	for conf := range confirms {
+		cc.mutex.Lock()
+		if ack, ok := cc.pending[conf.DeliveryTag]; ok {
+			delete(cc.pending, conf.DeliveryTag)
+			ack <- conf.Ack
+		}
+		cc.mutex.Unlock()
+	}
+
+	// the channel has been closed, so the remaining messages will never be
+	// confirmed.
+	cc.mutex.Lock()
+	for tag, ack := range cc.pending {
+		delete(cc.pending, tag)
+		close(ack)
+	}
+	cc.mutex.Unlock()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.current == cc {
+		c.current = nil
+	}
+
+	// the channel is returned to the pool only so that the pool stops
+	// tracking it, as closed channels are never reused.
+	c.channels.Put(cc.channel)
+}
+
+// discard closes the current channel. Publish() calls that are waiting for
+// confirmation of messages published on it return amqp.ErrClosed. It assumes
+// c.mutex is locked.
+func (c *confirmer) discard() {
+	_ = c.current.channel.Close()
+	c.current = nil
+}
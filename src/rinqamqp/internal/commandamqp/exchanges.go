@@ -1,6 +1,9 @@
 package commandamqp
 
-import "github.com/streadway/amqp"
+import (
+	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
+	"github.com/streadway/amqp"
+)
 
 const (
 	// unicastExchange is the exchange used to publish internal command requests
@@ -17,11 +20,28 @@ const (
 
 	// responseExchange is the exchange used to publish command responses.
 	responseExchange = "cmd.rsp"
+
+	// presenceExchange is the exchange used to announce the namespaces that
+	// each peer is listening to.
+	presenceExchange = "cmd.pres"
+
+	// cancelExchange is the exchange used to ask servers to stop handling
+	// command requests that the invoker is no longer waiting for.
+	cancelExchange = "cmd.cancel"
 )
 
-func declareExchanges(channel *amqp.Channel) error {
+// cancelBroadcastKey returns the routing key used to publish a cancellation to
+// all servers, for requests where the server handling the request is not
+// known.
+func cancelBroadcastKey(tenant string) string {
+	return amqputil.TenantKey(tenant, "*")
+}
+
+// declareExchanges declares the exchanges used for command requests and
+// responses, with names that begin with prefix.
+func declareExchanges(channel *amqp.Channel, prefix string) error {
 	if err := channel.ExchangeDeclare(
-		unicastExchange,
+		prefix+unicastExchange,
 		"direct",
 		false, // durable
 		false, // autoDelete
@@ -33,7 +53,7 @@ func declareExchanges(channel *amqp.Channel) error {
 	}
 
 	if err := channel.ExchangeDeclare(
-		multicastExchange,
+		prefix+multicastExchange,
 		"direct",
 		false, // durable
 		false, // autoDelete
@@ -45,7 +65,7 @@ func declareExchanges(channel *amqp.Channel) error {
 	}
 
 	if err := channel.ExchangeDeclare(
-		balancedExchange,
+		prefix+balancedExchange,
 		"direct",
 		false, // durable
 		false, // autoDelete
@@ -57,7 +77,7 @@ func declareExchanges(channel *amqp.Channel) error {
 	}
 
 	if err := channel.ExchangeDeclare(
-		responseExchange,
+		prefix+responseExchange,
 		"topic",
 		false, // durable
 		false, // autoDelete
@@ -68,5 +88,29 @@ func declareExchanges(channel *amqp.Channel) error {
 		return err
 	}
 
+	if err := channel.ExchangeDeclare(
+		prefix+presenceExchange,
+		"fanout",
+		false, // durable
+		false, // autoDelete
+		false, // internal
+		false, // noWait
+		nil,   // args
+	); err != nil {
+		return err
+	}
+
+	if err := channel.ExchangeDeclare(
+		prefix+cancelExchange,
+		"direct",
+		false, // durable
+		false, // autoDelete
+		false, // internal
+		false, // noWait
+		nil,   // args
+	); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -4,18 +4,26 @@ import (
 	"github.com/rinq/rinq-go/src/internal/command"
 	"github.com/rinq/rinq-go/src/internal/localsession"
 	"github.com/rinq/rinq-go/src/internal/revisions"
+	"github.com/rinq/rinq-go/src/internal/sampling"
+	"github.com/rinq/rinq-go/src/rinq/dedupe"
 	"github.com/rinq/rinq-go/src/rinq/ident"
 	"github.com/rinq/rinq-go/src/rinq/options"
 	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
 )
 
 // New returns a pair of invoker and server.
+//
+// incarnation identifies this instance of the peer among others that have used
+// the same peer ID. It must remain the same for the lifetime of the peer,
+// including across restarts.
 func New(
 	peerID ident.PeerID,
+	incarnation uint64,
 	opts options.Options,
 	sessions *localsession.Store,
 	revs revisions.Store,
 	channels amqputil.ChannelPool,
+	flow *amqputil.Flow,
 ) (command.Invoker, command.Server, error) {
 	channel, err := channels.Get()
 	if err != nil {
@@ -23,34 +31,72 @@ func New(
 	}
 	defer channels.Put(channel)
 
-	if err = declareExchanges(channel); err != nil {
+	if err = declareExchanges(channel, opts.NamePrefix); err != nil {
 		return nil, nil, err
 	}
 
-	queues := &queueSet{}
+	queues := &queueSet{
+		tenant:   opts.Tenant,
+		prefix:   opts.NamePrefix,
+		options:  opts.Queues,
+		defaults: opts.DefaultQueue,
+	}
+	loop := &loopback{}
+	sampler := sampling.NewSampler(opts.Sampling)
 
 	invoker, err := newInvoker(
 		peerID,
 		opts.SessionWorkers,
 		opts.DefaultTimeout,
+		opts.Tenant,
+		opts.NamePrefix,
+		opts.Balancing,
+		opts.StickySessions,
+		opts.DeadlineDiagnostics,
+		opts.AtLeastOnce,
 		sessions,
 		queues,
+		loop,
 		channels,
+		flow,
 		opts.Logger,
 		opts.Tracer,
+		sampler,
+		opts.Metrics,
+		opts.Clock,
 	)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	store := opts.DedupeStore
+	if store == nil {
+		store = dedupe.NewMemoryStore(0)
+	}
+
 	server, err := newServer(
 		peerID,
+		incarnation,
 		opts.CommandWorkers,
+		opts.Tenant,
+		opts.NamePrefix,
 		revs,
 		queues,
+		loop,
 		channels,
+		opts.ReplayWindow,
+		opts.HopMargin,
+		opts.Groups,
+		opts.SlowHandlerThreshold,
+		opts.HandlerTimeouts,
+		opts.AdaptiveCommandWorkers,
+		opts.AtLeastOnce,
+		store,
 		opts.Logger,
 		opts.Tracer,
+		sampler,
+		opts.Metrics,
+		opts.Clock,
 	)
 	if err != nil {
 		invoker.Stop()
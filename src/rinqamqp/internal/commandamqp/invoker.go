@@ -2,7 +2,9 @@ package commandamqp
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jmalloc/twelf/src/twelf"
@@ -10,9 +12,13 @@ import (
 	"github.com/opentracing/opentracing-go/ext"
 	"github.com/rinq/rinq-go/src/internal/command"
 	"github.com/rinq/rinq-go/src/internal/localsession"
+	"github.com/rinq/rinq-go/src/internal/sampling"
 	"github.com/rinq/rinq-go/src/internal/service"
 	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/clock"
 	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/metrics"
+	"github.com/rinq/rinq-go/src/rinq/options"
 	"github.com/rinq/rinq-go/src/rinq/trace"
 	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
 	"github.com/streadway/amqp"
@@ -26,52 +32,98 @@ type invoker struct {
 	peerID         ident.PeerID
 	preFetch       uint
 	defaultTimeout time.Duration
+	tenant         string
+	prefix         string // prepended to exchange and queue names
+	balancer       *balancer
+	liveness       *liveness
 	sessions       *localsession.Store
 	queues         *queueSet
+	loopback       *loopback
 	channels       amqputil.ChannelPool
+	flow           *amqputil.Flow
 	channel        *amqp.Channel // channel used for consuming
 	logger         twelf.Logger
 	tracer         opentracing.Tracer
-
+	sampler        *sampling.Sampler
+	metrics        metrics.Recorder
+	clock          clock.Clock
+	diagnostics    bool
+	atLeastOnce    map[string]struct{} // namespaces with at-least-once execution
+	confirmer      *confirmer          // publishes at-least-once requests
+
+	calls    int32 // number of synchronous calls awaiting a response, atomic
 	mutex    sync.RWMutex
 	handlers map[ident.SessionID]rinq.AsyncHandler
+	watchdog *asyncWatchdog // tracks async calls that are awaiting a response
 
 	track      chan call            // add information about a call to pending
 	cancel     chan call            // remove call information from pending
 	deliveries <-chan amqp.Delivery // incoming command responses
+	presence   <-chan amqp.Delivery // incoming presence announcements
 	amqpClosed chan *amqp.Error
 
 	// state-machine data
-	pending map[string]chan *amqp.Delivery // map of message ID to reply channel
+	pending map[string]call // map of message ID to call
 }
 
 // call associates the message ID of a command request with the AMQP channel
 // used to deliver the response.
 type call struct {
-	ID    string
-	Reply chan *amqp.Delivery
+	ID       string
+	Reply    chan *amqp.Delivery
+	Progress *int32 // the furthest progress reported by the server, atomic
 }
 
+// Values of call.Progress, as reported by notices from the server.
+const (
+	progressNone int32 = iota
+	progressConsumed
+	progressRunning
+)
+
 // newInvoker creates, initializes and returns a new invoker.
 func newInvoker(
 	peerID ident.PeerID,
 	preFetch uint,
 	defaultTimeout time.Duration,
+	tenant string,
+	prefix string,
+	balancing options.BalanceStrategy,
+	sticky bool,
+	diagnostics bool,
+	atLeastOnce []string,
 	sessions *localsession.Store,
 	queues *queueSet,
+	loop *loopback,
 	channels amqputil.ChannelPool,
+	flow *amqputil.Flow,
 	logger twelf.Logger,
 	tracer opentracing.Tracer,
+	sampler *sampling.Sampler,
+	recorder metrics.Recorder,
+	clk clock.Clock,
 ) (command.Invoker, error) {
 	i := &invoker{
 		peerID:         peerID,
 		preFetch:       preFetch,
 		defaultTimeout: defaultTimeout,
+		tenant:         tenant,
+		prefix:         prefix,
+		balancer:       newBalancer(balancing, sticky, clk),
+		liveness:       newLiveness(clk),
+		diagnostics:    diagnostics,
+		atLeastOnce:    map[string]struct{}{},
+		confirmer:      &confirmer{channels: channels},
 		sessions:       sessions,
 		queues:         queues,
+		loopback:       loop,
 		channels:       channels,
+		flow:           flow,
 		logger:         logger,
 		tracer:         tracer,
+		sampler:        sampler,
+		metrics:        recorder,
+		clock:          clk,
 
 		handlers: map[ident.SessionID]rinq.AsyncHandler{},
 
@@ -79,9 +131,14 @@ func newInvoker(
 		cancel:     make(chan call),
 		amqpClosed: make(chan *amqp.Error, 1),
 
-		pending: map[string]chan *amqp.Delivery{},
+		pending: map[string]call{},
+	}
+
+	for _, ns := range atLeastOnce {
+		i.atLeastOnce[ns] = struct{}{}
 	}
 
+	i.watchdog = newAsyncWatchdog(i.expireAsync, clk)
 	i.sm = service.NewStateMachine(i.run, i.finalize)
 	i.Service = i.sm
 
@@ -107,11 +164,16 @@ func (i *invoker) CallUnicast(
 		MessageId: msgID.String(),
 		Priority:  callUnicastPriority,
 	}
-	packRequest(msg, traceID, ns, cmd, out, replyCorrelated)
+	packRequest(msg, traceID, ns, cmd, 0, out, replyCorrelated)
+	amqputil.PackTenant(msg, i.tenant)
 
-	logUnicastCallBegin(i.logger, i.peerID, msgID, target, ns, cmd, traceID, out)
+	logger := i.sampler.Logger(i.logger, ns, traceID)
+
+	logUnicastCallBegin(logger, i.peerID, msgID, target, ns, cmd, traceID, out)
+	start := time.Now()
 	in, err := i.call(ctx, unicastExchange, target.String(), msg)
-	logCallEnd(i.logger, i.peerID, msgID, ns, cmd, traceID, in, err)
+	i.metrics.RecordCall(ns, cmd, time.Since(start), err)
+	logCallEnd(logger, i.peerID, msgID, ns, cmd, traceID, in, err)
 
 	return in, err
 }
@@ -128,11 +190,34 @@ func (i *invoker) CallBalanced(
 		MessageId: msgID.String(),
 		Priority:  callBalancedPriority,
 	}
-	packRequest(msg, traceID, ns, cmd, out, replyCorrelated)
+	version := rinq.APIVersion(ctx)
+	packRequest(msg, traceID, ns, cmd, version, out, replyCorrelated)
+	amqputil.PackTenant(msg, i.tenant)
+
+	target, ok, err := i.selectTarget(ctx, ns, version, msgID)
+	if err != nil {
+		return nil, err
+	}
 
-	logBalancedCallBegin(i.logger, i.peerID, msgID, ns, cmd, traceID, out)
-	in, err := i.call(ctx, balancedExchange, ns, msg)
-	logCallEnd(i.logger, i.peerID, msgID, ns, cmd, traceID, in, err)
+	logger := i.sampler.Logger(i.logger, ns, traceID)
+
+	if ok {
+		defer i.balancer.Done(target)
+
+		logBalancedCallBeginTarget(logger, i.peerID, msgID, target, ns, cmd, traceID, out)
+		start := time.Now()
+		in, err := i.call(ctx, unicastExchange, target.String(), msg)
+		i.metrics.RecordCall(ns, cmd, time.Since(start), err)
+		logCallEnd(logger, i.peerID, msgID, ns, cmd, traceID, in, err)
+
+		return in, err
+	}
+
+	logBalancedCallBegin(logger, i.peerID, msgID, ns, cmd, traceID, out)
+	start := time.Now()
+	in, err := i.call(ctx, balancedExchange, routingKey(i.tenant, ns, version), msg)
+	i.metrics.RecordCall(ns, cmd, time.Since(start), err)
+	logCallEnd(logger, i.peerID, msgID, ns, cmd, traceID, in, err)
 
 	return in, err
 }
@@ -146,19 +231,71 @@ func (i *invoker) CallBalancedAsync(
 	ns string,
 	cmd string,
 	out *rinq.Payload,
+	h rinq.AsyncHandler,
 ) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, i.defaultTimeout)
+		defer cancel()
+		deadline, _ = ctx.Deadline()
+	}
+
 	msg := &amqp.Publishing{
 		MessageId: msgID.String(),
 		Priority:  callBalancedPriority,
 	}
-	packRequest(msg, traceID, ns, cmd, out, replyUncorrelated)
+	version := rinq.APIVersion(ctx)
+	packRequest(msg, traceID, ns, cmd, version, out, replyUncorrelated)
+	amqputil.PackTenant(msg, i.tenant)
+
+	exchange, key, err := i.route(ctx, ns, version, msgID)
+	if err != nil {
+		return err
+	}
+
+	// track the call before it is sent, so that a fast response is not
+	// mistaken for a response to a call that has already expired
+	i.watchdog.Track(
+		&asyncCall{
+			ID:        msgID,
+			Namespace: ns,
+			Command:   cmd,
+			TraceID:   traceID,
+			Sent:      i.clock.Now(),
+			Handler:   h,
+		},
+		deadline,
+	)
+
+	err = i.send(ctx, exchange, key, msg)
+	if err != nil {
+		i.watchdog.Done(msgID)
+	}
 
-	err := i.send(ctx, balancedExchange, ns, msg)
-	logAsyncRequest(i.logger, i.peerID, msgID, ns, cmd, traceID, out, err)
+	logAsyncRequest(i.sampler.Logger(i.logger, ns, traceID), i.peerID, msgID, ns, cmd, traceID, out, err)
 
 	return err
 }
 
+// PendingCalls returns the number of command calls, including asynchronous
+// calls, that are awaiting a response.
+func (i *invoker) PendingCalls() int {
+	return int(atomic.LoadInt32(&i.calls)) + i.watchdog.Len()
+}
+
+// Capabilities returns the capabilities advertised by another peer.
+func (i *invoker) Capabilities(peerID ident.PeerID) (rinq.PeerCapabilities, bool) {
+	return i.balancer.Capabilities(peerID)
+}
+
+// ObserveLostPeers registers fn to be called when another peer is presumed to
+// have stopped because its presence announcements have expired, or because it
+// has been replaced by a new instance with the same peer ID.
+func (i *invoker) ObserveLostPeers(fn func(ident.PeerID)) {
+	i.liveness.Observe(fn)
+}
+
 // SetAsyncHandler sets the asynchronous handler to use for a specific
 // session.
 func (i *invoker) SetAsyncHandler(sessID ident.SessionID, h rinq.AsyncHandler) {
@@ -185,14 +322,87 @@ func (i *invoker) ExecuteBalanced(
 		Priority:     executePriority,
 		DeliveryMode: amqp.Persistent,
 	}
-	packRequest(msg, traceID, ns, cmd, out, replyNone)
+	version := rinq.APIVersion(ctx)
+	packRequest(msg, traceID, ns, cmd, version, out, replyNone)
+	amqputil.PackTenant(msg, i.tenant)
+
+	var err error
+	if _, ok := i.atLeastOnce[ns]; ok {
+		err = i.sendConfirmed(ctx, routingKey(i.tenant, ns, version), msg)
+	} else {
+		var exchange, key string
+		exchange, key, err = i.route(ctx, ns, version, msgID)
+		if err != nil {
+			return err
+		}
+
+		err = i.send(ctx, exchange, key, msg)
+	}
+
+	logBalancedExecute(i.sampler.Logger(i.logger, ns, traceID), i.peerID, msgID, ns, cmd, traceID, out, err)
+
+	return err
+}
+
+func (i *invoker) ExecuteBalancedAt(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	t time.Time,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+) error {
+	msg := &amqp.Publishing{
+		MessageId:    msgID.String(),
+		Priority:     executePriority,
+		DeliveryMode: amqp.Persistent,
+	}
+	version := rinq.APIVersion(ctx)
+	packRequest(msg, traceID, ns, cmd, version, out, replyNone)
+	amqputil.PackTenant(msg, i.tenant)
 
-	err := i.send(ctx, balancedExchange, ns, msg)
-	logBalancedExecute(i.logger, i.peerID, msgID, ns, cmd, traceID, out, err)
+	err := i.schedule(ctx, msgID, t, routingKey(i.tenant, ns, version), msg)
+	logBalancedExecuteAt(i.sampler.Logger(i.logger, ns, traceID), i.peerID, msgID, t, ns, cmd, traceID, out, err)
 
 	return err
 }
 
+func (i *invoker) CancelScheduled(ctx context.Context, msgID ident.MessageID) (bool, error) {
+	select {
+	default:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-i.sm.Graceful:
+		return false, context.Canceled
+	case <-i.sm.Forceful:
+		return false, context.Canceled
+	}
+
+	channel, err := i.channels.Get()
+	if err != nil {
+		return false, err
+	}
+	defer i.channels.Put(channel)
+
+	n, err := channel.QueueDelete(
+		scheduledRequestQueue(i.prefix, msgID),
+		false, // ifUnused
+		false, // ifEmpty
+		false, // noWait
+	)
+
+	// the queue is deleted by the broker some time after the request is sent
+	if e, ok := err.(*amqp.Error); ok && e.Code == amqp.NotFound {
+		n, err = 0, nil
+	}
+
+	ok := err == nil && n != 0
+	logCancelScheduled(i.logger, i.peerID, msgID, ok, err)
+
+	return ok, err
+}
+
 func (i *invoker) ExecuteMulticast(
 	ctx context.Context,
 	msgID ident.MessageID,
@@ -205,14 +415,96 @@ func (i *invoker) ExecuteMulticast(
 		MessageId: msgID.String(),
 		Priority:  executePriority,
 	}
-	packRequest(msg, traceID, ns, cmd, out, replyNone)
+	version := rinq.APIVersion(ctx)
+	packRequest(msg, traceID, ns, cmd, version, out, replyNone)
+	amqputil.PackTenant(msg, i.tenant)
 
-	err := i.send(ctx, multicastExchange, ns, msg)
-	logMulticastExecute(i.logger, i.peerID, msgID, ns, cmd, traceID, out, err)
+	err := i.send(ctx, multicastExchange, routingKey(i.tenant, ns, version), msg)
+	logMulticastExecute(i.sampler.Logger(i.logger, ns, traceID), i.peerID, msgID, ns, cmd, traceID, out, err)
 
 	return err
 }
 
+// selectTarget returns the peer that should service a load-balanced request
+// for the ns namespace. ok is false if the request should be balanced by the
+// broker. This peer is selected if it is the only peer listening to ns,
+// regardless of the balancing strategy.
+//
+// err is non-nil if ctx restricts the request to a group, as per
+// rinq.WithGroup(), and no peer in that group is known to be listening to ns.
+//
+// If ok is true, i.balancer.Done() must be called with the returned peer ID
+// once the request is complete.
+func (i *invoker) selectTarget(
+	ctx context.Context,
+	ns string,
+	version uint,
+	msgID ident.MessageID,
+) (target ident.PeerID, ok bool, err error) {
+	key := routingKey(i.tenant, ns, version)
+	group, strict := rinq.Group(ctx)
+
+	if group != "" {
+		if target, ok = i.balancer.Select(key, msgID.Ref.ID, group); ok {
+			return
+		}
+
+		if strict {
+			err = noGroupPeerError(group, ns)
+			return
+		}
+	}
+
+	if target, ok = i.balancer.Select(key, msgID.Ref.ID, ""); ok {
+		return
+	}
+
+	// if this peer is the only listener, the request is passed directly to
+	// the local server, rather than via the broker.
+	if i.balancer.SelectLocal(key, i.peerID) {
+		return i.peerID, true, nil
+	}
+
+	return
+}
+
+// route returns the exchange and routing key used to send a load-balanced
+// request that does not wait for a response.
+//
+// Such requests are balanced by the broker unless ctx restricts them to a
+// group, in which case they are sent directly to a peer in the group.
+func (i *invoker) route(
+	ctx context.Context,
+	ns string,
+	version uint,
+	msgID ident.MessageID,
+) (exchange string, key string, err error) {
+	key = routingKey(i.tenant, ns, version)
+
+	if group, strict := rinq.Group(ctx); group != "" {
+		if target, ok := i.balancer.Select(key, msgID.Ref.ID, group); ok {
+			i.balancer.Done(target)
+			return unicastExchange, target.String(), nil
+		}
+
+		if strict {
+			return "", "", noGroupPeerError(group, ns)
+		}
+	}
+
+	return balancedExchange, key, nil
+}
+
+// noGroupPeerError returns the error used when a request is restricted to a
+// group in which no peer is listening to the ns namespace.
+func noGroupPeerError(group, ns string) error {
+	return fmt.Errorf(
+		"no peers in the '%s' group are listening to the '%s' namespace",
+		group,
+		ns,
+	)
+}
+
 // initialize prepares the AMQP channel and starts the state machine
 func (i *invoker) initialize() error {
 	if channel, err := i.channels.GetQOS(i.preFetch); err == nil { // do not return to pool, used for consume
@@ -223,7 +515,7 @@ func (i *invoker) initialize() error {
 
 	i.channel.NotifyClose(i.amqpClosed)
 
-	queue := responseQueue(i.peerID)
+	queue := responseQueue(i.prefix, i.peerID)
 
 	if _, err := i.channel.QueueDeclare(
 		queue,
@@ -239,7 +531,7 @@ func (i *invoker) initialize() error {
 	if err := i.channel.QueueBind(
 		queue,
 		i.peerID.String()+".*",
-		responseExchange,
+		i.prefix+responseExchange,
 		false, // noWait
 		nil,   // args
 	); err != nil {
@@ -256,6 +548,49 @@ func (i *invoker) initialize() error {
 		false, // noWait
 		nil,   // args
 	)
+	if err != nil {
+		return err
+	}
+
+	return i.initializePresence()
+}
+
+// initializePresence prepares the queue used to receive presence
+// announcements from other peers.
+func (i *invoker) initializePresence() error {
+	queue := presenceQueue(i.prefix, i.peerID)
+
+	if _, err := i.channel.QueueDeclare(
+		queue,
+		false, // durable
+		false, // autoDelete
+		true,  // exclusive,
+		false, // noWait
+		nil,   // args
+	); err != nil {
+		return err
+	}
+
+	if err := i.channel.QueueBind(
+		queue,
+		"",
+		i.prefix+presenceExchange,
+		false, // noWait
+		nil,   // args
+	); err != nil {
+		return err
+	}
+
+	var err error
+	i.presence, err = i.channel.Consume(
+		queue,
+		queue, // use queue name as consumer tag
+		true,  // autoAck
+		true,  // exclusive
+		false, // noLocal
+		false, // noWait
+		nil,   // args
+	)
 
 	return err
 }
@@ -264,10 +599,13 @@ func (i *invoker) initialize() error {
 func (i *invoker) run() (service.State, error) {
 	logInvokerStart(i.logger, i.peerID, i.preFetch)
 
+	ticker := time.NewTicker(presenceInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case c := <-i.track:
-			i.pending[c.ID] = c.Reply
+			i.pending[c.ID] = c
 
 		case c := <-i.cancel:
 			delete(i.pending, c.ID)
@@ -279,6 +617,12 @@ func (i *invoker) run() (service.State, error) {
 			}
 			i.reply(&msg)
 
+		case msg := <-i.presence:
+			i.updatePresence(&msg)
+
+		case <-ticker.C:
+			i.liveness.Expire()
+
 		case <-i.sm.Graceful:
 			return i.graceful, nil
 
@@ -307,6 +651,9 @@ func (i *invoker) graceful() (service.State, error) {
 			}
 			i.reply(&msg)
 
+		case msg := <-i.presence:
+			i.updatePresence(&msg)
+
 		case <-i.sm.Forceful:
 			return i.forceful, nil
 
@@ -326,6 +673,8 @@ func (i *invoker) forceful() (service.State, error) {
 // finalize is the state-machine finalizer, it is called immediately before the
 // Done() channel is closed.
 func (i *invoker) finalize(err error) error {
+	i.watchdog.Stop()
+	i.confirmer.Close()
 	logInvokerStop(i.logger, i.peerID, err)
 	return err
 }
@@ -350,9 +699,17 @@ func (i *invoker) call(
 		return nil, err
 	}
 
+	atomic.AddInt32(&i.calls, 1)
+	defer atomic.AddInt32(&i.calls, -1)
+
 	c := call{
 		msg.MessageId,
 		make(chan *amqp.Delivery, 1),
+		new(int32),
+	}
+
+	if i.diagnostics {
+		packDiagnostics(msg)
 	}
 
 	select {
@@ -379,9 +736,11 @@ func (i *invoker) call(
 		}
 	}()
 
-	err := i.publish(ctx, exchange, key, msg)
-	if err != nil {
-		return nil, err
+	local := i.callLocal(ctx, exchange, key, msg, c.Reply)
+	if !local {
+		if err := i.publish(ctx, exchange, key, msg); err != nil {
+			return nil, err
+		}
 	}
 
 	select {
@@ -389,12 +748,105 @@ func (i *invoker) call(
 		payload, err := unpackResponse(msg)
 		return payload, err
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		err := ctx.Err()
+
+		// the server shares the deadline, but must be told about cancellation
+		if err == context.Canceled {
+			i.cancelCall(msg.MessageId, exchange, key, local)
+		} else if i.diagnostics {
+			err = deadlineExceeded(c, local)
+		}
+
+		return nil, err
 	case <-i.sm.Forceful:
 		return nil, context.Canceled
 	}
 }
 
+// deadlineExceeded returns an error describing how far the request made by c
+// had progressed when its deadline was exceeded.
+func deadlineExceeded(c call, local bool) error {
+	stage := rinq.DeadlineStageUnknown
+
+	// requests handled via the loopback do not report their progress
+	if !local {
+		switch atomic.LoadInt32(c.Progress) {
+		case progressNone:
+			stage = rinq.DeadlineStageNotConsumed
+		case progressConsumed:
+			stage = rinq.DeadlineStageResponseLost
+		case progressRunning:
+			stage = rinq.DeadlineStageHandling
+		}
+	}
+
+	return rinq.DeadlineExceededError{Stage: stage}
+}
+
+// callLocal passes a message for a "call-type" invocation to the server of this
+// peer via the loopback, if this peer is the target. It returns false if the
+// message must be published to the broker instead.
+func (i *invoker) callLocal(
+	ctx context.Context,
+	exchange string,
+	key string,
+	msg *amqp.Publishing,
+	reply chan<- *amqp.Delivery,
+) bool {
+	if exchange != unicastExchange || key != i.peerID.String() {
+		return false
+	}
+
+	return i.loopback.Call(ctx, msg, reply)
+}
+
+// cancelCall asks the server handling a call to cancel the request's context,
+// after the caller's context has been canceled.
+//
+// If the request was published to the unicast exchange, the cancellation is
+// sent only to the target peer. Otherwise, the server is not known, and the
+// cancellation is sent to all servers.
+func (i *invoker) cancelCall(id string, exchange, key string, local bool) {
+	msgID, err := ident.ParseMessageID(id)
+	if err != nil {
+		return
+	}
+
+	if local {
+		i.loopback.Cancel(msgID)
+		logCallCanceled(i.logger, i.peerID, msgID, nil)
+		return
+	}
+
+	target := cancelBroadcastKey(i.tenant)
+	if exchange == unicastExchange {
+		target = key
+	}
+
+	// cancellations share the server's request queue, they are sent with the
+	// highest priority so that they are not delayed behind pending requests.
+	msg := amqp.Publishing{
+		MessageId: id,
+		Priority:  callUnicastPriority,
+	}
+	amqputil.PackTenant(&msg, i.tenant)
+
+	channel, err := i.channels.Get()
+	if err == nil {
+		defer i.channels.Put(channel)
+
+		err = channel.Publish(
+			i.prefix+cancelExchange,
+			target,
+			false, // mandatory
+			false, // immediate
+			msg,
+		)
+	}
+
+	logCallCanceled(i.logger, i.peerID, msgID, err)
+}
+
 // send publishes a message for a command request
 func (i *invoker) send(
 	ctx context.Context,
@@ -429,6 +881,13 @@ func (i *invoker) publish(
 		return err
 	}
 
+	amqputil.PackBaggage(ctx, msg)
+	amqputil.PackHeaders(ctx, msg)
+
+	if err := i.flow.Wait(ctx); err != nil {
+		return err
+	}
+
 	channel, err := i.channels.Get()
 	if err != nil {
 		return err
@@ -442,7 +901,7 @@ func (i *invoker) publish(
 	}
 
 	return channel.Publish(
-		exchange,
+		i.prefix+exchange,
 		key,
 		false, // mandatory
 		false, // immediate
@@ -450,10 +909,146 @@ func (i *invoker) publish(
 	)
 }
 
+// sendConfirmed publishes a message for an at-least-once command request to
+// the balanced exchange, and waits for the broker to confirm that it has taken
+// responsibility for it.
+//
+// Unlike send(), the message does not carry the deadline of ctx, so the
+// request remains queued until it is handled.
+func (i *invoker) sendConfirmed(
+	ctx context.Context,
+	key string,
+	msg *amqp.Publishing,
+) error {
+	select {
+	default:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-i.sm.Graceful:
+		return context.Canceled
+	case <-i.sm.Forceful:
+		return context.Canceled
+	}
+
+	if err := amqputil.PackSpanContext(ctx, msg); err != nil {
+		return err
+	}
+
+	amqputil.PackBaggage(ctx, msg)
+	amqputil.PackHeaders(ctx, msg)
+
+	if err := i.flow.Wait(ctx); err != nil {
+		return err
+	}
+
+	return i.confirmer.Publish(
+		ctx,
+		i.queues,
+		i.prefix+balancedExchange,
+		key,
+		*msg,
+	)
+}
+
+// schedule sends a balanced command request to a queue of its own, from which
+// the broker dead-letters it to the balanced exchange once its TTL expires at
+// time t. The queue is deleted by the broker once it has been empty for
+// scheduledQueueExpiry.
+func (i *invoker) schedule(
+	ctx context.Context,
+	msgID ident.MessageID,
+	t time.Time,
+	key string,
+	msg *amqp.Publishing,
+) error {
+	select {
+	default:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-i.sm.Graceful:
+		return context.Canceled
+	case <-i.sm.Forceful:
+		return context.Canceled
+	}
+
+	// the deadline is deliberately not packed, as it applies to scheduling the
+	// request, and the expiration would override the queue's TTL.
+	if err := amqputil.PackSpanContext(ctx, msg); err != nil {
+		return err
+	}
+
+	amqputil.PackBaggage(ctx, msg)
+	amqputil.PackHeaders(ctx, msg)
+
+	if err := i.flow.Wait(ctx); err != nil {
+		return err
+	}
+
+	channel, err := i.channels.Get()
+	if err != nil {
+		return err
+	}
+	defer i.channels.Put(channel)
+
+	// declare the balanced queue now, so that the request is retained at time
+	// t even if no peers are listening.
+	if _, err = i.queues.Get(channel, key); err != nil {
+		return err
+	}
+
+	ttl := t.Sub(i.clock.Now()) / time.Millisecond
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	queue := scheduledRequestQueue(i.prefix, msgID)
+
+	if _, err = channel.QueueDeclare(
+		queue,
+		true,  // durable
+		false, // autoDelete
+		false, // exclusive,
+		false, // noWait
+		amqp.Table{
+			"x-message-ttl":             int64(ttl),
+			"x-expires":                 int64(ttl + scheduledQueueExpiry/time.Millisecond),
+			"x-dead-letter-exchange":    i.prefix + balancedExchange,
+			"x-dead-letter-routing-key": key,
+		},
+	); err != nil {
+		return err
+	}
+
+	return channel.Publish(
+		"", // default exchange, routes directly to queue
+		queue,
+		false, // mandatory
+		false, // immediate
+		*msg,
+	)
+}
+
+// updatePresence records a presence announcement from another peer.
+func (i *invoker) updatePresence(msg *amqp.Delivery) {
+	p, err := unpackPresence(msg)
+	if err != nil {
+		logInvokerIgnoredPresence(i.logger, i.peerID, err)
+		return
+	}
+
+	i.balancer.Update(p)
+
+	if p.PeerID != i.peerID {
+		i.liveness.Seen(p.PeerID, p.Incarnation)
+	}
+}
+
 // reply sends a command response to a waiting sender.
 func (i *invoker) reply(msg *amqp.Delivery) {
 	var ack bool
-	if unpackReplyMode(msg) == replyUncorrelated {
+	if isNotice(msg) {
+		ack = i.progress(msg)
+	} else if unpackReplyMode(msg) == replyUncorrelated {
 		ack = i.replyAsync(msg)
 	} else {
 		ack = i.replySync(msg)
@@ -467,14 +1062,34 @@ func (i *invoker) reply(msg *amqp.Delivery) {
 }
 
 func (i *invoker) replySync(msg *amqp.Delivery) bool {
-	channel := i.pending[msg.RoutingKey]
-	if channel == nil {
+	c, ok := i.pending[msg.RoutingKey]
+	if !ok {
 		return false
 	}
 
 	delete(i.pending, msg.RoutingKey)
-	channel <- msg // buffered chan
-	close(channel)
+	c.Reply <- msg // buffered chan
+	close(c.Reply)
+
+	return true
+}
+
+// progress records a notice from the server about the progress of a pending
+// call. Notices may arrive out of order, so only the furthest progress is kept.
+func (i *invoker) progress(msg *amqp.Delivery) bool {
+	c, ok := i.pending[msg.RoutingKey]
+	if !ok {
+		return false
+	}
+
+	p := progressConsumed
+	if msg.Type == runningNotice {
+		p = progressRunning
+	}
+
+	if p > atomic.LoadInt32(c.Progress) {
+		atomic.StoreInt32(c.Progress, p)
+	}
 
 	return true
 }
@@ -492,6 +1107,13 @@ func (i *invoker) replyAsync(msg *amqp.Delivery) bool {
 		return false
 	}
 
+	// the handler has already been notified of a timeout
+	c := i.watchdog.Done(msgID)
+	if c == nil {
+		logAsyncLateResponse(i.logger, i.peerID, msgID, ns, cmd)
+		return false
+	}
+
 	sess, ok := i.sessions.Get(msgID.Ref.ID)
 	if !ok {
 		return false
@@ -506,21 +1128,20 @@ func (i *invoker) replyAsync(msg *amqp.Delivery) bool {
 		return false
 	}
 
-	i.mutex.RLock()
-	handler := i.handlers[msgID.Ref.ID]
-	i.mutex.RUnlock()
-
+	handler := i.asyncHandler(c)
 	if handler == nil {
 		return false
 	}
 
 	ctx := amqputil.UnpackTrace(context.Background(), msg)
+	traceID := trace.Get(ctx)
 	payload, err := unpackResponse(msg)
+	i.metrics.RecordCall(ns, cmd, i.clock.Now().Sub(c.Sent), err)
 
-	span := i.tracer.StartSpan("", spanOpts...)
+	span := i.sampler.Tracer(i.tracer, ns, traceID).StartSpan("", spanOpts...)
 	ctx = opentracing.ContextWithSpan(ctx, span)
 
-	logAsyncResponse(i.logger, i.peerID, msgID, ns, cmd, trace.Get(ctx), payload, err)
+	logAsyncResponse(i.sampler.Logger(i.logger, ns, traceID), i.peerID, msgID, ns, cmd, traceID, payload, err)
 
 	go func() {
 		defer span.Finish()
@@ -529,3 +1150,41 @@ func (i *invoker) replyAsync(msg *amqp.Delivery) bool {
 
 	return true
 }
+
+// expireAsync invokes the asynchronous handler for c with a timeout error
+// because no response was received before its deadline.
+func (i *invoker) expireAsync(c *asyncCall) {
+	logAsyncTimeout(i.logger, i.peerID, c.ID, c.Namespace, c.Command, c.TraceID)
+	i.metrics.RecordCall(c.Namespace, c.Command, i.clock.Now().Sub(c.Sent), context.DeadlineExceeded)
+
+	sess, ok := i.sessions.Get(c.ID.Ref.ID)
+	if !ok {
+		return
+	}
+
+	handler := i.asyncHandler(c)
+	if handler == nil {
+		return
+	}
+
+	span := i.sampler.Tracer(i.tracer, c.Namespace, c.TraceID).StartSpan("", ext.SpanKindRPCClient)
+	defer span.Finish()
+
+	ctx := trace.With(context.Background(), c.TraceID)
+	ctx = opentracing.ContextWithSpan(ctx, span)
+
+	handler(ctx, sess, c.ID, c.Namespace, c.Command, nil, context.DeadlineExceeded)
+}
+
+// asyncHandler returns the handler to invoke with the response to c, or nil if
+// there is none.
+func (i *invoker) asyncHandler(c *asyncCall) rinq.AsyncHandler {
+	if c.Handler != nil {
+		return c.Handler
+	}
+
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	return i.handlers[c.ID.Ref.ID]
+}
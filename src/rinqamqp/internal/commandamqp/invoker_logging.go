@@ -1,6 +1,8 @@
 package commandamqp
 
 import (
+	"time"
+
 	"github.com/jmalloc/twelf/src/twelf"
 	"github.com/rinq/rinq-go/src/rinq"
 	"github.com/rinq/rinq-go/src/rinq/ident"
@@ -32,6 +34,18 @@ func logInvokerIgnoredMessage(
 	)
 }
 
+func logInvokerIgnoredPresence(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	err error,
+) {
+	logger.Debug(
+		"%s invoker ignored presence announcement, %s",
+		peerID.ShortString(),
+		err,
+	)
+}
+
 func logUnicastCallBegin(
 	logger twelf.Logger,
 	peerID ident.PeerID,
@@ -74,6 +88,28 @@ func logBalancedCallBegin(
 	)
 }
 
+func logBalancedCallBeginTarget(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	msgID ident.MessageID,
+	target ident.PeerID,
+	ns string,
+	cmd string,
+	traceID string,
+	payload *rinq.Payload,
+) {
+	logger.Debug(
+		"%s invoker began '%s::%s' call %s, balanced to %s [%s] >>> %s",
+		peerID.ShortString(),
+		ns,
+		cmd,
+		msgID.ShortString(),
+		target.ShortString(),
+		traceID,
+		payload,
+	)
+}
+
 func logCallEnd(
 	logger twelf.Logger,
 	peerID ident.PeerID,
@@ -171,6 +207,62 @@ func logAsyncResponse(
 	)
 }
 
+func logAsyncLateResponse(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	msgID ident.MessageID,
+	ns string,
+	cmd string,
+) {
+	logger.Debug(
+		"%s invoker ignored asynchronous '%s::%s' call response %s, the request has already timed out",
+		peerID.ShortString(),
+		ns,
+		cmd,
+		msgID.ShortString(),
+	)
+}
+
+func logAsyncTimeout(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	msgID ident.MessageID,
+	ns string,
+	cmd string,
+	traceID string,
+) {
+	logger.Debug(
+		"%s invoker did not receive a response to asynchronous '%s::%s' call request %s before the deadline [%s]",
+		peerID.ShortString(),
+		ns,
+		cmd,
+		msgID.ShortString(),
+		traceID,
+	)
+}
+
+func logCallCanceled(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	msgID ident.MessageID,
+	err error,
+) {
+	if err == nil {
+		logger.Debug(
+			"%s invoker asked the server to cancel call %s",
+			peerID.ShortString(),
+			msgID.ShortString(),
+		)
+	} else {
+		logger.Debug(
+			"%s invoker could not ask the server to cancel call %s: %s",
+			peerID.ShortString(),
+			msgID.ShortString(),
+			err,
+		)
+	}
+}
+
 func logBalancedExecute(
 	logger twelf.Logger,
 	peerID ident.PeerID,
@@ -192,6 +284,47 @@ func logBalancedExecute(
 	)
 }
 
+func logBalancedExecuteAt(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	msgID ident.MessageID,
+	t time.Time,
+	ns string,
+	cmd string,
+	traceID string,
+	payload *rinq.Payload,
+	err error,
+) {
+	logger.Debug(
+		"%s invoker scheduled '%s::%s' execution %s for %s [%s] >>> %s",
+		peerID.ShortString(),
+		ns,
+		cmd,
+		msgID.ShortString(),
+		t.Format(time.RFC3339),
+		traceID,
+		payload,
+	)
+}
+
+func logCancelScheduled(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	msgID ident.MessageID,
+	ok bool,
+	err error,
+) {
+	if err != nil || !ok {
+		return
+	}
+
+	logger.Debug(
+		"%s invoker canceled scheduled execution %s",
+		peerID.ShortString(),
+		msgID.ShortString(),
+	)
+}
+
 func logMulticastExecute(
 	logger twelf.Logger,
 	peerID ident.PeerID,
@@ -0,0 +1,97 @@
+package commandamqp
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/internal/service"
+	"github.com/rinq/rinq-go/src/rinq/clock"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+	"github.com/streadway/amqp"
+)
+
+var _ = Describe("invoker", func() {
+	var (
+		peerID  ident.PeerID
+		msgID   ident.MessageID
+		svr     *server
+		subject *invoker
+	)
+
+	BeforeEach(func() {
+		peerID = ident.NewPeerID()
+		msgID = peerID.Session(1).At(0).Message(1)
+
+		svr = &server{
+			sm:         &service.StateMachine{},
+			peerID:     peerID,
+			deliveries: make(chan amqp.Delivery, 1),
+		}
+
+		subject = &invoker{
+			peerID:   peerID,
+			balancer: newBalancer(options.BrokerBalancing, false, clock.NewManual(time.Now())),
+			loopback: &loopback{},
+		}
+		subject.loopback.Attach(svr)
+	})
+
+	Describe("selectTarget", func() {
+		It("selects this peer if it is the only peer listening to the namespace", func() {
+			subject.balancer.Update(presence{PeerID: peerID, Namespaces: []string{"ns"}})
+
+			target, ok, err := subject.selectTarget(context.Background(), "ns", 0, msgID)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(target).To(Equal(peerID))
+		})
+
+		It("leaves the request to the broker if other peers are listening", func() {
+			subject.balancer.Update(presence{PeerID: peerID, Namespaces: []string{"ns"}})
+			subject.balancer.Update(presence{PeerID: ident.NewPeerID(), Namespaces: []string{"ns"}})
+
+			_, ok, err := subject.selectTarget(context.Background(), "ns", 0, msgID)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("callLocal", func() {
+		It("passes requests for this peer to the local server", func() {
+			reply := make(chan *amqp.Delivery, 1)
+			msg := &amqp.Publishing{MessageId: msgID.String()}
+
+			ok := subject.callLocal(context.Background(), unicastExchange, peerID.String(), msg, reply)
+			Expect(ok).To(BeTrue())
+
+			var d amqp.Delivery
+			Expect(svr.deliveries).To(Receive(&d))
+			Expect(d.MessageId).To(Equal(msgID.String()))
+			Expect(loopbackReply(&d) == (chan<- *amqp.Delivery)(reply)).To(BeTrue())
+		})
+
+		It("does not pass requests for other peers to the local server", func() {
+			msg := &amqp.Publishing{MessageId: msgID.String()}
+			target := ident.NewPeerID()
+
+			ok := subject.callLocal(context.Background(), unicastExchange, target.String(), msg, nil)
+
+			Expect(ok).To(BeFalse())
+			Expect(svr.deliveries).NotTo(Receive())
+		})
+
+		It("does not pass balanced requests to the local server", func() {
+			msg := &amqp.Publishing{MessageId: msgID.String()}
+
+			ok := subject.callLocal(context.Background(), balancedExchange, "ns", msg, nil)
+
+			Expect(ok).To(BeFalse())
+			Expect(svr.deliveries).NotTo(Receive())
+		})
+	})
+})
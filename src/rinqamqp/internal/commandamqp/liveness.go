@@ -0,0 +1,95 @@
+package commandamqp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rinq/rinq-go/src/rinq/clock"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// liveness tracks which other peers are running, based on the presence
+// announcements that every peer publishes periodically.
+//
+// A peer that stops without announcing that it has done so, such as when its
+// process is killed, is presumed to have stopped once its most recent
+// announcement expires, or as soon as an announcement is received from a new
+// incarnation of the peer that uses the same peer ID.
+type liveness struct {
+	clock clock.Clock
+
+	mutex        sync.Mutex
+	expiresAt    map[ident.PeerID]time.Time
+	incarnations map[ident.PeerID]uint64
+	observer     func(ident.PeerID)
+}
+
+// newLiveness returns a new liveness tracker that uses clk to expire presence
+// announcements.
+func newLiveness(clk clock.Clock) *liveness {
+	return &liveness{
+		clock:        clk,
+		expiresAt:    map[ident.PeerID]time.Time{},
+		incarnations: map[ident.PeerID]uint64{},
+	}
+}
+
+// Seen records a presence announcement from the given incarnation of peerID.
+//
+// If the announcement is from a different incarnation than the previous one,
+// the previous incarnation is presumed to have stopped, and the observer is
+// notified.
+func (l *liveness) Seen(peerID ident.PeerID, incarnation uint64) {
+	l.mutex.Lock()
+
+	l.expiresAt[peerID] = l.clock.Now().Add(presenceTTL)
+
+	prev, ok := l.incarnations[peerID]
+	replaced := ok && prev != incarnation && prev != 0 && incarnation != 0
+	l.incarnations[peerID] = incarnation
+
+	observer := l.observer
+
+	l.mutex.Unlock()
+
+	if replaced && observer != nil {
+		observer(peerID)
+	}
+}
+
+// Observe registers fn to be called for each peer that is presumed to have
+// stopped. It replaces any previously registered function.
+func (l *liveness) Observe(fn func(ident.PeerID)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.observer = fn
+}
+
+// Expire forgets the peers whose most recent announcement has expired and
+// notifies the observer of each one.
+func (l *liveness) Expire() {
+	l.mutex.Lock()
+
+	now := l.clock.Now()
+	observer := l.observer
+	var lost []ident.PeerID
+
+	for id, expiresAt := range l.expiresAt {
+		if now.After(expiresAt) {
+			delete(l.expiresAt, id)
+			delete(l.incarnations, id)
+			lost = append(lost, id)
+		}
+	}
+
+	l.mutex.Unlock()
+
+	if observer == nil {
+		return
+	}
+
+	for _, id := range lost {
+		observer(id)
+	}
+}
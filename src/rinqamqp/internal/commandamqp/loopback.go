@@ -0,0 +1,144 @@
+package commandamqp
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
+	"github.com/streadway/amqp"
+)
+
+// loopback passes command requests from an invoker directly to the server of
+// the same peer, rather than publishing them to the broker.
+type loopback struct {
+	mutex  sync.RWMutex
+	server *server // nil if the server is not running
+}
+
+// Attach sets the server that handles requests passed via the loopback.
+func (l *loopback) Attach(s *server) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.server = s
+}
+
+// Detach removes s from the loopback, such that requests are published to the
+// broker instead.
+func (l *loopback) Detach(s *server) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.server == s {
+		l.server = nil
+	}
+}
+
+// Call passes a unicast command request to the local server, which sends the
+// response on reply.
+//
+// It returns false if the request was not accepted by the server, in which case
+// it must be published to the broker.
+func (l *loopback) Call(
+	ctx context.Context,
+	msg *amqp.Publishing,
+	reply chan<- *amqp.Delivery,
+) bool {
+	l.mutex.RLock()
+	s := l.server
+	l.mutex.RUnlock()
+
+	if s == nil {
+		return false
+	}
+
+	if err := amqputil.PackSpanContext(ctx, msg); err != nil {
+		return false
+	}
+
+	amqputil.PackBaggage(ctx, msg)
+	amqputil.PackHeaders(ctx, msg)
+
+	d := loopbackDelivery(unicastExchange, s.peerID.String(), msg, reply)
+
+	return s.deliverLocal(d)
+}
+
+// Cancel cancels a request that was passed to the local server by Call(), if
+// it is still being handled.
+func (l *loopback) Cancel(msgID ident.MessageID) {
+	l.mutex.RLock()
+	s := l.server
+	l.mutex.RUnlock()
+
+	if s != nil {
+		s.cancel(msgID)
+	}
+}
+
+// loopbackAcknowledger is the acknowledger for requests that are passed via the
+// loopback. There is no broker involved, so acknowledgements are no-ops.
+type loopbackAcknowledger struct {
+	reply chan<- *amqp.Delivery // receives the response
+}
+
+func (a *loopbackAcknowledger) Ack(tag uint64, multiple bool) error {
+	return nil
+}
+
+func (a *loopbackAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	return nil
+}
+
+func (a *loopbackAcknowledger) Reject(tag uint64, requeue bool) error {
+	return nil
+}
+
+// loopbackReply returns the channel on which the response to msg is sent, or
+// nil if msg was delivered by the broker.
+func loopbackReply(msg *amqp.Delivery) chan<- *amqp.Delivery {
+	if a, ok := msg.Acknowledger.(*loopbackAcknowledger); ok {
+		return a.reply
+	}
+
+	return nil
+}
+
+// loopbackDelivery returns a delivery equivalent to msg having been published
+// to exchange with the given routing key.
+//
+// The body is copied, as the payload it was taken from may be closed by the
+// sender before the recipient is done with it. If reply is non-nil, the
+// response to the delivery is sent on reply.
+func loopbackDelivery(
+	exchange string,
+	key string,
+	msg *amqp.Publishing,
+	reply chan<- *amqp.Delivery,
+) amqp.Delivery {
+	d := amqp.Delivery{
+		Headers:         msg.Headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    msg.DeliveryMode,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		Expiration:      msg.Expiration,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		UserId:          msg.UserId,
+		AppId:           msg.AppId,
+		Exchange:        exchange,
+		RoutingKey:      key,
+		Body:            append([]byte(nil), msg.Body...),
+	}
+
+	if reply != nil {
+		d.Acknowledger = &loopbackAcknowledger{reply}
+	}
+
+	return d
+}
@@ -3,6 +3,7 @@ package commandamqp
 import (
 	"errors"
 	"fmt"
+	"strconv"
 
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/rinq/rinq-go/src/internal/opentr"
@@ -23,6 +24,15 @@ const (
 	// errorResponse is the AMQP message type used for call responses indicating
 	// unepected error or internal error.
 	errorResponse = "e"
+
+	// consumedNotice is the AMQP message type used to inform the invoker that
+	// a request with the diagnosticsHeader has been consumed by a server.
+	consumedNotice = "nc"
+
+	// runningNotice is the AMQP message type used to inform the invoker that
+	// the handler of a request with the diagnosticsHeader was still running
+	// when the server's deadline passed.
+	runningNotice = "nr"
 )
 
 const (
@@ -41,6 +51,21 @@ const (
 	// failureMessageHeader holds the error message in command responses with
 	// the "failureResponse" type.
 	failureMessageHeader = "m"
+
+	// failureCodeHeader holds the failure code in command responses with the
+	// "failureResponse" type, if it is non-zero.
+	failureCodeHeader = "fc"
+
+	// failureFieldsHeader holds the failure fields in command responses with
+	// the "failureResponse" type, if there are any.
+	failureFieldsHeader = "ff"
+
+	// versionHeader specifies the API version in versioned command requests.
+	versionHeader = "v"
+
+	// diagnosticsHeader asks the server to send notices describing the
+	// progress of a correlated command request.
+	diagnosticsHeader = "dg"
 )
 
 type replyMode string
@@ -84,6 +109,45 @@ func unpackNamespaceAndCommand(msg *amqp.Delivery) (ns string, cmd string, err e
 	return
 }
 
+// routingKey returns the key used to route command requests for the given
+// tenant, namespace and API version. Unversioned requests are routed using the
+// namespace alone. Namespaces can not contain an "@" character, so the key for
+// a versioned request can never collide with that of another namespace.
+func routingKey(tenant, ns string, version uint) string {
+	key := ns
+	if version != 0 {
+		key += "@v" + strconv.FormatUint(uint64(version), 10)
+	}
+
+	return amqputil.TenantKey(tenant, key)
+}
+
+func packVersion(msg *amqp.Publishing, version uint) {
+	if version == 0 {
+		return
+	}
+
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+
+	msg.Headers[versionHeader] = int64(version)
+}
+
+func unpackVersion(msg *amqp.Delivery) (uint, error) {
+	v, ok := msg.Headers[versionHeader]
+	if !ok {
+		return 0, nil
+	}
+
+	version, ok := v.(int64)
+	if !ok || version < 0 {
+		return 0, errors.New("version header is not a positive integer")
+	}
+
+	return uint(version), nil
+}
+
 func packReplyMode(msg *amqp.Publishing, m replyMode) {
 	msg.ReplyTo = string(m)
 }
@@ -92,15 +156,34 @@ func unpackReplyMode(msg *amqp.Delivery) replyMode {
 	return replyMode(msg.ReplyTo)
 }
 
+func packDiagnostics(msg *amqp.Publishing) {
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+
+	msg.Headers[diagnosticsHeader] = true
+}
+
+func unpackDiagnostics(msg *amqp.Delivery) bool {
+	v, _ := msg.Headers[diagnosticsHeader].(bool)
+	return v
+}
+
+func isNotice(msg *amqp.Delivery) bool {
+	return msg.Type == consumedNotice || msg.Type == runningNotice
+}
+
 func packRequest(
 	msg *amqp.Publishing,
 	traceID string,
 	ns string,
 	cmd string,
+	version uint,
 	p *rinq.Payload,
 	m replyMode,
 ) {
 	packNamespaceAndCommand(msg, ns, cmd)
+	packVersion(msg, version)
 	packReplyMode(msg, m)
 	amqputil.PackTrace(msg, traceID)
 	msg.Body = p.Bytes()
@@ -129,6 +212,19 @@ func packErrorResponse(msg *amqp.Publishing, err error) {
 			msg.Headers[failureMessageHeader] = f.Message
 		}
 
+		if f.Code != 0 {
+			msg.Headers[failureCodeHeader] = int64(f.Code)
+		}
+
+		if len(f.Fields) != 0 {
+			fields := amqp.Table{}
+			for k, v := range f.Fields {
+				fields[k] = v
+			}
+
+			msg.Headers[failureFieldsHeader] = fields
+		}
+
 	} else {
 		msg.Type = errorResponse
 		msg.Body = []byte(err.Error())
@@ -147,12 +243,23 @@ func unpackResponse(msg *amqp.Delivery) (*rinq.Payload, error) {
 		}
 
 		failureMessage, _ := msg.Headers[failureMessageHeader].(string)
+		failureCode, _ := msg.Headers[failureCodeHeader].(int64)
+
+		var failureFields map[string]string
+		if t, ok := msg.Headers[failureFieldsHeader].(amqp.Table); ok {
+			failureFields = make(map[string]string, len(t))
+			for k, v := range t {
+				failureFields[k], _ = v.(string)
+			}
+		}
 
 		payload := rinq.NewPayloadFromBytes(msg.Body)
 		return payload, rinq.Failure{
 			Type:    failureType,
 			Message: failureMessage,
 			Payload: payload,
+			Code:    int(failureCode),
+			Fields:  failureFields,
 		}
 
 	case errorResponse:
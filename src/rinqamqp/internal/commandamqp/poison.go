@@ -0,0 +1,117 @@
+package commandamqp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/trace"
+	"github.com/streadway/amqp"
+)
+
+const (
+	// parkedRequestQueue is the queue used to hold "poison" requests, those
+	// that cause the command handler to panic repeatedly.
+	parkedRequestQueue = "cmd.parked"
+
+	// poisonThreshold is the number of times a request may be delivered to a
+	// handler that panics before it is parked.
+	poisonThreshold = 2
+
+	// parkedReasonHeader, parkedPeerHeader, parkedExchangeHeader and
+	// parkedRoutingKeyHeader describe why and from where a request was parked.
+	parkedReasonHeader     = "x-parked-reason"
+	parkedPeerHeader       = "x-parked-peer"
+	parkedExchangeHeader   = "x-parked-exchange"
+	parkedRoutingKeyHeader = "x-parked-routing-key"
+)
+
+// handlerPanic describes a panic that occurred within a command handler.
+type handlerPanic struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p *handlerPanic) String() string {
+	return fmt.Sprint(p.Value)
+}
+
+// internalError returns the failure sent to the caller when a command handler
+// panics. It includes the trace ID so that the failure can be correlated with
+// the server's logs.
+func internalError(ctx context.Context) rinq.Failure {
+	return rinq.Failure{
+		Type:    rinq.InternalErrorFailureType,
+		Message: fmt.Sprintf("the command handler failed unexpectedly [%s]", trace.Get(ctx)),
+	}
+}
+
+// declareParkedQueue declares the queue used to hold parked requests.
+func declareParkedQueue(channel *amqp.Channel, prefix string) error {
+	_, err := channel.QueueDeclare(
+		prefix+parkedRequestQueue,
+		true,  // durable
+		false, // autoDelete
+		false, // exclusive,
+		false, // noWait
+		nil,   // args
+	)
+
+	return err
+}
+
+// deliveryCount returns the number of times msg has been delivered, including
+// the current delivery.
+//
+// Quorum queues report the exact number of previous deliveries, otherwise the
+// best we can do is to distinguish between first and subsequent deliveries.
+func deliveryCount(msg *amqp.Delivery) uint {
+	switch n := msg.Headers["x-delivery-count"].(type) {
+	case int64:
+		return uint(n) + 1
+	case int32:
+		return uint(n) + 1
+	}
+
+	if msg.Redelivered {
+		return 2
+	}
+
+	return 1
+}
+
+// isPoison returns true if msg has been delivered enough times that it should
+// be parked rather than requeued.
+func isPoison(msg *amqp.Delivery) bool {
+	return deliveryCount(msg) >= poisonThreshold
+}
+
+// packParked returns a copy of msg suitable for publishing to the parked
+// request queue. prefix is the prefix that was removed from the name of the
+// exchange that msg was delivered from.
+func packParked(msg *amqp.Delivery, prefix, peer string, p *handlerPanic) amqp.Publishing {
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+
+	headers[parkedReasonHeader] = p.String()
+	headers[parkedPeerHeader] = peer
+	headers[parkedExchangeHeader] = prefix + msg.Exchange
+	headers[parkedRoutingKeyHeader] = msg.RoutingKey
+
+	return amqp.Publishing{
+		Headers:         headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		AppId:           msg.AppId,
+		Body:            msg.Body,
+	}
+}
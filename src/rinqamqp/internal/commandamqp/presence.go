@@ -0,0 +1,176 @@
+package commandamqp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/streadway/amqp"
+)
+
+const (
+	// presenceInterval is how often each server announces the namespaces that
+	// it is listening to.
+	presenceInterval = 10 * time.Second
+
+	// presenceTTL is how long an announcement remains valid. It is longer than
+	// presenceInterval so that a single late announcement does not cause the
+	// peer to be forgotten.
+	presenceTTL = 3 * presenceInterval
+)
+
+const (
+	// namespacesHeader holds the list of namespaces in presence announcements.
+	namespacesHeader = "ns"
+
+	// capacityHeader holds the number of command workers in presence
+	// announcements.
+	capacityHeader = "cap"
+
+	// groupsHeader holds the list of groups that the peer belongs to in
+	// presence announcements.
+	groupsHeader = "grp"
+
+	// revisionHeader holds the peer's protocol revision in presence
+	// announcements.
+	revisionHeader = "rev"
+
+	// featuresHeader holds the list of optional wire features that the peer
+	// supports in presence announcements.
+	featuresHeader = "ft"
+
+	// incarnationHeader holds the peer's incarnation in presence
+	// announcements.
+	incarnationHeader = "inc"
+)
+
+// presence is an announcement of the namespaces that a peer is listening to.
+type presence struct {
+	PeerID      ident.PeerID
+	Incarnation uint64 // zero if the peer does not announce its incarnation
+	Namespaces  []string
+	Capacity    uint
+	Groups      []string
+	Revision    uint
+	Features    []string
+}
+
+// presenceQueue returns the name of the queue used for presence announcements.
+func presenceQueue(prefix string, id ident.PeerID) string {
+	return prefix + id.ShortString() + ".pres"
+}
+
+func packPresence(msg *amqp.Publishing, p presence) {
+	msg.AppId = p.PeerID.String()
+	msg.Expiration = strconv.FormatInt(int64(presenceTTL/time.Millisecond), 10)
+	msg.Headers = amqp.Table{
+		namespacesHeader: packStrings(p.Namespaces),
+		capacityHeader:   int64(p.Capacity),
+		revisionHeader:   int64(p.Revision),
+		featuresHeader:   packStrings(p.Features),
+	}
+
+	if p.Incarnation != 0 {
+		msg.Headers[incarnationHeader] = int64(p.Incarnation)
+	}
+
+	if len(p.Groups) != 0 {
+		msg.Headers[groupsHeader] = packStrings(p.Groups)
+	}
+}
+
+func unpackPresence(msg *amqp.Delivery) (p presence, err error) {
+	p.PeerID, err = ident.ParsePeerID(msg.AppId)
+	if err != nil {
+		return
+	}
+
+	p.Namespaces, err = unpackStrings(msg, namespacesHeader, "namespaces")
+	if err != nil {
+		return
+	}
+
+	// announcements from peers that do not belong to any groups, including
+	// those from older versions, do not include the groups header.
+	if _, ok := msg.Headers[groupsHeader]; ok {
+		p.Groups, err = unpackStrings(msg, groupsHeader, "groups")
+		if err != nil {
+			return
+		}
+	}
+
+	// announcements from peers that predate capability advertisement do not
+	// include the revision or features headers, they are treated as revision
+	// zero, with no optional features.
+	if v, ok := msg.Headers[revisionHeader]; ok {
+		revision, ok := v.(int64)
+		if !ok || revision < 0 {
+			err = errors.New("revision header is not a positive integer")
+			return
+		}
+
+		p.Revision = uint(revision)
+	}
+
+	// announcements from peers that predate incarnations do not include the
+	// incarnation header, so replacements of those peers are not detected.
+	if v, ok := msg.Headers[incarnationHeader]; ok {
+		incarnation, ok := v.(int64)
+		if !ok {
+			err = errors.New("incarnation header is not an integer")
+			return
+		}
+
+		p.Incarnation = uint64(incarnation)
+	}
+
+	if _, ok := msg.Headers[featuresHeader]; ok {
+		p.Features, err = unpackStrings(msg, featuresHeader, "features")
+		if err != nil {
+			return
+		}
+	}
+
+	capacity, ok := msg.Headers[capacityHeader].(int64)
+	if !ok || capacity < 0 {
+		err = errors.New("capacity header is not a positive integer")
+		return
+	}
+
+	p.Capacity = uint(capacity)
+
+	return
+}
+
+// packStrings returns a header value containing the elements of s.
+func packStrings(s []string) []interface{} {
+	v := make([]interface{}, len(s))
+	for i, e := range s {
+		v[i] = e
+	}
+
+	return v
+}
+
+// unpackStrings returns the elements of the header h, which must be an array
+// of strings. name describes the header in error messages.
+func unpackStrings(msg *amqp.Delivery, h, name string) ([]string, error) {
+	values, ok := msg.Headers[h].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s header is not an array", name)
+	}
+
+	var s []string
+	for _, v := range values {
+		e, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s header contains a non-string value", name)
+		}
+
+		s = append(s, e)
+	}
+
+	return s, nil
+}
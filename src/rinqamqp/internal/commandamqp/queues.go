@@ -1,31 +1,51 @@
 package commandamqp
 
 import (
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
 	"github.com/streadway/amqp"
 )
 
 // balancedRequestQueue returns the name of the queue used for balanced
 // command requests in the given namespace.
-func balancedRequestQueue(namespace string) string {
-	return "cmd." + namespace
+func balancedRequestQueue(prefix, namespace string) string {
+	return prefix + "cmd." + namespace
 }
 
 // requestQueue returns the name of the queue used for unicast and multicast
 // command requests.
-func requestQueue(id ident.PeerID) string {
-	return id.ShortString() + ".req"
+func requestQueue(prefix string, id ident.PeerID) string {
+	return prefix + id.ShortString() + ".req"
 }
 
 // responseQueue returns the name of the queue used for command responses.
-func responseQueue(id ident.PeerID) string {
-	return id.ShortString() + ".rsp"
+func responseQueue(prefix string, id ident.PeerID) string {
+	return prefix + id.ShortString() + ".rsp"
+}
+
+// scheduledQueueExpiry is how long the queue for a scheduled request is
+// retained by the broker after the request's scheduled time. During this time,
+// attempts to cancel the request report that it has already been sent.
+const scheduledQueueExpiry = 1 * time.Minute
+
+// scheduledRequestQueue returns the name of the queue that holds a balanced
+// command request until its scheduled time.
+func scheduledRequestQueue(prefix string, id ident.MessageID) string {
+	return prefix + "cmd.sched." + id.String()
 }
 
 // queueSet declares AMQP resources for queuing balanced command requests.
 type queueSet struct {
+	tenant   string
+	prefix   string
+	options  map[string]options.ListenOptions // map of namespace to queue options
+	defaults options.ListenOptions            // options for namespaces not in the map
+
 	mutex  sync.Mutex
 	queues map[string]string
 }
@@ -40,15 +60,19 @@ func (s *queueSet) Get(channel *amqp.Channel, namespace string) (string, error)
 		return queue, nil
 	}
 
-	queue := balancedRequestQueue(namespace)
+	queue := balancedRequestQueue(s.prefix, namespace)
+	opts, ok := s.options[s.namespaceOf(namespace)]
+	if !ok {
+		opts = s.defaults
+	}
 
 	if _, err := channel.QueueDeclare(
 		queue,
-		true,  // durable
-		false, // autoDelete
-		false, // exclusive,
-		false, // noWait
-		amqp.Table{"x-max-priority": priorityCount},
+		!opts.Transient, // durable
+		false,           // autoDelete
+		false,           // exclusive,
+		false,           // noWait
+		queueArguments(opts),
 	); err != nil {
 		return "", err
 	}
@@ -56,7 +80,7 @@ func (s *queueSet) Get(channel *amqp.Channel, namespace string) (string, error)
 	if err := channel.QueueBind(
 		queue,
 		namespace,
-		balancedExchange,
+		s.prefix+balancedExchange,
 		false, // noWait
 		nil,   // args
 	); err != nil {
@@ -70,3 +94,45 @@ func (s *queueSet) Get(channel *amqp.Channel, namespace string) (string, error)
 
 	return queue, nil
 }
+
+// namespaceOf returns the namespace that the given routing key is used for,
+// without the tenant or API version.
+func (s *queueSet) namespaceOf(key string) string {
+	ns := strings.TrimPrefix(key, amqputil.TenantKey(s.tenant, ""))
+
+	if i := strings.IndexByte(ns, '@'); i != -1 {
+		ns = ns[:i]
+	}
+
+	return ns
+}
+
+// queueArguments returns the arguments used to declare a queue for balanced
+// command requests with the given options.
+func queueArguments(opts options.ListenOptions) amqp.Table {
+	args := amqp.Table{}
+
+	if opts.Quorum {
+		args["x-queue-type"] = "quorum"
+	} else {
+		args["x-max-priority"] = priorityCount
+	}
+
+	if opts.Lazy {
+		args["x-queue-mode"] = "lazy"
+	}
+
+	if opts.MaxLength != 0 {
+		args["x-max-length"] = int64(opts.MaxLength)
+	}
+
+	if opts.MessageTTL != 0 {
+		args["x-message-ttl"] = int64(opts.MessageTTL / time.Millisecond)
+	}
+
+	if opts.DeliveryLimit != 0 {
+		args["x-delivery-limit"] = int64(opts.DeliveryLimit)
+	}
+
+	return args
+}
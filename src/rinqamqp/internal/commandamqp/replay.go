@@ -0,0 +1,108 @@
+package commandamqp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rinq/rinq-go/src/rinq/clock"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/streadway/amqp"
+)
+
+// replayCache retains the responses sent for recent command requests so that
+// they can be re-sent, rather than invoking the handler again, if the request
+// is redelivered by the broker.
+type replayCache struct {
+	window time.Duration
+	clock  clock.Clock
+
+	mutex   sync.Mutex
+	entries map[ident.MessageID]replayEntry
+	order   []ident.MessageID // in order of insertion, and hence of expiry
+}
+
+// replayEntry is an entry in a replayCache.
+type replayEntry struct {
+	Response  amqp.Publishing
+	ExpiresAt time.Time
+}
+
+// newReplayCache returns a replay cache that retains responses for the given
+// window, as measured by clk. It returns nil if window is zero, disabling
+// duplicate suppression.
+func newReplayCache(window time.Duration, clk clock.Clock) *replayCache {
+	if window == 0 {
+		return nil
+	}
+
+	return &replayCache{
+		window:  window,
+		clock:   clk,
+		entries: map[ident.MessageID]replayEntry{},
+	}
+}
+
+// Add records msg as the response sent for the request with the given ID.
+func (c *replayCache) Add(msgID ident.MessageID, msg *amqp.Publishing) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.clock.Now()
+	c.expire(now)
+
+	if _, ok := c.entries[msgID]; ok {
+		return
+	}
+
+	// Copy the headers, as they are modified when the response is published,
+	// and the body, as it is usually the buffer of a pooled payload that is
+	// recycled once the payload is closed.
+	res := *msg
+	res.Body = append([]byte(nil), msg.Body...)
+	res.Headers = amqp.Table{}
+	for k, v := range msg.Headers {
+		res.Headers[k] = v
+	}
+
+	c.order = append(c.order, msgID)
+	c.entries[msgID] = replayEntry{res, now.Add(c.window)}
+}
+
+// Get returns the response sent for the request with the given ID, if it is
+// still retained.
+func (c *replayCache) Get(msgID ident.MessageID) (amqp.Publishing, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.expire(c.clock.Now())
+
+	entry, ok := c.entries[msgID]
+	if !ok {
+		return amqp.Publishing{}, false
+	}
+
+	res := entry.Response
+	res.Headers = amqp.Table{}
+	for k, v := range entry.Response.Headers {
+		res.Headers[k] = v
+	}
+
+	return res, true
+}
+
+// expire removes any entries that have expired as of now. It assumes c.mutex
+// is locked.
+func (c *replayCache) expire(now time.Time) {
+	n := 0
+
+	for _, msgID := range c.order {
+		if c.entries[msgID].ExpiresAt.After(now) {
+			break
+		}
+
+		delete(c.entries, msgID)
+		n++
+	}
+
+	c.order = c.order[n:]
+}
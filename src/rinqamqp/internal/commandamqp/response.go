@@ -16,24 +16,36 @@ import (
 type response struct {
 	context  context.Context
 	channels amqputil.ChannelPool
+	prefix   string
 	request  rinq.Request
+	reply    chan<- *amqp.Delivery // receives the response directly, nil unless passed via the loopback
+	replay   *replayCache          // may be nil
 
 	mutex     sync.RWMutex
 	replyMode replyMode
 	isClosed  bool
+	timedOut  bool  // true if a timeout failure was sent on the handler's behalf
+	err       error // the error sent in response, if any
+	sendErr   error // the error that occurred when sending the response, if any
 }
 
 func newResponse(
 	ctx context.Context,
 	channels amqputil.ChannelPool,
+	prefix string,
 	request rinq.Request,
 	replyMode replyMode,
-) (rinq.Response, func() bool) {
+	reply chan<- *amqp.Delivery,
+	replay *replayCache,
+) (*response, func() bool) {
 	r := &response{
 		context:   ctx,
 		channels:  channels,
+		prefix:    prefix,
 		request:   request,
 		replyMode: replyMode,
+		reply:     reply,
+		replay:    replay,
 	}
 
 	return r, r.finalize
@@ -70,7 +82,9 @@ func (r *response) Done(payload *rinq.Payload) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if r.isClosed {
+	if r.timedOut {
+		return
+	} else if r.isClosed {
 		panic("responder is already closed")
 	}
 
@@ -83,12 +97,15 @@ func (r *response) Error(err error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if r.isClosed {
+	if r.timedOut {
+		return
+	} else if r.isClosed {
 		panic("responder is already closed")
 	}
 
 	msg := &amqp.Publishing{}
 	packErrorResponse(msg, err)
+	r.err = err
 	r.respond(msg)
 }
 
@@ -118,6 +135,15 @@ func (r *response) Close() bool {
 	return true
 }
 
+// Err returns the error sent in response to the request, or nil if the response
+// was successful or has not been sent.
+func (r *response) Err() error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.err
+}
+
 func (r *response) finalize() bool {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -131,23 +157,78 @@ func (r *response) finalize() bool {
 	return false
 }
 
+// timeout sends err in response to the request because the handler has
+// exceeded its maximum execution time. Any response subsequently written by the
+// handler is discarded. It returns false if the handler has already responded.
+func (r *response) timeout(err rinq.Failure) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.isClosed {
+		return false
+	}
+
+	msg := &amqp.Publishing{}
+	packErrorResponse(msg, err)
+	r.err = err
+	r.timedOut = true
+	r.respond(msg)
+
+	return true
+}
+
+// TimedOut returns true if a timeout failure was sent in response to the
+// request.
+func (r *response) TimedOut() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.timedOut
+}
+
+// resend sends a response that was previously sent for the same request.
+func (r *response) resend(msg amqp.Publishing) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.isClosed = true
+	r.sendErr = r.publish(&msg)
+}
+
+// SendErr returns the error that occurred when sending the response, or nil if
+// it was sent successfully or has not been sent.
+func (r *response) SendErr() error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.sendErr
+}
+
 func (r *response) respond(msg *amqp.Publishing) {
 	r.isClosed = true
 
+	if r.replay != nil {
+		r.replay.Add(r.request.ID, msg)
+	}
+
+	r.sendErr = r.publish(msg)
+}
+
+func (r *response) publish(msg *amqp.Publishing) error {
 	if r.replyMode == replyNone {
-		return
+		return nil
 	}
 
 	if _, err := amqputil.PackDeadline(r.context, msg); err != nil {
 		// the context deadline has already passed
-		return
+		return nil
 	}
 
-	channel, err := r.channels.Get()
-	if err != nil {
-		panic(err)
+	if r.reply != nil {
+		d := loopbackDelivery(responseExchange, r.request.ID.String(), msg, nil)
+		r.reply <- &d
+		return nil
 	}
-	defer r.channels.Put(channel)
 
 	// TODO: is this necessary for correlated responses?
 	amqputil.PackTrace(msg, trace.Get(r.context))
@@ -156,20 +237,15 @@ func (r *response) respond(msg *amqp.Publishing) {
 		packNamespaceAndCommand(msg, r.request.Namespace, r.request.Command)
 		packReplyMode(msg, r.replyMode)
 
-		err = amqputil.PackSpanContext(r.context, msg)
-		if err != nil {
-			panic(err)
+		if err := amqputil.PackSpanContext(r.context, msg); err != nil {
+			return err
 		}
 	}
 
-	err = channel.Publish(
-		responseExchange,
+	return amqputil.Publish(
+		r.channels,
+		r.prefix+responseExchange,
 		r.request.ID.String(),
-		false, // mandatory,
-		false, // immediate,
 		*msg,
 	)
-	if err != nil {
-		panic(err)
-	}
 }
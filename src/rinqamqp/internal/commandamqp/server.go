@@ -2,31 +2,64 @@ package commandamqp
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/jmalloc/twelf/src/twelf"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
 	"github.com/rinq/rinq-go/src/internal/command"
+	"github.com/rinq/rinq-go/src/internal/namespaces"
+	"github.com/rinq/rinq-go/src/internal/opentr"
 	"github.com/rinq/rinq-go/src/internal/revisions"
+	"github.com/rinq/rinq-go/src/internal/sampling"
 	"github.com/rinq/rinq-go/src/internal/service"
 	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/clock"
+	"github.com/rinq/rinq-go/src/rinq/dedupe"
 	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/metrics"
+	"github.com/rinq/rinq-go/src/rinq/trace"
 	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
 	"github.com/streadway/amqp"
 )
 
+// adaptiveInterval is how often the pre-fetch count is adjusted when adaptive
+// concurrency is enabled.
+const adaptiveInterval = 1 * time.Second
+
 type server struct {
 	service.Service
 	sm *service.StateMachine
 
-	peerID    ident.PeerID
-	preFetch  uint
-	revisions revisions.Store
-	queues    *queueSet
-	channels  amqputil.ChannelPool
-	logger    twelf.Logger
-	tracer    opentracing.Tracer
+	peerID      ident.PeerID
+	incarnation uint64 // distinguishes this instance from others with the same peer ID
+	preFetch    uint
+	tenant      string
+	prefix      string // prepended to exchange and queue names
+	revisions   revisions.Store
+	queues      *queueSet
+	loopback    *loopback
+	channels    amqputil.ChannelPool
+	replay      *replayCache // nil if duplicate suppression is disabled
+	hopMargin   time.Duration
+	groups      []string
+	slow        time.Duration                // slow handler threshold, zero if disabled
+	timeouts    map[string]time.Duration     // map of namespace to maximum handler execution time
+	adaptive    *amqputil.PrefetchController // nil if adaptive concurrency is disabled
+	atLeastOnce map[string]struct{}          // namespaces with at-least-once execution
+	dedupe      dedupe.Store                 // detects requests that have already been handled
+	clock       clock.Clock
+	logger      twelf.Logger
+	tracer      opentracing.Tracer
+	sampler     *sampling.Sampler
+	metrics     metrics.Recorder
 
 	parentCtx context.Context // parent of all contexts passed to handlers
 	cancelCtx func()          // cancels parentCtx when the server stops
@@ -37,33 +70,83 @@ type server struct {
 	amqpClosed chan *amqp.Error
 	pending    uint // number of requests currently being handled
 
-	mutex    sync.RWMutex                   // guards handlers so handler can be read in dispatch() goroutine
-	handlers map[string]rinq.CommandHandler // map of namespace to handler
+	inFlight int32 // number of handlers currently executing, atomic
+
+	mutex    sync.RWMutex            // guards handlers so handler can be read in dispatch() goroutine
+	handlers map[string]registration // map of routing key to handler
+
+	requestsMutex sync.Mutex
+	requests      map[string]func() // map of message ID to context cancel func, for correlated requests
+}
+
+// registration is a command handler along with the namespace and version that
+// it handles.
+type registration struct {
+	Namespace command.Namespace
+	Handler   rinq.CommandHandler
 }
 
 // newServer creates, starts and returns a new server.
 func newServer(
 	peerID ident.PeerID,
+	incarnation uint64,
 	preFetch uint,
+	tenant string,
+	prefix string,
 	revs revisions.Store,
 	queues *queueSet,
+	loop *loopback,
 	channels amqputil.ChannelPool,
+	replayWindow time.Duration,
+	hopMargin time.Duration,
+	groups []string,
+	slowThreshold time.Duration,
+	handlerTimeouts map[string]time.Duration,
+	adaptiveTarget time.Duration,
+	atLeastOnce []string,
+	store dedupe.Store,
 	logger twelf.Logger,
 	tracer opentracing.Tracer,
+	sampler *sampling.Sampler,
+	recorder metrics.Recorder,
+	clk clock.Clock,
 ) (command.Server, error) {
 	s := &server{
-		peerID:    peerID,
-		preFetch:  preFetch,
-		revisions: revs,
-		queues:    queues,
-		channels:  channels,
-		logger:    logger,
-		tracer:    tracer,
+		peerID:      peerID,
+		incarnation: incarnation,
+		preFetch:    preFetch,
+		tenant:      tenant,
+		prefix:      prefix,
+		revisions:   revs,
+		queues:      queues,
+		loopback:    loop,
+		channels:    channels,
+		replay:      newReplayCache(replayWindow, clk),
+		hopMargin:   hopMargin,
+		groups:      groups,
+		slow:        slowThreshold,
+		timeouts:    handlerTimeouts,
+		atLeastOnce: map[string]struct{}{},
+		dedupe:      store,
+		clock:       clk,
+		logger:      logger,
+		tracer:      tracer,
+		sampler:     sampler,
+		metrics:     recorder,
 
 		deliveries: make(chan amqp.Delivery, preFetch),
 		amqpClosed: make(chan *amqp.Error, 1),
 
-		handlers: map[string]rinq.CommandHandler{},
+		handlers: map[string]registration{},
+		requests: map[string]func(){},
+	}
+
+	for _, ns := range atLeastOnce {
+		s.atLeastOnce[ns] = struct{}{}
+	}
+
+	if adaptiveTarget != 0 {
+		s.adaptive = amqputil.NewPrefetchController(adaptiveTarget, preFetch)
 	}
 
 	s.sm = service.NewStateMachine(s.run, s.finalize)
@@ -73,64 +156,199 @@ func newServer(
 		return nil, err
 	}
 
+	s.loopback.Attach(s)
+
 	go s.sm.Run()
 
 	return s, nil
 }
 
-func (s *server) Listen(ns string, h rinq.CommandHandler) (added bool, err error) {
+func (s *server) Listen(ns string, version uint, h rinq.CommandHandler) (added bool, err error) {
+	key := routingKey(s.tenant, ns, version)
+
 	err = s.sm.Do(func() error {
 		s.mutex.Lock()
 		defer s.mutex.Unlock()
 
-		if _, ok := s.handlers[ns]; ok {
-			s.handlers[ns] = h
+		_, ok := s.handlers[key]
+		s.handlers[key] = registration{command.Namespace{Name: ns, Version: version}, h}
+
+		if ok {
 			return nil
 		}
 
-		s.handlers[ns] = h
 		added = true
 
-		return s.bind(ns)
+		if err := s.bind(key); err != nil {
+			return err
+		}
+
+		return s.announce()
+	})
+
+	return
+}
+
+func (s *server) ListenAll(handlers map[string]rinq.CommandHandler) (added []string, err error) {
+	names := make([]string, 0, len(handlers))
+	for ns := range handlers {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+
+	err = s.sm.Do(func() error {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		// all handlers are registered before any consumers are started, so
+		// that no requests are dispatched while only some are present.
+		replaced := map[string]registration{}
+		var keys []string
+
+		for _, ns := range names {
+			key := routingKey(s.tenant, ns, 0)
+
+			if r, ok := s.handlers[key]; ok {
+				replaced[key] = r
+			} else {
+				keys = append(keys, key)
+				added = append(added, ns)
+			}
+
+			s.handlers[key] = registration{command.Namespace{Name: ns}, handlers[ns]}
+		}
+
+		for idx, key := range keys {
+			if err := s.bind(key); err != nil {
+				for _, k := range keys[:idx] {
+					_ = s.unbind(k)
+				}
+
+				for _, k := range keys {
+					delete(s.handlers, k)
+				}
+
+				for k, r := range replaced {
+					s.handlers[k] = r
+				}
+
+				added = nil
+
+				return err
+			}
+		}
+
+		if len(keys) == 0 {
+			return nil
+		}
+
+		return s.announce()
 	})
 
 	return
 }
 
-func (s *server) Unlisten(ns string) (removed bool, err error) {
+// InFlight returns the number of command handlers currently executing.
+func (s *server) InFlight() int {
+	return int(atomic.LoadInt32(&s.inFlight))
+}
+
+func (s *server) Unlisten(ns string, version uint) (removed bool, err error) {
+	key := routingKey(s.tenant, ns, version)
+
 	err = s.sm.Do(func() error {
 		s.mutex.Lock()
 		defer s.mutex.Unlock()
 
-		if _, ok := s.handlers[ns]; !ok {
+		if _, ok := s.handlers[key]; !ok {
 			return nil
 		}
 
 		removed = true
-		delete(s.handlers, ns)
+		delete(s.handlers, key)
+
+		if err := s.unbind(key); err != nil {
+			return err
+		}
 
-		return s.unbind(ns)
+		return s.announce()
 	})
 
 	return
 }
 
-func (s *server) bind(ns string) error {
-	if err := s.channel.QueueBind(
-		requestQueue(s.peerID),
-		ns,
-		multicastExchange,
+func (s *server) UnlistenAll() (removed []command.Namespace, err error) {
+	err = s.sm.Do(func() error {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		for key, r := range s.handlers {
+			// reserved namespaces, such as the one used to serve this peer's
+			// sessions to other peers, are not registered by the application
+			if namespaces.IsReserved(r.Namespace.Name) {
+				continue
+			}
+
+			removed = append(removed, r.Namespace)
+			delete(s.handlers, key)
+
+			if err := s.unbind(key); err != nil {
+				return err
+			}
+		}
+
+		if len(removed) == 0 {
+			return nil
+		}
+
+		return s.announce()
+	})
+
+	sort.Slice(removed, func(i, j int) bool {
+		if removed[i].Name == removed[j].Name {
+			return removed[i].Version < removed[j].Version
+		}
+
+		return removed[i].Name < removed[j].Name
+	})
+
+	return
+}
+
+// bind starts consuming command requests with the given routing key. If it
+// fails, the multicast binding is removed again so that no partial state is
+// left behind.
+func (s *server) bind(key string) (err error) {
+	if err = s.channel.QueueBind(
+		requestQueue(s.prefix, s.peerID),
+		key,
+		s.prefix+multicastExchange,
 		false, // noWait
 		nil,   //  args
 	); err != nil {
 		return err
 	}
 
-	queue, err := s.queues.Get(s.channel, ns)
+	defer func() {
+		if err != nil {
+			_ = s.channel.QueueUnbind(
+				requestQueue(s.prefix, s.peerID),
+				key,
+				s.prefix+multicastExchange,
+				nil, //  args
+			)
+		}
+	}()
+
+	queue, err := s.queues.Get(s.channel, key)
 	if err != nil {
 		return err
 	}
 
+	if err = declareParkedQueue(s.channel, s.prefix); err != nil {
+		return err
+	}
+
 	messages, err := s.channel.Consume(
 		queue,
 		queue, // use queue name as consumer tag
@@ -149,18 +367,19 @@ func (s *server) bind(ns string) error {
 	return nil
 }
 
-func (s *server) unbind(ns string) error {
+// unbind stops consuming command requests with the given routing key.
+func (s *server) unbind(key string) error {
 	if err := s.channel.QueueUnbind(
-		requestQueue(s.peerID),
-		ns,
-		multicastExchange,
+		requestQueue(s.prefix, s.peerID),
+		key,
+		s.prefix+multicastExchange,
 		nil, //  args
 	); err != nil {
 		return err
 	}
 
 	return s.channel.Cancel(
-		balancedRequestQueue(ns), // use queue name as consumer tag
+		balancedRequestQueue(s.prefix, key), // use queue name as consumer tag
 		false, // noWait
 	)
 }
@@ -175,7 +394,7 @@ func (s *server) initialize() error {
 
 	s.channel.NotifyClose(s.amqpClosed)
 
-	queue := requestQueue(s.peerID)
+	queue := requestQueue(s.prefix, s.peerID)
 
 	if _, err := s.channel.QueueDeclare(
 		queue,
@@ -191,13 +410,25 @@ func (s *server) initialize() error {
 	if err := s.channel.QueueBind(
 		queue,
 		s.peerID.String(),
-		unicastExchange,
+		s.prefix+unicastExchange,
 		false, // noWait
 		nil,   // args
 	); err != nil {
 		return err
 	}
 
+	for _, key := range []string{s.peerID.String(), cancelBroadcastKey(s.tenant)} {
+		if err := s.channel.QueueBind(
+			queue,
+			key,
+			s.prefix+cancelExchange,
+			false, // noWait
+			nil,   // args
+		); err != nil {
+			return err
+		}
+	}
+
 	messages, err := s.channel.Consume(
 		queue,
 		queue, // use queue name as consumer tag
@@ -222,11 +453,36 @@ func (s *server) run() (service.State, error) {
 
 	s.parentCtx, s.cancelCtx = context.WithCancel(context.Background())
 
+	ticker := time.NewTicker(presenceInterval)
+	defer ticker.Stop()
+
+	var adjust <-chan time.Time // nil if adaptive concurrency is disabled
+	if s.adaptive != nil {
+		t := time.NewTicker(adaptiveInterval)
+		defer t.Stop()
+		adjust = t.C
+	}
+
 	for {
 		select {
 		case msg := <-s.deliveries:
-			s.pending++
-			go s.dispatch(&msg)
+			batch := amqputil.Drain(msg, s.deliveries, int(s.capacity()))
+			s.pending += uint(len(batch))
+			go s.dispatchBatch(batch)
+
+		case <-adjust:
+			if err := s.adjustPreFetch(); err != nil {
+				return nil, err
+			}
+
+		case <-ticker.C:
+			s.mutex.RLock()
+			err := s.announce()
+			s.mutex.RUnlock()
+
+			if err != nil {
+				return nil, err
+			}
 
 		case req := <-s.sm.Commands:
 			s.sm.Execute(req)
@@ -248,12 +504,12 @@ func (s *server) run() (service.State, error) {
 func (s *server) gracefulStopConsuming() (service.State, error) {
 	logServerStopping(s.logger, s.peerID, s.pending)
 
-	queue := requestQueue(s.peerID)
+	queue := requestQueue(s.prefix, s.peerID)
 
 	if err := s.channel.QueueUnbind(
 		queue,
 		s.peerID.String(),
-		unicastExchange,
+		s.prefix+unicastExchange,
 		nil, // args
 	); err != nil {
 		return nil, err
@@ -270,12 +526,17 @@ func (s *server) gracefulStopConsuming() (service.State, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	for ns := range s.handlers {
-		if err := s.unbind(ns); err != nil {
+	for key := range s.handlers {
+		if err := s.unbind(key); err != nil {
 			return nil, err
 		}
 	}
 
+	// announce that we are no longer listening to any namespaces
+	if err := s.publishPresence(nil); err != nil {
+		return nil, err
+	}
+
 	return s.waitForHandlers, nil
 }
 
@@ -304,6 +565,7 @@ func (s *server) waitForHandlers() (service.State, error) {
 // finalize is the state-machine finalizer, it is called immediately before the
 // Done() channel is closed.
 func (s *server) finalize(err error) error {
+	s.loopback.Detach(s)
 	s.cancelCtx()
 	logServerStop(s.logger, s.peerID, err)
 
@@ -317,9 +579,52 @@ func (s *server) finalize(err error) error {
 	return err
 }
 
+// deliverLocal queues a command request that was passed via the loopback,
+// rather than delivered by the broker. It returns false if the server is
+// stopping.
+func (s *server) deliverLocal(msg amqp.Delivery) bool {
+	select {
+	case <-s.sm.Graceful:
+		return false
+	case <-s.sm.Forceful:
+		return false
+	default:
+	}
+
+	select {
+	case s.deliveries <- msg:
+		return true
+	case <-s.sm.Graceful:
+		return false
+	case <-s.sm.Forceful:
+		return false
+	case <-s.sm.Finalized:
+		return false
+	}
+}
+
+// dispatchBatch dispatches each of the command requests in batch, which were
+// all delivered at once.
+//
+// The source revisions of the requests are looked up together, so that when
+// multicast requests from many sessions arrive together, the state of those
+// sessions is not fetched with a separate request for each.
+func (s *server) dispatchBatch(batch []amqp.Delivery) {
+	var sources map[string]rinq.Revision
+	if len(batch) > 1 {
+		sources = amqputil.GetSources(s.parentCtx, s.revisions, batch)
+	}
+
+	for i := range batch {
+		msg := &batch[i]
+		go s.dispatch(msg, sources[msg.MessageId])
+	}
+}
+
 // dispatch validates an incoming command request and dispatches it the
-// appropriate handler.
-func (s *server) dispatch(msg *amqp.Delivery) {
+// appropriate handler. source is the revision of the session that sent the
+// request, or nil if it has not yet been looked up.
+func (s *server) dispatch(msg *amqp.Delivery, source rinq.Revision) {
 	defer s.sm.DoGraceful(func() error {
 		s.pending--
 		return nil
@@ -333,6 +638,19 @@ func (s *server) dispatch(msg *amqp.Delivery) {
 		return
 	}
 
+	// reject requests from other tenants
+	if err = amqputil.CheckTenant(msg, s.tenant); err != nil {
+		_ = msg.Reject(false) // false = don't requeue
+		logIgnoredMessage(s.logger, s.peerID, msgID, err)
+		return
+	}
+
+	if msg.Exchange == cancelExchange {
+		_ = msg.Ack(false) // false = single message
+		s.cancel(msgID)
+		return
+	}
+
 	// determine namespace + command
 	ns, cmd, err := unpackNamespaceAndCommand(msg)
 	if err != nil {
@@ -341,6 +659,13 @@ func (s *server) dispatch(msg *amqp.Delivery) {
 		return
 	}
 
+	version, err := unpackVersion(msg)
+	if err != nil {
+		_ = msg.Reject(false) // false = don't requeue
+		logIgnoredMessage(s.logger, s.peerID, msgID, err)
+		return
+	}
+
 	spanOpts, err := unpackSpanOptions(msg, s.tracer, ext.SpanKindRPCServer)
 	if err != nil {
 		_ = msg.Reject(false) // false = don't requeue
@@ -348,25 +673,28 @@ func (s *server) dispatch(msg *amqp.Delivery) {
 		return
 	}
 
-	// find the handler for this namespace
+	// find the handler for this namespace and version
+	key := routingKey(s.tenant, ns, version)
 	s.mutex.RLock()
-	h, ok := s.handlers[ns]
+	r, ok := s.handlers[key]
 	s.mutex.RUnlock()
 	if !ok {
 		_ = msg.Reject(msg.Exchange == balancedExchange) // requeue if "balanced"
-		logNoLongerListening(s.logger, s.peerID, msgID, ns)
+		logNoLongerListening(s.logger, s.peerID, msgID, key)
 		return
 	}
 
 	// find the source session revision
-	source, err := s.revisions.GetRevision(msgID.Ref)
-	if err != nil {
-		_ = msg.Reject(false) // false = don't requeue
-		logIgnoredMessage(s.logger, s.peerID, msgID, err)
-		return
+	if source == nil {
+		source, err = s.revisions.GetRevision(msgID.Ref)
+		if err != nil {
+			_ = msg.Reject(false) // false = don't requeue
+			logIgnoredMessage(s.logger, s.peerID, msgID, err)
+			return
+		}
 	}
 
-	s.handle(msgID, msg, ns, cmd, source, h, spanOpts)
+	s.handle(msgID, msg, ns, cmd, version, source, r.Handler, spanOpts)
 }
 
 // handle invokes the command handler for request.
@@ -375,66 +703,493 @@ func (s *server) handle(
 	msg *amqp.Delivery,
 	ns string,
 	cmd string,
+	version uint,
 	source rinq.Revision,
 	handler rinq.CommandHandler,
 	spanOpts []opentracing.StartSpanOption,
 ) {
 	ctx := amqputil.UnpackTrace(s.parentCtx, msg)
-	ctx, cancel := amqputil.UnpackDeadline(ctx, msg)
+	ctx = amqputil.UnpackBaggage(ctx, msg)
+	ctx = trace.WithPeer(ctx, s.peerID)
+	ctx = trace.WithSession(ctx, msgID.Ref)
+	ctx, cancel := amqputil.UnpackDeadline(ctx, msg, s.hopMargin)
 	defer cancel()
 
-	span := s.tracer.StartSpan("", spanOpts...)
+	// the invoker waiting for a correlated response may cancel the request
+	if unpackReplyMode(msg) == replyCorrelated {
+		defer s.track(msg.MessageId, cancel)()
+	}
+
+	traceID := trace.Get(ctx)
+
+	span := s.sampler.Tracer(s.tracer, ns, traceID).StartSpan("", spanOpts...)
 	defer span.Finish()
 
 	ctx = opentracing.ContextWithSpan(ctx, span)
 
+	// The payload borrows the message body rather than taking ownership of it,
+	// as the body is still required if the request is parked after the handler
+	// has closed the payload.
 	req := rinq.Request{
 		ID:        msgID,
 		Source:    source,
 		Namespace: ns,
 		Command:   cmd,
-		Payload:   rinq.NewPayloadFromBytes(msg.Body),
+		Version:   version,
+		Payload:   rinq.BorrowPayload(msg.Body, nil),
+		Headers:   amqputil.UnpackHeaders(msg),
 	}
 
-	res, finalize := newResponse(
+	r, finalize := newResponse(
 		ctx,
 		s.channels,
+		s.prefix,
 		req,
 		unpackReplyMode(msg),
+		loopbackReply(msg),
+		s.replay,
 	)
 
-	if s.logger.IsDebug() {
+	defer func() {
+		if err := r.SendErr(); err != nil {
+			logResponseFailed(ctx, s.logger, s.peerID, msgID, req, err)
+		}
+	}()
+
+	// If the request has been delivered before, and we already responded to it,
+	// send the same response again instead of invoking the handler.
+	if msg.Redelivered && s.replay != nil {
+		if rsp, ok := s.replay.Get(msgID); ok {
+			req.Payload.Close()
+			r.resend(rsp)
+			_ = msg.Ack(false) // false = single message
+			logRequestReplayed(ctx, s.logger, s.peerID, msgID, req)
+			return
+		}
+	}
+
+	// If the request was sent with at-least-once execution and has already
+	// been handled, acknowledge it without invoking the handler again.
+	if s.isDuplicate(ns, msgID, msg) {
+		req.Payload.Close()
+		_ = msg.Ack(false) // false = single message
+		logRequestDuplicate(ctx, s.logger, s.peerID, msgID, req)
+		return
+	}
+
+	var res rinq.Response = r
+
+	logger := s.sampler.Logger(s.logger, ns, traceID)
+	if logger.IsDebug() {
 		res = newDebugResponse(res)
-		logRequestBegin(ctx, s.logger, s.peerID, msgID, req)
+		logRequestBegin(ctx, logger, s.peerID, msgID, req)
 	}
 
-	handler(ctx, req, res)
+	handled := s.reportProgress(ctx, msgID, msg)
+	start := s.clock.Now()
+
+	hctx := ctx
+	if t, ok := s.timeouts[ns]; ok {
+		var stop func()
+		hctx, stop = s.limitExecution(ctx, msgID, req, r, t)
+		defer stop()
+	}
+
+	atomic.AddInt32(&s.inFlight, 1)
+	p := invoke(hctx, handler, req, res)
+	atomic.AddInt32(&s.inFlight, -1)
+	handled()
+
+	elapsed := s.clock.Now().Sub(start)
+	s.reportSlow(ctx, msgID, req, elapsed)
+
+	if p != nil {
+		s.recordHandled(ns, cmd, elapsed, internalError(ctx))
+		s.handlePanic(ctx, span, msgID, msg, req, r, finalize, p)
+		return
+	}
 
 	if finalize() {
-		_ = msg.Ack(false) // false = single message
+		s.recordHandled(ns, cmd, elapsed, r.Err())
+		s.ack(ns, msgID, msg)
 
-		if dr, ok := res.(*debugResponse); ok {
+		if dr, ok := res.(*debugResponse); ok && !r.TimedOut() {
 			defer dr.Payload.Close()
-			logRequestEnd(ctx, s.logger, s.peerID, msgID, req, dr.Payload, dr.Err)
+			logRequestEnd(ctx, logger, s.peerID, msgID, req, dr.Payload, dr.Err)
 		}
 	} else if msg.Exchange == balancedExchange {
-		select {
-		case <-ctx.Done():
+		if s.isAbandoned(ctx, ns, msg) {
+			s.recordHandled(ns, cmd, elapsed, ctx.Err())
 			_ = msg.Reject(false) // false = don't requeue
 			logRequestRejected(ctx, s.logger, s.peerID, msgID, req, ctx.Err().Error())
-		default:
+		} else {
 			_ = msg.Reject(true) // true = requeue
 			logRequestRequeued(ctx, s.logger, s.peerID, msgID, req)
 		}
 	} else {
+		s.recordHandled(ns, cmd, elapsed, errNoResponse)
 		_ = msg.Reject(false) // false = don't requeue
-		logRequestRejected(ctx, s.logger, s.peerID, msgID, req, "handler did not respond")
+		logRequestRejected(ctx, s.logger, s.peerID, msgID, req, errNoResponse.Error())
+	}
+}
+
+// isDuplicate returns true if msg is an at-least-once request that the dedupe
+// store reports as already handled.
+func (s *server) isDuplicate(ns string, msgID ident.MessageID, msg *amqp.Delivery) bool {
+	return s.isDeduplicated(ns, msg) && s.dedupe.Seen(msgID)
+}
+
+// ack acknowledges a request that has been handled. At-least-once requests are
+// marked as handled in the dedupe store once the acknowledgement succeeds, so
+// that a request is never marked unless it has been handled.
+func (s *server) ack(ns string, msgID ident.MessageID, msg *amqp.Delivery) {
+	if err := msg.Ack(false); err != nil { // false = single message
+		return
+	}
+
+	if s.isDeduplicated(ns, msg) {
+		s.dedupe.Mark(msgID)
+	}
+}
+
+// isDeduplicated returns true if msg is subject to duplicate detection, which
+// applies to requests sent by Session.Execute() to at-least-once namespaces.
+func (s *server) isDeduplicated(ns string, msg *amqp.Delivery) bool {
+	if unpackReplyMode(msg) != replyNone {
+		return false
+	}
+
+	_, ok := s.atLeastOnce[ns]
+	return ok
+}
+
+// isAbandoned returns true if a balanced request that was not handled should
+// be discarded rather than re-queued, because ctx is done.
+//
+// At-least-once requests are never abandoned. They do not have a deadline, so
+// ctx is only done when the server is stopping, in which case the request must
+// be re-queued so that it is handled by another peer.
+func (s *server) isAbandoned(ctx context.Context, ns string, msg *amqp.Delivery) bool {
+	if s.isDeduplicated(ns, msg) {
+		return false
+	}
+
+	return ctx.Err() != nil
+}
+
+// limitExecution returns a context derived from ctx that is canceled once the
+// handler for req has run for timeout. At that point the caller is sent a
+// handler-timeout failure, unless the handler has already responded. The
+// returned function must be called when the handler returns.
+func (s *server) limitExecution(
+	ctx context.Context,
+	msgID ident.MessageID,
+	req rinq.Request,
+	r *response,
+	timeout time.Duration,
+) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	timer := s.clock.AfterFunc(timeout, func() {
+		if r.timeout(handlerTimeout(ctx, timeout)) {
+			logRequestTimedOut(ctx, s.logger, s.peerID, msgID, req, timeout)
+		}
+
+		cancel()
+	})
+
+	return ctx, func() {
+		timer.Stop()
+		cancel()
+	}
+}
+
+// handlerTimeout returns the failure sent to the caller when a command handler
+// does not respond within its maximum execution time.
+func handlerTimeout(ctx context.Context, t time.Duration) rinq.Failure {
+	return rinq.Failure{
+		Type:    rinq.HandlerTimeoutFailureType,
+		Message: fmt.Sprintf("the command handler did not respond within %s [%s]", t, trace.Get(ctx)),
+	}
+}
+
+// recordHandled records the outcome of a command request with the metrics
+// recorder and, if enabled, the adaptive concurrency controller.
+func (s *server) recordHandled(ns, cmd string, elapsed time.Duration, err error) {
+	s.metrics.RecordHandled(ns, cmd, elapsed, err)
+
+	if s.adaptive != nil {
+		// failures are a normal outcome, except for handler timeouts, which
+		// indicate that the handler could not keep up
+		failed := err != nil &&
+			(!rinq.IsFailure(err) || rinq.FailureType(err) == rinq.HandlerTimeoutFailureType)
+		s.adaptive.Observe(elapsed, failed)
+	}
+}
+
+// adjustPreFetch updates the pre-fetch count of the consuming channel based on
+// the outcome of the requests handled since the last adjustment.
+func (s *server) adjustPreFetch() error {
+	limit, changed := s.adaptive.Adjust()
+	if !changed {
+		return nil
+	}
+
+	logPreFetchAdjusted(s.logger, s.peerID, limit, s.preFetch)
+
+	return s.channel.Qos(int(limit), 0, false)
+}
+
+// capacity returns the number of requests that the server handles
+// concurrently.
+func (s *server) capacity() uint {
+	if s.adaptive != nil {
+		return s.adaptive.Limit()
+	}
+
+	return s.preFetch
+}
+
+// reportSlow logs and records a slow handler event if the handler for req took
+// longer than the slow handler threshold.
+func (s *server) reportSlow(
+	ctx context.Context,
+	msgID ident.MessageID,
+	req rinq.Request,
+	elapsed time.Duration,
+) {
+	if s.slow == 0 || elapsed <= s.slow {
+		return
+	}
+
+	logSlowHandler(ctx, s.logger, s.peerID, msgID, req, elapsed, s.slow)
+
+	if r, ok := s.metrics.(metrics.SlowHandlerRecorder); ok {
+		r.RecordSlowHandler(req.Namespace, req.Command, elapsed)
 	}
 }
 
+// reportProgress sends a notice to the invoker waiting for the response to msg
+// saying that it has been consumed, and another if the handler is still running
+// when the deadline of ctx passes. It returns a function that must be called
+// when the handler returns.
+//
+// Notices are only sent if the invoker asked for them, and are best-effort.
+func (s *server) reportProgress(
+	ctx context.Context,
+	msgID ident.MessageID,
+	msg *amqp.Delivery,
+) func() {
+	if !unpackDiagnostics(msg) ||
+		unpackReplyMode(msg) != replyCorrelated ||
+		loopbackReply(msg) != nil {
+		return func() {}
+	}
+
+	s.sendNotice(msgID, consumedNotice)
+
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				s.sendNotice(msgID, runningNotice)
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// sendNotice publishes a notice of type t about the request with the given ID.
+func (s *server) sendNotice(msgID ident.MessageID, t string) {
+	_ = amqputil.Publish(
+		s.channels,
+		s.prefix+responseExchange,
+		msgID.String(),
+		amqp.Publishing{Type: t},
+	)
+}
+
+// track records cancel as the function that cancels the context of the
+// request with the given message ID. It returns a function that removes the
+// record.
+func (s *server) track(msgID string, cancel func()) func() {
+	s.requestsMutex.Lock()
+	s.requests[msgID] = cancel
+	s.requestsMutex.Unlock()
+
+	return func() {
+		s.requestsMutex.Lock()
+		delete(s.requests, msgID)
+		s.requestsMutex.Unlock()
+	}
+}
+
+// cancel cancels the context of the request with the given message ID, if it
+// is currently being handled.
+func (s *server) cancel(msgID ident.MessageID) {
+	s.requestsMutex.Lock()
+	cancel, ok := s.requests[msgID.String()]
+	s.requestsMutex.Unlock()
+
+	if ok {
+		cancel()
+		logRequestCanceled(s.logger, s.peerID, msgID)
+	}
+}
+
+// errNoResponse is recorded as the outcome of a request that is rejected
+// because the handler did not respond.
+var errNoResponse = errors.New("handler did not respond")
+
+// invoke calls handler, recovering from any panic. It returns nil if the
+// handler returned normally.
+func invoke(
+	ctx context.Context,
+	handler rinq.CommandHandler,
+	req rinq.Request,
+	res rinq.Response,
+) (p *handlerPanic) {
+	defer func() {
+		if v := recover(); v != nil {
+			p = &handlerPanic{v, debug.Stack()}
+		}
+	}()
+
+	handler(ctx, req, res)
+
+	return nil
+}
+
+// handlePanic responds to, rejects or parks a request that caused the command
+// handler to panic.
+//
+// Balanced requests are requeued until they have been delivered
+// poisonThreshold times, at which point they are parked. The caller is sent an
+// internal-error failure unless the request is requeued or the handler had
+// already responded.
+func (s *server) handlePanic(
+	ctx context.Context,
+	span opentracing.Span,
+	msgID ident.MessageID,
+	msg *amqp.Delivery,
+	req rinq.Request,
+	r *response,
+	finalize func() bool,
+	p *handlerPanic,
+) {
+	opentr.LogServerPanic(span, p, deliveryCount(msg))
+
+	if r.IsClosed() {
+		finalize()
+		s.ack(req.Namespace, msgID, msg)
+		logRequestPanicked(ctx, s.logger, s.peerID, msgID, req, p, "response already sent")
+		return
+	}
+
+	if msg.Exchange == balancedExchange && !isPoison(msg) {
+		finalize()
+
+		if s.isAbandoned(ctx, req.Namespace, msg) {
+			_ = msg.Reject(false) // false = don't requeue
+			logRequestPanicked(ctx, s.logger, s.peerID, msgID, req, p, "request has been abandoned")
+		} else {
+			_ = msg.Reject(true) // true = requeue
+			logRequestPanicked(ctx, s.logger, s.peerID, msgID, req, p, "request has been re-queued")
+		}
+
+		return
+	}
+
+	r.Error(internalError(ctx))
+	finalize()
+
+	if msg.Exchange != balancedExchange {
+		_ = msg.Ack(false) // false = single message
+		logRequestPanicked(ctx, s.logger, s.peerID, msgID, req, p, "internal error sent")
+		return
+	}
+
+	if err := s.park(msg, p); err != nil {
+		_ = msg.Reject(false) // false = don't requeue
+		logRequestPanicked(ctx, s.logger, s.peerID, msgID, req, p, "request could not be parked: "+err.Error())
+		return
+	}
+
+	_ = msg.Ack(false) // false = single message
+	opentr.LogServerParked(span, s.prefix+parkedRequestQueue)
+	logRequestParked(ctx, s.logger, s.peerID, msgID, req, p, deliveryCount(msg))
+}
+
+// park publishes a copy of msg to the parked request queue.
+func (s *server) park(msg *amqp.Delivery, p *handlerPanic) error {
+	channel, err := s.channels.Get()
+	if err != nil {
+		return err
+	}
+	defer s.channels.Put(channel)
+
+	return channel.Publish(
+		"", // default exchange routes directly to the queue
+		s.prefix+parkedRequestQueue,
+		false, // mandatory
+		false, // immediate
+		packParked(msg, s.prefix, s.peerID.String(), p),
+	)
+}
+
+// announce publishes a presence announcement listing the namespaces that the
+// server is listening to. Versioned namespaces are listed by their routing key.
+// It assumes s.mutex is locked.
+func (s *server) announce() error {
+	namespaces := make([]string, 0, len(s.handlers))
+	for key := range s.handlers {
+		namespaces = append(namespaces, key)
+	}
+
+	return s.publishPresence(namespaces)
+}
+
+// publishPresence publishes a presence announcement listing the given
+// namespaces.
+func (s *server) publishPresence(namespaces []string) error {
+	channel, err := s.channels.Get()
+	if err != nil {
+		return err
+	}
+	defer s.channels.Put(channel)
+
+	msg := amqp.Publishing{}
+	packPresence(&msg, presence{
+		PeerID:      s.peerID,
+		Incarnation: s.incarnation,
+		Namespaces:  namespaces,
+		Capacity:    s.capacity(),
+		Groups:      s.groups,
+		Revision:    rinq.ProtocolRevision,
+		Features:    rinq.Features,
+	})
+
+	return channel.Publish(
+		s.prefix+presenceExchange,
+		"",
+		false, // mandatory
+		false, // immediate
+		msg,
+	)
+}
+
 // pipe aggregates AMQP messages from multiple consumers to a single channel.
+//
+// The prefix is removed from the exchange name of each message, so that it can
+// be compared to the exchange names used by the loopback.
 func (s *server) pipe(messages <-chan amqp.Delivery) {
 	for msg := range messages {
+		msg.Exchange = strings.TrimPrefix(msg.Exchange, s.prefix)
+
 		select {
 		case s.deliveries <- msg:
 		case <-s.sm.Finalized:
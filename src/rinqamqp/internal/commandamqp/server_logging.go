@@ -2,6 +2,7 @@ package commandamqp
 
 import (
 	"context"
+	"time"
 
 	"github.com/jmalloc/twelf/src/twelf"
 	"github.com/rinq/rinq-go/src/rinq"
@@ -53,6 +54,40 @@ func logRequestBegin(
 	)
 }
 
+func logRequestReplayed(
+	ctx context.Context,
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	msgID ident.MessageID,
+	req rinq.Request,
+) {
+	logger.Log(
+		"%s server replayed the original response to redelivered '%s::%s' command request %s [%s]",
+		peerID.ShortString(),
+		req.Namespace,
+		req.Command,
+		msgID.ShortString(),
+		trace.Get(ctx),
+	)
+}
+
+func logRequestDuplicate(
+	ctx context.Context,
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	msgID ident.MessageID,
+	req rinq.Request,
+) {
+	logger.Log(
+		"%s server ignored duplicate '%s::%s' command request %s, it has already been handled [%s]",
+		peerID.ShortString(),
+		req.Namespace,
+		req.Command,
+		msgID.ShortString(),
+		trace.Get(ctx),
+	)
+}
+
 func logRequestEnd(
 	ctx context.Context,
 	logger twelf.Logger,
@@ -107,6 +142,18 @@ func logRequestEnd(
 	}
 }
 
+func logRequestCanceled(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	msgID ident.MessageID,
+) {
+	logger.Debug(
+		"%s server canceled request %s, the invoker is no longer waiting for a response",
+		peerID.ShortString(),
+		msgID.ShortString(),
+	)
+}
+
 func logNoLongerListening(
 	logger twelf.Logger,
 	peerID ident.PeerID,
@@ -138,6 +185,65 @@ func logRequestRequeued(
 	)
 }
 
+func logSlowHandler(
+	ctx context.Context,
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	msgID ident.MessageID,
+	req rinq.Request,
+	elapsed time.Duration,
+	threshold time.Duration,
+) {
+	logger.Log(
+		"%s server took %dms to handle '%s::%s' command request %s, which is longer than the slow handler threshold of %dms [%s]",
+		peerID.ShortString(),
+		elapsed/time.Millisecond,
+		req.Namespace,
+		req.Command,
+		msgID.ShortString(),
+		threshold/time.Millisecond,
+		trace.Get(ctx),
+	)
+}
+
+func logRequestTimedOut(
+	ctx context.Context,
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	msgID ident.MessageID,
+	req rinq.Request,
+	timeout time.Duration,
+) {
+	logger.Log(
+		"%s server canceled '%s::%s' command request %s, the handler did not respond within %dms [%s]",
+		peerID.ShortString(),
+		req.Namespace,
+		req.Command,
+		msgID.ShortString(),
+		timeout/time.Millisecond,
+		trace.Get(ctx),
+	)
+}
+
+func logResponseFailed(
+	ctx context.Context,
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	msgID ident.MessageID,
+	req rinq.Request,
+	err error,
+) {
+	logger.Log(
+		"%s server could not send the response for '%s::%s' command request %s: %s [%s]",
+		peerID.ShortString(),
+		req.Namespace,
+		req.Command,
+		msgID.ShortString(),
+		err,
+		trace.Get(ctx),
+	)
+}
+
 func logRequestRejected(
 	ctx context.Context,
 	logger twelf.Logger,
@@ -157,6 +263,51 @@ func logRequestRejected(
 	)
 }
 
+func logRequestPanicked(
+	ctx context.Context,
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	msgID ident.MessageID,
+	req rinq.Request,
+	p *handlerPanic,
+	outcome string,
+) {
+	logger.Log(
+		"%s handler panicked during '%s::%s' command request %s, %s: %s [%s]\n%s",
+		peerID.ShortString(),
+		req.Namespace,
+		req.Command,
+		msgID.ShortString(),
+		outcome,
+		p,
+		trace.Get(ctx),
+		p.Stack,
+	)
+}
+
+func logRequestParked(
+	ctx context.Context,
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	msgID ident.MessageID,
+	req rinq.Request,
+	p *handlerPanic,
+	deliveries uint,
+) {
+	logger.Log(
+		"%s handler panicked during '%s::%s' command request %s, request has been parked in '%s' after %d deliveries: %s [%s]\n%s",
+		peerID.ShortString(),
+		req.Namespace,
+		req.Command,
+		msgID.ShortString(),
+		parkedRequestQueue,
+		deliveries,
+		p,
+		trace.Get(ctx),
+		p.Stack,
+	)
+}
+
 func logServerStart(
 	logger twelf.Logger,
 	peerID ident.PeerID,
@@ -169,6 +320,20 @@ func logServerStart(
 	)
 }
 
+func logPreFetchAdjusted(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	preFetch uint,
+	max uint,
+) {
+	logger.Debug(
+		"%s server adjusted pre-fetch to %d (max: %d)",
+		peerID.ShortString(),
+		preFetch,
+		max,
+	)
+}
+
 func logServerStopping(
 	logger twelf.Logger,
 	peerID ident.PeerID,
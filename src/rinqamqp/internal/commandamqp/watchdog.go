@@ -0,0 +1,98 @@
+package commandamqp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/clock"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// asyncCall holds information about an asynchronous command request that is
+// awaiting a response.
+type asyncCall struct {
+	ID        ident.MessageID
+	Namespace string
+	Command   string
+	TraceID   string
+	Sent      time.Time
+	Handler   rinq.AsyncHandler // nil if the session's handler is used
+	timer     clock.Timer
+}
+
+// asyncWatchdog tracks asynchronous command requests and invokes a callback
+// for those that do not receive a response before their deadline.
+type asyncWatchdog struct {
+	expire func(*asyncCall)
+	clock  clock.Clock
+
+	mutex sync.Mutex
+	calls map[ident.MessageID]*asyncCall
+}
+
+func newAsyncWatchdog(expire func(*asyncCall), clk clock.Clock) *asyncWatchdog {
+	return &asyncWatchdog{
+		expire: expire,
+		clock:  clk,
+		calls:  map[ident.MessageID]*asyncCall{},
+	}
+}
+
+// Track begins tracking c, which expires at deadline.
+func (w *asyncWatchdog) Track(c *asyncCall, deadline time.Time) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.calls[c.ID] = c
+	c.timer = w.clock.AfterFunc(
+		deadline.Sub(w.clock.Now()),
+		func() {
+			if w.remove(c.ID) != nil {
+				w.expire(c)
+			}
+		},
+	)
+}
+
+// Done stops tracking the call with the given ID. It returns the call, or nil
+// if the call is not being tracked, such as when it has already expired.
+func (w *asyncWatchdog) Done(id ident.MessageID) *asyncCall {
+	c := w.remove(id)
+	if c != nil {
+		c.timer.Stop()
+	}
+
+	return c
+}
+
+// Len returns the number of calls being tracked.
+func (w *asyncWatchdog) Len() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return len(w.calls)
+}
+
+// Stop stops tracking all calls without expiring them.
+func (w *asyncWatchdog) Stop() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for id, c := range w.calls {
+		c.timer.Stop()
+		delete(w.calls, id)
+	}
+}
+
+func (w *asyncWatchdog) remove(id ident.MessageID) *asyncCall {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	c, ok := w.calls[id]
+	if ok {
+		delete(w.calls, id)
+	}
+
+	return c
+}
@@ -12,9 +12,11 @@ const (
 	multicastExchange = "ntf.mc"
 )
 
-func declareExchanges(channel *amqp.Channel) error {
+// declareExchanges declares the exchanges used for notifications, with names
+// that begin with prefix.
+func declareExchanges(channel *amqp.Channel, prefix string) error {
 	if err := channel.ExchangeDeclare(
-		unicastExchange,
+		prefix+unicastExchange,
 		"direct",
 		false, // durable
 		false, // autoDelete
@@ -26,7 +28,7 @@ func declareExchanges(channel *amqp.Channel) error {
 	}
 
 	if err := channel.ExchangeDeclare(
-		multicastExchange,
+		prefix+multicastExchange,
 		"direct",
 		false, // durable
 		false, // autoDelete
@@ -4,40 +4,76 @@ import (
 	"github.com/rinq/rinq-go/src/internal/localsession"
 	"github.com/rinq/rinq-go/src/internal/notify"
 	"github.com/rinq/rinq-go/src/internal/revisions"
+	"github.com/rinq/rinq-go/src/internal/sampling"
+	"github.com/rinq/rinq-go/src/internal/service"
 	"github.com/rinq/rinq-go/src/rinq/ident"
 	"github.com/rinq/rinq-go/src/rinq/options"
 	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
 )
 
-// New returns a pair of notifier and listener.
+// New returns a notifier, a listener and a stream consumer.
 func New(
 	peerID ident.PeerID,
 	opts options.Options,
 	sessions *localsession.Store,
 	revs revisions.Store,
 	channels amqputil.ChannelPool,
-) (notify.Notifier, notify.Listener, error) {
+	flow *amqputil.Flow,
+) (notify.Notifier, notify.Listener, notify.Streams, error) {
 	channel, err := channels.GetQOS(opts.SessionWorkers) // do not return to pool, use for listener
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	if err = declareExchanges(channel); err != nil {
-		return nil, nil, err
+	if err = declareExchanges(channel, opts.NamePrefix); err != nil {
+		return nil, nil, nil, err
 	}
 
+	// declare streams before any notifications are sent, so that they are
+	// retained even if no consumers are running
+	for ns, o := range opts.Streams {
+		if _, err = declareStream(channel, opts.NamePrefix, opts.Tenant, ns, o); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	sampler := sampling.NewSampler(opts.Sampling)
+	notifier := newNotifier(peerID, opts.Tenant, opts.NamePrefix, opts.NotifyBatch, channels, flow, opts.Logger, opts.Metrics)
+
 	listener, err := newListener(
 		peerID,
 		opts.SessionWorkers,
+		opts.Tenant,
+		opts.NamePrefix,
+		opts.ListenerConcurrency,
+		opts.ListenerBuffer,
+		opts.ListenerOverflow,
+		opts.OrderedNotifications,
 		sessions,
 		revs,
+		notifier,
 		channel,
 		opts.Logger,
 		opts.Tracer,
+		sampler,
+		opts.Metrics,
 	)
 	if err != nil {
-		return nil, nil, err
+		notifier.(service.Service).Stop()
+		return nil, nil, nil, err
 	}
 
-	return newNotifier(peerID, channels, opts.Logger), listener, nil
+	streams := newStreams(
+		peerID,
+		opts.Tenant,
+		opts.NamePrefix,
+		opts.Streams,
+		channels,
+		revs,
+		opts.Logger,
+		opts.Tracer,
+		sampler,
+	)
+
+	return notifier, listener, streams, nil
 }
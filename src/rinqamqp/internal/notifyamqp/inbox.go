@@ -0,0 +1,178 @@
+package notifyamqp
+
+import (
+	"sync"
+
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+)
+
+// delivery is a notification that is waiting to be handled by a session.
+type delivery struct {
+	ID     ident.MessageID
+	Handle func() // invokes the notification handler
+	Done   func() // called after the delivery is handled or dropped
+}
+
+// inbox limits the number of notification handlers that are invoked
+// concurrently for a single session, buffering deliveries that arrive while
+// the session is at its limit.
+type inbox struct {
+	limit  uint
+	buffer uint
+	policy options.OverflowPolicy
+
+	mutex   sync.Mutex
+	space   *sync.Cond // signaled when a handler finishes
+	running uint
+	queue   []*delivery
+}
+
+func newInbox(limit, buffer uint, policy options.OverflowPolicy) *inbox {
+	b := &inbox{
+		limit:  limit,
+		buffer: buffer,
+		policy: policy,
+	}
+
+	b.space = sync.NewCond(&b.mutex)
+
+	return b
+}
+
+// Push handles d as soon as the concurrency limit allows.
+//
+// If the buffer is full, the overflow policy is applied. Under BlockOverflow
+// Push blocks until there is space for d. Otherwise, the delivery that was
+// dropped to make space is returned, which may be d itself.
+func (b *inbox) Push(d *delivery) (dropped *delivery) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for {
+		if dropped, ok := b.add(d, false); ok {
+			return dropped
+		}
+
+		b.space.Wait()
+	}
+}
+
+// Enqueue handles d as soon as the concurrency limit allows, without blocking.
+//
+// If the buffer is full, the overflow policy is applied as per Push(), except
+// that under BlockOverflow d is buffered regardless. The caller must then call
+// Wait() to block until the buffer is no longer over-full.
+func (b *inbox) Enqueue(d *delivery) (dropped *delivery) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	dropped, _ = b.add(d, true)
+	return dropped
+}
+
+// Wait blocks until the number of buffered deliveries is within the buffer
+// size.
+func (b *inbox) Wait() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for uint(len(b.queue)) > b.buffer {
+		b.space.Wait()
+	}
+}
+
+// add handles or buffers d, applying the overflow policy if the buffer is
+// full. If overfill is true, d is buffered beyond the buffer size under
+// BlockOverflow, otherwise ok is false if d must wait for space. It assumes
+// b.mutex is locked.
+func (b *inbox) add(d *delivery, overfill bool) (dropped *delivery, ok bool) {
+	if b.running < b.limit {
+		b.running++
+		go b.run(d)
+		return nil, true
+	}
+
+	if uint(len(b.queue)) < b.buffer {
+		b.queue = append(b.queue, d)
+		return nil, true
+	}
+
+	switch b.policy {
+	case options.DropNewestOverflow:
+		return d, true
+
+	case options.DropOldestOverflow:
+		if len(b.queue) == 0 {
+			return d, true
+		}
+
+		dropped = b.queue[0]
+		b.queue = append(b.queue[1:], d)
+		return dropped, true
+
+	default:
+		if overfill {
+			b.queue = append(b.queue, d)
+			return nil, true
+		}
+
+		return nil, false
+	}
+}
+
+// run handles d, followed by any deliveries that are buffered while it is
+// being handled.
+func (b *inbox) run(d *delivery) {
+	for d != nil {
+		d.Handle()
+		d.Done()
+
+		b.mutex.Lock()
+
+		if len(b.queue) == 0 {
+			d = nil
+			b.running--
+		} else {
+			d = b.queue[0]
+			b.queue[0] = nil
+			b.queue = b.queue[1:]
+		}
+
+		b.space.Broadcast()
+		b.mutex.Unlock()
+	}
+}
+
+// turn orders the passing of deliveries to inboxes, such that each delivery
+// is passed only after the delivery received before it.
+type turn struct {
+	prev <-chan struct{} // closed when the previous delivery's turn is done
+	next chan struct{}   // closed when this turn is done
+}
+
+// Wait blocks until the previous delivery's turn is done. It is a no-op if t
+// is nil.
+func (t *turn) Wait() {
+	if t != nil {
+		<-t.prev
+	}
+}
+
+// Done ends the turn, allowing the next delivery to proceed. It waits for the
+// previous turn first, so that turns always end in order. It is a no-op if t
+// is nil or the turn has already ended.
+func (t *turn) Done() {
+	if t == nil {
+		return
+	}
+
+	<-t.prev
+
+	select {
+	case <-t.next:
+		// already ended, next is only closed by the delivery that owns t
+	default:
+		close(t.next)
+	}
+}
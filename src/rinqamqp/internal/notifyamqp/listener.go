@@ -3,70 +3,121 @@ package notifyamqp
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/jmalloc/twelf/src/twelf"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/rinq/rinq-go/src/internal/localsession"
 	"github.com/rinq/rinq-go/src/internal/notify"
+	"github.com/rinq/rinq-go/src/internal/opentr"
 	"github.com/rinq/rinq-go/src/internal/revisions"
+	"github.com/rinq/rinq-go/src/internal/sampling"
 	"github.com/rinq/rinq-go/src/internal/service"
 	"github.com/rinq/rinq-go/src/rinq"
 	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/metrics"
+	"github.com/rinq/rinq-go/src/rinq/options"
+	"github.com/rinq/rinq-go/src/rinq/trace"
 	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
 	"github.com/streadway/amqp"
 )
 
+// reportTimeout is the maximum time spent sending each dispatch report.
+const reportTimeout = 5 * time.Second
+
 type listener struct {
 	service.Service
 	sm *service.StateMachine
 
 	peerID    ident.PeerID
 	preFetch  uint
+	tenant    string
+	prefix    string // prepended to exchange and queue names
+	limit     uint   // per-session handler concurrency, zero if unlimited
+	buffer    uint
+	overflow  options.OverflowPolicy
+	ordered   bool
 	sessions  *localsession.Store
 	revisions revisions.Store
+	notifier  notify.Notifier // used to send dispatch reports
 	logger    twelf.Logger
 	tracer    opentracing.Tracer
+	sampler   *sampling.Sampler
+	metrics   metrics.Recorder
 
 	parentCtx context.Context // parent of all contexts passed to handlers
 	cancelCtx func()          // cancels parentCtx when the server stops
+	reportSeq uint32          // sequence number of dispatch reports, atomic
 
 	// state-machine data
 	channel    *amqp.Channel        // channel used for consuming
 	namespaces map[string]uint      // map of namespace to listener count
 	deliveries <-chan amqp.Delivery // incoming notifications
 	amqpClosed chan *amqp.Error
-	pending    uint // number of notifications currently being handled
+	pending    uint          // number of notifications currently being handled
+	turn       chan struct{} // closed when the last delivery has been passed to inboxes, if ordered
 
-	mutex    sync.RWMutex // guards handlers so handler can be read in dispatch() goroutine
+	mutex    sync.RWMutex // guards handlers and inboxes so they can be read in dispatch() goroutine
 	handlers map[ident.SessionID]map[string]rinq.NotificationHandler
+	inboxes  map[ident.SessionID]*inbox
 }
 
 // newListener creates, starts and returns a new listener.
 func newListener(
 	peerID ident.PeerID,
 	preFetch uint,
+	tenant string,
+	prefix string,
+	limit uint,
+	buffer uint,
+	overflow options.OverflowPolicy,
+	ordered bool,
 	sessions *localsession.Store,
 	revs revisions.Store,
+	notifier notify.Notifier,
 	channel *amqp.Channel,
 	logger twelf.Logger,
 	tracer opentracing.Tracer,
+	sampler *sampling.Sampler,
+	recorder metrics.Recorder,
 ) (notify.Listener, error) {
 	l := &listener{
 		peerID:    peerID,
 		preFetch:  preFetch,
+		tenant:    tenant,
+		prefix:    prefix,
+		limit:     limit,
+		buffer:    buffer,
+		overflow:  overflow,
+		ordered:   ordered,
 		sessions:  sessions,
 		revisions: revs,
+		notifier:  notifier,
 		logger:    logger,
 		tracer:    tracer,
+		sampler:   sampler,
+		metrics:   recorder,
 
 		channel:    channel,
 		namespaces: map[string]uint{},
 		amqpClosed: make(chan *amqp.Error, 1),
+		turn:       make(chan struct{}),
 
 		handlers: map[ident.SessionID]map[string]rinq.NotificationHandler{},
+		inboxes:  map[ident.SessionID]*inbox{},
+	}
+
+	// ordered delivery requires each session to handle its notifications one
+	// at a time.
+	if ordered {
+		l.limit = 1
 	}
 
+	close(l.turn)
+
 	l.sm = service.NewStateMachine(l.run, l.finalize)
 	l.Service = l.sm
 
@@ -136,6 +187,7 @@ func (l *listener) UnlistenAll(id ident.SessionID) error {
 
 		handlers := l.handlers[id]
 		delete(l.handlers, id)
+		delete(l.inboxes, id)
 
 		for ns := range handlers {
 			if err := l.unbind(ns); err != nil {
@@ -155,12 +207,12 @@ func (l *listener) bind(ns string) error {
 		return nil
 	}
 
-	queue := notifyQueue(l.peerID)
+	queue := notifyQueue(l.prefix, l.peerID)
 
 	if err := l.channel.QueueBind(
 		queue,
-		unicastRoutingKey(ns, l.peerID),
-		unicastExchange,
+		unicastRoutingKey(l.tenant, ns, l.peerID),
+		l.prefix+unicastExchange,
 		false, // noWait
 		nil,   // args
 	); err != nil {
@@ -169,8 +221,8 @@ func (l *listener) bind(ns string) error {
 
 	return l.channel.QueueBind(
 		queue,
-		ns,
-		multicastExchange,
+		multicastRoutingKey(l.tenant, ns),
+		l.prefix+multicastExchange,
 		false, // noWait
 		nil,   // args
 	)
@@ -184,12 +236,12 @@ func (l *listener) unbind(ns string) error {
 		return nil
 	}
 
-	queue := notifyQueue(l.peerID)
+	queue := notifyQueue(l.prefix, l.peerID)
 
 	if err := l.channel.QueueUnbind(
 		queue,
-		unicastRoutingKey(ns, l.peerID),
-		unicastExchange,
+		unicastRoutingKey(l.tenant, ns, l.peerID),
+		l.prefix+unicastExchange,
 		nil, // args
 	); err != nil {
 		return err
@@ -197,8 +249,8 @@ func (l *listener) unbind(ns string) error {
 
 	return l.channel.QueueUnbind(
 		queue,
-		ns,
-		multicastExchange,
+		multicastRoutingKey(l.tenant, ns),
+		l.prefix+multicastExchange,
 		nil, // args
 	)
 }
@@ -207,7 +259,7 @@ func (l *listener) unbind(ns string) error {
 func (l *listener) initialize() error {
 	l.channel.NotifyClose(l.amqpClosed)
 
-	queue := notifyQueue(l.peerID)
+	queue := notifyQueue(l.prefix, l.peerID)
 
 	if _, err := l.channel.QueueDeclare(
 		queue,
@@ -247,8 +299,13 @@ func (l *listener) run() (service.State, error) {
 				// sometimes the consumer channel is closed before the AMQP channel
 				return nil, <-l.amqpClosed
 			}
-			l.pending++
-			go l.dispatch(&msg)
+			batch := amqputil.Drain(msg, l.deliveries, int(l.preFetch))
+			turns := make([]*turn, len(batch))
+			for i := range batch {
+				turns[i] = l.nextTurn()
+			}
+			l.pending += uint(len(batch))
+			go l.dispatchBatch(batch, turns)
 
 		case req := <-l.sm.Commands:
 			l.sm.Execute(req)
@@ -269,7 +326,7 @@ func (l *listener) run() (service.State, error) {
 func (l *listener) stopConsuming() (service.State, error) {
 	logListenerStopping(l.logger, l.peerID, l.pending)
 
-	queue := notifyQueue(l.peerID)
+	queue := notifyQueue(l.prefix, l.peerID)
 	if err := l.channel.Cancel(queue, false); err != nil { // false = wait for response
 		return nil, err
 	}
@@ -317,14 +374,56 @@ func (l *listener) finalize(err error) error {
 	return err
 }
 
+// nextTurn returns the turn for the next delivery, or nil if notifications
+// are not ordered.
+func (l *listener) nextTurn() *turn {
+	if !l.ordered {
+		return nil
+	}
+
+	t := &turn{l.turn, make(chan struct{})}
+	l.turn = t.next
+
+	return t
+}
+
+// dispatchBatch dispatches each of the notifications in batch, which were all
+// delivered at once. turns contains the turn for each notification.
+//
+// The source revisions of the notifications are looked up together, so that
+// the state of the sessions that sent them is not fetched with a separate
+// request for each.
+func (l *listener) dispatchBatch(batch []amqp.Delivery, turns []*turn) {
+	var sources map[string]rinq.Revision
+	if len(batch) > 1 {
+		sources = amqputil.GetSources(l.parentCtx, l.revisions, batch)
+	}
+
+	for i := range batch {
+		msg := &batch[i]
+		go l.dispatch(msg, sources[msg.MessageId], turns[i])
+	}
+}
+
 // dispatch validates an incoming notification and dispatches it the
-// appropriate handler.
-func (l *listener) dispatch(msg *amqp.Delivery) {
+// appropriate handler. source is the revision of the session that sent the
+// notification, or nil if it has not yet been looked up.
+//
+// If t is non-nil, the notification is not passed to the session inboxes until
+// the previous delivery has been, so that sessions receive notifications in
+// the order they were delivered.
+func (l *listener) dispatch(msg *amqp.Delivery, source rinq.Revision, t *turn) {
 	defer l.sm.DoGraceful(func() error {
 		l.pending--
 		return nil
 	})
 
+	// wait for any notifications that were passed to session inboxes to be
+	// handled, so that graceful stops wait for them.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer t.Done()
+
 	// create a prototype notification that is cloned for each handler
 	proto := &rinq.Notification{}
 
@@ -344,26 +443,47 @@ func (l *listener) dispatch(msg *amqp.Delivery) {
 		}
 	}()
 
-	// find the source session revision
-	proto.Source, err = l.revisions.GetRevision(proto.ID.Ref)
-	if err != nil {
+	// reject notifications from other tenants
+	if err = amqputil.CheckTenant(msg, l.tenant); err != nil {
 		return
 	}
 
+	// find the source session revision
+	proto.Source = source
+	if proto.Source == nil {
+		proto.Source, err = l.revisions.GetRevision(proto.ID.Ref)
+		if err != nil {
+			return
+		}
+	}
+
 	proto.Namespace, proto.Type, proto.Payload, err = unpackCommonAttributes(msg)
 	if err != nil {
 		return
 	}
 	defer proto.Payload.Close()
 
+	proto.IsRequest, proto.CorrelationID, err = unpackCorrelation(msg)
+	if err != nil {
+		return
+	}
+
+	proto.Headers = amqputil.UnpackHeaders(msg)
+
 	var sessions []rinq.Session
 
-	switch msg.Exchange {
+	switch strings.TrimPrefix(msg.Exchange, l.prefix) {
 	case unicastExchange:
 		sessions, err = l.findUnicastTarget(proto, msg)
+		if err == nil && len(sessions) == 0 {
+			l.recordDropped(proto)
+		}
 	case multicastExchange:
 		proto.IsMulticast = true
 		sessions, err = l.findMulticastTargets(proto, msg)
+		if err == nil {
+			logMulticastDispatched(l.logger, l.peerID, proto, len(sessions))
+		}
 	default:
 		err = fmt.Errorf("delivery via '%s' exchange is not expected", msg.Exchange)
 	}
@@ -372,20 +492,127 @@ func (l *listener) dispatch(msg *amqp.Delivery) {
 	}
 
 	ctx := amqputil.UnpackTrace(l.parentCtx, msg)
+	ctx = amqputil.UnpackBaggage(ctx, msg)
+	ctx = trace.WithPeer(ctx, l.peerID)
+	ctx = trace.WithSession(ctx, proto.ID.Ref)
 
 	spanOpts, err := unpackSpanOptions(msg, l.tracer)
 	if err != nil {
 		return
 	}
 
+	if proto.IsMulticast && unpackReportRequest(msg) {
+		l.report(ctx, proto, len(sessions))
+	}
+
+	t.Wait()
+
+	// inboxes that have been filled beyond their buffer by ordered deliveries
+	var full []*inbox
+
 	for _, sess := range sessions {
-		l.handle(
-			ctx,
-			sess,
-			proto,
-			spanOpts,
-		)
+		if l.limit == 0 {
+			l.handle(
+				ctx,
+				sess,
+				proto,
+				spanOpts,
+			)
+		} else {
+			wg.Add(1)
+			if b := l.enqueue(
+				ctx,
+				sess,
+				proto,
+				spanOpts,
+				wg.Done,
+			); b != nil {
+				full = append(full, b)
+			}
+		}
+	}
+
+	// the turn is ended before waiting for space in the inboxes, so that a
+	// session that is slow to handle its notifications does not delay the
+	// delivery of notifications to other sessions. The notification is still
+	// not acknowledged until there is space, which applies back-pressure.
+	t.Done()
+
+	for _, b := range full {
+		b.Wait()
+	}
+}
+
+// enqueue passes a notification to the inbox of a specific session, which
+// invokes the handler once the session is below its concurrency limit.
+//
+// If notifications are ordered, enqueue does not block when the inbox is full,
+// as the caller holds the turn. Instead, it returns the inbox, and the caller
+// must call Wait() on it after ending the turn.
+func (l *listener) enqueue(
+	ctx context.Context,
+	sess rinq.Session,
+	proto *rinq.Notification,
+	spanOpts []opentracing.StartSpanOption,
+	done func(),
+) *inbox {
+	// the inbox may invoke the handler after proto has been closed by
+	// dispatch(), so it requires its own copy of the payload.
+	n := *proto
+	n.Payload = proto.Payload.Clone()
+
+	d := &delivery{
+		ID: proto.ID,
+		Handle: func() {
+			l.handle(ctx, sess, &n, spanOpts)
+		},
+		Done: func() {
+			n.Payload.Close()
+			done()
+		},
 	}
+
+	b := l.inbox(sess.ID())
+
+	var dropped *delivery
+	if l.ordered {
+		dropped = b.Enqueue(d)
+	} else {
+		dropped = b.Push(d)
+	}
+
+	if dropped != nil {
+		logNotificationDropped(l.logger, l.peerID, sess.ID(), dropped.ID, l.overflow)
+		dropped.Done()
+	}
+
+	if l.ordered {
+		return b
+	}
+
+	return nil
+}
+
+// inbox returns the inbox for the session with the given ID, creating it if
+// necessary.
+func (l *listener) inbox(id ident.SessionID) *inbox {
+	l.mutex.RLock()
+	b, ok := l.inboxes[id]
+	l.mutex.RUnlock()
+
+	if ok {
+		return b
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if b, ok = l.inboxes[id]; !ok {
+		b = newInbox(l.limit, l.buffer, l.overflow)
+		l.inboxes[id] = b
+	}
+
+	return b
 }
 
 // findUnicastTarget returns the session that should receive the unicast
@@ -408,7 +635,8 @@ func (l *listener) findUnicastTarget(
 }
 
 // findMulticastTargets returns the sessions that should receive the multicast
-// notification n.
+// notification n. Only those sessions that match the constraint and are
+// listening to the notification's namespace are returned.
 func (l *listener) findMulticastTargets(
 	n *rinq.Notification,
 	msg *amqp.Delivery,
@@ -421,8 +649,20 @@ func (l *listener) findMulticastTargets(
 		return
 	}
 
+	// l.mutex must not be held while matching, as session.Attrs() locks the
+	// session, whereas sessions remain locked while calling Listen(), which
+	// locks l.mutex.
+	listeners := l.listeners(n.Namespace)
+	listening := 0
+
 	l.sessions.Each(
 		func(session *localsession.Session) {
+			if _, ok := listeners[session.ID()]; !ok {
+				return
+			}
+
+			listening++
+
 			_, attrs := session.Attrs()
 			if attrs.MatchConstraint(n.Namespace, n.Constraint) {
 				sessions = append(sessions, session)
@@ -430,9 +670,64 @@ func (l *listener) findMulticastTargets(
 		},
 	)
 
+	if r, ok := l.metrics.(metrics.NotificationRecorder); ok {
+		r.RecordNotificationMatched(n.Namespace, n.Type, listening, len(sessions))
+	}
+
 	return
 }
 
+// listeners returns the IDs of the sessions that are listening to the ns
+// namespace.
+func (l *listener) listeners(ns string) map[ident.SessionID]struct{} {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	ids := map[ident.SessionID]struct{}{}
+
+	for id, handlers := range l.handlers {
+		if _, ok := handlers[ns]; ok {
+			ids[id] = struct{}{}
+		}
+	}
+
+	return ids
+}
+
+// report sends a rinq.DispatchReport to the source session of the multicast
+// notification n, which was dispatched to count sessions.
+//
+// Failures are logged rather than returned, as the notification is still
+// handled by the sessions that it was dispatched to.
+func (l *listener) report(ctx context.Context, n *rinq.Notification, count int) {
+	ctx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+
+	seq := atomic.AddUint32(&l.reportSeq, 1)
+	msgID := l.peerID.Session(0).At(0).Message(seq)
+
+	out := rinq.NewPayload(rinq.DispatchReport{
+		ID:        n.ID,
+		Peer:      l.peerID,
+		Namespace: n.Namespace,
+		Type:      n.Type,
+		Count:     count,
+	})
+	defer out.Close()
+
+	err := l.notifier.NotifyReply(
+		ctx,
+		msgID,
+		trace.Get(ctx),
+		n.ID.Ref.ID,
+		notify.DispatchNamespace,
+		n.ID,
+		out,
+	)
+
+	logDispatchReported(l.logger, l.peerID, n, count, err)
+}
+
 // handle invokes the notification handler for a specific session, if one is
 // present.
 func (l *listener) handle(
@@ -445,17 +740,41 @@ func (l *listener) handle(
 	h := l.handlers[sess.ID()][proto.Namespace]
 	l.mutex.RUnlock()
 
-	if h != nil {
-		n := *proto
-		n.Payload = n.Payload.Clone()
+	if h == nil {
+		l.recordDropped(proto)
+		return
+	}
+
+	if r, ok := l.metrics.(metrics.NotificationRecorder); ok {
+		r.RecordNotificationDelivered(proto.Namespace, proto.Type)
+	}
+
+	n := *proto
+	n.Payload = n.Payload.Clone()
+
+	span := l.sampler.Tracer(l.tracer, n.Namespace, trace.Get(ctx)).StartSpan("", spanOpts...)
+	defer span.Finish()
 
-		span := l.tracer.StartSpan("", spanOpts...)
-		defer span.Finish()
+	// record the panic on the span before it propagates, otherwise the
+	// span is finished without any indication that the handler failed.
+	defer func() {
+		if v := recover(); v != nil {
+			opentr.LogListenerPanic(span, v)
+			panic(v)
+		}
+	}()
+
+	h(
+		opentracing.ContextWithSpan(ctx, span),
+		sess,
+		n,
+	)
+}
 
-		h(
-			opentracing.ContextWithSpan(ctx, span),
-			sess,
-			n,
-		)
+// recordDropped records that n was not delivered to a session, if the metrics
+// recorder supports notification metrics.
+func (l *listener) recordDropped(n *rinq.Notification) {
+	if r, ok := l.metrics.(metrics.NotificationRecorder); ok {
+		r.RecordNotificationDropped(n.Namespace, n.Type)
 	}
 }
@@ -2,7 +2,9 @@ package notifyamqp
 
 import (
 	"github.com/jmalloc/twelf/src/twelf"
+	"github.com/rinq/rinq-go/src/rinq"
 	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
 )
 
 func logInvalidMessageID(
@@ -31,6 +33,70 @@ func logIgnoredMessage(
 	)
 }
 
+func logMulticastDispatched(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	n *rinq.Notification,
+	count int,
+) {
+	logger.Debug(
+		"%s listener dispatched '%s::%s' notification %s to %d session(s) matching %s",
+		peerID.ShortString(),
+		n.Namespace,
+		n.Type,
+		n.ID.ShortString(),
+		count,
+		n.Constraint,
+	)
+}
+
+func logDispatchReported(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	n *rinq.Notification,
+	count int,
+	err error,
+) {
+	if err != nil {
+		logger.Log(
+			"%s listener could not report dispatch of '%s::%s' notification %s to %s: %s",
+			peerID.ShortString(),
+			n.Namespace,
+			n.Type,
+			n.ID.ShortString(),
+			n.ID.Ref.ID.ShortString(),
+			err,
+		)
+		return
+	}
+
+	logger.Debug(
+		"%s listener reported dispatch of '%s::%s' notification %s to %d session(s) to %s",
+		peerID.ShortString(),
+		n.Namespace,
+		n.Type,
+		n.ID.ShortString(),
+		count,
+		n.ID.Ref.ID.ShortString(),
+	)
+}
+
+func logNotificationDropped(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	sessID ident.SessionID,
+	msgID ident.MessageID,
+	policy options.OverflowPolicy,
+) {
+	logger.Log(
+		"%s listener dropped notification %s for %s, the session's buffer is full (overflow: %s)",
+		peerID.ShortString(),
+		msgID.ShortString(),
+		sessID.ShortString(),
+		policy,
+	)
+}
+
 func logListenerStart(
 	logger twelf.Logger,
 	peerID ident.PeerID,
@@ -24,10 +24,23 @@ const (
 
 	// constraintHeader specifies the constraint for multicast notifications.
 	constraintHeader = "c"
+
+	// replyHeader specifies the ID of the request notification that a reply
+	// notification is sent in response to. The AMQP correlation ID can not be
+	// used, as it carries the trace ID.
+	replyHeader = "r"
+
+	// reportHeader is present in multicast notifications when the source
+	// session expects a dispatch report from each peer that receives it.
+	reportHeader = "d"
 )
 
-func unicastRoutingKey(ns string, p ident.PeerID) string {
-	return ns + "." + p.String()
+func unicastRoutingKey(tenant, ns string, p ident.PeerID) string {
+	return amqputil.TenantKey(tenant, ns+"."+p.String())
+}
+
+func multicastRoutingKey(tenant, ns string) string {
+	return amqputil.TenantKey(tenant, ns)
 }
 
 func packCommonAttributes(
@@ -79,6 +92,51 @@ func unpackTarget(msg *amqp.Delivery) (id ident.SessionID, err error) {
 	return
 }
 
+// packReplyTo marks msg as a request notification, to which the source
+// session expects a reply.
+func packReplyTo(msg *amqp.Publishing, source ident.SessionID) {
+	msg.ReplyTo = source.String()
+}
+
+// packCorrelationID marks msg as a reply to the request notification
+// identified by corrID.
+func packCorrelationID(msg *amqp.Publishing, corrID ident.MessageID) {
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+
+	msg.Headers[replyHeader] = corrID.String()
+}
+
+func unpackCorrelation(msg *amqp.Delivery) (isRequest bool, corrID ident.MessageID, err error) {
+	isRequest = msg.ReplyTo != ""
+
+	if v, ok := msg.Headers[replyHeader]; ok {
+		if s, ok := v.(string); ok {
+			corrID, err = ident.ParseMessageID(s)
+		} else {
+			err = errors.New("reply header is not a string")
+		}
+	}
+
+	return
+}
+
+// packReportRequest marks msg as a multicast notification for which the
+// source session expects a dispatch report.
+func packReportRequest(msg *amqp.Publishing) {
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+
+	msg.Headers[reportHeader] = true
+}
+
+func unpackReportRequest(msg *amqp.Delivery) bool {
+	_, ok := msg.Headers[reportHeader]
+	return ok
+}
+
 func packConstraint(msg *amqp.Publishing, con constraint.Constraint) {
 	if msg.Headers == nil {
 		msg.Headers = amqp.Table{}
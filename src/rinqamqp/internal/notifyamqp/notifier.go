@@ -2,6 +2,8 @@ package notifyamqp
 
 import (
 	"context"
+	"sync/atomic"
+	"time"
 
 	"github.com/jmalloc/twelf/src/twelf"
 	"github.com/rinq/rinq-go/src/internal/notify"
@@ -9,6 +11,7 @@ import (
 	"github.com/rinq/rinq-go/src/rinq"
 	"github.com/rinq/rinq-go/src/rinq/constraint"
 	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/metrics"
 	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
 	"github.com/streadway/amqp"
 )
@@ -18,20 +21,48 @@ type notifier struct {
 	sm *service.StateMachine
 
 	peerID   ident.PeerID
+	tenant   string
+	prefix   string        // prepended to exchange names
+	batch    time.Duration // zero if batching is disabled
 	channels amqputil.ChannelPool
+	flow     *amqputil.Flow
 	logger   twelf.Logger
+	metrics  metrics.Recorder
+
+	publishes chan publishing // notifications waiting to be batched
+	queued    int32           // number of notifications waiting in a batch, atomic
+}
+
+// publishing is a notification message that is waiting to be published as part
+// of a batch.
+type publishing struct {
+	Exchange string
+	Key      string
+	Message  amqp.Publishing
 }
 
 // newNotifier creates, initializes and returns a new notifier.
 func newNotifier(
 	peerID ident.PeerID,
+	tenant string,
+	prefix string,
+	batch time.Duration,
 	channels amqputil.ChannelPool,
+	flow *amqputil.Flow,
 	logger twelf.Logger,
+	recorder metrics.Recorder,
 ) notify.Notifier {
 	n := &notifier{
 		peerID:   peerID,
+		tenant:   tenant,
+		prefix:   prefix,
+		batch:    batch,
 		channels: channels,
+		flow:     flow,
 		logger:   logger,
+		metrics:  recorder,
+
+		publishes: make(chan publishing),
 	}
 
 	n.sm = service.NewStateMachine(n.run, n.finalize)
@@ -50,18 +81,76 @@ func (n *notifier) NotifyUnicast(
 	ns string,
 	notificationType string,
 	payload *rinq.Payload,
-) (err error) {
+) error {
+	msg := amqp.Publishing{
+		MessageId: msgID.String(),
+	}
+
+	return n.sendUnicast(ctx, msg, traceID, target, ns, notificationType, payload)
+}
+
+func (n *notifier) NotifyRequest(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	target ident.SessionID,
+	ns string,
+	notificationType string,
+	payload *rinq.Payload,
+) error {
+	msg := amqp.Publishing{
+		MessageId: msgID.String(),
+	}
+
+	packReplyTo(&msg, msgID.Ref.ID)
+
+	return n.sendUnicast(ctx, msg, traceID, target, ns, notificationType, payload)
+}
+
+func (n *notifier) NotifyReply(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	target ident.SessionID,
+	ns string,
+	corrID ident.MessageID,
+	payload *rinq.Payload,
+) error {
 	msg := amqp.Publishing{
 		MessageId: msgID.String(),
 	}
 
+	packCorrelationID(&msg, corrID)
+
+	return n.sendUnicast(ctx, msg, traceID, target, ns, "", payload)
+}
+
+// sendUnicast packs the common attributes of a unicast notification into msg
+// and sends it to the target session.
+func (n *notifier) sendUnicast(
+	ctx context.Context,
+	msg amqp.Publishing,
+	traceID string,
+	target ident.SessionID,
+	ns string,
+	notificationType string,
+	payload *rinq.Payload,
+) (err error) {
 	packCommonAttributes(&msg, traceID, ns, notificationType, payload)
 	packTarget(&msg, target)
+	amqputil.PackTenant(&msg, n.tenant)
+
+	amqputil.PackBaggage(ctx, &msg)
+	amqputil.PackHeaders(ctx, &msg)
 
 	err = amqputil.PackSpanContext(ctx, &msg)
 
 	if err == nil {
-		err = n.send(unicastExchange, unicastRoutingKey(ns, target.Peer), msg)
+		err = n.send(ctx, unicastExchange, unicastRoutingKey(n.tenant, ns, target.Peer), msg)
+	}
+
+	if err == nil {
+		n.recordPublished(ns, notificationType)
 	}
 
 	return
@@ -75,24 +164,74 @@ func (n *notifier) NotifyMulticast(
 	ns string,
 	notificationType string,
 	payload *rinq.Payload,
-) (err error) {
+) error {
 	msg := amqp.Publishing{
 		MessageId: msgID.String(),
 	}
 
+	return n.sendMulticast(ctx, msg, traceID, con, ns, notificationType, payload)
+}
+
+func (n *notifier) NotifyMulticastReport(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	con constraint.Constraint,
+	ns string,
+	notificationType string,
+	payload *rinq.Payload,
+) error {
+	msg := amqp.Publishing{
+		MessageId: msgID.String(),
+	}
+
+	packReportRequest(&msg)
+
+	return n.sendMulticast(ctx, msg, traceID, con, ns, notificationType, payload)
+}
+
+// sendMulticast packs the common attributes of a multicast notification into
+// msg and sends it to the sessions that match con.
+func (n *notifier) sendMulticast(
+	ctx context.Context,
+	msg amqp.Publishing,
+	traceID string,
+	con constraint.Constraint,
+	ns string,
+	notificationType string,
+	payload *rinq.Payload,
+) (err error) {
 	packCommonAttributes(&msg, traceID, ns, notificationType, payload)
 	packConstraint(&msg, con)
+	amqputil.PackTenant(&msg, n.tenant)
+
+	amqputil.PackBaggage(ctx, &msg)
+	amqputil.PackHeaders(ctx, &msg)
 
 	err = amqputil.PackSpanContext(ctx, &msg)
 
 	if err == nil {
-		err = n.send(multicastExchange, ns, msg)
+		err = n.send(ctx, multicastExchange, multicastRoutingKey(n.tenant, ns), msg)
+	}
+
+	if err == nil {
+		n.recordPublished(ns, notificationType)
 	}
 
 	return
 }
 
-func (n *notifier) send(exchange, key string, msg amqp.Publishing) error {
+// recordPublished records the publication of a notification, if the metrics
+// recorder supports notification metrics.
+func (n *notifier) recordPublished(ns, t string) {
+	if r, ok := n.metrics.(metrics.NotificationRecorder); ok {
+		r.RecordNotificationPublished(ns, t)
+	}
+}
+
+func (n *notifier) send(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	exchange = n.prefix + exchange
+
 	select {
 	case <-n.sm.Graceful:
 		return context.Canceled
@@ -102,6 +241,26 @@ func (n *notifier) send(exchange, key string, msg amqp.Publishing) error {
 		// ready to publish
 	}
 
+	if err := n.flow.Wait(ctx); err != nil {
+		return err
+	}
+
+	if n.batch != 0 {
+		// the body usually references the buffer of a pooled payload, which
+		// the caller may recycle before the batch is published
+		msg.Body = append([]byte(nil), msg.Body...)
+
+		select {
+		case n.publishes <- publishing{exchange, key, msg}:
+			atomic.AddInt32(&n.queued, 1)
+			return nil
+		case <-n.sm.Graceful:
+			return context.Canceled
+		case <-n.sm.Forceful:
+			return context.Canceled
+		}
+	}
+
 	channel, err := n.channels.Get()
 	if err != nil {
 		return err
@@ -117,15 +276,66 @@ func (n *notifier) send(exchange, key string, msg amqp.Publishing) error {
 	)
 }
 
+func (n *notifier) Queued() int {
+	return int(atomic.LoadInt32(&n.queued))
+}
+
 func (n *notifier) run() (service.State, error) {
-	logNotifierStart(n.logger, n.peerID)
+	logNotifierStart(n.logger, n.peerID, n.batch)
 
-	select {
-	case <-n.sm.Graceful:
-		return nil, nil
+	var (
+		batch []publishing
+		timer <-chan time.Time // nil until the first notification in a batch
+	)
 
-	case <-n.sm.Forceful:
-		return nil, nil
+	for {
+		select {
+		case p := <-n.publishes:
+			batch = append(batch, p)
+			if timer == nil {
+				timer = time.After(n.batch)
+			}
+
+		case <-timer:
+			n.flush(batch)
+			batch, timer = nil, nil
+
+		case <-n.sm.Graceful:
+			n.flush(batch)
+			return nil, nil
+
+		case <-n.sm.Forceful:
+			return nil, nil
+		}
+	}
+}
+
+// flush publishes a batch of notifications using a single channel.
+func (n *notifier) flush(batch []publishing) {
+	if len(batch) == 0 {
+		return
+	}
+
+	defer atomic.AddInt32(&n.queued, -int32(len(batch)))
+
+	channel, err := n.channels.Get()
+	if err != nil {
+		logBatchError(n.logger, n.peerID, len(batch), err)
+		return
+	}
+	defer n.channels.Put(channel)
+
+	for idx, p := range batch {
+		if err := channel.Publish(
+			p.Exchange,
+			p.Key,
+			false, // mandatory
+			false, // immediate
+			p.Message,
+		); err != nil {
+			logBatchError(n.logger, n.peerID, len(batch)-idx, err)
+			return
+		}
 	}
 }
 
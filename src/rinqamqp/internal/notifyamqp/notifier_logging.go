@@ -1,6 +1,8 @@
 package notifyamqp
 
 import (
+	"time"
+
 	"github.com/jmalloc/twelf/src/twelf"
 	"github.com/rinq/rinq-go/src/rinq/ident"
 )
@@ -8,10 +10,26 @@ import (
 func logNotifierStart(
 	logger twelf.Logger,
 	peerID ident.PeerID,
+	batch time.Duration,
 ) {
 	logger.Debug(
-		"%s notifier started",
+		"%s notifier started (batch: %s)",
+		peerID.ShortString(),
+		batch,
+	)
+}
+
+func logBatchError(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	count int,
+	err error,
+) {
+	logger.Log(
+		"%s notifier discarded %d batched notification(s): %s",
 		peerID.ShortString(),
+		count,
+		err,
 	)
 }
 
@@ -1,8 +1,86 @@
 package notifyamqp
 
-import "github.com/rinq/rinq-go/src/rinq/ident"
+import (
+	"fmt"
+	"time"
+
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+	"github.com/streadway/amqp"
+)
 
 // notifyQueue returns the name of the queue used for incoming notifications.
-func notifyQueue(id ident.PeerID) string {
-	return id.ShortString() + ".ntf"
+func notifyQueue(prefix string, id ident.PeerID) string {
+	return prefix + id.ShortString() + ".ntf"
+}
+
+// streamQueue returns the name of the stream that retains the multicast
+// notifications for the ns namespace.
+func streamQueue(prefix, tenant, ns string) string {
+	return prefix + "ntf.stream." + multicastRoutingKey(tenant, ns)
+}
+
+// declareStream declares the stream for the ns namespace and binds it to the
+// multicast exchange.
+func declareStream(
+	channel *amqp.Channel,
+	prefix string,
+	tenant string,
+	ns string,
+	opts options.StreamOptions,
+) (string, error) {
+	queue := streamQueue(prefix, tenant, ns)
+
+	if _, err := channel.QueueDeclare(
+		queue,
+		true,  // durable
+		false, // autoDelete
+		false, // exclusive,
+		false, // noWait
+		streamArguments(opts),
+	); err != nil {
+		return "", err
+	}
+
+	return queue, channel.QueueBind(
+		queue,
+		multicastRoutingKey(tenant, ns),
+		prefix+multicastExchange,
+		false, // noWait
+		nil,   // args
+	)
+}
+
+// streamArguments returns the arguments used to declare a stream with the
+// given options.
+func streamArguments(opts options.StreamOptions) amqp.Table {
+	args := amqp.Table{
+		"x-queue-type": "stream",
+	}
+
+	if opts.MaxAge != 0 {
+		args["x-max-age"] = fmt.Sprintf("%ds", opts.MaxAge/time.Second)
+	}
+
+	if opts.MaxBytes != 0 {
+		args["x-max-length-bytes"] = int64(opts.MaxBytes)
+	}
+
+	return args
+}
+
+// streamOffsetArgument returns the value of the consumer argument that starts
+// consuming a stream at offset.
+func streamOffsetArgument(offset rinq.StreamOffset) interface{} {
+	switch offset {
+	case rinq.StreamNext:
+		return "next"
+	case rinq.StreamFirst:
+		return "first"
+	case rinq.StreamLast:
+		return "last"
+	default:
+		return int64(offset)
+	}
 }
@@ -0,0 +1,370 @@
+package notifyamqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jmalloc/twelf/src/twelf"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rinq/rinq-go/src/internal/notify"
+	"github.com/rinq/rinq-go/src/internal/opentr"
+	"github.com/rinq/rinq-go/src/internal/revisions"
+	"github.com/rinq/rinq-go/src/internal/sampling"
+	"github.com/rinq/rinq-go/src/internal/service"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+	"github.com/rinq/rinq-go/src/rinq/trace"
+	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
+	"github.com/streadway/amqp"
+)
+
+// streamPreFetch is the number of notifications that the broker delivers to
+// each stream consumer before they are acknowledged. The broker requires a
+// pre-fetch limit when consuming from a stream.
+const streamPreFetch = 100
+
+// streamOffsetHeader is the header that the broker adds to each message
+// delivered from a stream, containing the message's offset.
+const streamOffsetHeader = "x-stream-offset"
+
+type streams struct {
+	service.Service
+	sm *service.StateMachine
+
+	peerID    ident.PeerID
+	tenant    string
+	prefix    string                           // prepended to exchange and queue names
+	options   map[string]options.StreamOptions // map of namespace to stream options
+	channels  amqputil.ChannelPool
+	revisions revisions.Store
+	logger    twelf.Logger
+	tracer    opentracing.Tracer
+	sampler   *sampling.Sampler
+
+	parentCtx context.Context // parent of all contexts passed to handlers
+	cancelCtx func()          // cancels parentCtx when the service stops
+
+	// state-machine data
+	consumers map[string]*streamConsumer // map of namespace to consumer
+	failures  chan error                 // receives the error of a consumer that fails
+	wg        sync.WaitGroup             // tracks running consumers
+}
+
+// streamConsumer consumes the stream for a single namespace on its own
+// channel.
+type streamConsumer struct {
+	namespace  string
+	handler    rinq.StreamHandler
+	channel    *amqp.Channel
+	deliveries <-chan amqp.Delivery
+	amqpClosed chan *amqp.Error
+}
+
+// newStreams creates, starts and returns a new stream consumer service.
+func newStreams(
+	peerID ident.PeerID,
+	tenant string,
+	prefix string,
+	opts map[string]options.StreamOptions,
+	channels amqputil.ChannelPool,
+	revs revisions.Store,
+	logger twelf.Logger,
+	tracer opentracing.Tracer,
+	sampler *sampling.Sampler,
+) notify.Streams {
+	s := &streams{
+		peerID:    peerID,
+		tenant:    tenant,
+		prefix:    prefix,
+		options:   opts,
+		channels:  channels,
+		revisions: revs,
+		logger:    logger,
+		tracer:    tracer,
+		sampler:   sampler,
+
+		consumers: map[string]*streamConsumer{},
+		failures:  make(chan error, 1),
+	}
+
+	s.parentCtx, s.cancelCtx = context.WithCancel(context.Background())
+
+	s.sm = service.NewStateMachine(s.run, s.finalize)
+	s.Service = s.sm
+
+	go s.sm.Run()
+
+	return s
+}
+
+func (s *streams) Listen(ns string, offset rinq.StreamOffset, h rinq.StreamHandler) (added bool, err error) {
+	if offset < rinq.StreamLast {
+		return false, fmt.Errorf("invalid stream offset: %d", offset)
+	}
+
+	err = s.sm.Do(func() error {
+		if c, ok := s.consumers[ns]; ok {
+			delete(s.consumers, ns)
+			_ = c.channel.Close()
+		} else {
+			added = true
+		}
+
+		c, err := s.consume(ns, offset, h)
+		if err != nil {
+			return err
+		}
+
+		s.consumers[ns] = c
+		return nil
+	})
+
+	return
+}
+
+func (s *streams) Unlisten(ns string) (removed bool, err error) {
+	err = s.sm.Do(func() error {
+		c, ok := s.consumers[ns]
+		if !ok {
+			return nil
+		}
+
+		delete(s.consumers, ns)
+		removed = true
+
+		return c.channel.Close()
+	})
+
+	return
+}
+
+// consume starts consuming the stream for ns at offset, on a new channel.
+func (s *streams) consume(
+	ns string,
+	offset rinq.StreamOffset,
+	h rinq.StreamHandler,
+) (*streamConsumer, error) {
+	channel, err := s.channels.GetQOS(streamPreFetch) // do not return to pool, closed when the consumer stops
+	if err != nil {
+		return nil, err
+	}
+
+	c := &streamConsumer{
+		namespace:  ns,
+		handler:    h,
+		channel:    channel,
+		amqpClosed: make(chan *amqp.Error, 1),
+	}
+
+	channel.NotifyClose(c.amqpClosed)
+
+	queue, err := declareStream(channel, s.prefix, s.tenant, ns, s.options[ns])
+	if err == nil {
+		c.deliveries, err = channel.Consume(
+			queue,
+			"",    // generate a consumer tag
+			false, // autoAck, required by streams
+			false, // exclusive
+			false, // noLocal
+			false, // noWait
+			amqp.Table{
+				"x-stream-offset": streamOffsetArgument(offset),
+			},
+		)
+	}
+
+	if err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go s.receive(c)
+
+	return c, nil
+}
+
+// receive dispatches the deliveries of c until its channel is closed.
+func (s *streams) receive(c *streamConsumer) {
+	defer s.wg.Done()
+
+	for msg := range c.deliveries {
+		batch := amqputil.Drain(msg, c.deliveries, streamPreFetch)
+
+		// the source revisions of the notifications are looked up together,
+		// so that the state of the sessions that sent them is not fetched
+		// with a separate request for each.
+		var sources map[string]rinq.Revision
+		if len(batch) > 1 {
+			sources = amqputil.GetSources(s.parentCtx, s.revisions, batch)
+		}
+
+		for i := range batch {
+			s.dispatch(c, &batch[i], sources[batch[i].MessageId])
+		}
+	}
+
+	// amqpClosed is closed without a value if the channel is closed by
+	// Unlisten() or when the service stops.
+	if err, ok := <-c.amqpClosed; ok {
+		select {
+		case s.failures <- err:
+		default:
+		}
+	}
+}
+
+// run is the state entered when the service starts.
+func (s *streams) run() (service.State, error) {
+	logStreamsStart(s.logger, s.peerID)
+
+	for {
+		select {
+		case req := <-s.sm.Commands:
+			s.sm.Execute(req)
+
+		case err := <-s.failures:
+			return nil, err
+
+		case <-s.sm.Graceful:
+			return s.waitForHandlers, nil
+
+		case <-s.sm.Forceful:
+			return nil, nil
+		}
+	}
+}
+
+// waitForHandlers is the state entered when a graceful stop is requested. It
+// stops consuming and waits for any handlers that are executing to return.
+func (s *streams) waitForHandlers() (service.State, error) {
+	s.closeConsumers()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-s.sm.Forceful:
+	}
+
+	return nil, nil
+}
+
+// finalize is the state-machine finalizer, it is called immediately before the
+// Done() channel is closed.
+func (s *streams) finalize(err error) error {
+	s.cancelCtx()
+	s.closeConsumers()
+
+	logStreamsStop(s.logger, s.peerID, err)
+
+	return err
+}
+
+// closeConsumers closes the channels of all consumers.
+func (s *streams) closeConsumers() {
+	for ns, c := range s.consumers {
+		delete(s.consumers, ns)
+		_ = c.channel.Close()
+	}
+}
+
+// dispatch validates a notification delivered from a stream and passes it to
+// the consumer's handler. Every delivery is acknowledged, so that the broker
+// continues to deliver notifications. source is the revision of the session
+// that sent the notification, or nil if it has not yet been looked up.
+func (s *streams) dispatch(c *streamConsumer, msg *amqp.Delivery, source rinq.Revision) {
+	defer func() {
+		_ = msg.Ack(false) // false = single message
+	}()
+
+	n, offset, spanOpts, err := s.unpack(msg, source)
+	if err != nil {
+		logIgnoredStreamMessage(s.logger, s.peerID, c.namespace, msg.MessageId, err)
+		return
+	}
+
+	ctx := amqputil.UnpackTrace(s.parentCtx, msg)
+	ctx = amqputil.UnpackBaggage(ctx, msg)
+	ctx = trace.WithPeer(ctx, s.peerID)
+	ctx = trace.WithSession(ctx, n.ID.Ref)
+
+	span := s.sampler.Tracer(s.tracer, c.namespace, trace.Get(ctx)).StartSpan("", spanOpts...)
+	defer span.Finish()
+
+	// record the panic on the span before it propagates, otherwise the span is
+	// finished without any indication that the handler failed.
+	defer func() {
+		if v := recover(); v != nil {
+			opentr.LogListenerPanic(span, v)
+			panic(v)
+		}
+	}()
+
+	c.handler(
+		opentracing.ContextWithSpan(ctx, span),
+		n,
+		offset,
+	)
+}
+
+// unpack builds a notification from a message delivered from a stream.
+func (s *streams) unpack(msg *amqp.Delivery, source rinq.Revision) (
+	n rinq.Notification,
+	offset rinq.StreamOffset,
+	spanOpts []opentracing.StartSpanOption,
+	err error,
+) {
+	n.IsMulticast = true
+
+	n.ID, err = ident.ParseMessageID(msg.MessageId)
+	if err != nil {
+		return
+	}
+
+	o, ok := msg.Headers[streamOffsetHeader].(int64)
+	if !ok {
+		err = errors.New("stream offset header is not an integer")
+		return
+	}
+	offset = rinq.StreamOffset(o)
+
+	if err = amqputil.CheckTenant(msg, s.tenant); err != nil {
+		return
+	}
+
+	n.Source = source
+	if n.Source == nil {
+		n.Source, err = s.revisions.GetRevision(n.ID.Ref)
+		if err != nil {
+			return
+		}
+	}
+
+	n.Constraint, err = unpackConstraint(msg)
+	if err != nil {
+		return
+	}
+
+	spanOpts, err = unpackSpanOptions(msg, s.tracer)
+	if err != nil {
+		return
+	}
+
+	n.Namespace, n.Type, n.Payload, err = unpackCommonAttributes(msg)
+	if err != nil {
+		n.Payload.Close()
+		return
+	}
+
+	n.Headers = amqputil.UnpackHeaders(msg)
+
+	return
+}
@@ -0,0 +1,51 @@
+package notifyamqp
+
+import (
+	"github.com/jmalloc/twelf/src/twelf"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+func logIgnoredStreamMessage(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	namespace string,
+	msgID string,
+	err error,
+) {
+	logger.Debug(
+		"%s stream consumer ignored AMQP message '%s' from '%s' stream, %s",
+		peerID.ShortString(),
+		msgID,
+		namespace,
+		err,
+	)
+}
+
+func logStreamsStart(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+) {
+	logger.Debug(
+		"%s stream consumer started",
+		peerID.ShortString(),
+	)
+}
+
+func logStreamsStop(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	err error,
+) {
+	if err == nil {
+		logger.Debug(
+			"%s stream consumer stopped",
+			peerID.ShortString(),
+		)
+	} else {
+		logger.Debug(
+			"%s stream consumer stopped: %s",
+			peerID.ShortString(),
+			err,
+		)
+	}
+}
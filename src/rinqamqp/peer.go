@@ -2,21 +2,27 @@ package rinqamqp
 
 import (
 	"context"
+	"sort"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/jmalloc/twelf/src/twelf"
 	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	"github.com/rinq/rinq-go/src/internal/command"
 	"github.com/rinq/rinq-go/src/internal/localsession"
 	"github.com/rinq/rinq-go/src/internal/namespaces"
-	"github.com/rinq/rinq-go/src/internal/notify"
 	"github.com/rinq/rinq-go/src/internal/opentr"
-	"github.com/rinq/rinq-go/src/internal/remotesession"
+	"github.com/rinq/rinq-go/src/internal/sampling"
 	"github.com/rinq/rinq-go/src/internal/service"
+	"github.com/rinq/rinq-go/src/internal/x/bufferpool"
 	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/clock"
 	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/metrics"
+	"github.com/rinq/rinq-go/src/rinq/options"
 	"github.com/rinq/rinq-go/src/rinq/trace"
-	"github.com/streadway/amqp"
 )
 
 // peer is an AMQP-based implementation of rinq.Peer.
@@ -24,52 +30,110 @@ type peer struct {
 	service.Service
 	sm *service.StateMachine
 
-	id          ident.PeerID
-	broker      *amqp.Connection
-	localStore  *localsession.Store
-	remoteStore remotesession.Store
-	invoker     command.Invoker
-	server      command.Server
-	notifier    notify.Notifier
-	listener    notify.Listener
-	logger      twelf.Logger
-	tracer      opentracing.Tracer
+	id           ident.PeerID
+	connector    *connector
+	transport    *transportRef
+	localStore   *localsession.Store
+	invoker      *invokerProxy
+	notifier     notifierProxy
+	listener     *listenerProxy
+	logger       twelf.Logger
+	tracer       opentracing.Tracer
+	sampler      *sampling.Sampler
+	metrics      metrics.Recorder
+	clock        clock.Clock
+	restartable  bool
+	attrQuota    options.QuotaOptions
+	attrFeed     []string
+	sessionLimit options.SessionLimitOptions
+
+	seq uint32
+
+	limitMutex   sync.Mutex
+	sessionCount uint      // number of sessions counted towards the session limit
+	tokens       float64   // sessions that may be created before the rate limit applies
+	refilledAt   time.Time // the time at which tokens was last refilled
+
+	// connected is true if the current transport is usable, only accessed by
+	// the state machine
+	connected bool
+
+	internalSession ident.SessionID // source of requests not made by a session
+	internalSeq     uint32
+
+	eventsMutex  sync.Mutex
+	events       chan rinq.PeerEvent
+	eventsClosed bool
+
+	observersMutex sync.RWMutex
+	observers      []rinq.SessionObserver
+
+	catalogMutex sync.RWMutex
+	catalog      map[command.Namespace]catalogEntry
+
+	streamsMutex sync.Mutex
+	streams      map[string]*streamEntry
+
+	// the time at which, and reason why, the broker last blocked the
+	// connection, only accessed by flowChanged()
+	blockedAt     time.Time
+	blockedReason string
+}
 
-	seq        uint32
-	amqpClosed chan *amqp.Error
+// catalogEntry describes the handler of a namespace that the peer listens to.
+type catalogEntry struct {
+	handler      rinq.CommandHandler // the wrapped handler, restored when the peer restarts
+	mux          *rinq.CommandMux    // nil if the handler is not a mux
+	registeredAt time.Time
 }
 
 func newPeer(
 	id ident.PeerID,
-	broker *amqp.Connection,
-	localStore *localsession.Store,
-	remoteStore remotesession.Store,
-	invoker command.Invoker,
-	server command.Server,
-	notifier notify.Notifier,
-	listener notify.Listener,
+	c *connector,
+	ref *transportRef,
 	logger twelf.Logger,
 	tracer opentracing.Tracer,
+	sampler *sampling.Sampler,
+	recorder metrics.Recorder,
+	clk clock.Clock,
+	restartable bool,
+	attrQuota options.QuotaOptions,
+	attrFeed []string,
+	sessionLimit options.SessionLimitOptions,
 ) *peer {
 	p := &peer{
-		id:          id,
-		broker:      broker,
-		localStore:  localStore,
-		remoteStore: remoteStore,
-		invoker:     invoker,
-		server:      server,
-		notifier:    notifier,
-		listener:    listener,
-		logger:      logger,
-		tracer:      tracer,
-
-		amqpClosed: make(chan *amqp.Error, 1),
+		id:           id,
+		connector:    c,
+		transport:    ref,
+		localStore:   c.sessions,
+		invoker:      newInvokerProxy(ref),
+		notifier:     notifierProxy{ref},
+		listener:     newListenerProxy(ref),
+		logger:       logger,
+		tracer:       tracer,
+		sampler:      sampler,
+		metrics:      recorder,
+		clock:        clk,
+		restartable:  restartable,
+		attrQuota:    attrQuota,
+		attrFeed:     attrFeed,
+		sessionLimit: sessionLimit,
+
+		connected: true,
+		events:    make(chan rinq.PeerEvent, eventBufferSize),
+		catalog:   map[command.Namespace]catalogEntry{},
+		streams:   map[string]*streamEntry{},
 	}
 
+	// reserve a session ID that is never created, for use as the source of
+	// requests made by Request.CallSource() and Broadcast()
+	p.internalSession = id.Session(atomic.AddUint32(&p.seq, 1))
+
 	p.sm = service.NewStateMachine(p.run, p.finalize)
 	p.Service = p.sm
 
-	broker.NotifyClose(p.amqpClosed)
+	ref.get().flow.Observe(p.flowChanged)
+	ref.get().invoker.ObserveLostPeers(p.peerLost)
 
 	go p.sm.Run()
 
@@ -80,7 +144,12 @@ func (p *peer) ID() ident.PeerID {
 	return p.id
 }
 
-func (p *peer) Session() rinq.Session {
+func (p *peer) Session() (rinq.Session, error) {
+	if err := p.acquireSession(); err != nil {
+		logSessionLimit(p.logger, p.id, err)
+		return nil, err
+	}
+
 	id := p.id.Session(
 		atomic.AddUint32(&p.seq, 1),
 	)
@@ -90,89 +159,356 @@ func (p *peer) Session() rinq.Session {
 		p.invoker,
 		p.notifier,
 		p.listener,
+		p.attrQuota,
+		p.attrFeed,
 		p.logger,
 		p.tracer,
+		p.sampler,
 	)
 
 	p.localStore.Add(sess)
-	go func() {
-		<-sess.Done()
-		p.localStore.Remove(sess.ID())
-	}()
+	p.emit(rinq.PeerEvent{Type: rinq.SessionCreatedEvent, Session: id})
+
+	observers := p.sessionObservers()
+	for _, o := range observers {
+		o.SessionCreated(sess)
+	}
+
+	sess.OnDestroy(func() {
+		p.localStore.Remove(id)
+		p.releaseSession()
+		p.emit(rinq.PeerEvent{Type: rinq.SessionDestroyedEvent, Session: id})
+
+		for _, o := range observers {
+			o.SessionDestroyed(sess)
+		}
+	})
+
+	return sess, nil
+}
+
+func (p *peer) Capabilities(id ident.PeerID) (rinq.PeerCapabilities, bool) {
+	if id == p.id {
+		return rinq.PeerCapabilities{
+			Revision: rinq.ProtocolRevision,
+			Features: rinq.Features,
+		}, true
+	}
+
+	return p.invoker.Capabilities(id)
+}
+
+func (p *peer) Stats() rinq.PeerStats {
+	s := rinq.PeerStats{
+		PendingCalls:        p.invoker.PendingCalls(),
+		InFlightCommands:    p.transport.get().server.InFlight(),
+		QueuedNotifications: p.notifier.Queued(),
+		LocalSessions:       p.localStore.Len(),
+		RemoteSessions:      p.transport.get().remoteStore.Stats().Size,
+	}
+
+	s.ConnectionBlocked, _ = p.transport.get().flow.Blocked()
 
-	return sess
+	b := bufferpool.GetStats()
+	s.BufferPool = rinq.BufferPoolStats{
+		Gets:     b.Gets,
+		Puts:     b.Puts,
+		Discards: b.Discards,
+	}
+
+	return s
+}
+
+func (p *peer) Events() <-chan rinq.PeerEvent {
+	return p.events
+}
+
+func (p *peer) ObserveSessions(o rinq.SessionObserver) {
+	p.observersMutex.Lock()
+	defer p.observersMutex.Unlock()
+
+	p.observers = append(p.observers, o)
+}
+
+// sessionObservers returns the observers that are currently registered.
+func (p *peer) sessionObservers() []rinq.SessionObserver {
+	p.observersMutex.RLock()
+	defer p.observersMutex.RUnlock()
+
+	return p.observers
 }
 
 func (p *peer) Listen(ns string, handler rinq.CommandHandler) error {
+	return p.ListenVersion(ns, 0, handler)
+}
+
+func (p *peer) Unlisten(ns string) error {
+	return p.UnlistenVersion(ns, 0)
+}
+
+func (p *peer) ListenVersion(ns string, version uint, handler rinq.CommandHandler) error {
+	return p.listen(ns, version, handler, nil)
+}
+
+func (p *peer) ListenMux(ns string, m *rinq.CommandMux) error {
+	return p.listen(ns, 0, m.Serve, m)
+}
+
+// listen starts listening to ns at the given version. m is the mux that
+// handler belongs to, if any, which is recorded in the peer's catalog.
+func (p *peer) listen(ns string, version uint, handler rinq.CommandHandler, m *rinq.CommandMux) error {
 	namespaces.MustValidate(ns)
 
-	added, err := p.server.Listen(
-		ns,
-		func(
-			ctx context.Context,
-			req rinq.Request,
-			res rinq.Response,
-		) {
-			span := opentracing.SpanFromContext(ctx)
+	wrapped := p.wrapHandler(handler)
+	added, err := p.transport.get().server.Listen(ns, version, wrapped)
+
+	if err == nil {
+		p.addToCatalog(command.Namespace{Name: ns, Version: version}, wrapped, m)
+	}
+
+	if added {
+		logStartedListening(p.logger, p.id, ns, version)
+		p.emit(rinq.PeerEvent{Type: rinq.ListenEvent, Namespace: ns, Version: version})
+	}
 
-			traceID := trace.Get(ctx)
+	return err
+}
 
-			opentr.SetupCommand(
-				span,
-				req.ID,
-				req.Namespace,
-				req.Command,
-			)
-			opentr.AddTraceID(span, traceID)
-			opentr.LogServerRequest(span, p.id, req.Payload)
-
-			handler(
-				ctx,
+func (p *peer) ListenAll(handlers map[string]rinq.CommandHandler) error {
+	wrapped := make(map[string]rinq.CommandHandler, len(handlers))
+
+	for ns, handler := range handlers {
+		namespaces.MustValidate(ns)
+		wrapped[ns] = p.wrapHandler(handler)
+	}
+
+	added, err := p.transport.get().server.ListenAll(wrapped)
+
+	if err == nil {
+		for ns, h := range wrapped {
+			p.addToCatalog(command.Namespace{Name: ns}, h, nil)
+		}
+	}
+
+	for _, ns := range added {
+		logStartedListening(p.logger, p.id, ns, 0)
+		p.emit(rinq.PeerEvent{Type: rinq.ListenEvent, Namespace: ns})
+	}
+
+	return err
+}
+
+// wrapHandler returns a command handler that sets up tracing and logging for
+// each request, and makes Request.CallSource() available, before invoking h.
+func (p *peer) wrapHandler(h rinq.CommandHandler) rinq.CommandHandler {
+	return func(
+		ctx context.Context,
+		req rinq.Request,
+		res rinq.Response,
+	) {
+		span := opentracing.SpanFromContext(ctx)
+
+		traceID := trace.Get(ctx)
+
+		opentr.SetupCommand(
+			span,
+			req.ID,
+			req.Namespace,
+			req.Command,
+		)
+		opentr.AddTraceID(span, traceID)
+		opentr.LogServerRequest(span, p.id, req.Payload)
+
+		h(
+			rinq.WithPeerCaller(ctx, p.callPeer),
+			req,
+			command.NewResponse(
 				req,
-				command.NewResponse(
-					req,
-					res,
-					p.id,
-					traceID,
-					p.logger,
-					span,
-				),
-			)
-		},
-	)
+				res,
+				p.id,
+				traceID,
+				p.logger,
+				span,
+			),
+		)
+	}
+}
 
-	if added {
-		logStartedListening(p.logger, p.id, ns)
+// callPeer sends a unicast command request to target on behalf of a command
+// handler. It implements rinq.PeerCaller.
+func (p *peer) callPeer(
+	ctx context.Context,
+	target ident.PeerID,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+) (*rinq.Payload, error) {
+	namespaces.MustValidate(ns)
+
+	msgID, traceID := p.nextMessageID(ctx)
+
+	span, ctx := opentr.ChildOf(ctx, p.sampler.Tracer(p.tracer, ns, traceID), ext.SpanKindRPCClient)
+	defer span.Finish()
+
+	opentr.SetupCommand(span, msgID, ns, cmd)
+	opentr.AddTraceID(span, traceID)
+
+	in, err := p.invoker.CallUnicast(ctx, msgID, traceID, target, ns, cmd, out)
+
+	if err == nil {
+		opentr.LogInvokerSuccess(span, in)
+	} else {
+		opentr.LogInvokerError(span, err)
+	}
+
+	return in, err
+}
+
+func (p *peer) Broadcast(ctx context.Context, ns, cmd string, out *rinq.Payload) error {
+	namespaces.MustValidate(ns)
+
+	msgID, traceID := p.nextMessageID(ctx)
+
+	span, ctx := opentr.ChildOf(ctx, p.sampler.Tracer(p.tracer, ns, traceID), ext.SpanKindProducer)
+	defer span.Finish()
+
+	opentr.SetupCommand(span, msgID, ns, cmd)
+	opentr.AddTraceID(span, traceID)
+
+	err := p.invoker.ExecuteMulticast(ctx, msgID, traceID, ns, cmd, out)
+
+	if err != nil {
+		opentr.LogInvokerError(span, err)
 	}
 
 	return err
 }
 
-func (p *peer) Unlisten(ns string) error {
+// nextMessageID returns a new unique message ID for a request that is sent by
+// the peer itself, rather than by one of its sessions.
+//
+// If ctx does not already have a trace ID, the message ID is used as the trace
+// ID.
+func (p *peer) nextMessageID(ctx context.Context) (msgID ident.MessageID, traceID string) {
+	msgID = p.internalSession.At(0).Message(
+		atomic.AddUint32(&p.internalSeq, 1),
+	)
+	traceID = trace.Get(ctx)
+
+	if traceID == "" {
+		traceID = msgID.String()
+	}
+
+	return
+}
+
+func (p *peer) UnlistenVersion(ns string, version uint) error {
 	namespaces.MustValidate(ns)
 
-	removed, err := p.server.Unlisten(ns)
+	removed, err := p.transport.get().server.Unlisten(ns, version)
 
 	if removed {
-		logStoppedListening(p.logger, p.id, ns)
+		p.removeFromCatalog(command.Namespace{Name: ns, Version: version})
+		logStoppedListening(p.logger, p.id, ns, version)
+		p.emit(rinq.PeerEvent{Type: rinq.UnlistenEvent, Namespace: ns, Version: version})
 	}
 
 	return err
 }
 
+func (p *peer) UnlistenAll() error {
+	removed, err := p.transport.get().server.UnlistenAll()
+
+	for _, n := range removed {
+		p.removeFromCatalog(n)
+		logStoppedListening(p.logger, p.id, n.Name, n.Version)
+		p.emit(rinq.PeerEvent{Type: rinq.UnlistenEvent, Namespace: n.Name, Version: n.Version})
+	}
+
+	return err
+}
+
+func (p *peer) Commands() []rinq.CommandInfo {
+	p.catalogMutex.RLock()
+	defer p.catalogMutex.RUnlock()
+
+	var commands []rinq.CommandInfo
+
+	for n, e := range p.catalog {
+		if e.mux == nil {
+			commands = append(commands, rinq.CommandInfo{
+				Namespace:    n.Name,
+				Version:      n.Version,
+				RegisteredAt: e.registeredAt,
+			})
+			continue
+		}
+
+		for _, c := range e.mux.Commands() {
+			c.Namespace = n.Name
+			c.Version = n.Version
+			if c.RegisteredAt.Before(e.registeredAt) {
+				c.RegisteredAt = e.registeredAt
+			}
+
+			commands = append(commands, c)
+		}
+	}
+
+	sort.Slice(commands, func(i, j int) bool {
+		a, b := commands[i], commands[j]
+
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		} else if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+
+		return a.Command < b.Command
+	})
+
+	return commands
+}
+
+// addToCatalog records that the handler h for n has been registered. m is the
+// handler's mux, if it has one.
+func (p *peer) addToCatalog(n command.Namespace, h rinq.CommandHandler, m *rinq.CommandMux) {
+	p.catalogMutex.Lock()
+	defer p.catalogMutex.Unlock()
+
+	p.catalog[n] = catalogEntry{h, m, time.Now()}
+}
+
+// removeFromCatalog removes the handler for n from the catalog.
+func (p *peer) removeFromCatalog(n command.Namespace) {
+	p.catalogMutex.Lock()
+	defer p.catalogMutex.Unlock()
+
+	delete(p.catalog, n)
+}
+
 func (p *peer) run() (service.State, error) {
+	t := p.transport.get()
+
 	select {
-	case <-p.remoteStore.Done():
-		return nil, p.remoteStore.Err()
+	case <-t.remoteStore.Done():
+		return p.fail(consumerFailed(p.emitConsumerError(t.remoteStore.Err())))
 
-	case <-p.invoker.Done():
-		return nil, p.invoker.Err()
+	case <-t.invoker.Done():
+		return p.fail(consumerFailed(p.emitConsumerError(t.invoker.Err())))
 
-	case <-p.server.Done():
-		return nil, p.server.Err()
+	case <-t.server.Done():
+		return p.fail(consumerFailed(p.emitConsumerError(t.server.Err())))
 
-	case <-p.listener.Done():
-		return nil, p.listener.Err()
+	case <-t.listener.Done():
+		return p.fail(consumerFailed(p.emitConsumerError(t.listener.Err())))
+
+	case <-t.streams.Done():
+		return p.fail(consumerFailed(p.emitConsumerError(t.streams.Err())))
+
+	case req := <-p.sm.Commands:
+		p.sm.Execute(req)
+		return p.next(), nil
 
 	case <-p.sm.Graceful:
 		return p.graceful, nil
@@ -180,59 +516,166 @@ func (p *peer) run() (service.State, error) {
 	case <-p.sm.Forceful:
 		return nil, nil
 
-	case err := <-p.amqpClosed:
+	case err := <-t.amqpClosed:
+		p.emitConnectionLost(err)
+		return p.fail(connectionLost(err))
+	}
+}
+
+// disconnected is the state entered when the peer's transport has failed, or
+// could not be restarted. The peer remains in this state until it is restarted
+// or stopped.
+func (p *peer) disconnected() (service.State, error) {
+	select {
+	case req := <-p.sm.Commands:
+		p.sm.Execute(req)
+		return p.next(), nil
+
+	case <-p.sm.Graceful:
+		return nil, nil
+
+	case <-p.sm.Forceful:
+		return nil, nil
+	}
+}
+
+// next returns the state to enter after executing a command, which may have
+// restarted the peer.
+func (p *peer) next() service.State {
+	if p.connected {
+		return p.run
+	}
+
+	return p.disconnected
+}
+
+// fail handles the failure of the peer's transport. If the peer is not
+// restartable it stops with err, otherwise the transport is closed and the
+// peer waits to be restarted.
+func (p *peer) fail(err error) (service.State, error) {
+	if !p.restartable {
 		return nil, err
 	}
+
+	_ = p.transport.get().close()
+	p.connected = false
+	logDisconnected(p.logger, p.id, err)
+
+	return p.disconnected, nil
+}
+
+func (p *peer) Restart(ctx context.Context) error {
+	return p.sm.Do(func() error {
+		return p.restart(ctx)
+	})
+}
+
+// restart closes the peer's current transport and replaces it with a new one
+// that uses the same peer ID. Sessions, their notification listeners and the
+// command handlers in the catalog are restored on the new transport.
+func (p *peer) restart(ctx context.Context) error {
+	if p.connected {
+		_ = p.transport.get().close()
+		p.connected = false
+	}
+
+	t, err := p.connector.reconnect(ctx, p.id)
+	if err != nil {
+		logRestartFailed(p.logger, p.id, err)
+		return err
+	}
+
+	p.transport.set(t)
+	t.flow.Observe(p.flowChanged)
+	t.invoker.ObserveLostPeers(p.peerLost)
+
+	if err := p.restore(t); err != nil {
+		_ = t.close()
+		logRestartFailed(p.logger, p.id, err)
+		return err
+	}
+
+	p.connected = true
+	logRestarted(p.logger, p.id)
+	p.emit(rinq.PeerEvent{Type: rinq.RestartEvent})
+
+	return nil
+}
+
+// restore registers the peer's command and stream handlers, and its sessions'
+// notification and asynchronous call handlers, with the components of t.
+func (p *peer) restore(t *transport) error {
+	p.catalogMutex.RLock()
+	defer p.catalogMutex.RUnlock()
+
+	for n, e := range p.catalog {
+		if _, err := t.server.Listen(n.Name, n.Version, e.handler); err != nil {
+			return err
+		}
+	}
+
+	if err := p.restoreStreams(t); err != nil {
+		return err
+	}
+
+	p.invoker.restore()
+
+	return p.listener.restore()
 }
 
 func (p *peer) graceful() (service.State, error) {
-	p.server.GracefulStop()
-	p.invoker.GracefulStop()
-	p.remoteStore.GracefulStop()
-	p.listener.GracefulStop()
+	t := p.transport.get()
 
-	done := service.WaitAll(
-		p.remoteStore,
-		p.invoker,
-		p.server,
-		p.listener,
-	)
+	t.server.GracefulStop()
+	t.invoker.GracefulStop()
+	t.remoteStore.GracefulStop()
+	t.listener.GracefulStop()
+	t.streams.GracefulStop()
 
 	select {
-	case <-done:
+	case <-t.done():
 		return nil, nil
 
 	case <-p.sm.Forceful:
 		return nil, nil
 
-	case err := <-p.amqpClosed:
-		return nil, err
+	case err := <-t.amqpClosed:
+		p.emitConnectionLost(err)
+		return nil, connectionLost(err)
 	}
 }
 
 func (p *peer) finalize(err error) error {
-	p.server.Stop()
-	p.invoker.Stop()
-	p.remoteStore.Stop()
-	p.listener.Stop()
+	t := p.transport.get()
+	t.stop()
 
+	// wait for the sessions to be destroyed outside of Each(), as sessions
+	// remove themselves from the store before their done channel is closed.
+	var sessions []*localsession.Session
 	p.localStore.Each(func(sess *localsession.Session) {
 		sess.Destroy()
-		<-sess.Done()
+		sessions = append(sessions, sess)
 	})
 
-	<-service.WaitAll(
-		p.remoteStore,
-		p.invoker,
-		p.server,
-		p.listener,
-	)
+	for _, sess := range sessions {
+		<-sess.Done()
+	}
+
+	<-t.done()
 
-	closeErr := p.broker.Close()
+	var closeErr error
+	if p.connected {
+		closeErr = t.broker.Close()
+	}
+
+	p.closeEvents()
 
 	// only return the close err if there's no causal error.
-	if err == nil {
-		return closeErr
+	if err == nil && closeErr != nil {
+		return rinq.PeerStoppedError{
+			Reason: rinq.StopRequested,
+			Cause:  closeErr,
+		}
 	}
 
 	return err
@@ -0,0 +1,87 @@
+package rinqamqp
+
+import (
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/metrics"
+	"github.com/streadway/amqp"
+)
+
+// eventBufferSize is the capacity of the channel returned by peer.Events().
+const eventBufferSize = 64
+
+// emit delivers ev to the peer's event channel, if it has not been closed and
+// there is room in the buffer.
+func (p *peer) emit(ev rinq.PeerEvent) {
+	p.eventsMutex.Lock()
+	defer p.eventsMutex.Unlock()
+
+	if p.eventsClosed {
+		return
+	}
+
+	select {
+	case p.events <- ev:
+	default:
+	}
+}
+
+// emitConsumerError emits a consumer error event for err, if it is non-nil,
+// and returns err unchanged.
+func (p *peer) emitConsumerError(err error) error {
+	if err != nil {
+		p.emit(rinq.PeerEvent{Type: rinq.ConsumerErrorEvent, Err: err})
+	}
+
+	return err
+}
+
+// emitConnectionLost emits a connection lost event for err, which is nil if
+// the broker did not give a reason.
+func (p *peer) emitConnectionLost(err *amqp.Error) {
+	ev := rinq.PeerEvent{Type: rinq.ConnectionLostEvent}
+	if err != nil {
+		ev.Err = err
+	}
+
+	p.emit(ev)
+}
+
+// flowChanged is called when the broker blocks or unblocks the peer's
+// connection.
+func (p *peer) flowChanged(blocked bool, reason string) {
+	if blocked {
+		p.blockedAt = p.clock.Now()
+		p.blockedReason = reason
+		logConnectionBlocked(p.logger, p.id, reason)
+		p.emit(rinq.PeerEvent{Type: rinq.ConnectionBlockedEvent, Reason: reason})
+		return
+	}
+
+	d := p.clock.Now().Sub(p.blockedAt)
+	logConnectionUnblocked(p.logger, p.id, d)
+	p.emit(rinq.PeerEvent{Type: rinq.ConnectionUnblockedEvent})
+
+	if r, ok := p.metrics.(metrics.ConnectionBlockedRecorder); ok {
+		r.RecordConnectionBlocked(p.blockedReason, d)
+	}
+}
+
+// peerLost is called when a remote peer is presumed to have stopped without
+// destroying its sessions.
+func (p *peer) peerLost(id ident.PeerID) {
+	n := p.transport.get().remoteStore.ClosePeer(id)
+	logPeerLost(p.logger, p.id, id, n)
+	p.emit(rinq.PeerEvent{Type: rinq.PeerLostEvent, Peer: id})
+}
+
+// closeEvents closes the peer's event channel.
+func (p *peer) closeEvents() {
+	p.eventsMutex.Lock()
+	defer p.eventsMutex.Unlock()
+
+	if !p.eventsClosed {
+		p.eventsClosed = true
+		close(p.events)
+	}
+}
@@ -5,11 +5,19 @@ package rinqamqp_test
 import (
 	"context"
 	"math/rand"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/rinq/rinq-go/src/internal/functest"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/clock"
+	"github.com/rinq/rinq-go/src/rinq/constraint"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/metrics"
+	"github.com/rinq/rinq-go/src/rinq/options"
+	"github.com/rinq/rinq-go/src/rinq/trace"
 )
 
 var _ = Describe("peer (functional)", func() {
@@ -30,37 +38,1628 @@ var _ = Describe("peer (functional)", func() {
 			err := subject.ID().Validate()
 			Expect(err).ShouldNot(HaveOccurred())
 		})
+
+		It("uses the configured peer ID generator", func() {
+			id := ident.NewPeerID()
+
+			subject := functest.NewPeer(options.PeerIDGenerator(func() ident.PeerID {
+				return id
+			}))
+			defer subject.Stop()
+
+			Expect(subject.ID()).To(Equal(id))
+		})
+	})
+
+	Describe("Session", func() {
+		It("returns a session that belongs to this peer", func() {
+			subject := functest.SharedPeer()
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			Expect(sess.ID().Peer).To(Equal(subject.ID()))
+		})
+
+		It("returns a session with a non-zero seq component", func() {
+			subject := functest.SharedPeer()
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			Expect(sess.ID().Seq).To(BeNumerically(">", 0))
+		})
+
+		It("returns a session even if the peer is stopped", func() {
+			subject := functest.NewPeer()
+
+			subject.Stop()
+			<-subject.Done()
+
+			sess := functest.Session(subject)
+			Expect(sess).ToNot(BeNil())
+
+			sess.Destroy()
+		})
+	})
+
+	Describe("SessionLimit", func() {
+		It("returns an error if the peer owns the maximum number of sessions", func() {
+			subject := functest.NewPeer(
+				options.SessionLimit(options.SessionLimitOptions{MaxSessions: 1}),
+			)
+			defer subject.Stop()
+
+			sess, err := subject.Session()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = subject.Session()
+			Expect(err).To(Equal(rinq.SessionLimitError{Peer: subject.ID()}))
+
+			sess.Destroy()
+
+			sess, err = subject.Session()
+			Expect(err).ShouldNot(HaveOccurred())
+			sess.Destroy()
+		})
+
+		It("returns an error if sessions are created faster than the rate limit", func() {
+			clk := clock.NewManual(time.Now())
+
+			subject := functest.NewPeer(
+				options.Clock(clk),
+				options.SessionLimit(options.SessionLimitOptions{Rate: 1, Burst: 2}),
+			)
+			defer subject.Stop()
+
+			for i := 0; i < 2; i++ {
+				sess, err := subject.Session()
+				Expect(err).ShouldNot(HaveOccurred())
+				defer sess.Destroy()
+			}
+
+			_, err := subject.Session()
+			Expect(err).To(Equal(rinq.SessionLimitError{Peer: subject.ID(), RateExceeded: true}))
+
+			clk.Advance(time.Second)
+
+			sess, err := subject.Session()
+			Expect(err).ShouldNot(HaveOccurred())
+			sess.Destroy()
+		})
+	})
+
+	Describe("Session.Link", func() {
+		It("passes messages between the linked sessions", func() {
+			client := functest.NewPeer()
+			defer client.Stop()
+
+			server := functest.SharedPeer()
+
+			a := functest.Session(client)
+			defer a.Destroy()
+
+			b := functest.Session(server)
+			defer b.Destroy()
+
+			ab, err := a.Link(b.ID())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			ba, err := b.Link(a.ID())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			nonce := rand.Int63()
+			out := rinq.NewPayload(nonce)
+			defer out.Close()
+
+			functest.Must(ab.Send(ctx, out))
+
+			p, err := ba.Receive(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(p.Value()).To(BeEquivalentTo(nonce))
+			p.Close()
+
+			functest.Must(ba.Send(ctx, out))
+
+			p, err = ab.Receive(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(p.Value()).To(BeEquivalentTo(nonce))
+			p.Close()
+		})
+
+		It("returns the existing link to the same target", func() {
+			subject := functest.SharedPeer()
+
+			a := functest.Session(subject)
+			defer a.Destroy()
+
+			b := functest.Session(subject)
+			defer b.Destroy()
+
+			l1, err := a.Link(b.ID())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			l2, err := a.Link(b.ID())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(l2).To(BeIdenticalTo(l1))
+		})
+
+		It("closes the link when the session is destroyed", func() {
+			subject := functest.SharedPeer()
+
+			a := functest.Session(subject)
+			b := functest.Session(subject)
+			defer b.Destroy()
+
+			l, err := a.Link(b.ID())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			a.Destroy()
+
+			_, err = l.Receive(context.Background())
+			Expect(rinq.IsLinkClosed(err)).To(BeTrue())
+		})
+	})
+
+	Describe("Session.AwaitRevision", func() {
+		It("returns once the session is updated remotely", func() {
+			client := functest.NewPeer()
+			defer client.Stop()
+
+			server := functest.SharedPeer()
+
+			sess := functest.Session(client)
+			defer sess.Destroy()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			functest.Must(server.Listen(ns, func(ctx context.Context, req rinq.Request, res rinq.Response) {
+				rev, err := req.Source.Update(ctx, ns, rinq.Set("a", "1"))
+				if err != nil {
+					res.Error(err)
+					return
+				}
+
+				res.Done(rinq.NewPayload(rev.Ref().Rev))
+			}))
+
+			result := make(chan rinq.Revision, 1)
+			go func() {
+				defer GinkgoRecover()
+
+				rev, err := sess.AwaitRevision(ctx, 1)
+				Expect(err).ShouldNot(HaveOccurred())
+				result <- rev
+			}()
+
+			p, err := sess.Call(ctx, ns, "", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			p.Close()
+
+			var rev rinq.Revision
+			Eventually(result).Should(Receive(&rev))
+			Expect(rev.Ref().Rev).To(BeNumerically(">=", 1))
+		})
+
+		It("returns a not found error if the session is destroyed first", func() {
+			subject := functest.SharedPeer()
+
+			sess := functest.Session(subject)
+
+			result := make(chan error, 1)
+			go func() {
+				_, err := sess.AwaitRevision(context.Background(), 1)
+				result <- err
+			}()
+
+			sess.Destroy()
+
+			var err error
+			Eventually(result).Should(Receive(&err))
+			Expect(rinq.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Describe("AttrFeed", func() {
+		It("publishes attribute changes to sessions listening to the namespace", func() {
+			subject := functest.NewPeer(options.AttrFeed(ns))
+			defer subject.Stop()
+
+			listener := functest.Session(functest.SharedPeer())
+			defer listener.Destroy()
+
+			received := make(chan rinq.Notification, 1)
+			functest.Must(listener.Listen(ns, func(_ context.Context, _ rinq.Session, n rinq.Notification) {
+				received <- n
+			}))
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			rev, err := sess.CurrentRevision().Update(context.Background(), ns, rinq.Set("a", "1"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var n rinq.Notification
+			Eventually(received).Should(Receive(&n))
+			defer n.Payload.Close()
+
+			Expect(n.Type).To(Equal(rinq.AttrChangeNotification))
+			Expect(n.Source.Ref()).To(Equal(rev.Ref()))
+
+			var attrs []rinq.Attr
+			functest.Must(n.Payload.Decode(&attrs))
+			Expect(attrs).To(Equal([]rinq.Attr{rinq.Set("a", "1")}))
+		})
+	})
+
+	Describe("Session.NotifyAndWait", func() {
+		It("returns the reply sent by the target session", func() {
+			client := functest.NewPeer()
+			defer client.Stop()
+
+			server := functest.SharedPeer()
+
+			a := functest.Session(client)
+			defer a.Destroy()
+
+			b := functest.Session(server)
+			defer b.Destroy()
+
+			functest.Must(b.Listen(ns, func(ctx context.Context, target rinq.Session, n rinq.Notification) {
+				defer n.Payload.Close()
+				functest.Must(target.Reply(ctx, n, n.Payload))
+			}))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			nonce := rand.Int63()
+			out := rinq.NewPayload(nonce)
+			defer out.Close()
+
+			in, err := a.NotifyAndWait(ctx, ns, "<type>", b.ID(), out)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer in.Close()
+
+			Expect(in.Value()).To(BeEquivalentTo(nonce))
+		})
+
+		It("returns a context error if no reply is sent", func() {
+			subject := functest.SharedPeer()
+
+			a := functest.Session(subject)
+			defer a.Destroy()
+
+			b := functest.Session(subject)
+			defer b.Destroy()
+
+			functest.Must(b.Listen(ns, func(ctx context.Context, target rinq.Session, n rinq.Notification) {
+				n.Payload.Close()
+			}))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			_, err := a.NotifyAndWait(ctx, ns, "<type>", b.ID(), nil)
+			Expect(err).To(Equal(context.DeadlineExceeded))
+		})
+
+		It("returns a not found error if the session is destroyed while waiting", func() {
+			subject := functest.SharedPeer()
+
+			a := functest.Session(subject)
+
+			b := functest.Session(subject)
+			defer b.Destroy()
+
+			functest.Must(b.Listen(ns, func(ctx context.Context, target rinq.Session, n rinq.Notification) {
+				n.Payload.Close()
+				a.Destroy()
+			}))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, err := a.NotifyAndWait(ctx, ns, "<type>", b.ID(), nil)
+			Expect(rinq.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Describe("baggage", func() {
+		It("is restored in the context of the command handler", func() {
+			client := functest.NewPeer()
+			defer client.Stop()
+
+			server := functest.SharedPeer()
+			functest.Must(server.Listen(ns, func(ctx context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+
+				v, _ := rinq.Baggage(ctx, "locale")
+				p := rinq.NewPayload(v)
+				defer p.Close()
+
+				res.Done(p)
+			}))
+
+			sess := functest.Session(client)
+			defer sess.Destroy()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			ctx = rinq.WithBaggage(ctx, "locale", "en-AU")
+
+			in, err := sess.Call(ctx, ns, "", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer in.Close()
+
+			Expect(in.Value()).To(Equal("en-AU"))
+		})
+
+		It("is restored in the context of the notification handler", func() {
+			subject := functest.SharedPeer()
+
+			a := functest.Session(subject)
+			defer a.Destroy()
+
+			b := functest.Session(subject)
+			defer b.Destroy()
+
+			values := make(chan string, 1)
+			functest.Must(b.Listen(ns, func(ctx context.Context, target rinq.Session, n rinq.Notification) {
+				n.Payload.Close()
+
+				v, _ := rinq.Baggage(ctx, "locale")
+				values <- v
+			}))
+
+			ctx := rinq.WithBaggage(context.Background(), "locale", "en-AU")
+			functest.Must(a.Notify(ctx, ns, "", b.ID(), nil))
+
+			Eventually(values).Should(Receive(Equal("en-AU")))
+		})
+	})
+
+	Describe("headers", func() {
+		It("are available to the command handler", func() {
+			subject := functest.SharedPeer()
+			functest.Must(subject.Listen(ns, func(ctx context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+
+				p := rinq.NewPayload(req.Headers["token"])
+				defer p.Close()
+
+				res.Done(p)
+			}))
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			ctx = rinq.WithHeader(ctx, "token", "<token>")
+
+			in, err := sess.Call(ctx, ns, "", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer in.Close()
+
+			Expect(in.Value()).To(Equal("<token>"))
+		})
+
+		It("are available to the notification handler", func() {
+			subject := functest.SharedPeer()
+
+			a := functest.Session(subject)
+			defer a.Destroy()
+
+			b := functest.Session(subject)
+			defer b.Destroy()
+
+			headers := make(chan map[string]string, 1)
+			functest.Must(b.Listen(ns, func(ctx context.Context, target rinq.Session, n rinq.Notification) {
+				n.Payload.Close()
+				headers <- n.Headers
+			}))
+
+			ctx := rinq.WithHeader(context.Background(), "token", "<token>")
+			functest.Must(a.Notify(ctx, ns, "", b.ID(), nil))
+
+			Eventually(headers).Should(Receive(Equal(map[string]string{"token": "<token>"})))
+		})
+	})
+
+	Describe("Capabilities", func() {
+		It("returns the capabilities of this peer", func() {
+			subject := functest.SharedPeer()
+
+			caps, ok := subject.Capabilities(subject.ID())
+
+			Expect(ok).To(BeTrue())
+			Expect(caps.Revision).To(BeEquivalentTo(rinq.ProtocolRevision))
+			Expect(caps.Supports(rinq.FeatureBaggage)).To(BeTrue())
+		})
+
+		It("returns the capabilities advertised by a peer that is listening", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			server := functest.NewPeer()
+			defer server.Stop()
+
+			functest.Must(server.Listen(ns, functest.AlwaysReturn(nil)))
+
+			Eventually(func() bool {
+				caps, ok := subject.Capabilities(server.ID())
+				return ok && caps.Supports(rinq.FeatureHeaders)
+			}, 5*time.Second).Should(BeTrue())
+		})
+
+		It("returns false if the peer is unknown", func() {
+			subject := functest.SharedPeer()
+
+			_, ok := subject.Capabilities(ident.NewPeerID())
+
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("Commands", func() {
+		It("includes the commands registered with each mux", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			schema := rinq.CommandSchema{Request: "<req>"}
+			mux := rinq.NewCommandMux()
+			mux.HandleSchema("a", schema, functest.AlwaysReturn(nil))
+			mux.Handle("b", functest.AlwaysReturn(nil))
+
+			plain := functest.NewNamespace()
+
+			functest.Must(subject.ListenMux(ns, mux))
+			functest.Must(subject.Listen(plain, functest.AlwaysReturn(nil)))
+
+			var names []string
+			for _, c := range subject.Commands() {
+				names = append(names, c.Namespace+"::"+c.Command)
+
+				if c.Command == "a" {
+					Expect(c.Schema).To(Equal(schema))
+				}
+			}
+
+			Expect(names).To(ConsistOf(ns+"::a", ns+"::b", plain+"::"))
+		})
+
+		It("does not include namespaces that are no longer listened to", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			functest.Must(subject.Listen(ns, functest.AlwaysReturn(nil)))
+			functest.Must(subject.Unlisten(ns))
+
+			Expect(subject.Commands()).To(BeEmpty())
+		})
+	})
+
+	Describe("Stats", func() {
+		It("includes the number of local sessions", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			Expect(subject.Stats().LocalSessions).To(Equal(1))
+		})
+
+		It("includes pending calls and in-flight command handlers", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			barrier := make(chan struct{})
+			functest.Must(subject.Listen(ns, functest.Barrier(barrier)))
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			go sess.Call(context.Background(), ns, "", nil)
+
+			<-barrier
+			stats := subject.Stats()
+			<-barrier
+
+			Expect(stats.PendingCalls).To(Equal(1))
+			Expect(stats.InFlightCommands).To(Equal(1))
+		})
+	})
+
+	Describe("command cancellation", func() {
+		// canceled returns a handler that signals started once it is invoked,
+		// then waits for its context to be canceled and signals done.
+		canceled := func(started, done chan<- struct{}) rinq.CommandHandler {
+			return func(ctx context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+				defer res.Close()
+
+				close(started)
+				<-ctx.Done()
+				close(done)
+			}
+		}
+
+		It("cancels the handler's context when the caller's context is canceled", func() {
+			server := functest.NewPeer()
+			defer server.Stop()
+
+			client := functest.NewPeer()
+			defer client.Stop()
+
+			started := make(chan struct{})
+			done := make(chan struct{})
+			functest.Must(server.Listen(ns, canceled(started, done)))
+
+			sess := functest.Session(client)
+			defer sess.Destroy()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			go func() {
+				<-started
+				cancel()
+			}()
+
+			_, err := sess.Call(ctx, ns, "", nil)
+			Expect(err).To(Equal(context.Canceled))
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("cancels the handler's context when the handler is on the same peer", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			started := make(chan struct{})
+			done := make(chan struct{})
+			functest.Must(subject.Listen(ns, canceled(started, done)))
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			go func() {
+				<-started
+				cancel()
+			}()
+
+			_, err := sess.Call(ctx, ns, "", nil)
+			Expect(err).To(Equal(context.Canceled))
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Describe("deadline diagnostics", func() {
+		It("reports a request that was never consumed", func() {
+			client := functest.NewPeer(options.DeadlineDiagnostics(true))
+			defer client.Stop()
+
+			sess := functest.Session(client)
+			defer sess.Destroy()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			_, err := sess.Call(ctx, ns, "", nil)
+			Expect(err).To(Equal(rinq.DeadlineExceededError{
+				Stage: rinq.DeadlineStageNotConsumed,
+			}))
+		})
+
+		It("reports a handler that was still running", func() {
+			server := functest.NewPeer(options.HopMargin(100 * time.Millisecond))
+			defer server.Stop()
+
+			client := functest.NewPeer(options.DeadlineDiagnostics(true))
+			defer client.Stop()
+
+			release := make(chan struct{})
+			defer close(release)
+
+			functest.Must(server.Listen(ns, func(ctx context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+				defer res.Close()
+
+				<-release
+			}))
+
+			sess := functest.Session(client)
+			defer sess.Destroy()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			_, err := sess.Call(ctx, ns, "", nil)
+			Expect(err).To(Equal(rinq.DeadlineExceededError{
+				Stage: rinq.DeadlineStageHandling,
+			}))
+		})
+	})
+
+	Describe("slow handler detection", func() {
+		It("records handlers that exceed the threshold", func() {
+			collector := metrics.NewCollector()
+
+			server := functest.NewPeer(
+				options.SlowHandlerThreshold(10*time.Millisecond),
+				options.Metrics(collector),
+			)
+			defer server.Stop()
+
+			functest.Must(server.Listen(ns, functest.CloseAfter(50*time.Millisecond)))
+
+			sess := functest.Session(server)
+			defer sess.Destroy()
+
+			_, err := sess.Call(context.Background(), ns, "", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			// the event is recorded after the response is sent
+			Eventually(func() uint64 {
+				var n uint64
+				for _, s := range collector.Handled() {
+					n += s.SlowHandlers
+				}
+				return n
+			}).Should(BeNumerically("==", 1))
+		})
+	})
+
+	Describe("AtLeastOnce", func() {
+		It("handles requests that are executed before a peer is listening", func() {
+			subject := functest.NewPeer(options.AtLeastOnce(ns))
+			defer subject.Stop()
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			err := sess.Execute(ctx, ns, "<cmd>", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			// the request must outlive the context used to execute it
+			<-ctx.Done()
+
+			handled := make(chan string, 1)
+			functest.Must(subject.Listen(ns, func(_ context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+				res.Close()
+				handled <- req.Command
+			}))
+
+			Eventually(handled, 5*time.Second).Should(Receive(Equal("<cmd>")))
+		})
+
+		It("marks requests as handled once they have been acknowledged", func() {
+			store := &markStore{IDs: make(chan ident.MessageID, 1)}
+
+			subject := functest.NewPeer(
+				options.AtLeastOnce(ns),
+				options.DedupeStore(store),
+			)
+			defer subject.Stop()
+
+			handled := make(chan ident.MessageID, 1)
+			functest.Must(subject.Listen(ns, func(_ context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+				Expect(store.IDs).NotTo(Receive())
+				handled <- req.ID
+				res.Close()
+			}))
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			err := sess.Execute(context.Background(), ns, "<cmd>", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var id ident.MessageID
+			Eventually(handled, 5*time.Second).Should(Receive(&id))
+			Eventually(store.IDs).Should(Receive(Equal(id)))
+		})
+
+		It("does not invoke the handler for requests that have already been seen", func() {
+			store := &seenStore{IDs: make(chan ident.MessageID, 1)}
+
+			subject := functest.NewPeer(
+				options.AtLeastOnce(ns),
+				options.DedupeStore(store),
+			)
+			defer subject.Stop()
+
+			handled := make(chan string, 1)
+			functest.Must(subject.Listen(ns, func(_ context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+				res.Close()
+				handled <- req.Command
+			}))
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			err := sess.Execute(context.Background(), ns, "<cmd>", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var id ident.MessageID
+			Eventually(store.IDs, 5*time.Second).Should(Receive(&id))
+			Expect(id.Ref.ID).To(Equal(sess.ID()))
+			Consistently(handled).ShouldNot(Receive())
+		})
+	})
+
+	Describe("Session.ExecuteAt", func() {
+		// handled returns a handler that sends the time at which it is invoked
+		// to ch.
+		handled := func(ch chan<- time.Time) rinq.CommandHandler {
+			return func(ctx context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+				res.Close()
+				ch <- time.Now()
+			}
+		}
+
+		It("sends the request to the handler no earlier than the given time", func() {
+			server := functest.NewPeer()
+			defer server.Stop()
+
+			ch := make(chan time.Time, 1)
+			functest.Must(server.Listen(ns, handled(ch)))
+
+			sess := functest.Session(server)
+			defer sess.Destroy()
+
+			t := time.Now().Add(500 * time.Millisecond)
+			_, err := sess.ExecuteAt(context.Background(), t, ns, "", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			var at time.Time
+			Eventually(ch, 5*time.Second).Should(Receive(&at))
+			Expect(at).NotTo(BeTemporally("<", t))
+		})
+
+		It("does not send the request if it is canceled", func() {
+			server := functest.NewPeer()
+			defer server.Stop()
+
+			ch := make(chan time.Time, 1)
+			functest.Must(server.Listen(ns, handled(ch)))
+
+			sess := functest.Session(server)
+			defer sess.Destroy()
+
+			t := time.Now().Add(500 * time.Millisecond)
+			id, err := sess.ExecuteAt(context.Background(), t, ns, "", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			ok, err := sess.CancelExecuteAt(context.Background(), id)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+
+			Consistently(ch, time.Second).ShouldNot(Receive())
+		})
+
+		It("returns false when canceling an unknown request", func() {
+			sess := functest.Session(functest.SharedPeer())
+			defer sess.Destroy()
+
+			ok, err := sess.CancelExecuteAt(
+				context.Background(),
+				sess.ID().At(0).Message(1),
+			)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("CallAsyncFunc", func() {
+		It("passes each response to the handler for that call", func() {
+			subject := functest.SharedPeer()
+
+			functest.Must(subject.Listen(ns, func(_ context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+				res.Done(rinq.NewPayload(req.Command))
+			}))
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			sessionHandled := make(chan struct{}, 1)
+			functest.Must(sess.SetAsyncHandler(func(
+				_ context.Context,
+				_ rinq.Session, _ ident.MessageID,
+				_, _ string,
+				in *rinq.Payload, _ error,
+			) {
+				in.Close()
+				sessionHandled <- struct{}{}
+			}))
+
+			call := func(cmd string) <-chan interface{} {
+				responses := make(chan interface{}, 1)
+
+				_, err := sess.CallAsyncFunc(
+					context.Background(),
+					ns,
+					cmd,
+					nil,
+					func(
+						_ context.Context,
+						_ rinq.Session, _ ident.MessageID,
+						_, _ string,
+						in *rinq.Payload, err error,
+					) {
+						defer in.Close()
+						Expect(err).ShouldNot(HaveOccurred())
+						responses <- in.Value()
+					},
+				)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				return responses
+			}
+
+			a := call("cmd-a")
+			b := call("cmd-b")
+
+			Eventually(a).Should(Receive(Equal("cmd-a")))
+			Eventually(b).Should(Receive(Equal("cmd-b")))
+			Consistently(sessionHandled).ShouldNot(Receive())
+		})
+
+		It("passes a timeout error to the handler if there is no response", func() {
+			subject := functest.SharedPeer()
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			errs := make(chan error, 1)
+			_, err := sess.CallAsyncFunc(
+				ctx,
+				ns,
+				"<cmd>",
+				nil,
+				func(
+					_ context.Context,
+					_ rinq.Session, _ ident.MessageID,
+					_, _ string,
+					in *rinq.Payload, err error,
+				) {
+					in.Close()
+					errs <- err
+				},
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Eventually(errs).Should(Receive(Equal(context.DeadlineExceeded)))
+		})
+	})
+
+	Describe("CallFuture", func() {
+		It("provides the responses to concurrent calls", func() {
+			subject := functest.SharedPeer()
+
+			functest.Must(subject.Listen(ns, func(_ context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+				res.Done(rinq.NewPayload(req.Command))
+			}))
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			var futures []rinq.Future
+			for _, cmd := range []string{"cmd-a", "cmd-b", "cmd-c"} {
+				f, err := sess.CallFuture(context.Background(), ns, cmd, nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				futures = append(futures, f)
+			}
+
+			var values []interface{}
+			for _, f := range futures {
+				in, err := f.Result()
+				Expect(err).ShouldNot(HaveOccurred())
+				values = append(values, in.Value())
+				in.Close()
+			}
+
+			Expect(values).To(Equal([]interface{}{"cmd-a", "cmd-b", "cmd-c"}))
+		})
+	})
+
+	Describe("notification metrics", func() {
+		It("records the dispatch of notifications", func() {
+			collector := metrics.NewCollector()
+
+			subject := functest.NewPeer(options.Metrics(collector))
+			defer subject.Stop()
+
+			matching := functest.Session(subject)
+			defer matching.Destroy()
+
+			other := functest.Session(subject)
+			defer other.Destroy()
+
+			_, err := matching.CurrentRevision().Update(context.Background(), ns, rinq.Set("a", "1"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			received := make(chan struct{}, 1)
+			handler := func(_ context.Context, _ rinq.Session, n rinq.Notification) {
+				n.Payload.Close()
+				received <- struct{}{}
+			}
+			functest.Must(matching.Listen(ns, handler))
+			functest.Must(other.Listen(ns, handler))
+
+			sender := functest.Session(subject)
+			defer sender.Destroy()
+
+			err = sender.NotifyMany(
+				context.Background(),
+				ns,
+				"<type>",
+				constraint.Equal("a", "1"),
+				nil,
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			Eventually(received).Should(Receive())
+
+			Eventually(collector.Notifications).Should(Equal([]metrics.NotificationStats{
+				{
+					Namespace: ns,
+					Type:      "<type>",
+					Published: 1,
+					Listening: 2,
+					Matched:   1,
+					Delivered: 1,
+				},
+			}))
+		})
+	})
+
+	Describe("Session.SetDispatchHandler", func() {
+		It("reports the number of sessions each peer dispatched the notification to", func() {
+			subject := functest.SharedPeer()
+
+			matching := functest.Session(subject)
+			defer matching.Destroy()
+
+			other := functest.Session(subject)
+			defer other.Destroy()
+
+			_, err := matching.CurrentRevision().Update(context.Background(), ns, rinq.Set("a", "1"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			handler := func(_ context.Context, _ rinq.Session, n rinq.Notification) {
+				n.Payload.Close()
+			}
+			functest.Must(matching.Listen(ns, handler))
+			functest.Must(other.Listen(ns, handler))
+
+			sender := functest.Session(subject)
+			defer sender.Destroy()
+
+			reports := make(chan rinq.DispatchReport, 1)
+			functest.Must(sender.SetDispatchHandler(
+				func(_ context.Context, _ rinq.Session, r rinq.DispatchReport) {
+					reports <- r
+				},
+			))
+
+			err = sender.NotifyMany(
+				context.Background(),
+				ns,
+				"<type>",
+				constraint.Equal("a", "1"),
+				nil,
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var r rinq.DispatchReport
+			Eventually(reports).Should(Receive(&r))
+			Expect(r.ID.Ref.ID).To(Equal(sender.ID()))
+			Expect(r.Peer).To(Equal(subject.ID()))
+			Expect(r.Namespace).To(Equal(ns))
+			Expect(r.Type).To(Equal("<type>"))
+			Expect(r.Count).To(Equal(1))
+		})
+	})
+
+	Describe("OrderedNotifications", func() {
+		It("delivers notifications to other sessions while one session's buffer is full", func() {
+			subject := functest.NewPeer(options.OrderedNotifications(true))
+			defer subject.Stop()
+
+			slow := functest.Session(subject)
+			defer slow.Destroy()
+
+			fast := functest.Session(subject)
+			defer fast.Destroy()
+
+			unblock := make(chan struct{})
+			defer close(unblock)
+
+			functest.Must(slow.Listen(ns, func(_ context.Context, _ rinq.Session, n rinq.Notification) {
+				n.Payload.Close()
+				<-unblock
+			}))
+
+			received := make(chan struct{}, 1)
+			functest.Must(fast.Listen(ns, func(_ context.Context, _ rinq.Session, n rinq.Notification) {
+				n.Payload.Close()
+				received <- struct{}{}
+			}))
+
+			sender := functest.Session(subject)
+			defer sender.Destroy()
+
+			// the first notification blocks the slow session's handler, and
+			// the second fills its buffer
+			functest.Must(sender.Notify(context.Background(), ns, "<type>", slow.ID(), nil))
+			functest.Must(sender.Notify(context.Background(), ns, "<type>", slow.ID(), nil))
+			functest.Must(sender.Notify(context.Background(), ns, "<type>", fast.ID(), nil))
+
+			Eventually(received).Should(Receive())
+		})
+	})
+
+	Describe("adaptive command workers", func() {
+		It("continues to handle requests while handlers exceed the latency target", func() {
+			server := functest.NewPeer(
+				options.CommandWorkers(4),
+				options.AdaptiveCommandWorkers(time.Millisecond),
+			)
+			defer server.Stop()
+
+			functest.Must(server.Listen(ns, functest.CloseAfter(10*time.Millisecond)))
+
+			sess := functest.Session(server)
+			defer sess.Destroy()
+
+			deadline := time.Now().Add(3 * time.Second) // spans several adjustments
+			for time.Now().Before(deadline) {
+				_, err := sess.Call(context.Background(), ns, "", nil)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
 	})
 
-	Describe("Session", func() {
-		It("returns a session that belongs to this peer", func() {
-			subject := functest.SharedPeer()
+	Describe("handler timeout", func() {
+		It("cancels the handler's context and sends a timeout failure", func() {
+			server := functest.NewPeer(options.HandlerTimeout(ns, 50*time.Millisecond))
+			defer server.Stop()
 
-			sess := subject.Session()
+			client := functest.NewPeer()
+			defer client.Stop()
+
+			done := make(chan struct{})
+			functest.Must(server.Listen(ns, func(ctx context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+
+				<-ctx.Done()
+				res.Done(nil) // discarded, the timeout failure has already been sent
+				close(done)
+			}))
+
+			sess := functest.Session(client)
 			defer sess.Destroy()
 
-			Expect(sess.ID().Peer).To(Equal(subject.ID()))
+			_, err := sess.Call(context.Background(), ns, "", nil)
+			Expect(rinq.IsFailureType(rinq.HandlerTimeoutFailureType, err)).To(BeTrue())
+			Eventually(done).Should(BeClosed())
 		})
+	})
 
-		It("returns a session with a non-zero seq component", func() {
-			subject := functest.SharedPeer()
+	Describe("failures", func() {
+		It("preserves the failure code and fields", func() {
+			server := functest.NewPeer()
+			defer server.Stop()
 
-			sess := subject.Session()
+			client := functest.NewPeer()
+			defer client.Stop()
+
+			functest.Must(server.Listen(ns, func(ctx context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+				res.Error(rinq.Failure{
+					Type:   "invalid-argument",
+					Code:   400,
+					Fields: map[string]string{"argument": "quantity"},
+				})
+			}))
+
+			sess := functest.Session(client)
 			defer sess.Destroy()
 
-			Expect(sess.ID().Seq).To(BeNumerically(">", 0))
+			_, err := sess.Call(context.Background(), ns, "", nil)
+			Expect(rinq.IsFailureType("invalid-argument", err)).To(BeTrue())
+
+			f := err.(rinq.Failure)
+			Expect(f.Code).To(Equal(400))
+			Expect(f.Fields).To(Equal(map[string]string{"argument": "quantity"}))
 		})
+	})
 
-		It("returns a session even if the peer is stopped", func() {
+	Describe("handler panics", func() {
+		It("sends an internal error to the caller of a balanced call", func() {
+			server := functest.NewPeer()
+			defer server.Stop()
+
+			client := functest.NewPeer()
+			defer client.Stop()
+
+			functest.Must(server.Listen(ns, func(ctx context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+				panic("<panic>")
+			}))
+
+			sess := functest.Session(client)
+			defer sess.Destroy()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			ctx = trace.With(ctx, "<trace>")
+
+			start := time.Now()
+			_, err := sess.Call(ctx, ns, "", nil)
+
+			Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+			Expect(rinq.IsFailureType(rinq.InternalErrorFailureType, err)).To(BeTrue())
+			Expect(err.(rinq.Failure).Message).To(ContainSubstring("<trace>"))
+		})
+
+		It("sends an internal error to the caller of a unicast call", func() {
+			server := functest.NewPeer()
+			defer server.Stop()
+
+			client := functest.NewPeer()
+			defer client.Stop()
+
+			callback := functest.NewNamespace()
+
+			functest.Must(client.Listen(callback, func(ctx context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+				panic("<panic>")
+			}))
+
+			type result struct {
+				TraceID string
+				Elapsed time.Duration
+				Err     error
+			}
+			results := make(chan result, 1)
+
+			functest.Must(server.Listen(ns, func(ctx context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+				defer res.Close()
+
+				start := time.Now()
+				_, err := req.CallSource(ctx, callback, "", nil)
+				results <- result{trace.Get(ctx), time.Since(start), err}
+			}))
+
+			sess := functest.Session(client)
+			defer sess.Destroy()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			_, err := sess.Call(ctx, ns, "", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			var r result
+			Expect(results).To(Receive(&r))
+			Expect(r.Elapsed).To(BeNumerically("<", 5*time.Second))
+			Expect(rinq.IsFailureType(rinq.InternalErrorFailureType, r.Err)).To(BeTrue())
+			Expect(r.Err.(rinq.Failure).Message).To(ContainSubstring(r.TraceID))
+		})
+	})
+
+	Describe("poison requests", func() {
+		It("parks a balanced request that repeatedly causes the handler to panic", func() {
+			server := functest.NewPeer()
+			defer server.Stop()
+
+			client := functest.NewPeer()
+			defer client.Stop()
+
+			var deliveries int32
+			functest.Must(server.Listen(ns, func(ctx context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+				atomic.AddInt32(&deliveries, 1)
+				panic("<panic>")
+			}))
+
+			sess := functest.Session(client)
+			defer sess.Destroy()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			_, err := sess.Call(ctx, ns, "", nil)
+			Expect(rinq.IsFailureType(rinq.InternalErrorFailureType, err)).To(BeTrue())
+
+			// the request is delivered until it is considered poison, then
+			// it is not requeued again
+			count := func() int32 { return atomic.LoadInt32(&deliveries) }
+			Expect(count()).To(BeNumerically("==", 2))
+			Consistently(count, 500*time.Millisecond).Should(BeNumerically("==", 2))
+
+			broker, err := functest.Broker()
+			Expect(err).NotTo(HaveOccurred())
+			defer broker.Close()
+
+			channel, err := broker.Channel()
+			Expect(err).NotTo(HaveOccurred())
+			defer channel.Close() // requeues the parked requests of other tests
+
+			for {
+				msg, ok, err := channel.Get("cmd.parked", false) // false = manual ack
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeTrue(), "request was not parked")
+
+				if msg.Headers["x-parked-routing-key"] == ns {
+					Expect(msg.Headers["x-parked-reason"]).To(Equal("<panic>"))
+					Expect(msg.Headers["x-parked-peer"]).To(Equal(server.ID().String()))
+					Expect(msg.Ack(false)).To(Succeed())
+					break
+				}
+			}
+		})
+	})
+
+	Describe("Events", func() {
+		It("emits events when sessions are created and destroyed", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			sess := functest.Session(subject)
+			sess.Destroy()
+
+			Eventually(subject.Events()).Should(Receive(Equal(rinq.PeerEvent{
+				Type:    rinq.SessionCreatedEvent,
+				Session: sess.ID(),
+			})))
+			Eventually(subject.Events()).Should(Receive(Equal(rinq.PeerEvent{
+				Type:    rinq.SessionDestroyedEvent,
+				Session: sess.ID(),
+			})))
+		})
+
+		It("emits events when the peer starts and stops listening", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			functest.Must(subject.ListenVersion(ns, 2, functest.AlwaysReturn(nil)))
+			functest.Must(subject.UnlistenVersion(ns, 2))
+
+			Eventually(subject.Events()).Should(Receive(Equal(rinq.PeerEvent{
+				Type:      rinq.ListenEvent,
+				Namespace: ns,
+				Version:   2,
+			})))
+			Eventually(subject.Events()).Should(Receive(Equal(rinq.PeerEvent{
+				Type:      rinq.UnlistenEvent,
+				Namespace: ns,
+				Version:   2,
+			})))
+		})
+
+		It("is closed when the peer stops", func() {
 			subject := functest.NewPeer()
 
 			subject.Stop()
 			<-subject.Done()
 
-			sess := subject.Session()
-			Expect(sess).ToNot(BeNil())
+			Eventually(subject.Events()).Should(BeClosed())
+		})
+	})
+
+	Describe("Err", func() {
+		It("returns nil when the peer is stopped deliberately", func() {
+			subject := functest.NewPeer()
+
+			subject.Stop()
+			<-subject.Done()
+
+			Expect(subject.Err()).To(BeNil())
+			Expect(rinq.ShouldRedial(subject.Err())).To(BeFalse())
+		})
+	})
+
+	Describe("Restart", func() {
+		It("preserves sessions and command handlers", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			functest.Must(subject.Listen(ns, functest.AlwaysReturn(nil)))
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			_, err := sess.CurrentRevision().Update(context.Background(), ns, rinq.Set("a", "1"))
+			Expect(err).NotTo(HaveOccurred())
+
+			err = subject.Restart(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(subject.Events()).Should(Receive(Equal(rinq.PeerEvent{
+				Type: rinq.RestartEvent,
+			})))
+
+			rev, err := sess.CurrentRevision().Refresh(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+
+			attr, err := rev.Get(context.Background(), ns, "a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attr.Value).To(Equal("1"))
+
+			_, err = sess.Call(context.Background(), ns, "", nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("fails once the peer has stopped", func() {
+			subject := functest.NewPeer()
+
+			subject.Stop()
+			<-subject.Done()
+
+			err := subject.Restart(context.Background())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("ListenStream", func() {
+		It("replays notifications from the requested offset", func() {
+			subject := functest.NewPeer(options.Stream(ns, options.StreamOptions{}))
+			defer subject.Stop()
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			for _, t := range []string{"a", "b", "c"} {
+				err := sess.NotifyMany(context.Background(), ns, t, constraint.None, nil)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			types := make(chan string, 3)
+			offsets := make(chan rinq.StreamOffset, 3)
+			err := subject.ListenStream(ns, rinq.StreamFirst, func(
+				_ context.Context,
+				n rinq.Notification,
+				offset rinq.StreamOffset,
+			) {
+				n.Payload.Close()
+				types <- n.Type
+				offsets <- offset
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, t := range []string{"a", "b", "c"} {
+				Eventually(types).Should(Receive(Equal(t)))
+			}
+
+			var first rinq.StreamOffset
+			Expect(offsets).To(Receive(&first))
+
+			err = subject.ListenStream(ns, first+2, func(
+				_ context.Context,
+				n rinq.Notification,
+				_ rinq.StreamOffset,
+			) {
+				n.Payload.Close()
+				types <- n.Type
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(types).Should(Receive(Equal("c")))
+		})
+
+		It("resumes after the last notification handled when the peer is restarted", func() {
+			subject := functest.NewPeer(options.Stream(ns, options.StreamOptions{}))
+			defer subject.Stop()
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			types := make(chan string, 3)
+			err := subject.ListenStream(ns, rinq.StreamNext, func(
+				_ context.Context,
+				n rinq.Notification,
+				_ rinq.StreamOffset,
+			) {
+				n.Payload.Close()
+				types <- n.Type
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = sess.NotifyMany(context.Background(), ns, "a", constraint.None, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(types).Should(Receive(Equal("a")))
+
+			err = subject.Restart(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+
+			err = sess.NotifyMany(context.Background(), ns, "b", constraint.None, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(types).Should(Receive(Equal("b")))
+			Consistently(types).ShouldNot(Receive())
+		})
+	})
+
+	Describe("Broadcast", func() {
+		It("sends the request to every peer listening to the namespace", func() {
+			subject := functest.SharedPeer()
+
+			other := functest.NewPeer()
+			defer other.Stop()
+
+			barrier := make(chan struct{})
+			functest.Must(subject.Listen(ns, functest.BarrierN(barrier, 1)))
+			functest.Must(other.Listen(ns, functest.BarrierN(barrier, 1)))
+
+			err := subject.Broadcast(context.Background(), ns, "", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			for i := 0; i < 2; i++ {
+				select {
+				case <-barrier:
+				case <-time.After(5 * time.Second):
+					Fail("timed out waiting for the broadcast")
+				}
+			}
+		})
+	})
+
+	Describe("ListenAll", func() {
+		It("listens to all of the namespaces", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			other := functest.NewNamespace()
+
+			functest.Must(subject.ListenAll(map[string]rinq.CommandHandler{
+				ns:    functest.AlwaysReturn("<ns>"),
+				other: functest.AlwaysReturn("<other>"),
+			}))
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			p, err := sess.Call(context.Background(), ns, "", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer p.Close()
+			Expect(p.Value()).To(Equal("<ns>"))
+
+			p, err = sess.Call(context.Background(), other, "", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer p.Close()
+			Expect(p.Value()).To(Equal("<other>"))
+		})
+	})
+
+	Describe("UnlistenAll", func() {
+		It("stops listening to all namespaces and versions", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			functest.Must(subject.Listen(ns, functest.AlwaysReturn(nil)))
+			functest.Must(subject.ListenVersion(ns, 2, functest.AlwaysReturn(nil)))
+			functest.Must(subject.UnlistenAll())
+
+			Eventually(subject.Events()).Should(Receive(Equal(rinq.PeerEvent{
+				Type:      rinq.ListenEvent,
+				Namespace: ns,
+			})))
+			Eventually(subject.Events()).Should(Receive(Equal(rinq.PeerEvent{
+				Type:      rinq.ListenEvent,
+				Namespace: ns,
+				Version:   2,
+			})))
+			Eventually(subject.Events()).Should(Receive(Equal(rinq.PeerEvent{
+				Type:      rinq.UnlistenEvent,
+				Namespace: ns,
+			})))
+			Eventually(subject.Events()).Should(Receive(Equal(rinq.PeerEvent{
+				Type:      rinq.UnlistenEvent,
+				Namespace: ns,
+				Version:   2,
+			})))
+		})
+
+		It("returns nil if the peer is not listening to any namespaces", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			err := subject.UnlistenAll()
+
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("continues to serve the peer's sessions to other peers", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			server := functest.NewPeer()
+			defer server.Stop()
+
+			refreshed := make(chan error, 1)
+			functest.Must(server.Listen(ns, func(ctx context.Context, req rinq.Request, res rinq.Response) {
+				req.Payload.Close()
+				_, err := req.Source.Refresh(ctx)
+				refreshed <- err
+				res.Close()
+			}))
+
+			functest.Must(subject.UnlistenAll())
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			_, err := sess.Call(context.Background(), ns, "<cmd>", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(refreshed).To(Receive(BeNil()))
+		})
+	})
+
+	Describe("ObserveSessions", func() {
+		It("notifies the observer when sessions are created and destroyed", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			o := &sessionObserver{}
+			subject.ObserveSessions(o)
+
+			sess := functest.Session(subject)
+			Expect(o.created).To(ConsistOf(sess))
+
+			sess.Destroy()
+			<-sess.Done()
+			Expect(o.destroyed).To(ConsistOf(sess))
+		})
+
+		It("notifies the observer after the session's destroy hooks", func() {
+			subject := functest.NewPeer()
+			defer subject.Stop()
+
+			o := &sessionObserver{}
+			subject.ObserveSessions(o)
+
+			sess := functest.Session(subject)
+			sess.OnDestroy(func() {
+				Expect(o.destroyed).To(BeEmpty())
+			})
 
 			sess.Destroy()
+			<-sess.Done()
+			Expect(o.destroyed).To(ConsistOf(sess))
+		})
+
+		It("notifies the observer of sessions destroyed when the peer stops", func() {
+			subject := functest.NewPeer()
+
+			o := &sessionObserver{}
+			subject.ObserveSessions(o)
+
+			sess := functest.Session(subject)
+
+			subject.Stop()
+			<-subject.Done()
+
+			Expect(o.destroyed).To(ConsistOf(sess))
 		})
 	})
 
@@ -72,7 +1671,7 @@ var _ = Describe("peer (functional)", func() {
 			err := subject.Listen(ns, functest.AlwaysReturn(nonce))
 			Expect(err).Should(BeNil())
 
-			sess := subject.Session()
+			sess := functest.Session(subject)
 			defer sess.Destroy()
 
 			p, err := sess.Call(context.Background(), ns, "", nil)
@@ -88,7 +1687,7 @@ var _ = Describe("peer (functional)", func() {
 			err := subject.Listen(ns, functest.AlwaysPanic())
 			Expect(err).Should(BeNil())
 
-			sess := subject.Session()
+			sess := functest.Session(subject)
 			defer sess.Destroy()
 
 			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
@@ -106,7 +1705,7 @@ var _ = Describe("peer (functional)", func() {
 			err := subject.Listen(ns, functest.AlwaysReturn(nonce))
 			Expect(err).Should(BeNil())
 
-			sess := subject.Session()
+			sess := functest.Session(subject)
 			defer sess.Destroy()
 
 			p, err := sess.Call(context.Background(), ns, "", nil)
@@ -135,6 +1734,77 @@ var _ = Describe("peer (functional)", func() {
 		})
 	})
 
+	Describe("Request.CallSource", func() {
+		It("calls the peer that owns the source session", func() {
+			client := functest.NewPeer()
+			defer client.Stop()
+
+			server := functest.SharedPeer()
+
+			nonce := rand.Int63()
+			callbackNS := functest.NewNamespace()
+			functest.Must(client.Listen(callbackNS, functest.AlwaysReturn(nonce)))
+
+			functest.Must(server.Listen(ns, func(
+				ctx context.Context,
+				req rinq.Request,
+				res rinq.Response,
+			) {
+				defer req.Payload.Close()
+
+				p, err := req.CallSource(ctx, callbackNS, "", nil)
+				if err != nil {
+					res.Error(err)
+					return
+				}
+
+				res.Done(p)
+			}))
+
+			sess := functest.Session(client)
+			defer sess.Destroy()
+
+			p, err := sess.Call(context.Background(), ns, "", nil)
+			defer p.Close()
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(p.Value()).To(BeEquivalentTo(nonce))
+		})
+
+		It("does not call other peers listening to the namespace", func() {
+			client := functest.NewPeer()
+			defer client.Stop()
+
+			server := functest.SharedPeer()
+
+			callbackNS := functest.NewNamespace()
+			functest.Must(server.Listen(callbackNS, functest.AlwaysReturn(rand.Int63())))
+
+			functest.Must(server.Listen(ns, func(
+				ctx context.Context,
+				req rinq.Request,
+				res rinq.Response,
+			) {
+				defer req.Payload.Close()
+
+				ctx, cancel := context.WithTimeout(ctx, 250*time.Millisecond)
+				defer cancel()
+
+				_, err := req.CallSource(ctx, callbackNS, "", nil)
+				res.Done(rinq.NewPayload(err == context.DeadlineExceeded))
+			}))
+
+			sess := functest.Session(client)
+			defer sess.Destroy()
+
+			p, err := sess.Call(context.Background(), ns, "", nil)
+			defer p.Close()
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(p.Value()).To(BeTrue())
+		})
+	})
+
 	Describe("Unlisten", func() {
 		It("stops accepting command requests", func() {
 			subject := functest.SharedPeer()
@@ -143,7 +1813,7 @@ var _ = Describe("peer (functional)", func() {
 			err := subject.Unlisten(ns)
 			Expect(err).Should(BeNil())
 
-			sess := subject.Session()
+			sess := functest.Session(subject)
 			defer sess.Destroy()
 
 			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
@@ -180,6 +1850,59 @@ var _ = Describe("peer (functional)", func() {
 		})
 	})
 
+	Describe("ListenVersion", func() {
+		It("accepts command requests for the specified version", func() {
+			subject := functest.SharedPeer()
+
+			nonce := rand.Int63()
+			functest.Must(subject.Listen(ns, functest.AlwaysPanic()))
+			err := subject.ListenVersion(ns, 2, functest.AlwaysReturn(nonce))
+			Expect(err).Should(BeNil())
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			ctx := rinq.WithAPIVersion(context.Background(), 2)
+			p, err := sess.Call(ctx, ns, "", nil)
+			defer p.Close()
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(p.Value()).To(BeEquivalentTo(nonce))
+		})
+
+		It("does not accept command requests for other versions", func() {
+			subject := functest.SharedPeer()
+
+			err := subject.ListenVersion(ns, 2, functest.AlwaysPanic())
+			Expect(err).Should(BeNil())
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
+
+			_, err = sess.Call(rinq.WithAPIVersion(ctx, 3), ns, "", nil)
+			Expect(err).To(Equal(context.DeadlineExceeded))
+		})
+
+		It("does not accept unversioned command requests", func() {
+			subject := functest.SharedPeer()
+
+			err := subject.ListenVersion(ns, 2, functest.AlwaysPanic())
+			Expect(err).Should(BeNil())
+
+			sess := functest.Session(subject)
+			defer sess.Destroy()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
+
+			_, err = sess.Call(ctx, ns, "", nil)
+			Expect(err).To(Equal(context.DeadlineExceeded))
+		})
+	})
+
 	Describe("Stop", func() {
 		Context("when running normally", func() {
 			It("cancels pending calls", func() {
@@ -195,7 +1918,7 @@ var _ = Describe("peer (functional)", func() {
 					<-barrier
 				}()
 
-				sess := subject.Session()
+				sess := functest.Session(subject)
 				defer sess.Destroy()
 
 				_, err := sess.Call(context.Background(), ns, "", nil)
@@ -218,7 +1941,7 @@ var _ = Describe("peer (functional)", func() {
 					<-barrier
 				}()
 
-				sess := subject.Session()
+				sess := functest.Session(subject)
 				defer sess.Destroy()
 
 				_, err := sess.Call(context.Background(), ns, "", nil)
@@ -241,7 +1964,7 @@ var _ = Describe("peer (functional)", func() {
 				<-barrier
 			}()
 
-			sess := subject.Session()
+			sess := functest.Session(subject)
 			defer sess.Destroy()
 
 			_, err := sess.Call(context.Background(), ns, "", nil)
@@ -249,3 +1972,42 @@ var _ = Describe("peer (functional)", func() {
 		})
 	})
 })
+
+type sessionObserver struct {
+	created   []rinq.Session
+	destroyed []rinq.Session
+}
+
+func (o *sessionObserver) SessionCreated(sess rinq.Session) {
+	o.created = append(o.created, sess)
+}
+
+func (o *sessionObserver) SessionDestroyed(sess rinq.Session) {
+	o.destroyed = append(o.destroyed, sess)
+}
+
+// seenStore is a dedupe.Store that reports every request as already handled.
+type seenStore struct {
+	IDs chan ident.MessageID
+}
+
+func (s *seenStore) Seen(id ident.MessageID) bool {
+	s.IDs <- id
+	return true
+}
+
+func (s *seenStore) Mark(ident.MessageID) {}
+
+// markStore is a dedupe.Store that records the requests that are marked as
+// handled.
+type markStore struct {
+	IDs chan ident.MessageID
+}
+
+func (s *markStore) Seen(ident.MessageID) bool {
+	return false
+}
+
+func (s *markStore) Mark(id ident.MessageID) {
+	s.IDs <- id
+}
@@ -0,0 +1,57 @@
+package rinqamqp
+
+import "github.com/rinq/rinq-go/src/rinq"
+
+// acquireSession reserves capacity for a new session, as per the peer's
+// session limit options. It returns a rinq.SessionLimitError if the peer has
+// reached its limits.
+func (p *peer) acquireSession() error {
+	p.limitMutex.Lock()
+	defer p.limitMutex.Unlock()
+
+	max := p.sessionLimit.MaxSessions
+	if max != 0 && p.sessionCount >= max {
+		return rinq.SessionLimitError{Peer: p.id}
+	}
+
+	if p.sessionLimit.Rate != 0 {
+		burst := float64(p.sessionLimit.Burst)
+		if burst == 0 {
+			burst = 1
+		}
+
+		now := p.clock.Now()
+
+		if p.refilledAt.IsZero() {
+			p.tokens = burst
+		} else if now.After(p.refilledAt) {
+			p.tokens += now.Sub(p.refilledAt).Seconds() * p.sessionLimit.Rate
+			if p.tokens > burst {
+				p.tokens = burst
+			}
+		}
+
+		if now.After(p.refilledAt) {
+			p.refilledAt = now
+		}
+
+		if p.tokens < 1 {
+			return rinq.SessionLimitError{Peer: p.id, RateExceeded: true}
+		}
+
+		p.tokens--
+	}
+
+	p.sessionCount++
+
+	return nil
+}
+
+// releaseSession releases the capacity reserved by acquireSession() when a
+// session is destroyed.
+func (p *peer) releaseSession() {
+	p.limitMutex.Lock()
+	defer p.limitMutex.Unlock()
+
+	p.sessionCount--
+}
@@ -1,7 +1,10 @@
 package rinqamqp
 
 import (
+	"time"
+
 	"github.com/jmalloc/twelf/src/twelf"
+	"github.com/rinq/rinq-go/src/rinq"
 	"github.com/rinq/rinq-go/src/rinq/ident"
 )
 
@@ -9,22 +12,180 @@ func logStartedListening(
 	logger twelf.Logger,
 	peerID ident.PeerID,
 	namespace string,
+	version uint,
+) {
+	if version == 0 {
+		logger.Log(
+			"%s started listening for command requests in '%s' namespace",
+			peerID.ShortString(),
+			namespace,
+		)
+	} else {
+		logger.Log(
+			"%s started listening for command requests in '%s' namespace, version %d",
+			peerID.ShortString(),
+			namespace,
+			version,
+		)
+	}
+}
+
+func logStoppedListening(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	namespace string,
+	version uint,
+) {
+	if version == 0 {
+		logger.Log(
+			"%s stopped listening for command requests in '%s' namespace",
+			peerID.ShortString(),
+			namespace,
+		)
+	} else {
+		logger.Log(
+			"%s stopped listening for command requests in '%s' namespace, version %d",
+			peerID.ShortString(),
+			namespace,
+			version,
+		)
+	}
+}
+
+func logStartedStreaming(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	namespace string,
+	offset rinq.StreamOffset,
+) {
+	switch offset {
+	case rinq.StreamNext:
+		logger.Log(
+			"%s started consuming the '%s' notification stream from the next notification",
+			peerID.ShortString(),
+			namespace,
+		)
+	case rinq.StreamFirst:
+		logger.Log(
+			"%s started consuming the '%s' notification stream from the first notification",
+			peerID.ShortString(),
+			namespace,
+		)
+	case rinq.StreamLast:
+		logger.Log(
+			"%s started consuming the '%s' notification stream from the last chunk",
+			peerID.ShortString(),
+			namespace,
+		)
+	default:
+		logger.Log(
+			"%s started consuming the '%s' notification stream from offset %d",
+			peerID.ShortString(),
+			namespace,
+			offset,
+		)
+	}
+}
+
+func logStoppedStreaming(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	namespace string,
 ) {
 	logger.Log(
-		"%s started listening for command requests in '%s' namespace",
+		"%s stopped consuming the '%s' notification stream",
 		peerID.ShortString(),
 		namespace,
 	)
 }
 
-func logStoppedListening(
+func logConnectionBlocked(
 	logger twelf.Logger,
 	peerID ident.PeerID,
-	namespace string,
+	reason string,
 ) {
 	logger.Log(
-		"%s stopped listening for command requests in '%s' namespace",
+		"%s connection blocked by the broker: %s",
 		peerID.ShortString(),
-		namespace,
+		reason,
+	)
+}
+
+func logConnectionUnblocked(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	d time.Duration,
+) {
+	logger.Log(
+		"%s connection unblocked by the broker after %s",
+		peerID.ShortString(),
+		d,
+	)
+}
+
+func logDisconnected(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	err error,
+) {
+	if err == nil {
+		logger.Log(
+			"%s disconnected, waiting to be restarted",
+			peerID.ShortString(),
+		)
+	} else {
+		logger.Log(
+			"%s disconnected, waiting to be restarted: %s",
+			peerID.ShortString(),
+			err,
+		)
+	}
+}
+
+func logRestarted(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+) {
+	logger.Log(
+		"%s restarted",
+		peerID.ShortString(),
+	)
+}
+
+func logRestartFailed(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	err error,
+) {
+	logger.Log(
+		"%s restart failed: %s",
+		peerID.ShortString(),
+		err,
+	)
+}
+
+func logPeerLost(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	lostID ident.PeerID,
+	closed int,
+) {
+	logger.Log(
+		"%s presumed %s has stopped, closed %d cached session(s)",
+		peerID.ShortString(),
+		lostID.ShortString(),
+		closed,
+	)
+}
+
+func logSessionLimit(
+	logger twelf.Logger,
+	peerID ident.PeerID,
+	err error,
+) {
+	logger.Debug(
+		"%s did not create a session: %s",
+		peerID.ShortString(),
+		err,
 	)
 }
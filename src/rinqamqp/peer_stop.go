@@ -0,0 +1,28 @@
+package rinqamqp
+
+import (
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/streadway/amqp"
+)
+
+// connectionLost returns the error that causes the peer to stop when its
+// connection to the broker is closed. err is nil if the broker did not give a
+// reason.
+func connectionLost(err *amqp.Error) error {
+	e := rinq.PeerStoppedError{Reason: rinq.StopConnectionLost}
+	if err != nil {
+		e.Cause = err
+	}
+
+	return e
+}
+
+// consumerFailed returns the error that causes the peer to stop when one of
+// its consumers stops unexpectedly. err is the consumer's error, which may be
+// nil.
+func consumerFailed(err error) error {
+	return rinq.PeerStoppedError{
+		Reason: rinq.StopConsumerFailed,
+		Cause:  err,
+	}
+}
@@ -0,0 +1,83 @@
+package rinqamqp
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/rinq/rinq-go/src/internal/namespaces"
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+// streamEntry describes the handler of a notification stream that the peer
+// consumes.
+type streamEntry struct {
+	handler rinq.StreamHandler
+
+	// next is the offset at which to resume consuming the stream when the peer
+	// restarts, atomic
+	next int64
+}
+
+// handle invokes the entry's handler, then records the offset of the
+// notification after n as the position from which to resume.
+func (e *streamEntry) handle(ctx context.Context, n rinq.Notification, offset rinq.StreamOffset) {
+	e.handler(ctx, n, offset)
+	atomic.StoreInt64(&e.next, int64(offset)+1)
+}
+
+func (p *peer) ListenStream(ns string, offset rinq.StreamOffset, h rinq.StreamHandler) error {
+	namespaces.MustValidate(ns)
+
+	p.streamsMutex.Lock()
+	defer p.streamsMutex.Unlock()
+
+	e := &streamEntry{handler: h, next: int64(offset)}
+
+	added, err := p.transport.get().streams.Listen(ns, offset, e.handle)
+	if err != nil {
+		return err
+	}
+
+	p.streams[ns] = e
+
+	if added {
+		logStartedStreaming(p.logger, p.id, ns, offset)
+	}
+
+	return nil
+}
+
+func (p *peer) UnlistenStream(ns string) error {
+	namespaces.MustValidate(ns)
+
+	p.streamsMutex.Lock()
+	defer p.streamsMutex.Unlock()
+
+	removed, err := p.transport.get().streams.Unlisten(ns)
+
+	// the handler is not restored, even if the consumer could not be stopped
+	delete(p.streams, ns)
+
+	if removed {
+		logStoppedStreaming(p.logger, p.id, ns)
+	}
+
+	return err
+}
+
+// restoreStreams resumes consuming each of the peer's notification streams
+// with the stream consumer of t.
+func (p *peer) restoreStreams(t *transport) error {
+	p.streamsMutex.Lock()
+	defer p.streamsMutex.Unlock()
+
+	for ns, e := range p.streams {
+		offset := rinq.StreamOffset(atomic.LoadInt64(&e.next))
+
+		if _, err := t.streams.Listen(ns, offset, e.handle); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
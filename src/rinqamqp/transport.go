@@ -0,0 +1,219 @@
+package rinqamqp
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rinq/rinq-go/src/internal/chaos"
+	"github.com/rinq/rinq-go/src/internal/command"
+	"github.com/rinq/rinq-go/src/internal/localsession"
+	"github.com/rinq/rinq-go/src/internal/notify"
+	"github.com/rinq/rinq-go/src/internal/remotesession"
+	"github.com/rinq/rinq-go/src/internal/revisions"
+	"github.com/rinq/rinq-go/src/internal/service"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+	"github.com/rinq/rinq-go/src/rinqamqp/internal/amqputil"
+	"github.com/rinq/rinq-go/src/rinqamqp/internal/commandamqp"
+	"github.com/rinq/rinq-go/src/rinqamqp/internal/notifyamqp"
+	"github.com/streadway/amqp"
+)
+
+// transport is the set of AMQP-based components that connect a peer to the
+// broker. The entire transport is replaced when the peer is restarted.
+type transport struct {
+	broker      *amqp.Connection
+	flow        *amqputil.Flow
+	invoker     command.Invoker
+	server      command.Server
+	notifier    notify.Notifier
+	listener    notify.Listener
+	streams     notify.Streams
+	remoteStore remotesession.Store
+	amqpClosed  chan *amqp.Error
+}
+
+// stop stops the transport's consumers.
+func (t *transport) stop() {
+	t.server.Stop()
+	t.invoker.Stop()
+	t.remoteStore.Stop()
+	t.listener.Stop()
+	t.streams.Stop()
+}
+
+// done returns a channel that is closed when all of the transport's consumers
+// have stopped.
+func (t *transport) done() <-chan struct{} {
+	return service.WaitAll(
+		t.remoteStore,
+		t.invoker,
+		t.server,
+		t.listener,
+		t.streams,
+	)
+}
+
+// close stops the transport's consumers, waits for them to finish and then
+// closes the broker connection.
+func (t *transport) close() error {
+	t.stop()
+	<-t.done()
+
+	if s, ok := t.notifier.(service.Service); ok {
+		s.Stop()
+	}
+
+	return t.broker.Close()
+}
+
+// transportRef is a reference to a peer's current transport.
+type transportRef struct {
+	mutex sync.RWMutex
+	t     *transport
+}
+
+// get returns the current transport.
+func (r *transportRef) get() *transport {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.t
+}
+
+// set replaces the current transport.
+func (r *transportRef) set(t *transport) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.t = t
+}
+
+// connector establishes transports for a peer, using the configuration that
+// was used to dial the original connection.
+type connector struct {
+	dialer   Dialer
+	dsn      string
+	config   amqp.Config
+	poolSize uint
+	opts     options.Options
+	faults   *chaos.Policy // nil if fault injection is disabled
+
+	// incarnation distinguishes the peer from previous peers with the same
+	// ID. It is chosen when the peer is dialed and kept across restarts.
+	incarnation uint64
+
+	sessions *localsession.Store
+	revs     revisions.Store
+	invoker  *invokerProxy // used by components that outlive a transport
+}
+
+// dial opens a new connection to the broker.
+func (c *connector) dial(ctx context.Context) (*amqp.Connection, amqputil.ChannelPool, error) {
+	// the AMQP library does not watch ctx itself, so do not start connecting
+	// if it is already done.
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := c.config
+	if cfg.Dial == nil {
+		cfg.Dial = makeDeadlineDialer(ctx, c.dialer.NetDialer)
+	}
+
+	broker, err := amqp.DialConfig(c.dsn, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := c.dialer.checkCapabilities(broker); err != nil {
+		_ = broker.Close()
+		return nil, nil, err
+	}
+
+	return broker, amqputil.NewChannelPool(broker, c.poolSize), nil
+}
+
+// start creates the components of a transport that uses the given broker
+// connection.
+func (c *connector) start(
+	peerID ident.PeerID,
+	broker *amqp.Connection,
+	channels amqputil.ChannelPool,
+) (*transport, error) {
+	t := &transport{
+		broker:     broker,
+		flow:       amqputil.NewFlow(broker, c.opts.PauseWhenBlocked),
+		amqpClosed: make(chan *amqp.Error, 1),
+	}
+
+	var err error
+
+	t.invoker, t.server, err = commandamqp.New(peerID, c.incarnation, c.opts, c.sessions, c.revs, channels, t.flow)
+	if err != nil {
+		return nil, err
+	}
+
+	t.notifier, t.listener, t.streams, err = notifyamqp.New(peerID, c.opts, c.sessions, c.revs, channels, t.flow)
+	if err != nil {
+		return nil, err
+	}
+
+	// the policy is shared by each transport, so that the sequence of faults
+	// continues across restarts rather than repeating from the seed.
+	if c.faults != nil {
+		t.invoker = chaos.NewInvoker(t.invoker, c.faults, c.opts.DefaultTimeout)
+		t.notifier = chaos.NewNotifier(t.notifier, c.faults)
+	}
+
+	t.remoteStore = remotesession.NewStore(
+		peerID,
+		t.invoker,
+		c.opts.PruneInterval,
+		c.opts.CacheTTL,
+		c.opts.CacheSize,
+		c.opts.NotFoundTTL,
+		c.opts.Prefetch,
+		c.opts.Clock,
+		c.opts.Logger,
+		c.opts.Tracer,
+	)
+
+	if err := remotesession.Listen(
+		t.server,
+		c.invoker,
+		peerID,
+		c.sessions,
+		t.remoteStore,
+		c.opts.Logger,
+	); err != nil {
+		return nil, err
+	}
+
+	broker.NotifyClose(t.amqpClosed)
+
+	return t, nil
+}
+
+// reconnect dials a new connection to the broker, reserves the existing peer
+// ID and starts a new transport.
+func (c *connector) reconnect(ctx context.Context, peerID ident.PeerID) (*transport, error) {
+	broker, channels, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.dialer.reserveIdentity(ctx, channels, c.opts.NamePrefix, peerID)
+
+	var t *transport
+	if err == nil {
+		t, err = c.start(peerID, broker, channels)
+	}
+
+	if err != nil {
+		_ = broker.Close()
+		return nil, err
+	}
+
+	return t, nil
+}
@@ -0,0 +1,316 @@
+package rinqamqp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/constraint"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// The types in this file are given to sessions in place of the components of
+// a specific transport, so that sessions survive a restart of the peer. Each
+// forwards to the equivalent component of the current transport.
+//
+// The proxies that accept registrations from sessions record them, so that
+// they can be restored to the components of a new transport.
+
+// invokerProxy is a command.Invoker that forwards to the current transport.
+type invokerProxy struct {
+	ref *transportRef
+
+	mutex    sync.Mutex
+	handlers map[ident.SessionID]rinq.AsyncHandler
+}
+
+func newInvokerProxy(ref *transportRef) *invokerProxy {
+	return &invokerProxy{
+		ref:      ref,
+		handlers: map[ident.SessionID]rinq.AsyncHandler{},
+	}
+}
+
+func (p *invokerProxy) CallUnicast(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	target ident.PeerID,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+) (*rinq.Payload, error) {
+	return p.ref.get().invoker.CallUnicast(ctx, msgID, traceID, target, ns, cmd, out)
+}
+
+func (p *invokerProxy) CallBalanced(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+) (*rinq.Payload, error) {
+	return p.ref.get().invoker.CallBalanced(ctx, msgID, traceID, ns, cmd, out)
+}
+
+func (p *invokerProxy) CallBalancedAsync(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+	h rinq.AsyncHandler,
+) error {
+	return p.ref.get().invoker.CallBalancedAsync(ctx, msgID, traceID, ns, cmd, out, h)
+}
+
+func (p *invokerProxy) SetAsyncHandler(sessID ident.SessionID, h rinq.AsyncHandler) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if h == nil {
+		delete(p.handlers, sessID)
+	} else {
+		p.handlers[sessID] = h
+	}
+
+	p.ref.get().invoker.SetAsyncHandler(sessID, h)
+}
+
+func (p *invokerProxy) ExecuteBalanced(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+) error {
+	return p.ref.get().invoker.ExecuteBalanced(ctx, msgID, traceID, ns, cmd, out)
+}
+
+func (p *invokerProxy) ExecuteBalancedAt(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	t time.Time,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+) error {
+	return p.ref.get().invoker.ExecuteBalancedAt(ctx, msgID, traceID, t, ns, cmd, out)
+}
+
+func (p *invokerProxy) CancelScheduled(ctx context.Context, msgID ident.MessageID) (bool, error) {
+	return p.ref.get().invoker.CancelScheduled(ctx, msgID)
+}
+
+func (p *invokerProxy) ExecuteMulticast(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	ns string,
+	cmd string,
+	out *rinq.Payload,
+) error {
+	return p.ref.get().invoker.ExecuteMulticast(ctx, msgID, traceID, ns, cmd, out)
+}
+
+func (p *invokerProxy) PendingCalls() int {
+	return p.ref.get().invoker.PendingCalls()
+}
+
+func (p *invokerProxy) Capabilities(peerID ident.PeerID) (rinq.PeerCapabilities, bool) {
+	return p.ref.get().invoker.Capabilities(peerID)
+}
+
+func (p *invokerProxy) ObserveLostPeers(fn func(ident.PeerID)) {
+	p.ref.get().invoker.ObserveLostPeers(fn)
+}
+
+func (p *invokerProxy) Done() <-chan struct{} { return p.ref.get().invoker.Done() }
+func (p *invokerProxy) Err() error            { return p.ref.get().invoker.Err() }
+func (p *invokerProxy) Stop()                 { p.ref.get().invoker.Stop() }
+func (p *invokerProxy) GracefulStop()         { p.ref.get().invoker.GracefulStop() }
+
+// restore registers the recorded asynchronous call handlers with the invoker
+// of the current transport.
+func (p *invokerProxy) restore() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	i := p.ref.get().invoker
+	for id, h := range p.handlers {
+		i.SetAsyncHandler(id, h)
+	}
+}
+
+// notifierProxy is a notify.Notifier that forwards to the current transport.
+type notifierProxy struct {
+	ref *transportRef
+}
+
+func (p notifierProxy) NotifyUnicast(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	s ident.SessionID,
+	ns string,
+	t string,
+	out *rinq.Payload,
+) error {
+	return p.ref.get().notifier.NotifyUnicast(ctx, msgID, traceID, s, ns, t, out)
+}
+
+func (p notifierProxy) NotifyRequest(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	s ident.SessionID,
+	ns string,
+	t string,
+	out *rinq.Payload,
+) error {
+	return p.ref.get().notifier.NotifyRequest(ctx, msgID, traceID, s, ns, t, out)
+}
+
+func (p notifierProxy) NotifyReply(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	s ident.SessionID,
+	ns string,
+	corrID ident.MessageID,
+	out *rinq.Payload,
+) error {
+	return p.ref.get().notifier.NotifyReply(ctx, msgID, traceID, s, ns, corrID, out)
+}
+
+func (p notifierProxy) NotifyMulticast(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	con constraint.Constraint,
+	ns string,
+	t string,
+	out *rinq.Payload,
+) error {
+	return p.ref.get().notifier.NotifyMulticast(ctx, msgID, traceID, con, ns, t, out)
+}
+
+func (p notifierProxy) NotifyMulticastReport(
+	ctx context.Context,
+	msgID ident.MessageID,
+	traceID string,
+	con constraint.Constraint,
+	ns string,
+	t string,
+	out *rinq.Payload,
+) error {
+	return p.ref.get().notifier.NotifyMulticastReport(ctx, msgID, traceID, con, ns, t, out)
+}
+
+func (p notifierProxy) Queued() int {
+	return p.ref.get().notifier.Queued()
+}
+
+// listenerProxy is a notify.Listener that forwards to the current transport.
+type listenerProxy struct {
+	ref *transportRef
+
+	mutex    sync.Mutex
+	handlers map[ident.SessionID]map[string]rinq.NotificationHandler
+}
+
+func newListenerProxy(ref *transportRef) *listenerProxy {
+	return &listenerProxy{
+		ref:      ref,
+		handlers: map[ident.SessionID]map[string]rinq.NotificationHandler{},
+	}
+}
+
+func (p *listenerProxy) Listen(id ident.SessionID, ns string, h rinq.NotificationHandler) (bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	added, err := p.ref.get().listener.Listen(id, ns, h)
+
+	if err == nil {
+		m, ok := p.handlers[id]
+		if !ok {
+			m = map[string]rinq.NotificationHandler{}
+			p.handlers[id] = m
+		}
+		m[ns] = h
+	}
+
+	return added, err
+}
+
+func (p *listenerProxy) Unlisten(id ident.SessionID, ns string) (bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	removed, err := p.ref.get().listener.Unlisten(id, ns)
+
+	if err == nil {
+		if m, ok := p.handlers[id]; ok {
+			delete(m, ns)
+			if len(m) == 0 {
+				delete(p.handlers, id)
+			}
+		}
+	}
+
+	return removed, err
+}
+
+func (p *listenerProxy) UnlistenAll(id ident.SessionID) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// the session no longer wants notifications, even if the current listener
+	// can not be updated, so the registrations are not restored
+	delete(p.handlers, id)
+
+	return p.ref.get().listener.UnlistenAll(id)
+}
+
+func (p *listenerProxy) Done() <-chan struct{} { return p.ref.get().listener.Done() }
+func (p *listenerProxy) Err() error            { return p.ref.get().listener.Err() }
+func (p *listenerProxy) Stop()                 { p.ref.get().listener.Stop() }
+func (p *listenerProxy) GracefulStop()         { p.ref.get().listener.GracefulStop() }
+
+// restore registers the recorded notification handlers with the listener of
+// the current transport.
+func (p *listenerProxy) restore() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	l := p.ref.get().listener
+	for id, m := range p.handlers {
+		for ns, h := range m {
+			if _, err := l.Listen(id, ns, h); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// remoteRevisions is a revisions.Store that forwards to the remote session
+// store of the current transport.
+type remoteRevisions struct {
+	ref *transportRef
+}
+
+func (s remoteRevisions) GetRevision(ref ident.Ref) (rinq.Revision, error) {
+	return s.ref.get().remoteStore.GetRevision(ref)
+}
+
+func (s remoteRevisions) GetRevisions(ctx context.Context, refs []ident.Ref) ([]rinq.Revision, error) {
+	return s.ref.get().remoteStore.GetRevisions(ctx, refs)
+}
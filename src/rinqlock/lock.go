@@ -0,0 +1,308 @@
+package rinqlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+// maxAttempts is the maximum number of times an operation is attempted before
+// giving up because the lock session is being modified concurrently.
+const maxAttempts = 10
+
+// Lease is a lock that is held by an owner until it expires.
+type Lease struct {
+	// Name is the name of the lock, which is the key of the attribute that
+	// stores it.
+	Name string
+
+	// Owner is an application-defined identifier for the holder of the lock.
+	Owner string
+
+	// Token is the fencing token for this acquisition of the lock. It is
+	// greater than the token of any previous acquisition of the same lock, and
+	// does not change when the lease is renewed.
+	Token uint64
+
+	// ExpiresAt is the time at which the lease expires, unless it is renewed.
+	ExpiresAt time.Time
+}
+
+// Acquire acquires the lock named name, stored in the ns namespace of the lock
+// session, on behalf of owner. rev may be any revision of the lock session.
+//
+// The lease expires after ttl unless it is renewed with Renew(). If the lock
+// is already held by owner, the existing lease is extended and its token is
+// unchanged.
+//
+// If the lock is held by another owner, err is a HeldError. If the lock's
+// attribute is frozen the lock can never be acquired, and err is a
+// rinq.FrozenAttributesError.
+func Acquire(
+	ctx context.Context,
+	rev rinq.Revision,
+	ns, name, owner string,
+	ttl time.Duration,
+) (Lease, error) {
+	if owner == "" {
+		panic("owner must not be empty")
+	}
+
+	var lease Lease
+
+	err := update(ctx, rev, ns, name, func(l Lease, now time.Time) (Lease, error) {
+		if l.Owner != owner {
+			if l.Owner != "" && now.Before(l.ExpiresAt) {
+				return l, HeldError{l}
+			}
+
+			l.Owner = owner
+			l.Token++
+		}
+
+		l.ExpiresAt = now.Add(ttl)
+		lease = l
+
+		return l, nil
+	})
+
+	return lease, err
+}
+
+// Renew extends lease, which was returned by Acquire() or a previous call to
+// Renew(), such that it expires after ttl.
+//
+// If the lease has been lost, because it expired and the lock was acquired by
+// another owner, or because it was released, err is a LostError.
+func Renew(
+	ctx context.Context,
+	rev rinq.Revision,
+	ns string,
+	lease Lease,
+	ttl time.Duration,
+) (Lease, error) {
+	err := update(ctx, rev, ns, lease.Name, func(l Lease, now time.Time) (Lease, error) {
+		if l.Owner != lease.Owner || l.Token != lease.Token {
+			return l, LostError{lease}
+		}
+
+		l.ExpiresAt = now.Add(ttl)
+		lease = l
+
+		return l, nil
+	})
+
+	return lease, err
+}
+
+// Release releases lease, which was returned by Acquire() or Renew(), allowing
+// the lock to be acquired by another owner immediately.
+//
+// If the lease has already been lost, err is a LostError.
+func Release(
+	ctx context.Context,
+	rev rinq.Revision,
+	ns string,
+	lease Lease,
+) error {
+	return update(ctx, rev, ns, lease.Name, func(l Lease, now time.Time) (Lease, error) {
+		if l.Owner != lease.Owner || l.Token != lease.Token {
+			return l, LostError{lease}
+		}
+
+		// the token is retained so that the next acquisition is assigned a
+		// greater one.
+		l.Owner = ""
+		l.ExpiresAt = time.Time{}
+
+		return l, nil
+	})
+}
+
+// Inspect returns the current state of the lock named name. ok is false if the
+// lock is not held, in which case lease contains only the name and the token
+// of the most recent acquisition.
+func Inspect(
+	ctx context.Context,
+	rev rinq.Revision,
+	ns, name string,
+) (lease Lease, ok bool, err error) {
+	rev, err = rev.Refresh(ctx)
+	if err != nil {
+		return
+	}
+
+	attr, err := rev.Get(ctx, ns, name)
+	if err != nil {
+		return
+	}
+
+	lease, err = unpack(name, attr.Value)
+	if err != nil {
+		return
+	}
+
+	ok = lease.Owner != "" && time.Now().Before(lease.ExpiresAt)
+
+	return
+}
+
+// update atomically replaces the state of the lock named name with the result
+// of fn, retrying on the latest revision of the lock session if it is modified
+// concurrently.
+func update(
+	ctx context.Context,
+	rev rinq.Revision,
+	ns, name string,
+	fn func(l Lease, now time.Time) (Lease, error),
+) error {
+	for attempt := 1; ; attempt++ {
+		next, err := rev.Refresh(ctx)
+		if err != nil {
+			return err
+		}
+		rev = next
+
+		attr, err := rev.Get(ctx, ns, name)
+		if rinq.ShouldRetry(err) && attempt < maxAttempts {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if attr.IsFrozen {
+			return rinq.FrozenAttributesError{Ref: rev.Ref()}
+		}
+
+		l, err := unpack(name, attr.Value)
+		if err != nil {
+			return err
+		}
+
+		l, err = fn(l, time.Now())
+		if err != nil {
+			return err
+		}
+
+		_, err = rev.Update(ctx, ns, rinq.Set(name, pack(l)))
+		if err == nil || !rinq.ShouldRetry(err) || attempt == maxAttempts {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// pack returns the attribute value representing l, in the form
+// "<token>:<expires-at>:<owner>", where expires-at is in Unix milliseconds.
+func pack(l Lease) string {
+	var expiresAt int64
+	if !l.ExpiresAt.IsZero() {
+		expiresAt = l.ExpiresAt.UnixNano() / int64(time.Millisecond)
+	}
+
+	return fmt.Sprintf("%d:%d:%s", l.Token, expiresAt, l.Owner)
+}
+
+// unpack returns the lease represented by the attribute value v. An empty
+// value represents a lock that has never been acquired.
+func unpack(name, v string) (Lease, error) {
+	l := Lease{Name: name}
+
+	if v == "" {
+		return l, nil
+	}
+
+	parts := strings.SplitN(v, ":", 3)
+	if len(parts) != 3 {
+		return l, fmt.Errorf("lock '%s' has an invalid value: %s", name, v)
+	}
+
+	token, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return l, fmt.Errorf("lock '%s' has an invalid token: %s", name, err)
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return l, fmt.Errorf("lock '%s' has an invalid expiry time: %s", name, err)
+	}
+
+	l.Token = token
+	l.Owner = parts[2]
+
+	if expiresAt != 0 {
+		l.ExpiresAt = time.Unix(0, expiresAt*int64(time.Millisecond))
+	}
+
+	return l, nil
+}
+
+// Sentinel errors that match the error types of the same name when compared
+// using errors.Is().
+var (
+	// ErrHeld matches any HeldError.
+	ErrHeld = errors.New("lock is held")
+
+	// ErrLost matches any LostError.
+	ErrLost = errors.New("lease lost")
+)
+
+// HeldError indicates that a lock could not be acquired because it is held by
+// another owner.
+type HeldError struct {
+	Lease Lease
+}
+
+func (err HeldError) Error() string {
+	return fmt.Sprintf(
+		"lock '%s' is held by '%s' until %s",
+		err.Lease.Name,
+		err.Lease.Owner,
+		err.Lease.ExpiresAt.Format(time.RFC3339),
+	)
+}
+
+// Is returns true if target is ErrHeld.
+func (err HeldError) Is(target error) bool {
+	return target == ErrHeld
+}
+
+// IsHeld returns true if err is a HeldError.
+func IsHeld(err error) bool {
+	_, ok := err.(HeldError)
+	return ok
+}
+
+// LostError indicates that a lease could not be renewed or released because
+// it is no longer held.
+type LostError struct {
+	Lease Lease
+}
+
+func (err LostError) Error() string {
+	return fmt.Sprintf(
+		"lease on lock '%s' by '%s' (token %d) has been lost",
+		err.Lease.Name,
+		err.Lease.Owner,
+		err.Lease.Token,
+	)
+}
+
+// Is returns true if target is ErrLost.
+func (err LostError) Is(target error) bool {
+	return target == ErrLost
+}
+
+// IsLost returns true if err is a LostError.
+func IsLost(err error) bool {
+	_, ok := err.(LostError)
+	return ok
+}
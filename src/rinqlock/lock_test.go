@@ -0,0 +1,167 @@
+package rinqlock_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmalloc/twelf/src/twelf"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rinq/rinq-go/src/internal/localsession"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	"github.com/rinq/rinq-go/src/rinq/options"
+	"github.com/rinq/rinq-go/src/rinqlock"
+)
+
+var _ = Describe("lock", func() {
+	var (
+		ctx  context.Context
+		sess *localsession.Session
+		rev  rinq.Revision
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		sess = localsession.NewSession(
+			ident.NewPeerID().Session(1),
+			nil, // invoker
+			nil, // notifier
+			nil, // listener
+			options.QuotaOptions{},
+			nil, // feed
+			&twelf.StandardLogger{},
+			opentracing.NoopTracer{},
+			nil, // sampler
+		)
+		rev = sess.CurrentRevision()
+	})
+
+	Describe("Acquire", func() {
+		It("acquires a lock that has never been held", func() {
+			lease, err := rinqlock.Acquire(ctx, rev, "ns", "lock", "a", time.Minute)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(lease.Name).To(Equal("lock"))
+			Expect(lease.Owner).To(Equal("a"))
+			Expect(lease.Token).To(Equal(uint64(1)))
+			Expect(lease.ExpiresAt).To(BeTemporally("~", time.Now().Add(time.Minute), time.Second))
+		})
+
+		It("returns a HeldError if the lock is held by another owner", func() {
+			_, err := rinqlock.Acquire(ctx, rev, "ns", "lock", "a", time.Minute)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = rinqlock.Acquire(ctx, rev, "ns", "lock", "b", time.Minute)
+
+			Expect(rinqlock.IsHeld(err)).To(BeTrue())
+			Expect(err.(rinqlock.HeldError).Is(rinqlock.ErrHeld)).To(BeTrue())
+		})
+
+		It("extends the lease if the lock is already held by the same owner", func() {
+			first, err := rinqlock.Acquire(ctx, rev, "ns", "lock", "a", time.Second)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			second, err := rinqlock.Acquire(ctx, rev, "ns", "lock", "a", time.Minute)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(second.Token).To(Equal(first.Token))
+			Expect(second.ExpiresAt).To(BeTemporally(">", first.ExpiresAt))
+		})
+
+		It("acquires an expired lock with a greater token", func() {
+			first, err := rinqlock.Acquire(ctx, rev, "ns", "lock", "a", -time.Second)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			second, err := rinqlock.Acquire(ctx, rev, "ns", "lock", "b", time.Minute)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(second.Owner).To(Equal("b"))
+			Expect(second.Token).To(BeNumerically(">", first.Token))
+		})
+
+		It("returns an error if the lock attribute is frozen", func() {
+			_, err := rev.Update(ctx, "ns", rinq.Freeze("lock", ""))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = rinqlock.Acquire(ctx, rev, "ns", "lock", "a", time.Minute)
+
+			Expect(err).To(BeAssignableToTypeOf(rinq.FrozenAttributesError{}))
+		})
+	})
+
+	Describe("Renew", func() {
+		It("extends the lease", func() {
+			lease, err := rinqlock.Acquire(ctx, rev, "ns", "lock", "a", time.Second)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			renewed, err := rinqlock.Renew(ctx, rev, "ns", lease, time.Minute)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(renewed.Token).To(Equal(lease.Token))
+			Expect(renewed.ExpiresAt).To(BeTemporally(">", lease.ExpiresAt))
+		})
+
+		It("returns a LostError if the lock has been acquired by another owner", func() {
+			lease, err := rinqlock.Acquire(ctx, rev, "ns", "lock", "a", -time.Second)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = rinqlock.Acquire(ctx, rev, "ns", "lock", "b", time.Minute)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = rinqlock.Renew(ctx, rev, "ns", lease, time.Minute)
+
+			Expect(rinqlock.IsLost(err)).To(BeTrue())
+			Expect(err.(rinqlock.LostError).Is(rinqlock.ErrLost)).To(BeTrue())
+		})
+	})
+
+	Describe("Release", func() {
+		It("allows the lock to be acquired by another owner", func() {
+			lease, err := rinqlock.Acquire(ctx, rev, "ns", "lock", "a", time.Minute)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = rinqlock.Release(ctx, rev, "ns", lease)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			next, err := rinqlock.Acquire(ctx, rev, "ns", "lock", "b", time.Minute)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(next.Token).To(BeNumerically(">", lease.Token))
+		})
+
+		It("returns a LostError if the lease has already been released", func() {
+			lease, err := rinqlock.Acquire(ctx, rev, "ns", "lock", "a", time.Minute)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = rinqlock.Release(ctx, rev, "ns", lease)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = rinqlock.Release(ctx, rev, "ns", lease)
+
+			Expect(rinqlock.IsLost(err)).To(BeTrue())
+		})
+	})
+
+	Describe("Inspect", func() {
+		It("returns the lease if the lock is held", func() {
+			lease, err := rinqlock.Acquire(ctx, rev, "ns", "lock", "a", time.Minute)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			current, ok, err := rinqlock.Inspect(ctx, rev, "ns", "lock")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(current.Owner).To(Equal(lease.Owner))
+			Expect(current.Token).To(Equal(lease.Token))
+		})
+
+		It("returns false if the lock is not held", func() {
+			_, ok, err := rinqlock.Inspect(ctx, rev, "ns", "lock")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
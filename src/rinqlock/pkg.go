@@ -0,0 +1,17 @@
+// Package rinqlock provides distributed locks that are stored in the attribute
+// table of a Rinq session.
+//
+// Each lock is a single attribute in a namespace of the "lock session", which
+// is modified using the optimistic-locking guarantees of rinq.Revision.Update().
+// Any peer that has a revision of the lock session, such as the source of a
+// command request, can acquire, renew and release locks stored in it.
+//
+// Locks are leases; they expire if they are not renewed. Each acquisition is
+// assigned a fencing token that is greater than that of any previous
+// acquisition of the same lock, allowing the resources protected by the lock
+// to reject requests made by holders whose leases have expired.
+//
+// Lease expiry is determined by the wall clock of the peer performing each
+// operation, so the clocks of all participating peers should be synchronized
+// to well within the lease duration.
+package rinqlock
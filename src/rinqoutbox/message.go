@@ -0,0 +1,119 @@
+package rinqoutbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/rinq/rinq-go/src/internal/namespaces"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+)
+
+// IDHeader is the header that contains the outbox message ID of each command
+// request and notification published by the relay.
+const IDHeader = "rinqoutbox-id"
+
+// Kind is the type of operation performed when a message is published.
+type Kind int
+
+const (
+	// Execute is a message that is published with rinq.Session.Execute().
+	Execute Kind = iota
+
+	// Notify is a message that is published with rinq.Session.Notify().
+	Notify
+)
+
+// Message is a command request or notification that has been staged in the
+// outbox.
+type Message struct {
+	// ID uniquely identifies the message within the outbox.
+	ID string
+
+	// Kind is the operation performed when the message is published.
+	Kind Kind
+
+	// Namespace is the namespace of the command or notification.
+	Namespace string
+
+	// Name is the command name, or the notification type.
+	Name string
+
+	// Target is the session that a notification is sent to. It is empty for
+	// command requests.
+	Target ident.SessionID
+
+	// Payload is the binary representation of the payload, as per
+	// rinq.Payload.Bytes().
+	Payload []byte
+
+	// Headers contains the application-defined headers to send with the
+	// message, as per rinq.WithHeader(). It is nil if there are no headers.
+	Headers map[string]string
+}
+
+// NewExecute returns a message that sends a command request to the ns
+// namespace when it is published, as per rinq.Session.Execute().
+//
+// The headers added to ctx with rinq.WithHeader() are sent with the request.
+// out may be closed as soon as NewExecute() returns.
+func NewExecute(ctx context.Context, ns, cmd string, out *rinq.Payload) Message {
+	return newMessage(ctx, Execute, ns, cmd, ident.SessionID{}, out)
+}
+
+// NewNotify returns a message that sends a notification to the s session when
+// it is published, as per rinq.Session.Notify().
+//
+// The headers added to ctx with rinq.WithHeader() are sent with the
+// notification. out may be closed as soon as NewNotify() returns.
+func NewNotify(ctx context.Context, ns, t string, s ident.SessionID, out *rinq.Payload) Message {
+	ident.MustValidate(s)
+	if s.Seq == 0 {
+		panic("can not send notifications to the zero-session")
+	}
+
+	return newMessage(ctx, Notify, ns, t, s, out)
+}
+
+func newMessage(
+	ctx context.Context,
+	k Kind,
+	ns, name string,
+	target ident.SessionID,
+	out *rinq.Payload,
+) Message {
+	namespaces.MustValidate(ns)
+
+	m := Message{
+		ID:        newID(),
+		Kind:      k,
+		Namespace: ns,
+		Name:      name,
+		Target:    target,
+	}
+
+	if buf := out.Bytes(); len(buf) != 0 {
+		m.Payload = append([]byte(nil), buf...)
+	}
+
+	if h := rinq.Headers(ctx); len(h) != 0 {
+		m.Headers = make(map[string]string, len(h))
+		for k, v := range h {
+			m.Headers[k] = v
+		}
+	}
+
+	return m
+}
+
+// newID returns a random message ID.
+func newID() string {
+	var buf [16]byte
+
+	if _, err := rand.Read(buf[:]); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(buf[:])
+}
@@ -0,0 +1,73 @@
+package rinqoutbox_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	. "github.com/rinq/rinq-go/src/rinqoutbox"
+)
+
+var _ = Describe("NewExecute", func() {
+	It("returns an execute message", func() {
+		out := rinq.NewPayload(123)
+		defer out.Close()
+
+		ctx := rinq.WithHeader(context.Background(), "<key>", "<value>")
+		m := NewExecute(ctx, "ns", "<cmd>", out)
+
+		Expect(m.ID).NotTo(BeEmpty())
+		Expect(m.Kind).To(Equal(Execute))
+		Expect(m.Namespace).To(Equal("ns"))
+		Expect(m.Name).To(Equal("<cmd>"))
+		Expect(m.Target).To(Equal(ident.SessionID{}))
+		Expect(m.Payload).To(Equal(out.Bytes()))
+		Expect(m.Headers).To(Equal(map[string]string{"<key>": "<value>"}))
+	})
+
+	It("assigns a unique ID to each message", func() {
+		a := NewExecute(context.Background(), "ns", "<cmd>", nil)
+		b := NewExecute(context.Background(), "ns", "<cmd>", nil)
+
+		Expect(a.ID).NotTo(Equal(b.ID))
+	})
+
+	It("copies the payload", func() {
+		out := rinq.NewPayload(123)
+		buf := append([]byte(nil), out.Bytes()...)
+
+		m := NewExecute(context.Background(), "ns", "<cmd>", out)
+		out.Close()
+
+		Expect(m.Payload).To(Equal(buf))
+	})
+
+	It("panics if the namespace is invalid", func() {
+		Expect(func() {
+			NewExecute(context.Background(), "_ns", "<cmd>", nil)
+		}).To(Panic())
+	})
+})
+
+var _ = Describe("NewNotify", func() {
+	It("returns a notify message", func() {
+		target := ident.NewPeerID().Session(1)
+
+		m := NewNotify(context.Background(), "ns", "<type>", target, nil)
+
+		Expect(m.Kind).To(Equal(Notify))
+		Expect(m.Namespace).To(Equal("ns"))
+		Expect(m.Name).To(Equal("<type>"))
+		Expect(m.Target).To(Equal(target))
+		Expect(m.Payload).To(BeNil())
+		Expect(m.Headers).To(BeNil())
+	})
+
+	It("panics if the target is the zero-session", func() {
+		Expect(func() {
+			NewNotify(context.Background(), "ns", "<type>", ident.NewPeerID().Session(0), nil)
+		}).To(Panic())
+	})
+})
@@ -0,0 +1,29 @@
+// Package rinqoutbox implements the transactional outbox pattern for sending
+// command requests and notifications.
+//
+// When an application modifies its database and sends a message as a result,
+// the two operations can not be made atomic. If the process crashes between
+// them, either the change is lost or the message is. Instead, the application
+// stages the message in an "outbox" table within the same database transaction
+// as the change, and a relay publishes the staged messages once they have been
+// committed.
+//
+// The application stores the Message values returned by NewExecute() and
+// NewNotify() itself, using whatever database it already has, and implements
+// the Store interface to read them back. Relay() or Flush() then publishes
+// them using a Rinq session and removes them from the outbox.
+//
+// Messages are published at least once. If the relay stops after publishing a
+// message but before removing it, the message is published again. The ID of
+// each message is sent in the IDHeader header, allowing handlers to detect
+// duplicates.
+//
+// A message is removed from the outbox once the session has accepted it, so
+// the guarantee extends only as far as the session's delivery guarantee.
+// Command requests for namespaces that use options.AtLeastOnce() on the peer
+// that owns the session are removed only once the broker has persisted them.
+// Other command requests, and all notifications, are removed as soon as they
+// are handed to the peer. Such a message is lost if the peer's connection to
+// the broker fails before the broker receives it. Use options.AtLeastOnce()
+// for command requests that must not be lost.
+package rinqoutbox
@@ -0,0 +1,100 @@
+package rinqoutbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rinq/rinq-go/src/rinq"
+)
+
+// BatchSize is the maximum number of messages that are read from the store
+// at once.
+const BatchSize = 100
+
+// Relay publishes the messages in store using sess, checking for new messages
+// every interval, until ctx is canceled or an error occurs.
+//
+// It returns ctx.Err() once ctx is canceled, otherwise it returns the error
+// from the first flush that fails. A relay is typically restarted after a
+// back-off period, as messages that could not be published remain in the
+// outbox. It panics if interval is not positive.
+func Relay(
+	ctx context.Context,
+	sess rinq.Session,
+	store Store,
+	interval time.Duration,
+) error {
+	if interval <= 0 {
+		panic("relay interval must be positive")
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if _, err := Flush(ctx, sess, store); err != nil {
+			return err
+		}
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Flush publishes all of the messages in store using sess, in the order that
+// they were staged, removing each one from the store after it is published.
+//
+// n is the number of messages published. If a message can not be published,
+// Flush() stops and returns the error, leaving that message and any that
+// follow it in the outbox.
+//
+// Messages for namespaces that use options.AtLeastOnce() on the peer that owns
+// sess are not removed until the broker has persisted them.
+func Flush(ctx context.Context, sess rinq.Session, store Store) (n int, err error) {
+	for {
+		msgs, err := store.Pending(ctx, BatchSize)
+		if err != nil {
+			return n, err
+		}
+
+		for _, m := range msgs {
+			if err := publish(ctx, sess, m); err != nil {
+				return n, err
+			}
+
+			if err := store.Remove(ctx, m.ID); err != nil {
+				return n, err
+			}
+
+			n++
+		}
+
+		if len(msgs) < BatchSize {
+			return n, nil
+		}
+	}
+}
+
+// publish sends m using sess.
+func publish(ctx context.Context, sess rinq.Session, m Message) error {
+	for k, v := range m.Headers {
+		ctx = rinq.WithHeader(ctx, k, v)
+	}
+	ctx = rinq.WithHeader(ctx, IDHeader, m.ID)
+
+	out := rinq.BorrowPayload(m.Payload, nil)
+	defer out.Close()
+
+	switch m.Kind {
+	case Execute:
+		return sess.Execute(ctx, m.Namespace, m.Name, out)
+	case Notify:
+		return sess.Notify(ctx, m.Namespace, m.Name, m.Target, out)
+	default:
+		return fmt.Errorf("outbox message %s has an unknown kind (%d)", m.ID, m.Kind)
+	}
+}
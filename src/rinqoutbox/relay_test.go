@@ -0,0 +1,252 @@
+package rinqoutbox_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rinq/rinq-go/src/rinq"
+	"github.com/rinq/rinq-go/src/rinq/ident"
+	. "github.com/rinq/rinq-go/src/rinqoutbox"
+)
+
+var _ = Describe("Flush", func() {
+	var (
+		ctx    context.Context
+		sess   *recordingSession
+		store  *memoryStore
+		target ident.SessionID
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		sess = &recordingSession{}
+		store = &memoryStore{}
+		target = ident.NewPeerID().Session(1)
+	})
+
+	It("publishes the pending messages in order", func() {
+		out := rinq.NewPayload(123)
+		defer out.Close()
+
+		hctx := rinq.WithHeader(ctx, "<key>", "<value>")
+		a := NewExecute(hctx, "ns", "<cmd>", out)
+		b := NewNotify(ctx, "ns", "<type>", target, nil)
+		store.Messages = []Message{a, b}
+
+		n, err := Flush(ctx, sess, store)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(n).To(Equal(2))
+		Expect(sess.Sent).To(Equal([]sent{
+			{
+				Kind:      Execute,
+				Namespace: "ns",
+				Name:      "<cmd>",
+				Value:     uint64(123),
+				Headers:   map[string]string{"<key>": "<value>", IDHeader: a.ID},
+			},
+			{
+				Kind:      Notify,
+				Namespace: "ns",
+				Name:      "<type>",
+				Target:    target,
+				Headers:   map[string]string{IDHeader: b.ID},
+			},
+		}))
+	})
+
+	It("removes messages after they are published", func() {
+		store.Messages = []Message{
+			NewExecute(ctx, "ns", "<cmd>", nil),
+		}
+
+		_, err := Flush(ctx, sess, store)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(store.Messages).To(BeEmpty())
+	})
+
+	It("publishes more messages than fit in a single batch", func() {
+		for i := 0; i < BatchSize*2+1; i++ {
+			store.Messages = append(
+				store.Messages,
+				NewExecute(ctx, "ns", fmt.Sprintf("<cmd-%d>", i), nil),
+			)
+		}
+
+		n, err := Flush(ctx, sess, store)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(n).To(Equal(BatchSize*2 + 1))
+		Expect(sess.Sent).To(HaveLen(BatchSize*2 + 1))
+		Expect(store.Messages).To(BeEmpty())
+	})
+
+	It("leaves messages in the outbox if they can not be published", func() {
+		a := NewExecute(ctx, "ns", "<cmd>", nil)
+		b := NewExecute(ctx, "ns", "<cmd>", nil)
+		c := NewExecute(ctx, "ns", "<cmd>", nil)
+		store.Messages = []Message{a, b, c}
+
+		sess.Fail = map[string]error{
+			b.ID: errors.New("<error>"),
+		}
+
+		n, err := Flush(ctx, sess, store)
+
+		Expect(err).To(MatchError("<error>"))
+		Expect(n).To(Equal(1))
+		Expect(store.Messages).To(Equal([]Message{b, c}))
+	})
+
+	It("returns an error if the pending messages can not be read", func() {
+		store.Err = errors.New("<error>")
+
+		_, err := Flush(ctx, sess, store)
+
+		Expect(err).To(MatchError("<error>"))
+	})
+
+	It("returns an error if the message kind is unknown", func() {
+		m := NewExecute(ctx, "ns", "<cmd>", nil)
+		m.Kind = Kind(-1)
+		store.Messages = []Message{m}
+
+		_, err := Flush(ctx, sess, store)
+
+		Expect(err).Should(HaveOccurred())
+		Expect(store.Messages).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("Relay", func() {
+	It("publishes messages that are staged while it is running", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		sess := &recordingSession{}
+		store := &memoryStore{}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- Relay(ctx, sess, store, 5*time.Millisecond)
+		}()
+
+		store.Stage(NewExecute(ctx, "ns", "<cmd>", nil))
+
+		Eventually(store.Len).Should(Equal(0))
+
+		cancel()
+		Eventually(done).Should(Receive(Equal(context.Canceled)))
+		Expect(sess.Sent).To(HaveLen(1))
+	})
+
+	It("returns an error if a flush fails", func() {
+		sess := &recordingSession{}
+		store := &memoryStore{Err: errors.New("<error>")}
+
+		err := Relay(context.Background(), sess, store, time.Second)
+
+		Expect(err).To(MatchError("<error>"))
+	})
+
+	It("panics if the interval is not positive", func() {
+		Expect(func() {
+			_ = Relay(context.Background(), &recordingSession{}, &memoryStore{}, 0)
+		}).To(Panic())
+	})
+})
+
+// sent is a message that was published by a session.
+type sent struct {
+	Kind      Kind
+	Namespace string
+	Name      string
+	Target    ident.SessionID
+	Value     interface{}
+	Headers   map[string]string
+}
+
+// recordingSession is a rinq.Session that records the messages that it sends.
+type recordingSession struct {
+	rinq.Session
+
+	Sent []sent
+	Fail map[string]error // map of outbox message ID to error
+}
+
+func (s *recordingSession) Execute(ctx context.Context, ns, cmd string, out *rinq.Payload) error {
+	return s.send(ctx, sent{Kind: Execute, Namespace: ns, Name: cmd}, out)
+}
+
+func (s *recordingSession) Notify(ctx context.Context, ns, t string, target ident.SessionID, out *rinq.Payload) error {
+	return s.send(ctx, sent{Kind: Notify, Namespace: ns, Name: t, Target: target}, out)
+}
+
+func (s *recordingSession) send(ctx context.Context, m sent, out *rinq.Payload) error {
+	m.Headers = rinq.Headers(ctx)
+
+	if err := s.Fail[m.Headers[IDHeader]]; err != nil {
+		return err
+	}
+
+	m.Value = out.Value()
+	s.Sent = append(s.Sent, m)
+
+	return nil
+}
+
+// memoryStore is an in-memory Store.
+type memoryStore struct {
+	mutex    sync.Mutex
+	Messages []Message
+	Err      error
+}
+
+func (s *memoryStore) Stage(m Message) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.Messages = append(s.Messages, m)
+}
+
+func (s *memoryStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.Messages)
+}
+
+func (s *memoryStore) Pending(ctx context.Context, n int) ([]Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	if n > len(s.Messages) {
+		n = len(s.Messages)
+	}
+
+	return append([]Message(nil), s.Messages[:n]...), nil
+}
+
+func (s *memoryStore) Remove(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, m := range s.Messages {
+		if m.ID == id {
+			s.Messages = append(s.Messages[:i], s.Messages[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
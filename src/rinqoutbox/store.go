@@ -0,0 +1,19 @@
+package rinqoutbox
+
+import "context"
+
+// Store is an interface for reading messages from an application's outbox.
+//
+// Messages are added to the outbox by the application, within the same
+// database transaction as the changes that caused them to be sent. The store
+// only returns messages from transactions that have been committed.
+type Store interface {
+	// Pending returns up to n messages that have not yet been removed, in the
+	// order that they were staged.
+	Pending(ctx context.Context, n int) ([]Message, error)
+
+	// Remove removes the message with the given ID from the outbox, once it
+	// has been published. It is not an error to remove a message that has
+	// already been removed.
+	Remove(ctx context.Context, id string) error
+}